@@ -1,6 +1,7 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"os"
 
@@ -9,7 +10,13 @@ import (
 
 func main() {
 	if err := cli.Execute(); err != nil {
+		if errors.Is(err, cli.ErrNoChanges) {
+			os.Exit(cli.ExitNoChanges)
+		}
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		if hint := cli.ErrorHint(err); hint != "" {
+			fmt.Fprintf(os.Stderr, "Hint: %s\n", hint)
+		}
 		os.Exit(1)
 	}
 }
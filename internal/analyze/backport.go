@@ -0,0 +1,81 @@
+package analyze
+
+import (
+	"fmt"
+	"strings"
+)
+
+// maxBackportLines bounds the differing lines listed per file
+const maxBackportLines = 5
+
+// BackportDifferences describes how diff, the backport of a commit, differs
+// from original, the diff of that commit: files changed by only one of them,
+// and lines added or removed by only one of them, which usually come from
+// resolving conflicts. It returns nil when the backport applies unchanged.
+func BackportDifferences(diff, original string) []string {
+	backportFiles := SplitFiles(diff)
+	originalFiles := make(map[string]FileDiff)
+	for _, file := range SplitFiles(original) {
+		originalFiles[file.Path] = file
+	}
+
+	var differences []string
+	seen := make(map[string]bool)
+	for _, file := range backportFiles {
+		seen[file.Path] = true
+		orig, ok := originalFiles[file.Path]
+		if !ok {
+			differences = append(differences, file.Path+": changed by the backport only")
+			continue
+		}
+
+		var parts []string
+		if only := lineDifference(file, orig); len(only) > 0 {
+			parts = append(parts, "only in the backport "+formatLines(only))
+		}
+		if only := lineDifference(orig, file); len(only) > 0 {
+			parts = append(parts, "only in the original "+formatLines(only))
+		}
+		if len(parts) > 0 {
+			differences = append(differences, file.Path+": "+strings.Join(parts, "; "))
+		}
+	}
+	for _, file := range SplitFiles(original) {
+		if !seen[file.Path] {
+			differences = append(differences, file.Path+": changed by the original commit only, left out of the backport")
+		}
+	}
+	return differences
+}
+
+// lineDifference returns the net changed lines of file that other doesn't
+// have as often
+func lineDifference(file, other FileDiff) []string {
+	counts := make(map[string]int)
+	for _, line := range netLines(other) {
+		counts[line]++
+	}
+
+	var only []string
+	for _, line := range netLines(file) {
+		if counts[line] > 0 {
+			counts[line]--
+			continue
+		}
+		only = append(only, line)
+	}
+	return only
+}
+
+// formatLines quotes up to maxBackportLines lines for a difference
+func formatLines(lines []string) string {
+	quoted := make([]string, 0, min(len(lines), maxBackportLines))
+	for _, line := range lines[:min(len(lines), maxBackportLines)] {
+		quoted = append(quoted, fmt.Sprintf("%q", line[:1]+strings.TrimSpace(line[1:])))
+	}
+	result := strings.Join(quoted, ", ")
+	if len(lines) > maxBackportLines {
+		result += fmt.Sprintf(" and %d more", len(lines)-maxBackportLines)
+	}
+	return result
+}
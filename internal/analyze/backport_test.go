@@ -0,0 +1,30 @@
+package analyze
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBackportDifferences(t *testing.T) {
+	original := "diff --git a/client.go b/client.go\n--- a/client.go\n+++ b/client.go\n@@ -10,2 +10,3 @@\n-	timeout := 0\n+	timeout := 30 * time.Second\n+	retries := 3\n" +
+		"diff --git a/CHANGELOG.md b/CHANGELOG.md\n--- a/CHANGELOG.md\n+++ b/CHANGELOG.md\n@@ -1 +1,2 @@\n # Changes\n+- Add a client timeout\n"
+
+	// Applied unchanged, at other line numbers
+	clean := "diff --git a/client.go b/client.go\n--- a/client.go\n+++ b/client.go\n@@ -8,2 +8,3 @@\n-	timeout := 0\n+	timeout := 30 * time.Second\n+	retries := 3\n" +
+		"diff --git a/CHANGELOG.md b/CHANGELOG.md\n--- a/CHANGELOG.md\n+++ b/CHANGELOG.md\n@@ -1 +1,2 @@\n # Changes\n+- Add a client timeout\n"
+	assert.Nil(t, BackportDifferences(clean, original))
+
+	// A line-by-line diff shows an insertion as every following line replaced
+	inserted := "diff --git a/list.txt b/list.txt\n--- a/list.txt\n+++ b/list.txt\n@@ -1,3 +1,4 @@\n a\n+x\n b\n c\n"
+	shifted := "diff --git a/list.txt b/list.txt\nindex xxxxxxx..xxxxxxx 100644\n--- a/list.txt\n+++ b/list.txt\n-b\n+x\n-c\n+b\n+c"
+	assert.Nil(t, BackportDifferences(shifted, inserted))
+
+	resolved := "diff --git a/client.go b/client.go\n--- a/client.go\n+++ b/client.go\n@@ -8,2 +8,3 @@\n-	timeout := 0\n+	timeout := 30 * time.Second\n+	retries := defaultRetries\n" +
+		"diff --git a/legacy.go b/legacy.go\n--- a/legacy.go\n+++ b/legacy.go\n@@ -1 +1 @@\n-const defaultRetries = 0\n+const defaultRetries = 3\n"
+	assert.Equal(t, []string{
+		`client.go: only in the backport "+retries := defaultRetries"; only in the original "+retries := 3"`,
+		"legacy.go: changed by the backport only",
+		"CHANGELOG.md: changed by the original commit only, left out of the backport",
+	}, BackportDifferences(resolved, original))
+}
@@ -0,0 +1,161 @@
+// Package analyze inspects diffs for facts worth telling the model, such as
+// breaking API changes, without calling it.
+package analyze
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/nseba/commit-ai/internal/diffparse"
+)
+
+var (
+	// exportedFunc matches an exported Go function or method declaration and
+	// captures the receiver type and the name
+	exportedFunc = regexp.MustCompile(`^func\s+(?:\(\s*\w*\s*\*?\s*(\w+)(?:\[[^\]]*\])?\s*\)\s*)?([A-Z]\w*)`)
+	// exportedType matches an exported Go type declaration
+	exportedType = regexp.MustCompile(`^type\s+([A-Z]\w*)\b`)
+	// modulePath matches the module directive of a go.mod file
+	modulePath = regexp.MustCompile(`^module\s+(\S+)`)
+)
+
+// FileDiff is the part of a unified diff that belongs to one file
+type FileDiff struct {
+	Path    string
+	Raw     string
+	Added   []string
+	Removed []string
+}
+
+// SplitFiles splits a unified diff into per-file added and removed lines
+func SplitFiles(diff string) []FileDiff {
+	_, sections := diffparse.Split(diff)
+	files := make([]FileDiff, 0, len(sections))
+	for _, section := range sections {
+		file := FileDiff{Path: section.Path(), Raw: section.Raw}
+		for _, line := range strings.Split(section.Body, "\n") {
+			switch {
+			case strings.HasPrefix(line, "+"):
+				file.Added = append(file.Added, line[1:])
+			case strings.HasPrefix(line, "-"):
+				file.Removed = append(file.Removed, line[1:])
+			}
+		}
+		files = append(files, file)
+	}
+
+	return files
+}
+
+// netLines returns the removed and added lines of file, marked with "-" and
+// "+", leaving out lines that are both removed and added. Those only moved,
+// or were shifted by a line-by-line diff, which shows an insertion as every
+// following line replaced.
+func netLines(file FileDiff) []string {
+	added := make(map[string]int)
+	for _, line := range file.Added {
+		added[line]++
+	}
+
+	var lines []string
+	for _, line := range file.Removed {
+		if added[line] > 0 {
+			added[line]--
+			continue
+		}
+		lines = append(lines, "-"+line)
+	}
+	for _, line := range file.Added {
+		if added[line] > 0 {
+			added[line]--
+			lines = append(lines, "+"+line)
+		}
+	}
+	return lines
+}
+
+// DetectBreaking returns human-readable reasons why the diff likely breaks
+// the public API: removed or changed exported Go declarations and go.mod
+// module path changes. It returns nil when nothing was found.
+func DetectBreaking(diff string) []string {
+	var reasons []string
+	for _, file := range SplitFiles(diff) {
+		switch {
+		case file.Path == "go.mod" || strings.HasSuffix(file.Path, "/go.mod"):
+			reasons = append(reasons, moduleChanges(file)...)
+		case strings.HasSuffix(file.Path, ".go") && !strings.HasSuffix(file.Path, "_test.go"):
+			reasons = append(reasons, goAPIChanges(file)...)
+		}
+	}
+	return reasons
+}
+
+// goAPIChanges compares the exported declarations removed from and added to a Go file
+func goAPIChanges(file FileDiff) []string {
+	removed := exportedDecls(file.Removed)
+	added := exportedDecls(file.Added)
+
+	names := make([]string, 0, len(removed))
+	for name := range removed {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var reasons []string
+	for _, name := range names {
+		signature, ok := added[name]
+		switch {
+		case !ok:
+			reasons = append(reasons, fmt.Sprintf("%s: exported %s was removed or renamed", file.Path, name))
+		case signature != removed[name]:
+			reasons = append(reasons, fmt.Sprintf("%s: signature of exported %s changed", file.Path, name))
+		}
+	}
+	return reasons
+}
+
+// exportedDecls maps exported declaration names (Type.Method for methods) to
+// their normalized signature
+func exportedDecls(lines []string) map[string]string {
+	decls := make(map[string]string)
+	for _, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if m := exportedFunc.FindStringSubmatch(trimmed); m != nil && line == trimmed {
+			name := m[2]
+			if m[1] != "" {
+				name = m[1] + "." + name
+			}
+			decls[name] = normalizeSignature(trimmed)
+		} else if m := exportedType.FindStringSubmatch(trimmed); m != nil && line == trimmed {
+			decls[m[1]] = normalizeSignature(trimmed)
+		}
+	}
+	return decls
+}
+
+// normalizeSignature drops the opening brace and collapses whitespace
+func normalizeSignature(line string) string {
+	line = strings.TrimSuffix(strings.TrimSpace(line), "{")
+	return strings.Join(strings.Fields(line), " ")
+}
+
+// moduleChanges reports a changed module path, e.g. a new major version
+func moduleChanges(file FileDiff) []string {
+	var oldPath, newPath string
+	for _, line := range file.Removed {
+		if m := modulePath.FindStringSubmatch(line); m != nil {
+			oldPath = m[1]
+		}
+	}
+	for _, line := range file.Added {
+		if m := modulePath.FindStringSubmatch(line); m != nil {
+			newPath = m[1]
+		}
+	}
+	if oldPath == "" || newPath == "" || oldPath == newPath {
+		return nil
+	}
+	return []string{fmt.Sprintf("%s: module path changed from %s to %s", file.Path, oldPath, newPath)}
+}
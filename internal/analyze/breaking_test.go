@@ -0,0 +1,55 @@
+package analyze
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDetectBreaking_GoAPI(t *testing.T) {
+	diff := `diff --git a/client.go b/client.go
+--- a/client.go
++++ b/client.go
+-func NewClient(url string) *Client {
++func NewClient(url string, timeout time.Duration) *Client {
+-func (c *Client) Close() error {
+-type Options struct {
++func (c *Client) Shutdown() error {
+-func helper() {}
++func helper(x int) {}
+-func Stable() {
++func Stable()  {
+diff --git a/client_test.go b/client_test.go
+--- a/client_test.go
++++ b/client_test.go
+-func TestOld(t *testing.T) {`
+
+	reasons := DetectBreaking(diff)
+
+	assert.Equal(t, []string{
+		"client.go: exported Client.Close was removed or renamed",
+		"client.go: signature of exported NewClient changed",
+		"client.go: exported Options was removed or renamed",
+	}, reasons)
+}
+
+func TestDetectBreaking_ModulePath(t *testing.T) {
+	diff := `diff --git a/go.mod b/go.mod
+--- a/go.mod
++++ b/go.mod
+-module github.com/acme/lib
++module github.com/acme/lib/v2`
+
+	assert.Equal(t, []string{"go.mod: module path changed from github.com/acme/lib to github.com/acme/lib/v2"}, DetectBreaking(diff))
+}
+
+func TestDetectBreaking_None(t *testing.T) {
+	diff := `diff --git a/client.go b/client.go
+--- a/client.go
++++ b/client.go
++func NewHelper() {}
+-	return nil
++	return err`
+
+	assert.Nil(t, DetectBreaking(diff))
+}
@@ -0,0 +1,111 @@
+package analyze
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var (
+	// packageJSONDep matches a "name": "version" entry of package.json
+	packageJSONDep = regexp.MustCompile(`^\s*"([^"]+)"\s*:\s*"([~^>=<]*\s*\d[^"]*)",?\s*$`)
+	// requirementsDep matches a pinned or ranged requirements.txt entry
+	requirementsDep = regexp.MustCompile(`^\s*([A-Za-z0-9][A-Za-z0-9._\-\[\]]*)\s*(?:==|>=|~=)\s*([^\s;#,]+)`)
+	// cargoDep matches name = "1.2" and name = { version = "1.2", ... } entries of Cargo.toml
+	cargoDep = regexp.MustCompile(`^\s*([A-Za-z0-9_\-]+)\s*=\s*(?:"([^"]+)"|\{.*\bversion\s*=\s*"([^"]+)".*\})`)
+)
+
+// manifestKeys are package.json and Cargo.toml keys that are not dependencies
+var manifestKeys = map[string]bool{
+	"version": true, "name": true, "edition": true, "rust-version": true,
+	"description": true, "main": true, "license": true,
+}
+
+// lockFiles change alongside manifests but carry no information worth keeping
+var lockFiles = map[string]bool{
+	"go.sum":            true,
+	"package-lock.json": true,
+	"yarn.lock":         true,
+	"pnpm-lock.yaml":    true,
+	"Cargo.lock":        true,
+}
+
+// DetectDependencyBumps reports the dependency version changes of a diff that
+// only touches dependency manifests and lock files (go.mod, package.json,
+// requirements*.txt, Cargo.toml and their lock files). It returns false when
+// any other file changed or no version change was found.
+func DetectDependencyBumps(diff string) ([]Bump, bool) {
+	var bumps []Bump
+	for _, file := range SplitFiles(diff) {
+		base := path.Base(file.Path)
+		switch {
+		case lockFiles[base]:
+		case base == "go.mod":
+			bumps = append(bumps, GoModBumps(file)...)
+		case base == "package.json":
+			bumps = append(bumps, manifestBumps(file, packageJSONDep, trimRange)...)
+		case base == "Cargo.toml":
+			bumps = append(bumps, manifestBumps(file, cargoDep, nil)...)
+		case strings.HasPrefix(base, "requirements") && strings.HasSuffix(base, ".txt"):
+			bumps = append(bumps, manifestBumps(file, requirementsDep, nil)...)
+		default:
+			return nil, false
+		}
+	}
+	return bumps, len(bumps) > 0
+}
+
+// ComposeBumpMessage builds a dependabot-style commit message for bumps
+func ComposeBumpMessage(bumps []Bump) string {
+	if len(bumps) == 1 {
+		return "chore(deps): " + bumps[0].String()
+	}
+
+	lines := make([]string, 0, len(bumps))
+	for _, bump := range bumps {
+		lines = append(lines, "- "+bump.String())
+	}
+	return fmt.Sprintf("chore(deps): bump %d dependencies\n\n%s", len(bumps), strings.Join(lines, "\n"))
+}
+
+// manifestBumps compares the name/version pairs matched by pattern on the
+// removed and added lines of a manifest
+func manifestBumps(file FileDiff, pattern *regexp.Regexp, clean func(string) string) []Bump {
+	extract := func(lines []string) map[string]string {
+		versions := make(map[string]string)
+		for _, line := range lines {
+			m := pattern.FindStringSubmatch(line)
+			if m == nil || manifestKeys[m[1]] {
+				continue
+			}
+			version := m[2]
+			if version == "" && len(m) > 3 {
+				version = m[3]
+			}
+			if clean != nil {
+				version = clean(version)
+			}
+			versions[m[1]] = version
+		}
+		return versions
+	}
+
+	removed := extract(file.Removed)
+	added := extract(file.Added)
+
+	var bumps []Bump
+	for name, to := range added {
+		if from, ok := removed[name]; ok && from != to {
+			bumps = append(bumps, Bump{Name: name, From: from, To: to})
+		}
+	}
+	sort.Slice(bumps, func(i, j int) bool { return bumps[i].Name < bumps[j].Name })
+	return bumps
+}
+
+// trimRange strips npm range operators from a version
+func trimRange(version string) string {
+	return strings.TrimSpace(strings.TrimLeft(version, "~^>=<"))
+}
@@ -0,0 +1,64 @@
+package analyze
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDetectDependencyBumps(t *testing.T) {
+	tests := []struct {
+		name  string
+		diff  string
+		bumps []Bump
+	}{
+		{
+			name:  "go.mod",
+			diff:  "diff --git a/go.mod b/go.mod\n-\tgolang.org/x/sys v0.33.0\n+\tgolang.org/x/sys v0.34.0\ndiff --git a/go.sum b/go.sum\n-golang.org/x/sys v0.33.0 h1:x=\n+golang.org/x/sys v0.34.0 h1:y=",
+			bumps: []Bump{{Name: "golang.org/x/sys", From: "v0.33.0", To: "v0.34.0"}},
+		},
+		{
+			name:  "package.json",
+			diff:  "diff --git a/package.json b/package.json\n-  \"version\": \"1.0.0\",\n+  \"version\": \"1.0.1\",\n-    \"react\": \"^18.2.0\",\n+    \"react\": \"^18.3.1\",\ndiff --git a/package-lock.json b/package-lock.json\n-      \"version\": \"18.2.0\"\n+      \"version\": \"18.3.1\"",
+			bumps: []Bump{{Name: "react", From: "18.2.0", To: "18.3.1"}},
+		},
+		{
+			name:  "requirements.txt",
+			diff:  "diff --git a/requirements.txt b/requirements.txt\n-requests==2.31.0\n+requests==2.32.3",
+			bumps: []Bump{{Name: "requests", From: "2.31.0", To: "2.32.3"}},
+		},
+		{
+			name:  "Cargo.toml",
+			diff:  "diff --git a/Cargo.toml b/Cargo.toml\n-serde = { version = \"1.0.190\", features = [\"derive\"] }\n+serde = { version = \"1.0.210\", features = [\"derive\"] }\n-anyhow = \"1.0.75\"\n+anyhow = \"1.0.86\"",
+			bumps: []Bump{{Name: "anyhow", From: "1.0.75", To: "1.0.86"}, {Name: "serde", From: "1.0.190", To: "1.0.210"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bumps, ok := DetectDependencyBumps(tt.diff)
+			require.True(t, ok)
+			assert.Equal(t, tt.bumps, bumps)
+		})
+	}
+}
+
+func TestDetectDependencyBumps_OtherFiles(t *testing.T) {
+	diff := "diff --git a/go.mod b/go.mod\n-\tgolang.org/x/sys v0.33.0\n+\tgolang.org/x/sys v0.34.0\ndiff --git a/main.go b/main.go\n+// uses the new API"
+
+	_, ok := DetectDependencyBumps(diff)
+	assert.False(t, ok)
+
+	// Lock file only changes carry no bump
+	_, ok = DetectDependencyBumps("diff --git a/go.sum b/go.sum\n+golang.org/x/sys v0.34.0 h1:y=")
+	assert.False(t, ok)
+}
+
+func TestComposeBumpMessage(t *testing.T) {
+	one := []Bump{{Name: "react", From: "18.2.0", To: "18.3.1"}}
+	assert.Equal(t, "chore(deps): bump react from 18.2.0 to 18.3.1", ComposeBumpMessage(one))
+
+	two := append(one, Bump{Name: "vite", From: "5.0.0", To: "5.1.0"})
+	assert.Equal(t, "chore(deps): bump 2 dependencies\n\n- bump react from 18.2.0 to 18.3.1\n- bump vite from 5.0.0 to 5.1.0", ComposeBumpMessage(two))
+}
@@ -0,0 +1,149 @@
+package analyze
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var (
+	// requireLine matches a go.mod requirement, inside or outside a require block
+	requireLine = regexp.MustCompile(`^\s*(?:require\s+)?([^\s()]+)\s+(v\S+)`)
+	// generatedHeader matches the standard generated code marker
+	generatedHeader = regexp.MustCompile(`^// Code generated .* DO NOT EDIT\.$`)
+)
+
+// Bump is a dependency version change
+type Bump struct {
+	Name string
+	From string
+	To   string
+}
+
+// String renders the bump the way dependabot does
+func (b Bump) String() string {
+	return fmt.Sprintf("bump %s from %s to %s", b.Name, b.From, b.To)
+}
+
+// GoEnrichment is the result of inspecting a diff of a Go repository
+type GoEnrichment struct {
+	// Diff is the diff with go.sum and generated file sections summarized
+	Diff string
+	// Notes describe the change for the prompt, one per entry
+	Notes []string
+	// Bumps are the go.mod dependency version changes
+	Bumps []Bump
+}
+
+// EnrichGo inspects a diff that touches Go files. go.sum changes and
+// generated files are replaced by one-line summaries to save tokens,
+// dependency bumps and test-only changes are called out as notes. Diffs
+// without Go files are returned unchanged with no notes.
+func EnrichGo(diff string) *GoEnrichment {
+	files := SplitFiles(diff)
+	result := &GoEnrichment{Diff: diff}
+	if !touchesGo(files) {
+		return result
+	}
+
+	var sections, generated []string
+	onlyTests := true
+	goFiles := 0
+	for _, file := range files {
+		base := path.Base(file.Path)
+		switch {
+		case base == "go.sum":
+			sections = append(sections, fmt.Sprintf("# %s changed (checksums omitted)", file.Path))
+			continue
+		case base == "go.mod":
+			result.Bumps = append(result.Bumps, GoModBumps(file)...)
+		case strings.HasSuffix(file.Path, ".go"):
+			goFiles++
+			if !strings.HasSuffix(file.Path, "_test.go") {
+				onlyTests = false
+			}
+			if isGenerated(file) {
+				generated = append(generated, file.Path)
+				sections = append(sections, fmt.Sprintf("# generated file %s changed (+%d/-%d lines, content omitted)", file.Path, len(file.Added), len(file.Removed)))
+				continue
+			}
+		default:
+			onlyTests = false
+		}
+		sections = append(sections, file.Raw)
+	}
+	result.Diff = strings.Join(sections, "\n")
+
+	if len(result.Bumps) > 0 {
+		bumps := make([]string, 0, len(result.Bumps))
+		for _, bump := range result.Bumps {
+			bumps = append(bumps, bump.String())
+		}
+		result.Notes = append(result.Notes, fmt.Sprintf("Dependency updates: %s. Prefer a \"chore(deps): ...\" message.", strings.Join(bumps, "; ")))
+	}
+	if len(generated) > 0 {
+		result.Notes = append(result.Notes, fmt.Sprintf("Generated files changed: %s. Describe the source change, not the generated code.", strings.Join(generated, ", ")))
+	}
+	if goFiles > 0 && onlyTests {
+		result.Notes = append(result.Notes, "Only test files changed. Use the \"test\" type.")
+	}
+
+	return result
+}
+
+// GoModBumps returns the requirement version changes of a go.mod diff
+func GoModBumps(file FileDiff) []Bump {
+	removed := requirements(file.Removed)
+	added := requirements(file.Added)
+
+	var bumps []Bump
+	for name, to := range added {
+		if from, ok := removed[name]; ok && from != to {
+			bumps = append(bumps, Bump{Name: name, From: from, To: to})
+		}
+	}
+	sort.Slice(bumps, func(i, j int) bool { return bumps[i].Name < bumps[j].Name })
+	return bumps
+}
+
+// requirements maps module paths to versions for go.mod require lines
+func requirements(lines []string) map[string]string {
+	reqs := make(map[string]string)
+	for _, line := range lines {
+		if strings.HasPrefix(strings.TrimSpace(line), "module ") || strings.HasPrefix(strings.TrimSpace(line), "go ") {
+			continue
+		}
+		if m := requireLine.FindStringSubmatch(line); m != nil {
+			reqs[m[1]] = m[2]
+		}
+	}
+	return reqs
+}
+
+// isGenerated reports whether the diff shows a generated code marker
+func isGenerated(file FileDiff) bool {
+	for _, line := range append(append([]string{}, file.Added...), file.Removed...) {
+		if generatedHeader.MatchString(strings.TrimSpace(line)) {
+			return true
+		}
+	}
+	for _, line := range strings.Split(file.Raw, "\n") {
+		if strings.HasPrefix(line, " ") && generatedHeader.MatchString(strings.TrimSpace(line)) {
+			return true
+		}
+	}
+	return false
+}
+
+// touchesGo reports whether any file in the diff belongs to a Go module
+func touchesGo(files []FileDiff) bool {
+	for _, file := range files {
+		base := path.Base(file.Path)
+		if base == "go.mod" || base == "go.sum" || strings.HasSuffix(base, ".go") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,66 @@
+package analyze
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEnrichGo_DependencyBump(t *testing.T) {
+	diff := `diff --git a/go.mod b/go.mod
+--- a/go.mod
++++ b/go.mod
+-	github.com/spf13/cobra v1.8.0
++	github.com/spf13/cobra v1.9.1
+diff --git a/go.sum b/go.sum
+--- a/go.sum
++++ b/go.sum
+-github.com/spf13/cobra v1.8.0 h1:abc=
++github.com/spf13/cobra v1.9.1 h1:def=`
+
+	result := EnrichGo(diff)
+
+	assert.Equal(t, []Bump{{Name: "github.com/spf13/cobra", From: "v1.8.0", To: "v1.9.1"}}, result.Bumps)
+	assert.NotContains(t, result.Diff, "h1:abc=")
+	assert.Contains(t, result.Diff, "# go.sum changed (checksums omitted)")
+	assert.Contains(t, result.Diff, "+\tgithub.com/spf13/cobra v1.9.1")
+	assert.Equal(t, []string{`Dependency updates: bump github.com/spf13/cobra from v1.8.0 to v1.9.1. Prefer a "chore(deps): ..." message.`}, result.Notes)
+}
+
+func TestEnrichGo_GeneratedAndTests(t *testing.T) {
+	diff := `diff --git a/api/api.pb.go b/api/api.pb.go
+--- a/api/api.pb.go
++++ b/api/api.pb.go
++// Code generated by protoc-gen-go. DO NOT EDIT.
++package api
+diff --git a/api/api_test.go b/api/api_test.go
+--- a/api/api_test.go
++++ b/api/api_test.go
++func TestNew(t *testing.T) {}`
+
+	result := EnrichGo(diff)
+
+	assert.NotContains(t, result.Diff, "package api")
+	assert.Contains(t, result.Diff, "# generated file api/api.pb.go changed (+2/-0 lines, content omitted)")
+	assert.Contains(t, result.Diff, "+func TestNew")
+	assert.Equal(t, []string{"Generated files changed: api/api.pb.go. Describe the source change, not the generated code."}, result.Notes)
+}
+
+func TestEnrichGo_TestOnly(t *testing.T) {
+	diff := `diff --git a/foo_test.go b/foo_test.go
+--- a/foo_test.go
++++ b/foo_test.go
++func TestFoo(t *testing.T) {}`
+
+	result := EnrichGo(diff)
+	assert.Equal(t, []string{`Only test files changed. Use the "test" type.`}, result.Notes)
+	assert.Equal(t, diff, result.Diff)
+}
+
+func TestEnrichGo_NonGo(t *testing.T) {
+	diff := "diff --git a/README.md b/README.md\n+hello"
+
+	result := EnrichGo(diff)
+	assert.Equal(t, diff, result.Diff)
+	assert.Empty(t, result.Notes)
+}
@@ -0,0 +1,60 @@
+package analyze
+
+import "strings"
+
+// FileGroup is a set of files of a diff that belong together, such as the
+// files below one top-level directory
+type FileGroup struct {
+	// Name is the top-level directory with a trailing slash, or "." for
+	// files in the repository root
+	Name  string
+	Files []FileDiff
+}
+
+// Added returns the number of added lines in the group
+func (g FileGroup) Added() int {
+	n := 0
+	for _, file := range g.Files {
+		n += len(file.Added)
+	}
+	return n
+}
+
+// Removed returns the number of removed lines in the group
+func (g FileGroup) Removed() int {
+	n := 0
+	for _, file := range g.Files {
+		n += len(file.Removed)
+	}
+	return n
+}
+
+// Diff returns the diff of the files in the group
+func (g FileGroup) Diff() string {
+	sections := make([]string, 0, len(g.Files))
+	for _, file := range g.Files {
+		sections = append(sections, file.Raw)
+	}
+	return strings.Join(sections, "\n")
+}
+
+// GroupFiles splits a diff into groups by top-level directory, in order of
+// first appearance
+func GroupFiles(diff string) []FileGroup {
+	var groups []FileGroup
+	index := make(map[string]int)
+	for _, file := range SplitFiles(diff) {
+		name := "."
+		if dir, _, found := strings.Cut(file.Path, "/"); found {
+			name = dir + "/"
+		}
+		i, ok := index[name]
+		if !ok {
+			i = len(groups)
+			index[name] = i
+			groups = append(groups, FileGroup{Name: name})
+		}
+		groups[i].Files = append(groups[i].Files, file)
+	}
+	return groups
+}
@@ -0,0 +1,28 @@
+package analyze
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGroupFiles(t *testing.T) {
+	diff := "diff --git a/internal/a.go b/internal/a.go\n+a\n+b\n" +
+		"diff --git a/README.md b/README.md\n-old\n+new\n" +
+		"diff --git a/internal/cli/b.go b/internal/cli/b.go\n-c"
+
+	groups := GroupFiles(diff)
+	require.Len(t, groups, 2)
+
+	assert.Equal(t, "internal/", groups[0].Name)
+	assert.Len(t, groups[0].Files, 2)
+	assert.Equal(t, 2, groups[0].Added())
+	assert.Equal(t, 1, groups[0].Removed())
+	assert.Equal(t, "diff --git a/internal/a.go b/internal/a.go\n+a\n+b\ndiff --git a/internal/cli/b.go b/internal/cli/b.go\n-c", groups[0].Diff())
+
+	assert.Equal(t, ".", groups[1].Name)
+	assert.Equal(t, "README.md", groups[1].Files[0].Path)
+
+	assert.Empty(t, GroupFiles(""))
+}
@@ -0,0 +1,205 @@
+package analyze
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Reasons for an ignore suggestion, in the order suggestions are listed
+const (
+	IgnoreVendored  = "vendored"
+	IgnoreGenerated = "generated"
+	IgnoreLarge     = "large"
+)
+
+// IgnoreHeadBytes is how much of the start of a file SuggestIgnores
+// searches for a generated marker
+const IgnoreHeadBytes = 1024
+
+var (
+	// vendoredDirs are directories holding third-party code
+	vendoredDirs = map[string]bool{
+		"vendor":           true,
+		"node_modules":     true,
+		"third_party":      true,
+		"bower_components": true,
+	}
+
+	// generatedNames maps file name patterns of generated files and
+	// lockfiles to how they are described
+	generatedNames = map[string]string{
+		"*.pb.go":           "protobuf code",
+		"*_pb2.py":          "protobuf code",
+		"*.min.js":          "minified code",
+		"*.min.css":         "minified code",
+		"*.map":             "source maps",
+		"package-lock.json": "lockfile",
+		"yarn.lock":         "lockfile",
+		"pnpm-lock.yaml":    "lockfile",
+		"Cargo.lock":        "lockfile",
+		"poetry.lock":       "lockfile",
+		"Pipfile.lock":      "lockfile",
+		"composer.lock":     "lockfile",
+		"Gemfile.lock":      "lockfile",
+	}
+
+	// generatedMarker matches the markers code generators put at the top
+	// of their output
+	generatedMarker = regexp.MustCompile(`(?i)code generated .* do not edit|@generated\b|auto-?generated`)
+)
+
+// RepoFile is a tracked file of a repository
+type RepoFile struct {
+	Path string
+	Size int64
+}
+
+// IgnoreSuggestion is a proposed .caiignore pattern
+type IgnoreSuggestion struct {
+	Pattern string
+	// Reason is IgnoreVendored, IgnoreGenerated or IgnoreLarge
+	Reason string
+	// Detail describes the matched files, e.g. "42 files" or "2.1 MB"
+	Detail string
+}
+
+// SuggestIgnores proposes .caiignore patterns for the tracked files that
+// make poor prompt material: vendored directories, generated files and
+// lockfiles, and files of at least largeBytes (zero skips the size check).
+// head returns the first IgnoreHeadBytes of a file, to look for generated
+// markers. Files are suggested by directory when everything below it is
+// generated.
+func SuggestIgnores(files []RepoFile, largeBytes int64, head func(name string) ([]byte, error)) []IgnoreSuggestion {
+	var suggestions []IgnoreSuggestion
+	covered := make(map[string]bool)
+
+	vendored := make(map[string]int)
+	for _, file := range files {
+		if dir, ok := vendoredDir(file.Path); ok {
+			vendored[dir]++
+			covered[file.Path] = true
+		}
+	}
+	for dir, count := range vendored {
+		suggestions = append(suggestions, IgnoreSuggestion{Pattern: "/" + dir + "/", Reason: IgnoreVendored, Detail: countFiles(count)})
+	}
+
+	byName := make(map[string]int)
+	for _, file := range files {
+		if covered[file.Path] {
+			continue
+		}
+		for pattern := range generatedNames {
+			if ok, _ := path.Match(pattern, path.Base(file.Path)); ok {
+				byName[pattern]++
+				covered[file.Path] = true
+				break
+			}
+		}
+	}
+	for pattern, count := range byName {
+		suggestions = append(suggestions, IgnoreSuggestion{Pattern: pattern, Reason: IgnoreGenerated, Detail: generatedNames[pattern] + ", " + countFiles(count)})
+	}
+
+	// Files with a generated marker are grouped by the shallowest directory
+	// holding nothing else
+	generated := make(map[string]bool)
+	for _, file := range files {
+		if covered[file.Path] {
+			continue
+		}
+		if content, err := head(file.Path); err == nil && hasGeneratedMarker(content) {
+			generated[file.Path] = true
+		}
+	}
+	total, marked := make(map[string]int), make(map[string]int)
+	for _, file := range files {
+		if covered[file.Path] {
+			continue
+		}
+		for _, dir := range parentDirs(file.Path) {
+			total[dir]++
+			if generated[file.Path] {
+				marked[dir]++
+			}
+		}
+	}
+	groups := make(map[string]int)
+	for file := range generated {
+		pattern := "/" + file
+		for _, dir := range parentDirs(file) {
+			if marked[dir] == total[dir] && marked[dir] > 1 {
+				pattern = "/" + dir + "/"
+				break
+			}
+		}
+		groups[pattern]++
+		covered[file] = true
+	}
+	for pattern, count := range groups {
+		detail := "generated code marker"
+		if count > 1 {
+			detail += ", " + countFiles(count)
+		}
+		suggestions = append(suggestions, IgnoreSuggestion{Pattern: pattern, Reason: IgnoreGenerated, Detail: detail})
+	}
+
+	for _, file := range files {
+		if !covered[file.Path] && largeBytes > 0 && file.Size >= largeBytes {
+			suggestions = append(suggestions, IgnoreSuggestion{Pattern: "/" + file.Path, Reason: IgnoreLarge, Detail: formatSize(file.Size)})
+		}
+	}
+
+	order := map[string]int{IgnoreVendored: 0, IgnoreGenerated: 1, IgnoreLarge: 2}
+	sort.Slice(suggestions, func(i, j int) bool {
+		a, b := suggestions[i], suggestions[j]
+		if a.Reason != b.Reason {
+			return order[a.Reason] < order[b.Reason]
+		}
+		return a.Pattern < b.Pattern
+	})
+	return suggestions
+}
+
+// vendoredDir returns the path of the outermost vendored directory file is
+// in
+func vendoredDir(file string) (string, bool) {
+	parts := strings.Split(file, "/")
+	for i, part := range parts[:len(parts)-1] {
+		if vendoredDirs[part] {
+			return strings.Join(parts[:i+1], "/"), true
+		}
+	}
+	return "", false
+}
+
+// parentDirs returns the directories containing file, outermost first,
+// without the repository root
+func parentDirs(file string) []string {
+	parts := strings.Split(file, "/")
+	dirs := make([]string, 0, len(parts)-1)
+	for i := 1; i < len(parts); i++ {
+		dirs = append(dirs, strings.Join(parts[:i], "/"))
+	}
+	return dirs
+}
+
+// hasGeneratedMarker reports whether the start of a file carries a generated
+// code marker
+func hasGeneratedMarker(content []byte) bool {
+	if len(content) > IgnoreHeadBytes {
+		content = content[:IgnoreHeadBytes]
+	}
+	return generatedMarker.Match(content)
+}
+
+// countFiles formats a file count
+func countFiles(count int) string {
+	if count == 1 {
+		return "1 file"
+	}
+	return fmt.Sprintf("%d files", count)
+}
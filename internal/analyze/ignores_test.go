@@ -0,0 +1,53 @@
+package analyze
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSuggestIgnores(t *testing.T) {
+	files := []RepoFile{
+		{Path: "main.go", Size: 100},
+		{Path: "vendor/github.com/pkg/errors/errors.go", Size: 100},
+		{Path: "vendor/modules.txt", Size: 100},
+		{Path: "web/node_modules/react/index.js", Size: 100},
+		{Path: "web/package-lock.json", Size: 100},
+		{Path: "web/dist/app.min.js", Size: 100},
+		{Path: "api/v1/api.pb.go", Size: 100},
+		{Path: "internal/mocks/store.go", Size: 100},
+		{Path: "internal/mocks/client.go", Size: 100},
+		{Path: "internal/store/zz_generated.go", Size: 100},
+		{Path: "internal/store/store.go", Size: 100},
+		{Path: "testdata/dump.sql", Size: 2 << 20},
+	}
+	heads := map[string]string{
+		"internal/mocks/store.go":        "// Code generated by MockGen. DO NOT EDIT.\npackage mocks\n",
+		"internal/mocks/client.go":       "// Code generated by MockGen. DO NOT EDIT.\npackage mocks\n",
+		"internal/store/zz_generated.go": "// This file is auto-generated.\npackage store\n",
+		"internal/store/store.go":        "package store\n",
+	}
+	head := func(name string) ([]byte, error) {
+		content, ok := heads[name]
+		if !ok {
+			return nil, os.ErrNotExist
+		}
+		return []byte(content), nil
+	}
+
+	assert.Equal(t, []IgnoreSuggestion{
+		{Pattern: "/vendor/", Reason: IgnoreVendored, Detail: "2 files"},
+		{Pattern: "/web/node_modules/", Reason: IgnoreVendored, Detail: "1 file"},
+		{Pattern: "*.min.js", Reason: IgnoreGenerated, Detail: "minified code, 1 file"},
+		{Pattern: "*.pb.go", Reason: IgnoreGenerated, Detail: "protobuf code, 1 file"},
+		{Pattern: "/internal/mocks/", Reason: IgnoreGenerated, Detail: "generated code marker, 2 files"},
+		{Pattern: "/internal/store/zz_generated.go", Reason: IgnoreGenerated, Detail: "generated code marker"},
+		{Pattern: "package-lock.json", Reason: IgnoreGenerated, Detail: "lockfile, 1 file"},
+		{Pattern: "/testdata/dump.sql", Reason: IgnoreLarge, Detail: "2.0 MB"},
+	}, SuggestIgnores(files, 512<<10, head))
+
+	// Without a size limit, large files are not suggested
+	suggestions := SuggestIgnores([]RepoFile{{Path: "dump.sql", Size: 2 << 20}}, 0, head)
+	assert.Empty(t, suggestions)
+}
@@ -0,0 +1,221 @@
+package analyze
+
+import (
+	"path"
+	"regexp"
+	"strings"
+)
+
+// Language is a programming or markup language recognized in diffs
+type Language struct {
+	// Name is the human-readable name, e.g. "Go"
+	Name string
+	// Fence is the Markdown code fence info string, e.g. "go"
+	Fence string
+}
+
+// languagesByExt maps lower-case file extensions to their language
+var languagesByExt = map[string]Language{
+	".go":    {"Go", "go"},
+	".py":    {"Python", "python"},
+	".js":    {"JavaScript", "javascript"},
+	".mjs":   {"JavaScript", "javascript"},
+	".cjs":   {"JavaScript", "javascript"},
+	".jsx":   {"JavaScript", "jsx"},
+	".ts":    {"TypeScript", "typescript"},
+	".tsx":   {"TypeScript", "tsx"},
+	".java":  {"Java", "java"},
+	".kt":    {"Kotlin", "kotlin"},
+	".kts":   {"Kotlin", "kotlin"},
+	".scala": {"Scala", "scala"},
+	".rb":    {"Ruby", "ruby"},
+	".rs":    {"Rust", "rust"},
+	".c":     {"C", "c"},
+	".h":     {"C", "c"},
+	".cc":    {"C++", "cpp"},
+	".cpp":   {"C++", "cpp"},
+	".hpp":   {"C++", "cpp"},
+	".cs":    {"C#", "csharp"},
+	".swift": {"Swift", "swift"},
+	".php":   {"PHP", "php"},
+	".sh":    {"Shell", "sh"},
+	".bash":  {"Shell", "bash"},
+	".zsh":   {"Shell", "zsh"},
+	".ps1":   {"PowerShell", "powershell"},
+	".sql":   {"SQL", "sql"},
+	".html":  {"HTML", "html"},
+	".css":   {"CSS", "css"},
+	".scss":  {"SCSS", "scss"},
+	".vue":   {"Vue", "vue"},
+	".md":    {"Markdown", "markdown"},
+	".rst":   {"reStructuredText", "rst"},
+	".adoc":  {"AsciiDoc", "asciidoc"},
+	".txt":   {"Text", "text"},
+	".json":  {"JSON", "json"},
+	".yaml":  {"YAML", "yaml"},
+	".yml":   {"YAML", "yaml"},
+	".toml":  {"TOML", "toml"},
+	".xml":   {"XML", "xml"},
+	".proto": {"Protocol Buffers", "protobuf"},
+	".tf":    {"Terraform", "hcl"},
+	".lua":   {"Lua", "lua"},
+}
+
+// languagesByName maps well-known file names without a telling extension
+var languagesByName = map[string]Language{
+	"Dockerfile":  {"Dockerfile", "dockerfile"},
+	"Makefile":    {"Makefile", "makefile"},
+	"go.mod":      {"Go Module", "go"},
+	"go.sum":      {"Go Module", "text"},
+	"Gemfile":     {"Ruby", "ruby"},
+	"Rakefile":    {"Ruby", "ruby"},
+	"Jenkinsfile": {"Groovy", "groovy"},
+}
+
+// languagesByInterpreter maps shebang interpreters to their language
+var languagesByInterpreter = map[string]Language{
+	"sh":      {"Shell", "sh"},
+	"bash":    {"Shell", "bash"},
+	"zsh":     {"Shell", "zsh"},
+	"python":  {"Python", "python"},
+	"python3": {"Python", "python"},
+	"node":    {"JavaScript", "javascript"},
+	"ruby":    {"Ruby", "ruby"},
+	"perl":    {"Perl", "perl"},
+}
+
+// firstLineHunk matches a hunk header whose new side starts at line 1
+var firstLineHunk = regexp.MustCompile(`^@@ -\d+(?:,\d+)? \+1(?:,\d+)? @@`)
+
+// DetectLanguage returns the language of a file from its name or, failing
+// that, from the shebang on its first line. ok is false when the language is
+// unknown.
+func DetectLanguage(file, firstLine string) (Language, bool) {
+	base := path.Base(file)
+	if lang, ok := languagesByName[base]; ok {
+		return lang, true
+	}
+	if lang, ok := languagesByExt[strings.ToLower(path.Ext(base))]; ok {
+		return lang, true
+	}
+	return shebangLanguage(firstLine)
+}
+
+// shebangLanguage returns the language of the interpreter named by a shebang
+// line, looking through /usr/bin/env
+func shebangLanguage(line string) (Language, bool) {
+	if !strings.HasPrefix(line, "#!") {
+		return Language{}, false
+	}
+	fields := strings.Fields(strings.TrimPrefix(line, "#!"))
+	if len(fields) == 0 {
+		return Language{}, false
+	}
+	interpreter := path.Base(fields[0])
+	if interpreter == "env" {
+		for _, arg := range fields[1:] {
+			if !strings.HasPrefix(arg, "-") {
+				interpreter = arg
+				break
+			}
+		}
+	}
+	lang, ok := languagesByInterpreter[interpreter]
+	return lang, ok
+}
+
+// firstLine returns the first line of the new file version when the diff
+// shows it, which is where a shebang would be
+func firstLine(raw string) string {
+	lines := strings.Split(raw, "\n")
+	for i, line := range lines {
+		if !firstLineHunk.MatchString(line) {
+			continue
+		}
+		for _, content := range lines[i+1:] {
+			if strings.HasPrefix(content, "+") || strings.HasPrefix(content, " ") {
+				return content[1:]
+			}
+			if !strings.HasPrefix(content, "-") {
+				break
+			}
+		}
+		break
+	}
+	return ""
+}
+
+// Languages returns the names of the languages of the files in a diff, in
+// order of first appearance
+func Languages(diff string) []string {
+	var names []string
+	seen := make(map[string]bool)
+	for _, file := range SplitFiles(diff) {
+		lang, ok := DetectLanguage(file.Path, firstLine(file.Raw))
+		if ok && !seen[lang.Name] {
+			seen[lang.Name] = true
+			names = append(names, lang.Name)
+		}
+	}
+	return names
+}
+
+// FenceDiff wraps the section of every file in a diff in a Markdown code
+// fence tagged with the file's language ("diff" when it is unknown), so the
+// model reads each section in the right syntax. Text before the first file
+// section is kept as is.
+func FenceDiff(diff string) string {
+	files := SplitFiles(diff)
+	if len(files) == 0 {
+		return diff
+	}
+
+	var b strings.Builder
+	if prefix, _, found := strings.Cut(diff, "diff --git "); found && strings.TrimSpace(prefix) != "" {
+		b.WriteString(strings.TrimRight(prefix, "\n"))
+		b.WriteString("\n\n")
+	}
+	for i, file := range files {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		raw, summaries := splitSummaries(file.Raw)
+		info := "diff"
+		if lang, ok := DetectLanguage(file.Path, firstLine(raw)); ok {
+			info = lang.Fence
+		}
+		fence := codeFence(raw)
+		b.WriteString(fence + info + "\n" + raw + "\n" + fence + "\n")
+		if summaries != "" {
+			b.WriteString("\n" + summaries + "\n")
+		}
+	}
+	return b.String()
+}
+
+// splitSummaries separates the one-line summaries that replaced the sections
+// of following files (such as "# go.sum changed") from a file's section.
+// Diff content lines always start with a marker, so "# " can't be content.
+func splitSummaries(raw string) (section, summaries string) {
+	lines := strings.Split(strings.TrimRight(raw, "\n"), "\n")
+	end := len(lines)
+	for end > 1 && (strings.HasPrefix(lines[end-1], "# ") || lines[end-1] == "") {
+		end--
+	}
+	return strings.Join(lines[:end], "\n"), strings.TrimSpace(strings.Join(lines[end:], "\n"))
+}
+
+// codeFence returns a backtick fence longer than any backtick run in content,
+// so fences inside the diff (e.g. in Markdown files) don't close it
+func codeFence(content string) string {
+	longest, run := 0, 0
+	for _, r := range content {
+		if r == '`' {
+			run++
+			longest = max(longest, run)
+		} else {
+			run = 0
+		}
+	}
+	return strings.Repeat("`", max(3, longest+1))
+}
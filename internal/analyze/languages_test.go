@@ -0,0 +1,89 @@
+package analyze
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDetectLanguage(t *testing.T) {
+	tests := []struct {
+		file      string
+		firstLine string
+		expected  string
+		ok        bool
+	}{
+		{"internal/cli/root.go", "", "Go", true},
+		{"web/App.TSX", "", "TypeScript", true},
+		{"build/Dockerfile", "", "Dockerfile", true},
+		{"scripts/release", "#!/usr/bin/env python3", "Python", true},
+		{"scripts/install", "#!/bin/bash -e", "Shell", true},
+		{"LICENSE", "MIT License", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			lang, ok := DetectLanguage(tt.file, tt.firstLine)
+			assert.Equal(t, tt.ok, ok)
+			assert.Equal(t, tt.expected, lang.Name)
+		})
+	}
+}
+
+const mixedDiff = `diff --git a/main.go b/main.go
+--- a/main.go
++++ b/main.go
+@@ -10,3 +10,3 @@
+-	fmt.Println("hi")
++	fmt.Println("hello")
+diff --git a/scripts/deploy b/scripts/deploy
+new file mode 100755
+--- /dev/null
++++ b/scripts/deploy
+@@ -0,0 +1,2 @@
++#!/usr/bin/env bash
++echo deploy
+diff --git a/README.md b/README.md
+--- a/README.md
++++ b/README.md
+@@ -1,3 +1,5 @@
+ # Title
++` + "```sh" + `
++make
++` + "```" + `
+diff --git a/LICENSE b/LICENSE
+--- a/LICENSE
++++ b/LICENSE
+@@ -1 +1 @@
+-2024
++2025
+`
+
+func TestLanguages(t *testing.T) {
+	assert.Equal(t, []string{"Go", "Shell", "Markdown"}, Languages(mixedDiff))
+	assert.Empty(t, Languages(""))
+}
+
+func TestFenceDiff(t *testing.T) {
+	fenced := FenceDiff(mixedDiff)
+
+	assert.Contains(t, fenced, "```go\ndiff --git a/main.go b/main.go\n")
+	assert.Contains(t, fenced, "```bash\ndiff --git a/scripts/deploy b/scripts/deploy\n")
+	// The Markdown section contains a fence itself, so a longer one is used
+	assert.Contains(t, fenced, "````markdown\ndiff --git a/README.md b/README.md\n")
+	assert.Contains(t, fenced, "+```\n````\n")
+	assert.Contains(t, fenced, "```diff\ndiff --git a/LICENSE b/LICENSE\n")
+	assert.Contains(t, fenced, "+2025\n```\n")
+
+	assert.Equal(t, "not a diff", FenceDiff("not a diff"))
+}
+
+func TestFenceDiff_Summaries(t *testing.T) {
+	diff := "# go.sum changed (checksums omitted)\n" +
+		"diff --git a/main.go b/main.go\n+package main\n" +
+		"# LFS object model.bin added (size 5 B, oid 2cf24db)"
+
+	assert.Equal(t, "# go.sum changed (checksums omitted)\n\n"+
+		"```go\ndiff --git a/main.go b/main.go\n+package main\n```\n\n"+
+		"# LFS object model.bin added (size 5 B, oid 2cf24db)\n", FenceDiff(diff))
+}
@@ -0,0 +1,89 @@
+package analyze
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/nseba/commit-ai/internal/lfs"
+)
+
+// SummarizeLFS replaces the sections of Git LFS pointer files in a diff with
+// a one-line description of the object change, or drops them when skip is
+// set. Other sections are kept as is.
+func SummarizeLFS(diff string, skip bool) string {
+	files := SplitFiles(diff)
+	sections := make([]string, 0, len(files))
+	changed := false
+	for _, file := range files {
+		summary, ok := lfsSummary(file)
+		switch {
+		case !ok:
+			sections = append(sections, file.Raw)
+			continue
+		case !skip:
+			sections = append(sections, summary)
+		}
+		changed = true
+	}
+	if !changed {
+		return diff
+	}
+	return strings.Join(sections, "\n")
+}
+
+// lfsSummary describes the change of an LFS pointer file section
+func lfsSummary(file FileDiff) (string, bool) {
+	var context []string
+	for _, line := range strings.Split(file.Raw, "\n") {
+		if strings.HasPrefix(line, " ") {
+			context = append(context, line[1:])
+		}
+	}
+	oldPointer, oldOK := lfs.ParseLines(append(append([]string{}, context...), file.Removed...))
+	newPointer, newOK := lfs.ParseLines(append(append([]string{}, context...), file.Added...))
+
+	switch {
+	case oldOK && newOK && len(file.Removed) > 0:
+		size := "size unchanged"
+		if oldPointer.Size >= 0 || newPointer.Size >= 0 {
+			size = fmt.Sprintf("size %s -> %s", formatSize(oldPointer.Size), formatSize(newPointer.Size))
+		}
+		return fmt.Sprintf("# LFS object %s updated (%s, oid %s -> %s)", file.Path,
+			size, shortOID(oldPointer.OID), shortOID(newPointer.OID)), true
+	case newOK && len(file.Removed) == 0 && len(file.Added) > 0:
+		return fmt.Sprintf("# LFS object %s added (size %s, oid %s)", file.Path, formatSize(newPointer.Size), shortOID(newPointer.OID)), true
+	case oldOK && len(file.Added) == 0 && len(file.Removed) > 0:
+		return fmt.Sprintf("# LFS object %s deleted (size %s, oid %s)", file.Path, formatSize(oldPointer.Size), shortOID(oldPointer.OID)), true
+	case newOK && len(file.Added) > 0:
+		return fmt.Sprintf("# %s moved to LFS (size %s, oid %s)", file.Path, formatSize(newPointer.Size), shortOID(newPointer.OID)), true
+	}
+	return "", false
+}
+
+// shortOID abbreviates an object ID the way git abbreviates commit hashes
+func shortOID(oid string) string {
+	if len(oid) > 7 {
+		return oid[:7]
+	}
+	return oid
+}
+
+// formatSize renders a byte count for humans; negative sizes are unknown
+// because the diff didn't show the size line
+func formatSize(size int64) string {
+	const unit = 1024
+	switch {
+	case size < 0:
+		return "?"
+	case size < unit:
+		return fmt.Sprintf("%d B", size)
+	}
+	value, suffix := float64(size)/unit, "KB"
+	for _, next := range []string{"MB", "GB", "TB"} {
+		if value < unit {
+			break
+		}
+		value, suffix = value/unit, next
+	}
+	return fmt.Sprintf("%.1f %s", value, suffix)
+}
@@ -0,0 +1,65 @@
+package analyze
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	oldOID = "4d7a214614ab2935c943f9e0ff69d22eadbb8f32b1258daaa5e2ca24d17e2393"
+	newOID = "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824"
+)
+
+const lfsDiff = `diff --git a/assets/logo.psd b/assets/logo.psd
+index 1111111..2222222 100644
+--- a/assets/logo.psd
++++ b/assets/logo.psd
+@@ -1,3 +1,3 @@
+ version https://git-lfs.github.com/spec/v1
+-oid sha256:` + oldOID + `
+-size 1048576
++oid sha256:` + newOID + `
++size 1572864
+diff --git a/models/v2.bin b/models/v2.bin
+new file mode 100644
+--- /dev/null
++++ b/models/v2.bin
+@@ -0,0 +1,3 @@
++version https://git-lfs.github.com/spec/v1
++oid sha256:` + newOID + `
++size 512
+diff --git a/main.go b/main.go
+--- a/main.go
++++ b/main.go
+@@ -1 +1 @@
+-package old
++package main`
+
+func TestSummarizeLFS(t *testing.T) {
+	summarized := SummarizeLFS(lfsDiff, false)
+
+	assert.Contains(t, summarized, "# LFS object assets/logo.psd updated (size 1.0 MB -> 1.5 MB, oid 4d7a214 -> 2cf24db)")
+	assert.Contains(t, summarized, "# LFS object models/v2.bin added (size 512 B, oid 2cf24db)")
+	assert.NotContains(t, summarized, "sha256:")
+	assert.Contains(t, summarized, "+package main")
+}
+
+func TestSummarizeLFS_Skip(t *testing.T) {
+	summarized := SummarizeLFS(lfsDiff, true)
+
+	assert.NotContains(t, summarized, "LFS")
+	assert.NotContains(t, summarized, "logo.psd")
+	assert.Contains(t, summarized, "diff --git a/main.go b/main.go")
+}
+
+func TestSummarizeLFS_OIDOnly(t *testing.T) {
+	// Line-by-line diffs leave out the unchanged version and size lines
+	diff := "diff --git a/a.bin b/a.bin\n--- a/a.bin\n+++ b/a.bin\n-oid sha256:" + oldOID + "\n+oid sha256:" + newOID
+	assert.Equal(t, "# LFS object a.bin updated (size unchanged, oid 4d7a214 -> 2cf24db)", SummarizeLFS(diff, false))
+}
+
+func TestSummarizeLFS_NoPointers(t *testing.T) {
+	diff := "diff --git a/main.go b/main.go\n--- a/main.go\n+++ b/main.go\n-oid x\n+size 1"
+	assert.Equal(t, diff, SummarizeLFS(diff, false))
+}
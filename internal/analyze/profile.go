@@ -0,0 +1,197 @@
+package analyze
+
+import (
+	"encoding/json"
+	"path"
+	"sort"
+	"strings"
+)
+
+// OtherLanguage stands for files of an unknown language in a ChangeProfile
+const OtherLanguage = "Other"
+
+const (
+	// repoLanguageShare is the share of a repository's recognized files a
+	// language needs to count as one of its dominant languages
+	repoLanguageShare = 0.1
+	// maxRepoLanguages bounds the number of dominant languages
+	maxRepoLanguages = 3
+)
+
+// ChangeProfile describes what a diff changes, for choosing the model
+type ChangeProfile struct {
+	// Languages lists the languages of the changed files in order of first
+	// appearance, with OtherLanguage for files of unknown language
+	Languages []string
+	// Primary is the language with the most changed lines
+	Primary string
+	// ChangedLines counts the added and removed lines, leaving out lines
+	// that only shifted
+	ChangedLines int
+}
+
+// RepoProfile describes a repository as a whole
+type RepoProfile struct {
+	// Languages lists the dominant languages by number of files, most
+	// common first
+	Languages []string
+	// Frameworks lists the frameworks and toolchains found in the
+	// repository root's manifests, e.g. "go", "node", "react"
+	Frameworks []string
+}
+
+// ProfileChange returns the profile of a diff
+func ProfileChange(diff string) ChangeProfile {
+	var profile ChangeProfile
+	lines := make(map[string]int)
+	for _, file := range SplitFiles(diff) {
+		name := OtherLanguage
+		if lang, ok := DetectLanguage(file.Path, firstLine(file.Raw)); ok {
+			name = lang.Name
+		}
+		if _, seen := lines[name]; !seen {
+			profile.Languages = append(profile.Languages, name)
+		}
+		changed := len(netLines(file))
+		lines[name] += changed
+		profile.ChangedLines += changed
+	}
+
+	for _, name := range profile.Languages {
+		if profile.Primary == "" || lines[name] > lines[profile.Primary] {
+			profile.Primary = name
+		}
+	}
+	return profile
+}
+
+// IsSmallChange reports whether a diff changes a single hunk of a single
+// file by at most maxLines added and removed lines, not counting lines that
+// are both. The line-by-line diffs of the work tree have no hunk headers and
+// count as one hunk. A maxLines of zero or less never matches.
+func IsSmallChange(diff string, maxLines int) bool {
+	if maxLines <= 0 {
+		return false
+	}
+	files := SplitFiles(diff)
+	if len(files) != 1 {
+		return false
+	}
+	hunks := 0
+	for _, line := range strings.Split(files[0].Raw, "\n") {
+		if strings.HasPrefix(line, "@@ ") {
+			hunks++
+		}
+	}
+	changed := len(netLines(files[0]))
+	return hunks <= 1 && changed > 0 && changed <= maxLines
+}
+
+// IsLargeChange reports whether a diff changes at least minLines added and
+// removed lines across its files, not counting lines that are both. A
+// minLines of zero or less never matches.
+func IsLargeChange(diff string, minLines int) bool {
+	return minLines > 0 && ProfileChange(diff).ChangedLines >= minLines
+}
+
+// manifest maps a file in the repository root to the framework it implies
+// and to frameworks implied by dependencies named in it
+type manifest struct {
+	framework    string
+	dependencies map[string]string
+}
+
+// manifests are the root files DetectRepo inspects
+var manifests = map[string]manifest{
+	"go.mod":           {framework: "go"},
+	"Cargo.toml":       {framework: "cargo"},
+	"package.json":     {framework: "node", dependencies: map[string]string{"react": "react", "vue": "vue", "next": "next", "@angular/core": "angular", "svelte": "svelte", "express": "express"}},
+	"pyproject.toml":   {framework: "python", dependencies: map[string]string{"django": "django", "flask": "flask", "fastapi": "fastapi"}},
+	"requirements.txt": {framework: "python", dependencies: map[string]string{"django": "django", "flask": "flask", "fastapi": "fastapi"}},
+	"Gemfile":          {framework: "ruby", dependencies: map[string]string{"rails": "rails"}},
+	"pom.xml":          {framework: "maven", dependencies: map[string]string{"spring-boot": "spring"}},
+	"build.gradle":     {framework: "gradle", dependencies: map[string]string{"spring-boot": "spring"}},
+	"build.gradle.kts": {framework: "gradle", dependencies: map[string]string{"spring-boot": "spring"}},
+	"Dockerfile":       {framework: "docker"},
+	"Chart.yaml":       {framework: "helm"},
+}
+
+// DetectRepo profiles a repository from the paths of its tracked files.
+// read returns the content of a file in the repository root; manifests it
+// can't read only count by their name.
+func DetectRepo(files []string, read func(name string) ([]byte, error)) RepoProfile {
+	var profile RepoProfile
+	counts := make(map[string]int)
+	total := 0
+	frameworks := make(map[string]bool)
+	for _, file := range files {
+		if lang, ok := DetectLanguage(file, ""); ok {
+			counts[lang.Name]++
+			total++
+		}
+		m, ok := manifests[file]
+		if !ok {
+			continue
+		}
+		frameworks[m.framework] = true
+		if len(m.dependencies) == 0 {
+			continue
+		}
+		if content, err := read(file); err == nil {
+			for _, dep := range manifestDependencies(file, content, m.dependencies) {
+				frameworks[dep] = true
+			}
+		}
+	}
+
+	for name, count := range counts {
+		if float64(count) >= repoLanguageShare*float64(total) {
+			profile.Languages = append(profile.Languages, name)
+		}
+	}
+	sort.Slice(profile.Languages, func(i, j int) bool {
+		a, b := profile.Languages[i], profile.Languages[j]
+		return counts[a] > counts[b] || (counts[a] == counts[b] && a < b)
+	})
+	if len(profile.Languages) > maxRepoLanguages {
+		profile.Languages = profile.Languages[:maxRepoLanguages]
+	}
+
+	for name := range frameworks {
+		profile.Frameworks = append(profile.Frameworks, name)
+	}
+	sort.Strings(profile.Frameworks)
+	return profile
+}
+
+// manifestDependencies returns the frameworks a manifest depends on.
+// package.json is parsed; other manifests are searched for the dependency
+// names.
+func manifestDependencies(file string, content []byte, dependencies map[string]string) []string {
+	var found []string
+	if path.Base(file) == "package.json" {
+		var pkg struct {
+			Dependencies    map[string]any `json:"dependencies"`
+			DevDependencies map[string]any `json:"devDependencies"`
+		}
+		if json.Unmarshal(content, &pkg) != nil {
+			return nil
+		}
+		for dep, framework := range dependencies {
+			_, runtime := pkg.Dependencies[dep]
+			_, dev := pkg.DevDependencies[dep]
+			if runtime || dev {
+				found = append(found, framework)
+			}
+		}
+		return found
+	}
+
+	text := strings.ToLower(string(content))
+	for dep, framework := range dependencies {
+		if strings.Contains(text, dep) {
+			found = append(found, framework)
+		}
+	}
+	return found
+}
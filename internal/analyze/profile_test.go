@@ -0,0 +1,85 @@
+package analyze
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProfileChange(t *testing.T) {
+	diff := "diff --git a/main.go b/main.go\n--- a/main.go\n+++ b/main.go\n@@ -1,2 +1,3 @@\n package main\n-func a() {}\n+func b() {}\n+func c() {}\n" +
+		"diff --git a/README.md b/README.md\n--- a/README.md\n+++ b/README.md\n@@ -1 +1 @@\n-# Old\n+# New\n" +
+		"diff --git a/LICENSE b/LICENSE\n--- a/LICENSE\n+++ b/LICENSE\n@@ -1 +1 @@\n-2023\n+2024\n"
+
+	profile := ProfileChange(diff)
+	assert.Equal(t, []string{"Go", "Markdown", OtherLanguage}, profile.Languages)
+	assert.Equal(t, "Go", profile.Primary)
+	assert.Equal(t, 7, profile.ChangedLines)
+
+	// Shifted lines of a line-by-line diff don't count
+	shifted := "diff --git a/list.txt b/list.txt\n--- a/list.txt\n+++ b/list.txt\n-b\n+x\n-c\n+b\n+c\n"
+	assert.Equal(t, 1, ProfileChange(shifted).ChangedLines)
+
+	assert.Equal(t, ChangeProfile{}, ProfileChange(""))
+}
+
+func TestIsSmallChange(t *testing.T) {
+	small := "diff --git a/main.go b/main.go\n--- a/main.go\n+++ b/main.go\n@@ -1,2 +1,2 @@\n package main\n-func a() {}\n+func b() {}\n"
+	assert.True(t, IsSmallChange(small, 2))
+	assert.False(t, IsSmallChange(small, 1), "more changed lines than the threshold")
+	assert.False(t, IsSmallChange(small, 0), "a zero threshold disables the check")
+
+	twoHunks := small + "@@ -10 +10 @@\n-x\n+y\n"
+	assert.False(t, IsSmallChange(twoHunks, 10))
+
+	twoFiles := small + "diff --git a/README.md b/README.md\n--- a/README.md\n+++ b/README.md\n@@ -1 +1 @@\n-# Old\n+# New\n"
+	assert.False(t, IsSmallChange(twoFiles, 10))
+
+	// Line-by-line diffs have no hunk headers and show an insertion as
+	// every following line replaced
+	lineByLine := "diff --git a/list.txt b/list.txt\nindex xxxxxxx..xxxxxxx 100644\n--- a/list.txt\n+++ b/list.txt\n-b\n+x\n-c\n+b\n+c"
+	assert.True(t, IsSmallChange(lineByLine, 1))
+
+	modeOnly := "diff --git a/run.sh b/run.sh\nold mode 100644\nnew mode 100755\n"
+	assert.False(t, IsSmallChange(modeOnly, 10))
+	assert.False(t, IsSmallChange("", 10))
+}
+
+func TestIsLargeChange(t *testing.T) {
+	diff := "diff --git a/main.go b/main.go\n--- a/main.go\n+++ b/main.go\n@@ -1,2 +1,2 @@\n package main\n-func a() {}\n+func b() {}\n" +
+		"diff --git a/README.md b/README.md\n--- a/README.md\n+++ b/README.md\n@@ -1 +1 @@\n-# Old\n+# New\n"
+	assert.True(t, IsLargeChange(diff, 4), "lines of every file count")
+	assert.False(t, IsLargeChange(diff, 5))
+	assert.False(t, IsLargeChange(diff, 0), "a zero threshold disables the check")
+
+	shifted := "diff --git a/list.txt b/list.txt\n--- a/list.txt\n+++ b/list.txt\n-b\n+x\n-c\n+b\n+c\n"
+	assert.False(t, IsLargeChange(shifted, 2), "shifted lines don't count")
+}
+
+func TestDetectRepo(t *testing.T) {
+	manifests := map[string]string{
+		"package.json":     `{"dependencies": {"react": "^18.0.0"}, "devDependencies": {"vite": "^5.0.0"}}`,
+		"requirements.txt": "Django==5.0\nrequests",
+	}
+	read := func(name string) ([]byte, error) {
+		content, ok := manifests[name]
+		if !ok {
+			return nil, errors.New("not found")
+		}
+		return []byte(content), nil
+	}
+
+	files := []string{
+		"package.json", "requirements.txt", "go.mod", "Dockerfile",
+		"src/App.tsx", "src/index.tsx", "src/api.ts", "src/util.ts",
+		"app/views.py", "app/models.py", "app/urls.py",
+		"docs/guide.md", "LICENSE", "web/app.css",
+		"nested/Cargo.toml",
+	}
+	profile := DetectRepo(files, read)
+	assert.Equal(t, []string{"TypeScript", "Python"}, profile.Languages)
+	assert.Equal(t, []string{"django", "docker", "go", "node", "python", "react"}, profile.Frameworks)
+
+	assert.Equal(t, RepoProfile{}, DetectRepo(nil, read))
+}
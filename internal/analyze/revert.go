@@ -0,0 +1,55 @@
+package analyze
+
+import "fmt"
+
+// revertSimilarity is the share of changed lines two diffs must have in
+// common, with added and removed lines swapped, for one to revert the other
+const revertSimilarity = 0.9
+
+// IsRevert reports whether diff approximately undoes previous: the lines it
+// adds are the lines previous removed from the same files and vice versa.
+// Diffs without added or removed lines, such as mode changes, never match.
+func IsRevert(diff, previous string) bool {
+	current := changedLines(diff)
+	earlier := changedLines(previous)
+
+	total, common := 0, 0
+	for key, n := range current {
+		total += n
+		inverse := key
+		inverse.added = !key.added
+		common += min(n, earlier[inverse])
+	}
+	for _, n := range earlier {
+		total += n
+	}
+	if total == 0 {
+		return false
+	}
+	return float64(2*common)/float64(total) >= revertSimilarity
+}
+
+// changedLine is an added or removed line of a file
+type changedLine struct {
+	path  string
+	added bool
+	text  string
+}
+
+// changedLines counts the added and removed lines of each file of diff,
+// leaving out lines that only shifted
+func changedLines(diff string) map[changedLine]int {
+	lines := make(map[changedLine]int)
+	for _, file := range SplitFiles(diff) {
+		for _, line := range netLines(file) {
+			lines[changedLine{path: file.Path, added: line[0] == '+', text: line[1:]}]++
+		}
+	}
+	return lines
+}
+
+// ComposeRevertMessage builds the message git revert writes for the commit
+// with the given subject and hash
+func ComposeRevertMessage(subject, hash string) string {
+	return fmt.Sprintf("Revert \"%s\"\n\nThis reverts commit %s.", subject, hash)
+}
@@ -0,0 +1,36 @@
+package analyze
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsRevert(t *testing.T) {
+	original := "diff --git a/main.go b/main.go\n--- a/main.go\n+++ b/main.go\n@@ -1,3 +1,4 @@\n package main\n-func a() {}\n+func b() {}\n+func c() {}\n" +
+		"diff --git a/README.md b/README.md\n--- a/README.md\n+++ b/README.md\n@@ -1 +1 @@\n-# Old\n+# New\n"
+	revert := "diff --git a/README.md b/README.md\n--- a/README.md\n+++ b/README.md\n@@ -1 +1 @@\n-# New\n+# Old\n" +
+		"diff --git a/main.go b/main.go\nindex 1..2 100644\n--- a/main.go\n+++ b/main.go\n@@ -1,4 +1,3 @@\n package main\n-func b() {}\n-func c() {}\n+func a() {}\n"
+
+	assert.True(t, IsRevert(revert, original))
+	assert.False(t, IsRevert(original, original), "the same change again")
+
+	// A revert with a small adjustment still counts, a larger one doesn't
+	adjusted := revert + "diff --git a/go.mod b/go.mod\n--- a/go.mod\n+++ b/go.mod\n@@ -1 +1,2 @@\n module app\n+go 1.24\n"
+	assert.True(t, IsRevert(adjusted, original))
+	assert.False(t, IsRevert(adjusted+"+toolchain go1.24.4\n", original))
+
+	// A line-by-line diff of the work tree shows the lines after an insertion
+	// as replaced
+	inserted := "diff --git a/list.txt b/list.txt\n--- a/list.txt\n+++ b/list.txt\n@@ -1,2 +1,3 @@\n a\n+x\n b\n c\n"
+	undone := "diff --git a/list.txt b/list.txt\nindex xxxxxxx..xxxxxxx 100644\n--- a/list.txt\n+++ b/list.txt\n a\n-x\n+b\n-b\n+c\n-c\n"
+	assert.True(t, IsRevert(undone, inserted))
+
+	modeOnly := "diff --git a/run.sh b/run.sh\nold mode 100644\nnew mode 100755\n"
+	assert.False(t, IsRevert(modeOnly, modeOnly))
+}
+
+func TestComposeRevertMessage(t *testing.T) {
+	assert.Equal(t, "Revert \"feat: add login\"\n\nThis reverts commit 3f2c1a9.", ComposeRevertMessage("feat: add login", "3f2c1a9"))
+	assert.Equal(t, "Revert \"Revert \"feat: add login\"\"\n\nThis reverts commit 5d0e4b2.", ComposeRevertMessage("Revert \"feat: add login\"", "5d0e4b2"))
+}
@@ -0,0 +1,117 @@
+// Package audit appends a record of every request sent to a model provider
+// to a JSON Lines log for compliance reviews. Records describe requests,
+// never their content: no diff, prompt, message or repository path.
+package audit
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/nseba/commit-ai/internal/filelock"
+)
+
+// Request outcomes
+const (
+	StatusOK    = "ok"
+	StatusError = "error"
+)
+
+// Event is one request sent to a provider
+type Event struct {
+	Time time.Time `json:"time"`
+	// Repo is the HashPath of the repository the request was made for
+	Repo     string `json:"repo"`
+	Provider string `json:"provider"`
+	Model    string `json:"model"`
+	// PromptTokens is the estimated size of the prompt
+	PromptTokens int    `json:"prompt_tokens"`
+	Status       string `json:"status"`
+	DurationMS   int64  `json:"duration_ms"`
+}
+
+// Log is an append-only audit log rotated by size. When a write would grow
+// File beyond MaxBytes, File is renamed to File.1, File.1 to File.2 and so
+// on, keeping at most MaxFiles rotated files. A MaxBytes of zero disables
+// rotation.
+type Log struct {
+	File     string
+	MaxBytes int64
+	MaxFiles int
+}
+
+// HashPath identifies a repository path without revealing it
+func HashPath(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	sum := sha256.Sum256([]byte(filepath.Clean(path)))
+	return hex.EncodeToString(sum[:])
+}
+
+// Record appends event to the log as one JSON line, rotating the log first
+// when needed. Writers are serialized with a lock file so concurrent
+// processes neither interleave lines nor rotate twice.
+func (l *Log) Record(event Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to encode audit event: %w", err)
+	}
+	data = append(data, '\n')
+
+	if err := os.MkdirAll(filepath.Dir(l.File), 0o750); err != nil {
+		return fmt.Errorf("failed to create audit log directory: %w", err)
+	}
+	unlock, err := filelock.Lock(l.File)
+	if err != nil {
+		return fmt.Errorf("failed to lock audit log: %w", err)
+	}
+	defer unlock()
+
+	if info, err := os.Stat(l.File); err == nil && l.MaxBytes > 0 && info.Size()+int64(len(data)) > l.MaxBytes {
+		if err := l.rotate(); err != nil {
+			return err
+		}
+	}
+
+	f, err := os.OpenFile(l.File, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600) // #nosec G304 -- audit log path is configured by the user
+	if err != nil {
+		return fmt.Errorf("failed to open audit log: %w", err)
+	}
+	if _, err := f.Write(data); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("failed to write audit log: %w", err)
+	}
+	return f.Close()
+}
+
+// rotate shifts the rotated files up by one, dropping the oldest, and moves
+// the current log to File.1
+func (l *Log) rotate() error {
+	if l.MaxFiles <= 0 {
+		if err := os.Remove(l.File); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to rotate audit log: %w", err)
+		}
+		return nil
+	}
+
+	_ = os.Remove(l.rotated(l.MaxFiles))
+	for i := l.MaxFiles - 1; i >= 1; i-- {
+		if err := os.Rename(l.rotated(i), l.rotated(i+1)); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to rotate audit log: %w", err)
+		}
+	}
+	if err := os.Rename(l.File, l.rotated(1)); err != nil {
+		return fmt.Errorf("failed to rotate audit log: %w", err)
+	}
+	return nil
+}
+
+// rotated returns the path of the i-th rotated file
+func (l *Log) rotated(i int) string {
+	return fmt.Sprintf("%s.%d", l.File, i)
+}
@@ -0,0 +1,80 @@
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// readEvents decodes the events in an audit log file
+func readEvents(t *testing.T, file string) []Event {
+	t.Helper()
+	f, err := os.Open(file)
+	require.NoError(t, err)
+	defer f.Close()
+
+	var events []Event
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var event Event
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &event))
+		events = append(events, event)
+	}
+	require.NoError(t, scanner.Err())
+	return events
+}
+
+func TestLog_Record(t *testing.T) {
+	log := &Log{File: filepath.Join(t.TempDir(), "audit", "audit.jsonl")}
+
+	event := Event{
+		Time:         time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+		Repo:         HashPath("/src/project"),
+		Provider:     "openai",
+		Model:        "gpt-4",
+		PromptTokens: 1200,
+		Status:       StatusOK,
+		DurationMS:   850,
+	}
+	require.NoError(t, log.Record(event))
+	require.NoError(t, log.Record(event))
+
+	assert.Equal(t, []Event{event, event}, readEvents(t, log.File))
+	_, err := os.Stat(log.File + ".lock")
+	assert.True(t, os.IsNotExist(err), "lock file should be released")
+}
+
+func TestLog_Rotate(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "audit.jsonl")
+	event := Event{Provider: "ollama", Model: "llama2", Status: StatusOK}
+	line, err := json.Marshal(event)
+	require.NoError(t, err)
+
+	// Each file holds two events
+	log := &Log{File: file, MaxBytes: int64(2 * (len(line) + 1)), MaxFiles: 2}
+	for i := 0; i < 7; i++ {
+		event.PromptTokens = i
+		require.NoError(t, log.Record(event))
+	}
+
+	assert.Len(t, readEvents(t, file), 1)
+	assert.Equal(t, 4, readEvents(t, file+".1")[0].PromptTokens)
+	assert.Equal(t, 2, readEvents(t, file+".2")[0].PromptTokens)
+	_, err = os.Stat(file + ".3")
+	assert.True(t, os.IsNotExist(err), "only MaxFiles rotated files are kept")
+}
+
+func TestHashPath(t *testing.T) {
+	hash := HashPath("/src/project")
+
+	assert.Len(t, hash, 64)
+	assert.Equal(t, hash, HashPath("/src/project/"))
+	assert.NotEqual(t, hash, HashPath("/src/other"))
+	assert.NotContains(t, hash, "project")
+}
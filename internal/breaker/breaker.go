@@ -0,0 +1,124 @@
+// Package breaker implements a circuit breaker for provider endpoints. The
+// state lives in a small file in the cache directory, so one invocation
+// learning that an endpoint is down spares the following ones from waiting
+// out the full request timeout.
+package breaker
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/nseba/commit-ai/internal/filelock"
+)
+
+const (
+	// lockTimeout bounds how long a process waits for the state lock
+	lockTimeout = 2 * time.Second
+	// staleLockAge is the age after which an abandoned state lock is removed
+	staleLockAge = 10 * time.Second
+)
+
+// state is the persisted breaker state
+type state struct {
+	Failures  int       `json:"failures"`
+	OpenUntil time.Time `json:"open_until"`
+}
+
+// Breaker opens after threshold consecutive failures and stays open for the
+// cooldown. Once the cooldown has passed, the next request is let through: a
+// success closes the circuit, a failure opens it for another cooldown.
+type Breaker struct {
+	file      string
+	threshold int
+	cooldown  time.Duration
+
+	now func() time.Time
+}
+
+// New creates a breaker keeping its state in file
+func New(file string, threshold int, cooldown time.Duration) *Breaker {
+	return &Breaker{file: file, threshold: threshold, cooldown: cooldown, now: time.Now}
+}
+
+// Open reports whether the circuit is open, and until when. An unreadable
+// state file counts as closed.
+func (b *Breaker) Open() (bool, time.Time) {
+	s, err := b.load()
+	if err != nil || !b.now().Before(s.OpenUntil) {
+		return false, time.Time{}
+	}
+	return true, s.OpenUntil
+}
+
+// Success records a successful request, closing the circuit. A closed
+// circuit without failures is left untouched, so the common case costs no
+// write.
+func (b *Breaker) Success() error {
+	if s, err := b.load(); err == nil && s == (state{}) {
+		return nil
+	}
+	return b.update(func(s *state) {
+		*s = state{}
+	})
+}
+
+// Failure records a failed request and reports whether the circuit is open
+// as a result
+func (b *Breaker) Failure() (bool, error) {
+	var open bool
+	err := b.update(func(s *state) {
+		s.Failures++
+		if s.Failures >= b.threshold {
+			s.OpenUntil = b.now().Add(b.cooldown)
+			open = true
+		}
+	})
+	return open, err
+}
+
+// update applies fn to the state under the state lock
+func (b *Breaker) update(fn func(*state)) error {
+	if err := os.MkdirAll(filepath.Dir(b.file), 0o750); err != nil {
+		return fmt.Errorf("failed to create breaker directory: %w", err)
+	}
+	unlock, err := filelock.Acquire(b.file+".lock", lockTimeout, staleLockAge)
+	if err != nil {
+		return fmt.Errorf("failed to lock breaker state: %w", err)
+	}
+	defer unlock()
+
+	s, err := b.load()
+	if err != nil {
+		s = state{}
+	}
+	fn(&s)
+
+	data, err := json.Marshal(s)
+	if err != nil {
+		return fmt.Errorf("failed to encode breaker state: %w", err)
+	}
+	if err := os.WriteFile(b.file, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write breaker state: %w", err)
+	}
+	return nil
+}
+
+// load reads the state file; a missing file is a closed circuit
+func (b *Breaker) load() (state, error) {
+	var s state
+	data, err := os.ReadFile(b.file)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return s, fmt.Errorf("failed to read breaker state: %w", err)
+	}
+	if err := json.Unmarshal(data, &s); err != nil {
+		return state{}, fmt.Errorf("failed to decode breaker state: %w", err)
+	}
+	return s, nil
+}
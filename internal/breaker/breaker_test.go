@@ -0,0 +1,75 @@
+package breaker
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBreaker(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "cache", "breaker.json")
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	b := New(file, 2, time.Minute)
+	b.now = func() time.Time { return now }
+
+	open, _ := b.Open()
+	assert.False(t, open)
+
+	opened, err := b.Failure()
+	require.NoError(t, err)
+	assert.False(t, opened)
+
+	opened, err = b.Failure()
+	require.NoError(t, err)
+	assert.True(t, opened)
+
+	open, until := b.Open()
+	assert.True(t, open)
+	assert.Equal(t, now.Add(time.Minute), until)
+
+	// Another process sees the same state
+	other := New(file, 2, time.Minute)
+	other.now = b.now
+	open, _ = other.Open()
+	assert.True(t, open)
+
+	// After the cooldown one attempt is let through; failing reopens at once
+	now = now.Add(2 * time.Minute)
+	open, _ = b.Open()
+	assert.False(t, open)
+	opened, err = b.Failure()
+	require.NoError(t, err)
+	assert.True(t, opened)
+
+	// A success closes the circuit and resets the count
+	now = now.Add(2 * time.Minute)
+	require.NoError(t, b.Success())
+	opened, err = b.Failure()
+	require.NoError(t, err)
+	assert.False(t, opened)
+}
+
+func TestBreaker_CorruptState(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "breaker.json")
+	require.NoError(t, os.WriteFile(file, []byte("{"), 0o600))
+
+	b := New(file, 1, time.Minute)
+	open, _ := b.Open()
+	assert.False(t, open)
+
+	opened, err := b.Failure()
+	require.NoError(t, err)
+	assert.True(t, opened)
+}
+
+func TestBreaker_SuccessWithoutFailures(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "breaker.json")
+	b := New(file, 3, time.Minute)
+
+	require.NoError(t, b.Success())
+	assert.NoFileExists(t, file)
+}
@@ -0,0 +1,116 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/nseba/commit-ai/internal/config"
+	"github.com/nseba/commit-ai/internal/git"
+	"github.com/nseba/commit-ai/internal/keyring"
+)
+
+// apiTokenKeyringAccount is the keyring account holding CAI_API_TOKEN
+const apiTokenKeyringAccount = "api"
+
+// warnedAPITokens is set once the exposed API tokens were reported, so that
+// commands loading the configuration more than once warn only once
+var warnedAPITokens bool
+
+// keyringAPIToken returns the API token stored in the keyring, or "" when
+// there is none
+func keyringAPIToken() string {
+	token, err := keyring.Get(apiTokenKeyringAccount)
+	if err != nil && !errors.Is(err, keyring.ErrNotFound) && !errors.Is(err, keyring.ErrUnsupported) {
+		fmt.Fprintf(os.Stderr, "Warning: failed to read API token from keyring: %v\n", err)
+	}
+	return token
+}
+
+// apiTokenExposures describes how a config file holding CAI_API_TOKEN exposes
+// it: readable by other users, or in a git repository it may be committed to.
+// The mode of the global config file is already checked by the doctor, so
+// reportMode leaves it out.
+func apiTokenExposures(file string, reportMode bool) (exposures []string, readable bool) {
+	if info, err := os.Stat(file); err == nil {
+		if perm := info.Mode().Perm(); perm&0o077 != 0 {
+			readable = true
+			if reportMode {
+				exposures = append(exposures, fmt.Sprintf("is readable by other users (mode %o)", perm))
+			}
+		}
+	}
+	if repo, err := git.NewRepository(filepath.Dir(file)); err == nil && repo.MayCommit(file) {
+		exposures = append(exposures, "is in a git repository it may be committed to")
+	}
+	return exposures, readable
+}
+
+// isGlobalConfig reports whether file is the global config file
+func isGlobalConfig(file string) bool {
+	global, err := filepath.Abs(cfgFile)
+	return err == nil && global == file
+}
+
+// warnExposedAPITokens warns on stderr about every config file that holds
+// CAI_API_TOKEN in plain text where other users or a commit can expose it
+func warnExposedAPITokens(cfg *config.Config) {
+	if warnedAPITokens {
+		return
+	}
+	warnedAPITokens = true
+
+	for _, file := range cfg.APITokenFiles() {
+		exposures, _ := apiTokenExposures(file, true)
+		if len(exposures) > 0 {
+			fmt.Fprintf(os.Stderr, "Warning: %s holds CAI_API_TOKEN in plain text and %s; run 'commit-ai config doctor --fix'\n",
+				file, strings.Join(exposures, " and "))
+		}
+	}
+}
+
+// diagnoseAPITokens reports the config files that expose CAI_API_TOKEN. The
+// token is moved to the keyring when a single file sets it, since any file
+// setting it takes precedence over the keyring; otherwise the fix is
+// restricting the file's mode, when that is the problem.
+func diagnoseAPITokens(cfg *config.Config) []doctorFinding {
+	var findings []doctorFinding
+
+	files := cfg.APITokenFiles()
+	for _, file := range files {
+		reportMode := !isGlobalConfig(file)
+		exposures, readable := apiTokenExposures(file, reportMode)
+		if len(exposures) == 0 {
+			continue
+		}
+
+		finding := doctorFinding{
+			problem: fmt.Sprintf("%s holds CAI_API_TOKEN in plain text and %s", file, strings.Join(exposures, " and ")),
+		}
+		switch {
+		case len(files) == 1 && cfg.RequiresAPIToken():
+			finding.fix = "move the token to the OS keyring"
+			finding.apply = func() error { return moveAPITokenToKeyring(file) }
+		case readable && reportMode:
+			finding.fix = "restrict it to mode 600"
+			finding.apply = func() error { return os.Chmod(file, 0o600) }
+		}
+		findings = append(findings, finding)
+	}
+	return findings
+}
+
+// moveAPITokenToKeyring stores the CAI_API_TOKEN of file in the keyring and
+// removes it from the file
+func moveAPITokenToKeyring(file string) error {
+	token, err := config.ReadAPIToken(file)
+	if err != nil {
+		return err
+	}
+	if err := keyring.Set(apiTokenKeyringAccount, token); err != nil {
+		return err
+	}
+	return rewriteConfigFile(file, config.RemoveAPIToken)
+}
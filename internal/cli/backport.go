@@ -0,0 +1,49 @@
+package cli
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/nseba/commit-ai/internal/analyze"
+	"github.com/nseba/commit-ai/internal/commitmsg"
+	"github.com/nseba/commit-ai/internal/git"
+)
+
+// backportContext resolves the --backport commit rev and returns the prompt
+// instructions for describing diff as its backport, along with the hashes of
+// the "(cherry picked from commit ...)" lines the message must carry: those
+// of the original message, followed by the original commit itself.
+func backportContext(gitRepo *git.Repository, rev, diff string) (string, []string, error) {
+	commit, original, err := gitRepo.CommitDiff(rev)
+	if err != nil {
+		return "", nil, fmt.Errorf("invalid --backport commit: %w", err)
+	}
+	msg := commitmsg.Parse(commit.Message)
+	cherryPicks := append(slices.Clone(msg.CherryPicks), commit.Hash)
+
+	// The model restates the message without the cherry-pick lines, which
+	// are added back afterwards
+	msg.CherryPicks = nil
+	var b strings.Builder
+	fmt.Fprintf(&b, "This change backports commit %s to this branch. Its original message was:\n\n%s\n\n", commit.Hash[:12], msg.String())
+	b.WriteString("Keep the original message where it still describes the change.")
+
+	differences := analyze.BackportDifferences(diff, original)
+	if len(differences) == 0 {
+		b.WriteString(" The backport applies the original change unchanged.")
+	} else {
+		b.WriteString(" The backport differs from the original commit, most likely from resolving conflicts.")
+		b.WriteString(" Add a body paragraph starting with \"Conflicts resolved:\" that briefly summarizes these differences:\n")
+		b.WriteString(formatReasons(differences))
+	}
+	return b.String(), cherryPicks, nil
+}
+
+// addCherryPicks appends the cherry-pick lines of a backport to message
+func addCherryPicks(message string, hashes []string) string {
+	for _, hash := range hashes {
+		message = commitmsg.AddCherryPick(message, hash)
+	}
+	return message
+}
@@ -0,0 +1,137 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/nseba/commit-ai/internal/commitmsg"
+	"github.com/nseba/commit-ai/internal/config"
+	"github.com/nseba/commit-ai/internal/diffsource"
+	"github.com/nseba/commit-ai/internal/git"
+	"github.com/nseba/commit-ai/internal/policy"
+)
+
+// botLog writes the structured log of --bot runs, one JSON object per line
+// on stderr
+var botLog = slog.New(slog.NewJSONHandler(os.Stderr, nil))
+
+// checkBotFlags rejects the flags that need someone at the terminal
+func checkBotFlags() error {
+	switch {
+	case editCommit:
+		return fmt.Errorf("--bot and --edit cannot be used together")
+	case interactiveChunks:
+		return fmt.Errorf("--bot and --interactive-chunks cannot be used together")
+	case filterMode:
+		return fmt.Errorf("--bot and --filter cannot be used together")
+	}
+	return nil
+}
+
+// applyBotSettings makes generation deterministic and the message strictly
+// a commit message, and commits as CAI_BOT_AUTHOR when it is set
+func applyBotSettings(cfg *config.Config, gitRepo *git.Repository) {
+	temperature := 0.0
+	cfg.Temperature = &temperature
+	cfg.OutputContract = config.OutputContractStrict
+	if name, email, err := config.ParseAuthor(cfg.BotAuthor); err == nil {
+		gitRepo.SetAuthor(name, email)
+	}
+}
+
+// runBot generates a conventional commit message for the repository's
+// changes and commits them without prompting, logging each step. Failures
+// are logged before they are returned.
+func runBot(cmd *cobra.Command, cfg *config.Config, gitRepo *git.Repository, targetPath string, src diffsource.Source) error {
+	err := commitAsBot(cmd, cfg, gitRepo, targetPath, src)
+	if err != nil && !errors.Is(err, ErrNoChanges) {
+		botLog.Error("failed", "error", err.Error())
+	}
+	return err
+}
+
+// commitAsBot is the body of runBot
+func commitAsBot(cmd *cobra.Command, cfg *config.Config, gitRepo *git.Repository, targetPath string, src diffsource.Source) error {
+	start := time.Now()
+	applyBotSettings(cfg, gitRepo)
+
+	if stageAll {
+		if err := gitRepo.StageAll(); err != nil {
+			return fmt.Errorf("failed to stage changes: %w", err)
+		}
+		if len(onlyPaths) > 0 {
+			botLog.Info("staged", "paths", onlyPaths)
+		} else {
+			botLog.Info("staged")
+		}
+	}
+
+	diff, err := readDiff(gitRepo, src)
+	if err != nil {
+		return err
+	}
+	if diff == "" && !allowEmpty {
+		return botNoChanges(cmd, "no changes to commit")
+	}
+	if err := checkCommitSize(cfg, diff, true); err != nil {
+		return err
+	}
+
+	p, err := newPipeline(cfg, gitRepo, targetPath, diff)
+	if err != nil {
+		return err
+	}
+	if p == nil {
+		return botNoChanges(cmd, "no changes left after applying ignore patterns")
+	}
+	p.pol = policy.RequireConventional(p.pol)
+
+	message, violations, err := p.generateWithHint("")
+	if err != nil {
+		return fmt.Errorf("failed to generate commit message: %w", err)
+	}
+	if len(violations) > 0 {
+		reasons := make([]string, 0, len(violations))
+		for _, v := range violations {
+			reasons = append(reasons, v.String())
+		}
+		return fmt.Errorf("generated message violates the commit policy: %s", strings.Join(reasons, "; "))
+	}
+
+	attrs := []any{"subject", commitmsg.Parse(message).Header}
+	if p.gen.LastPromptHash() == "" {
+		attrs = append(attrs, "composed", "locally")
+	} else {
+		provider := p.gen.LastProvider()
+		attrs = append(attrs, "provider", provider.Provider, "model", provider.Model)
+	}
+	botLog.Info("generated", attrs...)
+
+	if err := p.commit(message); err != nil {
+		return fmt.Errorf("failed to commit: %w", err)
+	}
+	hash, err := gitRepo.HeadHash()
+	if err != nil {
+		return err
+	}
+	botLog.Info("committed", "commit", hash, "duration_ms", time.Since(start).Milliseconds())
+	return nil
+}
+
+// botNoChanges logs that there is nothing to commit, returning ErrNoChanges
+// with --exit-code
+func botNoChanges(cmd *cobra.Command, reason string) error {
+	botLog.Info("skipped", "reason", reason)
+	if !exitCode {
+		return nil
+	}
+	cmd.SilenceErrors = true
+	cmd.SilenceUsage = true
+	return ErrNoChanges
+}
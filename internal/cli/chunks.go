@@ -0,0 +1,56 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/nseba/commit-ai/internal/analyze"
+)
+
+// maxChunkFilesShown bounds the file list printed for each group
+const maxChunkFilesShown = 10
+
+// reviewChunks walks through the file groups of diff, asking for each whether
+// it belongs in the message context, and returns the diff of the approved
+// groups. Diffs with a single group are returned unchanged. The diff that is
+// committed is not affected, only what the model is told about.
+func reviewChunks(diff string) (string, error) {
+	groups := analyze.GroupFiles(diff)
+	if len(groups) < 2 {
+		return diff, nil
+	}
+	if !stdinIsTerminal() {
+		return "", fmt.Errorf("--interactive-chunks needs an interactive terminal")
+	}
+
+	editor := NewInteractiveEditor()
+	var approved []string
+	files := 0
+	for i, group := range groups {
+		fmt.Fprintf(os.Stderr, "\n[%d/%d] %s (%d files, +%d/-%d)\n",
+			i+1, len(groups), group.Name, len(group.Files), group.Added(), group.Removed())
+		for j, file := range group.Files {
+			if j == maxChunkFilesShown {
+				fmt.Fprintf(os.Stderr, "  ... and %d more\n", len(group.Files)-j)
+				break
+			}
+			fmt.Fprintf(os.Stderr, "  %s (+%d/-%d)\n", file.Path, len(file.Added), len(file.Removed))
+		}
+
+		include, err := editor.PromptYesNo("Include in this commit's message context?", true)
+		if err != nil {
+			return "", fmt.Errorf("failed to get confirmation: %w", err)
+		}
+		if include {
+			approved = append(approved, group.Diff())
+			files += len(group.Files)
+		}
+	}
+
+	if len(approved) == 0 {
+		return "", fmt.Errorf("no file groups selected for the message context")
+	}
+	fmt.Fprintf(os.Stderr, "\nGenerating from %d of %d file groups (%d files)\n", len(approved), len(groups), files)
+	return strings.Join(approved, "\n"), nil
+}
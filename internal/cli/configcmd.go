@@ -0,0 +1,121 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/BurntSushi/toml"
+	"github.com/spf13/cobra"
+
+	"github.com/nseba/commit-ai/internal/config"
+)
+
+var (
+	revealSecrets bool
+	schemaOutput  string
+)
+
+// configCmd groups commands that inspect the configuration
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Inspect the commit-ai configuration",
+}
+
+// configListCmd represents the config list command
+var configListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "Print the effective configuration",
+	Long: `Print the effective configuration for the current project after the global
+file, project .commitai files, CAI_* environment variables and flags such as
+--model are merged.
+
+Tokens and other secrets are masked unless --reveal-secrets is given.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runConfigList()
+	},
+}
+
+// runConfigList prints the effective configuration as TOML
+func runConfigList() error {
+	targetPath := "."
+	if path != "" {
+		targetPath = path
+	}
+
+	cfg, err := loadConfig(targetPath, false)
+	if err != nil {
+		return fmt.Errorf("failed to load configuration: %w", err)
+	}
+
+	if !revealSecrets {
+		cfg = cfg.Redacted()
+	}
+
+	if err := toml.NewEncoder(os.Stdout).Encode(cfg); err != nil {
+		return fmt.Errorf("failed to print configuration: %w", err)
+	}
+	return nil
+}
+
+// configSchemaCmd represents the config schema command
+var configSchemaCmd = &cobra.Command{
+	Use:   "schema",
+	Short: "Print the supported configuration keys",
+	Long: `Print every supported configuration key with its type, default value,
+environment variable, flag and description. Keys of nested tables are written as
+providers.<name>.url or CAI_MODEL_ROUTES[].model.
+
+--output json prints the keys for editors, validators and shell completion.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runConfigSchema()
+	},
+}
+
+// runConfigSchema prints the configuration schema
+func runConfigSchema() error {
+	if schemaOutput != "text" && schemaOutput != "json" {
+		return fmt.Errorf("invalid output format: %s. Supported formats: text, json", schemaOutput)
+	}
+
+	options := config.Schema()
+	if schemaOutput == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(options); err != nil {
+			return fmt.Errorf("failed to encode configuration schema: %w", err)
+		}
+		return nil
+	}
+
+	for i, option := range options {
+		if i > 0 {
+			fmt.Println()
+		}
+		fmt.Printf("%s (%s)\n", option.Key, option.Type)
+		fmt.Printf("  %s\n", option.Description)
+		if option.Default != nil {
+			defaultValue, err := json.Marshal(option.Default)
+			if err != nil {
+				return fmt.Errorf("failed to encode default of %s: %w", option.Key, err)
+			}
+			fmt.Printf("  Default: %s\n", defaultValue)
+		}
+		if option.Env != "" {
+			fmt.Printf("  Environment: %s\n", option.Env)
+		}
+		if flag, ok := configFlags[option.Key]; ok {
+			fmt.Printf("  Flag: --%s\n", flag)
+		}
+	}
+	return nil
+}
+
+func init() {
+	configCmd.PersistentFlags().BoolVar(&revealSecrets, "reveal-secrets", false, "print tokens and other secrets unmasked")
+	configSchemaCmd.Flags().StringVarP(&schemaOutput, "output", "o", "text", "output format: text or json")
+	configCmd.AddCommand(configListCmd)
+	configCmd.AddCommand(configSchemaCmd)
+}
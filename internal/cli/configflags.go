@@ -0,0 +1,83 @@
+package cli
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/nseba/commit-ai/internal/config"
+)
+
+// configFlags maps configuration keys to the flags overriding them for a
+// single run, such as CAI_MODEL to --model
+var configFlags = make(map[string]string)
+
+// configFlagsCmd is the command holding the configuration flags
+var configFlagsCmd *cobra.Command
+
+// configFlagName derives the flag of a configuration key: CAI_API_URL is set
+// with --api-url
+func configFlagName(key string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.TrimPrefix(key, "CAI_"), "_", "-"))
+}
+
+// addConfigFlags adds a persistent flag for every configuration key an
+// environment variable can set. Secrets are left out, as command lines end up
+// in shell history and process listings, and so are keys that already have a
+// flag, like --debug for CAI_DEBUG.
+func addConfigFlags(cmd *cobra.Command) {
+	configFlagsCmd = cmd
+	flags := cmd.PersistentFlags()
+	for _, option := range config.Schema() {
+		if option.Env == "" || option.Secret {
+			continue
+		}
+		name := configFlagName(option.Key)
+		if flags.Lookup(name) != nil {
+			continue
+		}
+
+		usage := fmt.Sprintf("%s (overrides %s)", option.Description, option.Key)
+		switch option.Type {
+		case "boolean":
+			flags.Bool(name, false, usage)
+		case "integer":
+			flags.Int(name, 0, usage)
+		case "float":
+			flags.Float64(name, 0, usage)
+		case "array of strings":
+			flags.StringSlice(name, nil, usage)
+		default:
+			flags.String(name, "", usage)
+		}
+		configFlags[option.Key] = name
+	}
+}
+
+// configOverrides returns the values of the configuration flags given on the
+// command line, keyed by configuration key, in the form the matching
+// environment variables take
+func configOverrides() map[string]string {
+	overrides := make(map[string]string)
+	if configFlagsCmd == nil {
+		return overrides
+	}
+	flags := configFlagsCmd.PersistentFlags()
+	for key, name := range configFlags {
+		flag := flags.Lookup(name)
+		if flag == nil || !flag.Changed {
+			continue
+		}
+		value := flag.Value.String()
+		if flag.Value.Type() == "stringSlice" {
+			values, err := flags.GetStringSlice(name)
+			if err != nil {
+				continue
+			}
+			value = strings.Join(values, ",")
+		}
+		overrides[key] = value
+	}
+	return overrides
+}
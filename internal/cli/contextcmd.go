@@ -0,0 +1,48 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/nseba/commit-ai/internal/config"
+)
+
+// maxExtraContext bounds how much command output is passed to the prompt
+const maxExtraContext = 8 * 1024
+
+// runContextCommand runs CAI_CONTEXT_CMD in dir and returns its trimmed
+// stdout. Failures are reported as warnings so a broken command never blocks
+// message generation.
+func runContextCommand(cfg *config.Config, dir string) string {
+	if cfg.ContextCmd == "" {
+		return ""
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(cfg.TimeoutSeconds)*time.Second)
+	defer cancel()
+
+	shell, flag := "sh", "-c"
+	if runtime.GOOS == "windows" {
+		shell, flag = "cmd", "/C"
+	}
+
+	cmd := exec.CommandContext(ctx, shell, flag, cfg.ContextCmd) // #nosec G204 -- the command comes from the global config, the environment or a flag, never from the repository
+	cmd.Dir = dir
+	cmd.Stderr = os.Stderr
+	out, err := cmd.Output()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: CAI_CONTEXT_CMD failed: %v\n", err)
+		return ""
+	}
+
+	output := strings.TrimSpace(string(out))
+	if len(output) > maxExtraContext {
+		output = output[:maxExtraContext] + "\n[output truncated]"
+	}
+	return output
+}
@@ -0,0 +1,231 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/nseba/commit-ai/internal/config"
+	"github.com/nseba/commit-ai/internal/generator"
+)
+
+var (
+	doctorFix bool
+	doctorYes bool
+)
+
+// configDoctorCmd represents the config doctor command
+var configDoctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "Check the configuration for problems and optionally fix them",
+	Long: `Check the global and project configuration for problems: a missing config
+file, config files readable by other users, API tokens stored in plain text in
+config files that other users can read or that may be committed, unknown keys,
+legacy keys, API URLs that include an endpoint path, invalid settings and a
+missing prompt template.
+
+With --fix the safe fixes are applied, each after confirmation unless --yes is
+given. Config files are edited in place, so comments and formatting are kept.
+An exposed API token is moved to the OS keyring when a single config file sets
+it. Problems without a safe fix, such as unknown keys, are only reported. The
+command exits with an error while problems remain.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+		return runConfigDoctor()
+	},
+}
+
+// doctorFinding is a configuration problem with an optional safe fix
+type doctorFinding struct {
+	problem string
+	// fix describes the fix as an imperative phrase; empty when there is none
+	fix   string
+	apply func() error
+}
+
+// runConfigDoctor reports the configuration problems and applies fixes
+func runConfigDoctor() error {
+	targetPath := "."
+	if path != "" {
+		targetPath = path
+	}
+
+	findings := diagnoseConfig(targetPath)
+	if len(findings) == 0 {
+		fmt.Println("✓ No problems found")
+		return nil
+	}
+
+	if doctorFix && config.AutoConfigDisabled() {
+		return fmt.Errorf("--fix is disabled by CAI_NO_AUTO_CONFIG")
+	}
+
+	editor := NewInteractiveEditor()
+	remaining := 0
+	for _, f := range findings {
+		fmt.Printf("✗ %s\n", f.problem)
+		if f.apply == nil {
+			remaining++
+			continue
+		}
+		if !doctorFix {
+			fmt.Printf("  Fix: %s (run with --fix)\n", f.fix)
+			remaining++
+			continue
+		}
+
+		if !doctorYes {
+			apply, err := editor.PromptYesNo(fmt.Sprintf("  %s?", capitalize(f.fix)), true)
+			if err != nil {
+				return fmt.Errorf("failed to get confirmation: %w", err)
+			}
+			if !apply {
+				remaining++
+				continue
+			}
+		}
+
+		if err := f.apply(); err != nil {
+			fmt.Printf("  Failed to %s: %v\n", f.fix, err)
+			remaining++
+			continue
+		}
+		fmt.Printf("  ✓ Fixed: %s\n", f.fix)
+	}
+
+	if remaining > 0 {
+		return fmt.Errorf("%d problem(s) remaining", remaining)
+	}
+	return nil
+}
+
+// diagnoseConfig collects the problems of the configuration for targetPath
+func diagnoseConfig(targetPath string) []doctorFinding {
+	var findings []doctorFinding
+
+	info, err := os.Stat(cfgFile)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		findings = append(findings, doctorFinding{
+			problem: fmt.Sprintf("global config %s does not exist", cfgFile),
+			fix:     "create it with the defaults",
+			apply:   func() error { return config.DefaultConfig().Save(cfgFile) },
+		})
+	case err != nil:
+		findings = append(findings, doctorFinding{problem: fmt.Sprintf("cannot read global config: %v", err)})
+	default:
+		if perm := info.Mode().Perm(); perm&0o077 != 0 {
+			findings = append(findings, doctorFinding{
+				problem: fmt.Sprintf("%s is accessible by other users (mode %o) and may contain API tokens", cfgFile, perm),
+				fix:     "restrict it to mode 600",
+				apply:   func() error { return os.Chmod(cfgFile, 0o600) },
+			})
+		}
+		findings = append(findings, diagnoseConfigFile(cfgFile)...)
+	}
+
+	projectFile := filepath.Join(targetPath, ".commitai")
+	if _, err := os.Stat(projectFile); err == nil {
+		findings = append(findings, diagnoseConfigFile(projectFile)...)
+	}
+
+	// Exposed API tokens are reported as findings rather than warnings
+	warnedAPITokens = true
+	cfg, err := loadConfig(targetPath, false)
+	if err != nil {
+		return append(findings, doctorFinding{problem: fmt.Sprintf("cannot load configuration: %v", err)})
+	}
+	findings = append(findings, diagnoseAPITokens(cfg)...)
+	if err := cfg.Validate(); err != nil {
+		// Report each problem as its own finding
+		var validationErr *config.ValidationError
+		if errors.As(err, &validationErr) {
+			for _, problem := range validationErr.Problems {
+				findings = append(findings, doctorFinding{problem: fmt.Sprintf("invalid configuration: %v", problem)})
+			}
+		} else {
+			findings = append(findings, doctorFinding{problem: fmt.Sprintf("invalid configuration: %v", err)})
+		}
+	}
+
+	templatePath := cfg.GetPromptTemplatePath(cfgFile)
+	if _, err := os.Stat(templatePath); errors.Is(err, os.ErrNotExist) {
+		findings = append(findings, doctorFinding{
+			problem: fmt.Sprintf("prompt template %s does not exist", templatePath),
+			fix:     "create it with the default template",
+			apply:   func() error { return generator.WriteDefaultTemplate(templatePath) },
+		})
+	}
+
+	return findings
+}
+
+// diagnoseConfigFile checks the keys and URLs of a single config file
+func diagnoseConfigFile(file string) []doctorFinding {
+	var findings []doctorFinding
+
+	keys, err := config.UnknownKeys(file)
+	if err != nil {
+		return append(findings, doctorFinding{problem: err.Error()})
+	}
+	for _, key := range keys {
+		findings = append(findings, doctorFinding{problem: fmt.Sprintf("unknown key %s in %s", key, file)})
+	}
+
+	data, err := os.ReadFile(file) // #nosec G304 -- config files chosen by the user
+	if err != nil {
+		return append(findings, doctorFinding{problem: fmt.Sprintf("cannot read %s: %v", file, err)})
+	}
+	content := string(data)
+
+	if _, changes := config.MigrateLegacyKeys(content); len(changes) > 0 {
+		findings = append(findings, doctorFinding{
+			problem: fmt.Sprintf("legacy keys in %s: %s", file, strings.Join(changes, ", ")),
+			fix:     "migrate them",
+			apply:   func() error { return rewriteConfigFile(file, config.MigrateLegacyKeys) },
+		})
+	}
+	if _, changes := config.FixAPIURLs(content); len(changes) > 0 {
+		findings = append(findings, doctorFinding{
+			problem: fmt.Sprintf("API URLs in %s include an endpoint path: %s", file, strings.Join(changes, ", ")),
+			fix:     "use the base URLs",
+			apply:   func() error { return rewriteConfigFile(file, config.FixAPIURLs) },
+		})
+	}
+
+	return findings
+}
+
+// rewriteConfigFile applies transform to the current content of file,
+// keeping its permissions
+func rewriteConfigFile(file string, transform func(string) (string, []string)) error {
+	info, err := os.Stat(file)
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(file) // #nosec G304 -- config files chosen by the user
+	if err != nil {
+		return err
+	}
+	content, _ := transform(string(data))
+	return writeFile(file, []byte(content), info.Mode().Perm())
+}
+
+// capitalize upper-cases the first letter of s
+func capitalize(s string) string {
+	if s == "" {
+		return s
+	}
+	return strings.ToUpper(s[:1]) + s[1:]
+}
+
+func init() {
+	configDoctorCmd.Flags().BoolVar(&doctorFix, "fix", false, "apply the safe fixes")
+	configDoctorCmd.Flags().BoolVarP(&doctorYes, "yes", "y", false, "apply fixes without confirmation")
+	configCmd.AddCommand(configDoctorCmd)
+}
@@ -0,0 +1,187 @@
+package cli
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/nseba/commit-ai/internal/diffsource"
+)
+
+// payloadSchemaVersion is bumped on incompatible editor payload changes
+const payloadSchemaVersion = 1
+
+var (
+	payloadCandidates int
+	payloadHint       string
+	payloadContinue   string
+)
+
+// EditorPayload is the JSON document printed by editor-payload
+type EditorPayload struct {
+	SchemaVersion     int          `json:"schema_version"`
+	Message           string       `json:"message"`
+	Subject           string       `json:"subject"`
+	Body              string       `json:"body"`
+	Candidates        []string     `json:"candidates"`
+	Diagnostics       []Diagnostic `json:"diagnostics"`
+	ContinuationToken string       `json:"continuation_token,omitempty"`
+}
+
+// Diagnostic is a problem or notice about the generated message
+type Diagnostic struct {
+	// Severity is "info", "warning" or "error"
+	Severity string `json:"severity"`
+	Code     string `json:"code"`
+	Message  string `json:"message"`
+}
+
+// continuation identifies the diff a payload was generated for, so a
+// "regenerate with hint" request can detect that the changes moved on
+type continuation struct {
+	Version  int    `json:"v"`
+	Repo     string `json:"repo"`
+	DiffHash string `json:"diff"`
+}
+
+// editorPayloadCmd represents the editor-payload command
+var editorPayloadCmd = &cobra.Command{
+	Use:   "editor-payload",
+	Short: "Print the generated message as JSON for editor plugins",
+	Long: `Print the generated commit message and metadata as a JSON document for
+editor plugins (VS Code, Neovim).
+
+The payload contains the message split into subject and body, the candidate
+messages (see --candidates), diagnostics such as commit policy violations, and
+a continuation token. Pass the token back with --continue and a --hint to
+regenerate the message with extra guidance; a stale-token diagnostic is
+reported when the staged changes no longer match.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runEditorPayload()
+	},
+}
+
+// payloadOptions select the candidates of an editor payload and how they
+// are generated
+type payloadOptions struct {
+	candidates   int
+	hint         string
+	continuation string
+}
+
+// runEditorPayload generates the candidates and prints the payload
+func runEditorPayload() error {
+	targetPath := "."
+	if path != "" {
+		targetPath = path
+	}
+
+	payload, err := buildPayload(targetPath, payloadOptions{
+		candidates:   payloadCandidates,
+		hint:         payloadHint,
+		continuation: payloadContinue,
+	})
+	if err != nil {
+		return err
+	}
+	return printPayload(payload)
+}
+
+// buildPayload generates the editor payload for the changes of the
+// repository at targetPath
+func buildPayload(targetPath string, opts payloadOptions) (*EditorPayload, error) {
+	payload := &EditorPayload{
+		SchemaVersion: payloadSchemaVersion,
+		Candidates:    []string{},
+		Diagnostics:   []Diagnostic{},
+	}
+
+	cfg, gitRepo, err := loadRepository(targetPath, false)
+	if err != nil {
+		return nil, err
+	}
+
+	diff, err := readDiff(gitRepo, diffsource.Auto{Repo: gitRepo})
+	if err != nil {
+		return nil, err
+	}
+	if diff == "" {
+		payload.Diagnostics = append(payload.Diagnostics, Diagnostic{Severity: "info", Code: "no-changes", Message: "No changes to commit"})
+		return payload, nil
+	}
+
+	token := newContinuation(gitRepo.Path(), diff)
+	if opts.continuation != "" && opts.continuation != token {
+		payload.Diagnostics = append(payload.Diagnostics, Diagnostic{
+			Severity: "warning",
+			Code:     "stale-token",
+			Message:  "The changes differ from the ones the continuation token was issued for",
+		})
+	}
+	payload.ContinuationToken = token
+
+	p, err := newPipeline(cfg, gitRepo, targetPath, diff)
+	if err != nil {
+		return nil, err
+	}
+	if p == nil {
+		payload.Diagnostics = append(payload.Diagnostics, Diagnostic{Severity: "info", Code: "all-ignored", Message: "All changes are excluded by ignore patterns"})
+		return payload, nil
+	}
+
+	count := opts.candidates
+	if count < 1 {
+		count = 1
+	}
+	for i := 0; i < count; i++ {
+		message, violations, err := p.generateWithHint(opts.hint)
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate commit message: %w", err)
+		}
+		payload.Candidates = append(payload.Candidates, message)
+
+		// Diagnostics describe the primary message only
+		if i == 0 {
+			for _, v := range violations {
+				payload.Diagnostics = append(payload.Diagnostics, Diagnostic{Severity: "warning", Code: "policy/" + v.Rule, Message: v.Message})
+			}
+		}
+	}
+
+	payload.Message = payload.Candidates[0]
+	subject, body, _ := strings.Cut(strings.TrimSpace(payload.Message), "\n")
+	payload.Subject = strings.TrimSpace(subject)
+	payload.Body = strings.TrimSpace(body)
+
+	return payload, nil
+}
+
+// newContinuation encodes the continuation token for a repository diff
+func newContinuation(repo, diff string) string {
+	sum := sha256.Sum256([]byte(diff))
+	data, _ := json.Marshal(continuation{Version: 1, Repo: repo, DiffHash: hex.EncodeToString(sum[:])})
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// printPayload writes the payload as indented JSON to stdout
+func printPayload(payload *EditorPayload) error {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(payload); err != nil {
+		return fmt.Errorf("failed to encode payload: %w", err)
+	}
+	return nil
+}
+
+func init() {
+	editorPayloadCmd.Flags().IntVarP(&payloadCandidates, "candidates", "n", 1, "number of candidate messages to generate")
+	editorPayloadCmd.Flags().StringVar(&payloadHint, "hint", "", "extra guidance for the model, e.g. \"mention the migration\"")
+	editorPayloadCmd.Flags().StringVar(&payloadContinue, "continue", "", "continuation token from a previous payload")
+}
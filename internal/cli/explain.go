@@ -0,0 +1,109 @@
+package cli
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/nseba/commit-ai/internal/analyze"
+	"github.com/nseba/commit-ai/internal/generator"
+	"github.com/nseba/commit-ai/internal/git"
+)
+
+var explainStaged bool
+
+// explainCmd represents the explain command
+var explainCmd = &cobra.Command{
+	Use:   "explain [rev]",
+	Short: "Explain in plain language what a commit or the staged changes do",
+	Long: `Explain what a change does and why it might have been made, for reviewing
+unfamiliar commits. The explanation is written in CAI_LANGUAGE and printed as
+Markdown on stdout.
+
+Without arguments the HEAD commit is explained. rev is any revision, such as
+a commit hash, a branch or HEAD~2; its commit message is passed to the model
+along with its diff against its first parent. With --staged the staged
+changes are explained instead.
+
+The diff goes through the same ignore patterns, LFS summaries, compression
+and model routes as for commit messages.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		rev := "HEAD"
+		if len(args) > 0 {
+			if explainStaged {
+				return fmt.Errorf("--staged and a revision cannot be used together")
+			}
+			rev = args[0]
+		}
+		return runExplain(rev)
+	},
+}
+
+// runExplain explains the commit at rev, or the staged changes with --staged
+func runExplain(rev string) error {
+	targetPath := "."
+	if path != "" {
+		targetPath = path
+	}
+
+	cfg, gitRepo, err := loadRepository(targetPath, true)
+	if err != nil {
+		return err
+	}
+
+	var message, diff string
+	if explainStaged {
+		diff, err = gitRepo.StagedDiff()
+		if err != nil {
+			return fmt.Errorf("failed to get staged diff: %w", err)
+		}
+	} else {
+		var commit git.HistoryCommit
+		commit, diff, err = gitRepo.CommitDiff(rev)
+		if err != nil {
+			return err
+		}
+		message = commit.Message
+	}
+
+	filteredDiff, err := gitRepo.ApplyIgnorePatterns(diff, targetPath)
+	if err != nil {
+		return fmt.Errorf("failed to apply ignore patterns: %w", err)
+	}
+	filteredDiff = analyze.SummarizeLFS(filteredDiff, cfg.SkipLFS)
+	if filteredDiff == "" {
+		if explainStaged {
+			return fmt.Errorf("no staged changes to explain")
+		}
+		return fmt.Errorf("%s has no changes to explain", rev)
+	}
+
+	enrichment := analyze.EnrichGo(filteredDiff)
+	filteredDiff, err = compressDiff(cfg, enrichment.Diff)
+	if err != nil {
+		return err
+	}
+	routeModel(cfg, gitRepo, filteredDiff)
+
+	gen, err := generator.New(cfg, cfgFile)
+	if err != nil {
+		return fmt.Errorf("failed to create generator: %w", err)
+	}
+	gen.SetContext(generator.PromptContext{
+		Breaking: formatReasons(analyze.DetectBreaking(filteredDiff)),
+		Notes:    formatReasons(enrichment.Notes),
+	})
+
+	explanation, err := gen.Explain(filteredDiff, message)
+	if err != nil {
+		return fmt.Errorf("failed to explain the change: %w", err)
+	}
+	fmt.Println(strings.TrimSpace(explanation))
+	return nil
+}
+
+func init() {
+	explainCmd.Flags().BoolVar(&explainStaged, "staged", false, "explain the staged changes instead of a commit")
+}
@@ -0,0 +1,91 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/nseba/commit-ai/internal/commitmsg"
+	"github.com/nseba/commit-ai/internal/config"
+	"github.com/nseba/commit-ai/internal/diffsource"
+	"github.com/nseba/commit-ai/internal/git"
+)
+
+// runFilter implements --filter: it reads a COMMIT_EDITMSG-style buffer from
+// stdin and writes it back with the generated message inserted above git's
+// comments, so ":%!commit-ai --filter" works in an editor. A buffer that
+// already has a message is written back as it is. Editors usually
+// capture stderr into the buffer as well, so problems are reported as '#'
+// comment lines, which git strips, and the buffer is never lost.
+func runFilter(targetPath string) error {
+	input, err := io.ReadAll(os.Stdin)
+	if err != nil {
+		return fmt.Errorf("failed to read buffer: %w", err)
+	}
+	buffer := string(input)
+
+	// Keep anything the author already wrote
+	if commitmsg.HasText(buffer) {
+		fmt.Print("# commit-ai: the buffer already has a message; delete it to generate one\n" + buffer)
+		return nil
+	}
+
+	message, notes, err := filterMessage(targetPath)
+	if err != nil {
+		notes = append(notes, err.Error())
+	}
+
+	var comments strings.Builder
+	for _, note := range notes {
+		comments.WriteString("# commit-ai: " + note + "\n")
+	}
+
+	if message == "" {
+		fmt.Print(comments.String() + buffer)
+		return nil
+	}
+
+	fmt.Print(commitmsg.InsertAboveComments(comments.String()+buffer, message))
+	return nil
+}
+
+// filterMessage generates the message for --filter and returns notes about
+// anything the author should know, such as remaining policy violations
+func filterMessage(targetPath string) (string, []string, error) {
+	cfg, gitRepo, err := loadRepository(targetPath, false)
+	if err != nil {
+		return "", nil, err
+	}
+	return filterRepositoryMessage(cfg, gitRepo, targetPath)
+}
+
+// filterRepositoryMessage is filterMessage for an already loaded repository
+func filterRepositoryMessage(cfg *config.Config, gitRepo *git.Repository, targetPath string) (string, []string, error) {
+	diff, err := readDiff(gitRepo, diffsource.Auto{Repo: gitRepo})
+	if err != nil {
+		return "", nil, err
+	}
+	if diff == "" {
+		return "", []string{"no changes to commit"}, nil
+	}
+
+	p, err := newPipeline(cfg, gitRepo, targetPath, diff)
+	if err != nil {
+		return "", nil, err
+	}
+	if p == nil {
+		return "", []string{"no changes after applying ignore patterns"}, nil
+	}
+
+	message, violations, err := p.generateWithHint("")
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to generate commit message: %w", err)
+	}
+
+	var notes []string
+	for _, v := range violations {
+		notes = append(notes, "policy violation: "+v.String())
+	}
+	return message, notes, nil
+}
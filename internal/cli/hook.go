@@ -0,0 +1,237 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/nseba/commit-ai/internal/commitmsg"
+)
+
+// hookCmd groups git hook integrations
+var hookCmd = &cobra.Command{
+	Use:   "hook",
+	Short: "Integrate commit-ai with git hooks",
+}
+
+var (
+	hookHusky bool
+	hookForce bool
+)
+
+// hookMarker identifies hook scripts written by commit-ai
+const hookMarker = "# Installed by commit-ai"
+
+// hookInstallCmd represents the hook install command
+var hookInstallCmd = &cobra.Command{
+	Use:   "install",
+	Short: "Install the prepare-commit-msg hook",
+	Long: `Install a prepare-commit-msg hook that runs "commit-ai hook prepare-commit-msg".
+
+By default the hook is written to the directory git runs hooks from:
+core.hooksPath when it is set, otherwise .git/hooks of the main repository,
+which linked worktrees share. With --husky it is written to
+.husky/prepare-commit-msg for JavaScript projects managed with husky. The
+script uses a portable "#!/usr/bin/env sh" shebang and LF line endings, so it
+also runs from Git for Windows.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		targetPath := "."
+		if path != "" {
+			targetPath = path
+		}
+		if err := ensureWritable(targetPath); err != nil {
+			return err
+		}
+		return runHookInstall(targetPath)
+	},
+}
+
+// hookPreCommitConfigCmd represents the hook pre-commit-config command
+var hookPreCommitConfigCmd = &cobra.Command{
+	Use:   "pre-commit-config",
+	Short: "Print a .pre-commit-config.yaml entry for the pre-commit framework",
+	Long: `Print the .pre-commit-config.yaml snippet that runs commit-ai in the
+prepare-commit-msg stage of the pre-commit framework (https://pre-commit.com).
+
+Add the snippet to your .pre-commit-config.yaml and install the hook type with:
+
+  pre-commit install --hook-type prepare-commit-msg`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		fmt.Print(preCommitConfig())
+	},
+}
+
+// hookPrepareCommitMsgCmd represents the hook prepare-commit-msg command
+var hookPrepareCommitMsgCmd = &cobra.Command{
+	Use:   "prepare-commit-msg <message-file> [source] [commit]",
+	Short: "Entrypoint for the prepare-commit-msg git hook",
+	Long: `Generate a commit message into the message file git passes to the
+prepare-commit-msg hook, keeping git's comments below it.
+
+Nothing is generated when the message already comes from somewhere else
+(-m, -F, a merge, a squash or an amend). The text git copied from
+commit.template is replaced by a message that fills it in, keeping the
+template's comments. The source is read from the
+second argument or, under the pre-commit framework, from
+PRE_COMMIT_COMMIT_MSG_SOURCE. Failures are written into the file as comments
+and never block the commit. In read-only mode (CAI_READ_ONLY) the hook
+leaves the file untouched.`,
+	Args: cobra.RangeArgs(1, 3),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		source := os.Getenv("PRE_COMMIT_COMMIT_MSG_SOURCE")
+		if len(args) > 1 {
+			source = args[1]
+		}
+		return runPrepareCommitMsg(args[0], source)
+	},
+}
+
+// preCommitConfig returns the .pre-commit-config.yaml snippet for this version
+func preCommitConfig() string {
+	rev := "v" + strings.TrimPrefix(version, "v")
+	if version == "dev" {
+		rev = "main # pin to a release tag"
+	}
+
+	return fmt.Sprintf(`default_install_hook_types: [pre-commit, prepare-commit-msg]
+repos:
+  - repo: https://github.com/nseba/commit-ai
+    rev: %s
+    hooks:
+      - id: commit-ai
+`, rev)
+}
+
+// runPrepareCommitMsg fills the message file unless git already has a
+// message. A message file git started from commit.template has only the
+// template, which the generated message fills in.
+func runPrepareCommitMsg(messageFile, source string) error {
+	fromTemplate := source == "template"
+	if source != "" && !fromTemplate {
+		return nil
+	}
+
+	content, err := os.ReadFile(messageFile) // #nosec G304 -- path is passed by git
+	if err != nil {
+		return fmt.Errorf("failed to read commit message file: %w", err)
+	}
+
+	// Keep anything the author already wrote
+	if !fromTemplate && commitmsg.HasText(string(content)) {
+		return nil
+	}
+
+	targetPath := "."
+	if path != "" {
+		targetPath = path
+	}
+
+	cfg, gitRepo, err := loadRepository(targetPath, false)
+	if err == nil && cfg.ReadOnly {
+		// Read-only mode leaves the message file as git wrote it
+		return nil
+	}
+	var message string
+	var notes []string
+	if err == nil {
+		message, notes, err = filterRepositoryMessage(cfg, gitRepo, targetPath)
+	}
+	if err != nil {
+		notes = append(notes, err.Error())
+	}
+
+	var comments strings.Builder
+	for _, note := range notes {
+		comments.WriteString("# commit-ai: " + note + "\n")
+	}
+
+	result := comments.String() + string(content)
+	switch {
+	case message != "" && fromTemplate:
+		result = commitmsg.ReplaceText(result, message)
+	case message != "":
+		result = commitmsg.InsertAboveComments(result, message)
+	}
+
+	if err := writeFile(messageFile, []byte(result), 0o600); err != nil {
+		return fmt.Errorf("failed to write commit message file: %w", err)
+	}
+	return nil
+}
+
+// runHookInstall writes the prepare-commit-msg hook script
+func runHookInstall(targetPath string) error {
+	_, gitRepo, err := loadRepository(targetPath, true)
+	if err != nil {
+		return err
+	}
+
+	hookDir := filepath.Join(gitRepo.Path(), ".husky")
+	if !hookHusky {
+		if hookDir, err = gitRepo.HooksDir(); err != nil {
+			return err
+		}
+	}
+	hookPath := filepath.Join(hookDir, "prepare-commit-msg")
+
+	if existing, err := os.ReadFile(hookPath); err == nil && !hookForce { // #nosec G304 -- fixed hook path inside the repository
+		if strings.Contains(string(existing), hookMarker) {
+			fmt.Printf("✓ Hook already installed: %s\n", hookPath)
+			return nil
+		}
+		return fmt.Errorf("%s already exists, use --force to replace it", hookPath)
+	}
+
+	if err := os.MkdirAll(hookDir, 0o750); err != nil {
+		return fmt.Errorf("failed to create hook directory: %w", err)
+	}
+
+	// #nosec G306 -- hooks must be executable
+	if err := writeFile(hookPath, []byte(hookScript(hookDir, hookHusky)), 0o755); err != nil {
+		return fmt.Errorf("failed to write hook: %w", err)
+	}
+
+	fmt.Printf("✓ Installed prepare-commit-msg hook: %s\n", hookPath)
+	return nil
+}
+
+// hookScript returns the prepare-commit-msg script. Husky v8 hooks must
+// source husky.sh, which husky v9 deprecated, so it is only sourced when the
+// v8 layout is present.
+func hookScript(hookDir string, husky bool) string {
+	var b strings.Builder
+	b.WriteString("#!/usr/bin/env sh\n")
+	b.WriteString(hookMarker + "\n")
+
+	if husky && isHuskyV8(hookDir) {
+		b.WriteString(`. "$(dirname -- "$0")/_/husky.sh"` + "\n")
+	}
+
+	b.WriteString("\n# Generate the commit message unless one was given with -m, -F, a merge, etc.\n")
+	b.WriteString(`commit-ai hook prepare-commit-msg "$1" "$2" "$3" || true` + "\n")
+	return b.String()
+}
+
+// isHuskyV8 reports whether the husky directory uses the husky v8 layout
+func isHuskyV8(huskyDir string) bool {
+	if _, err := os.Stat(filepath.Join(huskyDir, "_", "husky.sh")); err != nil {
+		return false
+	}
+	// husky v9 ships the "h" runner next to its deprecated husky.sh
+	_, err := os.Stat(filepath.Join(huskyDir, "_", "h"))
+	return err != nil
+}
+
+func init() {
+	hookInstallCmd.Flags().BoolVar(&hookHusky, "husky", false, "install into .husky/ for husky-managed JavaScript projects")
+	hookInstallCmd.Flags().BoolVar(&hookForce, "force", false, "replace an existing prepare-commit-msg hook")
+
+	hookCmd.AddCommand(hookInstallCmd)
+	hookCmd.AddCommand(hookPreCommitConfigCmd)
+	hookCmd.AddCommand(hookPrepareCommitMsgCmd)
+}
@@ -0,0 +1,196 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/nseba/commit-ai/internal/analyze"
+	"github.com/nseba/commit-ai/internal/git"
+)
+
+var (
+	ignoreYes     bool
+	ignoreDryRun  bool
+	ignoreLargeKB int64
+)
+
+// ignoreSections heads the suggested patterns of each reason in .caiignore
+var ignoreSections = map[string]string{
+	analyze.IgnoreVendored:  "# Vendored dependencies",
+	analyze.IgnoreGenerated: "# Generated files and lockfiles",
+	analyze.IgnoreLarge:     "# Large files",
+}
+
+// ignoreCmd groups the .caiignore commands
+var ignoreCmd = &cobra.Command{
+	Use:   "ignore",
+	Short: "Manage .caiignore patterns",
+}
+
+// ignoreSuggestCmd represents the ignore suggest command
+var ignoreSuggestCmd = &cobra.Command{
+	Use:   "suggest",
+	Short: "Suggest .caiignore patterns from the repository's files",
+	Long: `Scan the repository's tracked files for ones that make poor prompt material
+and propose .caiignore patterns for them, one at a time:
+
+- vendored dependencies: vendor/, node_modules/, third_party/, bower_components/
+- generated files: lockfiles, minified code, source maps, protobuf code, and
+  files starting with a generated code marker such as "Code generated ... DO
+  NOT EDIT." or "@generated"
+- large files of at least --large-kb
+
+Files already ignored are skipped. The accepted patterns are appended to the
+.caiignore file in the repository root, which is created when there is none.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		targetPath := "."
+		if path != "" {
+			targetPath = path
+		}
+		if !ignoreDryRun {
+			if err := ensureWritable(targetPath); err != nil {
+				return err
+			}
+		}
+		return runIgnoreSuggest(targetPath)
+	},
+}
+
+// runIgnoreSuggest proposes ignore patterns and adds the accepted ones
+func runIgnoreSuggest(targetPath string) error {
+	gitRepo, err := git.NewRepository(targetPath)
+	if err != nil {
+		return fmt.Errorf("failed to initialize git repository: %w", err)
+	}
+	root := gitRepo.Path()
+	gitRepo.SetGlobalIgnoreFile(globalIgnoreFile())
+	if err := gitRepo.SetIgnorePatterns(root); err != nil {
+		return fmt.Errorf("failed to apply ignore patterns: %w", err)
+	}
+
+	suggestions, err := suggestIgnores(gitRepo)
+	if err != nil {
+		return err
+	}
+	if len(suggestions) == 0 {
+		fmt.Println("No suggestions: no vendored, generated or large files outside the ignore patterns")
+		return nil
+	}
+
+	if ignoreDryRun {
+		for _, s := range suggestions {
+			fmt.Printf("%s\t# %s: %s\n", s.Pattern, s.Reason, s.Detail)
+		}
+		return nil
+	}
+
+	accepted := suggestions
+	if !ignoreYes {
+		if !stdinIsTerminal() {
+			return errors.New("ignore suggest needs an interactive terminal; use --yes to add every suggestion or --dry-run to list them")
+		}
+		accepted = nil
+		editor := NewInteractiveEditor()
+		for i, s := range suggestions {
+			fmt.Fprintf(os.Stderr, "\n[%d/%d] %s (%s: %s)\n", i+1, len(suggestions), s.Pattern, s.Reason, s.Detail)
+			add, err := editor.PromptYesNo("Add to .caiignore?", true)
+			if err != nil {
+				return fmt.Errorf("failed to get confirmation: %w", err)
+			}
+			if add {
+				accepted = append(accepted, s)
+			}
+		}
+	}
+	if len(accepted) == 0 {
+		fmt.Println("No patterns added")
+		return nil
+	}
+
+	ignorePath := filepath.Join(root, ".caiignore")
+	if err := appendIgnorePatterns(ignorePath, accepted); err != nil {
+		return err
+	}
+	fmt.Printf("✓ Added %d patterns to %s\n", len(accepted), ignorePath)
+	return nil
+}
+
+// suggestIgnores analyzes the tracked files of the repository that aren't
+// ignored yet
+func suggestIgnores(gitRepo *git.Repository) ([]analyze.IgnoreSuggestion, error) {
+	tracked, err := gitRepo.TrackedFiles()
+	if err != nil {
+		return nil, err
+	}
+
+	var files []analyze.RepoFile
+	for _, name := range tracked {
+		if gitRepo.IsIgnored(name) {
+			continue
+		}
+		// Deleted files, symbolic links and submodules are skipped
+		info, err := os.Lstat(filepath.Join(gitRepo.Path(), filepath.FromSlash(name)))
+		if err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+		files = append(files, analyze.RepoFile{Path: name, Size: info.Size()})
+	}
+
+	return analyze.SuggestIgnores(files, ignoreLargeKB<<10, func(name string) ([]byte, error) {
+		f, err := os.Open(filepath.Join(gitRepo.Path(), filepath.FromSlash(name))) // #nosec G304 -- tracked file of the repository
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		return io.ReadAll(io.LimitReader(f, analyze.IgnoreHeadBytes))
+	}), nil
+}
+
+// appendIgnorePatterns adds the patterns of suggestions to the ignore file,
+// grouped by reason, creating the file when it doesn't exist
+func appendIgnorePatterns(ignorePath string, suggestions []analyze.IgnoreSuggestion) error {
+	content, err := os.ReadFile(ignorePath) // #nosec G304 -- .caiignore in the repository root
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to read .caiignore: %w", err)
+	}
+
+	var b strings.Builder
+	b.Write(content)
+	if len(content) == 0 {
+		b.WriteString("# Commit-AI ignore patterns (suggested by commit-ai ignore suggest)\n")
+		b.WriteString("# These files will be excluded from diff analysis when generating commit messages\n")
+	} else {
+		if !strings.HasSuffix(string(content), "\n") {
+			b.WriteString("\n")
+		}
+		b.WriteString("\n# Suggested by commit-ai ignore suggest\n")
+	}
+
+	reason := ""
+	for _, s := range suggestions {
+		if s.Reason != reason {
+			reason = s.Reason
+			fmt.Fprintf(&b, "\n%s\n", ignoreSections[reason])
+		}
+		fmt.Fprintf(&b, "%s\n", s.Pattern)
+	}
+
+	if err := writeFile(ignorePath, []byte(b.String()), 0o600); err != nil {
+		return fmt.Errorf("failed to write .caiignore: %w", err)
+	}
+	return nil
+}
+
+func init() {
+	ignoreSuggestCmd.Flags().BoolVarP(&ignoreYes, "yes", "y", false, "add every suggestion without asking")
+	ignoreSuggestCmd.Flags().BoolVar(&ignoreDryRun, "dry-run", false, "only list the suggestions")
+	ignoreSuggestCmd.Flags().Int64Var(&ignoreLargeKB, "large-kb", 512, "size in KiB from which files are suggested as large; 0 disables it")
+	ignoreCmd.AddCommand(ignoreSuggestCmd)
+}
@@ -2,10 +2,26 @@ package cli
 
 import (
 	"bufio"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
+	"path/filepath"
+	"strconv"
 	"strings"
+
+	"github.com/nseba/commit-ai/internal/git"
+	"github.com/nseba/commit-ai/internal/interrupt"
+	"github.com/nseba/commit-ai/internal/lineedit"
+)
+
+var (
+	// errInputCancelled is returned when input is cancelled with Ctrl-C or
+	// by choosing to quit
+	errInputCancelled = lineedit.ErrCancelled
+	// errNoLineEditing is returned when the terminal cannot be put in raw mode
+	errNoLineEditing = lineedit.ErrUnavailable
 )
 
 // EditMode represents the editing mode
@@ -20,13 +36,51 @@ const (
 // InteractiveEditor handles user interaction for editing commit messages
 type InteractiveEditor struct {
 	reader *bufio.Reader
+	// lines edits the lines read with ReadLine, reading from reader
+	lines *lineedit.Editor
+	// gitDir receives the external editor's message file and gitEditor is
+	// core.editor; both are set by UseRepository
+	gitDir    string
+	gitEditor string
 }
 
 // NewInteractiveEditor creates a new interactive editor
 func NewInteractiveEditor() *InteractiveEditor {
+	reader := bufio.NewReader(os.Stdin)
 	return &InteractiveEditor{
-		reader: bufio.NewReader(os.Stdin),
+		reader: reader,
+		lines:  lineedit.New(reader, os.Stderr),
+	}
+}
+
+// ReadLine reads a line on the terminal with readline-style editing, see
+// lineedit.Editor. Ctrl-C returns errInputCancelled. When standard input is
+// not a terminal, or it cannot be put in raw mode, errNoLineEditing is
+// returned.
+func (ie *InteractiveEditor) ReadLine(prompt, initial string) (string, error) {
+	if !stdinIsTerminal() {
+		return "", errNoLineEditing
+	}
+	return ie.lines.ReadLine(prompt, initial)
+}
+
+// UseRepository makes the external editor follow git: the message file is
+// written to the repository's git directory, like COMMIT_EDITMSG, and
+// core.editor chooses the editor
+func (ie *InteractiveEditor) UseRepository(gitRepo *git.Repository) {
+	if dir, err := gitRepo.GitDir(); err == nil {
+		ie.gitDir = dir
+	}
+	ie.gitEditor = gitRepo.Editor()
+}
+
+// stdinIsTerminal reports whether standard input is an interactive terminal
+func stdinIsTerminal() bool {
+	info, err := os.Stdin.Stat()
+	if err != nil {
+		return false
 	}
+	return info.Mode()&os.ModeCharDevice != 0
 }
 
 // PromptYesNo prompts the user for a yes/no answer
@@ -36,7 +90,7 @@ func (ie *InteractiveEditor) PromptYesNo(question string, defaultValue bool) (bo
 		defaultStr = "Y/n"
 	}
 
-	fmt.Printf("%s [%s]: ", question, defaultStr)
+	fmt.Fprintf(os.Stderr, "%s [%s]: ", question, defaultStr)
 
 	response, err := ie.reader.ReadString('\n')
 	if err != nil {
@@ -59,34 +113,77 @@ func (ie *InteractiveEditor) PromptYesNo(question string, defaultValue bool) (bo
 	}
 }
 
-// PromptChoice prompts the user to choose from a list of options
-func (ie *InteractiveEditor) PromptChoice(question string, options []string) (int, error) {
-	fmt.Println(question)
+// maxChoiceAttempts bounds how often PromptChoice asks again after an
+// invalid answer
+const maxChoiceAttempts = 3
+
+// PromptChoice prompts the user to choose from a numbered list of options and
+// returns the index of the chosen one. An empty response chooses
+// defaultChoice, which is marked in the list; "q" returns errInputCancelled.
+func (ie *InteractiveEditor) PromptChoice(question string, options []string, defaultChoice int) (int, error) {
+	if defaultChoice < 0 || defaultChoice >= len(options) {
+		defaultChoice = 0
+	}
+
+	fmt.Fprintln(os.Stderr, question)
 	for i, option := range options {
-		fmt.Printf("  %d. %s\n", i+1, option)
+		if i == defaultChoice {
+			option += " (default)"
+		}
+		fmt.Fprintf(os.Stderr, "  %d. %s\n", i+1, option)
+	}
+
+	for attempt := 1; ; attempt++ {
+		fmt.Fprintf(os.Stderr, "Choose an option [%d], or q to cancel: ", defaultChoice+1)
+
+		response, err := ie.reader.ReadString('\n')
+		if err != nil {
+			return 0, fmt.Errorf("failed to read input: %w", err)
+		}
+
+		response = strings.TrimSpace(strings.ToLower(response))
+		switch response {
+		case "":
+			return defaultChoice, nil
+		case "q", "quit":
+			return 0, errInputCancelled
+		}
+		if n, err := strconv.Atoi(response); err == nil && n >= 1 && n <= len(options) {
+			return n - 1, nil
+		}
+
+		if attempt == maxChoiceAttempts {
+			return 0, fmt.Errorf("no valid choice after %d attempts", maxChoiceAttempts)
+		}
+		fmt.Fprintf(os.Stderr, "Invalid choice. Enter a number from 1 to %d.\n", len(options))
 	}
-	fmt.Print("Choose an option [1]: ")
+}
+
+// PromptKey prompts the user for a single-key action. An empty response (just
+// Enter) returns an empty string; otherwise the first character is returned
+// lowercased if it is one of the accepted keys.
+func (ie *InteractiveEditor) PromptKey(question string, keys []string) (string, error) {
+	fmt.Fprintf(os.Stderr, "%s ", question)
 
 	response, err := ie.reader.ReadString('\n')
 	if err != nil {
-		return 0, fmt.Errorf("failed to read input: %w", err)
+		return "", fmt.Errorf("failed to read input: %w", err)
 	}
 
-	response = strings.TrimSpace(response)
+	response = strings.TrimSpace(strings.ToLower(response))
 	if response == "" {
-		return 0, nil // Default to first option
+		return "", nil
 	}
 
-	// Try to parse as number
-	if len(response) == 1 && response[0] >= '1' && response[0] <= '9' {
-		choice := int(response[0] - '1')
-		if choice >= 0 && choice < len(options) {
-			return choice, nil
+	key := response[:1]
+	for _, k := range keys {
+		if key == k {
+			return key, nil
 		}
 	}
 
-	fmt.Println("Invalid choice. Please try again.")
-	return ie.PromptChoice(question, options)
+	fmt.Fprintln(os.Stderr, "Invalid key. Please try again.")
+	return ie.PromptKey(question, keys)
 }
 
 // EditMessage allows the user to edit a commit message
@@ -103,17 +200,34 @@ func (ie *InteractiveEditor) EditMessage(message string, mode EditMode) (string,
 	}
 }
 
-// editInline allows inline editing of the message
+// editInline allows inline editing of the message. On a terminal the
+// subject line is edited in place and the body is kept; otherwise the new
+// message may span several lines and an empty first line keeps the current one.
 func (ie *InteractiveEditor) editInline(message string) (string, error) {
-	fmt.Printf("Current message: %s\n", message)
-	fmt.Print("Enter new message (or press Enter to keep current): ")
+	subject, body, _ := strings.Cut(message, "\n")
+	edited, err := ie.ReadLine("Subject: ", subject)
+	switch {
+	case err == nil:
+		if strings.TrimSpace(edited) == "" {
+			return message, nil
+		}
+		if body == "" {
+			return strings.TrimSpace(edited), nil
+		}
+		return strings.TrimSpace(edited) + "\n" + body, nil
+	case errors.Is(err, errInputCancelled):
+		fmt.Fprintln(os.Stderr, "Edit cancelled.")
+		return message, nil
+	case !errors.Is(err, errNoLineEditing):
+		return message, err
+	}
 
-	response, err := ie.reader.ReadString('\n')
+	fmt.Fprintf(os.Stderr, "Current message:\n%s\n", message)
+
+	response, err := ie.PromptMultiline("Enter new message (or press Enter to keep current)", inputSentinel)
 	if err != nil {
-		return message, fmt.Errorf("failed to read input: %w", err)
+		return message, err
 	}
-
-	response = strings.TrimSpace(response)
 	if response == "" {
 		return message, nil
 	}
@@ -121,40 +235,22 @@ func (ie *InteractiveEditor) editInline(message string) (string, error) {
 	return response, nil
 }
 
+// editorMessageFile is the name of the external editor's message file
+const editorMessageFile = "COMMIT_AI_EDITMSG"
+
 // editWithEditor opens the user's preferred editor to edit the message
 func (ie *InteractiveEditor) editWithEditor(message string) (string, error) {
-	// Get the editor from environment variables
-	editor := os.Getenv("EDITOR")
-	if editor == "" {
-		editor = os.Getenv("VISUAL")
-	}
-	if editor == "" {
-		// Default editors to try
-		editors := []string{"nano", "vim", "vi", "emacs"}
-		for _, ed := range editors {
-			if _, err := exec.LookPath(ed); err == nil {
-				editor = ed
-				break
-			}
-		}
-		if editor == "" {
-			return "", fmt.Errorf("no editor found. Please set EDITOR or VISUAL environment variable")
-		}
-	}
-
-	// Validate editor command for security
-	if strings.Contains(editor, "/") && !strings.HasPrefix(editor, "/usr/bin/") && !strings.HasPrefix(editor, "/bin/") {
-		if _, err := exec.LookPath(editor); err != nil {
-			return "", fmt.Errorf("editor not found in PATH: %s", editor)
-		}
+	args, err := ie.editorCommand()
+	if err != nil {
+		return "", err
 	}
 
-	// Create temporary file
-	tmpFile, err := os.CreateTemp("", "commit-ai-*.txt")
+	tmpFile, err := ie.createMessageFile()
 	if err != nil {
-		return "", fmt.Errorf("failed to create temporary file: %w", err)
+		return "", err
 	}
 	tmpFileName := tmpFile.Name()
+	defer interrupt.OnInterrupt(func() { _ = os.Remove(tmpFileName) })()
 	defer func() {
 		if err := os.Remove(tmpFileName); err != nil {
 			// Log error but don't fail the operation
@@ -174,17 +270,19 @@ func (ie *InteractiveEditor) editWithEditor(message string) (string, error) {
 	}
 
 	// Open editor with validated command
-	cmd := exec.Command(editor, tmpFileName) // #nosec G204 -- editor is validated above
+	cmd := exec.Command(args[0], append(args[1:], tmpFileName)...) // #nosec G204 -- editor is validated by editorCommand
+	// The editor draws on stderr so a captured stdout only receives the message
 	cmd.Stdin = os.Stdin
-	cmd.Stdout = os.Stdout
+	cmd.Stdout = os.Stderr
 	cmd.Stderr = os.Stderr
 
-	if err := cmd.Run(); err != nil {
+	// Like git, leave Ctrl-C to the editor while it runs
+	if err := interrupt.IgnoreInterrupts(cmd.Run); err != nil {
 		return "", fmt.Errorf("failed to run editor: %w", err)
 	}
 
 	// Read edited content
-	content, err := os.ReadFile(tmpFileName) // #nosec G304 -- tmpFileName is from os.CreateTemp, safe path
+	content, err := os.ReadFile(tmpFileName) // #nosec G304 -- tmpFileName is the message file created above
 	if err != nil {
 		return "", fmt.Errorf("failed to read edited file: %w", err)
 	}
@@ -192,17 +290,80 @@ func (ie *InteractiveEditor) editWithEditor(message string) (string, error) {
 	return strings.TrimSpace(string(content)), nil
 }
 
+// editorCommand returns the editor and its arguments, chosen like git from
+// GIT_EDITOR, core.editor, EDITOR and VISUAL, falling back to the first
+// common editor found in PATH
+func (ie *InteractiveEditor) editorCommand() ([]string, error) {
+	var args []string
+	for _, editor := range []string{os.Getenv("GIT_EDITOR"), ie.gitEditor, os.Getenv("EDITOR"), os.Getenv("VISUAL")} {
+		if args = strings.Fields(editor); len(args) > 0 {
+			break
+		}
+	}
+	if len(args) == 0 {
+		// Default editors to try
+		for _, ed := range []string{"nano", "vim", "vi", "emacs"} {
+			if _, err := exec.LookPath(ed); err == nil {
+				return []string{ed}, nil
+			}
+		}
+		return nil, fmt.Errorf("no editor found. Please set EDITOR, VISUAL or git's core.editor")
+	}
+
+	// Validate editor command for security
+	editor := args[0]
+	if strings.Contains(editor, "/") && !strings.HasPrefix(editor, "/usr/bin/") && !strings.HasPrefix(editor, "/bin/") {
+		if _, err := exec.LookPath(editor); err != nil {
+			return nil, fmt.Errorf("editor not found in PATH: %s", editor)
+		}
+	}
+	return args, nil
+}
+
+// createMessageFile creates the file the external editor edits, readable
+// only by the user: COMMIT_AI_EDITMSG in the git directory, or a uniquely
+// named file in commit-ai's cache directory outside a repository
+func (ie *InteractiveEditor) createMessageFile() (*os.File, error) {
+	if ie.gitDir != "" {
+		name := filepath.Join(ie.gitDir, editorMessageFile)
+		f, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600) // #nosec G304 -- a fixed name in the git directory
+		if err != nil {
+			return nil, fmt.Errorf("failed to create temporary file: %w", err)
+		}
+		// A file left by an earlier run keeps its mode when truncated
+		if err := f.Chmod(0o600); err != nil {
+			_ = f.Close()
+			return nil, fmt.Errorf("failed to create temporary file: %w", err)
+		}
+		return f, nil
+	}
+
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		dir = os.TempDir()
+	}
+	dir = filepath.Join(dir, "commit-ai")
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create temporary directory: %w", err)
+	}
+	f, err := os.CreateTemp(dir, editorMessageFile+"-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	return f, nil
+}
+
 // DisplayMessage displays a commit message with formatting
 func (ie *InteractiveEditor) DisplayMessage(title, message string) {
-	fmt.Printf("\n%s:\n", title)
-	fmt.Printf("─────────────────────────────────────────────────────────────\n")
-	fmt.Printf("%s\n", message)
-	fmt.Printf("─────────────────────────────────────────────────────────────\n")
+	fmt.Fprintf(os.Stderr, "\n%s:\n", title)
+	fmt.Fprintf(os.Stderr, "─────────────────────────────────────────────────────────────\n")
+	fmt.Fprintf(os.Stderr, "%s\n", message)
+	fmt.Fprintf(os.Stderr, "─────────────────────────────────────────────────────────────\n")
 }
 
 // PromptString prompts for a string input
 func (ie *InteractiveEditor) PromptString(question string) (string, error) {
-	fmt.Printf("%s: ", question)
+	fmt.Fprintf(os.Stderr, "%s: ", question)
 
 	response, err := ie.reader.ReadString('\n')
 	if err != nil {
@@ -211,3 +372,32 @@ func (ie *InteractiveEditor) PromptString(question string) (string, error) {
 
 	return strings.TrimSpace(response), nil
 }
+
+// inputSentinel is the line that ends multi-line input
+const inputSentinel = "."
+
+// PromptMultiline prompts for input spanning several lines, read until a
+// line consisting of sentinel or the end of input. An empty first line
+// returns an empty string, so Enter alone still skips the prompt.
+func (ie *InteractiveEditor) PromptMultiline(question, sentinel string) (string, error) {
+	fmt.Fprintf(os.Stderr, "%s, ending with a line containing only %q:\n", question, sentinel)
+
+	var lines []string
+	for {
+		line, err := ie.reader.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return "", fmt.Errorf("failed to read input: %w", err)
+		}
+
+		line = strings.TrimRight(line, "\r\n")
+		if strings.TrimSpace(line) == sentinel || (len(lines) == 0 && strings.TrimSpace(line) == "") {
+			break
+		}
+		lines = append(lines, line)
+		if err == io.EOF {
+			break
+		}
+	}
+
+	return strings.TrimSpace(strings.Join(lines, "\n")), nil
+}
@@ -0,0 +1,91 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/nseba/commit-ai/internal/git"
+	"github.com/nseba/commit-ai/internal/policy"
+)
+
+// lintCmd represents the lint command
+var lintCmd = &cobra.Command{
+	Use:   "lint [message-file]",
+	Short: "Check a commit message against the team policy",
+	Long: `Check a commit message against the repository's .commitai-policy file.
+
+The message is read from the given file (for example .git/COMMIT_EDITMSG in a
+commit-msg hook), from standard input when the file is "-", or from the last
+commit when no file is given. The command exits with an error when the message
+violates the policy, making it suitable for CI.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runLint(args)
+	},
+}
+
+// runLint loads the policy and the message to check and reports violations
+func runLint(args []string) error {
+	targetPath := "."
+	if path != "" {
+		targetPath = path
+	}
+
+	pol, err := policy.Discover(targetPath)
+	if err != nil {
+		return fmt.Errorf("failed to load commit policy: %w", err)
+	}
+	if pol == nil {
+		fmt.Printf("No %s file found, nothing to check\n", policy.FileName)
+		return nil
+	}
+
+	message, err := readLintMessage(args, targetPath)
+	if err != nil {
+		return err
+	}
+
+	violations := pol.Check(message)
+	if len(violations) == 0 {
+		fmt.Println("✓ Commit message satisfies the commit policy")
+		return nil
+	}
+
+	fmt.Fprintln(os.Stderr, "Commit message violates the commit policy:")
+	for _, v := range violations {
+		fmt.Fprintf(os.Stderr, "  - %s\n", v)
+	}
+	return fmt.Errorf("%d policy violation(s) found", len(violations))
+}
+
+// readLintMessage reads the message to lint from a file, stdin or the last commit
+func readLintMessage(args []string, targetPath string) (string, error) {
+	if len(args) == 0 {
+		gitRepo, err := git.NewRepository(targetPath)
+		if err != nil {
+			return "", fmt.Errorf("failed to initialize git repository: %w", err)
+		}
+		message, err := gitRepo.GetLastCommitMessage()
+		if err != nil {
+			return "", fmt.Errorf("failed to get last commit message: %w", err)
+		}
+		return message, nil
+	}
+
+	if args[0] == "-" {
+		content, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return "", fmt.Errorf("failed to read message from stdin: %w", err)
+		}
+		return string(content), nil
+	}
+
+	content, err := os.ReadFile(args[0]) // #nosec G304 -- message file is provided by the user
+	if err != nil {
+		return "", fmt.Errorf("failed to read message file: %w", err)
+	}
+	return string(content), nil
+}
@@ -0,0 +1,125 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"github.com/nseba/commit-ai/internal/config"
+	"github.com/nseba/commit-ai/internal/generator"
+)
+
+var (
+	modelsOutput  string
+	modelsPresets bool
+)
+
+// modelsCmd represents the models command
+var modelsCmd = &cobra.Command{
+	Use:   "models",
+	Short: "List the models offered by the configured provider",
+	Long: `List the models the configured provider offers: the installed models for
+Ollama and the /v1/models listing for OpenAI-compatible services. The model in
+use is marked with an asterisk.
+
+For provider presets (deepseek, qwen) the well-known models are shown when the
+service can't be asked. Use --presets to list the presets with their base URL
+and default model.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runModels()
+	},
+}
+
+// modelListing is the JSON output of the models command
+type modelListing struct {
+	Provider string   `json:"provider"`
+	Preset   string   `json:"preset,omitempty"`
+	URL      string   `json:"url"`
+	Model    string   `json:"model"`
+	Models   []string `json:"models"`
+}
+
+// runModels prints the models of the active provider, or the presets
+func runModels() error {
+	if modelsOutput != "text" && modelsOutput != "json" {
+		return fmt.Errorf("invalid output format: %s. Supported formats: text, json", modelsOutput)
+	}
+	if modelsPresets {
+		return printPresets()
+	}
+
+	cfg, err := loadConfig(".", false)
+	if err != nil {
+		return fmt.Errorf("failed to load configuration: %w", err)
+	}
+	gen, err := generator.New(cfg, cfgFile)
+	if err != nil {
+		return fmt.Errorf("failed to create generator: %w", err)
+	}
+
+	active := cfg.ActiveProvider()
+	models, err := gen.ListModels()
+	if err != nil {
+		preset, ok := config.Preset(active.Preset)
+		if !ok {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "Warning: %v\nShowing the well-known %s models instead.\n", err, preset.Title)
+		models = preset.Models
+	}
+
+	if modelsOutput == "json" {
+		if models == nil {
+			models = []string{}
+		}
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		listing := modelListing{Provider: active.Provider, Preset: active.Preset, URL: active.URL, Model: active.Model, Models: models}
+		if err := encoder.Encode(listing); err != nil {
+			return fmt.Errorf("failed to encode models: %w", err)
+		}
+		return nil
+	}
+
+	for _, model := range models {
+		marker := " "
+		if model == active.Model {
+			marker = "*"
+		}
+		fmt.Printf("%s %s\n", marker, model)
+	}
+	return nil
+}
+
+// printPresets lists the provider presets
+func printPresets() error {
+	if modelsOutput == "json" {
+		presets := make(map[string]config.ProviderPreset)
+		for _, name := range config.PresetNames() {
+			presets[name], _ = config.Preset(name)
+		}
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(presets); err != nil {
+			return fmt.Errorf("failed to encode presets: %w", err)
+		}
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "PRESET\tSERVICE\tURL\tDEFAULT MODEL")
+	for _, name := range config.PresetNames() {
+		preset, _ := config.Preset(name)
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", name, preset.Title, preset.URL, preset.DefaultModel)
+	}
+	return w.Flush()
+}
+
+func init() {
+	modelsCmd.Flags().StringVarP(&modelsOutput, "output", "o", "text", "output format: text or json")
+	modelsCmd.Flags().BoolVar(&modelsPresets, "presets", false, "list the provider presets instead")
+}
@@ -0,0 +1,93 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/nseba/commit-ai/internal/git"
+	"github.com/nseba/commit-ai/internal/notes"
+)
+
+var notesOutput string
+
+// notesCmd represents the notes command
+var notesCmd = &cobra.Command{
+	Use:   "notes",
+	Short: "Inspect the generation notes attached to commits",
+	Long: `Inspect the generation metadata commit-ai attaches to the commits it creates
+when CAI_NOTES is enabled.
+
+The metadata (provider, model, prompt hash, candidate messages and whether the
+message was edited) is stored as a git note under ` + notes.Ref + `. Share it
+with "git push origin ` + notes.Ref + `".`,
+}
+
+// notesShowCmd represents the notes show command
+var notesShowCmd = &cobra.Command{
+	Use:   "show [rev]",
+	Short: "Show the generation note of a commit (default HEAD)",
+	Args:  cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		rev := "HEAD"
+		if len(args) > 0 {
+			rev = args[0]
+		}
+		return runNotesShow(rev)
+	},
+}
+
+// runNotesShow prints the generation note attached to rev
+func runNotesShow(rev string) error {
+	if notesOutput != "text" && notesOutput != "json" {
+		return fmt.Errorf("invalid output format: %s. Supported formats: text, json", notesOutput)
+	}
+
+	targetPath := "."
+	if path != "" {
+		targetPath = path
+	}
+
+	gitRepo, err := git.NewRepository(targetPath)
+	if err != nil {
+		return fmt.Errorf("failed to initialize git repository: %w", err)
+	}
+
+	note, err := gitRepo.ReadNote(notes.Ref, rev)
+	if err != nil {
+		return err
+	}
+	meta, err := notes.Decode(note)
+	if err != nil {
+		return err
+	}
+
+	if notesOutput == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(meta); err != nil {
+			return fmt.Errorf("failed to encode note: %w", err)
+		}
+		return nil
+	}
+
+	fmt.Printf("Tool:        %s\n", meta.Tool)
+	if meta.Provider != "" {
+		fmt.Printf("Provider:    %s\n", meta.Provider)
+		fmt.Printf("Model:       %s\n", meta.Model)
+		fmt.Printf("Prompt hash: %s\n", meta.PromptHash)
+	}
+	fmt.Printf("Generated:   %s\n", meta.GeneratedAt.Format("2006-01-02 15:04:05 MST"))
+	fmt.Printf("Edited:      %t\n", meta.Edited)
+	for i, candidate := range meta.Candidates {
+		fmt.Printf("\nCandidate %d:\n%s\n", i+1, candidate)
+	}
+	return nil
+}
+
+func init() {
+	notesShowCmd.Flags().StringVarP(&notesOutput, "output", "o", "text", "output format: text or json")
+	notesCmd.AddCommand(notesShowCmd)
+}
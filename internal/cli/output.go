@@ -0,0 +1,65 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// emitMessage prints the final commit message on stdout, or writes it to the
+// --out file. Stdout carries nothing else: prompts, progress and warnings go to
+// stderr, so msg=$(commit-ai) captures just the message.
+func emitMessage(message string) error {
+	if outFile == "" {
+		if editCommit {
+			fmt.Fprintln(os.Stderr, "\nFinal message:")
+			fmt.Println(strings.TrimRight(message, "\n"))
+			return nil
+		}
+		fmt.Print(message)
+		return nil
+	}
+
+	if err := writeMessageFile(outFile, message, appendOut); err != nil {
+		return err
+	}
+	if editCommit || commitChanges {
+		fmt.Fprintf(os.Stderr, "✓ Wrote commit message to %s\n", outFile)
+	}
+	return nil
+}
+
+// writeMessageFile writes message to file terminated by exactly one newline.
+// With appendMode the message is added after the existing content, which is
+// first terminated with a newline if needed.
+func writeMessageFile(file, message string, appendMode bool) error {
+	content := strings.TrimRight(message, "\r\n") + "\n"
+
+	flags := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+	if appendMode {
+		flags = os.O_CREATE | os.O_WRONLY | os.O_APPEND
+
+		existing, err := os.ReadFile(filepath.Clean(file))
+		if err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to read %s: %w", file, err)
+		}
+		if len(existing) > 0 && !strings.HasSuffix(string(existing), "\n") {
+			content = "\n" + content
+		}
+	}
+
+	// #nosec G302 G304 -- the user chose the file; commit messages are not secret
+	f, err := os.OpenFile(filepath.Clean(file), flags, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", file, err)
+	}
+	if _, err := f.WriteString(content); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("failed to write %s: %w", file, err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to write %s: %w", file, err)
+	}
+	return nil
+}
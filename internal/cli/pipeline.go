@@ -0,0 +1,490 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/nseba/commit-ai/internal/analyze"
+	"github.com/nseba/commit-ai/internal/commitmsg"
+	"github.com/nseba/commit-ai/internal/config"
+	"github.com/nseba/commit-ai/internal/diffsource"
+	"github.com/nseba/commit-ai/internal/drafts"
+	"github.com/nseba/commit-ai/internal/generator"
+	"github.com/nseba/commit-ai/internal/git"
+	"github.com/nseba/commit-ai/internal/interrupt"
+	"github.com/nseba/commit-ai/internal/notes"
+	"github.com/nseba/commit-ai/internal/policy"
+	"github.com/nseba/commit-ai/internal/stats"
+)
+
+// attributionAuthor is the identity named by the co-author attribution trailer
+const attributionAuthor = "commit-ai <commit-ai@users.noreply.github.com>"
+
+// revertLookback is how many recent commits are checked for being undone by
+// the changes
+const revertLookback = 20
+
+// pipeline turns a prepared diff into commit messages, applying the ticket
+// trailers, the commit policy and the --breaking override
+type pipeline struct {
+	cfg      *config.Config
+	gitRepo  *git.Repository
+	gen      *generator.Generator
+	pol      *policy.Policy
+	diff     string
+	trailers []commitmsg.Trailer
+	// attribution holds the AI attribution trailer for model-generated messages
+	attribution []commitmsg.Trailer
+	// localMessage is a locally composed message for dependency-only changes
+	// and reverts
+	localMessage string
+	// candidates holds every message generated so far, for generation notes
+	candidates []string
+	// cherryPicks are the commit hashes of the "(cherry picked from commit
+	// ...)" lines added with --backport
+	cherryPicks []string
+	// drafts keeps the last message until it is committed; nil when the
+	// diff doesn't come from the repository's changes
+	drafts *drafts.Store
+}
+
+// loadRepository loads and validates the configuration for targetPath and
+// opens its repository. autoCreate is passed on to loadConfig.
+func loadRepository(targetPath string, autoCreate bool) (*config.Config, *git.Repository, error) {
+	cfg, err := loadConfig(targetPath, autoCreate)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load configuration: %w", err)
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, nil, fmt.Errorf("invalid configuration: %w", err)
+	}
+
+	gitRepo, err := git.NewRepository(targetPath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to initialize git repository: %w", err)
+	}
+	gitRepo.SetReadOnly(cfg.ReadOnly)
+	gitRepo.SetIndexOnly(cfg.IndexOnly)
+
+	// The repository is found from any directory inside the work tree; an
+	// explicit path, even --path ., also limits the diff to changes below it
+	if path != "" || targetPath != "." {
+		if err := gitRepo.SetScope(targetPath); err != nil {
+			return nil, nil, fmt.Errorf("invalid path: %w", err)
+		}
+	}
+	if err := gitRepo.SetPathspecs(onlyPaths); err != nil {
+		return nil, nil, fmt.Errorf("invalid --only path: %w", err)
+	}
+	if err := gitRepo.SetExcludes(append(fixtureExcludes(cfg, gitRepo), excludePaths...)); err != nil {
+		return nil, nil, fmt.Errorf("invalid --exclude path: %w", err)
+	}
+	// Ignored files are left out while the diff is generated, without being
+	// read; newPipeline's ApplyIgnorePatterns then finds nothing to drop
+	gitRepo.SetGlobalIgnoreFile(globalIgnoreFile())
+	if err := gitRepo.SetIgnorePatterns(targetPath); err != nil {
+		return nil, nil, fmt.Errorf("failed to apply ignore patterns: %w", err)
+	}
+
+	return cfg, gitRepo, nil
+}
+
+// globalIgnoreFile returns the path of the ignore file applied in every
+// repository, beneath its .caiignore files
+func globalIgnoreFile() string {
+	return filepath.Join(filepath.Dir(cfgFile), "ignore")
+}
+
+// fixtureExcludes returns CAI_REPLAY_FIXTURES when the file is in the
+// repository, so recording a response doesn't change the diff a later replay
+// describes
+func fixtureExcludes(cfg *config.Config, gitRepo *git.Repository) []string {
+	if cfg.ReplayFixtures == "" {
+		return nil
+	}
+	file, err := cfg.ReplayFixturesPath()
+	if err != nil {
+		return nil
+	}
+	rel, err := filepath.Rel(gitRepo.Path(), file)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return nil
+	}
+	return []string{file}
+}
+
+// readDiff reads the diff of src. Diffs that don't come from the repository's
+// changes, such as patch files, get the same path filters applied.
+func readDiff(gitRepo *git.Repository, src diffsource.Source) (string, error) {
+	diff, err := src.Diff()
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", src.Name(), err)
+	}
+	if !diffsource.IsRepositoryChanges(src) {
+		diff = gitRepo.ScopeDiff(diff)
+	}
+	return diff, nil
+}
+
+// newPipeline filters, enriches and compresses diff and sets up the
+// generator. An empty diff stands for an --allow-empty commit. It returns a
+// nil pipeline when the ignore patterns (or CAI_SKIP_LFS) filter out every
+// change.
+func newPipeline(cfg *config.Config, gitRepo *git.Repository, targetPath, diff string) (*pipeline, error) {
+	filteredDiff := emptyCommitDiff
+	if diff != "" {
+		var err error
+		filteredDiff, err = gitRepo.ApplyIgnorePatterns(diff, targetPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to apply ignore patterns: %w", err)
+		}
+		filteredDiff = analyze.SummarizeLFS(filteredDiff, cfg.SkipLFS)
+		if filteredDiff == "" {
+			return nil, nil
+		}
+	}
+
+	p := &pipeline{cfg: cfg, gitRepo: gitRepo}
+
+	// Dependency-only changes get a locally composed message
+	if diff != "" && !cfg.DepsUseLLM && backportRev == "" {
+		if bumps, ok := analyze.DetectDependencyBumps(filteredDiff); ok {
+			p.localMessage = analyze.ComposeBumpMessage(bumps)
+		}
+	}
+
+	// A change undoing a recent commit gets the message git revert writes
+	if diff != "" && p.localMessage == "" && backportRev == "" {
+		p.localMessage = detectRevert(cfg, gitRepo, diff)
+	}
+
+	// Summarize go.sum and generated files, and note Go-specific facts
+	var notes []string
+	if diff != "" {
+		enrichment := analyze.EnrichGo(filteredDiff)
+		filteredDiff, notes = enrichment.Diff, enrichment.Notes
+
+		var err error
+		filteredDiff, err = compressDiff(cfg, filteredDiff)
+		if err != nil {
+			return nil, err
+		}
+	}
+	p.diff = filteredDiff
+
+	// Apply the branch template matching the current branch
+	var instructions string
+	if branch, err := gitRepo.CurrentBranch(); err == nil {
+		if bt := cfg.BranchTemplateFor(branch); bt != nil {
+			if bt.Template != "" {
+				cfg.PromptTemplate = bt.Template
+			}
+			instructions = bt.Instructions
+		}
+	}
+
+	if backportRev != "" {
+		backport, cherryPicks, err := backportContext(gitRepo, backportRev, diff)
+		if err != nil {
+			return nil, err
+		}
+		instructions = strings.TrimSpace(instructions + "\n\n" + backport)
+		p.cherryPicks = cherryPicks
+	}
+
+	if diff != "" {
+		routeModel(cfg, gitRepo, filteredDiff)
+	}
+
+	gen, err := generator.New(cfg, cfgFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create generator: %w", err)
+	}
+	if providerMetrics != nil {
+		gen.SetMetrics(providerMetrics)
+	}
+	p.gen = gen
+
+	p.pol, err = policy.Discover(targetPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load commit policy: %w", err)
+	}
+
+	var issue string
+	issue, p.trailers = resolveTicketContext(cfg, gitRepo)
+	p.attribution = attributionTrailers(cfg)
+
+	var extraContext string
+	if p.localMessage == "" {
+		extraContext = runContextCommand(cfg, gitRepo.Path())
+	}
+
+	breaking := analyze.DetectBreaking(filteredDiff)
+	if markBreaking && len(breaking) == 0 {
+		breaking = []string{"marked as breaking by the author"}
+	}
+	gen.SetContext(generator.PromptContext{
+		Issue:          issue,
+		Instructions:   instructions,
+		Breaking:       formatReasons(breaking),
+		Notes:          formatReasons(notes),
+		ExtraContext:   extraContext,
+		BranchState:    branchState(cfg, gitRepo),
+		CommitTemplate: commitTemplate(gitRepo),
+	})
+
+	return p, nil
+}
+
+// routeModel applies the first CAI_MODEL_ROUTES entry matching the change and
+// the repository, if any. Without a matching route, a small change goes to
+// CAI_SMALL_CHANGE_MODEL when one is set.
+func routeModel(cfg *config.Config, gitRepo *git.Repository, diff string) {
+	if len(cfg.ModelRoutes) > 0 {
+		change := analyze.ProfileChange(diff)
+		var repo analyze.RepoProfile
+		if files, err := gitRepo.TrackedFiles(); err == nil {
+			repo = analyze.DetectRepo(files, func(name string) ([]byte, error) {
+				return os.ReadFile(filepath.Join(gitRepo.Path(), filepath.FromSlash(name))) // #nosec G304 -- manifest in the repository root
+			})
+		}
+
+		route := cfg.RouteFor(change, repo)
+		if cfg.Debug {
+			target := "no route matched"
+			if route != nil {
+				target = "routed to " + route.String()
+			}
+			fmt.Fprintf(os.Stderr, "Debug: change: %s, %d changed lines (%s); repository: %s (%s); %s\n",
+				change.Primary, change.ChangedLines, strings.Join(change.Languages, ", "),
+				strings.Join(repo.Languages, ", "), strings.Join(repo.Frameworks, ", "), target)
+		}
+		if route != nil {
+			cfg.ApplyRoute(route)
+			return
+		}
+	}
+
+	if cfg.SmallChangeModel != "" && analyze.IsSmallChange(diff, cfg.SmallChangeLines) {
+		if cfg.Debug {
+			fmt.Fprintf(os.Stderr, "Debug: small change, using model %s\n", cfg.SmallChangeModel)
+		}
+		cfg.ApplyRoute(&config.ModelRoute{Model: cfg.SmallChangeModel})
+	}
+}
+
+// detectRevert returns the revert message for the most recent of the last
+// revertLookback commits that diff undoes, or "" when it undoes none
+func detectRevert(cfg *config.Config, gitRepo *git.Repository, diff string) string {
+	commits, err := gitRepo.RecentCommits(revertLookback)
+	if err != nil {
+		return ""
+	}
+	for _, commit := range commits {
+		_, previous, err := gitRepo.CommitDiff(commit.Hash)
+		if err != nil || !analyze.IsRevert(diff, previous) {
+			continue
+		}
+		if cfg.Debug {
+			fmt.Fprintf(os.Stderr, "Debug: the changes revert commit %s\n", commit.Hash)
+		}
+		return analyze.ComposeRevertMessage(commitmsg.Parse(commit.Message).Header, commit.Hash)
+	}
+	return ""
+}
+
+// branchState describes how the current branch relates to its upstream for
+// the prompt, or returns "" when it has none
+func branchState(cfg *config.Config, gitRepo *git.Repository) string {
+	topology, err := gitRepo.Topology()
+	if err != nil {
+		if cfg.Debug {
+			fmt.Fprintf(os.Stderr, "Debug: branch state unavailable: %v\n", err)
+		}
+		return ""
+	}
+	if topology == nil {
+		return ""
+	}
+	if topology.Ahead == 0 && topology.Behind == 0 {
+		return fmt.Sprintf("%s is up to date with %s", topology.Branch, topology.Upstream)
+	}
+	return fmt.Sprintf("%s is %d ahead and %d behind %s (merge base %s)",
+		topology.Branch, topology.Ahead, topology.Behind, topology.Upstream, topology.MergeBase[:12])
+}
+
+// commitTemplate returns the text and guidance of the repository's
+// commit.template for the prompt, or "" without one
+func commitTemplate(gitRepo *git.Repository) string {
+	content, err := gitRepo.CommitTemplate()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		return ""
+	}
+
+	tmpl := commitmsg.ParseTemplate(content)
+	var parts []string
+	if tmpl.Text != "" {
+		parts = append(parts, "Template:\n"+tmpl.Text)
+	}
+	if len(tmpl.Guidance) > 0 {
+		parts = append(parts, "Guidance:\n"+formatReasons(tmpl.Guidance))
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+// attributionTrailers returns the AI attribution trailer selected by
+// CAI_ATTRIBUTION, if any
+func attributionTrailers(cfg *config.Config) []commitmsg.Trailer {
+	switch cfg.Attribution {
+	case config.AttributionCoAuthor:
+		return []commitmsg.Trailer{{Key: "Co-authored-by", Value: attributionAuthor}}
+	case config.AttributionAssisted:
+		return []commitmsg.Trailer{{Key: "AI-assisted", Value: "model=" + cfg.ActiveProvider().Model}}
+	default:
+		return nil
+	}
+}
+
+// generate returns a commit message, printing remaining policy violations
+// as warnings
+func (p *pipeline) generate() (string, error) {
+	message, violations, err := p.generateWithHint("")
+	if err != nil {
+		return "", err
+	}
+
+	if len(violations) > 0 {
+		fmt.Fprintln(os.Stderr, "Warning: generated message still violates the commit policy:")
+		for _, v := range violations {
+			fmt.Fprintf(os.Stderr, "  - %s\n", v)
+		}
+	}
+
+	return message, nil
+}
+
+// generateWithHint returns a commit message generated with an optional user
+// hint, along with the policy violations it still has
+func (p *pipeline) generateWithHint(hint string) (string, []policy.Violation, error) {
+	if p.localMessage != "" && hint == "" {
+		message := appendTrailers(p.localMessage, p.trailers)
+		p.candidates = append(p.candidates, message)
+		return message, nil, nil
+	}
+
+	message, violations, err := generateMessage(p.gen, p.pol, p.diff, hint, slices.Concat(p.trailers, p.attribution))
+	if err != nil {
+		return "", nil, err
+	}
+	if markBreaking {
+		message = commitmsg.MarkBreaking(message, commitmsg.Parse(message).Subject)
+	}
+	message = addCherryPicks(message, p.cherryPicks)
+	p.candidates = append(p.candidates, message)
+	p.saveDraft(message)
+	return message, violations, nil
+}
+
+// commit commits message and, with CAI_NOTES, records how it was generated
+// in a git note. Failing to write the note only produces a warning, as the
+// commit itself succeeded.
+func (p *pipeline) commit(message string) error {
+	if err := interrupt.Critical(func() error { return p.gitRepo.Commit(message) }); err != nil {
+		return err
+	}
+	if p.drafts != nil {
+		if err := p.drafts.Delete(p.gitRepo.Path()); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to remove draft message: %v\n", err)
+		}
+	}
+	if p.cfg.Notes {
+		if err := p.recordNote(message); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to record generation note: %v\n", err)
+		}
+	}
+	return nil
+}
+
+// recordNote attaches the generation metadata to the new HEAD commit
+func (p *pipeline) recordNote(message string) error {
+	hash, err := p.gitRepo.HeadHash()
+	if err != nil {
+		return err
+	}
+
+	meta := notes.New(version)
+	if promptHash := p.gen.LastPromptHash(); promptHash != "" {
+		provider := p.gen.LastProvider()
+		meta.Provider = provider.Provider
+		meta.Model = provider.Model
+		meta.PromptHash = promptHash
+	}
+	meta.Candidates = append(meta.Candidates, p.candidates...)
+	meta.Edited = len(p.candidates) == 0 || p.candidates[len(p.candidates)-1] != message
+
+	note, err := meta.Encode()
+	if err != nil {
+		return err
+	}
+	return p.gitRepo.AddNote(notes.Ref, hash, note)
+}
+
+// recordOutcome records whether the user accepted, edited or rejected the
+// generated message in the local stats. Messages composed without the model
+// are not recorded.
+func (p *pipeline) recordOutcome(message string, accepted bool) {
+	if p.cfg.NoStats || !statsEnabled() || p.gen.LastPromptHash() == "" {
+		return
+	}
+
+	outcome := stats.OutcomeAccepted
+	switch {
+	case !accepted:
+		outcome = stats.OutcomeRejected
+	case len(p.candidates) == 0 || p.candidates[len(p.candidates)-1] != message:
+		outcome = stats.OutcomeEdited
+	}
+
+	provider := p.gen.LastProvider()
+	err := stats.Record(statsFile(), stats.Event{
+		Time:          time.Now().UTC().Truncate(time.Second),
+		Outcome:       outcome,
+		Provider:      provider.Provider,
+		Model:         provider.Model,
+		Template:      p.cfg.PromptTemplate,
+		Regenerations: max(len(p.candidates)-1, 0),
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to record stats: %v\n", err)
+	}
+}
+
+// saveDraft keeps message as the draft for the changes, so a later run can
+// offer it again. Failing to save it only produces a warning.
+func (p *pipeline) saveDraft(message string) {
+	if p.drafts == nil {
+		return
+	}
+	if err := interrupt.Critical(func() error { return p.drafts.Save(p.gitRepo.Path(), p.diff, message) }); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to save draft message: %v\n", err)
+	}
+}
+
+// draft returns the saved draft of the repository, or nil when there is none
+func (p *pipeline) draft() *drafts.Draft {
+	if p.drafts == nil {
+		return nil
+	}
+	d, err := p.drafts.Get(p.gitRepo.Path())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to read draft message: %v\n", err)
+		return nil
+	}
+	return d
+}
@@ -0,0 +1,214 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/nseba/commit-ai/internal/analyze"
+	"github.com/nseba/commit-ai/internal/config"
+	"github.com/nseba/commit-ai/internal/generator"
+	"github.com/nseba/commit-ai/internal/git"
+	"github.com/nseba/commit-ai/internal/pr"
+)
+
+var (
+	prBase   string
+	prCreate bool
+	prVia    string
+	prDraft  bool
+)
+
+// prCmd represents the pr command
+var prCmd = &cobra.Command{
+	Use:   "pr",
+	Short: "Generate a pull request title and description for the current branch",
+	Long: `Generate a pull request title and description from the commits and diff of
+the current branch relative to its base branch.
+
+With --create the pull request is created by delegating to the gh (GitHub) or
+glab (GitLab) CLI, reusing their existing authentication. For GitLab remotes
+with CAI_GITLAB_TOKEN configured, the merge request for the current branch is
+created or updated directly through the GitLab API instead. Azure Repos
+remotes use the Azure DevOps API (CAI_AZURE_DEVOPS_TOKEN) and link the pull
+request to work items referenced by the branch name or AB#<id> mentions. The
+publisher is chosen from the origin remote unless --via is given.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runPR()
+	},
+}
+
+// runPR generates the pull request content and optionally publishes it
+func runPR() error {
+	targetPath := "."
+	if path != "" {
+		targetPath = path
+	}
+
+	cfg, err := loadConfig(targetPath, true)
+	if err != nil {
+		return fmt.Errorf("failed to load configuration: %w", err)
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return fmt.Errorf("invalid configuration: %w", err)
+	}
+
+	if cfg.ReadOnly && prCreate {
+		return fmt.Errorf("--create is disabled in read-only mode (CAI_READ_ONLY)")
+	}
+
+	gitRepo, err := git.NewRepository(targetPath)
+	if err != nil {
+		return fmt.Errorf("failed to initialize git repository: %w", err)
+	}
+	gitRepo.SetGlobalIgnoreFile(globalIgnoreFile())
+
+	base := prBase
+	if base == "" {
+		base, err = gitRepo.DefaultBranch()
+		if err != nil {
+			return err
+		}
+	}
+
+	head, err := gitRepo.CurrentBranch()
+	if err != nil {
+		return fmt.Errorf("failed to get current branch: %w", err)
+	}
+
+	changes, err := gitRepo.BranchChanges(base)
+	if err != nil {
+		return fmt.Errorf("failed to get branch changes: %w", err)
+	}
+
+	filteredDiff, err := gitRepo.ApplyIgnorePatterns(changes.Diff, targetPath)
+	if err != nil {
+		return fmt.Errorf("failed to apply ignore patterns: %w", err)
+	}
+	filteredDiff = analyze.SummarizeLFS(filteredDiff, cfg.SkipLFS)
+
+	if filteredDiff == "" {
+		fmt.Fprintf(os.Stderr, "No changes between %s and HEAD\n", base)
+		return nil
+	}
+
+	filteredDiff, err = compressDiff(cfg, analyze.EnrichGo(filteredDiff).Diff)
+	if err != nil {
+		return err
+	}
+
+	gen, err := generator.New(cfg, cfgFile)
+	if err != nil {
+		return fmt.Errorf("failed to create generator: %w", err)
+	}
+
+	issue, _ := resolveTicketContext(cfg, gitRepo)
+	gen.SetContext(generator.PromptContext{Issue: issue})
+
+	pullRequest, err := gen.GeneratePullRequest(filteredDiff, changes.Subjects)
+	if err != nil {
+		return fmt.Errorf("failed to generate pull request: %w", err)
+	}
+
+	if !prCreate {
+		fmt.Printf("%s\n\n%s\n", pullRequest.Title, pullRequest.Body)
+		return nil
+	}
+
+	publisher, err := newPRPublisher(cfg, gitRepo)
+	if err != nil {
+		return err
+	}
+
+	url, err := publisher.Publish(&pr.Request{
+		Title:     pullRequest.Title,
+		Body:      pullRequest.Body,
+		Base:      base,
+		Head:      head,
+		Draft:     prDraft,
+		WorkItems: pr.ExtractWorkItems(head, changes.Subjects),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create pull request with %s: %w", publisher.Name(), err)
+	}
+
+	fmt.Printf("✓ Published pull request: %s\n", url)
+	return nil
+}
+
+// newPRPublisher selects the publisher from --via or the origin remote host
+func newPRPublisher(cfg *config.Config, gitRepo *git.Repository) (pr.Publisher, error) {
+	via := prVia
+	if via == "" {
+		remote, err := gitRepo.Remote("origin")
+		if err != nil {
+			return nil, fmt.Errorf("cannot detect hosting service, use --via: %w", err)
+		}
+		via = pr.DetectPublisher(remote.Host)
+		if via == "" {
+			return nil, fmt.Errorf("unrecognized hosting service %s, use --via", remote.Host)
+		}
+		// Prefer the API when a GitLab token is configured
+		if via == pr.PublisherGlab && cfg.GitLabToken != "" {
+			via = pr.PublisherGitLab
+		}
+	}
+
+	switch via {
+	case pr.PublisherGitLab:
+		return newGitLabPublisher(cfg, gitRepo)
+	case pr.PublisherAzure:
+		return newAzurePublisher(cfg, gitRepo)
+	default:
+		return pr.NewCLIPublisher(via, gitRepo.Path())
+	}
+}
+
+// newAzurePublisher creates an Azure DevOps publisher for the origin repository
+func newAzurePublisher(cfg *config.Config, gitRepo *git.Repository) (pr.Publisher, error) {
+	if cfg.AzureToken == "" {
+		return nil, fmt.Errorf("CAI_AZURE_DEVOPS_TOKEN is required to use Azure DevOps")
+	}
+
+	remote, err := gitRepo.Remote("origin")
+	if err != nil {
+		return nil, fmt.Errorf("cannot resolve Azure DevOps repository: %w", err)
+	}
+
+	baseURL, project, repo, err := pr.AzureDevOpsTarget(remote.Host, remote.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	return pr.NewAzureDevOpsPublisher(baseURL, project, repo, cfg.AzureToken, time.Duration(cfg.TimeoutSeconds)*time.Second), nil
+}
+
+// newGitLabPublisher creates a GitLab API publisher for the origin project
+func newGitLabPublisher(cfg *config.Config, gitRepo *git.Repository) (pr.Publisher, error) {
+	if cfg.GitLabToken == "" {
+		return nil, fmt.Errorf("CAI_GITLAB_TOKEN is required to use the GitLab API")
+	}
+
+	remote, err := gitRepo.Remote("origin")
+	if err != nil {
+		return nil, fmt.Errorf("cannot resolve GitLab project: %w", err)
+	}
+
+	baseURL := cfg.GitLabURL
+	if baseURL == "" {
+		baseURL = "https://" + remote.Host
+	}
+
+	return pr.NewGitLabPublisher(baseURL, remote.Path, cfg.GitLabToken, time.Duration(cfg.TimeoutSeconds)*time.Second), nil
+}
+
+func init() {
+	prCmd.Flags().StringVar(&prBase, "base", "", "base branch to compare against (default is the repository default branch)")
+	prCmd.Flags().BoolVar(&prCreate, "create", false, "create the pull request instead of printing it")
+	prCmd.Flags().StringVar(&prVia, "via", "", "how to create the pull request: gh, glab, gitlab (API) or azure (default is detected from origin)")
+	prCmd.Flags().BoolVar(&prDraft, "draft", false, "create the pull request as a draft")
+}
@@ -0,0 +1,139 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/nseba/commit-ai/internal/commitmsg"
+	"github.com/nseba/commit-ai/internal/config"
+	"github.com/nseba/commit-ai/internal/diffsource"
+	"github.com/nseba/commit-ai/internal/drafts"
+)
+
+// resumeCmd represents the resume command
+var resumeCmd = &cobra.Command{
+	Use:   "resume [path]",
+	Short: "Review and commit the last generated message that wasn't committed",
+	Long: `Open the last message generated for this repository that wasn't committed
+in the edit and commit flow of --edit --commit, without asking the model
+again.
+
+Messages are kept in drafts.json next to the global config file until they
+are committed. When the changes differ from the ones the message was
+generated from, resume warns before showing it.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		targetPath := "."
+		if len(args) > 0 {
+			targetPath = args[0]
+		}
+		if path != "" {
+			targetPath = path
+		}
+		return runResume(targetPath)
+	},
+}
+
+// draftsFile returns the path of the local drafts file
+func draftsFile() string {
+	return filepath.Join(filepath.Dir(cfgFile), "drafts.json")
+}
+
+// draftsEnabled reports whether drafts may be written next to the config file
+func draftsEnabled() bool {
+	return !noConfigWrite && !config.AutoConfigDisabled()
+}
+
+// useDrafts keeps the messages of p as drafts when they describe the
+// repository's changes
+func useDrafts(p *pipeline, src diffsource.Source) {
+	if draftsEnabled() && diffsource.IsRepositoryChanges(src) {
+		p.drafts = drafts.New(draftsFile())
+	}
+}
+
+// offerDraft offers the saved draft when it was generated from the same
+// changes, so an interrupted review doesn't cost another generation. It
+// returns the draft message, or "" when there is none or it was declined.
+func offerDraft(p *pipeline) (string, error) {
+	d := p.draft()
+	if d == nil || !d.Matches(p.diff) || !stdinIsTerminal() {
+		return "", nil
+	}
+
+	editor := NewInteractiveEditor()
+	question := fmt.Sprintf("Found a draft for these changes from %s: %q. Use it instead of generating a new message?",
+		d.Time.Local().Format(time.DateTime), commitmsg.Parse(d.Message).Subject)
+	useDraft, err := editor.PromptYesNo(question, true)
+	if err != nil {
+		return "", fmt.Errorf("failed to get confirmation: %w", err)
+	}
+	if !useDraft {
+		return "", nil
+	}
+	p.candidates = append(p.candidates, d.Message)
+	return d.Message, nil
+}
+
+// runResume opens the draft of the repository at targetPath in the
+// interactive commit flow
+func runResume(targetPath string) error {
+	cfg, gitRepo, err := loadRepository(targetPath, true)
+	if err != nil {
+		return err
+	}
+	if cfg.ReadOnly {
+		return fmt.Errorf("resume is disabled in read-only mode (CAI_READ_ONLY)")
+	}
+	if !draftsEnabled() {
+		return fmt.Errorf("drafts are not kept with --no-config-write or CAI_NO_AUTO_CONFIG")
+	}
+	if err := checkProtectedBranch(cfg, gitRepo); err != nil {
+		return err
+	}
+
+	src := diffsource.Auto{Repo: gitRepo}
+	diff, err := readDiff(gitRepo, src)
+	if err != nil {
+		return err
+	}
+	if diff == "" {
+		return fmt.Errorf("no changes to commit")
+	}
+	if err := checkCommitSize(cfg, diff, true); err != nil {
+		return err
+	}
+
+	p, err := newPipeline(cfg, gitRepo, targetPath, diff)
+	if err != nil {
+		return err
+	}
+	if p == nil {
+		return fmt.Errorf("no changes left after applying ignore patterns")
+	}
+	useDrafts(p, src)
+
+	d := p.draft()
+	if d == nil {
+		return fmt.Errorf("no draft message for %s; run commit-ai to generate one", gitRepo.Path())
+	}
+	if !d.Matches(p.diff) {
+		fmt.Fprintln(os.Stderr, "Warning: the changes differ from the ones the draft was generated from")
+	}
+	p.candidates = append(p.candidates, d.Message)
+
+	editCommit, commitChanges = true, true
+	if cfg.QuickMode {
+		return handleQuickMode(d.Message, p)
+	}
+	return handleInteractiveMode(d.Message, p)
+}
+
+func init() {
+	resumeCmd.Flags().BoolVar(&forceCommit, "force", false, "allow committing on protected branches")
+	resumeCmd.Flags().BoolVar(&allowOversized, "allow-oversized", false, "allow committing changes CAI_OVERSIZED_COMMIT_MODE = \"refuse\" blocks as oversized")
+}
@@ -0,0 +1,128 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/nseba/commit-ai/internal/interrupt"
+	"github.com/nseba/commit-ai/internal/metrics"
+	"github.com/nseba/commit-ai/internal/serve"
+)
+
+// readHeaderTimeout bounds how long a client may take to send the request
+// headers, so idle connections cannot pile up
+const readHeaderTimeout = 10 * time.Second
+
+var (
+	serveListen string
+	serveSocket string
+)
+
+// providerMetrics counts the provider requests of every generator created
+// by newPipeline; nil unless the process serves requests
+var providerMetrics *metrics.Metrics
+
+// serveCmd represents the serve command
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Serve commit message generation over HTTP for editors and tools",
+	Long: `Serve commit message generation over HTTP, so editors and tools on a shared
+development machine can use one long-running commit-ai.
+
+POST /v1/generate takes a JSON request such as {"repo": "/home/me/src/app",
+"hint": "mention the migration", "candidates": 2} and answers with the
+editor-payload document for the repository's changes. GET /metrics reports
+provider requests in the Prometheus text format and GET /healthz answers "ok".
+
+By default commit-ai listens on the unix socket serve.sock next to the config
+file, which only its owner can use. --listen serves on a TCP address instead,
+which requires CAI_SERVE_TOKEN: clients then send it as a bearer token
+("Authorization: Bearer <token>"). A token set for a socket is checked too.
+
+Requests may only name repositories below the CAI_SERVE_REPOS directories,
+with symbolic links resolved. Their project config files apply as they do on
+the command line, so allow only repositories you trust. Request bodies are
+limited to CAI_SERVE_MAX_REQUEST_KB, and requests beyond
+CAI_SERVE_MAX_CONCURRENT generating at the same time are refused with 503.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runServe()
+	},
+}
+
+// runServe serves requests until the process is interrupted
+func runServe() error {
+	if serveListen != "" && serveSocket != "" {
+		return errors.New("--listen and --socket cannot be used together")
+	}
+
+	cfg, err := loadConfig(".", false)
+	if err != nil {
+		return fmt.Errorf("failed to load configuration: %w", err)
+	}
+	if err := cfg.Validate(); err != nil {
+		return fmt.Errorf("invalid configuration: %w", err)
+	}
+
+	repos, err := cfg.ServePaths()
+	if err != nil {
+		return err
+	}
+	if len(repos) == 0 {
+		return errors.New("no repositories may be served; set CAI_SERVE_REPOS to the directories holding them")
+	}
+	if cfg.ServeMaxRequestKB == 0 || cfg.ServeMaxConcurrent == 0 {
+		return errors.New("CAI_SERVE_MAX_REQUEST_KB and CAI_SERVE_MAX_CONCURRENT must be positive")
+	}
+
+	socket := serveSocket
+	if serveListen == "" && socket == "" {
+		socket = filepath.Join(filepath.Dir(cfgFile), "serve.sock")
+	}
+	if serveListen != "" && cfg.ServeToken == "" {
+		return errors.New("serving on TCP requires CAI_SERVE_TOKEN; set it or serve on a unix socket with --socket")
+	}
+
+	providerMetrics = metrics.New()
+	handler, err := serve.NewHandler(serve.Options{
+		Token:           cfg.ServeToken,
+		Repos:           repos,
+		MaxRequestBytes: int64(cfg.ServeMaxRequestKB) << 10,
+		MaxConcurrent:   cfg.ServeMaxConcurrent,
+		Metrics:         providerMetrics,
+	}, func(req serve.Request) (any, error) {
+		return buildPayload(req.Repo, payloadOptions{
+			candidates:   req.Candidates,
+			hint:         req.Hint,
+			continuation: req.Continue,
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	listener, err := serve.Listen(serveListen, socket)
+	if err != nil {
+		return fmt.Errorf("failed to listen: %w", err)
+	}
+	if socket != "" {
+		defer interrupt.OnInterrupt(func() { _ = os.Remove(socket) })()
+		fmt.Fprintf(os.Stderr, "Serving on unix socket %s\n", socket)
+	} else {
+		fmt.Fprintf(os.Stderr, "Serving on http://%s\n", listener.Addr())
+	}
+
+	server := &http.Server{Handler: handler, ReadHeaderTimeout: readHeaderTimeout}
+	return server.Serve(listener)
+}
+
+func init() {
+	serveCmd.Flags().StringVar(&serveListen, "listen", "", "serve on this TCP address, e.g. 127.0.0.1:7420 (requires CAI_SERVE_TOKEN)")
+	serveCmd.Flags().StringVar(&serveSocket, "socket", "", "serve on this unix socket (default serve.sock next to the config file)")
+}
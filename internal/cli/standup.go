@@ -0,0 +1,224 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/nseba/commit-ai/internal/analyze"
+	"github.com/nseba/commit-ai/internal/commitmsg"
+	"github.com/nseba/commit-ai/internal/config"
+	"github.com/nseba/commit-ai/internal/generator"
+	"github.com/nseba/commit-ai/internal/git"
+)
+
+// maxStandupDiff bounds the uncommitted changes of one repository in the
+// standup prompt
+const maxStandupDiff = 8 * 1024
+
+// daysPattern matches a --since value in days, such as "3d"
+var daysPattern = regexp.MustCompile(`^(\d+)d$`)
+
+var (
+	standupSince  string
+	standupAuthor string
+)
+
+// standupCmd represents the standup command
+var standupCmd = &cobra.Command{
+	Use:   "standup [repo...]",
+	Short: "Summarize recent commits and uncommitted work for a standup",
+	Long: `Summarize the work in one or more repositories since a given time, for
+standups and timesheets. The summary is written in CAI_LANGUAGE and printed as
+Markdown on stdout.
+
+The commits of every local branch authored since --since and the uncommitted
+changes of each repository are passed to the model. The repositories are the
+arguments, or CAI_STANDUP_REPOS from the global config, or the current
+repository. --author keeps only commits whose "Name <email>" author contains
+the given text, such as your email address.
+
+--since is a duration such as 24h or 3d, a date (2006-01-02) or a time in
+RFC 3339 format.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runStandup(args)
+	},
+}
+
+// runStandup summarizes the work in repos, or the configured repositories
+// when there are none
+func runStandup(repos []string) error {
+	since, err := parseSince(standupSince, time.Now())
+	if err != nil {
+		return err
+	}
+
+	cfg, err := loadConfig(".", true)
+	if err != nil {
+		return fmt.Errorf("failed to load configuration: %w", err)
+	}
+	if err := cfg.Validate(); err != nil {
+		return fmt.Errorf("invalid configuration: %w", err)
+	}
+
+	if len(repos) == 0 {
+		if repos, err = cfg.StandupPaths(); err != nil {
+			return err
+		}
+	}
+	if len(repos) == 0 {
+		repos = []string{"."}
+		if path != "" {
+			repos = []string{path}
+		}
+	}
+
+	var activity []generator.RepoActivity
+	for _, repo := range repos {
+		a, err := collectActivity(cfg, repo, since)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: skipping %s: %v\n", repo, err)
+			continue
+		}
+		if a.Commits != "" || a.Diff != "" {
+			activity = append(activity, a)
+		}
+	}
+	if len(activity) == 0 {
+		fmt.Fprintf(os.Stderr, "No commits or uncommitted changes since %s\n", since.Format(time.DateTime))
+		return nil
+	}
+
+	gen, err := generator.New(cfg, cfgFile)
+	if err != nil {
+		return fmt.Errorf("failed to create generator: %w", err)
+	}
+	summary, err := gen.Standup(activity, since)
+	if err != nil {
+		return fmt.Errorf("failed to summarize the work: %w", err)
+	}
+	fmt.Println(strings.TrimSpace(summary))
+	return nil
+}
+
+// collectActivity returns the commits since the given time and the
+// uncommitted changes of the repository at repoPath
+func collectActivity(cfg *config.Config, repoPath string, since time.Time) (generator.RepoActivity, error) {
+	gitRepo, err := git.NewRepository(repoPath)
+	if err != nil {
+		return generator.RepoActivity{}, err
+	}
+	gitRepo.SetIndexOnly(cfg.IndexOnly)
+	gitRepo.SetGlobalIgnoreFile(globalIgnoreFile())
+	if err := gitRepo.SetIgnorePatterns(repoPath); err != nil {
+		return generator.RepoActivity{}, fmt.Errorf("failed to apply ignore patterns: %w", err)
+	}
+	activity := generator.RepoActivity{Name: filepath.Base(gitRepo.Path())}
+
+	commits, err := gitRepo.CommitsSince(since)
+	if err != nil {
+		return generator.RepoActivity{}, err
+	}
+	var lines []string
+	for _, commit := range commits {
+		if standupAuthor != "" && !strings.Contains(strings.ToLower(commit.Author), strings.ToLower(standupAuthor)) {
+			continue
+		}
+		lines = append(lines, fmt.Sprintf("- %s %s", commit.Time.Local().Format("Mon 15:04"), commitmsg.Parse(commit.Message).Header))
+	}
+	activity.Commits = strings.Join(lines, "\n")
+
+	diff, err := uncommittedDiff(gitRepo)
+	if err != nil {
+		return generator.RepoActivity{}, err
+	}
+	if diff, err = gitRepo.ApplyIgnorePatterns(diff, repoPath); err != nil {
+		return generator.RepoActivity{}, fmt.Errorf("failed to apply ignore patterns: %w", err)
+	}
+	diff = analyze.SummarizeLFS(diff, cfg.SkipLFS)
+	if diff != "" {
+		if diff, err = compressDiff(cfg, analyze.EnrichGo(diff).Diff); err != nil {
+			return generator.RepoActivity{}, err
+		}
+	}
+	activity.Diff = truncateDiff(diff, maxStandupDiff)
+	return activity, nil
+}
+
+// uncommittedDiff returns the changes of the work tree, along with staged
+// changes to files that have no further changes in the work tree. Only the
+// staged changes are read in index-only mode.
+func uncommittedDiff(gitRepo *git.Repository) (string, error) {
+	if gitRepo.IndexOnly() {
+		staged, err := gitRepo.StagedDiff()
+		if err != nil {
+			return "", fmt.Errorf("failed to get staged diff: %w", err)
+		}
+		return staged, nil
+	}
+
+	worktree, err := gitRepo.WorkingTreeDiff()
+	if err != nil {
+		return "", fmt.Errorf("failed to get work tree diff: %w", err)
+	}
+	staged, err := gitRepo.StagedDiff()
+	if err != nil {
+		return "", fmt.Errorf("failed to get staged diff: %w", err)
+	}
+
+	changed := make(map[string]bool)
+	for _, file := range analyze.SplitFiles(worktree) {
+		changed[file.Path] = true
+	}
+	sections := []string{worktree}
+	for _, file := range analyze.SplitFiles(staged) {
+		if !changed[file.Path] {
+			sections = append(sections, file.Raw)
+		}
+	}
+	return strings.TrimSpace(strings.Join(sections, "\n")), nil
+}
+
+// truncateDiff cuts diff at the last line that fits in limit bytes
+func truncateDiff(diff string, limit int) string {
+	if len(diff) <= limit {
+		return diff
+	}
+	cut := strings.LastIndex(diff[:limit], "\n")
+	if cut < 0 {
+		cut = limit
+	}
+	return diff[:cut] + "\n[diff truncated]"
+}
+
+// parseSince parses a --since value relative to now: a duration such as
+// "24h" or "3d", a date or an RFC 3339 time
+func parseSince(value string, now time.Time) (time.Time, error) {
+	if match := daysPattern.FindStringSubmatch(value); match != nil {
+		days, err := strconv.Atoi(match[1])
+		if err == nil {
+			return now.AddDate(0, 0, -days), nil
+		}
+	}
+	if d, err := time.ParseDuration(value); err == nil {
+		return now.Add(-d), nil
+	}
+	if t, err := time.ParseInLocation(time.DateOnly, value, time.Local); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("invalid --since %q: use a duration such as 24h or 3d, a date (2006-01-02) or an RFC 3339 time", value)
+}
+
+func init() {
+	standupCmd.Flags().StringVar(&standupSince, "since", "24h", "start of the period: a duration such as 24h or 3d, a date or an RFC 3339 time")
+	standupCmd.Flags().StringVar(&standupAuthor, "author", "", "only include commits whose author contains this text")
+}
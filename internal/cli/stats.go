@@ -0,0 +1,98 @@
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"github.com/nseba/commit-ai/internal/config"
+	"github.com/nseba/commit-ai/internal/stats"
+)
+
+var (
+	statsOutput string
+	statsReset  bool
+)
+
+// statsCmd represents the stats command
+var statsCmd = &cobra.Command{
+	Use:   "stats",
+	Short: "Show how often generated messages are accepted, edited or rejected",
+	Long: `Show how often interactively reviewed messages (--edit, --commit) were
+accepted as generated, edited before use or rejected, per provider, model and
+prompt template.
+
+The outcomes are recorded locally in stats.jsonl next to the global config
+file and never leave the machine. Set CAI_NO_STATS to stop recording; nothing
+is recorded with --no-config-write or CAI_NO_AUTO_CONFIG either.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runStats()
+	},
+}
+
+// statsFile returns the path of the local stats file
+func statsFile() string {
+	return filepath.Join(filepath.Dir(cfgFile), "stats.jsonl")
+}
+
+// statsEnabled reports whether stats may be written next to the config file
+func statsEnabled() bool {
+	return !noConfigWrite && !config.AutoConfigDisabled()
+}
+
+// runStats prints the acceptance statistics or resets them
+func runStats() error {
+	if statsOutput != "text" && statsOutput != "json" {
+		return fmt.Errorf("invalid output format: %s. Supported formats: text, json", statsOutput)
+	}
+
+	if statsReset {
+		if err := os.Remove(statsFile()); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("failed to reset stats: %w", err)
+		}
+		fmt.Println("✓ Stats reset")
+		return nil
+	}
+
+	events, err := stats.Load(statsFile())
+	if err != nil {
+		return err
+	}
+	summaries := stats.Summarize(events)
+
+	if statsOutput == "json" {
+		if summaries == nil {
+			summaries = []stats.Summary{}
+		}
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(summaries); err != nil {
+			return fmt.Errorf("failed to encode stats: %w", err)
+		}
+		return nil
+	}
+
+	if len(summaries) == 0 {
+		fmt.Println("No stats recorded yet. Review messages with --edit or --commit to collect them.")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "PROVIDER\tMODEL\tTEMPLATE\tSESSIONS\tACCEPTED\tEDITED\tREJECTED\tACCEPT RATE")
+	for _, s := range summaries {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%d\t%d\t%d\t%.0f%%\n",
+			s.Provider, s.Model, s.Template, s.Total(), s.Accepted, s.Edited, s.Rejected, s.AcceptanceRate()*100)
+	}
+	return w.Flush()
+}
+
+func init() {
+	statsCmd.Flags().StringVarP(&statsOutput, "output", "o", "text", "output format: text or json")
+	statsCmd.Flags().BoolVar(&statsReset, "reset", false, "delete the recorded stats")
+}
@@ -0,0 +1,133 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/nseba/commit-ai/internal/diffsource"
+	"github.com/nseba/commit-ai/internal/generator"
+)
+
+// maxExampleLines bounds how much of an example value templates vars prints
+// as text
+const maxExampleLines = 6
+
+var templatesOutput string
+
+// templatesCmd groups commands for prompt template authors
+var templatesCmd = &cobra.Command{
+	Use:   "templates",
+	Short: "Help with writing prompt templates",
+}
+
+// templatesVarsCmd represents the templates vars command
+var templatesVarsCmd = &cobra.Command{
+	Use:   "vars",
+	Short: "List the variables and functions prompt templates can use",
+	Long: `List every variable and function available to prompt templates, with the
+value each variable would have for the current changes.
+
+The examples go through the same ignore patterns, compression, ticket lookup,
+branch templates and CAI_CONTEXT_CMD as a generated message, without calling
+the model. Long values are shortened; --output json prints them in full.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runTemplatesVars()
+	},
+}
+
+// templateReference is the JSON output of templates vars
+type templateReference struct {
+	Variables []generator.TemplateVariable `json:"variables"`
+	Functions []generator.TemplateFunction `json:"functions"`
+}
+
+// runTemplatesVars prints the template variables, with examples from the
+// current changes, and the template functions
+func runTemplatesVars() error {
+	if templatesOutput != "text" && templatesOutput != "json" {
+		return fmt.Errorf("invalid output format: %s. Supported formats: text, json", templatesOutput)
+	}
+
+	targetPath := "."
+	if path != "" {
+		targetPath = path
+	}
+
+	cfg, gitRepo, err := loadRepository(targetPath, false)
+	if err != nil {
+		return err
+	}
+
+	diff, err := readDiff(gitRepo, diffsource.Auto{Repo: gitRepo})
+	if err != nil {
+		return err
+	}
+	p, err := newPipeline(cfg, gitRepo, targetPath, diff)
+	if err != nil {
+		return err
+	}
+	if p == nil {
+		// Every change is ignored; show the values of an empty commit
+		diff = ""
+		if p, err = newPipeline(cfg, gitRepo, targetPath, diff); err != nil {
+			return err
+		}
+	}
+	if diff == "" {
+		fmt.Fprintln(os.Stderr, "No changes to describe; the examples are those of an empty commit.")
+	}
+
+	reference := templateReference{
+		Variables: p.gen.TemplateVariables(p.diff),
+		Functions: generator.TemplateFunctions(),
+	}
+	if templatesOutput == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		// Keep the <<<BEGIN UNTRUSTED DIFF>>> delimiters readable
+		encoder.SetEscapeHTML(false)
+		if err := encoder.Encode(reference); err != nil {
+			return fmt.Errorf("failed to encode template reference: %w", err)
+		}
+		return nil
+	}
+
+	fmt.Println("Variables:")
+	for _, variable := range reference.Variables {
+		fmt.Printf("\n  %s\n", variable.Name)
+		fmt.Printf("    %s\n", variable.Description)
+		fmt.Printf("    Example: %s\n", formatExample(variable.Example))
+	}
+
+	fmt.Println("\nFunctions:")
+	for _, fn := range reference.Functions {
+		fmt.Printf("\n  %s\n", fn.Usage)
+		fmt.Printf("    %s\n", fn.Description)
+	}
+	return nil
+}
+
+// formatExample shortens an example value to maxExampleLines, indenting the
+// lines after the first under the "Example:" label
+func formatExample(example string) string {
+	if strings.TrimSpace(example) == "" {
+		return "(empty)"
+	}
+
+	lines := strings.Split(strings.TrimRight(example, "\n"), "\n")
+	if len(lines) > maxExampleLines {
+		more := len(lines) - maxExampleLines
+		lines = append(lines[:maxExampleLines], fmt.Sprintf("[%d more lines]", more))
+	}
+	return strings.Join(lines, "\n             ")
+}
+
+func init() {
+	templatesVarsCmd.Flags().StringVarP(&templatesOutput, "output", "o", "text", "output format: text or json")
+	templatesCmd.AddCommand(templatesVarsCmd)
+}
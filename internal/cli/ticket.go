@@ -0,0 +1,120 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/nseba/commit-ai/internal/commitmsg"
+	"github.com/nseba/commit-ai/internal/config"
+	"github.com/nseba/commit-ai/internal/git"
+	"github.com/nseba/commit-ai/internal/keyring"
+	"github.com/nseba/commit-ai/internal/ticket"
+)
+
+// jiraKeyringAccount is the keyring account holding the Jira API token
+const jiraKeyringAccount = "jira"
+
+// resolveTicketContext looks up the ticket referenced by the current branch and
+// returns the prompt context for it along with the trailers to append to the
+// generated message. Failures are reported as warnings so that an unreachable
+// tracker never blocks generating a message.
+func resolveTicketContext(cfg *config.Config, gitRepo *git.Repository) (string, []commitmsg.Trailer) {
+	providerName := cfg.EffectiveTicketProvider()
+	if providerName == "" || providerName == "none" {
+		return "", nil
+	}
+
+	branch, err := gitRepo.CurrentBranch()
+	if err != nil || branch == "" {
+		return "", nil
+	}
+
+	provider, err := newTicketProvider(cfg, providerName, gitRepo)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: cannot use %s ticket provider: %v\n", providerName, err)
+		return "", nil
+	}
+
+	key := provider.ExtractKey(branch)
+	if key == "" {
+		return "", nil
+	}
+	trailers := []commitmsg.Trailer{{Key: cfg.TicketTrailer, Value: key}}
+
+	issue, err := provider.FetchIssue(key)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to fetch %s issue %s: %v\n", providerName, key, err)
+		return "", trailers
+	}
+
+	return issue.Context(), trailers
+}
+
+// newTicketProvider creates the ticket provider adapter selected by name
+func newTicketProvider(cfg *config.Config, name string, gitRepo *git.Repository) (ticket.Provider, error) {
+	timeout := time.Duration(cfg.TimeoutSeconds) * time.Second
+
+	switch name {
+	case ticket.ProviderJira:
+		return ticket.NewJiraClient(cfg.JiraURL, cfg.JiraEmail, jiraToken(cfg), timeout), nil
+	case ticket.ProviderGitHub:
+		remote, err := gitRepo.Remote("origin")
+		if err != nil {
+			return nil, err
+		}
+		return ticket.NewGitHubClient(cfg.GitHubAPIURL, remote.Path, githubToken(cfg, remote.Host), timeout), nil
+	case ticket.ProviderGitLab:
+		remote, err := gitRepo.Remote("origin")
+		if err != nil {
+			return nil, err
+		}
+		baseURL := cfg.GitLabURL
+		if baseURL == "" {
+			baseURL = "https://" + remote.Host
+		}
+		return ticket.NewGitLabClient(baseURL, remote.Path, cfg.GitLabToken, timeout), nil
+	case ticket.ProviderLinear:
+		return ticket.NewLinearClient("", cfg.LinearToken, timeout), nil
+	default:
+		return nil, fmt.Errorf("unsupported ticket provider: %s", name)
+	}
+}
+
+// jiraToken returns the configured Jira token, falling back to the keyring
+func jiraToken(cfg *config.Config) string {
+	if cfg.JiraToken != "" {
+		return cfg.JiraToken
+	}
+
+	token, err := keyring.Get(jiraKeyringAccount)
+	if err != nil && !errors.Is(err, keyring.ErrNotFound) && !errors.Is(err, keyring.ErrUnsupported) {
+		fmt.Fprintf(os.Stderr, "Warning: failed to read Jira token from keyring: %v\n", err)
+	}
+	return token
+}
+
+// githubToken returns the configured GitHub token, falling back to the
+// GH_TOKEN/GITHUB_TOKEN environment variables and then to the gh CLI login.
+func githubToken(cfg *config.Config, host string) string {
+	if cfg.GitHubToken != "" {
+		return cfg.GitHubToken
+	}
+	for _, env := range []string{"GH_TOKEN", "GITHUB_TOKEN"} {
+		if token := os.Getenv(env); token != "" {
+			return token
+		}
+	}
+
+	if _, err := exec.LookPath("gh"); err != nil {
+		return ""
+	}
+	out, err := exec.Command("gh", "auth", "token", "--hostname", host).Output() // #nosec G204 -- host comes from the repository remote
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
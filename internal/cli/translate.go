@@ -0,0 +1,140 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/nseba/commit-ai/internal/generator"
+)
+
+var (
+	translateTo       string
+	translateRewrite  bool
+	translateNotesRef string
+	translateDryRun   bool
+)
+
+// translateCmd represents the translate command
+var translateCmd = &cobra.Command{
+	Use:   "translate <rev-range>",
+	Short: "Translate existing commit messages into another language",
+	Long: `Translate the messages of existing commits into another language, for
+repositories migrating their commit message convention.
+
+The range is <from>..<to> or <from>, which means <from>..HEAD; merge commits are
+followed along their first parent. By default each translation is attached to
+its commit as a git note, leaving history untouched. With --rewrite the commits
+from <from> to HEAD are reworded instead, like an interactive rebase, which
+changes their hashes: only do this on branches nobody else has pulled.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runTranslate(args[0])
+	},
+}
+
+// runTranslate translates the commits of revRange and stores the result
+func runTranslate(revRange string) error {
+	if translateTo == "" {
+		return fmt.Errorf("--to is required")
+	}
+
+	from, to, ok := strings.Cut(revRange, "..")
+	if !ok || to == "" {
+		to = "HEAD"
+	}
+	if from == "" {
+		return fmt.Errorf("invalid range %q: expected <from>..<to> or <from>", revRange)
+	}
+	if translateRewrite && to != "HEAD" {
+		return fmt.Errorf("--rewrite only supports ranges ending at HEAD")
+	}
+
+	targetPath := "."
+	if path != "" {
+		targetPath = path
+	}
+
+	cfg, gitRepo, err := loadRepository(targetPath, true)
+	if err != nil {
+		return err
+	}
+	if cfg.ReadOnly && !translateDryRun {
+		return fmt.Errorf("translate is disabled in read-only mode (CAI_READ_ONLY); use --dry-run")
+	}
+	if translateRewrite && !translateDryRun {
+		if err := checkProtectedBranch(cfg, gitRepo); err != nil {
+			return err
+		}
+	}
+
+	commits, err := gitRepo.CommitRange(from, to)
+	if err != nil {
+		return err
+	}
+	if len(commits) == 0 {
+		fmt.Fprintln(os.Stderr, "No commits to translate")
+		return nil
+	}
+
+	gen, err := generator.New(cfg, cfgFile)
+	if err != nil {
+		return fmt.Errorf("failed to create generator: %w", err)
+	}
+
+	translations := make(map[string]string, len(commits))
+	for i, c := range commits {
+		fmt.Fprintf(os.Stderr, "Translating %d/%d %s...\n", i+1, len(commits), shortHash(c.Hash))
+		translated, err := gen.Translate(strings.TrimSpace(c.Message), translateTo)
+		if err != nil {
+			return err
+		}
+		translations[c.Hash] = translated + "\n"
+		fmt.Printf("%s %s\n    %s\n", shortHash(c.Hash), firstLine(c.Message), firstLine(translated))
+	}
+
+	if translateDryRun {
+		return nil
+	}
+
+	if translateRewrite {
+		newHead, err := gitRepo.RewordCommits(from, translations)
+		if err != nil {
+			return fmt.Errorf("failed to reword commits: %w", err)
+		}
+		fmt.Fprintf(os.Stderr, "✓ Reworded %d commits, HEAD is now %s\n", len(commits), shortHash(newHead))
+		return nil
+	}
+
+	for _, c := range commits {
+		if err := gitRepo.AddNote(translateNotesRef, c.Hash, translations[c.Hash]); err != nil {
+			return err
+		}
+	}
+	fmt.Fprintf(os.Stderr, "✓ Added %d translation notes\n", len(commits))
+	return nil
+}
+
+// shortHash abbreviates a commit hash for display
+func shortHash(hash string) string {
+	if len(hash) > 7 {
+		return hash[:7]
+	}
+	return hash
+}
+
+// firstLine returns the subject line of a commit message
+func firstLine(message string) string {
+	line, _, _ := strings.Cut(strings.TrimSpace(message), "\n")
+	return line
+}
+
+func init() {
+	translateCmd.Flags().StringVar(&translateTo, "to", "", "language to translate the messages into")
+	translateCmd.Flags().BoolVar(&translateRewrite, "rewrite", false, "reword the commits instead of adding git notes")
+	translateCmd.Flags().StringVar(&translateNotesRef, "notes-ref", "", "notes ref for the translations (default is git's notes ref)")
+	translateCmd.Flags().BoolVar(&translateDryRun, "dry-run", false, "print the translations without writing anything")
+	translateCmd.Flags().BoolVar(&forceCommit, "force", false, "allow --rewrite on protected branches")
+}
@@ -0,0 +1,129 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/nseba/commit-ai/internal/commitmsg"
+	"github.com/nseba/commit-ai/internal/policy"
+)
+
+const (
+	// scopeHistory is the number of recent commits whose scopes the scope
+	// picker suggests
+	scopeHistory = 100
+	// maxScopeSuggestions bounds the scopes suggested from the history
+	maxScopeSuggestions = 8
+)
+
+// pickType asks for another conventional commit type for message, keeping
+// its scope and subject. The types are those of the commit policy, or the
+// Conventional Commits types.
+func pickType(editor *InteractiveEditor, p *pipeline, message string) (string, error) {
+	msg := commitmsg.Parse(message)
+	types := policy.ConventionalTypes
+	if p.pol != nil && len(p.pol.Types) > 0 {
+		types = p.pol.Types
+	}
+
+	current := msg.Type
+	if current == "" {
+		current = "no type"
+	}
+	options := []string{"Keep " + current}
+	for _, typ := range types {
+		if typ != msg.Type {
+			options = append(options, typ)
+		}
+	}
+
+	choice, err := editor.PromptChoice("Commit type:", options, 0)
+	if errors.Is(err, errInputCancelled) {
+		return message, nil
+	}
+	if err != nil || choice == 0 {
+		return message, err
+	}
+	return commitmsg.SetTypeScope(message, options[choice], msg.Scope), nil
+}
+
+// pickScope asks for another scope for message, keeping its type and
+// subject. It suggests the scopes of the commit policy or, without policy
+// scopes, the ones used most in recent commits, and also accepts a new one.
+func pickScope(editor *InteractiveEditor, p *pipeline, message string) (string, error) {
+	msg := commitmsg.Parse(message)
+	if msg.Type == "" {
+		fmt.Fprintln(os.Stderr, "The message has no conventional commit type; choose a type first.")
+		return message, nil
+	}
+
+	restricted := p.pol != nil && len(p.pol.Scopes) > 0
+	scopes := recentScopes(p)
+	if restricted {
+		scopes = p.pol.Scopes
+	}
+
+	current := "no scope"
+	if msg.Scope != "" {
+		current = msg.Scope
+	}
+	options := []string{"Keep " + current}
+	if msg.Scope != "" {
+		options = append(options, "No scope")
+	}
+	for _, scope := range scopes {
+		if scope != msg.Scope {
+			options = append(options, scope)
+		}
+	}
+	if !restricted {
+		options = append(options, "Another scope...")
+	}
+
+	choice, err := editor.PromptChoice("Commit scope:", options, 0)
+	if errors.Is(err, errInputCancelled) {
+		return message, nil
+	}
+	if err != nil || choice == 0 {
+		return message, err
+	}
+
+	scope := options[choice]
+	switch {
+	case msg.Scope != "" && choice == 1:
+		scope = ""
+	case !restricted && choice == len(options)-1:
+		if scope, err = editor.PromptString("Scope"); err != nil {
+			return message, err
+		}
+	}
+	return commitmsg.SetTypeScope(message, msg.Type, scope), nil
+}
+
+// recentScopes returns the scopes of the last scopeHistory commits, most
+// used first
+func recentScopes(p *pipeline) []string {
+	commits, err := p.gitRepo.RecentCommits(scopeHistory)
+	if err != nil {
+		return nil
+	}
+
+	counts := make(map[string]int)
+	var scopes []string
+	for _, commit := range commits {
+		scope := commitmsg.Parse(commit.Message).Scope
+		if scope == "" {
+			continue
+		}
+		if counts[scope] == 0 {
+			scopes = append(scopes, scope)
+		}
+		counts[scope]++
+	}
+
+	// Ties keep the order of first use, newest first
+	sort.SliceStable(scopes, func(i, j int) bool { return counts[scopes[i]] > counts[scopes[j]] })
+	return scopes[:min(len(scopes), maxScopeSuggestions)]
+}
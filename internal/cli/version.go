@@ -0,0 +1,210 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"runtime"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// Build metadata, set by build flags:
+//
+//	-X github.com/nseba/commit-ai/internal/cli.version=v1.2.3
+//	-X github.com/nseba/commit-ai/internal/cli.commit=abc1234
+//	-X github.com/nseba/commit-ai/internal/cli.buildDate=2024-01-02T03:04:05Z
+var (
+	version   = "dev"
+	commit    = ""
+	buildDate = ""
+)
+
+// latestReleaseURL is the GitHub API endpoint for the latest release
+const latestReleaseURL = "https://api.github.com/repos/nseba/commit-ai/releases/latest"
+
+var (
+	versionOutput      string
+	versionCheckUpdate bool
+)
+
+// VersionInfo describes the running binary
+type VersionInfo struct {
+	Version         string `json:"version"`
+	Commit          string `json:"commit,omitempty"`
+	BuildDate       string `json:"build_date,omitempty"`
+	GoVersion       string `json:"go_version"`
+	Platform        string `json:"platform"`
+	LatestVersion   string `json:"latest_version,omitempty"`
+	UpdateAvailable *bool  `json:"update_available,omitempty"`
+}
+
+// versionCmd represents the version command
+var versionCmd = &cobra.Command{
+	Use:   "version",
+	Short: "Print the version number of commit-ai",
+	Long: `Print the version of commit-ai with its build metadata and exit.
+
+--check-update queries GitHub for the latest release and reports whether a newer
+version is available. --output json prints the information for tooling.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runVersion()
+	},
+}
+
+// runVersion prints the version information
+func runVersion() error {
+	if versionOutput != "text" && versionOutput != "json" {
+		return fmt.Errorf("invalid output format: %s. Supported formats: text, json", versionOutput)
+	}
+
+	info := buildVersionInfo()
+	if versionCheckUpdate {
+		latest, err := fetchLatestVersion()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to check for updates: %v\n", err)
+		} else {
+			available := isNewerVersion(info.Version, latest)
+			info.LatestVersion = latest
+			info.UpdateAvailable = &available
+		}
+	}
+
+	if versionOutput == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(info)
+	}
+
+	fmt.Printf("commit-ai version %s\n", info.Version)
+	if info.Commit != "" {
+		fmt.Printf("  commit:     %s\n", info.Commit)
+	}
+	if info.BuildDate != "" {
+		fmt.Printf("  built:      %s\n", info.BuildDate)
+	}
+	fmt.Printf("  go version: %s\n", info.GoVersion)
+	fmt.Printf("  platform:   %s\n", info.Platform)
+	if info.UpdateAvailable != nil {
+		if *info.UpdateAvailable {
+			fmt.Printf("\nA newer version is available: %s\n", info.LatestVersion)
+		} else {
+			fmt.Println("\nYou are running the latest version.")
+		}
+	}
+	return nil
+}
+
+// buildVersionInfo collects the build metadata, falling back to the VCS
+// information Go embeds when the build flags were not set
+func buildVersionInfo() *VersionInfo {
+	info := &VersionInfo{
+		Version:   version,
+		Commit:    commit,
+		BuildDate: buildDate,
+		GoVersion: runtime.Version(),
+		Platform:  runtime.GOOS + "/" + runtime.GOARCH,
+	}
+
+	if buildInfo, ok := debug.ReadBuildInfo(); ok {
+		// Local builds get a v0.0.0 pseudo-version, which is less useful than "dev"
+		mainVersion := buildInfo.Main.Version
+		if info.Version == "dev" && mainVersion != "" && mainVersion != "(devel)" && !strings.HasPrefix(mainVersion, "v0.0.0-") {
+			info.Version = mainVersion
+		}
+		for _, setting := range buildInfo.Settings {
+			switch {
+			case setting.Key == "vcs.revision" && info.Commit == "":
+				info.Commit = setting.Value
+			case setting.Key == "vcs.time" && info.BuildDate == "":
+				info.BuildDate = setting.Value
+			}
+		}
+	}
+
+	return info
+}
+
+// fetchLatestVersion returns the tag of the latest GitHub release
+func fetchLatestVersion() (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, latestReleaseURL, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch latest release: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return "", fmt.Errorf("GitHub API error (status %d): %s", resp.StatusCode, string(body))
+	}
+
+	var release struct {
+		TagName string `json:"tag_name"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&release); err != nil {
+		return "", fmt.Errorf("failed to decode GitHub response: %w", err)
+	}
+	return release.TagName, nil
+}
+
+// isNewerVersion reports whether latest is a higher semantic version than
+// current. Development builds are never considered outdated.
+func isNewerVersion(current, latest string) bool {
+	cur, ok := parseSemver(current)
+	if !ok {
+		return false
+	}
+	lat, ok := parseSemver(latest)
+	if !ok {
+		return false
+	}
+	for i := range cur {
+		if lat[i] != cur[i] {
+			return lat[i] > cur[i]
+		}
+	}
+	return false
+}
+
+// parseSemver parses the major.minor.patch part of a version such as v1.2.3-rc1
+func parseSemver(v string) ([3]int, bool) {
+	var parts [3]int
+	v = strings.TrimPrefix(strings.TrimSpace(v), "v")
+	v, _, _ = strings.Cut(v, "-")
+	v, _, _ = strings.Cut(v, "+")
+
+	fields := strings.Split(v, ".")
+	if len(fields) != 3 {
+		return parts, false
+	}
+	for i, field := range fields {
+		n, err := strconv.Atoi(field)
+		if err != nil {
+			return parts, false
+		}
+		parts[i] = n
+	}
+	return parts, true
+}
+
+func init() {
+	versionCmd.Flags().StringVarP(&versionOutput, "output", "o", "text", "output format: text or json")
+	versionCmd.Flags().BoolVar(&versionCheckUpdate, "check-update", false, "check GitHub for a newer release")
+}
@@ -0,0 +1,388 @@
+package commitmsg
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+var (
+	// headerPattern matches a conventional commit header: type(scope)!: subject
+	headerPattern = regexp.MustCompile(`^([a-zA-Z]+)(?:\(([^)]*)\))?(!)?: (.*)$`)
+	// trailerPattern matches a git trailer line such as "Refs: PROJ-123"
+	trailerPattern = regexp.MustCompile(`^([A-Za-z][A-Za-z0-9-]*|BREAKING CHANGE): (.+)$`)
+	// cherryPickPattern matches the line git cherry-pick -x appends
+	cherryPickPattern = regexp.MustCompile(`^\(cherry picked from commit ([0-9a-f]{7,64})\)$`)
+	// paragraphSeparator matches the blank lines between paragraphs
+	paragraphSeparator = regexp.MustCompile(`\n\s*\n`)
+)
+
+// scissorsLine is the line above the diff git appends for commit --verbose;
+// everything below it is dropped from the message
+const scissorsLine = "# ------------------------ >8 ------------------------"
+
+// Trailer is a single "Key: value" line from the trailer block of a message
+type Trailer struct {
+	Key   string
+	Value string
+}
+
+// Message is a commit message split into its conventional commit parts
+type Message struct {
+	Header   string
+	Type     string
+	Scope    string
+	Breaking bool
+	Subject  string
+	Body     string
+	Trailers []Trailer
+	// CherryPicks holds the hashes of the "(cherry picked from commit ...)"
+	// lines of the trailer block, which follow the trailers
+	CherryPicks []string
+}
+
+// Parse parses a raw commit message. Comment lines starting with '#' are
+// ignored, as git does when reading COMMIT_EDITMSG, and so is everything from
+// the scissors line of a commit --verbose buffer on. Messages that do not follow
+// the conventional commit format are returned with an empty Type and the whole
+// header as Subject.
+func Parse(raw string) *Message {
+	var lines []string
+	for _, line := range strings.Split(strings.ReplaceAll(raw, "\r\n", "\n"), "\n") {
+		if line == scissorsLine {
+			break
+		}
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		lines = append(lines, strings.TrimRight(line, " \t"))
+	}
+
+	text := strings.TrimSpace(strings.Join(lines, "\n"))
+	msg := &Message{}
+	if text == "" {
+		return msg
+	}
+
+	header, rest, _ := strings.Cut(text, "\n")
+	msg.Header = strings.TrimSpace(header)
+	msg.Subject = msg.Header
+
+	if m := headerPattern.FindStringSubmatch(msg.Header); m != nil {
+		msg.Type = strings.ToLower(m[1])
+		msg.Scope = m[2]
+		msg.Breaking = m[3] == "!"
+		msg.Subject = strings.TrimSpace(m[4])
+	}
+
+	paragraphs := splitParagraphs(strings.TrimSpace(rest))
+	if len(paragraphs) > 0 {
+		if trailers, cherryPicks, ok := parseTrailers(paragraphs[len(paragraphs)-1]); ok {
+			msg.Trailers, msg.CherryPicks = trailers, cherryPicks
+			paragraphs = paragraphs[:len(paragraphs)-1]
+		}
+	}
+	msg.Body = strings.Join(paragraphs, "\n\n")
+
+	for _, trailer := range msg.Trailers {
+		if trailer.Key == "BREAKING CHANGE" || trailer.Key == "BREAKING-CHANGE" {
+			msg.Breaking = true
+		}
+	}
+
+	return msg
+}
+
+// Trailer returns the value of the first trailer with the given key,
+// compared case-insensitively.
+func (m *Message) Trailer(key string) (string, bool) {
+	for _, trailer := range m.Trailers {
+		if strings.EqualFold(trailer.Key, key) {
+			return trailer.Value, true
+		}
+	}
+	return "", false
+}
+
+// AddTrailer returns raw with a "key: value" trailer appended, unless the
+// message already carries that exact trailer.
+func AddTrailer(raw, key, value string) string {
+	msg := Parse(raw)
+	for _, trailer := range msg.Trailers {
+		if strings.EqualFold(trailer.Key, key) && trailer.Value == value {
+			return raw
+		}
+	}
+
+	msg.Trailers = append(msg.Trailers, Trailer{Key: key, Value: value})
+	return msg.String()
+}
+
+// AddCherryPick returns raw with the "(cherry picked from commit ...)" line
+// git cherry-pick -x writes for hash, unless the message already has it
+func AddCherryPick(raw, hash string) string {
+	msg := Parse(raw)
+	for _, h := range msg.CherryPicks {
+		if h == hash {
+			return raw
+		}
+	}
+
+	msg.CherryPicks = append(msg.CherryPicks, hash)
+	return msg.String()
+}
+
+// MarkBreaking returns raw marked as a breaking change: a conventional header
+// gets "!" before the colon, and a "BREAKING CHANGE" trailer with description
+// is appended unless the message already has one.
+func MarkBreaking(raw, description string) string {
+	msg := Parse(raw)
+	if msg.Header == "" {
+		return raw
+	}
+
+	if m := headerPattern.FindStringSubmatch(msg.Header); m != nil && m[3] == "" {
+		prefix := m[1]
+		if m[2] != "" {
+			prefix += "(" + m[2] + ")"
+		}
+		msg.Header = prefix + "!: " + m[4]
+	}
+
+	hasFooter := false
+	for _, trailer := range msg.Trailers {
+		if trailer.Key == "BREAKING CHANGE" || trailer.Key == "BREAKING-CHANGE" {
+			hasFooter = true
+		}
+	}
+	if !hasFooter {
+		msg.Trailers = append(msg.Trailers, Trailer{Key: "BREAKING CHANGE", Value: description})
+	}
+
+	return msg.String()
+}
+
+// SetTypeScope returns raw with the type and scope of its header replaced,
+// keeping the subject and a "!" breaking marker. An empty scope removes the
+// scope; a header that isn't conventional becomes the subject.
+func SetTypeScope(raw, typ, scope string) string {
+	msg := Parse(raw)
+	if msg.Header == "" {
+		return raw
+	}
+
+	header := typ
+	if scope != "" {
+		header += "(" + scope + ")"
+	}
+	if m := headerPattern.FindStringSubmatch(msg.Header); m != nil && m[3] == "!" {
+		header += "!"
+	}
+	msg.Header = header + ": " + msg.Subject
+	return msg.String()
+}
+
+// HeaderRules selects the consistency rules NormalizeHeader applies, the
+// ones commit linters such as commitlint check most often
+type HeaderRules struct {
+	// LowercaseAfterType lowercases the first word of a conventional
+	// subject: "feat: Add login" becomes "feat: add login"
+	LowercaseAfterType bool
+	// CapitalizeFirstWord capitalizes the first word of the subject, after
+	// the type of a conventional header
+	CapitalizeFirstWord bool
+	// StripTrailingPeriod removes the periods ending the header
+	StripTrailingPeriod bool
+}
+
+// NormalizeHeader returns raw with rules applied to its first line, leaving
+// the body and trailers as they are. Only plain words change case: words
+// such as README, iOS or Config.Load name something and are kept.
+func NormalizeHeader(raw string, rules HeaderRules) string {
+	header, rest, hasRest := strings.Cut(raw, "\n")
+
+	prefix, subject := "", header
+	if m := headerPattern.FindStringSubmatchIndex(header); m != nil {
+		prefix, subject = header[:m[8]], header[m[8]:]
+		if rules.LowercaseAfterType {
+			subject = setFirstWordCase(subject, unicode.IsUpper, unicode.ToLower)
+		}
+	}
+	if rules.CapitalizeFirstWord {
+		subject = setFirstWordCase(subject, unicode.IsLower, unicode.ToUpper)
+	}
+	if rules.StripTrailingPeriod {
+		if stripped := strings.TrimRight(strings.TrimRight(subject, " \t."), " \t"); letterOrDigit(stripped) {
+			subject = stripped
+		}
+	}
+
+	if !hasRest {
+		return prefix + subject
+	}
+	return prefix + subject + "\n" + rest
+}
+
+// setFirstWordCase maps the first letter of s with to when it satisfies is
+// and the first word is a plain lowercase or capitalized word
+func setFirstWordCase(s string, is func(rune) bool, to func(rune) rune) string {
+	first, size := utf8.DecodeRuneInString(s)
+	if !is(first) {
+		return s
+	}
+	word, _, _ := strings.Cut(s, " ")
+	for _, r := range strings.TrimRight(word[size:], ",:;") {
+		if !unicode.IsLetter(r) || unicode.IsUpper(r) {
+			return s
+		}
+	}
+	return string(to(first)) + s[size:]
+}
+
+// letterOrDigit reports whether s has a letter or digit
+func letterOrDigit(s string) bool {
+	return strings.IndexFunc(s, func(r rune) bool { return unicode.IsLetter(r) || unicode.IsDigit(r) }) >= 0
+}
+
+// Template is the structure of a commit.template file
+type Template struct {
+	// Text holds the lines the message starts with, such as section labels
+	// and placeholders, without surrounding blank lines
+	Text string
+	// Guidance holds the text of the comment lines, such as "Explain why
+	// the change is needed", leaving out blank and divider comments
+	Guidance []string
+}
+
+// ParseTemplate splits the content of a commit.template file into the text
+// git puts into the message and the guidance of its '#' comment lines
+func ParseTemplate(content string) Template {
+	var tmpl Template
+	var text []string
+	for _, line := range strings.Split(strings.ReplaceAll(content, "\r\n", "\n"), "\n") {
+		line = strings.TrimRight(line, " \t")
+		if !strings.HasPrefix(line, "#") {
+			text = append(text, line)
+			continue
+		}
+		if comment := strings.TrimSpace(strings.TrimLeft(line, "#")); letterOrDigit(comment) {
+			tmpl.Guidance = append(tmpl.Guidance, comment)
+		}
+	}
+	tmpl.Text = strings.Trim(strings.Join(text, "\n"), "\n")
+	return tmpl
+}
+
+// ReplaceText returns a COMMIT_EDITMSG-style buffer with its text, such as
+// the lines git copied from commit.template, replaced by message. The '#'
+// comments and the verbose diff below the scissors line are kept.
+func ReplaceText(buffer, message string) string {
+	lines := strings.Split(buffer, "\n")
+	var kept []string
+	for i, line := range lines {
+		if line == scissorsLine {
+			kept = append(kept, lines[i:]...)
+			break
+		}
+		if strings.HasPrefix(line, "#") {
+			kept = append(kept, line)
+		}
+	}
+	rest := strings.Join(kept, "\n")
+	if rest != "" && strings.HasSuffix(buffer, "\n") && !strings.HasSuffix(rest, "\n") {
+		rest += "\n"
+	}
+	return InsertAboveComments(rest, message)
+}
+
+// HasText reports whether a COMMIT_EDITMSG-style buffer holds text besides
+// git's '#' comments and the verbose diff below the scissors line, such as a
+// message the author already wrote
+func HasText(buffer string) bool {
+	for _, line := range strings.Split(buffer, "\n") {
+		line = strings.TrimRight(line, "\r")
+		if line == scissorsLine {
+			return false
+		}
+		if !strings.HasPrefix(line, "#") && strings.TrimSpace(line) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// InsertAboveComments returns a COMMIT_EDITMSG-style buffer with message
+// placed at the top, replacing any blank lines there, and the rest of the
+// buffer (git's '#' comments and the verbose diff) kept below it. Callers
+// check HasText first, since a message already in the buffer would end up
+// below the new one.
+func InsertAboveComments(buffer, message string) string {
+	rest := strings.TrimLeft(buffer, "\r\n \t")
+	message = strings.TrimRight(message, "\n")
+	if rest == "" {
+		return message + "\n"
+	}
+	return message + "\n\n" + rest
+}
+
+// String reassembles the message from its parts
+func (m *Message) String() string {
+	var b strings.Builder
+	b.WriteString(m.Header)
+
+	if m.Body != "" {
+		b.WriteString("\n\n")
+		b.WriteString(m.Body)
+	}
+
+	lines := make([]string, 0, len(m.Trailers)+len(m.CherryPicks))
+	for _, trailer := range m.Trailers {
+		lines = append(lines, trailer.Key+": "+trailer.Value)
+	}
+	for _, hash := range m.CherryPicks {
+		lines = append(lines, "(cherry picked from commit "+hash+")")
+	}
+	if len(lines) > 0 {
+		b.WriteString("\n\n")
+		b.WriteString(strings.Join(lines, "\n"))
+	}
+
+	return b.String()
+}
+
+// splitParagraphs splits text on blank lines
+func splitParagraphs(text string) []string {
+	if text == "" {
+		return nil
+	}
+
+	var paragraphs []string
+	for _, paragraph := range paragraphSeparator.Split(text, -1) {
+		if paragraph = strings.TrimSpace(paragraph); paragraph != "" {
+			paragraphs = append(paragraphs, paragraph)
+		}
+	}
+	return paragraphs
+}
+
+// parseTrailers parses a paragraph as a trailer block, which like in git may
+// contain "(cherry picked from commit ...)" lines. It reports false if any
+// line in the paragraph is neither.
+func parseTrailers(paragraph string) ([]Trailer, []string, bool) {
+	var trailers []Trailer
+	var cherryPicks []string
+	for _, line := range strings.Split(paragraph, "\n") {
+		line = strings.TrimSpace(line)
+		if m := cherryPickPattern.FindStringSubmatch(line); m != nil {
+			cherryPicks = append(cherryPicks, m[1])
+			continue
+		}
+		m := trailerPattern.FindStringSubmatch(line)
+		if m == nil {
+			return nil, nil, false
+		}
+		trailers = append(trailers, Trailer{Key: m[1], Value: m[2]})
+	}
+	return trailers, cherryPicks, true
+}
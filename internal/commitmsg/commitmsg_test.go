@@ -0,0 +1,197 @@
+package commitmsg
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse_Conventional(t *testing.T) {
+	raw := `feat(config)!: add include directive
+
+Allow config files to include shared settings.
+
+Refs: PROJ-123
+Signed-off-by: Jane Doe <jane@example.com>`
+
+	msg := Parse(raw)
+
+	assert.Equal(t, "feat(config)!: add include directive", msg.Header)
+	assert.Equal(t, "feat", msg.Type)
+	assert.Equal(t, "config", msg.Scope)
+	assert.True(t, msg.Breaking)
+	assert.Equal(t, "add include directive", msg.Subject)
+	assert.Equal(t, "Allow config files to include shared settings.", msg.Body)
+	assert.Len(t, msg.Trailers, 2)
+
+	value, ok := msg.Trailer("refs")
+	assert.True(t, ok)
+	assert.Equal(t, "PROJ-123", value)
+}
+
+func TestParse_NonConventional(t *testing.T) {
+	msg := Parse("Update README")
+
+	assert.Equal(t, "", msg.Type)
+	assert.Equal(t, "Update README", msg.Subject)
+	assert.Empty(t, msg.Body)
+	assert.Empty(t, msg.Trailers)
+}
+
+func TestParse_IgnoresComments(t *testing.T) {
+	raw := "fix: handle empty diff\n\n# Please enter the commit message\n# Lines starting with '#' will be ignored"
+
+	msg := Parse(raw)
+
+	assert.Equal(t, "fix", msg.Type)
+	assert.Empty(t, msg.Body)
+}
+
+func TestParse_StopsAtScissors(t *testing.T) {
+	raw := "fix: handle empty diff\n\nRefs: #42\n\n" +
+		"# Please enter the commit message\n" +
+		scissorsLine + "\n" +
+		"# Do not modify or remove the line above.\n" +
+		"diff --git a/main.go b/main.go\n" +
+		"@@ -1 +1 @@\n" +
+		"-Old: value\n" +
+		"+New: value\n"
+
+	msg := Parse(raw)
+
+	assert.Equal(t, "fix", msg.Type)
+	assert.Empty(t, msg.Body)
+	assert.Equal(t, []Trailer{{Key: "Refs", Value: "#42"}}, msg.Trailers)
+}
+
+func TestParse_BreakingChangeTrailer(t *testing.T) {
+	msg := Parse("feat: drop legacy keys\n\nBREAKING CHANGE: CAI_URL is no longer read")
+
+	assert.True(t, msg.Breaking)
+}
+
+func TestParse_BodyIsNotTrailer(t *testing.T) {
+	msg := Parse("fix: handle errors\n\nThis explains the change: in detail.")
+
+	assert.Empty(t, msg.Trailers)
+	assert.Equal(t, "This explains the change: in detail.", msg.Body)
+}
+
+func TestMessage_String(t *testing.T) {
+	raw := "feat(cli): add lint command\n\nValidate messages in CI.\n\nRefs: #42"
+
+	assert.Equal(t, raw, Parse(raw).String())
+}
+
+func TestAddTrailer(t *testing.T) {
+	assert.Equal(t, "feat: add login\n\nRefs: PROJ-1", AddTrailer("feat: add login", "Refs", "PROJ-1"))
+	assert.Equal(t, "feat: add login\n\nBody.\n\nSigned-off-by: A <a@b.c>\nRefs: PROJ-1",
+		AddTrailer("feat: add login\n\nBody.\n\nSigned-off-by: A <a@b.c>", "Refs", "PROJ-1"))
+
+	existing := "feat: add login\n\nRefs: PROJ-1"
+	assert.Equal(t, existing, AddTrailer(existing, "Refs", "PROJ-1"))
+}
+
+func TestAddCherryPick(t *testing.T) {
+	assert.Equal(t, "fix: handle timeouts\n\n(cherry picked from commit 3f2c1a9)", AddCherryPick("fix: handle timeouts", "3f2c1a9"))
+
+	// The line joins the trailer block, and trailers added later go before it
+	raw := AddCherryPick("fix: handle timeouts\n\nRefs: PROJ-1", "3f2c1a9")
+	assert.Equal(t, "fix: handle timeouts\n\nRefs: PROJ-1\n(cherry picked from commit 3f2c1a9)", raw)
+	assert.Equal(t, []string{"3f2c1a9"}, Parse(raw).CherryPicks)
+	assert.Equal(t, "fix: handle timeouts\n\nRefs: PROJ-1\nSigned-off-by: A <a@b.c>\n(cherry picked from commit 3f2c1a9)",
+		AddTrailer(raw, "Signed-off-by", "A <a@b.c>"))
+
+	// Earlier cherry-pick lines of a chained backport are kept
+	raw = AddCherryPick(raw, "5d0e4b2")
+	assert.Equal(t, []string{"3f2c1a9", "5d0e4b2"}, Parse(raw).CherryPicks)
+	assert.Equal(t, raw, AddCherryPick(raw, "5d0e4b2"))
+}
+
+func TestMarkBreaking(t *testing.T) {
+	result := MarkBreaking("feat(api): drop v1 endpoints\n\nRemove the legacy handlers.", "the v1 API is gone")
+	assert.Equal(t, "feat(api)!: drop v1 endpoints\n\nRemove the legacy handlers.\n\nBREAKING CHANGE: the v1 API is gone", result)
+
+	// Already marked messages are left alone
+	marked := "feat!: drop v1\n\nBREAKING CHANGE: gone"
+	assert.Equal(t, marked, MarkBreaking(marked, "other"))
+
+	// Non-conventional headers only get the footer
+	result = MarkBreaking("Drop v1", "gone")
+	assert.Equal(t, "Drop v1\n\nBREAKING CHANGE: gone", result)
+}
+
+func TestSetTypeScope(t *testing.T) {
+	message := "feat(api)!: drop v1 endpoints\n\nRemove the legacy handlers.\n\nRefs: PROJ-1"
+	assert.Equal(t, "fix(api)!: drop v1 endpoints\n\nRemove the legacy handlers.\n\nRefs: PROJ-1", SetTypeScope(message, "fix", "api"))
+	assert.Equal(t, "feat(server)!: drop v1 endpoints\n\nRemove the legacy handlers.\n\nRefs: PROJ-1", SetTypeScope(message, "feat", "server"))
+	assert.Equal(t, "feat!: drop v1 endpoints\n\nRemove the legacy handlers.\n\nRefs: PROJ-1", SetTypeScope(message, "feat", ""))
+
+	// Non-conventional headers become the subject
+	assert.Equal(t, "docs: Update README", SetTypeScope("Update README", "docs", ""))
+	assert.Equal(t, "", SetTypeScope("", "docs", ""))
+}
+
+func TestNormalizeHeader(t *testing.T) {
+	lower := HeaderRules{LowercaseAfterType: true, StripTrailingPeriod: true}
+	assert.Equal(t, "feat(auth): add login\n\nBody ends with a period.", NormalizeHeader("feat(auth): Add login.\n\nBody ends with a period.", lower))
+	assert.Equal(t, "fix!: handle Ü", NormalizeHeader("fix!: Handle Ü", lower))
+	assert.Equal(t, "Add login", NormalizeHeader("Add login.", lower), "only conventional subjects are lowercased")
+	assert.Equal(t, "feat: ...", NormalizeHeader("feat: ...", lower), "a subject of periods is kept")
+
+	// Words that name something keep their case
+	for _, header := range []string{"docs: README updates", "fix: HTTPClient retries", "refactor: Config.Load errors", "feat: API v2"} {
+		assert.Equal(t, header, NormalizeHeader(header, lower))
+	}
+
+	capitalize := HeaderRules{CapitalizeFirstWord: true}
+	assert.Equal(t, "feat: Add login.", NormalizeHeader("feat: add login.", capitalize))
+	assert.Equal(t, "Update deps", NormalizeHeader("update deps", capitalize))
+	assert.Equal(t, "fix: iOS layout", NormalizeHeader("fix: iOS layout", capitalize))
+	assert.Equal(t, "fix: go.mod tidy", NormalizeHeader("fix: go.mod tidy", capitalize))
+
+	assert.Equal(t, "", NormalizeHeader("", lower))
+}
+
+func TestParseTemplate(t *testing.T) {
+	content := "\n[TICKET] Subject\n\nWhy:\n\nHow:\n\n# Subject: 50 characters or less\r\n#\n# --------\n## Explain why, not how\n"
+
+	tmpl := ParseTemplate(content)
+	assert.Equal(t, "[TICKET] Subject\n\nWhy:\n\nHow:", tmpl.Text)
+	assert.Equal(t, []string{"Subject: 50 characters or less", "Explain why, not how"}, tmpl.Guidance)
+
+	// Templates often hold only comments
+	tmpl = ParseTemplate("# Use the imperative mood\n")
+	assert.Empty(t, tmpl.Text)
+	assert.Equal(t, []string{"Use the imperative mood"}, tmpl.Guidance)
+}
+
+func TestReplaceText(t *testing.T) {
+	buffer := "Why:\n\nHow:\n\n# Explain why\n# Please enter the commit message for your changes.\n" +
+		"# ------------------------ >8 ------------------------\ndiff --git a/a.go b/a.go\n+# not a comment\n"
+
+	result := ReplaceText(buffer, "feat: add a\n\nWhy: users asked\n")
+	assert.Equal(t, "feat: add a\n\nWhy: users asked\n\n# Explain why\n# Please enter the commit message for your changes.\n"+
+		"# ------------------------ >8 ------------------------\ndiff --git a/a.go b/a.go\n+# not a comment\n", result)
+
+	assert.Equal(t, "feat: add a\n", ReplaceText("Why:\n", "feat: add a"))
+}
+
+func TestHasText(t *testing.T) {
+	verbose := "# Please enter the commit message for your changes.\n# ------------------------ >8 ------------------------\ndiff --git a/a.go b/a.go\n+added\n"
+
+	assert.False(t, HasText(""))
+	assert.False(t, HasText("\n\n"+verbose), "the verbose diff isn't text of the message")
+	assert.False(t, HasText(" \r\n\t\n# Please enter the commit message for your changes.\n"))
+	assert.True(t, HasText("fix: handle the empty case\n\n"+verbose))
+	assert.True(t, HasText("\nWIP\r\n# comment\n"))
+}
+
+func TestInsertAboveComments(t *testing.T) {
+	buffer := "\n# Please enter the commit message for your changes.\n# ------------------------ >8 ------------------------\ndiff --git a/a.go b/a.go\n"
+
+	result := InsertAboveComments(buffer, "feat: add a\n\nBody.\n")
+	assert.Equal(t, "feat: add a\n\nBody.\n\n# Please enter the commit message for your changes.\n# ------------------------ >8 ------------------------\ndiff --git a/a.go b/a.go\n", result)
+
+	assert.Equal(t, "feat: add a\n", InsertAboveComments("", "feat: add a"))
+}
@@ -2,8 +2,14 @@ package config
 
 import (
 	"fmt"
+	"maps"
+	"net"
+	"net/url"
 	"os"
+	"path"
 	"path/filepath"
+	"regexp"
+	"slices"
 	"strconv"
 	"strings"
 
@@ -13,29 +19,424 @@ import (
 const (
 	providerOllama = "ollama"
 	providerOpenAI = "openai"
+	providerLocal  = "local"
+	providerVertex = "vertex"
+	providerReplay = "replay"
+)
+
+// validProviders are the provider API types; presets resolve to one of them
+var validProviders = map[string]bool{
+	providerOllama: true,
+	providerOpenAI: true,
+	providerLocal:  true,
+	providerVertex: true,
+	providerReplay: true,
+}
+
+// Protected branch modes
+const (
+	ProtectedBranchRefuse = "refuse"
+	ProtectedBranchWarn   = "warn"
+	ProtectedBranchOff    = "off"
+)
+
+// Oversized commit modes
+const (
+	OversizedCommitWarn   = "warn"
+	OversizedCommitRefuse = "refuse"
+	OversizedCommitOff    = "off"
+)
+
+// Auth schemes for CAI_AUTH_SCHEME; the header and query schemes are
+// written "header:<name>" and "query:<name>"
+const (
+	AuthBearer = "bearer"
+	AuthBasic  = "basic"
+	AuthHeader = "header"
+	AuthQuery  = "query"
+)
+
+// DefaultPromptTemplate is the file name of the built-in prompt template
+const DefaultPromptTemplate = "default.txt"
+
+// authorPattern matches a "Name <email>" commit author
+var authorPattern = regexp.MustCompile(`^([^<>]*[^<>\s])\s*<([^<>\s]+)>$`)
+
+// envName matches the environment variable names CAI_TEMPLATE_ENV can list,
+// which templates reference as {{.Env.NAME}}
+var envName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// secretEnvName matches environment variable names that look like they hold
+// a secret, whose values must not end up in prompts
+var secretEnvName = regexp.MustCompile(`(?i)TOKEN|SECRET|KEY|PASSWORD|PASSWD|CREDENTIAL`)
+
+// Prompt guard modes for diff lines that look like instructions to the model
+const (
+	PromptGuardOff   = "off"
+	PromptGuardWarn  = "warn"
+	PromptGuardStrip = "strip"
+)
+
+// Output contract modes
+const (
+	OutputContractStrict = "strict"
+	OutputContractOff    = "off"
+)
+
+// Built-in prompt variants, by change size
+const (
+	PromptVariantAuto   = "auto"
+	PromptVariantTiny   = "tiny"
+	PromptVariantMedium = "medium"
+	PromptVariantLarge  = "large"
+)
+
+// Message normalization rules
+const (
+	NormalizeLowercaseAfterType  = "lowercase-after-type"
+	NormalizeCapitalizeFirstWord = "capitalize-first-word"
+	NormalizeStripTrailingPeriod = "strip-trailing-period"
+)
+
+// AI attribution trailer styles
+const (
+	AttributionOff      = "off"
+	AttributionCoAuthor = "co-author"
+	AttributionAssisted = "assisted"
 )
 
 // Config holds the application configuration
 type Config struct {
-	APIURL         string `toml:"CAI_API_URL"`
-	Model          string `toml:"CAI_MODEL"`
-	Provider       string `toml:"CAI_PROVIDER"`
-	APIToken       string `toml:"CAI_API_TOKEN"`
-	Language       string `toml:"CAI_LANGUAGE"`
-	PromptTemplate string `toml:"CAI_PROMPT_TEMPLATE"`
-	TimeoutSeconds int    `toml:"CAI_TIMEOUT_SECONDS"`
+	APIURL         string `toml:"CAI_API_URL" desc:"API URL for the AI provider"`
+	Model          string `toml:"CAI_MODEL" desc:"Model name to use"`
+	Provider       string `toml:"CAI_PROVIDER" desc:"AI provider (ollama, openai, local, vertex, replay) or preset (deepseek, qwen)"`
+	APIToken       string `toml:"CAI_API_TOKEN" secret:"true" desc:"API token (required for OpenAI, falls back to the OS keyring)"`
+	Language       string `toml:"CAI_LANGUAGE" desc:"Language for commit messages"`
+	PromptTemplate string `toml:"CAI_PROMPT_TEMPLATE" desc:"Prompt template file name or path"`
+	TimeoutSeconds int    `toml:"CAI_TIMEOUT_SECONDS" desc:"Timeout for AI requests (seconds)"`
+	// AuthScheme is how the API token is sent: "bearer" (the default),
+	// "basic" with a "user:password" token, "header:<name>" or "query:<name>"
+	AuthScheme     string `toml:"CAI_AUTH_SCHEME" desc:"How the API token is sent: bearer, basic, header:<name> or query:<name>"`
+	QuickMode      bool   `toml:"CAI_QUICK_MODE" desc:"Single-key interactive flow for -e/-c"`
+	ReadOnly       bool   `toml:"CAI_READ_ONLY" desc:"Disable -c, -a and all repository writes"`
+	IndexOnly      bool   `toml:"CAI_INDEX_ONLY" desc:"Build diffs from the index and HEAD without reading the work tree"`
+	JiraURL        string `toml:"CAI_JIRA_URL" desc:"Jira base URL; enables ticket context from the branch name; not read from project files"`
+	JiraEmail      string `toml:"CAI_JIRA_EMAIL" desc:"Jira Cloud account email (Basic auth)"`
+	JiraToken      string `toml:"CAI_JIRA_TOKEN" secret:"true" desc:"Jira API token (falls back to the OS keyring)"`
+	GitHubIssues   bool   `toml:"CAI_GITHUB_ISSUES" desc:"Fetch GitHub issue context from the branch name"`
+	GitHubToken    string `toml:"CAI_GITHUB_TOKEN" secret:"true" desc:"GitHub token (falls back to GH_TOKEN, GITHUB_TOKEN, gh auth token)"`
+	GitHubAPIURL   string `toml:"CAI_GITHUB_API_URL" desc:"GitHub API URL (GitHub Enterprise); not read from project files"`
+	GitLabURL      string `toml:"CAI_GITLAB_URL" desc:"GitLab instance URL; not read from project files"`
+	GitLabToken    string `toml:"CAI_GITLAB_TOKEN" secret:"true" desc:"GitLab access token"`
+	LinearToken    string `toml:"CAI_LINEAR_TOKEN" secret:"true" desc:"Linear personal API key"`
+	AzureToken     string `toml:"CAI_AZURE_DEVOPS_TOKEN" secret:"true" desc:"Azure DevOps personal access token for pr --create"`
+	TicketProvider string `toml:"CAI_TICKET_PROVIDER" desc:"Ticket tracker (jira, github, gitlab, linear, none)"`
+	TicketTrailer  string `toml:"CAI_TICKET_TRAILER" desc:"Trailer key used to reference the ticket"`
+
+	// ModelPath is the GGUF model file run in-process by the "local"
+	// provider, available in builds with the local tag
+	ModelPath string `toml:"CAI_MODEL_PATH" desc:"GGUF model file for the local provider"`
+	// ReplayFixtures is the file the "replay" provider answers from and
+	// CAI_RECORD records to
+	ReplayFixtures string `toml:"CAI_REPLAY_FIXTURES" desc:"JSON fixture file the replay provider answers from and --record writes to"`
+	// Record saves every provider response to ReplayFixtures. Project
+	// .commitai files can't enable it, as it writes to a file they name.
+	Record bool `toml:"CAI_RECORD" desc:"Record provider responses to CAI_REPLAY_FIXTURES; not read from project files"`
+
+	// VertexProject and VertexLocation select the Google Cloud project and
+	// region of the "vertex" provider; VertexCredentials is a service account
+	// key or credentials file used instead of Application Default Credentials
+	VertexProject     string `toml:"CAI_VERTEX_PROJECT" desc:"Google Cloud project for the vertex provider"`
+	VertexLocation    string `toml:"CAI_VERTEX_LOCATION" desc:"Vertex AI region, or global"`
+	VertexCredentials string `toml:"CAI_VERTEX_CREDENTIALS" desc:"Service account key or credentials file for the vertex provider"`
+
+	// AllowedProviders restricts the providers a repository may send its
+	// diffs to, e.g. ["ollama"] for code that must not leave the machine.
+	// Nested project files can only narrow the list, and no environment
+	// variable lifts it.
+	AllowedProviders []string `toml:"CAI_ALLOWED_PROVIDERS" env:"-" desc:"Providers the repository may use; others fail with an error"`
+
+	// Proxy sends provider requests through an http(s):// or socks5:// proxy
+	// instead of the one from HTTP_PROXY/HTTPS_PROXY; SSHJumpHost tunnels
+	// them through "ssh -W" via the given ssh destination instead
+	Proxy       string `toml:"CAI_PROXY" desc:"HTTP(S) or SOCKS5 proxy for provider requests"`
+	SSHJumpHost string `toml:"CAI_SSH_JUMP_HOST" desc:"SSH destination to tunnel provider requests through"`
+
+	// ProtectedBranches lists branch patterns (path.Match syntax) that
+	// --commit must not commit to directly; ProtectedBranchMode is one of
+	// "refuse", "warn" or "off"
+	ProtectedBranches   []string `toml:"CAI_PROTECTED_BRANCHES" desc:"Branch patterns --commit won't commit to directly (comma-separated in env)"`
+	ProtectedBranchMode string   `toml:"CAI_PROTECTED_BRANCH_MODE" desc:"refuse, warn or off for protected branches"`
+
+	// MaxCommitFiles and MaxCommitLines are the number of files and changed
+	// lines above which a change counts as oversized; zero disables either.
+	// OversizedCommitMode is one of "warn", "refuse" (refuse --commit) or "off"
+	MaxCommitFiles      int    `toml:"CAI_MAX_COMMIT_FILES" desc:"Number of changed files above which a change is oversized; 0 disables it"`
+	MaxCommitLines      int    `toml:"CAI_MAX_COMMIT_LINES" desc:"Number of added and removed lines above which a change is oversized; 0 disables it"`
+	OversizedCommitMode string `toml:"CAI_OVERSIZED_COMMIT_MODE" desc:"warn, refuse (block --commit without --allow-oversized) or off for oversized changes"`
+
+	// CompressDiff trims context lines beyond DiffContextLines and applies
+	// DiffRewrites before the diff is sent to the model
+	CompressDiff     bool          `toml:"CAI_COMPRESS_DIFF" desc:"Trim context and normalize noise before sending the diff"`
+	DiffContextLines int           `toml:"CAI_DIFF_CONTEXT_LINES" desc:"Unchanged lines kept around each change when compressing"`
+	DiffRewrites     []DiffRewrite `toml:"CAI_DIFF_REWRITES,omitempty" env:"-" desc:"Regex rewrites applied when compressing (TOML only)"`
+
+	// PlainDiff sends the diff as is instead of wrapping each file in a code
+	// fence tagged with its language
+	PlainDiff bool `toml:"CAI_PLAIN_DIFF" desc:"Don't wrap each file of the diff in a language-tagged code fence"`
+
+	// PromptGuard scans the diff for lines that read like instructions to
+	// the model: "warn" reports them, "strip" also removes them from the
+	// prompt, "off" skips the scan
+	PromptGuard string `toml:"CAI_PROMPT_GUARD" desc:"Scan the diff for instruction-like lines: warn, strip or off"`
+
+	// OutputContract is "strict" to re-prompt the model when a response
+	// isn't a bare commit message (explanations, headings, alternatives), or
+	// "off" to accept responses as they are
+	OutputContract string `toml:"CAI_OUTPUT_CONTRACT" desc:"strict re-prompts on responses that aren't a bare commit message; off accepts them"`
+
+	// Normalize lists the consistency rules applied to the subject of every
+	// generated message, so it passes the repository's commit linter
+	Normalize []string `toml:"CAI_NORMALIZE,omitempty" desc:"Subject rules applied after generation: lowercase-after-type, capitalize-first-word, strip-trailing-period"`
+
+	// Temperature is the sampling temperature sent to the provider; nil
+	// leaves it to the provider's default
+	Temperature *float64 `toml:"CAI_TEMPERATURE" desc:"Sampling temperature from 0 to 2 (--bot uses 0)"`
+
+	// BotAuthor is the "Name <email>" author of commits created with --bot
+	BotAuthor string `toml:"CAI_BOT_AUTHOR" desc:"Name <email> author of commits created with --bot"`
+
+	// Debug prints diagnostics, such as rejected model responses, to stderr
+	Debug bool `toml:"CAI_DEBUG" desc:"Print diagnostics such as rejected model responses to stderr (also --debug)"`
+
+	// SkipLFS drops Git LFS pointer files from the diff instead of
+	// summarizing them as object updates
+	SkipLFS bool `toml:"CAI_SKIP_LFS" desc:"Leave Git LFS objects out of the prompt instead of summarizing them"`
+
+	// Languages enables bilingual messages: the message is generated in the
+	// first language and translated into the others in a second pass. When
+	// set, its first entry takes precedence over Language.
+	Languages []string `toml:"CAI_LANGUAGES,omitempty" desc:"Bilingual messages: generate in the first language, append translations into the others"`
+
+	// Notes attaches generation metadata as a git note (refs/notes/commit-ai)
+	// to commits created by commit-ai
+	Notes bool `toml:"CAI_NOTES" desc:"Attach generation metadata as a git note to commits created with -c"`
+
+	// Attribution appends an AI attribution trailer to generated messages:
+	// "co-author" adds a Co-authored-by trailer, "assisted" an AI-assisted
+	// trailer naming the model, and "off" (or empty) adds none
+	Attribution string `toml:"CAI_ATTRIBUTION" desc:"AI attribution trailer: co-author, assisted or off"`
+
+	// NoStats disables recording whether interactively reviewed messages
+	// were accepted, edited or rejected (see commit-ai stats)
+	NoStats bool `toml:"CAI_NO_STATS" desc:"Don't record message acceptance for commit-ai stats"`
+
+	// RateLimitRPM and RateLimitTPM cap the requests and estimated tokens
+	// sent per minute with one API key, across all local processes; zero
+	// disables a cap
+	RateLimitRPM int `toml:"CAI_RATE_LIMIT_RPM" desc:"Max requests per minute per API key, shared across local processes"`
+	RateLimitTPM int `toml:"CAI_RATE_LIMIT_TPM" desc:"Max estimated prompt tokens per minute per API key"`
+
+	// BreakerThreshold consecutive failures of a provider endpoint open its
+	// circuit for BreakerCooldownSeconds; requests then go to the
+	// [providers.<name>] section named by FallbackProfile, or fail fast. A
+	// threshold of zero disables the circuit breaker.
+	BreakerThreshold       int    `toml:"CAI_BREAKER_THRESHOLD" desc:"Consecutive endpoint failures that open the circuit breaker (0 disables)"`
+	BreakerCooldownSeconds int    `toml:"CAI_BREAKER_COOLDOWN_SECONDS" desc:"How long an open circuit skips the endpoint"`
+	FallbackProfile        string `toml:"CAI_FALLBACK_PROFILE" desc:"[providers.<name>] section used while the circuit is open"`
+
+	// DepsUseLLM sends dependency-only changes to the model instead of
+	// composing the bump message locally
+	DepsUseLLM bool `toml:"CAI_DEPS_USE_LLM" desc:"Send dependency-only changes to the model instead of composing the message locally"`
+
+	// ContextCmd is a shell command whose output is exposed to the prompt
+	// template as {{.ExtraContext}}. Project files can't set it.
+	ContextCmd string `toml:"CAI_CONTEXT_CMD" desc:"Shell command whose output is passed to the prompt as {{.ExtraContext}}; not read from project files"`
+
+	// StandupRepos lists the repositories summarized by commit-ai standup;
+	// a leading ~ stands for the home directory
+	StandupRepos []string `toml:"CAI_STANDUP_REPOS" desc:"Repositories summarized by commit-ai standup; global config only"`
+
+	// TemplateEnv lists the environment variables exposed to prompt
+	// templates as {{.Env.NAME}}, such as CI job URLs and build numbers. It
+	// is not read from project files, which could otherwise send any
+	// variable of whoever works in the repository to the provider.
+	TemplateEnv []string `toml:"CAI_TEMPLATE_ENV" desc:"Environment variables templates can read as {{.Env.NAME}} (comma-separated in env); not read from project files"`
+
+	// ForbiddenPatterns are regular expressions, matched case-insensitively,
+	// that generated messages must not match, such as internal codenames or
+	// customer names. Project files add to the list rather than replace it.
+	ForbiddenPatterns []string `toml:"CAI_FORBIDDEN_PATTERNS" desc:"Regular expressions generated messages must not match (comma-separated in env)"`
+
+	// BranchTemplates maps branch name patterns to prompt templates and
+	// extra instructions; the first matching entry applies
+	BranchTemplates []BranchTemplate `toml:"CAI_BRANCH_TEMPLATES,omitempty" env:"-" desc:"Branch pattern to prompt template/instructions mapping (TOML only)"`
+
+	// SmallChangeLines is the size, in changed lines, up to which a diff of
+	// a single hunk gets the compact prompt and SmallChangeModel, when set;
+	// zero disables the fast path
+	SmallChangeLines int    `toml:"CAI_SMALL_CHANGE_LINES" desc:"Largest single-hunk change that gets the compact prompt; 0 disables it"`
+	SmallChangeModel string `toml:"CAI_SMALL_CHANGE_MODEL" desc:"Model used for small changes"`
+
+	// LargeChangeLines is the size, in changed lines, from which a diff gets
+	// the large prompt; zero disables it
+	LargeChangeLines int `toml:"CAI_LARGE_CHANGE_LINES" desc:"Changed lines from which a change gets the large prompt; 0 disables it"`
+
+	// PromptVariant pins the built-in prompt: "tiny", "medium" or "large",
+	// or "auto" to pick it by the size of the change. Custom templates are
+	// always used as configured.
+	PromptVariant string `toml:"CAI_PROMPT_VARIANT" desc:"Built-in prompt: auto picks tiny, medium or large by change size; the others pin one"`
+
+	// ModelRoutes send changes matching a route's conditions to another
+	// model or provider profile; the first matching route applies
+	ModelRoutes []ModelRoute `toml:"CAI_MODEL_ROUTES,omitempty" env:"-" desc:"Rules choosing a model or provider profile per change (TOML only)"`
+
+	// Profile names the [providers.<name>] section to use; when empty the
+	// section named after Provider is used
+	Profile string `toml:"CAI_PROFILE" desc:"[providers.<name>] section to use instead of the one named after CAI_PROVIDER"`
+	// Providers holds per-provider (or per-profile) endpoint settings
+	Providers map[string]ProviderSettings `toml:"providers,omitempty" env:"-" desc:"Endpoint settings per provider or profile, selected by CAI_PROVIDER or CAI_PROFILE"`
+
+	// envProvider holds provider settings from CAI_* environment variables
+	// and per-run overrides, which take precedence over [providers.*] sections
+	envProvider ProviderSettings
+	// projectProvider holds the flat provider keys of project files, and
+	// projectProviders their [providers.*] sections: a repository's model or
+	// endpoint overrides the global section of the same provider
+	projectProvider  ProviderSettings
+	projectProviders map[string]ProviderSettings
+	// routeModel is the model chosen by a model route, which overrides all
+	// other model settings
+	routeModel string
+
+	// repoRoot is the repository the project configuration was loaded for and
+	// promptTemplateDir the directory of the project config file declaring
+	// CAI_PROMPT_TEMPLATE; both anchor relative template paths
+	repoRoot          string
+	promptTemplateDir string
+
+	// RemoteConfigURL names an HTTPS-hosted TOML configuration merged below
+	// the global file, cached for RemoteConfigTTLSeconds. When
+	// RemoteConfigPublicKey is set, it must carry a valid Ed25519 signature.
+	// These are only read from the environment and the global file.
+	RemoteConfigURL        string `toml:"CAI_REMOTE_CONFIG_URL" desc:"HTTPS URL of an organization config merged below the global file"`
+	RemoteConfigPublicKey  string `toml:"CAI_REMOTE_CONFIG_PUBKEY" desc:"Base64 Ed25519 key the remote config's .sig must verify against"`
+	RemoteConfigTTLSeconds int    `toml:"CAI_REMOTE_CONFIG_TTL" desc:"Seconds a fetched remote config is used before it is revalidated"`
+
+	// AuditLog is the path of an append-only JSONL log recording every
+	// provider request without its content; empty disables it. The log is
+	// rotated past AuditLogMaxMB, keeping AuditLogMaxFiles old files. Like
+	// the remote config settings, these are ignored in project files.
+	AuditLog         string `toml:"CAI_AUDIT_LOG" desc:"JSONL file recording every provider request without its content"`
+	AuditLogMaxMB    int    `toml:"CAI_AUDIT_LOG_MAX_MB" desc:"Size at which the audit log is rotated (0 disables rotation)"`
+	AuditLogMaxFiles int    `toml:"CAI_AUDIT_LOG_MAX_FILES" desc:"Rotated audit log files kept"`
+
+	// ServeRepos lists the directories whose repositories commit-ai serve
+	// may read, with a leading ~ standing for the home directory. Clients
+	// must send ServeToken, which is required on TCP listeners. Requests
+	// are limited to ServeMaxRequestKB and ServeMaxConcurrent at a time.
+	// These are ignored in project files, so a repository cannot widen them.
+	ServeRepos         []string `toml:"CAI_SERVE_REPOS" desc:"Directories whose repositories commit-ai serve may read; global config only"`
+	ServeToken         string   `toml:"CAI_SERVE_TOKEN" secret:"true" desc:"Bearer token clients of commit-ai serve must send; required on TCP"`
+	ServeMaxRequestKB  int      `toml:"CAI_SERVE_MAX_REQUEST_KB" desc:"Largest request body commit-ai serve accepts, in KiB"`
+	ServeMaxConcurrent int      `toml:"CAI_SERVE_MAX_CONCURRENT" desc:"Requests commit-ai serve generates at the same time; others are refused"`
+
+	// warnings collects non-fatal problems found while loading
+	warnings []string
+	// apiTokenFiles lists the config files that set CAI_API_TOKEN
+	apiTokenFiles []string
+
+	// Include lists additional config files whose values are loaded before the
+	// including file, so the including file always wins.
+	Include []string `toml:"include,omitempty" env:"-" desc:"Config files loaded before this one, whose values this file overrides"`
+}
+
+// BranchTemplate selects a prompt template and/or additional instructions for
+// branches whose name matches Pattern (path.Match syntax, e.g. "hotfix/*")
+type BranchTemplate struct {
+	Pattern      string `toml:"pattern" desc:"Branch name pattern (path.Match syntax)"`
+	Template     string `toml:"template,omitempty" desc:"Prompt template used on matching branches"`
+	Instructions string `toml:"instructions,omitempty" desc:"Extra instructions added to the prompt on matching branches"`
+}
+
+// ProviderSettings are the endpoint settings of a [providers.<name>] section.
+// Provider selects the API type for profiles not named after a provider.
+type ProviderSettings struct {
+	Provider string `toml:"provider,omitempty" desc:"Provider of the section: ollama, openai, local, vertex, replay or a preset"`
+	URL      string `toml:"url,omitempty" desc:"API URL of the provider"`
+	Token    string `toml:"token,omitempty" secret:"true" desc:"API token of the provider"`
+	Model    string `toml:"model,omitempty" desc:"Model name to use"`
+	// AuthScheme is how Token is sent; see CAI_AUTH_SCHEME
+	AuthScheme string `toml:"auth_scheme,omitempty" desc:"How the token is sent, like CAI_AUTH_SCHEME"`
+	// Preset names the provider preset the settings were resolved from, in
+	// which case Provider is "openai"
+	Preset string `toml:"-"`
+}
+
+// merge overrides s with the non-empty values of other
+func (s ProviderSettings) merge(other ProviderSettings) ProviderSettings {
+	if other.Provider != "" {
+		s.Provider = other.Provider
+	}
+	if other.URL != "" {
+		s.URL = other.URL
+	}
+	if other.Token != "" {
+		s.Token = other.Token
+	}
+	if other.Model != "" {
+		s.Model = other.Model
+	}
+	if other.AuthScheme != "" {
+		s.AuthScheme = other.AuthScheme
+	}
+	return s
+}
+
+// DiffRewrite normalizes noisy diff content by replacing every match of the
+// Pattern regular expression with Replacement
+type DiffRewrite struct {
+	Pattern     string `toml:"pattern" desc:"Regular expression matched against diff lines"`
+	Replacement string `toml:"replacement" desc:"Replacement text, which may refer to capture groups"`
 }
 
 // DefaultConfig returns the default configuration
 func DefaultConfig() *Config {
 	return &Config{
-		APIURL:         "http://localhost:11434",
-		Model:          "llama2",
-		Provider:       providerOllama,
-		APIToken:       "",
-		Language:       "english",
-		PromptTemplate: "default.txt",
-		TimeoutSeconds: 300, // 5 minutes default
+		APIURL:           defaultAPIURL,
+		Model:            defaultModel,
+		Provider:         providerOllama,
+		APIToken:         "",
+		Language:         "english",
+		PromptTemplate:   DefaultPromptTemplate,
+		TimeoutSeconds:   300, // 5 minutes default
+		QuickMode:        false,
+		ReadOnly:         false,
+		IndexOnly:        false,
+		TicketTrailer:    "Refs",
+		OutputContract:   OutputContractStrict,
+		SmallChangeLines: 10,
+		LargeChangeLines: 300,
+		PromptVariant:    PromptVariantAuto,
+		VertexLocation:   "us-central1",
+
+		ProtectedBranches:   []string{"main", "master", "release/*"},
+		ProtectedBranchMode: ProtectedBranchRefuse,
+		MaxCommitFiles:      50,
+		MaxCommitLines:      1000,
+		OversizedCommitMode: OversizedCommitWarn,
+		DiffContextLines:    3,
+
+		BreakerThreshold:       3,
+		BreakerCooldownSeconds: 120,
+
+		RemoteConfigTTLSeconds: 3600,
+
+		AuditLogMaxMB:    10,
+		AuditLogMaxFiles: 5,
+
+		ServeMaxRequestKB:  64,
+		ServeMaxConcurrent: 4,
 	}
 }
 
@@ -47,10 +448,11 @@ func Load(configFile string) (*Config, error) {
 
 // LoadWithProjectPath loads the configuration with cascading project-local overrides.
 // Configuration is loaded in the following priority order (highest to lowest):
-//  1. Environment variables (CAI_*)
-//  2. Project-local .commitai files (more specific directories override less specific ones)
-//  3. Global configuration file
-//  4. Default values
+//  1. Per-run overrides (LoadOptions.Overrides, e.g. command line flags)
+//  2. Environment variables (CAI_*)
+//  3. Project-local .commitai files (more specific directories override less specific ones)
+//  4. Global configuration file
+//  5. Default values
 //
 // Project-local configurations are discovered by:
 //   - Finding the git repository root (if in a git repository)
@@ -63,17 +465,46 @@ func Load(configFile string) (*Config, error) {
 //
 // Returns the merged configuration with all overrides applied.
 func LoadWithProjectPath(configFile, projectPath string) (*Config, error) {
+	return LoadWithOptions(configFile, projectPath, LoadOptions{})
+}
+
+// LoadOptions controls side effects of loading the configuration
+type LoadOptions struct {
+	// NoWrite keeps a missing global configuration file from being created
+	// with default values, e.g. for read-only commands. CAI_NO_AUTO_CONFIG
+	// and CAI_READ_ONLY have the same effect.
+	NoWrite bool
+	// Overrides maps configuration keys such as CAI_MODEL to values for a
+	// single run, e.g. from command line flags. They take precedence over
+	// environment variables and are parsed the same way.
+	Overrides map[string]string
+}
+
+// LoadWithOptions is LoadWithProjectPath with explicit load options
+func LoadWithOptions(configFile, projectPath string, opts LoadOptions) (*Config, error) {
 	cfg := DefaultConfig()
 
 	// Load global configuration
-	if _, err := os.Stat(configFile); os.IsNotExist(err) {
-		// If config file doesn't exist, create it with default values
-		if err := cfg.Save(configFile); err != nil {
-			return nil, fmt.Errorf("failed to create default config file: %w", err)
+	_, statErr := os.Stat(configFile)
+	exists := !os.IsNotExist(statErr)
+	if !exists {
+		// If config file doesn't exist, create it with default values unless
+		// writing was disabled, e.g. in packaging sandboxes, CI or read-only runs
+		if !opts.NoWrite && !AutoConfigDisabled() && !readOnlyFromEnv() && !opts.readOnly() {
+			if err := cfg.saveIfMissing(configFile); err != nil {
+				return nil, fmt.Errorf("failed to create default config file: %w", err)
+			}
 		}
-	} else {
+	}
+
+	// Organization-managed settings sit between the defaults and the global file
+	if err := cfg.applyRemoteConfig(configFile, exists); err != nil {
+		return nil, fmt.Errorf("failed to apply remote configuration: %w", err)
+	}
+
+	if exists {
 		// Load configuration from file
-		if _, err := toml.DecodeFile(configFile, cfg); err != nil {
+		if err := decodeWithIncludes(configFile, cfg, map[string]bool{}); err != nil {
 			return nil, fmt.Errorf("failed to decode config file %s: %w", configFile, err)
 		}
 	}
@@ -83,35 +514,174 @@ func LoadWithProjectPath(configFile, projectPath string) (*Config, error) {
 		return nil, fmt.Errorf("failed to apply project configuration: %w", err)
 	}
 
-	// Override with environment variables if present (highest priority)
+	// Override with environment variables, then with per-run overrides
 	cfg.loadFromEnv()
+	cfg.applyOverrides(func(key string) string { return opts.Overrides[key] })
 
 	return cfg, nil
 }
 
-// Save saves the configuration to the specified file
-func (c *Config) Save(configFile string) error {
-	// Create directory if it doesn't exist
-	dir := filepath.Dir(configFile)
-	if err := os.MkdirAll(dir, 0o750); err != nil {
-		return fmt.Errorf("failed to create config directory: %w", err)
+// readOnly reports whether the overrides enable read-only mode
+func (o LoadOptions) readOnly() bool {
+	readOnly, err := strconv.ParseBool(o.Overrides["CAI_READ_ONLY"])
+	return err == nil && readOnly
+}
+
+// Warnings returns the non-fatal problems found while loading the
+// configuration, such as an unreachable remote configuration
+func (c *Config) Warnings() []string {
+	return c.warnings
+}
+
+// APITokenFiles returns the config files, global, included or project files,
+// that hold CAI_API_TOKEN in plain text, in the order they were loaded
+func (c *Config) APITokenFiles() []string {
+	return c.apiTokenFiles
+}
+
+// RequiresAPIToken reports whether the provider needs CAI_API_TOKEN
+func (c *Config) RequiresAPIToken() bool {
+	return c.Provider == providerOpenAI
+}
+
+// ProjectEndpoint reports whether the active provider's URL comes from a
+// project config file rather than the global config or the environment.
+// Credentials kept outside the repository must not be sent to such a URL.
+func (c *Config) ProjectEndpoint() bool {
+	if c.envProvider.URL != "" {
+		return false
+	}
+	name := c.Profile
+	if name == "" {
+		name = c.Provider
+	}
+	if section, ok := c.projectProviders[name]; ok && section.URL != "" {
+		return true
 	}
+	return c.projectProvider.URL != ""
+}
+
+// warn records a non-fatal loading problem
+func (c *Config) warn(msg string) {
+	c.warnings = append(c.warnings, msg)
+}
 
-	// #nosec G304 -- configFile path is controlled by application
-	file, err := os.OpenFile(configFile, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+// readOnlyFromEnv reports whether CAI_READ_ONLY enables read-only mode
+func readOnlyFromEnv() bool {
+	readOnly, err := strconv.ParseBool(os.Getenv("CAI_READ_ONLY"))
+	return err == nil && readOnly
+}
+
+// AutoConfigDisabled reports whether CAI_NO_AUTO_CONFIG disables creating a
+// default global configuration file
+func AutoConfigDisabled() bool {
+	disabled, err := strconv.ParseBool(os.Getenv("CAI_NO_AUTO_CONFIG"))
+	return err == nil && disabled
+}
+
+// decodeWithIncludes decodes configFile into cfg after first decoding every file
+// listed in its include directive. Includes are resolved relative to the
+// including file, support a leading ~ for the home directory, and are skipped
+// when missing so shared files can be optional. Include cycles are rejected.
+func decodeWithIncludes(configFile string, cfg *Config, seen map[string]bool) error {
+	absPath, err := filepath.Abs(configFile)
 	if err != nil {
-		return fmt.Errorf("failed to create config file: %w", err)
+		return fmt.Errorf("failed to resolve config path: %w", err)
 	}
-	defer file.Close()
+	if seen[absPath] {
+		return fmt.Errorf("include cycle detected at %s", absPath)
+	}
+	seen[absPath] = true
+	defer delete(seen, absPath)
 
-	encoder := toml.NewEncoder(file)
-	if err := encoder.Encode(c); err != nil {
-		return fmt.Errorf("failed to encode config: %w", err)
+	var directives struct {
+		Include []string `toml:"include"`
+	}
+	if _, err := toml.DecodeFile(absPath, &directives); err != nil {
+		return err
 	}
 
+	for _, include := range directives.Include {
+		includePath, err := resolveIncludePath(include, filepath.Dir(absPath))
+		if err != nil {
+			return fmt.Errorf("invalid include %q: %w", include, err)
+		}
+		if _, err := os.Stat(includePath); os.IsNotExist(err) {
+			continue
+		}
+		if err := decodeWithIncludes(includePath, cfg, seen); err != nil {
+			return fmt.Errorf("failed to load include %s: %w", includePath, err)
+		}
+	}
+
+	meta, err := toml.DecodeFile(absPath, cfg)
+	if err != nil {
+		return err
+	}
+	// The file just decoded sets APIToken when it defines the key
+	if meta.IsDefined("CAI_API_TOKEN") && cfg.APIToken != "" {
+		cfg.apiTokenFiles = append(cfg.apiTokenFiles, absPath)
+	}
 	return nil
 }
 
+// resolveIncludePath expands a leading ~ and makes relative include paths
+// relative to the directory of the including file.
+func resolveIncludePath(include, baseDir string) (string, error) {
+	if include == "" {
+		return "", fmt.Errorf("empty include path")
+	}
+
+	include, err := expandHome(include)
+	if err != nil {
+		return "", err
+	}
+
+	if !filepath.IsAbs(include) {
+		include = filepath.Join(baseDir, include)
+	}
+
+	return filepath.Clean(include), nil
+}
+
+// expandHome replaces a leading ~ in path with the home directory
+func expandHome(path string) (string, error) {
+	if path != "~" && !strings.HasPrefix(path, "~/") {
+		return path, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve home directory: %w", err)
+	}
+	return filepath.Join(home, strings.TrimPrefix(path, "~")), nil
+}
+
+// StandupPaths returns the CAI_STANDUP_REPOS paths with a leading ~ expanded
+func (c *Config) StandupPaths() ([]string, error) {
+	paths := make([]string, 0, len(c.StandupRepos))
+	for _, repo := range c.StandupRepos {
+		expanded, err := expandHome(repo)
+		if err != nil {
+			return nil, err
+		}
+		paths = append(paths, expanded)
+	}
+	return paths, nil
+}
+
+// ServePaths returns the CAI_SERVE_REPOS paths with a leading ~ expanded
+func (c *Config) ServePaths() ([]string, error) {
+	paths := make([]string, 0, len(c.ServeRepos))
+	for _, repo := range c.ServeRepos {
+		expanded, err := expandHome(repo)
+		if err != nil {
+			return nil, err
+		}
+		paths = append(paths, expanded)
+	}
+	return paths, nil
+}
+
 // applyProjectConfig applies project-local configuration from .commitai files.
 // It finds the git repository root and looks for .commitai files from the root
 // to the project path, applying them in hierarchical order.
@@ -121,6 +691,8 @@ func (c *Config) applyProjectConfig(projectPath string) error {
 	if err != nil {
 		// If not in a git repository, just use the project path
 		gitRoot = projectPath
+	} else {
+		c.repoRoot = gitRoot
 	}
 
 	// Look for .commitai files from git root up to current directory
@@ -151,10 +723,17 @@ func (c *Config) loadProjectConfig(configFile string) error {
 
 	// Create a temporary config to load project settings
 	projectCfg := &Config{}
-	if _, err := toml.DecodeFile(configFile, projectCfg); err != nil {
+	if err := decodeWithIncludes(configFile, projectCfg, map[string]bool{}); err != nil {
 		return fmt.Errorf("failed to decode project config file %s: %w", configFile, err)
 	}
 
+	c.projectProvider = c.projectProvider.merge(ProviderSettings{
+		URL:        projectCfg.APIURL,
+		Token:      projectCfg.APIToken,
+		Model:      projectCfg.Model,
+		AuthScheme: projectCfg.AuthScheme,
+	})
+
 	// Merge non-empty values from project config into main config
 	if projectCfg.APIURL != "" {
 		c.APIURL = projectCfg.APIURL
@@ -167,16 +746,217 @@ func (c *Config) loadProjectConfig(configFile string) error {
 	}
 	if projectCfg.APIToken != "" {
 		c.APIToken = projectCfg.APIToken
+		c.apiTokenFiles = append(c.apiTokenFiles, projectCfg.apiTokenFiles...)
+	}
+	if projectCfg.AuthScheme != "" {
+		c.AuthScheme = projectCfg.AuthScheme
 	}
 	if projectCfg.Language != "" {
 		c.Language = projectCfg.Language
 	}
+	if len(projectCfg.Languages) > 0 {
+		c.Languages = projectCfg.Languages
+	}
+	if projectCfg.Notes {
+		c.Notes = true
+	}
+	if projectCfg.Attribution != "" {
+		c.Attribution = projectCfg.Attribution
+	}
+	if projectCfg.NoStats {
+		c.NoStats = true
+	}
+	if projectCfg.RateLimitRPM != 0 {
+		c.RateLimitRPM = projectCfg.RateLimitRPM
+	}
+	if projectCfg.RateLimitTPM != 0 {
+		c.RateLimitTPM = projectCfg.RateLimitTPM
+	}
+	if projectCfg.BreakerThreshold != 0 {
+		c.BreakerThreshold = projectCfg.BreakerThreshold
+	}
+	if projectCfg.BreakerCooldownSeconds != 0 {
+		c.BreakerCooldownSeconds = projectCfg.BreakerCooldownSeconds
+	}
+	if projectCfg.AllowedProviders != nil {
+		c.AllowedProviders = narrowProviders(c.AllowedProviders, projectCfg.AllowedProviders)
+	}
+	if projectCfg.FallbackProfile != "" {
+		c.FallbackProfile = projectCfg.FallbackProfile
+	}
 	if projectCfg.PromptTemplate != "" {
 		c.PromptTemplate = projectCfg.PromptTemplate
+		if absConfig, err := filepath.Abs(configFile); err == nil {
+			c.promptTemplateDir = filepath.Dir(absConfig)
+		}
 	}
 	if projectCfg.TimeoutSeconds != 0 {
 		c.TimeoutSeconds = projectCfg.TimeoutSeconds
 	}
+	if projectCfg.Temperature != nil {
+		c.Temperature = projectCfg.Temperature
+	}
+	if projectCfg.BotAuthor != "" {
+		c.BotAuthor = projectCfg.BotAuthor
+	}
+	if projectCfg.QuickMode {
+		c.QuickMode = true
+	}
+	if projectCfg.ReadOnly {
+		c.ReadOnly = true
+	}
+	if projectCfg.IndexOnly {
+		c.IndexOnly = true
+	}
+	// The Jira token, possibly from the keyring, is sent to this host
+	if projectCfg.JiraURL != "" {
+		c.warn(fmt.Sprintf("ignoring CAI_JIRA_URL from project config %s; set it in the global config", configFile))
+	}
+	if projectCfg.JiraEmail != "" {
+		c.JiraEmail = projectCfg.JiraEmail
+	}
+	if projectCfg.JiraToken != "" {
+		c.JiraToken = projectCfg.JiraToken
+	}
+	if projectCfg.GitHubIssues {
+		c.GitHubIssues = true
+	}
+	if projectCfg.GitHubToken != "" {
+		c.GitHubToken = projectCfg.GitHubToken
+	}
+	// GH_TOKEN, GITHUB_TOKEN and the gh login are sent to this host
+	if projectCfg.GitHubAPIURL != "" {
+		c.warn(fmt.Sprintf("ignoring CAI_GITHUB_API_URL from project config %s; set it in the global config", configFile))
+	}
+	// CAI_GITLAB_TOKEN is sent to this host
+	if projectCfg.GitLabURL != "" {
+		c.warn(fmt.Sprintf("ignoring CAI_GITLAB_URL from project config %s; set it in the global config", configFile))
+	}
+	if projectCfg.GitLabToken != "" {
+		c.GitLabToken = projectCfg.GitLabToken
+	}
+	if projectCfg.LinearToken != "" {
+		c.LinearToken = projectCfg.LinearToken
+	}
+	if projectCfg.AzureToken != "" {
+		c.AzureToken = projectCfg.AzureToken
+	}
+	if projectCfg.TicketProvider != "" {
+		c.TicketProvider = projectCfg.TicketProvider
+	}
+	if projectCfg.TicketTrailer != "" {
+		c.TicketTrailer = projectCfg.TicketTrailer
+	}
+	if len(projectCfg.ProtectedBranches) > 0 {
+		c.ProtectedBranches = projectCfg.ProtectedBranches
+	}
+	if projectCfg.ProtectedBranchMode != "" {
+		c.ProtectedBranchMode = projectCfg.ProtectedBranchMode
+	}
+	if projectCfg.MaxCommitFiles != 0 {
+		c.MaxCommitFiles = projectCfg.MaxCommitFiles
+	}
+	if projectCfg.MaxCommitLines != 0 {
+		c.MaxCommitLines = projectCfg.MaxCommitLines
+	}
+	if projectCfg.OversizedCommitMode != "" {
+		c.OversizedCommitMode = projectCfg.OversizedCommitMode
+	}
+	if projectCfg.CompressDiff {
+		c.CompressDiff = true
+	}
+	if projectCfg.PlainDiff {
+		c.PlainDiff = true
+	}
+	if projectCfg.PromptGuard != "" {
+		c.PromptGuard = projectCfg.PromptGuard
+	}
+	if projectCfg.OutputContract != "" {
+		c.OutputContract = projectCfg.OutputContract
+	}
+	if len(projectCfg.Normalize) > 0 {
+		c.Normalize = projectCfg.Normalize
+	}
+	if projectCfg.Debug {
+		c.Debug = true
+	}
+	if projectCfg.SkipLFS {
+		c.SkipLFS = true
+	}
+	if projectCfg.DiffContextLines != 0 {
+		c.DiffContextLines = projectCfg.DiffContextLines
+	}
+	// A cloned repository must not run commands on the machine of whoever
+	// generates a message in it
+	if projectCfg.ContextCmd != "" {
+		c.warn(fmt.Sprintf("ignoring CAI_CONTEXT_CMD from project config %s; set it in the global config", configFile))
+	}
+	if len(projectCfg.TemplateEnv) > 0 {
+		c.warn(fmt.Sprintf("ignoring CAI_TEMPLATE_ENV from project config %s; set it in the global config or the environment", configFile))
+	}
+	for _, pattern := range projectCfg.ForbiddenPatterns {
+		if !slices.Contains(c.ForbiddenPatterns, pattern) {
+			c.ForbiddenPatterns = append(c.ForbiddenPatterns, pattern)
+		}
+	}
+	if projectCfg.VertexProject != "" {
+		c.VertexProject = projectCfg.VertexProject
+	}
+	if projectCfg.VertexLocation != "" {
+		c.VertexLocation = projectCfg.VertexLocation
+	}
+	if projectCfg.VertexCredentials != "" {
+		c.VertexCredentials = projectCfg.VertexCredentials
+	}
+	if projectCfg.ModelPath != "" {
+		c.ModelPath = projectCfg.ModelPath
+	}
+	if projectCfg.ReplayFixtures != "" {
+		c.ReplayFixtures = projectCfg.ReplayFixtures
+	}
+	if projectCfg.Proxy != "" {
+		c.Proxy = projectCfg.Proxy
+	}
+	if projectCfg.SSHJumpHost != "" {
+		c.SSHJumpHost = projectCfg.SSHJumpHost
+	}
+	if projectCfg.DepsUseLLM {
+		c.DepsUseLLM = true
+	}
+	if len(projectCfg.DiffRewrites) > 0 {
+		c.DiffRewrites = projectCfg.DiffRewrites
+	}
+	if projectCfg.Profile != "" {
+		c.Profile = projectCfg.Profile
+	}
+	for name, settings := range projectCfg.Providers {
+		if c.Providers == nil {
+			c.Providers = make(map[string]ProviderSettings)
+		}
+		if c.projectProviders == nil {
+			c.projectProviders = make(map[string]ProviderSettings)
+		}
+		c.Providers[name] = c.Providers[name].merge(settings)
+		c.projectProviders[name] = c.projectProviders[name].merge(settings)
+	}
+	if len(projectCfg.BranchTemplates) > 0 {
+		c.BranchTemplates = projectCfg.BranchTemplates
+	}
+	if len(projectCfg.ModelRoutes) > 0 {
+		c.ModelRoutes = projectCfg.ModelRoutes
+	}
+	if projectCfg.SmallChangeLines != 0 {
+		c.SmallChangeLines = projectCfg.SmallChangeLines
+	}
+	if projectCfg.SmallChangeModel != "" {
+		c.SmallChangeModel = projectCfg.SmallChangeModel
+	}
+	if projectCfg.LargeChangeLines != 0 {
+		c.LargeChangeLines = projectCfg.LargeChangeLines
+	}
+	if projectCfg.PromptVariant != "" {
+		c.PromptVariant = projectCfg.PromptVariant
+	}
 
 	return nil
 }
@@ -323,40 +1103,629 @@ func findProjectConfigs(gitRoot, projectPath string) []string {
 
 // loadFromEnv loads configuration values from environment variables
 func (c *Config) loadFromEnv() {
-	if val := os.Getenv("CAI_API_URL"); val != "" {
+	c.applyOverrides(os.Getenv)
+}
+
+// applyOverrides sets every key for which get returns a value, get being
+// called with the key's name such as CAI_MODEL. Values that don't parse, or
+// are out of range, are ignored.
+func (c *Config) applyOverrides(get func(key string) string) {
+	if val := get("CAI_API_URL"); val != "" {
 		c.APIURL = val
+		c.envProvider.URL = val
 	}
-	if val := os.Getenv("CAI_MODEL"); val != "" {
+	if val := get("CAI_MODEL"); val != "" {
 		c.Model = val
+		c.envProvider.Model = val
 	}
-	if val := os.Getenv("CAI_PROVIDER"); val != "" {
+	if val := get("CAI_PROVIDER"); val != "" {
 		c.Provider = val
 	}
-	if val := os.Getenv("CAI_API_TOKEN"); val != "" {
+	if val := get("CAI_API_TOKEN"); val != "" {
 		c.APIToken = val
+		c.envProvider.Token = val
+	}
+	if val := get("CAI_AUTH_SCHEME"); val != "" {
+		c.AuthScheme = val
+		c.envProvider.AuthScheme = val
+	}
+	if val := get("CAI_PROFILE"); val != "" {
+		c.Profile = val
+	}
+	if val := get("CAI_VERTEX_PROJECT"); val != "" {
+		c.VertexProject = val
+	}
+	if val := get("CAI_VERTEX_LOCATION"); val != "" {
+		c.VertexLocation = val
 	}
-	if val := os.Getenv("CAI_LANGUAGE"); val != "" {
+	if val := get("CAI_VERTEX_CREDENTIALS"); val != "" {
+		c.VertexCredentials = val
+	}
+	if val := get("CAI_MODEL_PATH"); val != "" {
+		c.ModelPath = val
+	}
+	if val := get("CAI_REPLAY_FIXTURES"); val != "" {
+		c.ReplayFixtures = val
+	}
+	if val := get("CAI_RECORD"); val != "" {
+		if record, err := strconv.ParseBool(val); err == nil {
+			c.Record = record
+		}
+	}
+	if val := get("CAI_PROXY"); val != "" {
+		c.Proxy = val
+	}
+	if val := get("CAI_SSH_JUMP_HOST"); val != "" {
+		c.SSHJumpHost = val
+	}
+	if val := get("CAI_LANGUAGE"); val != "" {
 		c.Language = val
 	}
-	if val := os.Getenv("CAI_PROMPT_TEMPLATE"); val != "" {
+	if val := get("CAI_LANGUAGES"); val != "" {
+		c.Languages = splitList(val)
+	}
+	if val := get("CAI_NOTES"); val != "" {
+		if notes, err := strconv.ParseBool(val); err == nil {
+			c.Notes = notes
+		}
+	}
+	if val := get("CAI_ATTRIBUTION"); val != "" {
+		c.Attribution = val
+	}
+	if val := get("CAI_NO_STATS"); val != "" {
+		if noStats, err := strconv.ParseBool(val); err == nil {
+			c.NoStats = noStats
+		}
+	}
+	if val := get("CAI_RATE_LIMIT_RPM"); val != "" {
+		if rpm, err := strconv.Atoi(val); err == nil && rpm >= 0 {
+			c.RateLimitRPM = rpm
+		}
+	}
+	if val := get("CAI_RATE_LIMIT_TPM"); val != "" {
+		if tpm, err := strconv.Atoi(val); err == nil && tpm >= 0 {
+			c.RateLimitTPM = tpm
+		}
+	}
+	if val := get("CAI_BREAKER_THRESHOLD"); val != "" {
+		if threshold, err := strconv.Atoi(val); err == nil && threshold >= 0 {
+			c.BreakerThreshold = threshold
+		}
+	}
+	if val := get("CAI_BREAKER_COOLDOWN_SECONDS"); val != "" {
+		if cooldown, err := strconv.Atoi(val); err == nil && cooldown > 0 {
+			c.BreakerCooldownSeconds = cooldown
+		}
+	}
+	if val := get("CAI_FALLBACK_PROFILE"); val != "" {
+		c.FallbackProfile = val
+	}
+	if val := get("CAI_PROMPT_TEMPLATE"); val != "" {
 		c.PromptTemplate = val
+		c.promptTemplateDir = ""
 	}
-	if val := os.Getenv("CAI_TIMEOUT_SECONDS"); val != "" {
+	if val := get("CAI_TIMEOUT_SECONDS"); val != "" {
 		if timeout, err := strconv.Atoi(val); err == nil && timeout > 0 {
 			c.TimeoutSeconds = timeout
 		}
 	}
+	if val := get("CAI_TEMPERATURE"); val != "" {
+		if temperature, err := strconv.ParseFloat(val, 64); err == nil {
+			c.Temperature = &temperature
+		}
+	}
+	if val := get("CAI_BOT_AUTHOR"); val != "" {
+		c.BotAuthor = val
+	}
+	if val := get("CAI_QUICK_MODE"); val != "" {
+		if quick, err := strconv.ParseBool(val); err == nil {
+			c.QuickMode = quick
+		}
+	}
+	if val := get("CAI_READ_ONLY"); val != "" {
+		if readOnly, err := strconv.ParseBool(val); err == nil {
+			c.ReadOnly = readOnly
+		}
+	}
+	if val := get("CAI_INDEX_ONLY"); val != "" {
+		if indexOnly, err := strconv.ParseBool(val); err == nil {
+			c.IndexOnly = indexOnly
+		}
+	}
+	if val := get("CAI_JIRA_URL"); val != "" {
+		c.JiraURL = val
+	}
+	if val := get("CAI_JIRA_EMAIL"); val != "" {
+		c.JiraEmail = val
+	}
+	if val := get("CAI_JIRA_TOKEN"); val != "" {
+		c.JiraToken = val
+	}
+	if val := get("CAI_GITHUB_ISSUES"); val != "" {
+		if enabled, err := strconv.ParseBool(val); err == nil {
+			c.GitHubIssues = enabled
+		}
+	}
+	if val := get("CAI_GITHUB_TOKEN"); val != "" {
+		c.GitHubToken = val
+	}
+	if val := get("CAI_GITHUB_API_URL"); val != "" {
+		c.GitHubAPIURL = val
+	}
+	if val := get("CAI_GITLAB_URL"); val != "" {
+		c.GitLabURL = val
+	}
+	if val := get("CAI_GITLAB_TOKEN"); val != "" {
+		c.GitLabToken = val
+	}
+	if val := get("CAI_LINEAR_TOKEN"); val != "" {
+		c.LinearToken = val
+	}
+	if val := get("CAI_AZURE_DEVOPS_TOKEN"); val != "" {
+		c.AzureToken = val
+	}
+	if val := get("CAI_TICKET_PROVIDER"); val != "" {
+		c.TicketProvider = val
+	}
+	if val := get("CAI_TICKET_TRAILER"); val != "" {
+		c.TicketTrailer = val
+	}
+	if val := get("CAI_PROTECTED_BRANCHES"); val != "" {
+		c.ProtectedBranches = splitList(val)
+	}
+	if val := get("CAI_PROTECTED_BRANCH_MODE"); val != "" {
+		c.ProtectedBranchMode = val
+	}
+	if val := get("CAI_MAX_COMMIT_FILES"); val != "" {
+		if files, err := strconv.Atoi(val); err == nil && files >= 0 {
+			c.MaxCommitFiles = files
+		}
+	}
+	if val := get("CAI_MAX_COMMIT_LINES"); val != "" {
+		if lines, err := strconv.Atoi(val); err == nil && lines >= 0 {
+			c.MaxCommitLines = lines
+		}
+	}
+	if val := get("CAI_OVERSIZED_COMMIT_MODE"); val != "" {
+		c.OversizedCommitMode = val
+	}
+	if val := get("CAI_COMPRESS_DIFF"); val != "" {
+		if compress, err := strconv.ParseBool(val); err == nil {
+			c.CompressDiff = compress
+		}
+	}
+	if val := get("CAI_PROMPT_GUARD"); val != "" {
+		c.PromptGuard = val
+	}
+	if val := get("CAI_SMALL_CHANGE_LINES"); val != "" {
+		if lines, err := strconv.Atoi(val); err == nil {
+			c.SmallChangeLines = lines
+		}
+	}
+	if val := get("CAI_SMALL_CHANGE_MODEL"); val != "" {
+		c.SmallChangeModel = val
+	}
+	if val := get("CAI_LARGE_CHANGE_LINES"); val != "" {
+		if lines, err := strconv.Atoi(val); err == nil {
+			c.LargeChangeLines = lines
+		}
+	}
+	if val := get("CAI_PROMPT_VARIANT"); val != "" {
+		c.PromptVariant = val
+	}
+	if val := get("CAI_OUTPUT_CONTRACT"); val != "" {
+		c.OutputContract = val
+	}
+	if val := get("CAI_NORMALIZE"); val != "" {
+		c.Normalize = splitList(val)
+	}
+	if val := get("CAI_DEBUG"); val != "" {
+		if debug, err := strconv.ParseBool(val); err == nil {
+			c.Debug = debug
+		}
+	}
+	if val := get("CAI_PLAIN_DIFF"); val != "" {
+		if plain, err := strconv.ParseBool(val); err == nil {
+			c.PlainDiff = plain
+		}
+	}
+	if val := get("CAI_SKIP_LFS"); val != "" {
+		if skip, err := strconv.ParseBool(val); err == nil {
+			c.SkipLFS = skip
+		}
+	}
+	if val := get("CAI_CONTEXT_CMD"); val != "" {
+		c.ContextCmd = val
+	}
+	if val := get("CAI_STANDUP_REPOS"); val != "" {
+		c.StandupRepos = splitList(val)
+	}
+	if val := get("CAI_TEMPLATE_ENV"); val != "" {
+		c.TemplateEnv = splitList(val)
+	}
+	if val := get("CAI_FORBIDDEN_PATTERNS"); val != "" {
+		c.ForbiddenPatterns = splitList(val)
+	}
+	if val := get("CAI_REMOTE_CONFIG_URL"); val != "" {
+		c.RemoteConfigURL = val
+	}
+	if val := get("CAI_REMOTE_CONFIG_PUBKEY"); val != "" {
+		c.RemoteConfigPublicKey = val
+	}
+	if val := get("CAI_REMOTE_CONFIG_TTL"); val != "" {
+		if ttl, err := strconv.Atoi(val); err == nil && ttl >= 0 {
+			c.RemoteConfigTTLSeconds = ttl
+		}
+	}
+	if val := get("CAI_AUDIT_LOG"); val != "" {
+		c.AuditLog = val
+	}
+	if val := get("CAI_AUDIT_LOG_MAX_MB"); val != "" {
+		if maxMB, err := strconv.Atoi(val); err == nil && maxMB >= 0 {
+			c.AuditLogMaxMB = maxMB
+		}
+	}
+	if val := get("CAI_AUDIT_LOG_MAX_FILES"); val != "" {
+		if maxFiles, err := strconv.Atoi(val); err == nil && maxFiles >= 0 {
+			c.AuditLogMaxFiles = maxFiles
+		}
+	}
+	if val := get("CAI_SERVE_REPOS"); val != "" {
+		c.ServeRepos = splitList(val)
+	}
+	if val := get("CAI_SERVE_TOKEN"); val != "" {
+		c.ServeToken = val
+	}
+	if val := get("CAI_SERVE_MAX_REQUEST_KB"); val != "" {
+		if maxKB, err := strconv.Atoi(val); err == nil {
+			c.ServeMaxRequestKB = maxKB
+		}
+	}
+	if val := get("CAI_SERVE_MAX_CONCURRENT"); val != "" {
+		if maxConcurrent, err := strconv.Atoi(val); err == nil {
+			c.ServeMaxConcurrent = maxConcurrent
+		}
+	}
+	if val := get("CAI_DEPS_USE_LLM"); val != "" {
+		if useLLM, err := strconv.ParseBool(val); err == nil {
+			c.DepsUseLLM = useLLM
+		}
+	}
+	if val := get("CAI_DIFF_CONTEXT_LINES"); val != "" {
+		if lines, err := strconv.Atoi(val); err == nil && lines >= 0 {
+			c.DiffContextLines = lines
+		}
+	}
+}
+
+// ParseAuthor splits a "Name <email>" commit author, as in git's --author
+func ParseAuthor(author string) (name, email string, err error) {
+	match := authorPattern.FindStringSubmatch(strings.TrimSpace(author))
+	if match == nil {
+		return "", "", fmt.Errorf("%q is not in the form \"Name <email>\"", author)
+	}
+	return match[1], match[2], nil
+}
+
+// splitList splits a comma-separated environment value, dropping empty items
+func splitList(val string) []string {
+	var items []string
+	for _, item := range strings.Split(val, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// PrimaryLanguage returns the language commit messages are generated in
+func (c *Config) PrimaryLanguage() string {
+	if len(c.Languages) > 0 {
+		return c.Languages[0]
+	}
+	return c.Language
+}
+
+// TranslationLanguages returns the languages the generated message is
+// translated into, in order
+func (c *Config) TranslationLanguages() []string {
+	if len(c.Languages) < 2 {
+		return nil
+	}
+	return c.Languages[1:]
+}
+
+// IsProtectedBranch reports whether branch matches one of the protected
+// branch patterns
+func (c *Config) IsProtectedBranch(branch string) bool {
+	if branch == "" {
+		return false
+	}
+	for _, pattern := range c.ProtectedBranches {
+		if matched, err := path.Match(pattern, branch); err == nil && matched {
+			return true
+		}
+	}
+	return false
+}
+
+// ForbiddenMatches returns the text of message matched by each of the
+// CAI_FORBIDDEN_PATTERNS, without duplicates
+func (c *Config) ForbiddenMatches(message string) []string {
+	var matches []string
+	for _, pattern := range c.ForbiddenPatterns {
+		re, err := regexp.Compile("(?i)" + pattern)
+		if err != nil {
+			continue
+		}
+		if match := re.FindString(message); match != "" && !slices.Contains(matches, match) {
+			matches = append(matches, match)
+		}
+	}
+	return matches
+}
+
+// OversizedCommit describes how a change of the given number of files and
+// changed lines exceeds CAI_MAX_COMMIT_FILES or CAI_MAX_COMMIT_LINES, e.g.
+// "73 files (limit 50)", or returns "" when it doesn't
+func (c *Config) OversizedCommit(files, lines int) string {
+	var reasons []string
+	if c.MaxCommitFiles > 0 && files > c.MaxCommitFiles {
+		reasons = append(reasons, fmt.Sprintf("%d files (limit %d)", files, c.MaxCommitFiles))
+	}
+	if c.MaxCommitLines > 0 && lines > c.MaxCommitLines {
+		reasons = append(reasons, fmt.Sprintf("%d changed lines (limit %d)", lines, c.MaxCommitLines))
+	}
+	return strings.Join(reasons, " and ")
+}
+
+// ParseAuthScheme splits an auth scheme into its kind (AuthBearer, AuthBasic,
+// AuthHeader or AuthQuery) and, for the header and query kinds, the header or
+// query parameter name. An empty scheme means bearer.
+func ParseAuthScheme(scheme string) (kind, name string, err error) {
+	kind, name, _ = strings.Cut(strings.TrimSpace(scheme), ":")
+	kind = strings.ToLower(kind)
+
+	switch kind {
+	case "", AuthBearer:
+		return AuthBearer, "", nil
+	case AuthBasic:
+		return AuthBasic, "", nil
+	case AuthHeader, AuthQuery:
+		if name == "" {
+			return "", "", fmt.Errorf("%s auth scheme needs a name, e.g. %s:api-key", kind, kind)
+		}
+		return kind, name, nil
+	default:
+		return "", "", fmt.Errorf("unknown auth scheme %q. Supported schemes: bearer, basic, header:<name>, query:<name>", scheme)
+	}
+}
+
+// ActiveProvider returns the effective provider settings. The flat CAI_*
+// keys form the base, the [providers.*] section selected by CAI_PROFILE (or
+// named after CAI_PROVIDER) overrides them, and CAI_* environment variables
+// override both.
+func (c *Config) ActiveProvider() ProviderSettings {
+	settings := c.flatProvider()
+
+	name := c.Profile
+	if name == "" {
+		name = c.Provider
+	}
+	if section, ok := c.Providers[name]; ok {
+		settings = settings.merge(section)
+	}
+	// Within each level a section overrides the flat keys, and project
+	// settings override global ones
+	settings = settings.merge(c.projectProvider)
+	if section, ok := c.projectProviders[name]; ok {
+		settings = settings.merge(section)
+	}
+
+	return c.resolveProvider(settings.merge(c.envProvider).merge(ProviderSettings{Model: c.routeModel}))
+}
+
+// ProviderProfile returns the settings of the [providers.<name>] section on
+// top of the flat CAI_* keys, and whether the section exists. Unlike
+// ActiveProvider, environment overrides don't apply, so a fallback profile
+// keeps its own endpoint.
+func (c *Config) ProviderProfile(name string) (ProviderSettings, bool) {
+	section, ok := c.Providers[name]
+	if !ok {
+		return ProviderSettings{}, false
+	}
+	return c.resolveProvider(c.flatProvider().merge(section)), true
+}
+
+// flatProvider returns the provider settings of the flat CAI_* keys
+func (c *Config) flatProvider() ProviderSettings {
+	return ProviderSettings{
+		Provider:   c.Provider,
+		URL:        c.APIURL,
+		Token:      c.APIToken,
+		Model:      c.Model,
+		AuthScheme: c.AuthScheme,
+	}
+}
+
+// BranchTemplateFor returns the first branch template whose pattern matches
+// the branch name, or nil if none matches
+func (c *Config) BranchTemplateFor(branch string) *BranchTemplate {
+	if branch == "" {
+		return nil
+	}
+	for i := range c.BranchTemplates {
+		if matched, err := path.Match(c.BranchTemplates[i].Pattern, branch); err == nil && matched {
+			return &c.BranchTemplates[i]
+		}
+	}
+	return nil
+}
+
+// EffectiveTicketProvider returns the configured ticket provider. When
+// CAI_TICKET_PROVIDER is unset it is inferred from the legacy CAI_JIRA_URL and
+// CAI_GITHUB_ISSUES settings; an empty result disables ticket context.
+func (c *Config) EffectiveTicketProvider() string {
+	if c.TicketProvider != "" {
+		return c.TicketProvider
+	}
+	if c.JiraURL != "" {
+		return "jira"
+	}
+	if c.GitHubIssues {
+		return "github"
+	}
+	return ""
+}
+
+// validateTunnel checks CAI_PROXY and CAI_SSH_JUMP_HOST
+func (c *Config) validateTunnel(v *validation) {
+	if c.Proxy != "" && c.SSHJumpHost != "" {
+		v.add("CAI_PROXY", fmt.Errorf("CAI_PROXY and CAI_SSH_JUMP_HOST cannot both be set"))
+		return
+	}
+	if c.Proxy != "" {
+		u, err := url.Parse(c.Proxy)
+		if err != nil || u.Host == "" {
+			v.add("CAI_PROXY", fmt.Errorf("invalid CAI_PROXY: %q", c.Proxy))
+		} else {
+			switch u.Scheme {
+			case "http", "https", "socks5", "socks5h":
+			default:
+				v.add("CAI_PROXY", fmt.Errorf("invalid CAI_PROXY scheme: %s. Supported schemes: http, https, socks5, socks5h", u.Scheme))
+			}
+		}
+	}
+	// A leading dash would be read as an ssh option
+	if strings.HasPrefix(c.SSHJumpHost, "-") || strings.ContainsAny(c.SSHJumpHost, " \t\n") {
+		v.add("CAI_SSH_JUMP_HOST", fmt.Errorf("invalid CAI_SSH_JUMP_HOST: %q", c.SSHJumpHost))
+	}
+}
+
+// checkAllowedProvider rejects a provider that CAI_ALLOWED_PROVIDERS doesn't
+// list. Presets are matched by their own name, so allowing "openai" doesn't
+// allow "deepseek". An allowed ollama provider must run on this machine:
+// allowing it is meant to keep the code local, not to permit any Ollama
+// server.
+func (c *Config) checkAllowedProvider(settings ProviderSettings) error {
+	if c.AllowedProviders == nil {
+		return nil
+	}
+	for _, name := range c.AllowedProviders {
+		if _, ok := providerPresets[name]; !ok && !validProviders[name] {
+			return fmt.Errorf("invalid provider in CAI_ALLOWED_PROVIDERS: %s. Supported providers: %s", name, supportedProviders())
+		}
+	}
+
+	name := settings.Provider
+	if settings.Preset != "" {
+		name = settings.Preset
+	}
+	if slices.Contains(c.AllowedProviders, name) {
+		if name != providerOllama {
+			return nil
+		}
+		if !isLoopbackURL(settings.URL) {
+			return fmt.Errorf("provider ollama at %s is not allowed in this repository: CAI_ALLOWED_PROVIDERS only allows it on localhost or a loopback address", settings.URL)
+		}
+		if c.SSHJumpHost != "" {
+			return fmt.Errorf("provider ollama through CAI_SSH_JUMP_HOST %s is not allowed in this repository: CAI_ALLOWED_PROVIDERS only allows it on this machine", c.SSHJumpHost)
+		}
+		return nil
+	}
+	if len(c.AllowedProviders) == 0 {
+		return fmt.Errorf("provider %s is not allowed in this repository: CAI_ALLOWED_PROVIDERS allows no provider", name)
+	}
+	return fmt.Errorf("provider %s is not allowed in this repository; CAI_ALLOWED_PROVIDERS only allows %s", name, strings.Join(c.AllowedProviders, ", "))
+}
+
+// isLoopbackURL reports whether rawURL points at localhost or a loopback
+// address
+func isLoopbackURL(rawURL string) bool {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return false
+	}
+	host := u.Hostname()
+	if strings.EqualFold(host, "localhost") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// narrowProviders applies a project's provider allow-list on top of the one
+// already in effect, keeping only providers both allow
+func narrowProviders(current, project []string) []string {
+	if current == nil {
+		return project
+	}
+	narrowed := []string{}
+	for _, name := range project {
+		if slices.Contains(current, name) {
+			narrowed = append(narrowed, name)
+		}
+	}
+	return narrowed
+}
+
+// AuditLogPath returns the path of the audit log, with a leading ~ expanded
+// and relative paths resolved against the global config directory. It is
+// empty when the audit log is disabled.
+func (c *Config) AuditLogPath(configFile string) (string, error) {
+	if c.AuditLog == "" {
+		return "", nil
+	}
+	return resolveIncludePath(c.AuditLog, filepath.Dir(configFile))
+}
+
+// LocalModelPath returns the path of the GGUF model file for the local
+// provider, with a leading ~ expanded
+func (c *Config) LocalModelPath() (string, error) {
+	if c.ModelPath == "" {
+		return "", fmt.Errorf("CAI_MODEL_PATH is not set")
+	}
+	return resolveIncludePath(c.ModelPath, ".")
+}
+
+// ReplayFixturesPath returns the fixture file of the replay provider and
+// CAI_RECORD, with a leading ~ expanded. Relative paths are relative to the
+// repository root, or to the working directory outside a repository.
+func (c *Config) ReplayFixturesPath() (string, error) {
+	if c.ReplayFixtures == "" {
+		return "", fmt.Errorf("CAI_REPLAY_FIXTURES is not set")
+	}
+	base := c.RepoRoot()
+	if base == "" {
+		base = "."
+	}
+	return resolveIncludePath(c.ReplayFixtures, base)
+}
+
+// RepoRoot returns the root of the git repository the configuration was
+// loaded for, or an empty string outside a repository
+func (c *Config) RepoRoot() string {
+	return c.repoRoot
 }
 
 // GetPromptTemplatePath returns the full path to the prompt template file.
-// It first checks for the template in the current working directory (project-local),
-// then falls back to the global config directory.
+// Absolute paths are used as is. Paths starting with "./" are relative to the
+// repository root, and other relative paths declared in a project .commitai
+// file are relative to that file. Remaining relative paths are looked up in
+// the current working directory first, then in the global config directory.
 func (c *Config) GetPromptTemplatePath(configFile string) string {
 	// Check if template path is absolute
 	if filepath.IsAbs(c.PromptTemplate) {
 		return c.PromptTemplate
 	}
 
+	if c.repoRoot != "" && isRepoRelative(c.PromptTemplate) {
+		return filepath.Join(c.repoRoot, c.PromptTemplate)
+	}
+	if c.promptTemplateDir != "" {
+		return filepath.Join(c.promptTemplateDir, c.PromptTemplate)
+	}
+
 	// First, check if template exists in current working directory (project-local)
 	if currentDir, err := os.Getwd(); err == nil {
 		projectTemplatePath := filepath.Join(currentDir, c.PromptTemplate)
@@ -370,37 +1739,222 @@ func (c *Config) GetPromptTemplatePath(configFile string) string {
 	return filepath.Join(configDir, c.PromptTemplate)
 }
 
+// isRepoRelative reports whether a template path is written relative to the
+// repository root, e.g. ./prompts/commit.txt
+func isRepoRelative(templatePath string) bool {
+	return strings.HasPrefix(templatePath, "./") || strings.HasPrefix(templatePath, "."+string(filepath.Separator))
+}
+
+// validatePromptTemplatePath rejects repository and project relative template
+// paths that resolve outside the repository
+func (c *Config) validatePromptTemplatePath() error {
+	if filepath.IsAbs(c.PromptTemplate) {
+		return nil
+	}
+
+	root := c.repoRoot
+	if root == "" {
+		root = c.promptTemplateDir
+	}
+	anchored := (c.repoRoot != "" && isRepoRelative(c.PromptTemplate)) || c.promptTemplateDir != ""
+	if !anchored || root == "" {
+		return nil
+	}
+
+	rel, err := filepath.Rel(root, c.GetPromptTemplatePath(""))
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return fmt.Errorf("path traversal detected in CAI_PROMPT_TEMPLATE: %s resolves outside %s", c.PromptTemplate, root)
+	}
+	return nil
+}
+
 // Validate validates the configuration
 func (c *Config) Validate() error {
-	if c.APIURL == "" {
-		return fmt.Errorf("CAI_API_URL cannot be empty")
+	v := &validation{}
+
+	v.check(c.APIURL != "", "CAI_API_URL", "CAI_API_URL cannot be empty")
+	v.check(c.Model != "", "CAI_MODEL", "CAI_MODEL cannot be empty")
+	v.check(c.Provider != "", "CAI_PROVIDER", "CAI_PROVIDER cannot be empty")
+	v.check(c.Language != "", "CAI_LANGUAGE", "CAI_LANGUAGE cannot be empty")
+	v.check(!slices.ContainsFunc(c.Languages, func(lang string) bool { return strings.TrimSpace(lang) == "" }),
+		"CAI_LANGUAGES", "CAI_LANGUAGES cannot contain empty entries")
+	if c.PromptTemplate == "" {
+		v.add("CAI_PROMPT_TEMPLATE", fmt.Errorf("CAI_PROMPT_TEMPLATE cannot be empty"))
+	} else {
+		v.add("CAI_PROMPT_TEMPLATE", c.validatePromptTemplatePath())
 	}
-	if c.Model == "" {
-		return fmt.Errorf("CAI_MODEL cannot be empty")
+	v.check(c.TimeoutSeconds >= 0, "CAI_TIMEOUT_SECONDS", "CAI_TIMEOUT_SECONDS cannot be negative")
+
+	// Validate provider; the checks of the active provider only apply to a
+	// known one
+	if c.Profile != "" {
+		_, ok := c.Providers[c.Profile]
+		v.check(ok, "CAI_PROFILE", "profile %s has no [providers.%s] section", c.Profile, c.Profile)
 	}
-	if c.Provider == "" {
-		return fmt.Errorf("CAI_PROVIDER cannot be empty")
+	switch provider := c.ActiveProvider().Provider; {
+	case v.has("CAI_PROVIDER"):
+		// An empty provider is already reported
+	case !validProviders[provider]:
+		v.add("CAI_PROVIDER", fmt.Errorf("invalid provider: %s. Supported providers: %s", provider, supportedProviders()))
+	default:
+		v.add("CAI_PROVIDER", validatePreset(c.ActiveProvider()))
+		v.add("CAI_ALLOWED_PROVIDERS", c.checkAllowedProvider(c.ActiveProvider()))
+		c.validateVertex(v)
+		v.check(provider != providerLocal || c.ModelPath != "", "CAI_MODEL_PATH", "CAI_MODEL_PATH is required when using the local provider")
+		v.check(provider != providerReplay || c.ReplayFixtures != "", "CAI_REPLAY_FIXTURES", "CAI_REPLAY_FIXTURES is required when using the replay provider")
+		v.check(!c.Record || provider != providerReplay, "CAI_RECORD", "CAI_RECORD records the responses of another provider and can't be used with replay")
 	}
-	if c.Language == "" {
-		return fmt.Errorf("CAI_LANGUAGE cannot be empty")
+	if c.Record && !v.has("CAI_REPLAY_FIXTURES") {
+		v.check(c.ReplayFixtures != "", "CAI_REPLAY_FIXTURES", "CAI_REPLAY_FIXTURES is required with CAI_RECORD")
 	}
-	if c.PromptTemplate == "" {
-		return fmt.Errorf("CAI_PROMPT_TEMPLATE cannot be empty")
+	for _, name := range slices.Sorted(maps.Keys(c.Providers)) {
+		if _, _, err := ParseAuthScheme(c.Providers[name].AuthScheme); err != nil {
+			v.add("providers."+name+".auth_scheme", fmt.Errorf("invalid auth_scheme in [providers.%s]: %w", name, err))
+		}
+	}
+	if _, _, err := ParseAuthScheme(c.ActiveProvider().AuthScheme); err != nil {
+		v.add("CAI_AUTH_SCHEME", fmt.Errorf("invalid CAI_AUTH_SCHEME: %w", err))
+	}
+	if c.FallbackProfile != "" {
+		v.add("CAI_FALLBACK_PROFILE", c.validateFallback())
+	}
+	v.check(c.BreakerThreshold >= 0 && c.BreakerCooldownSeconds >= 0, "CAI_BREAKER_THRESHOLD", "circuit breaker settings cannot be negative")
+	c.validateTunnel(v)
+	v.check(c.RemoteConfigTTLSeconds >= 0, "CAI_REMOTE_CONFIG_TTL", "CAI_REMOTE_CONFIG_TTL cannot be negative")
+	v.check(c.AuditLogMaxMB >= 0 && c.AuditLogMaxFiles >= 0, "CAI_AUDIT_LOG_MAX_MB", "audit log rotation settings cannot be negative")
+	v.check(c.ServeMaxRequestKB >= 0 && c.ServeMaxConcurrent >= 0, "CAI_SERVE_MAX_REQUEST_KB", "serve limits cannot be negative")
+
+	// Validate branch template patterns
+	for _, bt := range c.BranchTemplates {
+		if _, err := path.Match(bt.Pattern, ""); err != nil || bt.Pattern == "" {
+			v.add("CAI_BRANCH_TEMPLATES", fmt.Errorf("invalid branch template pattern: %q", bt.Pattern))
+		}
+	}
+
+	v.check(c.SmallChangeLines >= 0, "CAI_SMALL_CHANGE_LINES", "CAI_SMALL_CHANGE_LINES cannot be negative")
+	v.check(c.LargeChangeLines >= 0, "CAI_LARGE_CHANGE_LINES", "CAI_LARGE_CHANGE_LINES cannot be negative")
+	switch c.PromptVariant {
+	case "", PromptVariantAuto, PromptVariantTiny, PromptVariantMedium, PromptVariantLarge:
+	default:
+		v.add("CAI_PROMPT_VARIANT", fmt.Errorf("invalid prompt variant: %s. Supported values: auto, tiny, medium, large", c.PromptVariant))
+	}
+	v.check(c.Temperature == nil || (*c.Temperature >= 0 && *c.Temperature <= 2), "CAI_TEMPERATURE", "CAI_TEMPERATURE must be between 0 and 2")
+	if c.BotAuthor != "" {
+		if _, _, err := ParseAuthor(c.BotAuthor); err != nil {
+			v.add("CAI_BOT_AUTHOR", fmt.Errorf("invalid CAI_BOT_AUTHOR: %w", err))
+		}
+	}
+	for _, name := range c.TemplateEnv {
+		v.check(envName.MatchString(name), "CAI_TEMPLATE_ENV", "invalid CAI_TEMPLATE_ENV entry %q: must be an environment variable name", name)
+		v.check(!secretEnvName.MatchString(name), "CAI_TEMPLATE_ENV", "invalid CAI_TEMPLATE_ENV entry %q: the name looks like a secret, which must not be sent to the provider", name)
+	}
+	for _, pattern := range c.ForbiddenPatterns {
+		if _, err := regexp.Compile("(?i)" + pattern); err != nil {
+			v.add("CAI_FORBIDDEN_PATTERNS", fmt.Errorf("invalid CAI_FORBIDDEN_PATTERNS entry %q: %w", pattern, err))
+		}
+	}
+	v.add("CAI_MODEL_ROUTES", c.validateModelRoutes())
+
+	// Validate protected branch settings
+	switch c.ProtectedBranchMode {
+	case "", ProtectedBranchRefuse, ProtectedBranchWarn, ProtectedBranchOff:
+	default:
+		v.add("CAI_PROTECTED_BRANCH_MODE", fmt.Errorf("invalid protected branch mode: %s. Supported modes: refuse, warn, off", c.ProtectedBranchMode))
+	}
+	for _, pattern := range c.ProtectedBranches {
+		if _, err := path.Match(pattern, ""); err != nil {
+			v.add("CAI_PROTECTED_BRANCHES", fmt.Errorf("invalid protected branch pattern: %q", pattern))
+		}
 	}
 
-	// Validate provider
-	validProviders := map[string]bool{
-		providerOllama: true,
-		providerOpenAI: true,
+	// Validate oversized commit settings
+	switch c.OversizedCommitMode {
+	case "", OversizedCommitWarn, OversizedCommitRefuse, OversizedCommitOff:
+	default:
+		v.add("CAI_OVERSIZED_COMMIT_MODE", fmt.Errorf("invalid oversized commit mode: %s. Supported modes: warn, refuse, off", c.OversizedCommitMode))
 	}
-	if !validProviders[c.Provider] {
-		return fmt.Errorf("invalid provider: %s. Supported providers: ollama, openai", c.Provider)
+	v.check(c.MaxCommitFiles >= 0 && c.MaxCommitLines >= 0, "CAI_MAX_COMMIT_FILES", "oversized commit limits cannot be negative")
+
+	v.check(c.RateLimitRPM >= 0 && c.RateLimitTPM >= 0, "CAI_RATE_LIMIT_RPM", "rate limits cannot be negative")
+
+	switch c.PromptGuard {
+	case "", PromptGuardOff, PromptGuardWarn, PromptGuardStrip:
+	default:
+		v.add("CAI_PROMPT_GUARD", fmt.Errorf("invalid prompt guard mode: %s. Supported modes: warn, strip, off", c.PromptGuard))
+	}
+
+	switch c.OutputContract {
+	case "", OutputContractStrict, OutputContractOff:
+	default:
+		v.add("CAI_OUTPUT_CONTRACT", fmt.Errorf("invalid output contract: %s. Supported values: strict, off", c.OutputContract))
+	}
+
+	c.validateNormalize(v)
+
+	switch c.Attribution {
+	case "", AttributionOff, AttributionCoAuthor, AttributionAssisted:
+	default:
+		v.add("CAI_ATTRIBUTION", fmt.Errorf("invalid attribution: %s. Supported values: co-author, assisted, off", c.Attribution))
+	}
+
+	// Validate diff compression settings
+	v.check(c.DiffContextLines >= 0, "CAI_DIFF_CONTEXT_LINES", "CAI_DIFF_CONTEXT_LINES cannot be negative")
+	for _, rw := range c.DiffRewrites {
+		if _, err := regexp.Compile(rw.Pattern); err != nil {
+			v.add("CAI_DIFF_REWRITES", fmt.Errorf("invalid diff rewrite pattern %q: %w", rw.Pattern, err))
+		}
+	}
+
+	// Validate ticket provider
+	validTicketProviders := map[string]bool{
+		"":       true,
+		"none":   true,
+		"jira":   true,
+		"github": true,
+		"gitlab": true,
+		"linear": true,
+	}
+	if !validTicketProviders[c.TicketProvider] {
+		v.add("CAI_TICKET_PROVIDER", fmt.Errorf("invalid ticket provider: %s. Supported ticket providers: jira, github, gitlab, linear, none", c.TicketProvider))
+	} else {
+		v.check(c.EffectiveTicketProvider() != "jira" || c.JiraURL != "", "CAI_JIRA_URL", "CAI_JIRA_URL is required when using the Jira ticket provider")
 	}
 
 	// If using OpenAI, API token is required
-	if c.Provider == providerOpenAI && c.APIToken == "" {
-		return fmt.Errorf("CAI_API_TOKEN is required when using OpenAI provider")
+	v.check(!c.RequiresAPIToken() || c.APIToken != "", "CAI_API_TOKEN", "CAI_API_TOKEN is required when using OpenAI provider")
+
+	return v.err()
+}
+
+// validateNormalize checks the CAI_NORMALIZE rules, of which lowercasing and
+// capitalizing the subject exclude each other
+func (c *Config) validateNormalize(v *validation) {
+	for _, rule := range c.Normalize {
+		switch rule {
+		case NormalizeLowercaseAfterType, NormalizeCapitalizeFirstWord, NormalizeStripTrailingPeriod:
+		default:
+			v.add("CAI_NORMALIZE", fmt.Errorf("invalid normalization rule: %s. Supported rules: %s, %s, %s", rule,
+				NormalizeLowercaseAfterType, NormalizeCapitalizeFirstWord, NormalizeStripTrailingPeriod))
+		}
 	}
+	v.check(!slices.Contains(c.Normalize, NormalizeLowercaseAfterType) || !slices.Contains(c.Normalize, NormalizeCapitalizeFirstWord),
+		"CAI_NORMALIZE", "CAI_NORMALIZE can't both lowercase and capitalize the subject")
+}
 
+// validateFallback checks the profile named by CAI_FALLBACK_PROFILE
+func (c *Config) validateFallback() error {
+	fallback, ok := c.ProviderProfile(c.FallbackProfile)
+	if !ok {
+		return fmt.Errorf("fallback profile %s has no [providers.%s] section", c.FallbackProfile, c.FallbackProfile)
+	}
+	if !validProviders[fallback.Provider] {
+		return fmt.Errorf("invalid fallback provider: %s. Supported providers: %s", fallback.Provider, supportedProviders())
+	}
+	if err := validatePreset(fallback); err != nil {
+		return fmt.Errorf("invalid fallback profile %s: %w", c.FallbackProfile, err)
+	}
+	if err := c.checkAllowedProvider(fallback); err != nil {
+		return fmt.Errorf("invalid fallback profile %s: %w", c.FallbackProfile, err)
+	}
 	return nil
 }
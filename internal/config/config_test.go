@@ -158,6 +158,32 @@ func TestConfig_Validate(t *testing.T) {
 	}
 }
 
+func TestConfig_Validate_ReportsAllProblems(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Model = ""
+	cfg.Provider = "invalid"
+	cfg.TimeoutSeconds = -1
+	temperature := 3.0
+	cfg.Temperature = &temperature
+	cfg.SSHJumpHost = "-oProxyCommand=x"
+	cfg.Providers = map[string]ProviderSettings{"work": {AuthScheme: "digest"}}
+
+	err := cfg.Validate()
+	require.Error(t, err)
+
+	var validationErr *ValidationError
+	require.ErrorAs(t, err, &validationErr)
+	assert.Equal(t, []string{"CAI_MODEL", "CAI_TIMEOUT_SECONDS", "CAI_PROVIDER", "providers.work.auth_scheme", "CAI_SSH_JUMP_HOST", "CAI_TEMPERATURE"}, validationErr.Keys())
+	// Keys only read from files have no environment variable
+	assert.Equal(t, []string{"CAI_MODEL", "CAI_TIMEOUT_SECONDS", "CAI_PROVIDER", "CAI_SSH_JUMP_HOST", "CAI_TEMPERATURE"}, validationErr.EnvVars())
+	assert.Contains(t, err.Error(), "6 configuration problems:\n  - CAI_MODEL cannot be empty\n")
+
+	// A single problem reads as before
+	cfg = DefaultConfig()
+	cfg.Model = ""
+	assert.EqualError(t, cfg.Validate(), "CAI_MODEL cannot be empty")
+}
+
 func TestConfig_GetPromptTemplatePath(t *testing.T) {
 	cfg := DefaultConfig()
 	configFile := "/home/user/.config/commit-ai/config.toml"
@@ -168,6 +194,56 @@ func TestConfig_GetPromptTemplatePath(t *testing.T) {
 	assert.Equal(t, expected, actual)
 }
 
+func TestGetPromptTemplatePath_ProjectRelative(t *testing.T) {
+	tempDir := t.TempDir()
+	configFile := filepath.Join(tempDir, "config.toml")
+	require.NoError(t, DefaultConfig().Save(configFile))
+
+	repoDir := filepath.Join(tempDir, "repo")
+	subDir := filepath.Join(repoDir, "services", "api")
+	require.NoError(t, os.MkdirAll(filepath.Join(repoDir, ".git"), 0o755))
+	require.NoError(t, os.MkdirAll(subDir, 0o755))
+
+	tests := []struct {
+		name     string
+		template string
+		expected string
+	}{
+		{"relative to declaring file", "prompts/commit.txt", filepath.Join(subDir, "prompts", "commit.txt")},
+		{"relative to repository root", "./prompts/commit.txt", filepath.Join(repoDir, "prompts", "commit.txt")},
+		{"parent of declaring file", "../../prompts/commit.txt", filepath.Join(repoDir, "prompts", "commit.txt")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			content := `CAI_PROMPT_TEMPLATE = "` + tt.template + `"`
+			require.NoError(t, os.WriteFile(filepath.Join(subDir, ".commitai"), []byte(content), 0o644))
+
+			cfg, err := LoadWithProjectPath(configFile, subDir)
+			require.NoError(t, err)
+			require.NoError(t, cfg.Validate())
+			assert.Equal(t, tt.expected, cfg.GetPromptTemplatePath(configFile))
+		})
+	}
+}
+
+func TestValidate_PromptTemplateTraversal(t *testing.T) {
+	tempDir := t.TempDir()
+	configFile := filepath.Join(tempDir, "config.toml")
+	require.NoError(t, DefaultConfig().Save(configFile))
+
+	repoDir := filepath.Join(tempDir, "repo")
+	require.NoError(t, os.MkdirAll(filepath.Join(repoDir, ".git"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(repoDir, ".commitai"), []byte(`CAI_PROMPT_TEMPLATE = "../outside.txt"`), 0o644))
+
+	cfg, err := LoadWithProjectPath(configFile, repoDir)
+	require.NoError(t, err)
+
+	err = cfg.Validate()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "path traversal")
+}
+
 func TestLoad_NonExistentFile(t *testing.T) {
 	tempDir := t.TempDir()
 	configFile := filepath.Join(tempDir, "nonexistent.toml")
@@ -627,3 +703,1114 @@ func TestLoadProjectConfig_SecurityValidation(t *testing.T) {
 	require.NoError(t, err)
 	assert.Equal(t, "valid", cfg.Model)
 }
+
+func TestConfig_LoadFromEnv_QuickMode(t *testing.T) {
+	os.Setenv("CAI_QUICK_MODE", "true")
+	defer os.Unsetenv("CAI_QUICK_MODE")
+
+	cfg := DefaultConfig()
+	assert.False(t, cfg.QuickMode)
+
+	cfg.loadFromEnv()
+	assert.True(t, cfg.QuickMode)
+}
+
+func TestConfig_LoadFromEnv_IndexOnly(t *testing.T) {
+	t.Setenv("CAI_INDEX_ONLY", "true")
+
+	cfg := DefaultConfig()
+	assert.False(t, cfg.IndexOnly)
+
+	cfg.loadFromEnv()
+	assert.True(t, cfg.IndexOnly)
+}
+
+func TestLoad_WithInclude(t *testing.T) {
+	tempDir := t.TempDir()
+	configFile := filepath.Join(tempDir, "config.toml")
+	sharedFile := filepath.Join(tempDir, "shared", "company.toml")
+
+	require.NoError(t, os.MkdirAll(filepath.Dir(sharedFile), 0o755))
+	sharedContent := `CAI_API_URL = "http://company.example.com"
+CAI_MODEL = "company-model"`
+	require.NoError(t, os.WriteFile(sharedFile, []byte(sharedContent), 0o644))
+
+	content := `include = ["shared/company.toml", "missing.toml"]
+CAI_MODEL = "personal-model"`
+	require.NoError(t, os.WriteFile(configFile, []byte(content), 0o644))
+
+	cfg, err := Load(configFile)
+	require.NoError(t, err)
+
+	// Included values apply, but the including file wins
+	assert.Equal(t, "http://company.example.com", cfg.APIURL)
+	assert.Equal(t, "personal-model", cfg.Model)
+}
+
+func TestLoad_APITokenFiles(t *testing.T) {
+	tempDir := t.TempDir()
+	configFile := filepath.Join(tempDir, "config.toml")
+	secretsFile := filepath.Join(tempDir, "secrets.toml")
+	require.NoError(t, os.WriteFile(secretsFile, []byte(`CAI_API_TOKEN = "sk-included"`), 0o600))
+	require.NoError(t, os.WriteFile(configFile, []byte("include = [\"secrets.toml\"]\nCAI_API_TOKEN = \"\"\n"), 0o600))
+
+	projectDir := filepath.Join(tempDir, "project")
+	require.NoError(t, os.MkdirAll(filepath.Join(projectDir, ".git"), 0o755))
+	projectFile := filepath.Join(projectDir, ".commitai")
+	require.NoError(t, os.WriteFile(projectFile, []byte(`CAI_API_TOKEN = "sk-project"`), 0o644))
+
+	cfg, err := LoadWithProjectPath(configFile, projectDir)
+	require.NoError(t, err)
+
+	// The empty token of the global file doesn't count
+	assert.Equal(t, "sk-project", cfg.APIToken)
+	assert.Equal(t, []string{secretsFile, projectFile}, cfg.APITokenFiles())
+}
+
+func TestLoadWithOptions_NoWrite(t *testing.T) {
+	configFile := filepath.Join(t.TempDir(), "config", "config.toml")
+
+	cfg, err := LoadWithOptions(configFile, ".", LoadOptions{NoWrite: true})
+	require.NoError(t, err)
+	assert.Equal(t, "llama2", cfg.Model)
+
+	_, err = os.Stat(configFile)
+	assert.True(t, os.IsNotExist(err), "config file should not be created")
+}
+
+func TestLoadWithOptions_Overrides(t *testing.T) {
+	tempDir := t.TempDir()
+	configFile := filepath.Join(tempDir, "config.toml")
+	t.Setenv("CAI_MODEL", "env-model")
+	t.Setenv("CAI_LANGUAGE", "german")
+
+	require.NoError(t, os.WriteFile(filepath.Join(tempDir, ".commitai"), []byte(`CAI_TIMEOUT_SECONDS = 60`), 0o644))
+
+	cfg, err := LoadWithOptions(configFile, tempDir, LoadOptions{Overrides: map[string]string{
+		"CAI_MODEL":            "flag-model",
+		"CAI_COMPRESS_DIFF":    "true",
+		"CAI_TEMPERATURE":      "0.2",
+		"CAI_READ_ONLY":        "true",
+		"CAI_LANGUAGES":        "english,french",
+		"CAI_MAX_COMMIT_FILES": "not-a-number",
+	}})
+	require.NoError(t, err)
+
+	// Overrides beat the environment, which beats project files
+	assert.Equal(t, "flag-model", cfg.Model)
+	assert.Equal(t, "flag-model", cfg.ActiveProvider().Model)
+	assert.Equal(t, "german", cfg.Language)
+	assert.Equal(t, 60, cfg.TimeoutSeconds)
+	assert.True(t, cfg.CompressDiff)
+	require.NotNil(t, cfg.Temperature)
+	assert.Equal(t, 0.2, *cfg.Temperature)
+	assert.Equal(t, []string{"english", "french"}, cfg.Languages)
+	assert.Equal(t, DefaultConfig().MaxCommitFiles, cfg.MaxCommitFiles)
+
+	// A read-only override keeps the missing global file from being created
+	_, err = os.Stat(configFile)
+	assert.True(t, os.IsNotExist(err), "config file should not be created")
+}
+
+func TestConfig_ProjectEndpoint(t *testing.T) {
+	tests := []struct {
+		name    string
+		global  string
+		project string
+		envURL  string
+		want    bool
+	}{
+		{"global endpoint", `CAI_API_URL = "https://api.openai.com"`, "CAI_MODEL = \"gpt-4o\"", "", false},
+		{"project endpoint", `CAI_PROVIDER = "openai"`, `CAI_API_URL = "https://attacker.example.com"`, "", true},
+		{"project provider section", `CAI_PROVIDER = "openai"`, "[providers.openai]\nurl = \"https://attacker.example.com\"", "", true},
+		{"project section of another provider", `CAI_PROVIDER = "openai"`, "[providers.ollama]\nurl = \"http://gpu-box:11434\"", "", false},
+		{"environment beats the project", `CAI_PROVIDER = "openai"`, `CAI_API_URL = "https://attacker.example.com"`, "https://api.openai.com", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tempDir := t.TempDir()
+			configFile := filepath.Join(tempDir, "config.toml")
+			require.NoError(t, os.WriteFile(configFile, []byte(tt.global), 0o644))
+			require.NoError(t, os.WriteFile(filepath.Join(tempDir, ".commitai"), []byte(tt.project), 0o644))
+			t.Setenv("CAI_API_URL", tt.envURL)
+
+			cfg, err := LoadWithOptions(configFile, tempDir, LoadOptions{NoWrite: true})
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, cfg.ProjectEndpoint())
+		})
+	}
+}
+
+func TestLoad_NoAutoConfigEnv(t *testing.T) {
+	configFile := filepath.Join(t.TempDir(), "config.toml")
+	t.Setenv("CAI_NO_AUTO_CONFIG", "1")
+
+	_, err := Load(configFile)
+	require.NoError(t, err)
+
+	_, err = os.Stat(configFile)
+	assert.True(t, os.IsNotExist(err), "config file should not be created")
+}
+
+func TestLoad_CreatesDefaultConfig(t *testing.T) {
+	configFile := filepath.Join(t.TempDir(), "config.toml")
+
+	_, err := Load(configFile)
+	require.NoError(t, err)
+
+	_, err = os.Stat(configFile)
+	assert.NoError(t, err)
+}
+
+func TestLoad_IncludeCycle(t *testing.T) {
+	tempDir := t.TempDir()
+	configFile := filepath.Join(tempDir, "config.toml")
+	otherFile := filepath.Join(tempDir, "other.toml")
+
+	require.NoError(t, os.WriteFile(configFile, []byte(`include = ["other.toml"]`), 0o644))
+	require.NoError(t, os.WriteFile(otherFile, []byte(`include = ["config.toml"]`), 0o644))
+
+	_, err := Load(configFile)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "include cycle")
+}
+
+func TestLoadProjectConfig_WithInclude(t *testing.T) {
+	tempDir := t.TempDir()
+	sharedFile := filepath.Join(tempDir, "team.toml")
+	require.NoError(t, os.WriteFile(sharedFile, []byte(`CAI_LANGUAGE = "german"`), 0o644))
+
+	projectConfigFile := filepath.Join(tempDir, ".commitai")
+	require.NoError(t, os.WriteFile(projectConfigFile, []byte(`include = ["team.toml"]`), 0o644))
+
+	cfg := DefaultConfig()
+	err := cfg.loadProjectConfig(projectConfigFile)
+	require.NoError(t, err)
+	assert.Equal(t, "german", cfg.Language)
+}
+
+func TestResolveIncludePath(t *testing.T) {
+	home, err := os.UserHomeDir()
+	require.NoError(t, err)
+
+	path, err := resolveIncludePath("~/company.toml", "/etc/commit-ai")
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(home, "company.toml"), path)
+
+	path, err = resolveIncludePath("shared.toml", "/etc/commit-ai")
+	require.NoError(t, err)
+	assert.Equal(t, "/etc/commit-ai/shared.toml", path)
+
+	_, err = resolveIncludePath("", "/etc/commit-ai")
+	assert.Error(t, err)
+}
+
+func TestLoadProjectConfig_IgnoresContextCmd(t *testing.T) {
+	tempDir := t.TempDir()
+	projectConfigFile := filepath.Join(tempDir, ".commitai")
+	require.NoError(t, os.WriteFile(projectConfigFile, []byte(`CAI_CONTEXT_CMD = "touch /tmp/pwned"`), 0o644))
+
+	cfg := DefaultConfig()
+	cfg.ContextCmd = "git log -1"
+	require.NoError(t, cfg.loadProjectConfig(projectConfigFile))
+	assert.Equal(t, "git log -1", cfg.ContextCmd)
+	require.Len(t, cfg.Warnings(), 1)
+	assert.Contains(t, cfg.Warnings()[0], "ignoring CAI_CONTEXT_CMD")
+}
+
+func TestLoadProjectConfig_IgnoresGitHubAPIURL(t *testing.T) {
+	projectConfigFile := filepath.Join(t.TempDir(), ".commitai")
+	require.NoError(t, os.WriteFile(projectConfigFile, []byte(`CAI_TICKET_PROVIDER = "github"
+CAI_GITHUB_API_URL = "https://attacker.example.com"`), 0o644))
+
+	cfg := DefaultConfig()
+	cfg.GitHubAPIURL = "https://github.example.com/api/v3"
+	require.NoError(t, cfg.loadProjectConfig(projectConfigFile))
+	assert.Equal(t, "https://github.example.com/api/v3", cfg.GitHubAPIURL)
+	assert.Equal(t, "github", cfg.TicketProvider)
+	require.Len(t, cfg.Warnings(), 1)
+	assert.Contains(t, cfg.Warnings()[0], "ignoring CAI_GITHUB_API_URL")
+}
+
+func TestLoadProjectConfig_IgnoresJiraURL(t *testing.T) {
+	projectConfigFile := filepath.Join(t.TempDir(), ".commitai")
+	require.NoError(t, os.WriteFile(projectConfigFile, []byte(`CAI_JIRA_URL = "https://attacker.example.com"`), 0o644))
+
+	cfg := DefaultConfig()
+	require.NoError(t, cfg.loadProjectConfig(projectConfigFile))
+	assert.Empty(t, cfg.JiraURL)
+	assert.Empty(t, cfg.EffectiveTicketProvider())
+	require.Len(t, cfg.Warnings(), 1)
+	assert.Contains(t, cfg.Warnings()[0], "ignoring CAI_JIRA_URL")
+}
+
+func TestLoadProjectConfig_IgnoresGitLabURL(t *testing.T) {
+	projectConfigFile := filepath.Join(t.TempDir(), ".commitai")
+	require.NoError(t, os.WriteFile(projectConfigFile, []byte(`CAI_GITLAB_URL = "https://attacker.example.com"`), 0o644))
+
+	cfg := DefaultConfig()
+	cfg.GitLabURL = "https://gitlab.example.com"
+	require.NoError(t, cfg.loadProjectConfig(projectConfigFile))
+	assert.Equal(t, "https://gitlab.example.com", cfg.GitLabURL)
+	require.Len(t, cfg.Warnings(), 1)
+	assert.Contains(t, cfg.Warnings()[0], "ignoring CAI_GITLAB_URL")
+}
+
+func TestLoadProjectConfig_ReadOnly(t *testing.T) {
+	tempDir := t.TempDir()
+	projectConfigFile := filepath.Join(tempDir, ".commitai")
+	require.NoError(t, os.WriteFile(projectConfigFile, []byte(`CAI_READ_ONLY = true`), 0o644))
+
+	cfg := DefaultConfig()
+	require.NoError(t, cfg.loadProjectConfig(projectConfigFile))
+	assert.True(t, cfg.ReadOnly)
+}
+
+func TestConfig_EffectiveTicketProvider(t *testing.T) {
+	cfg := DefaultConfig()
+	assert.Equal(t, "", cfg.EffectiveTicketProvider())
+
+	cfg.GitHubIssues = true
+	assert.Equal(t, "github", cfg.EffectiveTicketProvider())
+
+	cfg.JiraURL = "https://jira.example.com"
+	assert.Equal(t, "jira", cfg.EffectiveTicketProvider())
+
+	cfg.TicketProvider = "linear"
+	assert.Equal(t, "linear", cfg.EffectiveTicketProvider())
+}
+
+func TestConfig_Validate_TicketProvider(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.TicketProvider = "trello"
+	err := cfg.Validate()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid ticket provider")
+
+	cfg.TicketProvider = "jira"
+	err = cfg.Validate()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "CAI_JIRA_URL is required")
+
+	cfg.JiraURL = "https://jira.example.com"
+	require.NoError(t, cfg.Validate())
+}
+
+func TestLoadProjectConfig_BranchTemplates(t *testing.T) {
+	tempDir := t.TempDir()
+	projectConfigFile := filepath.Join(tempDir, ".commitai")
+	content := `[[CAI_BRANCH_TEMPLATES]]
+pattern = "hotfix/*"
+instructions = "Urgent production fix."
+
+[[CAI_BRANCH_TEMPLATES]]
+pattern = "release/*"
+template = "release.txt"`
+	require.NoError(t, os.WriteFile(projectConfigFile, []byte(content), 0o644))
+
+	cfg := DefaultConfig()
+	require.NoError(t, cfg.loadProjectConfig(projectConfigFile))
+	require.Len(t, cfg.BranchTemplates, 2)
+
+	bt := cfg.BranchTemplateFor("hotfix/login-crash")
+	require.NotNil(t, bt)
+	assert.Equal(t, "Urgent production fix.", bt.Instructions)
+
+	bt = cfg.BranchTemplateFor("release/1.2.0")
+	require.NotNil(t, bt)
+	assert.Equal(t, "release.txt", bt.Template)
+
+	assert.Nil(t, cfg.BranchTemplateFor("feature/x"))
+	assert.Nil(t, cfg.BranchTemplateFor(""))
+}
+
+func TestConfig_Validate_BranchTemplatePattern(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.BranchTemplates = []BranchTemplate{{Pattern: "release/["}}
+
+	err := cfg.Validate()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid branch template pattern")
+}
+
+func TestConfig_IsProtectedBranch(t *testing.T) {
+	cfg := DefaultConfig()
+
+	assert.True(t, cfg.IsProtectedBranch("main"))
+	assert.True(t, cfg.IsProtectedBranch("master"))
+	assert.True(t, cfg.IsProtectedBranch("release/1.2"))
+	assert.False(t, cfg.IsProtectedBranch("feature/login"))
+	assert.False(t, cfg.IsProtectedBranch(""))
+}
+
+func TestLoadFromEnv_ProtectedBranches(t *testing.T) {
+	t.Setenv("CAI_PROTECTED_BRANCHES", "trunk, prod/*")
+	t.Setenv("CAI_PROTECTED_BRANCH_MODE", "warn")
+
+	cfg := DefaultConfig()
+	cfg.loadFromEnv()
+
+	assert.Equal(t, []string{"trunk", "prod/*"}, cfg.ProtectedBranches)
+	assert.Equal(t, ProtectedBranchWarn, cfg.ProtectedBranchMode)
+	assert.True(t, cfg.IsProtectedBranch("prod/eu"))
+	assert.False(t, cfg.IsProtectedBranch("main"))
+}
+
+func TestConfig_Validate_ProtectedBranchMode(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.ProtectedBranchMode = "block"
+
+	err := cfg.Validate()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid protected branch mode")
+}
+
+func TestConfig_ForbiddenPatterns(t *testing.T) {
+	t.Setenv("CAI_FORBIDDEN_PATTERNS", `project\s*falcon, acme corp`)
+
+	cfg := DefaultConfig()
+	cfg.loadFromEnv()
+	require.NoError(t, cfg.Validate())
+
+	// Project files add patterns
+	tempDir := t.TempDir()
+	projectConfigFile := filepath.Join(tempDir, ".commitai")
+	require.NoError(t, os.WriteFile(projectConfigFile, []byte(`CAI_FORBIDDEN_PATTERNS = ["acme corp", "\\bwip\\b"]`), 0o644))
+	require.NoError(t, cfg.loadProjectConfig(projectConfigFile))
+	assert.Equal(t, []string{`project\s*falcon`, "acme corp", `\bwip\b`}, cfg.ForbiddenPatterns)
+
+	assert.Equal(t, []string{"Project Falcon", "ACME Corp"}, cfg.ForbiddenMatches("feat: port Project Falcon login for ACME Corp"))
+	assert.Equal(t, []string{"WIP"}, cfg.ForbiddenMatches("WIP: login"))
+	assert.Empty(t, cfg.ForbiddenMatches("feat: add login wiping"))
+
+	cfg.ForbiddenPatterns = []string{"(unclosed"}
+	err := cfg.Validate()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid CAI_FORBIDDEN_PATTERNS entry")
+}
+
+func TestConfig_OversizedCommit(t *testing.T) {
+	t.Setenv("CAI_MAX_COMMIT_FILES", "10")
+	t.Setenv("CAI_OVERSIZED_COMMIT_MODE", "refuse")
+
+	cfg := DefaultConfig()
+	cfg.loadFromEnv()
+	require.NoError(t, cfg.Validate())
+	assert.Equal(t, OversizedCommitRefuse, cfg.OversizedCommitMode)
+
+	assert.Empty(t, cfg.OversizedCommit(10, 1000))
+	assert.Equal(t, "11 files (limit 10)", cfg.OversizedCommit(11, 20))
+	assert.Equal(t, "12 files (limit 10) and 1500 changed lines (limit 1000)", cfg.OversizedCommit(12, 1500))
+
+	cfg.MaxCommitFiles, cfg.MaxCommitLines = 0, 0
+	assert.Empty(t, cfg.OversizedCommit(5000, 100000))
+
+	cfg.OversizedCommitMode = "block"
+	err := cfg.Validate()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid oversized commit mode")
+}
+
+func TestLoadProjectConfig_DiffCompression(t *testing.T) {
+	tempDir := t.TempDir()
+	projectConfigFile := filepath.Join(tempDir, ".commitai")
+	content := `CAI_COMPRESS_DIFF = true
+CAI_DIFF_CONTEXT_LINES = 1
+
+[[CAI_DIFF_REWRITES]]
+pattern = "req-[0-9]+"
+replacement = "req-<id>"`
+	require.NoError(t, os.WriteFile(projectConfigFile, []byte(content), 0o644))
+
+	cfg := DefaultConfig()
+	require.NoError(t, cfg.loadProjectConfig(projectConfigFile))
+
+	assert.True(t, cfg.CompressDiff)
+	assert.Equal(t, 1, cfg.DiffContextLines)
+	assert.Equal(t, []DiffRewrite{{Pattern: "req-[0-9]+", Replacement: "req-<id>"}}, cfg.DiffRewrites)
+	assert.NoError(t, cfg.Validate())
+
+	cfg.DiffRewrites = []DiffRewrite{{Pattern: "("}}
+	err := cfg.Validate()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid diff rewrite pattern")
+}
+
+func TestConfig_ActiveProvider(t *testing.T) {
+	tempDir := t.TempDir()
+	configFile := filepath.Join(tempDir, "config.toml")
+	content := `CAI_PROVIDER = "openai"
+CAI_API_TOKEN = "flat-token"
+
+[providers.openai]
+url = "https://api.openai.com"
+model = "gpt-4o-mini"
+
+[providers.ollama]
+url = "http://gpu-box:11434"
+model = "llama3"
+
+[providers.azure]
+provider = "openai"
+url = "https://example.openai.azure.com"
+token = "azure-token"
+model = "gpt-4o"`
+	require.NoError(t, os.WriteFile(configFile, []byte(content), 0o644))
+
+	cfg, err := Load(configFile)
+	require.NoError(t, err)
+
+	active := cfg.ActiveProvider()
+	assert.Equal(t, ProviderSettings{
+		Provider: "openai",
+		URL:      "https://api.openai.com",
+		Token:    "flat-token",
+		Model:    "gpt-4o-mini",
+	}, active)
+
+	cfg.Provider = "ollama"
+	assert.Equal(t, "http://gpu-box:11434", cfg.ActiveProvider().URL)
+	assert.Equal(t, "llama3", cfg.ActiveProvider().Model)
+
+	cfg.Profile = "azure"
+	active = cfg.ActiveProvider()
+	assert.Equal(t, "openai", active.Provider)
+	assert.Equal(t, "azure-token", active.Token)
+	require.NoError(t, cfg.Validate())
+
+	cfg.Profile = "missing"
+	err = cfg.Validate()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "profile missing has no [providers.missing] section")
+}
+
+func TestConfig_ActiveProvider_ProjectOverridesGlobalSection(t *testing.T) {
+	tempDir := t.TempDir()
+	configFile := filepath.Join(tempDir, "config.toml")
+	require.NoError(t, os.WriteFile(configFile, []byte(`[providers.ollama]
+url = "http://gpu-box:11434"
+model = "global-section"`), 0o644))
+
+	repoDir := filepath.Join(tempDir, "repo")
+	require.NoError(t, os.MkdirAll(filepath.Join(repoDir, ".git"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(repoDir, ".commitai"), []byte(`CAI_MODEL = "project-model"`), 0o644))
+
+	cfg, err := LoadWithProjectPath(configFile, repoDir)
+	require.NoError(t, err)
+	active := cfg.ActiveProvider()
+	assert.Equal(t, "project-model", active.Model)
+	assert.Equal(t, "http://gpu-box:11434", active.URL)
+
+	// A project's own section still overrides its flat keys
+	require.NoError(t, os.WriteFile(filepath.Join(repoDir, ".commitai"), []byte(`CAI_MODEL = "project-model"
+
+[providers.ollama]
+model = "project-section"`), 0o644))
+	cfg, err = LoadWithProjectPath(configFile, repoDir)
+	require.NoError(t, err)
+	assert.Equal(t, "project-section", cfg.ActiveProvider().Model)
+
+	// The environment overrides both
+	t.Setenv("CAI_MODEL", "env-model")
+	cfg, err = LoadWithProjectPath(configFile, repoDir)
+	require.NoError(t, err)
+	assert.Equal(t, "env-model", cfg.ActiveProvider().Model)
+}
+
+func TestConfig_ActiveProvider_EnvOverridesSection(t *testing.T) {
+	t.Setenv("CAI_MODEL", "env-model")
+
+	cfg := DefaultConfig()
+	cfg.Providers = map[string]ProviderSettings{"ollama": {Model: "section-model"}}
+	cfg.loadFromEnv()
+
+	assert.Equal(t, "env-model", cfg.ActiveProvider().Model)
+}
+
+func TestLoadFromEnv_DepsUseLLM(t *testing.T) {
+	t.Setenv("CAI_DEPS_USE_LLM", "true")
+
+	cfg := DefaultConfig()
+	assert.False(t, cfg.DepsUseLLM)
+
+	cfg.loadFromEnv()
+	assert.True(t, cfg.DepsUseLLM)
+}
+
+func TestLoad_ReadOnlyEnvDoesNotCreateConfig(t *testing.T) {
+	configFile := filepath.Join(t.TempDir(), "config.toml")
+	t.Setenv("CAI_READ_ONLY", "true")
+
+	_, err := Load(configFile)
+	require.NoError(t, err)
+
+	_, err = os.Stat(configFile)
+	assert.True(t, os.IsNotExist(err), "config file should not be created")
+}
+
+func TestLanguages(t *testing.T) {
+	cfg := DefaultConfig()
+	assert.Equal(t, "english", cfg.PrimaryLanguage())
+	assert.Empty(t, cfg.TranslationLanguages())
+
+	t.Setenv("CAI_LANGUAGES", "german, japanese, korean")
+	cfg.loadFromEnv()
+	assert.Equal(t, "german", cfg.PrimaryLanguage())
+	assert.Equal(t, []string{"japanese", "korean"}, cfg.TranslationLanguages())
+	require.NoError(t, cfg.Validate())
+
+	cfg.Languages = []string{"english", " "}
+	assert.Error(t, cfg.Validate())
+}
+
+func TestConfig_Notes(t *testing.T) {
+	tempDir := t.TempDir()
+	projectConfigFile := filepath.Join(tempDir, ".commitai")
+	require.NoError(t, os.WriteFile(projectConfigFile, []byte(`CAI_NOTES = true`), 0o644))
+
+	cfg := DefaultConfig()
+	assert.False(t, cfg.Notes)
+	require.NoError(t, cfg.loadProjectConfig(projectConfigFile))
+	assert.True(t, cfg.Notes)
+
+	t.Setenv("CAI_NOTES", "false")
+	cfg.loadFromEnv()
+	assert.False(t, cfg.Notes)
+}
+
+func TestConfig_Attribution(t *testing.T) {
+	tempDir := t.TempDir()
+	projectConfigFile := filepath.Join(tempDir, ".commitai")
+	require.NoError(t, os.WriteFile(projectConfigFile, []byte(`CAI_ATTRIBUTION = "co-author"`), 0o644))
+
+	cfg := DefaultConfig()
+	require.NoError(t, cfg.loadProjectConfig(projectConfigFile))
+	assert.Equal(t, AttributionCoAuthor, cfg.Attribution)
+	assert.NoError(t, cfg.Validate())
+
+	t.Setenv("CAI_ATTRIBUTION", "assisted")
+	cfg.loadFromEnv()
+	assert.Equal(t, AttributionAssisted, cfg.Attribution)
+
+	cfg.Attribution = "robot"
+	err := cfg.Validate()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid attribution")
+}
+
+func TestConfig_RateLimits(t *testing.T) {
+	tempDir := t.TempDir()
+	projectConfigFile := filepath.Join(tempDir, ".commitai")
+	require.NoError(t, os.WriteFile(projectConfigFile, []byte("CAI_RATE_LIMIT_RPM = 20\nCAI_RATE_LIMIT_TPM = 40000"), 0o644))
+
+	cfg := DefaultConfig()
+	require.NoError(t, cfg.loadProjectConfig(projectConfigFile))
+	assert.Equal(t, 20, cfg.RateLimitRPM)
+	assert.Equal(t, 40000, cfg.RateLimitTPM)
+
+	t.Setenv("CAI_RATE_LIMIT_RPM", "5")
+	cfg.loadFromEnv()
+	assert.Equal(t, 5, cfg.RateLimitRPM)
+	assert.NoError(t, cfg.Validate())
+
+	cfg.RateLimitTPM = -1
+	assert.Error(t, cfg.Validate())
+}
+
+func TestConfig_FallbackProfile(t *testing.T) {
+	cfg := DefaultConfig()
+	assert.Equal(t, 3, cfg.BreakerThreshold)
+
+	cfg.FallbackProfile = "backup"
+	err := cfg.Validate()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "fallback profile backup")
+
+	cfg.Providers = map[string]ProviderSettings{
+		"backup": {Provider: "openai", Model: "gpt-4o-mini"},
+	}
+	require.NoError(t, cfg.Validate())
+
+	t.Setenv("CAI_API_URL", "http://primary.example.com")
+	cfg.loadFromEnv()
+	fallback, ok := cfg.ProviderProfile("backup")
+	require.True(t, ok)
+	assert.Equal(t, "openai", fallback.Provider)
+	assert.Equal(t, "gpt-4o-mini", fallback.Model)
+	assert.Equal(t, "http://primary.example.com", cfg.ActiveProvider().URL)
+
+	cfg.BreakerThreshold = -1
+	assert.Error(t, cfg.Validate())
+}
+
+func TestParseAuthScheme(t *testing.T) {
+	tests := []struct {
+		scheme string
+		kind   string
+		name   string
+		valid  bool
+	}{
+		{"", AuthBearer, "", true},
+		{"Bearer", AuthBearer, "", true},
+		{"basic", AuthBasic, "", true},
+		{"header:X-Api-Key", AuthHeader, "X-Api-Key", true},
+		{"query:api_key", AuthQuery, "api_key", true},
+		{"header:", "", "", false},
+		{"digest", "", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.scheme, func(t *testing.T) {
+			kind, name, err := ParseAuthScheme(tt.scheme)
+			if !tt.valid {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.kind, kind)
+			assert.Equal(t, tt.name, name)
+		})
+	}
+}
+
+func TestConfig_AuthScheme(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Providers = map[string]ProviderSettings{
+		"ollama": {AuthScheme: "header:X-Api-Key"},
+	}
+	assert.Equal(t, "header:X-Api-Key", cfg.ActiveProvider().AuthScheme)
+	require.NoError(t, cfg.Validate())
+
+	t.Setenv("CAI_AUTH_SCHEME", "basic")
+	cfg.loadFromEnv()
+	assert.Equal(t, "basic", cfg.ActiveProvider().AuthScheme)
+
+	cfg.Providers["ollama"] = ProviderSettings{AuthScheme: "query:"}
+	err := cfg.Validate()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "[providers.ollama]")
+}
+
+func TestConfig_PlainDiff(t *testing.T) {
+	cfg := DefaultConfig()
+	assert.False(t, cfg.PlainDiff)
+
+	projectConfigFile := filepath.Join(t.TempDir(), ".commitai")
+	require.NoError(t, os.WriteFile(projectConfigFile, []byte("CAI_PLAIN_DIFF = true\n"), 0o644))
+	require.NoError(t, cfg.loadProjectConfig(projectConfigFile))
+	assert.True(t, cfg.PlainDiff)
+
+	t.Setenv("CAI_PLAIN_DIFF", "false")
+	cfg.loadFromEnv()
+	assert.False(t, cfg.PlainDiff)
+}
+
+func TestConfig_SkipLFS(t *testing.T) {
+	cfg := DefaultConfig()
+	assert.False(t, cfg.SkipLFS)
+
+	projectConfigFile := filepath.Join(t.TempDir(), ".commitai")
+	require.NoError(t, os.WriteFile(projectConfigFile, []byte("CAI_SKIP_LFS = true\n"), 0o644))
+	require.NoError(t, cfg.loadProjectConfig(projectConfigFile))
+	assert.True(t, cfg.SkipLFS)
+
+	t.Setenv("CAI_SKIP_LFS", "false")
+	cfg.loadFromEnv()
+	assert.False(t, cfg.SkipLFS)
+}
+
+func TestConfig_AuditLog(t *testing.T) {
+	cfg := DefaultConfig()
+	assert.Empty(t, cfg.AuditLog)
+	assert.Equal(t, 10, cfg.AuditLogMaxMB)
+	assert.Equal(t, 5, cfg.AuditLogMaxFiles)
+
+	path, err := cfg.AuditLogPath("/etc/commit-ai/config.toml")
+	require.NoError(t, err)
+	assert.Empty(t, path)
+
+	// Project files can't redirect or disable the audit log
+	projectConfigFile := filepath.Join(t.TempDir(), ".commitai")
+	require.NoError(t, os.WriteFile(projectConfigFile, []byte("CAI_AUDIT_LOG = \"/tmp/elsewhere.jsonl\"\n"), 0o644))
+	require.NoError(t, cfg.loadProjectConfig(projectConfigFile))
+	assert.Empty(t, cfg.AuditLog)
+
+	t.Setenv("CAI_AUDIT_LOG", "audit/requests.jsonl")
+	t.Setenv("CAI_AUDIT_LOG_MAX_MB", "50")
+	t.Setenv("CAI_AUDIT_LOG_MAX_FILES", "0")
+	cfg.loadFromEnv()
+	assert.Equal(t, 50, cfg.AuditLogMaxMB)
+	assert.Equal(t, 0, cfg.AuditLogMaxFiles)
+	path, err = cfg.AuditLogPath("/etc/commit-ai/config.toml")
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join("/etc/commit-ai", "audit", "requests.jsonl"), path)
+
+	cfg.AuditLogMaxMB = -1
+	assert.Error(t, cfg.Validate())
+}
+
+func TestConfig_Serve(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	cfg := DefaultConfig()
+	assert.Empty(t, cfg.ServeRepos)
+	assert.Equal(t, 64, cfg.ServeMaxRequestKB)
+	assert.Equal(t, 4, cfg.ServeMaxConcurrent)
+
+	// Project files can't widen the allowed repositories or replace the token
+	projectConfigFile := filepath.Join(t.TempDir(), ".commitai")
+	require.NoError(t, os.WriteFile(projectConfigFile, []byte("CAI_SERVE_REPOS = [\"/\"]\nCAI_SERVE_TOKEN = \"mine\"\n"), 0o644))
+	require.NoError(t, cfg.loadProjectConfig(projectConfigFile))
+	assert.Empty(t, cfg.ServeRepos)
+	assert.Empty(t, cfg.ServeToken)
+
+	t.Setenv("CAI_SERVE_REPOS", "~/src, /srv/repos")
+	t.Setenv("CAI_SERVE_TOKEN", "s3cret")
+	t.Setenv("CAI_SERVE_MAX_REQUEST_KB", "16")
+	t.Setenv("CAI_SERVE_MAX_CONCURRENT", "1")
+	cfg.loadFromEnv()
+	paths, err := cfg.ServePaths()
+	require.NoError(t, err)
+	assert.Equal(t, []string{filepath.Join(home, "src"), "/srv/repos"}, paths)
+	assert.Equal(t, "s3cret", cfg.ServeToken)
+	assert.Equal(t, 16, cfg.ServeMaxRequestKB)
+	assert.Equal(t, 1, cfg.ServeMaxConcurrent)
+
+	cfg.ServeMaxConcurrent = -1
+	assert.ErrorContains(t, cfg.Validate(), "serve limits cannot be negative")
+}
+
+func TestConfig_Tunnel(t *testing.T) {
+	cfg := DefaultConfig()
+	projectConfigFile := filepath.Join(t.TempDir(), ".commitai")
+	require.NoError(t, os.WriteFile(projectConfigFile, []byte("CAI_PROXY = \"socks5://127.0.0.1:1080\"\n"), 0o644))
+	require.NoError(t, cfg.loadProjectConfig(projectConfigFile))
+	assert.Equal(t, "socks5://127.0.0.1:1080", cfg.Proxy)
+	assert.NoError(t, cfg.Validate())
+
+	t.Setenv("CAI_SSH_JUMP_HOST", "dev@bastion")
+	cfg.loadFromEnv()
+	assert.Equal(t, "dev@bastion", cfg.SSHJumpHost)
+	assert.ErrorContains(t, cfg.Validate(), "cannot both be set")
+
+	cfg.Proxy = ""
+	assert.NoError(t, cfg.Validate())
+
+	cfg.SSHJumpHost = "-oProxyCommand=evil"
+	assert.Error(t, cfg.Validate())
+
+	cfg.SSHJumpHost = ""
+	cfg.Proxy = "ftp://proxy:21"
+	assert.ErrorContains(t, cfg.Validate(), "invalid CAI_PROXY scheme")
+}
+
+func TestConfig_LocalProvider(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Provider = "local"
+	assert.ErrorContains(t, cfg.Validate(), "CAI_MODEL_PATH is required")
+
+	t.Setenv("CAI_MODEL_PATH", "~/models/model.gguf")
+	cfg.loadFromEnv()
+	assert.NoError(t, cfg.Validate())
+
+	home, err := os.UserHomeDir()
+	require.NoError(t, err)
+	path, err := cfg.LocalModelPath()
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(home, "models", "model.gguf"), path)
+}
+
+func TestConfig_ReplayProvider(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Provider = "replay"
+	assert.ErrorContains(t, cfg.Validate(), "CAI_REPLAY_FIXTURES is required when using the replay provider")
+
+	cfg.ReplayFixtures = "testdata/fixtures.json"
+	require.NoError(t, cfg.Validate())
+	cfg.repoRoot = t.TempDir()
+	path, err := cfg.ReplayFixturesPath()
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(cfg.repoRoot, "testdata", "fixtures.json"), path)
+
+	cfg.Record = true
+	assert.ErrorContains(t, cfg.Validate(), "can't be used with replay")
+
+	cfg = DefaultConfig()
+	cfg.Record = true
+	assert.EqualError(t, cfg.Validate(), "CAI_REPLAY_FIXTURES is required with CAI_RECORD")
+}
+
+func TestLoadProjectConfig_Record(t *testing.T) {
+	projectConfigFile := filepath.Join(t.TempDir(), ".commitai")
+	require.NoError(t, os.WriteFile(projectConfigFile, []byte("CAI_RECORD = true\nCAI_REPLAY_FIXTURES = \"fixtures.json\""), 0o644))
+
+	cfg := DefaultConfig()
+	require.NoError(t, cfg.loadProjectConfig(projectConfigFile))
+	assert.Equal(t, "fixtures.json", cfg.ReplayFixtures)
+	assert.False(t, cfg.Record, "project files can't enable recording")
+}
+
+func TestConfig_VertexProvider(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Provider = "vertex"
+	require.NoError(t, cfg.Validate())
+
+	// The regional endpoint and a Gemini model replace the Ollama defaults
+	active := cfg.ActiveProvider()
+	assert.Equal(t, "https://us-central1-aiplatform.googleapis.com", active.URL)
+	assert.Equal(t, "gemini-2.5-flash", active.Model)
+
+	t.Setenv("CAI_VERTEX_PROJECT", "my-project")
+	t.Setenv("CAI_VERTEX_LOCATION", "global")
+	t.Setenv("CAI_VERTEX_CREDENTIALS", "~/keys/vertex.json")
+	t.Setenv("CAI_MODEL", "gemini-2.5-pro")
+	cfg.loadFromEnv()
+	require.NoError(t, cfg.Validate())
+	active = cfg.ActiveProvider()
+	assert.Equal(t, "https://aiplatform.googleapis.com", active.URL)
+	assert.Equal(t, "gemini-2.5-pro", active.Model)
+
+	home, err := os.UserHomeDir()
+	require.NoError(t, err)
+	path, err := cfg.VertexCredentialsPath()
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(home, "keys", "vertex.json"), path)
+
+	// The location becomes part of the host name
+	cfg.VertexLocation = "evil.example.com/x"
+	assert.ErrorContains(t, cfg.Validate(), "invalid CAI_VERTEX_LOCATION")
+}
+
+func TestLoadProjectConfig_AllowedProviders(t *testing.T) {
+	dir := t.TempDir()
+	outer := filepath.Join(dir, ".commitai")
+	require.NoError(t, os.WriteFile(outer, []byte(`CAI_ALLOWED_PROVIDERS = ["ollama", "local"]`+"\n"), 0o644))
+	inner := filepath.Join(dir, "client", ".commitai")
+	require.NoError(t, os.MkdirAll(filepath.Dir(inner), 0o755))
+	require.NoError(t, os.WriteFile(inner, []byte(`CAI_ALLOWED_PROVIDERS = ["ollama", "openai"]`+"\n"), 0o644))
+
+	cfg := DefaultConfig()
+	require.NoError(t, cfg.loadProjectConfig(outer))
+	assert.Equal(t, []string{"ollama", "local"}, cfg.AllowedProviders)
+	assert.NoError(t, cfg.Validate())
+
+	// A nested project file can't allow more than its parent
+	require.NoError(t, cfg.loadProjectConfig(inner))
+	assert.Equal(t, []string{"ollama"}, cfg.AllowedProviders)
+
+	// The environment can't switch to a blocked provider
+	t.Setenv("CAI_PROVIDER", "openai")
+	t.Setenv("CAI_API_TOKEN", "sk-test")
+	cfg.loadFromEnv()
+	assert.ErrorContains(t, cfg.Validate(), "provider openai is not allowed in this repository; CAI_ALLOWED_PROVIDERS only allows ollama")
+
+	// Presets are matched by name, not by their API type
+	cfg.AllowedProviders = []string{"openai"}
+	cfg.Provider = "deepseek"
+	assert.ErrorContains(t, cfg.Validate(), "provider deepseek is not allowed")
+
+	// So is the fallback profile
+	cfg.Provider = "openai"
+	cfg.Providers = map[string]ProviderSettings{"cloud": {Provider: "vertex"}}
+	cfg.FallbackProfile = "cloud"
+	assert.ErrorContains(t, cfg.Validate(), "invalid fallback profile cloud: provider vertex is not allowed")
+
+	// An allowed local provider can't point at a remote server
+	cfg.FallbackProfile = ""
+	cfg.AllowedProviders = []string{"ollama"}
+	cfg.Provider = "ollama"
+	cfg.APIURL = "https://ollama.example.com"
+	assert.ErrorContains(t, cfg.Validate(), "provider ollama at https://ollama.example.com is not allowed in this repository")
+	for _, local := range []string{"http://localhost:11434", "http://127.0.0.1:11434", "http://[::1]:11434"} {
+		cfg.APIURL = local
+		assert.NoError(t, cfg.Validate(), local)
+	}
+	cfg.SSHJumpHost = "gpu-box"
+	assert.ErrorContains(t, cfg.Validate(), "provider ollama through CAI_SSH_JUMP_HOST gpu-box is not allowed")
+	cfg.SSHJumpHost = ""
+
+	cfg.AllowedProviders = []string{"olama"}
+	assert.ErrorContains(t, cfg.Validate(), "invalid provider in CAI_ALLOWED_PROVIDERS: olama")
+}
+
+func TestConfig_PromptGuard(t *testing.T) {
+	projectConfigFile := filepath.Join(t.TempDir(), ".commitai")
+	require.NoError(t, os.WriteFile(projectConfigFile, []byte(`CAI_PROMPT_GUARD = "strip"`), 0o644))
+
+	cfg := DefaultConfig()
+	require.NoError(t, cfg.loadProjectConfig(projectConfigFile))
+	assert.Equal(t, PromptGuardStrip, cfg.PromptGuard)
+	assert.NoError(t, cfg.Validate())
+
+	t.Setenv("CAI_PROMPT_GUARD", "warn")
+	cfg.loadFromEnv()
+	assert.Equal(t, PromptGuardWarn, cfg.PromptGuard)
+
+	cfg.PromptGuard = "block"
+	assert.ErrorContains(t, cfg.Validate(), "invalid prompt guard mode: block")
+}
+
+func TestConfig_OutputContract(t *testing.T) {
+	cfg := DefaultConfig()
+	assert.Equal(t, OutputContractStrict, cfg.OutputContract)
+	assert.False(t, cfg.Debug)
+
+	t.Setenv("CAI_OUTPUT_CONTRACT", "off")
+	t.Setenv("CAI_DEBUG", "1")
+	cfg.loadFromEnv()
+	assert.Equal(t, OutputContractOff, cfg.OutputContract)
+	assert.True(t, cfg.Debug)
+	assert.NoError(t, cfg.Validate())
+
+	cfg.OutputContract = "lenient"
+	assert.ErrorContains(t, cfg.Validate(), "invalid output contract: lenient")
+}
+
+func TestConfig_Normalize(t *testing.T) {
+	cfg := DefaultConfig()
+	assert.Empty(t, cfg.Normalize)
+
+	t.Setenv("CAI_NORMALIZE", "lowercase-after-type, strip-trailing-period")
+	cfg.loadFromEnv()
+	assert.Equal(t, []string{NormalizeLowercaseAfterType, NormalizeStripTrailingPeriod}, cfg.Normalize)
+	assert.NoError(t, cfg.Validate())
+
+	cfg.Normalize = []string{"sentence-case"}
+	assert.ErrorContains(t, cfg.Validate(), "invalid normalization rule: sentence-case")
+
+	cfg.Normalize = []string{NormalizeLowercaseAfterType, NormalizeCapitalizeFirstWord}
+	assert.ErrorContains(t, cfg.Validate(), "can't both lowercase and capitalize")
+}
+
+func TestConfig_SmallChange(t *testing.T) {
+	cfg := DefaultConfig()
+	assert.Equal(t, 10, cfg.SmallChangeLines)
+	assert.Empty(t, cfg.SmallChangeModel)
+
+	projectFile := filepath.Join(t.TempDir(), ".commitai")
+	require.NoError(t, os.WriteFile(projectFile, []byte("CAI_SMALL_CHANGE_LINES = 5\nCAI_SMALL_CHANGE_MODEL = \"gpt-4o-mini\"\n"), 0o600))
+	require.NoError(t, cfg.loadProjectConfig(projectFile))
+	assert.Equal(t, 5, cfg.SmallChangeLines)
+	assert.Equal(t, "gpt-4o-mini", cfg.SmallChangeModel)
+
+	t.Setenv("CAI_SMALL_CHANGE_LINES", "0")
+	t.Setenv("CAI_SMALL_CHANGE_MODEL", "llama3.2:1b")
+	cfg.loadFromEnv()
+	assert.Equal(t, 0, cfg.SmallChangeLines)
+	assert.Equal(t, "llama3.2:1b", cfg.SmallChangeModel)
+	assert.NoError(t, cfg.Validate())
+
+	cfg.SmallChangeLines = -1
+	assert.ErrorContains(t, cfg.Validate(), "CAI_SMALL_CHANGE_LINES cannot be negative")
+}
+
+func TestConfig_PromptVariant(t *testing.T) {
+	cfg := DefaultConfig()
+	assert.Equal(t, PromptVariantAuto, cfg.PromptVariant)
+	assert.Equal(t, 300, cfg.LargeChangeLines)
+
+	projectFile := filepath.Join(t.TempDir(), ".commitai")
+	require.NoError(t, os.WriteFile(projectFile, []byte("CAI_PROMPT_VARIANT = \"large\"\nCAI_LARGE_CHANGE_LINES = 100\n"), 0o600))
+	require.NoError(t, cfg.loadProjectConfig(projectFile))
+	assert.Equal(t, PromptVariantLarge, cfg.PromptVariant)
+	assert.Equal(t, 100, cfg.LargeChangeLines)
+
+	t.Setenv("CAI_PROMPT_VARIANT", "tiny")
+	t.Setenv("CAI_LARGE_CHANGE_LINES", "0")
+	cfg.loadFromEnv()
+	assert.Equal(t, PromptVariantTiny, cfg.PromptVariant)
+	assert.Equal(t, 0, cfg.LargeChangeLines)
+	assert.NoError(t, cfg.Validate())
+
+	cfg.PromptVariant = "huge"
+	assert.ErrorContains(t, cfg.Validate(), "invalid prompt variant: huge")
+
+	cfg.PromptVariant = PromptVariantAuto
+	cfg.LargeChangeLines = -1
+	assert.ErrorContains(t, cfg.Validate(), "CAI_LARGE_CHANGE_LINES cannot be negative")
+}
+
+func TestConfig_TemplateEnv(t *testing.T) {
+	cfg := DefaultConfig()
+	assert.Empty(t, cfg.TemplateEnv)
+
+	configFile := filepath.Join(t.TempDir(), "config.toml")
+	require.NoError(t, os.WriteFile(configFile, []byte("CAI_TEMPLATE_ENV = [\"CI_JOB_URL\", \"BUILD_NUMBER\"]\n"), 0o600))
+	cfg, err := Load(configFile)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"CI_JOB_URL", "BUILD_NUMBER"}, cfg.TemplateEnv)
+	assert.NoError(t, cfg.Validate())
+
+	// A repository can't expose the environment of whoever works in it
+	projectFile := filepath.Join(t.TempDir(), ".commitai")
+	require.NoError(t, os.WriteFile(projectFile, []byte("CAI_TEMPLATE_ENV = [\"HOME\"]\n"), 0o600))
+	require.NoError(t, cfg.loadProjectConfig(projectFile))
+	assert.Equal(t, []string{"CI_JOB_URL", "BUILD_NUMBER"}, cfg.TemplateEnv)
+	require.Len(t, cfg.Warnings(), 1)
+	assert.Contains(t, cfg.Warnings()[0], "ignoring CAI_TEMPLATE_ENV")
+
+	t.Setenv("CAI_TEMPLATE_ENV", "GITHUB_RUN_ID, GITHUB_SERVER_URL")
+	cfg.loadFromEnv()
+	assert.Equal(t, []string{"GITHUB_RUN_ID", "GITHUB_SERVER_URL"}, cfg.TemplateEnv)
+
+	cfg.TemplateEnv = []string{"CI-JOB-URL"}
+	assert.ErrorContains(t, cfg.Validate(), `invalid CAI_TEMPLATE_ENV entry "CI-JOB-URL"`)
+
+	for _, name := range []string{"GITHUB_TOKEN", "AWS_SECRET_ACCESS_KEY", "OPENAI_API_KEY", "DB_PASSWORD", "api_key"} {
+		cfg.TemplateEnv = []string{name}
+		assert.ErrorContains(t, cfg.Validate(), "the name looks like a secret", name)
+	}
+}
+
+func TestConfig_Bot(t *testing.T) {
+	cfg := DefaultConfig()
+	assert.Nil(t, cfg.Temperature)
+
+	projectFile := filepath.Join(t.TempDir(), ".commitai")
+	require.NoError(t, os.WriteFile(projectFile, []byte("CAI_TEMPERATURE = 0.2\nCAI_BOT_AUTHOR = \"deps-bot <deps-bot@example.com>\"\n"), 0o600))
+	require.NoError(t, cfg.loadProjectConfig(projectFile))
+	require.NotNil(t, cfg.Temperature)
+	assert.InDelta(t, 0.2, *cfg.Temperature, 1e-9)
+	assert.Equal(t, "deps-bot <deps-bot@example.com>", cfg.BotAuthor)
+	assert.NoError(t, cfg.Validate())
+
+	t.Setenv("CAI_TEMPERATURE", "0")
+	t.Setenv("CAI_BOT_AUTHOR", "renovate[bot] <bot@renovateapp.com>")
+	cfg.loadFromEnv()
+	require.NotNil(t, cfg.Temperature)
+	assert.Zero(t, *cfg.Temperature)
+	assert.Equal(t, "renovate[bot] <bot@renovateapp.com>", cfg.BotAuthor)
+
+	temperature := 2.5
+	cfg.Temperature = &temperature
+	assert.ErrorContains(t, cfg.Validate(), "CAI_TEMPERATURE must be between 0 and 2")
+	cfg.Temperature = nil
+
+	cfg.BotAuthor = "deps-bot"
+	assert.ErrorContains(t, cfg.Validate(), "invalid CAI_BOT_AUTHOR")
+}
+
+func TestParseAuthor(t *testing.T) {
+	name, email, err := ParseAuthor(" Deps Bot <deps-bot@example.com> ")
+	require.NoError(t, err)
+	assert.Equal(t, "Deps Bot", name)
+	assert.Equal(t, "deps-bot@example.com", email)
+
+	for _, author := range []string{"", "deps-bot", "<deps-bot@example.com>", "Deps Bot <>", "Deps <Bot> <a@b>"} {
+		_, _, err := ParseAuthor(author)
+		assert.Error(t, err, author)
+	}
+}
+
+func TestConfig_StandupPaths(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("CAI_STANDUP_REPOS", "~/src/app, /srv/lib")
+
+	cfg := DefaultConfig()
+	cfg.loadFromEnv()
+	paths, err := cfg.StandupPaths()
+	require.NoError(t, err)
+	assert.Equal(t, []string{filepath.Join(home, "src", "app"), "/srv/lib"}, paths)
+}
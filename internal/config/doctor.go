@@ -0,0 +1,146 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+// endpointSuffixes are API paths that belong after the base URL; the
+// generator appends them itself, so a CAI_API_URL ending in one is broken
+var endpointSuffixes = []string{
+	"/api/generate",
+	"/api/chat",
+	"/v1/chat/completions",
+	"/v1/completions",
+	"/chat/completions",
+	"/v1",
+	"/api",
+}
+
+var (
+	// urlAssignment matches CAI_API_URL and provider section url assignments
+	urlAssignment = regexp.MustCompile(`^(\s*(?:CAI_API_URL|url)\s*=\s*)"([^"]*)"(.*)$`)
+	// githubIssuesAssignment matches the legacy CAI_GITHUB_ISSUES = true
+	githubIssuesAssignment = regexp.MustCompile(`^\s*CAI_GITHUB_ISSUES\s*=\s*true\s*(#.*)?$`)
+	// ticketProviderAssignment matches a non-empty CAI_TICKET_PROVIDER
+	ticketProviderAssignment = regexp.MustCompile(`(?m)^\s*CAI_TICKET_PROVIDER\s*=\s*"[^"]+"`)
+	// emptyTicketProvider matches CAI_TICKET_PROVIDER = "", as written by Save
+	emptyTicketProvider = regexp.MustCompile(`^\s*CAI_TICKET_PROVIDER\s*=\s*""\s*$`)
+	// apiTokenAssignment matches a non-empty CAI_API_TOKEN
+	apiTokenAssignment = regexp.MustCompile(`^\s*CAI_API_TOKEN\s*=\s*("[^"]+"|'[^']+')\s*(#.*)?$`)
+)
+
+// NormalizeAPIURL strips API endpoint paths and trailing slashes from an API
+// base URL and reports whether anything changed
+func NormalizeAPIURL(url string) (string, bool) {
+	normalized := strings.TrimRight(url, "/")
+	for _, suffix := range endpointSuffixes {
+		if strings.HasSuffix(normalized, suffix) {
+			normalized = strings.TrimRight(strings.TrimSuffix(normalized, suffix), "/")
+			break
+		}
+	}
+	return normalized, normalized != url && normalized != ""
+}
+
+// FixAPIURLs rewrites the API URLs in config file content to their base URL,
+// leaving comments and formatting intact. It returns the fixed content and a
+// description of each change.
+func FixAPIURLs(content string) (string, []string) {
+	var changes []string
+	lines := strings.Split(content, "\n")
+	for i, line := range lines {
+		m := urlAssignment.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		if fixed, changed := NormalizeAPIURL(m[2]); changed {
+			lines[i] = m[1] + `"` + fixed + `"` + m[3]
+			changes = append(changes, fmt.Sprintf("%s -> %s", m[2], fixed))
+		}
+	}
+	return strings.Join(lines, "\n"), changes
+}
+
+// MigrateLegacyKeys replaces legacy keys in config file content with their
+// current equivalent: CAI_GITHUB_ISSUES = true becomes CAI_TICKET_PROVIDER =
+// "github" unless a ticket provider is already set. It returns the migrated
+// content and a description of each change.
+func MigrateLegacyKeys(content string) (string, []string) {
+	if ticketProviderAssignment.MatchString(content) {
+		return content, nil
+	}
+
+	lines := strings.Split(content, "\n")
+	migrated := -1
+	for i, line := range lines {
+		if githubIssuesAssignment.MatchString(line) {
+			migrated = i
+			break
+		}
+	}
+	if migrated < 0 {
+		return content, nil
+	}
+
+	result := make([]string, 0, len(lines))
+	for i, line := range lines {
+		switch {
+		case i == migrated:
+			result = append(result, `CAI_TICKET_PROVIDER = "github"`)
+		case emptyTicketProvider.MatchString(line):
+			// superseded by the migrated key
+		default:
+			result = append(result, line)
+		}
+	}
+	return strings.Join(result, "\n"), []string{`CAI_GITHUB_ISSUES = true -> CAI_TICKET_PROVIDER = "github"`}
+}
+
+// ReadAPIToken returns the CAI_API_TOKEN set in a single config file,
+// without its includes
+func ReadAPIToken(configFile string) (string, error) {
+	var values struct {
+		APIToken string `toml:"CAI_API_TOKEN"`
+	}
+	if _, err := toml.DecodeFile(configFile, &values); err != nil {
+		return "", fmt.Errorf("failed to decode config file %s: %w", configFile, err)
+	}
+	return values.APIToken, nil
+}
+
+// RemoveAPIToken replaces the CAI_API_TOKEN assignments in config file
+// content, once the token is stored in the OS keyring, with a comment saying
+// where it went. It returns the content and a description of each change.
+func RemoveAPIToken(content string) (string, []string) {
+	var changes []string
+	lines := strings.Split(content, "\n")
+	for i, line := range lines {
+		if apiTokenAssignment.MatchString(line) {
+			lines[i] = "# CAI_API_TOKEN is stored in the OS keyring"
+			changes = append(changes, "CAI_API_TOKEN -> OS keyring")
+		}
+	}
+	return strings.Join(lines, "\n"), changes
+}
+
+// UnknownKeys returns the keys in a config file that commit-ai doesn't
+// recognize, such as misspelled settings
+func UnknownKeys(configFile string) ([]string, error) {
+	var cfg Config
+	meta, err := toml.DecodeFile(configFile, &cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode config file %s: %w", configFile, err)
+	}
+
+	var keys []string
+	for _, key := range meta.Undecoded() {
+		keys = append(keys, key.String())
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
@@ -0,0 +1,110 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalizeAPIURL(t *testing.T) {
+	tests := []struct {
+		url      string
+		expected string
+		changed  bool
+	}{
+		{"http://localhost:11434", "http://localhost:11434", false},
+		{"http://localhost:11434/", "http://localhost:11434", true},
+		{"http://localhost:11434/api/generate", "http://localhost:11434", true},
+		{"https://api.openai.com/v1", "https://api.openai.com", true},
+		{"https://api.openai.com/v1/chat/completions", "https://api.openai.com", true},
+		{"https://proxy.example.com/openai", "https://proxy.example.com/openai", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			fixed, changed := NormalizeAPIURL(tt.url)
+			assert.Equal(t, tt.expected, fixed)
+			assert.Equal(t, tt.changed, changed)
+		})
+	}
+}
+
+func TestFixAPIURLs(t *testing.T) {
+	content := `# Provider settings
+CAI_API_URL = "https://api.openai.com/v1/"  # OpenAI
+
+[providers.ollama]
+url = "http://localhost:11434/api/generate"
+model = "llama3"
+`
+	fixed, changes := FixAPIURLs(content)
+	assert.Equal(t, `# Provider settings
+CAI_API_URL = "https://api.openai.com"  # OpenAI
+
+[providers.ollama]
+url = "http://localhost:11434"
+model = "llama3"
+`, fixed)
+	assert.Len(t, changes, 2)
+
+	_, changes = FixAPIURLs(fixed)
+	assert.Empty(t, changes)
+}
+
+func TestMigrateLegacyKeys(t *testing.T) {
+	content := "CAI_MODEL = \"llama2\"\nCAI_GITHUB_ISSUES = true\n"
+	migrated, changes := MigrateLegacyKeys(content)
+	assert.Equal(t, "CAI_MODEL = \"llama2\"\nCAI_TICKET_PROVIDER = \"github\"\n", migrated)
+	assert.Len(t, changes, 1)
+
+	// An empty ticket provider, as written by Save, is replaced
+	content = "CAI_GITHUB_ISSUES = true\nCAI_TICKET_PROVIDER = \"\"\n"
+	migrated, changes = MigrateLegacyKeys(content)
+	assert.Equal(t, "CAI_TICKET_PROVIDER = \"github\"\n", migrated)
+	assert.Len(t, changes, 1)
+
+	// An explicit ticket provider wins, so nothing is migrated
+	content = "CAI_TICKET_PROVIDER = \"jira\"\nCAI_GITHUB_ISSUES = true\n"
+	migrated, changes = MigrateLegacyKeys(content)
+	assert.Equal(t, content, migrated)
+	assert.Empty(t, changes)
+}
+
+func TestRemoveAPIToken(t *testing.T) {
+	configFile := filepath.Join(t.TempDir(), "config.toml")
+	content := "CAI_PROVIDER = \"openai\"\nCAI_API_TOKEN = \"sk-secret\" # personal\nCAI_MODEL = \"gpt-4\"\n"
+	require.NoError(t, os.WriteFile(configFile, []byte(content), 0o600))
+
+	token, err := ReadAPIToken(configFile)
+	require.NoError(t, err)
+	assert.Equal(t, "sk-secret", token)
+
+	removed, changes := RemoveAPIToken(content)
+	assert.Equal(t, "CAI_PROVIDER = \"openai\"\n# CAI_API_TOKEN is stored in the OS keyring\nCAI_MODEL = \"gpt-4\"\n", removed)
+	assert.Len(t, changes, 1)
+
+	// An empty token, as written by Save, is left alone
+	content = "CAI_API_TOKEN = \"\"\n"
+	removed, changes = RemoveAPIToken(content)
+	assert.Equal(t, content, removed)
+	assert.Empty(t, changes)
+}
+
+func TestUnknownKeys(t *testing.T) {
+	configFile := filepath.Join(t.TempDir(), "config.toml")
+	content := `CAI_MODEL = "llama2"
+CAI_MODLE = "typo"
+
+[providers.openai]
+model = "gpt-4"
+temperature = 0.2
+`
+	require.NoError(t, os.WriteFile(configFile, []byte(content), 0o600))
+
+	keys, err := UnknownKeys(configFile)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"CAI_MODLE", "providers.openai.temperature"}, keys)
+}
@@ -0,0 +1,111 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Default endpoint settings, which a preset replaces when they weren't changed
+const (
+	defaultAPIURL = "http://localhost:11434"
+	defaultModel  = "llama2"
+)
+
+// ProviderPreset is an OpenAI-compatible service selected by name with
+// CAI_PROVIDER (or a profile's provider), bringing its own base URL and model
+// naming
+type ProviderPreset struct {
+	// Title is the service name used in messages
+	Title string `json:"title"`
+	// URL is the base URL used unless CAI_API_URL is set
+	URL          string `json:"url"`
+	DefaultModel string `json:"default_model"`
+	// Models lists well-known models, shown by "commit-ai models" when the
+	// service can't be asked
+	Models []string `json:"models"`
+	// ModelPrefixes are the prefixes of the service's model names
+	ModelPrefixes []string `json:"model_prefixes"`
+	// ExtraBody holds request fields the service needs on top of the
+	// OpenAI chat completion request
+	ExtraBody map[string]any `json:"extra_body,omitempty"`
+}
+
+// providerPresets are the known OpenAI-compatible services
+var providerPresets = map[string]ProviderPreset{
+	"deepseek": {
+		Title:         "DeepSeek",
+		URL:           "https://api.deepseek.com",
+		DefaultModel:  "deepseek-chat",
+		Models:        []string{"deepseek-chat", "deepseek-reasoner"},
+		ModelPrefixes: []string{"deepseek-"},
+	},
+	"qwen": {
+		// The international DashScope endpoint; mainland China accounts use
+		// https://dashscope.aliyuncs.com/compatible-mode
+		Title:         "Qwen (DashScope)",
+		URL:           "https://dashscope-intl.aliyuncs.com/compatible-mode",
+		DefaultModel:  "qwen-plus",
+		Models:        []string{"qwen-max", "qwen-plus", "qwen-turbo", "qwen-coder-plus", "qwen3-coder-plus"},
+		ModelPrefixes: []string{"qwen", "qwq-", "qvq-"},
+		// Qwen3 models reject non-streaming requests with thinking enabled
+		ExtraBody: map[string]any{"enable_thinking": false},
+	},
+}
+
+// Preset returns the provider preset with the given name
+func Preset(name string) (ProviderPreset, bool) {
+	preset, ok := providerPresets[name]
+	return preset, ok
+}
+
+// PresetNames returns the names of the provider presets in sorted order
+func PresetNames() []string {
+	names := make([]string, 0, len(providerPresets))
+	for name := range providerPresets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// supportedProviders lists the accepted provider names for error messages
+func supportedProviders() string {
+	return strings.Join(append([]string{providerOllama, providerOpenAI, providerLocal, providerVertex, providerReplay}, PresetNames()...), ", ")
+}
+
+// withPreset resolves a preset provider to the OpenAI API type, filling in
+// the preset's base URL and default model unless they were configured
+func (s ProviderSettings) withPreset() ProviderSettings {
+	preset, ok := providerPresets[s.Provider]
+	if !ok {
+		return s
+	}
+	s.Preset = s.Provider
+	s.Provider = providerOpenAI
+	if s.URL == "" || s.URL == defaultAPIURL {
+		s.URL = preset.URL
+	}
+	if s.Model == "" || s.Model == defaultModel {
+		s.Model = preset.DefaultModel
+	}
+	return s
+}
+
+// validatePreset checks that a preset provider has a token and a model named
+// like the service's models
+func validatePreset(settings ProviderSettings) error {
+	preset, ok := providerPresets[settings.Preset]
+	if !ok {
+		return nil
+	}
+	if settings.Token == "" {
+		return fmt.Errorf("CAI_API_TOKEN is required when using the %s provider", preset.Title)
+	}
+	for _, prefix := range preset.ModelPrefixes {
+		if strings.HasPrefix(settings.Model, prefix) {
+			return nil
+		}
+	}
+	return fmt.Errorf("model %s is not a %s model; try one of: %s", settings.Model, preset.Title, strings.Join(preset.Models, ", "))
+}
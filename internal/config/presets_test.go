@@ -0,0 +1,60 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestActiveProvider_Preset(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Provider = "deepseek"
+	cfg.APIToken = "sk-test"
+
+	active := cfg.ActiveProvider()
+	assert.Equal(t, "openai", active.Provider)
+	assert.Equal(t, "deepseek", active.Preset)
+	assert.Equal(t, "https://api.deepseek.com", active.URL)
+	assert.Equal(t, "deepseek-chat", active.Model)
+	assert.NoError(t, cfg.Validate())
+
+	// Configured values win over the preset's
+	cfg.APIURL = "https://gateway.example.com/deepseek"
+	cfg.Model = "deepseek-reasoner"
+	active = cfg.ActiveProvider()
+	assert.Equal(t, "https://gateway.example.com/deepseek", active.URL)
+	assert.Equal(t, "deepseek-reasoner", active.Model)
+}
+
+func TestValidate_Preset(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Provider = "qwen"
+	assert.ErrorContains(t, cfg.Validate(), "CAI_API_TOKEN is required when using the Qwen (DashScope) provider")
+
+	cfg.APIToken = "sk-test"
+	cfg.Model = "qwen3-coder-plus"
+	assert.NoError(t, cfg.Validate())
+
+	cfg.Model = "gpt-4o"
+	err := cfg.Validate()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "model gpt-4o is not a Qwen (DashScope) model")
+	assert.Contains(t, err.Error(), "qwen-plus")
+
+	cfg.Provider = "mistral"
+	assert.ErrorContains(t, cfg.Validate(), "Supported providers: ollama, openai, local, vertex, replay, deepseek, qwen")
+}
+
+func TestProviderProfile_Preset(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Providers = map[string]ProviderSettings{
+		"cheap": {Provider: "deepseek", Token: "sk-test"},
+	}
+
+	profile, ok := cfg.ProviderProfile("cheap")
+	require.True(t, ok)
+	assert.Equal(t, "openai", profile.Provider)
+	assert.Equal(t, "deepseek", profile.Preset)
+	assert.Equal(t, "https://api.deepseek.com", profile.URL)
+}
@@ -0,0 +1,80 @@
+package config
+
+import "reflect"
+
+// secretMask replaces redacted values
+const secretMask = "****"
+
+// MaskSecret masks a secret value for display. Long values keep their last
+// four characters so different tokens can still be told apart.
+func MaskSecret(secret string) string {
+	if secret == "" {
+		return ""
+	}
+	if len(secret) < 12 {
+		return secretMask
+	}
+	return secretMask + secret[len(secret)-4:]
+}
+
+// Redacted returns a copy of the configuration with every field tagged
+// `secret:"true"` masked, including those nested in provider sections. Use it
+// whenever configuration is printed or logged.
+func (c *Config) Redacted() *Config {
+	redacted := *c
+	redactValue(reflect.ValueOf(&redacted).Elem())
+	// Unexported fields can't be set through reflection
+	redacted.envProvider.Token = MaskSecret(redacted.envProvider.Token)
+	redacted.projectProvider.Token = MaskSecret(redacted.projectProvider.Token)
+	redacted.projectProviders = make(map[string]ProviderSettings, len(c.projectProviders))
+	for name, settings := range c.projectProviders {
+		settings.Token = MaskSecret(settings.Token)
+		redacted.projectProviders[name] = settings
+	}
+	return &redacted
+}
+
+// redactValue masks secret string fields of a struct value in place. Maps and
+// slices are copied before their elements are redacted so the original
+// configuration is left untouched.
+func redactValue(v reflect.Value) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := v.Field(i)
+		if !field.CanSet() {
+			continue
+		}
+
+		switch field.Kind() {
+		case reflect.String:
+			if t.Field(i).Tag.Get("secret") == "true" {
+				field.SetString(MaskSecret(field.String()))
+			}
+		case reflect.Struct:
+			redactValue(field)
+		case reflect.Map:
+			if field.IsNil() || field.Type().Elem().Kind() != reflect.Struct {
+				continue
+			}
+			copied := reflect.MakeMapWithSize(field.Type(), field.Len())
+			iter := field.MapRange()
+			for iter.Next() {
+				elem := reflect.New(field.Type().Elem()).Elem()
+				elem.Set(iter.Value())
+				redactValue(elem)
+				copied.SetMapIndex(iter.Key(), elem)
+			}
+			field.Set(copied)
+		case reflect.Slice:
+			if field.IsNil() || field.Type().Elem().Kind() != reflect.Struct {
+				continue
+			}
+			copied := reflect.MakeSlice(field.Type(), field.Len(), field.Len())
+			reflect.Copy(copied, field)
+			for j := 0; j < copied.Len(); j++ {
+				redactValue(copied.Index(j))
+			}
+			field.Set(copied)
+		}
+	}
+}
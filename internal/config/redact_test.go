@@ -0,0 +1,36 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMaskSecret(t *testing.T) {
+	assert.Equal(t, "", MaskSecret(""))
+	assert.Equal(t, "****", MaskSecret("short"))
+	assert.Equal(t, "****wxyz", MaskSecret("sk-abcdefghijklmnopqrstuvwxyz"))
+}
+
+func TestConfig_Redacted(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.APIToken = "sk-abcdefghijklmnopqrstuvwxyz"
+	cfg.JiraToken = "jira-secret"
+	cfg.JiraEmail = "dev@example.com"
+	cfg.Providers = map[string]ProviderSettings{
+		"openai": {URL: "https://api.openai.com", Token: "provider-token-1234"},
+	}
+
+	redacted := cfg.Redacted()
+
+	assert.Equal(t, "****wxyz", redacted.APIToken)
+	assert.Equal(t, "****", redacted.JiraToken)
+	assert.Equal(t, "dev@example.com", redacted.JiraEmail)
+	assert.Equal(t, "****1234", redacted.Providers["openai"].Token)
+	assert.Equal(t, "https://api.openai.com", redacted.Providers["openai"].URL)
+	assert.Equal(t, "", redacted.GitHubToken)
+
+	// The original configuration is untouched
+	assert.Equal(t, "sk-abcdefghijklmnopqrstuvwxyz", cfg.APIToken)
+	assert.Equal(t, "provider-token-1234", cfg.Providers["openai"].Token)
+}
@@ -0,0 +1,306 @@
+package config
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+)
+
+// maxRemoteConfigSize bounds the remote configuration and its signature
+const maxRemoteConfigSize = 1 << 20
+
+// remoteClient fetches remote configurations; tests replace it with the
+// client of a TLS test server
+var remoteClient = &http.Client{Timeout: 10 * time.Second}
+
+// remoteSource is an organization-managed configuration hosted over HTTPS and
+// cached in the user cache directory
+type remoteSource struct {
+	url *url.URL
+	// key verifies the detached signature at the URL with ".sig" appended;
+	// nil when no public key is configured
+	key ed25519.PublicKey
+	ttl time.Duration
+	// cacheFile holds the last accepted body and metaFile its remoteMeta
+	cacheFile string
+	metaFile  string
+}
+
+// remoteMeta describes the cached copy of a remote configuration
+type remoteMeta struct {
+	URL       string    `json:"url"`
+	ETag      string    `json:"etag,omitempty"`
+	Signature string    `json:"signature,omitempty"`
+	FetchedAt time.Time `json:"fetched_at"`
+}
+
+// applyRemoteConfig merges the remote configuration named by
+// CAI_REMOTE_CONFIG_URL into c. It is applied below the global file, so every
+// local setting wins. The remote settings themselves are only read from the
+// environment and the global file, never from project files, so a cloned
+// repository can't point commit-ai at another organization's config.
+// Network failures fall back to the cached copy with a warning; only an
+// invalid URL or public key is an error.
+func (c *Config) applyRemoteConfig(configFile string, exists bool) error {
+	settings := DefaultConfig()
+	if exists {
+		// Errors are reported when the global file is decoded for real
+		_ = decodeWithIncludes(configFile, settings, map[string]bool{})
+	}
+	settings.loadFromEnv()
+	if settings.RemoteConfigURL == "" {
+		return nil
+	}
+
+	source, err := newRemoteSource(settings)
+	if err != nil {
+		return err
+	}
+	body, signed, err := source.load(c.warn)
+	if err != nil {
+		c.warn(fmt.Sprintf("remote config %s unavailable, continuing without it: %v", source.displayURL(), err))
+		return nil
+	}
+	c.mergeRemote(body, signed)
+	return nil
+}
+
+// newRemoteSource validates the remote config settings of cfg
+func newRemoteSource(cfg *Config) (*remoteSource, error) {
+	u, err := url.Parse(cfg.RemoteConfigURL)
+	if err != nil || u.Scheme != "https" || u.Host == "" {
+		return nil, fmt.Errorf("CAI_REMOTE_CONFIG_URL must be an https:// URL")
+	}
+
+	source := &remoteSource{
+		url: u,
+		ttl: time.Duration(cfg.RemoteConfigTTLSeconds) * time.Second,
+	}
+	if cfg.RemoteConfigPublicKey != "" {
+		key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(cfg.RemoteConfigPublicKey))
+		if err != nil || len(key) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("CAI_REMOTE_CONFIG_PUBKEY must be a base64-encoded Ed25519 public key")
+		}
+		source.key = key
+	}
+
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		dir = os.TempDir()
+	}
+	sum := sha256.Sum256([]byte(cfg.RemoteConfigURL))
+	name := fmt.Sprintf("remote-config-%x", sum[:8])
+	source.cacheFile = filepath.Join(dir, "commit-ai", name+".toml")
+	source.metaFile = filepath.Join(dir, "commit-ai", name+".json")
+	return source, nil
+}
+
+// load returns the remote configuration, from the cache while it is younger
+// than the TTL and otherwise revalidated with its ETag. signed reports whether
+// the body was verified against the configured public key.
+func (s *remoteSource) load(warn func(string)) (body []byte, signed bool, err error) {
+	cached, meta := s.readCache()
+	if meta != nil && time.Since(meta.FetchedAt) < s.ttl && s.verify(cached, meta.Signature) == nil {
+		return cached, s.key != nil, nil
+	}
+
+	body, fresh, err := s.fetch(cached, meta)
+	if err == nil {
+		err = s.verify(body, fresh.Signature)
+	}
+	if err != nil {
+		if meta != nil && s.verify(cached, meta.Signature) == nil {
+			warn(fmt.Sprintf("failed to refresh remote config %s, using the cached copy: %v", s.displayURL(), err))
+			return cached, s.key != nil, nil
+		}
+		return nil, false, err
+	}
+
+	// A failing cache only costs a refetch next time
+	_ = s.writeCache(body, fresh)
+	return body, s.key != nil, nil
+}
+
+// fetch downloads the remote configuration, sending the cached ETag so an
+// unchanged configuration costs a 304 response
+func (s *remoteSource) fetch(cached []byte, meta *remoteMeta) ([]byte, *remoteMeta, error) {
+	req, err := http.NewRequest(http.MethodGet, s.url.String(), nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	if meta != nil && meta.ETag != "" {
+		req.Header.Set("If-None-Match", meta.ETag)
+	}
+
+	resp, err := remoteClient.Do(req)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to fetch: %w", redactURLError(err))
+	}
+	defer resp.Body.Close()
+
+	fresh := &remoteMeta{URL: s.url.String(), FetchedAt: time.Now()}
+	switch {
+	case resp.StatusCode == http.StatusNotModified && meta != nil:
+		fresh.ETag = meta.ETag
+		fresh.Signature = meta.Signature
+		return cached, fresh, nil
+	case resp.StatusCode != http.StatusOK:
+		return nil, nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	body, err := readLimited(resp.Body)
+	if err != nil {
+		return nil, nil, err
+	}
+	fresh.ETag = resp.Header.Get("ETag")
+	if s.key != nil {
+		if fresh.Signature, err = s.fetchSignature(); err != nil {
+			return nil, nil, err
+		}
+	}
+	return body, fresh, nil
+}
+
+// fetchSignature downloads the detached signature, the base64-encoded
+// Ed25519 signature of the configuration at the URL path with ".sig" appended
+func (s *remoteSource) fetchSignature() (string, error) {
+	sigURL := *s.url
+	sigURL.Path += ".sig"
+	sigURL.RawPath = ""
+
+	resp, err := remoteClient.Get(sigURL.String())
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch signature: %w", redactURLError(err))
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to fetch signature: unexpected status %s", resp.Status)
+	}
+
+	sig, err := readLimited(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(sig)), nil
+}
+
+// verify checks body against its signature when a public key is configured
+func (s *remoteSource) verify(body []byte, signature string) error {
+	if s.key == nil {
+		return nil
+	}
+	sig, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil || !ed25519.Verify(s.key, body, sig) {
+		return fmt.Errorf("signature verification failed")
+	}
+	return nil
+}
+
+// readCache returns the cached body and its metadata, or a nil meta when
+// nothing usable is cached for the URL
+func (s *remoteSource) readCache() ([]byte, *remoteMeta) {
+	data, err := os.ReadFile(s.metaFile)
+	if err != nil {
+		return nil, nil
+	}
+	var meta remoteMeta
+	if err := json.Unmarshal(data, &meta); err != nil || meta.URL != s.url.String() {
+		return nil, nil
+	}
+	body, err := os.ReadFile(s.cacheFile)
+	if err != nil {
+		return nil, nil
+	}
+	return body, &meta
+}
+
+// writeCache stores an accepted body with its metadata
+func (s *remoteSource) writeCache(body []byte, meta *remoteMeta) error {
+	data, err := json.Marshal(meta)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.cacheFile), 0o700); err != nil {
+		return err
+	}
+	if err := os.WriteFile(s.cacheFile, body, 0o600); err != nil {
+		return err
+	}
+	return os.WriteFile(s.metaFile, data, 0o600)
+}
+
+// mergeRemote decodes a remote configuration into c. The remote config can't
+// include files or move the remote config itself, and an unsigned one can't
+// set CAI_CONTEXT_CMD, which runs a local command, or CAI_TEMPLATE_ENV, which
+// exposes local environment variables to the prompt.
+func (c *Config) mergeRemote(body []byte, signed bool) {
+	if _, err := toml.Decode(string(body), &Config{}); err != nil {
+		c.warn(fmt.Sprintf("ignoring invalid remote config: %v", err))
+		return
+	}
+
+	saved := *c
+	_, _ = toml.Decode(string(body), c)
+
+	c.Include = saved.Include
+	c.RemoteConfigURL = saved.RemoteConfigURL
+	c.RemoteConfigPublicKey = saved.RemoteConfigPublicKey
+	c.RemoteConfigTTLSeconds = saved.RemoteConfigTTLSeconds
+	if !signed && c.ContextCmd != saved.ContextCmd {
+		c.ContextCmd = saved.ContextCmd
+		c.warn("ignoring CAI_CONTEXT_CMD from an unsigned remote config; set CAI_REMOTE_CONFIG_PUBKEY to allow it")
+	}
+	if !signed && !slices.Equal(c.TemplateEnv, saved.TemplateEnv) {
+		c.TemplateEnv = saved.TemplateEnv
+		c.warn("ignoring CAI_TEMPLATE_ENV from an unsigned remote config; set CAI_REMOTE_CONFIG_PUBKEY to allow it")
+	}
+	if !signed && (!slices.Equal(c.ServeRepos, saved.ServeRepos) || c.ServeToken != saved.ServeToken) {
+		c.ServeRepos, c.ServeToken = saved.ServeRepos, saved.ServeToken
+		c.warn("ignoring CAI_SERVE_REPOS and CAI_SERVE_TOKEN from an unsigned remote config; set CAI_REMOTE_CONFIG_PUBKEY to allow them")
+	}
+}
+
+// displayURL returns the URL for messages, without a query string that may
+// carry an access token
+func (s *remoteSource) displayURL() string {
+	u := *s.url
+	u.RawQuery = ""
+	return u.Redacted()
+}
+
+// readLimited reads a response body of at most maxRemoteConfigSize bytes
+func readLimited(r io.Reader) ([]byte, error) {
+	body, err := io.ReadAll(io.LimitReader(r, maxRemoteConfigSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	if len(body) > maxRemoteConfigSize {
+		return nil, fmt.Errorf("response exceeds %d bytes", maxRemoteConfigSize)
+	}
+	return body, nil
+}
+
+// redactURLError drops the query string, which may carry an access token,
+// from the URL in a transport error
+func redactURLError(err error) error {
+	if urlErr, ok := err.(*url.Error); ok {
+		if u, parseErr := url.Parse(urlErr.URL); parseErr == nil {
+			u.RawQuery = ""
+			urlErr.URL = u.Redacted()
+		}
+		return urlErr
+	}
+	return err
+}
@@ -0,0 +1,195 @@
+package config
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const remoteBody = `CAI_MODEL = "remote-model"
+CAI_LANGUAGE = "french"
+CAI_CONTEXT_CMD = "echo org"
+CAI_TEMPLATE_ENV = ["CI_JOB_URL"]
+CAI_SERVE_REPOS = ["/"]
+include = ["/etc/commit-ai/extra.toml"]
+`
+
+// remoteServer serves remoteBody at /commit-ai.toml with an ETag and, when
+// signature is set, the signature at /commit-ai.toml.sig
+type remoteServer struct {
+	*httptest.Server
+	requests    atomic.Int32
+	revalidated atomic.Int32
+	signature   string
+}
+
+func newRemoteServer(t *testing.T) *remoteServer {
+	t.Helper()
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+
+	rs := &remoteServer{}
+	rs.Server = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/commit-ai.toml":
+			rs.requests.Add(1)
+			if r.Header.Get("If-None-Match") == `"v1"` {
+				rs.revalidated.Add(1)
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			w.Header().Set("ETag", `"v1"`)
+			_, _ = w.Write([]byte(remoteBody))
+		case "/commit-ai.toml.sig":
+			_, _ = w.Write([]byte(rs.signature + "\n"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(rs.Close)
+
+	client := remoteClient
+	remoteClient = rs.Client()
+	t.Cleanup(func() { remoteClient = client })
+	return rs
+}
+
+// writeGlobalConfig writes a global config file with the given content
+func writeGlobalConfig(t *testing.T, content string) string {
+	t.Helper()
+	configFile := filepath.Join(t.TempDir(), "config.toml")
+	require.NoError(t, os.WriteFile(configFile, []byte(content), 0o600))
+	return configFile
+}
+
+func TestLoad_RemoteConfig(t *testing.T) {
+	rs := newRemoteServer(t)
+	configFile := writeGlobalConfig(t, `CAI_MODEL = "local-model"
+CAI_REMOTE_CONFIG_URL = "`+rs.URL+`/commit-ai.toml"
+`)
+
+	cfg, err := LoadWithOptions(configFile, t.TempDir(), LoadOptions{NoWrite: true})
+	require.NoError(t, err)
+
+	// The global file wins over the remote config
+	assert.Equal(t, "local-model", cfg.Model)
+	assert.Equal(t, "french", cfg.Language)
+	// Unsigned remote configs can't run commands, read the environment,
+	// expose repositories or include files
+	assert.Empty(t, cfg.ContextCmd)
+	assert.Empty(t, cfg.TemplateEnv)
+	assert.Empty(t, cfg.ServeRepos)
+	assert.Empty(t, cfg.Include)
+	assert.Equal(t, rs.URL+"/commit-ai.toml", cfg.RemoteConfigURL)
+	require.Len(t, cfg.Warnings(), 3)
+	assert.Contains(t, cfg.Warnings()[0], "CAI_CONTEXT_CMD")
+	assert.Contains(t, cfg.Warnings()[1], "CAI_TEMPLATE_ENV")
+	assert.Contains(t, cfg.Warnings()[2], "CAI_SERVE_REPOS")
+
+	// Within the TTL the cached copy is used
+	cfg, err = LoadWithOptions(configFile, t.TempDir(), LoadOptions{NoWrite: true})
+	require.NoError(t, err)
+	assert.Equal(t, "french", cfg.Language)
+	assert.Equal(t, int32(1), rs.requests.Load())
+
+	// Past the TTL the cached copy is revalidated with its ETag
+	t.Setenv("CAI_REMOTE_CONFIG_TTL", "0")
+	cfg, err = LoadWithOptions(configFile, t.TempDir(), LoadOptions{NoWrite: true})
+	require.NoError(t, err)
+	assert.Equal(t, "french", cfg.Language)
+	assert.Equal(t, int32(1), rs.revalidated.Load())
+
+	// Environment variables still win
+	t.Setenv("CAI_LANGUAGE", "german")
+	cfg, err = LoadWithOptions(configFile, t.TempDir(), LoadOptions{NoWrite: true})
+	require.NoError(t, err)
+	assert.Equal(t, "german", cfg.Language)
+}
+
+func TestLoad_RemoteConfigUnavailable(t *testing.T) {
+	rs := newRemoteServer(t)
+	t.Setenv("CAI_REMOTE_CONFIG_URL", rs.URL+"/commit-ai.toml")
+	t.Setenv("CAI_REMOTE_CONFIG_TTL", "0")
+	configFile := filepath.Join(t.TempDir(), "config.toml")
+
+	cfg, err := LoadWithOptions(configFile, t.TempDir(), LoadOptions{NoWrite: true})
+	require.NoError(t, err)
+	assert.Equal(t, "french", cfg.Language)
+
+	// A failed refresh falls back to the cached copy
+	rs.Close()
+	cfg, err = LoadWithOptions(configFile, t.TempDir(), LoadOptions{NoWrite: true})
+	require.NoError(t, err)
+	assert.Equal(t, "french", cfg.Language)
+	require.NotEmpty(t, cfg.Warnings())
+	assert.Contains(t, cfg.Warnings()[0], "using the cached copy")
+
+	// Without a cached copy, loading continues with the local settings
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	cfg, err = LoadWithOptions(configFile, t.TempDir(), LoadOptions{NoWrite: true})
+	require.NoError(t, err)
+	assert.Equal(t, "english", cfg.Language)
+	require.NotEmpty(t, cfg.Warnings())
+	assert.Contains(t, cfg.Warnings()[0], "continuing without it")
+}
+
+func TestLoad_RemoteConfigSignature(t *testing.T) {
+	rs := newRemoteServer(t)
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	t.Setenv("CAI_REMOTE_CONFIG_URL", rs.URL+"/commit-ai.toml")
+	t.Setenv("CAI_REMOTE_CONFIG_PUBKEY", base64.StdEncoding.EncodeToString(pub))
+	configFile := filepath.Join(t.TempDir(), "config.toml")
+
+	// A bad signature rejects the remote config
+	rs.signature = base64.StdEncoding.EncodeToString(ed25519.Sign(priv, []byte("something else")))
+	cfg, err := LoadWithOptions(configFile, t.TempDir(), LoadOptions{NoWrite: true})
+	require.NoError(t, err)
+	assert.Equal(t, "english", cfg.Language)
+	require.Len(t, cfg.Warnings(), 1)
+	assert.Contains(t, cfg.Warnings()[0], "signature verification failed")
+
+	// A signed remote config may set CAI_CONTEXT_CMD, CAI_TEMPLATE_ENV and
+	// CAI_SERVE_REPOS
+	rs.signature = base64.StdEncoding.EncodeToString(ed25519.Sign(priv, []byte(remoteBody)))
+	cfg, err = LoadWithOptions(configFile, t.TempDir(), LoadOptions{NoWrite: true})
+	require.NoError(t, err)
+	assert.Equal(t, "french", cfg.Language)
+	assert.Equal(t, "echo org", cfg.ContextCmd)
+	assert.Equal(t, []string{"CI_JOB_URL"}, cfg.TemplateEnv)
+	assert.Equal(t, []string{"/"}, cfg.ServeRepos)
+	assert.Empty(t, cfg.Warnings())
+}
+
+func TestLoad_RemoteConfigInvalidSettings(t *testing.T) {
+	configFile := filepath.Join(t.TempDir(), "config.toml")
+
+	t.Setenv("CAI_REMOTE_CONFIG_URL", "http://config.example.com/commit-ai.toml")
+	_, err := LoadWithOptions(configFile, t.TempDir(), LoadOptions{NoWrite: true})
+	assert.ErrorContains(t, err, "must be an https:// URL")
+
+	t.Setenv("CAI_REMOTE_CONFIG_URL", "https://config.example.com/commit-ai.toml")
+	t.Setenv("CAI_REMOTE_CONFIG_PUBKEY", "not-a-key")
+	_, err = LoadWithOptions(configFile, t.TempDir(), LoadOptions{NoWrite: true})
+	assert.ErrorContains(t, err, "Ed25519 public key")
+}
+
+func TestLoad_RemoteConfigIgnoredInProjectFiles(t *testing.T) {
+	rs := newRemoteServer(t)
+	projectDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(projectDir, ".commitai"),
+		[]byte(`CAI_REMOTE_CONFIG_URL = "`+rs.URL+`/commit-ai.toml"`+"\n"), 0o600))
+
+	cfg, err := LoadWithOptions(filepath.Join(t.TempDir(), "config.toml"), projectDir, LoadOptions{NoWrite: true})
+	require.NoError(t, err)
+	assert.Equal(t, "english", cfg.Language)
+	assert.Equal(t, int32(0), rs.requests.Load())
+}
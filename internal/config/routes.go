@@ -0,0 +1,132 @@
+package config
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/nseba/commit-ai/internal/analyze"
+)
+
+// ModelRoute selects a model or [providers.<name>] profile for changes that
+// meet all of its conditions; conditions left empty always hold. Language
+// and framework names are compared case-insensitively.
+type ModelRoute struct {
+	// Languages matches the primary language of the change, the one with
+	// the most changed lines. With Only, every changed file must be in one
+	// of them, e.g. ["Markdown"] for documentation-only changes.
+	Languages []string `toml:"languages,omitempty" desc:"Languages of the change; the primary one must be listed"`
+	Only      bool     `toml:"only,omitempty" desc:"Require every changed file to be in one of languages"`
+	// RepoLanguages and Frameworks match the dominant languages and the
+	// detected frameworks of the repository
+	RepoLanguages []string `toml:"repo_languages,omitempty" desc:"Dominant languages of the repository"`
+	Frameworks    []string `toml:"frameworks,omitempty" desc:"Frameworks detected in the repository"`
+	// MinLines and MaxLines bound the number of changed lines; zero leaves
+	// the bound open
+	MinLines int `toml:"min_lines,omitempty" desc:"Minimum number of changed lines"`
+	MaxLines int `toml:"max_lines,omitempty" desc:"Maximum number of changed lines"`
+
+	// Profile is the [providers.<name>] section to use, and Model the model
+	// to use with it (or with the active provider)
+	Profile string `toml:"profile,omitempty" desc:"[providers.<name>] section to use"`
+	Model   string `toml:"model,omitempty" desc:"Model to use"`
+}
+
+// Matches reports whether the route applies to a change in a repository
+func (r ModelRoute) Matches(change analyze.ChangeProfile, repo analyze.RepoProfile) bool {
+	if len(r.Languages) > 0 {
+		allowed := func(lang string) bool {
+			return slices.ContainsFunc(r.Languages, func(l string) bool { return strings.EqualFold(l, lang) })
+		}
+		if !allowed(change.Primary) {
+			return false
+		}
+		if r.Only {
+			for _, lang := range change.Languages {
+				if !allowed(lang) {
+					return false
+				}
+			}
+		}
+	}
+	if len(r.RepoLanguages) > 0 && !intersectsFold(r.RepoLanguages, repo.Languages) {
+		return false
+	}
+	if len(r.Frameworks) > 0 && !intersectsFold(r.Frameworks, repo.Frameworks) {
+		return false
+	}
+	if r.MinLines > 0 && change.ChangedLines < r.MinLines {
+		return false
+	}
+	if r.MaxLines > 0 && change.ChangedLines > r.MaxLines {
+		return false
+	}
+	return true
+}
+
+// String describes the route's target for messages
+func (r ModelRoute) String() string {
+	switch {
+	case r.Profile != "" && r.Model != "":
+		return fmt.Sprintf("profile %s, model %s", r.Profile, r.Model)
+	case r.Profile != "":
+		return "profile " + r.Profile
+	default:
+		return "model " + r.Model
+	}
+}
+
+// RouteFor returns the first model route matching a change, or nil
+func (c *Config) RouteFor(change analyze.ChangeProfile, repo analyze.RepoProfile) *ModelRoute {
+	for i := range c.ModelRoutes {
+		if c.ModelRoutes[i].Matches(change, repo) {
+			return &c.ModelRoutes[i]
+		}
+	}
+	return nil
+}
+
+// ApplyRoute switches the active provider to the route's profile and model.
+// The routed model, or else the model of the routed profile, takes
+// precedence over CAI_MODEL.
+func (c *Config) ApplyRoute(route *ModelRoute) {
+	c.routeModel = route.Model
+	if route.Profile != "" {
+		c.Profile = route.Profile
+		if c.routeModel == "" {
+			c.routeModel = c.Providers[route.Profile].Model
+		}
+	}
+}
+
+// validateModelRoutes checks that every route has a target, an existing
+// profile and sensible line bounds
+func (c *Config) validateModelRoutes() error {
+	for i, route := range c.ModelRoutes {
+		if route.Profile == "" && route.Model == "" {
+			return fmt.Errorf("model route %d needs a profile or a model", i+1)
+		}
+		if route.Profile != "" {
+			if _, ok := c.Providers[route.Profile]; !ok {
+				return fmt.Errorf("model route %d: profile %s has no [providers.%s] section", i+1, route.Profile, route.Profile)
+			}
+		}
+		if route.MinLines < 0 || route.MaxLines < 0 || (route.MaxLines > 0 && route.MinLines > route.MaxLines) {
+			return fmt.Errorf("model route %d has invalid line bounds", i+1)
+		}
+		if route.Only && len(route.Languages) == 0 {
+			return fmt.Errorf("model route %d sets only without languages", i+1)
+		}
+	}
+	return nil
+}
+
+// intersectsFold reports whether the lists share a value, ignoring case
+func intersectsFold(a, b []string) bool {
+	for _, item := range b {
+		if slices.ContainsFunc(a, func(x string) bool { return strings.EqualFold(x, item) }) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,86 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nseba/commit-ai/internal/analyze"
+)
+
+func TestModelRoutes(t *testing.T) {
+	projectConfigFile := filepath.Join(t.TempDir(), ".commitai")
+	require.NoError(t, os.WriteFile(projectConfigFile, []byte(`
+[providers.small]
+provider = "ollama"
+model = "llama3.2:1b"
+
+[[CAI_MODEL_ROUTES]]
+languages = ["markdown", "text"]
+only = true
+profile = "small"
+
+[[CAI_MODEL_ROUTES]]
+languages = ["Go"]
+min_lines = 200
+model = "qwen2.5-coder:32b"
+`), 0o644))
+
+	cfg := DefaultConfig()
+	require.NoError(t, cfg.loadProjectConfig(projectConfigFile))
+	require.Len(t, cfg.ModelRoutes, 2)
+	require.NoError(t, cfg.Validate())
+
+	goRepo := analyze.RepoProfile{Languages: []string{"Go"}, Frameworks: []string{"go"}}
+	docs := analyze.ChangeProfile{Languages: []string{"Markdown"}, Primary: "Markdown", ChangedLines: 10}
+	mixed := analyze.ChangeProfile{Languages: []string{"Markdown", "Go"}, Primary: "Markdown", ChangedLines: 10}
+	smallGo := analyze.ChangeProfile{Languages: []string{"Go"}, Primary: "Go", ChangedLines: 50}
+	largeGo := analyze.ChangeProfile{Languages: []string{"Go", "YAML"}, Primary: "Go", ChangedLines: 400}
+
+	route := cfg.RouteFor(docs, goRepo)
+	require.NotNil(t, route)
+	assert.Equal(t, "profile small", route.String())
+	assert.Nil(t, cfg.RouteFor(mixed, goRepo))
+	assert.Nil(t, cfg.RouteFor(smallGo, goRepo))
+
+	route = cfg.RouteFor(largeGo, goRepo)
+	require.NotNil(t, route)
+	cfg.ApplyRoute(route)
+	assert.Equal(t, "qwen2.5-coder:32b", cfg.ActiveProvider().Model)
+
+	// A routed model wins over CAI_MODEL
+	t.Setenv("CAI_MODEL", "llama3")
+	cfg.loadFromEnv()
+	assert.Equal(t, "qwen2.5-coder:32b", cfg.ActiveProvider().Model)
+
+	cfg.ApplyRoute(&cfg.ModelRoutes[0])
+	assert.Equal(t, "llama3.2:1b", cfg.ActiveProvider().Model)
+}
+
+func TestModelRoute_Repository(t *testing.T) {
+	route := ModelRoute{Frameworks: []string{"react"}, RepoLanguages: []string{"TypeScript"}, MaxLines: 100, Model: "gpt-4o-mini"}
+	change := analyze.ChangeProfile{Languages: []string{"TypeScript"}, Primary: "TypeScript", ChangedLines: 20}
+	assert.True(t, route.Matches(change, analyze.RepoProfile{Languages: []string{"TypeScript", "CSS"}, Frameworks: []string{"node", "react"}}))
+	assert.False(t, route.Matches(change, analyze.RepoProfile{Languages: []string{"TypeScript"}, Frameworks: []string{"node", "vue"}}))
+
+	change.ChangedLines = 101
+	assert.False(t, route.Matches(change, analyze.RepoProfile{Languages: []string{"TypeScript"}, Frameworks: []string{"react"}}))
+}
+
+func TestValidate_ModelRoutes(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.ModelRoutes = []ModelRoute{{Languages: []string{"Go"}}}
+	assert.ErrorContains(t, cfg.Validate(), "model route 1 needs a profile or a model")
+
+	cfg.ModelRoutes = []ModelRoute{{Profile: "missing"}}
+	assert.ErrorContains(t, cfg.Validate(), "profile missing has no [providers.missing] section")
+
+	cfg.ModelRoutes = []ModelRoute{{MinLines: 100, MaxLines: 10, Model: "x"}}
+	assert.ErrorContains(t, cfg.Validate(), "invalid line bounds")
+
+	cfg.ModelRoutes = []ModelRoute{{Only: true, Model: "x"}}
+	assert.ErrorContains(t, cfg.Validate(), "only without languages")
+}
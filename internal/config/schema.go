@@ -0,0 +1,136 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+)
+
+// Option describes a configuration key. Options are generated from the toml,
+// desc, env and secret tags of Config and the tables it contains.
+type Option struct {
+	// Key is the TOML key; keys of nested tables are written as
+	// "providers.<name>.url" or "CAI_MODEL_ROUTES[].model"
+	Key  string `json:"key"`
+	Type string `json:"type"`
+	// Default is the built-in value of top-level keys, nil for nested ones
+	Default any `json:"default"`
+	// Env is the environment variable overriding the key, empty when none does
+	Env         string `json:"env,omitempty"`
+	Description string `json:"description"`
+	Secret      bool   `json:"secret,omitempty"`
+}
+
+// Schema returns every supported configuration key with its type, default,
+// environment variable and description, in declaration order
+func Schema() []Option {
+	defaults := reflect.ValueOf(DefaultConfig()).Elem()
+	var options []Option
+	for i := 0; i < defaults.NumField(); i++ {
+		field := defaults.Type().Field(i)
+		key := tomlKey(field)
+		if key == "" {
+			continue
+		}
+
+		option := Option{
+			Key:         key,
+			Type:        typeName(field.Type),
+			Default:     defaultValue(defaults.Field(i)),
+			Description: field.Tag.Get("desc"),
+			Secret:      field.Tag.Get("secret") == "true",
+		}
+		if field.Tag.Get("env") != "-" {
+			option.Env = key
+		}
+		options = append(options, option)
+
+		// Tables list their keys after the option holding them
+		switch field.Type.Kind() {
+		case reflect.Slice:
+			if field.Type.Elem().Kind() == reflect.Struct {
+				options = append(options, tableOptions(key+"[].", field.Type.Elem())...)
+			}
+		case reflect.Map:
+			options = append(options, tableOptions(key+".<name>.", field.Type.Elem())...)
+		}
+	}
+	return options
+}
+
+// tableOptions describes the keys of a table type, prefixed with prefix
+func tableOptions(prefix string, t reflect.Type) []Option {
+	var options []Option
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		key := tomlKey(field)
+		if key == "" {
+			continue
+		}
+		options = append(options, Option{
+			Key:         prefix + key,
+			Type:        typeName(field.Type),
+			Description: field.Tag.Get("desc"),
+			Secret:      field.Tag.Get("secret") == "true",
+		})
+	}
+	return options
+}
+
+// tomlKey returns the TOML key of an exported field, or "" when the field is
+// not part of the configuration file
+func tomlKey(field reflect.StructField) string {
+	if !field.IsExported() {
+		return ""
+	}
+	key, _, _ := strings.Cut(field.Tag.Get("toml"), ",")
+	if key == "-" {
+		return ""
+	}
+	return key
+}
+
+// typeName names the TOML type of a field
+func typeName(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.Pointer:
+		return typeName(t.Elem())
+	case reflect.String:
+		return "string"
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Int, reflect.Int64:
+		return "integer"
+	case reflect.Float64:
+		return "float"
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Struct {
+			return "array of tables"
+		}
+		return "array of " + typeName(t.Elem()) + "s"
+	case reflect.Map:
+		return "table of tables"
+	default:
+		return t.Kind().String()
+	}
+}
+
+// defaultValue returns the default of a field, with empty arrays and tables
+// rather than nil ones and nil for unset pointers
+func defaultValue(v reflect.Value) any {
+	switch v.Kind() {
+	case reflect.Pointer:
+		if v.IsNil() {
+			return nil
+		}
+		return v.Elem().Interface()
+	case reflect.Slice:
+		if v.IsNil() {
+			return reflect.MakeSlice(v.Type(), 0, 0).Interface()
+		}
+	case reflect.Map:
+		if v.IsNil() {
+			return reflect.MakeMap(v.Type()).Interface()
+		}
+	}
+	return v.Interface()
+}
@@ -0,0 +1,59 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSchema(t *testing.T) {
+	options := make(map[string]Option)
+	for _, option := range Schema() {
+		assert.NotEmpty(t, option.Description, "%s has no description", option.Key)
+		options[option.Key] = option
+	}
+
+	assert.Equal(t, Option{Key: "CAI_TIMEOUT_SECONDS", Type: "integer", Default: 300, Env: "CAI_TIMEOUT_SECONDS", Description: "Timeout for AI requests (seconds)"}, options["CAI_TIMEOUT_SECONDS"])
+	assert.Equal(t, "float", options["CAI_TEMPERATURE"].Type)
+	assert.Nil(t, options["CAI_TEMPERATURE"].Default)
+	assert.Equal(t, []string{"main", "master", "release/*"}, options["CAI_PROTECTED_BRANCHES"].Default)
+	assert.Equal(t, []string{}, options["CAI_FORBIDDEN_PATTERNS"].Default)
+	assert.True(t, options["CAI_API_TOKEN"].Secret)
+
+	// Tables are only read from files and list their own keys
+	assert.Equal(t, "array of tables", options["CAI_MODEL_ROUTES"].Type)
+	assert.Empty(t, options["CAI_MODEL_ROUTES"].Env)
+	assert.Contains(t, options, "CAI_MODEL_ROUTES[].model")
+	assert.True(t, options["providers.<name>.token"].Secret)
+	assert.NotContains(t, options, "providers.<name>.Preset")
+}
+
+// TestSchema_Env checks that every environment variable of the schema
+// overrides its key
+func TestSchema_Env(t *testing.T) {
+	samples := map[string]string{
+		"string":           "sample",
+		"integer":          "7",
+		"float":            "0.5",
+		"boolean":          "true",
+		"array of strings": "a,b",
+	}
+
+	defaults := DefaultConfig()
+	for _, option := range Schema() {
+		if option.Env == "" {
+			continue
+		}
+		sample, ok := samples[option.Type]
+		require.True(t, ok, "no sample for %s of type %s", option.Key, option.Type)
+
+		t.Run(option.Key, func(t *testing.T) {
+			t.Setenv(option.Env, sample)
+			cfg := DefaultConfig()
+			cfg.loadFromEnv()
+			assert.False(t, reflect.DeepEqual(defaults, cfg), "%s doesn't change the configuration", option.Env)
+		})
+	}
+}
@@ -0,0 +1,120 @@
+package config
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// FieldError is a configuration problem found by Validate, with the key it
+// concerns and the environment variable that overrides the key, if any
+type FieldError struct {
+	// Key is the configuration key, such as CAI_MODEL or
+	// providers.<name>.auth_scheme
+	Key string
+	Env string
+	Err error
+}
+
+func (e *FieldError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *FieldError) Unwrap() error {
+	return e.Err
+}
+
+// ValidationError holds every problem Validate found, so all of them can be
+// fixed in one pass
+type ValidationError struct {
+	Problems []*FieldError
+}
+
+func (e *ValidationError) Error() string {
+	if len(e.Problems) == 1 {
+		return e.Problems[0].Error()
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d configuration problems:", len(e.Problems))
+	for _, problem := range e.Problems {
+		b.WriteString("\n  - " + problem.Error())
+	}
+	return b.String()
+}
+
+// Unwrap exposes the problems to errors.Is and errors.As
+func (e *ValidationError) Unwrap() []error {
+	errs := make([]error, len(e.Problems))
+	for i, problem := range e.Problems {
+		errs[i] = problem
+	}
+	return errs
+}
+
+// Keys returns the keys with problems, in the order they were found
+func (e *ValidationError) Keys() []string {
+	var keys []string
+	for _, problem := range e.Problems {
+		if !slices.Contains(keys, problem.Key) {
+			keys = append(keys, problem.Key)
+		}
+	}
+	return keys
+}
+
+// EnvVars returns the environment variables overriding the keys with
+// problems, in the order they were found
+func (e *ValidationError) EnvVars() []string {
+	var envs []string
+	for _, problem := range e.Problems {
+		if problem.Env != "" && !slices.Contains(envs, problem.Env) {
+			envs = append(envs, problem.Env)
+		}
+	}
+	return envs
+}
+
+// envVars maps the configuration keys to their environment variables
+var envVars = sync.OnceValue(func() map[string]string {
+	vars := make(map[string]string)
+	for _, option := range Schema() {
+		if option.Env != "" {
+			vars[option.Key] = option.Env
+		}
+	}
+	return vars
+})
+
+// validation collects the problems found by Validate
+type validation struct {
+	problems []*FieldError
+}
+
+// add records err as a problem with key, unless it is nil
+func (v *validation) add(key string, err error) {
+	if err == nil {
+		return
+	}
+	v.problems = append(v.problems, &FieldError{Key: key, Env: envVars()[key], Err: err})
+}
+
+// check records a problem with key unless ok holds
+func (v *validation) check(ok bool, key, format string, args ...any) {
+	if !ok {
+		v.add(key, fmt.Errorf(format, args...))
+	}
+}
+
+// has reports whether a problem with key was recorded
+func (v *validation) has(key string) bool {
+	return slices.ContainsFunc(v.problems, func(p *FieldError) bool { return p.Key == key })
+}
+
+// err returns the recorded problems as a *ValidationError, or nil
+func (v *validation) err() error {
+	if len(v.problems) == 0 {
+		return nil
+	}
+	return &ValidationError{Problems: v.problems}
+}
@@ -0,0 +1,62 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// defaultVertexModel is used by the vertex provider unless CAI_MODEL is set
+const defaultVertexModel = "gemini-2.5-flash"
+
+var (
+	// vertexLocation matches Google Cloud region names such as us-central1,
+	// which become part of the endpoint host name
+	vertexLocation = regexp.MustCompile(`^[a-z][a-z0-9-]*[a-z0-9]$`)
+	// vertexProject matches project IDs, including domain-scoped ones
+	vertexProject = regexp.MustCompile(`^[a-z0-9.:-]+$`)
+)
+
+// resolveProvider fills in the defaults of providers with their own endpoint
+// conventions: Vertex AI's regional endpoint and default model, and the
+// settings of provider presets
+func (c *Config) resolveProvider(s ProviderSettings) ProviderSettings {
+	if s.Provider != providerVertex {
+		return s.withPreset()
+	}
+	if s.URL == "" || s.URL == defaultAPIURL {
+		s.URL = VertexEndpoint(c.VertexLocation)
+	}
+	if s.Model == "" || s.Model == defaultModel {
+		s.Model = defaultVertexModel
+	}
+	return s
+}
+
+// VertexEndpoint returns the Vertex AI API base URL of a location; the
+// "global" location has no regional host
+func VertexEndpoint(location string) string {
+	if location == "global" {
+		return "https://aiplatform.googleapis.com"
+	}
+	return fmt.Sprintf("https://%s-aiplatform.googleapis.com", location)
+}
+
+// validateVertex checks the Vertex AI settings when the vertex provider is
+// active
+func (c *Config) validateVertex(v *validation) {
+	if c.ActiveProvider().Provider != providerVertex {
+		return
+	}
+	v.check(vertexLocation.MatchString(c.VertexLocation), "CAI_VERTEX_LOCATION", "invalid CAI_VERTEX_LOCATION: %q", c.VertexLocation)
+	v.check(c.VertexProject == "" || vertexProject.MatchString(c.VertexProject), "CAI_VERTEX_PROJECT", "invalid CAI_VERTEX_PROJECT: %q", c.VertexProject)
+}
+
+// VertexCredentialsPath returns the credentials file for the vertex
+// provider with a leading ~ expanded, or an empty string to use Application
+// Default Credentials
+func (c *Config) VertexCredentialsPath() (string, error) {
+	if c.VertexCredentials == "" {
+		return "", nil
+	}
+	return resolveIncludePath(c.VertexCredentials, ".")
+}
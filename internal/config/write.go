@@ -0,0 +1,84 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/BurntSushi/toml"
+
+	"github.com/nseba/commit-ai/internal/filelock"
+	"github.com/nseba/commit-ai/internal/interrupt"
+)
+
+// Save atomically writes the configuration to the specified file. Concurrent
+// writers are serialized with a lock file and readers never observe a
+// partially written file.
+func (c *Config) Save(configFile string) error {
+	unlock, err := lockConfig(configFile)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	return interrupt.Critical(func() error { return c.writeAtomic(configFile) })
+}
+
+// saveIfMissing writes the configuration to configFile unless the file
+// exists, which another process may have created while we waited for the lock
+func (c *Config) saveIfMissing(configFile string) error {
+	unlock, err := lockConfig(configFile)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if _, err := os.Stat(configFile); err == nil {
+		return nil
+	}
+	return interrupt.Critical(func() error { return c.writeAtomic(configFile) })
+}
+
+// writeAtomic encodes the configuration to a temporary file next to
+// configFile and renames it into place
+func (c *Config) writeAtomic(configFile string) error {
+	dir := filepath.Dir(configFile)
+
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(configFile)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to create config file: %w", err)
+	}
+	tmpName := tmp.Name()
+	defer os.Remove(tmpName) // no-op once renamed
+
+	if err := toml.NewEncoder(tmp).Encode(c); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("failed to encode config: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("failed to write config file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write config file: %w", err)
+	}
+
+	if err := os.Rename(tmpName, configFile); err != nil {
+		return fmt.Errorf("failed to replace config file: %w", err)
+	}
+	return nil
+}
+
+// lockConfig takes the lock file guarding configFile, creating the config
+// directory if needed, and returns a function releasing it
+func lockConfig(configFile string) (func(), error) {
+	if err := os.MkdirAll(filepath.Dir(configFile), 0o750); err != nil {
+		return nil, fmt.Errorf("failed to create config directory: %w", err)
+	}
+
+	unlock, err := filelock.Lock(configFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock config file: %w", err)
+	}
+	return unlock, nil
+}
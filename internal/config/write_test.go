@@ -0,0 +1,76 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nseba/commit-ai/internal/filelock"
+)
+
+func TestSave_ConcurrentWriters(t *testing.T) {
+	configFile := filepath.Join(t.TempDir(), "commit-ai", "config.toml")
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 20)
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			_, err := Load(configFile)
+			errs <- err
+		}()
+		go func() {
+			defer wg.Done()
+			errs <- DefaultConfig().Save(configFile)
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		require.NoError(t, err)
+	}
+
+	cfg, err := Load(configFile)
+	require.NoError(t, err)
+	assert.Equal(t, "llama2", cfg.Model)
+
+	// Neither the lock nor temporary files are left behind
+	entries, err := os.ReadDir(filepath.Dir(configFile))
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, "config.toml", entries[0].Name())
+}
+
+func TestSaveIfMissing_KeepsExistingFile(t *testing.T) {
+	configFile := filepath.Join(t.TempDir(), "config.toml")
+	require.NoError(t, os.WriteFile(configFile, []byte(`CAI_MODEL = "mine"`), 0o600))
+
+	require.NoError(t, DefaultConfig().saveIfMissing(configFile))
+
+	content, err := os.ReadFile(configFile)
+	require.NoError(t, err)
+	assert.Equal(t, `CAI_MODEL = "mine"`, string(content))
+}
+
+func TestLockConfig_RemovesStaleLock(t *testing.T) {
+	configFile := filepath.Join(t.TempDir(), "config.toml")
+	lockPath := configFile + ".lock"
+	require.NoError(t, os.WriteFile(lockPath, nil, 0o600))
+
+	stale := time.Now().Add(-2 * filelock.DefaultStaleAge)
+	require.NoError(t, os.Chtimes(lockPath, stale, stale))
+
+	unlock, err := lockConfig(configFile)
+	require.NoError(t, err)
+	unlock()
+
+	_, err = os.Stat(lockPath)
+	assert.True(t, os.IsNotExist(err))
+}
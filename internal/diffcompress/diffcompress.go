@@ -0,0 +1,185 @@
+// Package diffcompress shrinks unified diffs before they are sent to a model
+// by trimming unchanged context, merging nearby hunks and normalizing noisy
+// values such as timestamps and hashes.
+package diffcompress
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// gapMarker replaces runs of context lines that were dropped
+const gapMarker = "@@ ... @@"
+
+// Rewrite replaces every match of Pattern with Replacement (regexp syntax,
+// $1 style references are expanded)
+type Rewrite struct {
+	Pattern     string
+	Replacement string
+}
+
+// DefaultRewrites normalize values that change often but rarely carry meaning
+var DefaultRewrites = []Rewrite{
+	{Pattern: `\b\d{4}-\d{2}-\d{2}[T ]\d{2}:\d{2}:\d{2}(\.\d+)?(Z|[+-]\d{2}:?\d{2})?\b`, Replacement: "<timestamp>"},
+	{Pattern: `\b[0-9a-f]{40}\b|\b[0-9a-f]{64}\b`, Replacement: "<hash>"},
+}
+
+// Options controls the compression
+type Options struct {
+	// ContextLines is the number of unchanged lines kept around each change
+	ContextLines int
+	// Rewrites are applied to every content line, after DefaultRewrites
+	Rewrites []Rewrite
+}
+
+type compiledRewrite struct {
+	re          *regexp.Regexp
+	replacement string
+}
+
+// Compress returns the compressed form of a unified diff
+func Compress(diff string, opts Options) (string, error) {
+	rewrites, err := compile(append(append([]Rewrite{}, DefaultRewrites...), opts.Rewrites...))
+	if err != nil {
+		return "", err
+	}
+
+	context := opts.ContextLines
+	if context < 0 {
+		context = 0
+	}
+
+	var out []string
+	var file []string
+	flush := func() {
+		if len(file) > 0 {
+			out = append(out, compressFile(file, context)...)
+			file = nil
+		}
+	}
+
+	for _, line := range strings.Split(diff, "\n") {
+		if strings.HasPrefix(line, "diff --git ") {
+			flush()
+		}
+		file = append(file, rewrite(line, rewrites))
+	}
+	flush()
+
+	return strings.Join(out, "\n"), nil
+}
+
+// compile compiles the rewrite patterns
+func compile(rewrites []Rewrite) ([]compiledRewrite, error) {
+	compiled := make([]compiledRewrite, 0, len(rewrites))
+	for _, rw := range rewrites {
+		re, err := regexp.Compile(rw.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid rewrite pattern %q: %w", rw.Pattern, err)
+		}
+		compiled = append(compiled, compiledRewrite{re: re, replacement: rw.Replacement})
+	}
+	return compiled, nil
+}
+
+// rewrite applies the rewrites to a line, leaving file headers untouched
+func rewrite(line string, rewrites []compiledRewrite) string {
+	if isFileHeader(line) {
+		return line
+	}
+	for _, rw := range rewrites {
+		line = rw.re.ReplaceAllString(line, rw.replacement)
+	}
+	return line
+}
+
+// compressFile trims the context of a single file section. Hunks whose
+// separating context fits within the context budget are merged into one.
+func compressFile(lines []string, context int) []string {
+	var out []string
+	var pending []string
+	var header string
+	seenChange := false
+
+	for _, line := range lines {
+		switch {
+		case !seenChange && len(pending) == 0 && header == "" && isFileHeader(line):
+			out = append(out, line)
+		case strings.HasPrefix(line, "@@"):
+			if header == "" {
+				header = line
+			}
+		case isChange(line):
+			out = append(out, flushContext(pending, header, context, seenChange)...)
+			out = append(out, line)
+			pending, header = nil, ""
+			seenChange = true
+		default:
+			pending = append(pending, line)
+		}
+	}
+
+	// Trailing context after the last change
+	if seenChange {
+		out = append(out, head(pending, context)...)
+	} else if header != "" || len(pending) > 0 {
+		out = append(out, flushContext(pending, header, context, false)...)
+	}
+
+	return out
+}
+
+// flushContext returns the context lines to keep before a change. Leading
+// context keeps its hunk header; context between changes keeps up to context
+// lines on each side and collapses the rest into a gap marker.
+func flushContext(pending []string, header string, context int, afterChange bool) []string {
+	var out []string
+	if !afterChange {
+		if header != "" {
+			out = append(out, header)
+		}
+		return append(out, tail(pending, context)...)
+	}
+
+	if len(pending) <= 2*context {
+		return pending
+	}
+
+	marker := header
+	if marker == "" {
+		marker = gapMarker
+	}
+	out = append(out, head(pending, context)...)
+	out = append(out, marker)
+	return append(out, tail(pending, context)...)
+}
+
+func head(lines []string, n int) []string {
+	if len(lines) > n {
+		return lines[:n]
+	}
+	return lines
+}
+
+func tail(lines []string, n int) []string {
+	if len(lines) > n {
+		return lines[len(lines)-n:]
+	}
+	return lines
+}
+
+// isFileHeader reports whether line belongs to the per-file diff header
+func isFileHeader(line string) bool {
+	for _, prefix := range []string{"diff --git ", "index ", "--- ", "+++ ", "new file mode", "deleted file mode", "old mode", "new mode", "similarity index", "rename from", "rename to", "Binary files"} {
+		if strings.HasPrefix(line, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// isChange reports whether line is an added, removed or no-newline marker line
+func isChange(line string) bool {
+	return strings.HasPrefix(line, "+") || strings.HasPrefix(line, "-") || strings.HasPrefix(line, `\`)
+}
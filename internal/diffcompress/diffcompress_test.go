@@ -0,0 +1,100 @@
+package diffcompress
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const sampleDiff = `diff --git a/main.go b/main.go
+index 1111111..2222222 100644
+--- a/main.go
++++ b/main.go
+@@ -1,12 +1,12 @@ package main
+ line1
+ line2
+ line3
+-old4
++new4
+ line5
+ line6
+ line7
+ line8
+ line9
+ line10
+-old11
++new11
+ line12
+@@ -40,5 +40,5 @@ func helper()
+ line40
+ line41
+-old42
++new42
+ line43`
+
+func TestCompress_TrimsContext(t *testing.T) {
+	result, err := Compress(sampleDiff, Options{ContextLines: 1})
+	require.NoError(t, err)
+
+	expected := `diff --git a/main.go b/main.go
+index 1111111..2222222 100644
+--- a/main.go
++++ b/main.go
+@@ -1,12 +1,12 @@ package main
+ line3
+-old4
++new4
+ line5
+@@ ... @@
+ line10
+-old11
++new11
+ line12
+@@ -40,5 +40,5 @@ func helper()
+ line41
+-old42
++new42
+ line43`
+	assert.Equal(t, expected, result)
+}
+
+func TestCompress_MergesAdjacentHunks(t *testing.T) {
+	result, err := Compress(sampleDiff, Options{ContextLines: 5})
+	require.NoError(t, err)
+
+	// All context between the first two changes fits in the budget
+	assert.Contains(t, result, "+new4\n line5\n line6\n line7\n line8\n line9\n line10\n-old11")
+	// line12 to line41 is four lines, so the second hunk is merged too
+	assert.NotContains(t, result, "func helper()")
+	assert.Equal(t, 1, strings.Count(result, "@@ -"))
+}
+
+func TestCompress_Rewrites(t *testing.T) {
+	diff := `diff --git a/fixture.json b/fixture.json
+--- a/fixture.json
++++ b/fixture.json
+-  "created": "2024-01-02T03:04:05Z",
++  "created": "2025-06-07T08:09:10Z",
+-  "id": "req-17",
++  "id": "req-42",
++  "sha": "0123456789abcdef0123456789abcdef01234567"`
+
+	result, err := Compress(diff, Options{
+		ContextLines: 3,
+		Rewrites:     []Rewrite{{Pattern: `req-\d+`, Replacement: "req-<n>"}},
+	})
+	require.NoError(t, err)
+
+	assert.Contains(t, result, `+  "created": "<timestamp>",`)
+	assert.Contains(t, result, `+  "id": "req-<n>",`)
+	assert.Contains(t, result, `+  "sha": "<hash>"`)
+	assert.Contains(t, result, "--- a/fixture.json")
+}
+
+func TestCompress_InvalidPattern(t *testing.T) {
+	_, err := Compress(sampleDiff, Options{Rewrites: []Rewrite{{Pattern: "("}}})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid rewrite pattern")
+}
@@ -0,0 +1,313 @@
+// Package diffparse splits unified diffs in git's format into file sections
+// and reads the paths of each file from its headers. Paths with spaces, and
+// the C-style quoted paths git writes for names with special characters, such
+// as diff --git "a/x\ty" "b/x\ty", come out as the names in the repository.
+package diffparse
+
+import (
+	"errors"
+	"strings"
+)
+
+// headerPrefix starts the first line of every file section
+const headerPrefix = "diff --git "
+
+// devNull stands for the missing side of an added or deleted file
+const devNull = "/dev/null"
+
+// File is the section of a diff about one file
+type File struct {
+	// OldPath is the path before the change, empty for an added file
+	OldPath string
+	// NewPath is the path after the change, empty for a deleted file
+	NewPath string
+	// Raw is the whole section, from its "diff --git" line on
+	Raw string
+	// Body is the part of Raw after the headers: the hunks, or the changed
+	// lines of diffs without hunk headers. It is empty for binary files and
+	// changes of mode only.
+	Body string
+}
+
+// Path returns the path the file has after the change, or the path it had
+// for a deleted file
+func (f File) Path() string {
+	if f.NewPath != "" {
+		return f.NewPath
+	}
+	return f.OldPath
+}
+
+// IsHeader reports whether line starts a file section
+func IsHeader(line string) bool {
+	return strings.HasPrefix(line, headerPrefix)
+}
+
+// Split splits diff into its file sections. Text before the first section,
+// such as the message of a patch file, is returned as preamble, with its
+// trailing newline: the preamble followed by the Raw of every file, joined
+// with newlines, gives diff back.
+func Split(diff string) (preamble string, files []File) {
+	lines := strings.Split(diff, "\n")
+	start := -1
+	for i, line := range lines {
+		if !IsHeader(line) {
+			continue
+		}
+		if start < 0 {
+			if i > 0 {
+				preamble = strings.Join(lines[:i], "\n") + "\n"
+			}
+		} else {
+			files = append(files, parseFile(lines[start:i]))
+		}
+		start = i
+	}
+	if start < 0 {
+		return diff, nil
+	}
+	return preamble, append(files, parseFile(lines[start:]))
+}
+
+// extendedHeaders start the header lines that may follow "diff --git"
+var extendedHeaders = []string{
+	"old mode ", "new mode ", "new file mode ", "deleted file mode ",
+	"similarity index ", "dissimilarity index ", "index ",
+	"rename from ", "rename to ", "copy from ", "copy to ",
+	"Binary files ", "--- ", "+++ ",
+}
+
+// isExtendedHeader reports whether line is a header line of a file section
+func isExtendedHeader(line string) bool {
+	for _, prefix := range extendedHeaders {
+		if strings.HasPrefix(line, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseFile reads the paths of a file section from its headers. The
+// "diff --git" line is ambiguous for unquoted names with spaces, so the
+// rename, copy and ---/+++ lines take precedence when present. The headers
+// end at the first other line, or after the "+++" line, so that removed and
+// added lines starting with "-- " or "++ " aren't taken for headers.
+func parseFile(lines []string) File {
+	file := File{Raw: strings.Join(lines, "\n")}
+	file.OldPath, file.NewPath, _ = ParseHeader(lines[0])
+
+	added, deleted := false, false
+	body := len(lines)
+	for i := 1; i < len(lines) && body == len(lines); i++ {
+		line := lines[i]
+		if !isExtendedHeader(line) {
+			body = i
+			continue
+		}
+		switch {
+		case strings.HasPrefix(line, "new file mode "):
+			added = true
+		case strings.HasPrefix(line, "deleted file mode "):
+			deleted = true
+		case strings.HasPrefix(line, "rename from "):
+			file.OldPath = headerPath(strings.TrimPrefix(line, "rename from "), "")
+		case strings.HasPrefix(line, "copy from "):
+			file.OldPath = headerPath(strings.TrimPrefix(line, "copy from "), "")
+		case strings.HasPrefix(line, "rename to "):
+			file.NewPath = headerPath(strings.TrimPrefix(line, "rename to "), "")
+		case strings.HasPrefix(line, "copy to "):
+			file.NewPath = headerPath(strings.TrimPrefix(line, "copy to "), "")
+		case strings.HasPrefix(line, "--- "):
+			file.OldPath = headerPath(strings.TrimPrefix(line, "--- "), "a/")
+		case strings.HasPrefix(line, "+++ "):
+			file.NewPath = headerPath(strings.TrimPrefix(line, "+++ "), "b/")
+			body = i + 1
+		}
+	}
+	if body < len(lines) {
+		file.Body = strings.Join(lines[body:], "\n")
+	}
+
+	if added {
+		file.OldPath = ""
+	}
+	if deleted {
+		file.NewPath = ""
+	}
+	return file
+}
+
+// headerPath reads a path from a header line, unquoting it and trimming
+// prefix. Unquoted names end at a tab: git adds one after names with spaces,
+// and other tools a timestamp.
+func headerPath(s, prefix string) string {
+	if strings.HasPrefix(s, `"`) {
+		if unquoted, err := Unquote(s); err == nil {
+			s = unquoted
+		}
+	} else if i := strings.IndexByte(s, '\t'); i >= 0 {
+		s = s[:i]
+	}
+	if s == devNull {
+		return ""
+	}
+	return strings.TrimPrefix(s, prefix)
+}
+
+// ParseHeader reads the old and new paths from a "diff --git" line, without
+// their a/ and b/ prefixes. When neither path is quoted and the names have
+// spaces, the line is split where both sides name the same file, as git
+// does; a rename between such names is only told apart by the rename lines
+// that follow, which Split reads.
+func ParseHeader(line string) (oldPath, newPath string, ok bool) {
+	if !IsHeader(line) {
+		return "", "", false
+	}
+	rest := strings.TrimPrefix(line, headerPrefix)
+
+	if strings.HasPrefix(rest, `"`) {
+		end := closingQuote(rest)
+		if end < 0 {
+			return "", "", false
+		}
+		old, err := Unquote(rest[:end+1])
+		if err != nil {
+			return "", "", false
+		}
+		return strings.TrimPrefix(old, "a/"), headerPath(strings.TrimPrefix(rest[end+1:], " "), "b/"), true
+	}
+
+	if strings.HasSuffix(rest, `"`) {
+		if i := strings.LastIndex(rest, ` "`); i >= 0 {
+			if newPath, err := Unquote(rest[i+1:]); err == nil {
+				return strings.TrimPrefix(rest[:i], "a/"), strings.TrimPrefix(newPath, "b/"), true
+			}
+		}
+	}
+
+	for i := 0; i < len(rest); i++ {
+		if rest[i] != ' ' {
+			continue
+		}
+		oldName, newName := strings.TrimPrefix(rest[:i], "a/"), strings.TrimPrefix(rest[i+1:], "b/")
+		if oldName == newName {
+			return oldName, newName, true
+		}
+	}
+	if i := strings.Index(rest, " b/"); i >= 0 {
+		return strings.TrimPrefix(rest[:i], "a/"), rest[i+3:], true
+	}
+	if i := strings.IndexByte(rest, ' '); i >= 0 {
+		return rest[:i], rest[i+1:], true
+	}
+	return "", "", false
+}
+
+// closingQuote returns the index of the quote ending the quoted string s
+// starts with, or -1
+func closingQuote(s string) int {
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '"':
+			return i
+		}
+	}
+	return -1
+}
+
+// errInvalidQuoting is returned by Unquote for malformed quoted paths
+var errInvalidQuoting = errors.New("invalid quoted path")
+
+// unescapes maps the characters after a backslash in a quoted path to the
+// characters they stand for
+var unescapes = map[byte]byte{
+	'a': '\a', 'b': '\b', 't': '\t', 'n': '\n', 'v': '\v', 'f': '\f', 'r': '\r',
+	'"': '"', '\\': '\\',
+}
+
+// Unquote decodes a path quoted the way git quotes names with special
+// characters: in double quotes, with C escapes and three-digit octal escapes
+// for the bytes of non-ASCII characters
+func Unquote(s string) (string, error) {
+	if len(s) < 2 || s[0] != '"' || s[len(s)-1] != '"' {
+		return "", errInvalidQuoting
+	}
+	s = s[1 : len(s)-1]
+
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '"':
+			return "", errInvalidQuoting
+		case c != '\\':
+			b.WriteByte(c)
+			continue
+		}
+		i++
+		if i == len(s) {
+			return "", errInvalidQuoting
+		}
+		if unescaped, ok := unescapes[s[i]]; ok {
+			b.WriteByte(unescaped)
+			continue
+		}
+		if i+2 >= len(s) || !isOctal(s[i]) || s[i] > '3' || !isOctal(s[i+1]) || !isOctal(s[i+2]) {
+			return "", errInvalidQuoting
+		}
+		b.WriteByte((s[i]-'0')<<6 | (s[i+1]-'0')<<3 | (s[i+2] - '0'))
+		i += 2
+	}
+	return b.String(), nil
+}
+
+// isOctal reports whether c is an octal digit
+func isOctal(c byte) bool {
+	return c >= '0' && c <= '7'
+}
+
+// escapes maps the characters Quote escapes with a letter to that letter
+var escapes = map[byte]byte{
+	'\a': 'a', '\b': 'b', '\t': 't', '\n': 'n', '\v': 'v', '\f': 'f', '\r': 'r',
+	'"': '"', '\\': '\\',
+}
+
+// Quote returns path the way git writes it in diff headers: as is, unless it
+// has control characters, quotes, backslashes or non-ASCII characters, in
+// which case it's quoted and escaped
+func Quote(path string) string {
+	if !needsQuoting(path) {
+		return path
+	}
+
+	var b strings.Builder
+	b.WriteByte('"')
+	for i := 0; i < len(path); i++ {
+		c := path[i]
+		if escaped, ok := escapes[c]; ok {
+			b.WriteByte('\\')
+			b.WriteByte(escaped)
+		} else if c < 0x20 || c >= 0x7f {
+			b.WriteByte('\\')
+			b.WriteByte('0' + c>>6)
+			b.WriteByte('0' + c>>3&7)
+			b.WriteByte('0' + c&7)
+		} else {
+			b.WriteByte(c)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+// needsQuoting reports whether Quote has to quote path
+func needsQuoting(path string) bool {
+	for i := 0; i < len(path); i++ {
+		if c := path[i]; c < 0x20 || c >= 0x7f || c == '"' || c == '\\' {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,173 @@
+package diffparse
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// gitDiff is the output of git diff --cached -M for a rename, a deletion and
+// changes to files with names git quotes or leaves unquoted despite spaces
+const gitDiff = `diff --git "a/h\303\251llo.go" b/new name.go
+similarity index 100%
+rename from "h\303\251llo.go"
+rename to new name.go
+diff --git "a/q\"uote" "b/q\"uote"
+deleted file mode 100644
+index 4bcfe98..0000000
+--- "a/q\"uote"
++++ /dev/null
+@@ -1 +0,0 @@
+-d
+diff --git "a/t\tab" "b/t\tab"
+index 6178079..9eafba7 100644
+--- "a/t\tab"
++++ "b/t\tab"
+@@ -1 +1,2 @@
+ b
++e
+diff --git a/x y.txt b/x y.txt
+index 7898192..9ad2ebb 100644
+--- a/x y.txt
++++ b/x y.txt
+@@ -1 +1,2 @@
+ a
++a2
+`
+
+func TestSplit(t *testing.T) {
+	preamble, files := Split(gitDiff)
+
+	assert.Empty(t, preamble)
+	require.Len(t, files, 4)
+
+	assert.Equal(t, "héllo.go", files[0].OldPath)
+	assert.Equal(t, "new name.go", files[0].NewPath)
+	assert.Empty(t, files[0].Body)
+
+	assert.Equal(t, `q"uote`, files[1].OldPath)
+	assert.Empty(t, files[1].NewPath)
+	assert.Equal(t, `q"uote`, files[1].Path())
+	assert.Equal(t, "@@ -1 +0,0 @@\n-d", files[1].Body)
+
+	assert.Equal(t, "t\tab", files[2].Path())
+	assert.Equal(t, "x y.txt", files[3].OldPath)
+	assert.Equal(t, "x y.txt", files[3].NewPath)
+	assert.True(t, strings.HasSuffix(files[3].Raw, "+a2\n"))
+}
+
+func TestSplit_Preamble(t *testing.T) {
+	patch := "From abc Mon Sep 17 00:00:00 2001\nSubject: [PATCH] add\n\n---\n" +
+		"diff --git a/new.txt b/new.txt\nnew file mode 100644\nindex 0000000..1111111\n--- /dev/null\n+++ b/new.txt\n@@ -0,0 +1 @@\n+hi"
+
+	preamble, files := Split(patch)
+
+	assert.Equal(t, "From abc Mon Sep 17 00:00:00 2001\nSubject: [PATCH] add\n\n---\n", preamble)
+	require.Len(t, files, 1)
+	assert.Empty(t, files[0].OldPath)
+	assert.Equal(t, "new.txt", files[0].NewPath)
+
+	preamble, files = Split("some random content")
+	assert.Equal(t, "some random content", preamble)
+	assert.Empty(t, files)
+}
+
+func TestSplit_ChangedLinesLikeHeaders(t *testing.T) {
+	// Removed and added lines starting with "-- " and "++ " aren't headers
+	diff := "diff --git a/q.sql b/q.sql\nindex 1..2 100644\n--- a/q.sql\n+++ b/q.sql\n@@ -1 +1 @@\n--- old comment\n+++ new comment"
+
+	_, files := Split(diff)
+
+	require.Len(t, files, 1)
+	assert.Equal(t, "q.sql", files[0].OldPath)
+	assert.Equal(t, "q.sql", files[0].NewPath)
+	assert.Equal(t, "@@ -1 +1 @@\n--- old comment\n+++ new comment", files[0].Body)
+
+	// Nor are they in diffs without hunk headers
+	diff = "diff --git a/q.sql b/q.sql\n--- a/q.sql\n+++ b/q.sql\n--- old comment\n+++ new comment"
+	_, files = Split(diff)
+	require.Len(t, files, 1)
+	assert.Equal(t, "q.sql", files[0].NewPath)
+	assert.Equal(t, "--- old comment\n+++ new comment", files[0].Body)
+}
+
+func TestParseHeader(t *testing.T) {
+	testCases := []struct {
+		name    string
+		line    string
+		oldPath string
+		newPath string
+		ok      bool
+	}{
+		{"plain", "diff --git a/src/main.go b/src/main.go", "src/main.go", "src/main.go", true},
+		{"spaces", "diff --git a/my file b/x.go b/my file b/x.go", "my file b/x.go", "my file b/x.go", true},
+		{"quoted", `diff --git "a/x\ty" "b/x\ty"`, "x\ty", "x\ty", true},
+		{"quoted spaces", `diff --git "a/x y" "b/x y"`, "x y", "x y", true},
+		{"quoted new path", `diff --git a/x y "b/\303\251"`, "x y", "é", true},
+		{"rename with spaces", "diff --git a/old name b/new name", "old name", "new name", true},
+		{"no prefixes", "diff --git x.go x.go", "x.go", "x.go", true},
+		{"unterminated quote", `diff --git "a/x b/x`, "", "", false},
+		{"not a header", "index 123..456", "", "", false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			oldPath, newPath, ok := ParseHeader(tc.line)
+			assert.Equal(t, tc.ok, ok)
+			assert.Equal(t, tc.oldPath, oldPath)
+			assert.Equal(t, tc.newPath, newPath)
+		})
+	}
+}
+
+func TestQuote(t *testing.T) {
+	assert.Equal(t, "src/x y.go", Quote("src/x y.go"))
+	assert.Equal(t, `"a/x\ty"`, Quote("a/x\ty"))
+	assert.Equal(t, `"q\"uote\\"`, Quote(`q"uote\`))
+	assert.Equal(t, `"h\303\251llo"`, Quote("héllo"))
+
+	for _, quoted := range []string{`"x`, `"x\"`, `"x\9"`, `"x\477"`, `"a"b"`, `x`} {
+		_, err := Unquote(quoted)
+		assert.Error(t, err, quoted)
+	}
+}
+
+func FuzzSplit(f *testing.F) {
+	f.Add(gitDiff)
+	f.Add("preamble\ndiff --git a/x b/x\n@@ -1 +1 @@\n-a\n+b\n")
+	f.Add(`diff --git "a/\"" "b/\\"` + "\nrename from \"\\777\"\n")
+
+	f.Fuzz(func(t *testing.T, diff string) {
+		preamble, files := Split(diff)
+
+		raws := make([]string, len(files))
+		for i, file := range files {
+			assert.True(t, IsHeader(file.Raw), "every section starts with its header")
+			assert.True(t, strings.HasSuffix(file.Raw, file.Body))
+			raws[i] = file.Raw
+		}
+		assert.Equal(t, diff, preamble+strings.Join(raws, "\n"))
+	})
+}
+
+func FuzzQuote(f *testing.F) {
+	f.Add("x y")
+	f.Add("a/x\ty\n\"\\é\x7f")
+
+	f.Fuzz(func(t *testing.T, path string) {
+		quoted := Quote(path)
+		if quoted == path {
+			return
+		}
+		unquoted, err := Unquote(quoted)
+		require.NoError(t, err)
+		assert.Equal(t, path, unquoted)
+
+		oldPath, newPath, ok := ParseHeader("diff --git " + Quote("a/"+path) + " " + Quote("b/"+path))
+		assert.True(t, ok)
+		assert.Equal(t, path, oldPath)
+		assert.Equal(t, path, newPath)
+	})
+}
@@ -0,0 +1,194 @@
+// Package diffsource provides the diffs commit messages are generated from.
+//
+// A Source hides where a diff comes from (the index, the working tree, a
+// revision range, stdin or a patch file) so new origins can be added without
+// touching generation logic.
+package diffsource
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Source produces a unified diff to describe
+type Source interface {
+	// Name describes the source in messages, e.g. "staged changes"
+	Name() string
+	// Diff returns the unified diff, or an empty string when there are no changes
+	Diff() (string, error)
+}
+
+// Repository is the part of a git repository the repository-backed sources need
+type Repository interface {
+	GetDiff() (string, error)
+	StagedDiff() (string, error)
+	WorkingTreeDiff() (string, error)
+	RangeDiff(from, to string) (string, error)
+}
+
+// Auto is the staged changes, or the working tree changes when nothing is staged
+type Auto struct {
+	Repo Repository
+}
+
+// Name implements Source
+func (s Auto) Name() string { return "changes" }
+
+// Diff implements Source
+func (s Auto) Diff() (string, error) { return s.Repo.GetDiff() }
+
+// Staged is the changes in the index
+type Staged struct {
+	Repo Repository
+}
+
+// Name implements Source
+func (s Staged) Name() string { return "staged changes" }
+
+// Diff implements Source
+func (s Staged) Diff() (string, error) { return s.Repo.StagedDiff() }
+
+// WorkingTree is the changes in the working tree
+type WorkingTree struct {
+	Repo Repository
+}
+
+// Name implements Source
+func (s WorkingTree) Name() string { return "working tree changes" }
+
+// Diff implements Source
+func (s WorkingTree) Diff() (string, error) { return s.Repo.WorkingTreeDiff() }
+
+// Range is the changes between two revisions
+type Range struct {
+	Repo     Repository
+	From, To string
+}
+
+// Name implements Source
+func (s Range) Name() string { return fmt.Sprintf("changes in %s..%s", s.From, s.To) }
+
+// Diff implements Source
+func (s Range) Diff() (string, error) { return s.Repo.RangeDiff(s.From, s.To) }
+
+// Stdin is a diff piped into the process
+type Stdin struct {
+	Reader io.Reader
+}
+
+// Name implements Source
+func (s Stdin) Name() string { return "diff from stdin" }
+
+// Diff implements Source
+func (s Stdin) Diff() (string, error) {
+	data, err := io.ReadAll(s.Reader)
+	if err != nil {
+		return "", fmt.Errorf("failed to read diff from stdin: %w", err)
+	}
+	return ExtractDiff(string(data)), nil
+}
+
+// PatchFile is a diff or `git format-patch` file on disk
+type PatchFile struct {
+	Path string
+}
+
+// Name implements Source
+func (s PatchFile) Name() string { return "patch " + s.Path }
+
+// Diff implements Source
+func (s PatchFile) Diff() (string, error) {
+	data, err := os.ReadFile(filepath.Clean(s.Path))
+	if err != nil {
+		return "", fmt.Errorf("failed to read patch file: %w", err)
+	}
+	return ExtractDiff(string(data)), nil
+}
+
+// ExtractDiff returns the diff part of a patch, dropping the mail headers,
+// commit messages and signatures of `git format-patch` output. A patch series
+// in one file yields the diffs of all its patches. Plain diffs without
+// "diff --git" headers are returned unchanged.
+func ExtractDiff(patch string) string {
+	if !strings.HasPrefix(patch, "diff --git ") && !strings.Contains(patch, "\ndiff --git ") {
+		return patch
+	}
+
+	var kept []string
+	inDiff := false
+	for _, line := range strings.SplitAfter(patch, "\n") {
+		switch {
+		case strings.HasPrefix(line, "diff --git "):
+			inDiff = true
+		case line == "-- \n" || strings.HasPrefix(line, "From ") && isMboxSeparator(line):
+			// Signature or the start of the next mail in a series
+			inDiff = false
+		}
+		if inDiff {
+			kept = append(kept, line)
+		}
+	}
+	return strings.Join(kept, "")
+}
+
+// isMboxSeparator reports whether line starts a mail in format-patch output,
+// e.g. "From 1234abcd Mon Sep 17 00:00:00 2001"
+func isMboxSeparator(line string) bool {
+	fields := strings.Fields(line)
+	if len(fields) < 3 || len(fields[1]) != 40 {
+		return false
+	}
+	for _, c := range fields[1] {
+		if !strings.ContainsRune("0123456789abcdef", c) {
+			return false
+		}
+	}
+	return true
+}
+
+// Parse builds the Source described by spec:
+//
+//	auto | "" | staged | worktree | stdin | range:<from>..<to> | patch:<file>
+func Parse(spec string, repo Repository, stdin io.Reader) (Source, error) {
+	switch {
+	case spec == "" || spec == "auto":
+		return Auto{Repo: repo}, nil
+	case spec == "staged":
+		return Staged{Repo: repo}, nil
+	case spec == "worktree":
+		return WorkingTree{Repo: repo}, nil
+	case spec == "stdin":
+		return Stdin{Reader: stdin}, nil
+	case strings.HasPrefix(spec, "range:"):
+		from, to, ok := strings.Cut(strings.TrimPrefix(spec, "range:"), "..")
+		if !ok || from == "" {
+			return nil, fmt.Errorf("invalid range %q: expected range:<from>..<to>", spec)
+		}
+		if to == "" {
+			to = "HEAD"
+		}
+		return Range{Repo: repo, From: from, To: to}, nil
+	case strings.HasPrefix(spec, "patch:"):
+		patchPath := strings.TrimPrefix(spec, "patch:")
+		if patchPath == "" {
+			return nil, fmt.Errorf("invalid patch source %q: expected patch:<file>", spec)
+		}
+		return PatchFile{Path: patchPath}, nil
+	default:
+		return nil, fmt.Errorf("unknown diff source %q: supported sources are auto, staged, worktree, stdin, range:<from>..<to> and patch:<file>", spec)
+	}
+}
+
+// IsRepositoryChanges reports whether src describes uncommitted changes of
+// the repository, which can be staged and committed
+func IsRepositoryChanges(src Source) bool {
+	switch src.(type) {
+	case Auto, Staged, WorkingTree:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,143 @@
+package diffsource
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeRepo struct {
+	staged, worktree string
+	from, to         string
+}
+
+func (f *fakeRepo) GetDiff() (string, error) {
+	if f.staged != "" {
+		return f.staged, nil
+	}
+	return f.worktree, nil
+}
+
+func (f *fakeRepo) StagedDiff() (string, error)      { return f.staged, nil }
+func (f *fakeRepo) WorkingTreeDiff() (string, error) { return f.worktree, nil }
+
+func (f *fakeRepo) RangeDiff(from, to string) (string, error) {
+	f.from, f.to = from, to
+	return "range diff", nil
+}
+
+func TestParse(t *testing.T) {
+	repo := &fakeRepo{staged: "staged diff", worktree: "worktree diff"}
+
+	tests := []struct {
+		spec string
+		want string
+	}{
+		{"", "staged diff"},
+		{"auto", "staged diff"},
+		{"staged", "staged diff"},
+		{"worktree", "worktree diff"},
+		{"stdin", "piped diff"},
+		{"range:v1.0.0..main", "range diff"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			src, err := Parse(tt.spec, repo, strings.NewReader("piped diff"))
+			require.NoError(t, err)
+
+			diff, err := src.Diff()
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, diff)
+		})
+	}
+
+	assert.Equal(t, "v1.0.0", repo.from)
+	assert.Equal(t, "main", repo.to)
+}
+
+func TestParse_Invalid(t *testing.T) {
+	for _, spec := range []string{"bogus", "range:main", "range:..main", "patch:"} {
+		_, err := Parse(spec, &fakeRepo{}, nil)
+		assert.Error(t, err, spec)
+	}
+}
+
+func TestParse_RangeDefaultsToHead(t *testing.T) {
+	src, err := Parse("range:main..", &fakeRepo{}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, Range{Repo: &fakeRepo{}, From: "main", To: "HEAD"}, src)
+}
+
+func TestPatchFile(t *testing.T) {
+	patch := `From 1234567890abcdef1234567890abcdef12345678 Mon Sep 17 00:00:00 2001
+From: Jane Doe <jane@example.com>
+Subject: [PATCH] Add feature
+
+Longer description of the change.
+---
+ main.go | 1 +
+ 1 file changed, 1 insertion(+)
+
+diff --git a/main.go b/main.go
+index e69de29..4b825dc 100644
+--- a/main.go
++++ b/main.go
+@@ -0,0 +1 @@
++package main
+-- 
+2.43.0
+`
+	patchFile := filepath.Join(t.TempDir(), "0001-add-feature.patch")
+	require.NoError(t, os.WriteFile(patchFile, []byte(patch), 0o644))
+
+	src, err := Parse("patch:"+patchFile, &fakeRepo{}, nil)
+	require.NoError(t, err)
+
+	diff, err := src.Diff()
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(diff, "diff --git a/main.go b/main.go"))
+	assert.True(t, strings.HasSuffix(diff, "+package main\n"))
+	assert.NotContains(t, diff, "Subject:")
+	assert.NotContains(t, diff, "2.43.0")
+}
+
+func TestExtractDiff_Series(t *testing.T) {
+	series := `From 1111111111111111111111111111111111111111 Mon Sep 17 00:00:00 2001
+Subject: [PATCH 1/2] First
+
+---
+diff --git a/a.go b/a.go
++package a
+-- 
+2.43.0
+
+From 2222222222222222222222222222222222222222 Mon Sep 17 00:00:00 2001
+Subject: [PATCH 2/2] Second
+
+---
+diff --git a/b.go b/b.go
++package b
+-- 
+2.43.0
+`
+	assert.Equal(t, "diff --git a/a.go b/a.go\n+package a\ndiff --git a/b.go b/b.go\n+package b\n", ExtractDiff(series))
+}
+
+func TestExtractDiff_PlainDiff(t *testing.T) {
+	diff := "--- a/file.txt\n+++ b/file.txt\n@@ -1 +1 @@\n-old\n+new\n"
+	assert.Equal(t, diff, ExtractDiff(diff))
+}
+
+func TestIsRepositoryChanges(t *testing.T) {
+	assert.True(t, IsRepositoryChanges(Auto{}))
+	assert.True(t, IsRepositoryChanges(Staged{}))
+	assert.True(t, IsRepositoryChanges(WorkingTree{}))
+	assert.False(t, IsRepositoryChanges(Range{}))
+	assert.False(t, IsRepositoryChanges(Stdin{}))
+	assert.False(t, IsRepositoryChanges(PatchFile{}))
+}
@@ -0,0 +1,134 @@
+// Package drafts keeps the last generated message of each repository until
+// it is committed, so running commit-ai again over the same changes can offer
+// it instead of asking the model for a new one.
+package drafts
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/nseba/commit-ai/internal/filelock"
+)
+
+const (
+	// lockTimeout bounds how long a process waits for the drafts lock
+	lockTimeout = 2 * time.Second
+	// staleLockAge is the age after which an abandoned drafts lock is removed
+	staleLockAge = 10 * time.Second
+	// maxAge is how long a draft is kept; older drafts are dropped on save
+	maxAge = 30 * 24 * time.Hour
+)
+
+// Draft is a generated message that hasn't been committed yet
+type Draft struct {
+	// DiffHash identifies the changes the message was generated from
+	DiffHash string    `json:"diff_hash"`
+	Message  string    `json:"message"`
+	Time     time.Time `json:"time"`
+}
+
+// Matches reports whether the draft was generated from diff
+func (d *Draft) Matches(diff string) bool {
+	return d.DiffHash == Hash(diff)
+}
+
+// Hash returns the identifier of a diff stored with its drafts
+func Hash(diff string) string {
+	return fmt.Sprintf("sha256:%x", sha256.Sum256([]byte(diff)))
+}
+
+// Store keeps one draft per repository in a JSON file keyed by the
+// repository path
+type Store struct {
+	file string
+	now  func() time.Time
+}
+
+// New creates a store keeping its drafts in file
+func New(file string) *Store {
+	return &Store{file: file, now: time.Now}
+}
+
+// Get returns the draft of repo, or nil when there is none
+func (s *Store) Get(repo string) (*Draft, error) {
+	drafts, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+	d, ok := drafts[repo]
+	if !ok {
+		return nil, nil
+	}
+	return &d, nil
+}
+
+// Save replaces the draft of repo with message generated from diff
+func (s *Store) Save(repo, diff, message string) error {
+	return s.update(func(drafts map[string]Draft) {
+		drafts[repo] = Draft{DiffHash: Hash(diff), Message: message, Time: s.now().UTC().Truncate(time.Second)}
+	})
+}
+
+// Delete removes the draft of repo, if any
+func (s *Store) Delete(repo string) error {
+	if d, err := s.Get(repo); err == nil && d == nil {
+		return nil
+	}
+	return s.update(func(drafts map[string]Draft) {
+		delete(drafts, repo)
+	})
+}
+
+// update applies fn to the drafts under the drafts lock, dropping drafts
+// older than maxAge
+func (s *Store) update(fn func(map[string]Draft)) error {
+	if err := os.MkdirAll(filepath.Dir(s.file), 0o750); err != nil {
+		return fmt.Errorf("failed to create drafts directory: %w", err)
+	}
+	unlock, err := filelock.Acquire(s.file+".lock", lockTimeout, staleLockAge)
+	if err != nil {
+		return fmt.Errorf("failed to lock drafts: %w", err)
+	}
+	defer unlock()
+
+	drafts, err := s.load()
+	if err != nil {
+		drafts = make(map[string]Draft)
+	}
+	fn(drafts)
+	for repo, d := range drafts {
+		if s.now().Sub(d.Time) > maxAge {
+			delete(drafts, repo)
+		}
+	}
+
+	data, err := json.MarshalIndent(drafts, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode drafts: %w", err)
+	}
+	if err := os.WriteFile(s.file, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write drafts: %w", err)
+	}
+	return nil
+}
+
+// load reads the drafts file; a missing file has no drafts
+func (s *Store) load() (map[string]Draft, error) {
+	drafts := make(map[string]Draft)
+	data, err := os.ReadFile(s.file)
+	if errors.Is(err, os.ErrNotExist) {
+		return drafts, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read drafts: %w", err)
+	}
+	if err := json.Unmarshal(data, &drafts); err != nil {
+		return nil, fmt.Errorf("failed to decode drafts: %w", err)
+	}
+	return drafts, nil
+}
@@ -0,0 +1,63 @@
+package drafts
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStore(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "config", "drafts.json")
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	s := New(file)
+	s.now = func() time.Time { return now }
+
+	d, err := s.Get("/src/app")
+	require.NoError(t, err)
+	assert.Nil(t, d)
+
+	require.NoError(t, s.Save("/src/app", "diff a", "feat: add a"))
+	require.NoError(t, s.Save("/src/lib", "diff b", "fix: fix b"))
+
+	// Another process sees the same drafts
+	d, err = New(file).Get("/src/app")
+	require.NoError(t, err)
+	require.NotNil(t, d)
+	assert.Equal(t, "feat: add a", d.Message)
+	assert.Equal(t, now, d.Time)
+	assert.True(t, d.Matches("diff a"))
+	assert.False(t, d.Matches("diff a\n+more"))
+
+	require.NoError(t, s.Delete("/src/app"))
+	require.NoError(t, s.Delete("/src/app"))
+	d, err = s.Get("/src/app")
+	require.NoError(t, err)
+	assert.Nil(t, d)
+
+	// Old drafts are dropped with the next save
+	now = now.Add(maxAge + time.Hour)
+	require.NoError(t, s.Save("/src/app", "diff c", "docs: describe c"))
+	d, err = s.Get("/src/lib")
+	require.NoError(t, err)
+	assert.Nil(t, d)
+}
+
+func TestStore_CorruptFile(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "drafts.json")
+	require.NoError(t, os.WriteFile(file, []byte("{"), 0o600))
+
+	s := New(file)
+	_, err := s.Get("/src/app")
+	assert.ErrorContains(t, err, "failed to decode drafts")
+
+	// Saving starts over
+	require.NoError(t, s.Save("/src/app", "diff", "feat: add"))
+	d, err := s.Get("/src/app")
+	require.NoError(t, err)
+	require.NotNil(t, d)
+	assert.Equal(t, "feat: add", d.Message)
+}
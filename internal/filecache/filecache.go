@@ -0,0 +1,67 @@
+// Package filecache memoizes values derived from files, such as compiled
+// ignore matchers and parsed templates, so long-running processes don't
+// re-parse them on every request. An entry is reloaded when the file's
+// modification time or size changes.
+package filecache
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+type entry[T any] struct {
+	modTime time.Time
+	size    int64
+	value   T
+}
+
+// Cache maps file paths to values loaded from them. It is safe for
+// concurrent use.
+type Cache[T any] struct {
+	mu      sync.Mutex
+	entries map[string]entry[T]
+}
+
+// New creates an empty cache
+func New[T any]() *Cache[T] {
+	return &Cache[T]{entries: make(map[string]entry[T])}
+}
+
+// Get returns the cached value for path, calling load when the path is not
+// cached yet or the file changed since it was loaded. Load errors are not
+// cached.
+func (c *Cache[T]) Get(path string, load func(path string) (T, error)) (T, error) {
+	var zero T
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return zero, fmt.Errorf("failed to stat %s: %w", path, err)
+	}
+
+	c.mu.Lock()
+	cached, ok := c.entries[path]
+	c.mu.Unlock()
+	if ok && cached.modTime.Equal(info.ModTime()) && cached.size == info.Size() {
+		return cached.value, nil
+	}
+
+	value, err := load(path)
+	if err != nil {
+		return zero, err
+	}
+
+	c.mu.Lock()
+	c.entries[path] = entry[T]{modTime: info.ModTime(), size: info.Size(), value: value}
+	c.mu.Unlock()
+
+	return value, nil
+}
+
+// Invalidate drops the cached value for path
+func (c *Cache[T]) Invalidate(path string) {
+	c.mu.Lock()
+	delete(c.entries, path)
+	c.mu.Unlock()
+}
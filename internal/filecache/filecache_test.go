@@ -0,0 +1,59 @@
+package filecache
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCache_ReloadsOnChange(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "template.txt")
+	require.NoError(t, os.WriteFile(file, []byte("v1"), 0o644))
+
+	loads := 0
+	load := func(path string) (string, error) {
+		loads++
+		content, err := os.ReadFile(path)
+		return string(content), err
+	}
+
+	cache := New[string]()
+
+	value, err := cache.Get(file, load)
+	require.NoError(t, err)
+	assert.Equal(t, "v1", value)
+
+	value, err = cache.Get(file, load)
+	require.NoError(t, err)
+	assert.Equal(t, "v1", value)
+	assert.Equal(t, 1, loads)
+
+	// Changing the file invalidates the entry
+	require.NoError(t, os.WriteFile(file, []byte("v2 changed"), 0o644))
+	later := time.Now().Add(time.Second)
+	require.NoError(t, os.Chtimes(file, later, later))
+
+	value, err = cache.Get(file, load)
+	require.NoError(t, err)
+	assert.Equal(t, "v2 changed", value)
+	assert.Equal(t, 2, loads)
+
+	cache.Invalidate(file)
+	_, err = cache.Get(file, load)
+	require.NoError(t, err)
+	assert.Equal(t, 3, loads)
+}
+
+func TestCache_MissingFile(t *testing.T) {
+	cache := New[string]()
+
+	_, err := cache.Get(filepath.Join(t.TempDir(), "missing"), func(string) (string, error) {
+		t.Fatal("load must not be called for a missing file")
+		return "", nil
+	})
+	assert.Error(t, err)
+}
@@ -0,0 +1,63 @@
+// Package filelock serializes processes with lock files created exclusively
+// next to the file they guard.
+package filelock
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/nseba/commit-ai/internal/interrupt"
+)
+
+// retryInterval is how often a waiting process retries the lock
+const retryInterval = 50 * time.Millisecond
+
+const (
+	// DefaultTimeout bounds how long Lock waits for another process
+	DefaultTimeout = 5 * time.Second
+	// DefaultStaleAge is the age after which Lock removes a lock file left
+	// behind by a crashed process
+	DefaultStaleAge = 30 * time.Second
+)
+
+// Lock takes the lock file guarding file, file+".lock", with DefaultTimeout
+// and DefaultStaleAge, and returns a function releasing it. It suits files
+// that are read and written in well under a second.
+func Lock(file string) (func(), error) {
+	return Acquire(file+".lock", DefaultTimeout, DefaultStaleAge)
+}
+
+// Acquire creates the lock file lockPath, waiting up to timeout for another
+// process to release it, and returns a function releasing it. A lock file
+// older than staleAge is assumed to be left behind by a crashed process and
+// removed.
+func Acquire(lockPath string, timeout, staleAge time.Duration) (func(), error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		// #nosec G304 -- lock paths are derived from application controlled paths
+		f, err := os.OpenFile(lockPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+		if err == nil {
+			_ = f.Close()
+			// An interrupted process removes its lock instead of leaving it
+			// to go stale
+			removeHook := interrupt.OnInterrupt(func() { _ = os.Remove(lockPath) })
+			return func() {
+				removeHook()
+				_ = os.Remove(lockPath)
+			}, nil
+		}
+		if !os.IsExist(err) {
+			return nil, fmt.Errorf("failed to create lock file: %w", err)
+		}
+
+		if info, statErr := os.Stat(lockPath); statErr == nil && time.Since(info.ModTime()) > staleAge {
+			_ = os.Remove(lockPath)
+			continue
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("timed out waiting for lock %s", lockPath)
+		}
+		time.Sleep(retryInterval)
+	}
+}
@@ -0,0 +1,56 @@
+package filelock
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAcquire(t *testing.T) {
+	lockPath := filepath.Join(t.TempDir(), "file.lock")
+
+	unlock, err := Acquire(lockPath, time.Second, time.Minute)
+	require.NoError(t, err)
+	assert.FileExists(t, lockPath)
+
+	// A second holder times out while the lock is held
+	_, err = Acquire(lockPath, 100*time.Millisecond, time.Minute)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "timed out")
+
+	unlock()
+	assert.NoFileExists(t, lockPath)
+}
+
+func TestAcquire_StaleLock(t *testing.T) {
+	lockPath := filepath.Join(t.TempDir(), "file.lock")
+	require.NoError(t, os.WriteFile(lockPath, nil, 0o600))
+	stale := time.Now().Add(-time.Hour)
+	require.NoError(t, os.Chtimes(lockPath, stale, stale))
+
+	unlock, err := Acquire(lockPath, 100*time.Millisecond, time.Minute)
+	require.NoError(t, err)
+	unlock()
+}
+
+func TestLock(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "ledger.json")
+
+	unlock, err := Lock(file)
+	require.NoError(t, err)
+	assert.FileExists(t, file+".lock")
+	unlock()
+	assert.NoFileExists(t, file+".lock")
+
+	// A lock older than DefaultStaleAge is taken over
+	require.NoError(t, os.WriteFile(file+".lock", nil, 0o600))
+	stale := time.Now().Add(-2 * DefaultStaleAge)
+	require.NoError(t, os.Chtimes(file+".lock", stale, stale))
+	unlock, err = Lock(file)
+	require.NoError(t, err)
+	unlock()
+}
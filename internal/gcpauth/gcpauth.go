@@ -0,0 +1,318 @@
+// Package gcpauth obtains OAuth2 access tokens for Google Cloud APIs from
+// Application Default Credentials: a service account key, the user
+// credentials written by "gcloud auth application-default login", or the
+// metadata server on Google Cloud compute. It implements just enough of the
+// flows for commit-ai, without the Google client libraries.
+package gcpauth
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Scope grants access to Google Cloud APIs such as Vertex AI
+const Scope = "https://www.googleapis.com/auth/cloud-platform"
+
+const (
+	// expiryMargin renews tokens this long before they expire
+	expiryMargin = time.Minute
+	// jwtLifetime is the validity of the assertion for a service account
+	jwtLifetime = time.Hour
+)
+
+// Google's OAuth2 token endpoint and the metadata server's token endpoint;
+// tests replace them
+var (
+	defaultTokenURL = "https://oauth2.googleapis.com/token"
+	metadataURL     = "http://metadata.google.internal/computeMetadata/v1/instance/service-accounts/default/token"
+)
+
+// Credential file types
+const (
+	typeServiceAccount = "service_account"
+	typeAuthorizedUser = "authorized_user"
+)
+
+// credentialsFile is the JSON of a service account key or of gcloud's
+// application default user credentials
+type credentialsFile struct {
+	Type string `json:"type"`
+
+	// Service account keys
+	ProjectID    string `json:"project_id"`
+	ClientEmail  string `json:"client_email"`
+	PrivateKeyID string `json:"private_key_id"`
+	PrivateKey   string `json:"private_key"`
+	TokenURI     string `json:"token_uri"`
+
+	// User credentials
+	ClientID       string `json:"client_id"`
+	ClientSecret   string `json:"client_secret"`
+	RefreshToken   string `json:"refresh_token"`
+	QuotaProjectID string `json:"quota_project_id"`
+}
+
+// Credentials issues access tokens, caching each until shortly before it
+// expires
+type Credentials struct {
+	client *http.Client
+	// file is nil for the metadata server
+	file *credentialsFile
+	key  *rsa.PrivateKey
+
+	mu      sync.Mutex
+	token   string
+	expires time.Time
+}
+
+// Find loads the credentials in file or, when file is empty, looks up
+// Application Default Credentials: GOOGLE_APPLICATION_CREDENTIALS, then
+// gcloud's application default credentials, then the metadata server.
+// Requests are made with client.
+func Find(file string, client *http.Client) (*Credentials, error) {
+	if file == "" {
+		file = os.Getenv("GOOGLE_APPLICATION_CREDENTIALS")
+	}
+	if file == "" {
+		if wellKnown := wellKnownFile(); wellKnown != "" {
+			if _, err := os.Stat(wellKnown); err == nil {
+				file = wellKnown
+			}
+		}
+	}
+	if file == "" {
+		return &Credentials{client: client}, nil
+	}
+
+	data, err := os.ReadFile(filepath.Clean(file))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read Google credentials: %w", err)
+	}
+	var creds credentialsFile
+	if err := json.Unmarshal(data, &creds); err != nil {
+		return nil, fmt.Errorf("failed to parse Google credentials %s: %w", file, err)
+	}
+
+	c := &Credentials{client: client, file: &creds}
+	switch creds.Type {
+	case typeServiceAccount:
+		if c.key, err = parsePrivateKey(creds.PrivateKey); err != nil {
+			return nil, fmt.Errorf("invalid private key in %s: %w", file, err)
+		}
+	case typeAuthorizedUser:
+		if creds.RefreshToken == "" {
+			return nil, fmt.Errorf("no refresh token in %s; run `gcloud auth application-default login`", file)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported Google credentials type %q in %s", creds.Type, file)
+	}
+	return c, nil
+}
+
+// wellKnownFile returns where gcloud writes application default credentials
+func wellKnownFile() string {
+	if runtime.GOOS == "windows" {
+		if appData := os.Getenv("APPDATA"); appData != "" {
+			return filepath.Join(appData, "gcloud", "application_default_credentials.json")
+		}
+		return ""
+	}
+	if configDir := os.Getenv("CLOUDSDK_CONFIG"); configDir != "" {
+		return filepath.Join(configDir, "application_default_credentials.json")
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".config", "gcloud", "application_default_credentials.json")
+}
+
+// ProjectID returns the project named by the credentials, if any
+func (c *Credentials) ProjectID() string {
+	if c.file == nil {
+		return ""
+	}
+	if c.file.ProjectID != "" {
+		return c.file.ProjectID
+	}
+	return c.file.QuotaProjectID
+}
+
+// Token returns a valid access token, fetching a new one when needed
+func (c *Credentials) Token(ctx context.Context) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.token != "" && time.Now().Before(c.expires) {
+		return c.token, nil
+	}
+
+	var token string
+	var lifetime time.Duration
+	var err error
+	switch {
+	case c.file == nil:
+		token, lifetime, err = c.metadataToken(ctx)
+	case c.file.Type == typeServiceAccount:
+		token, lifetime, err = c.serviceAccountToken(ctx)
+	default:
+		token, lifetime, err = c.refreshToken(ctx)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	c.token = token
+	c.expires = time.Now().Add(lifetime - expiryMargin)
+	return token, nil
+}
+
+// serviceAccountToken exchanges a signed JWT for an access token
+func (c *Credentials) serviceAccountToken(ctx context.Context) (string, time.Duration, error) {
+	tokenURL := c.file.TokenURI
+	if tokenURL == "" {
+		tokenURL = defaultTokenURL
+	}
+	assertion, err := c.signJWT(tokenURL, time.Now())
+	if err != nil {
+		return "", 0, err
+	}
+	return c.exchange(ctx, tokenURL, url.Values{
+		"grant_type": {"urn:ietf:params:oauth:grant-type:jwt-bearer"},
+		"assertion":  {assertion},
+	})
+}
+
+// refreshToken exchanges the user's refresh token for an access token
+func (c *Credentials) refreshToken(ctx context.Context) (string, time.Duration, error) {
+	return c.exchange(ctx, defaultTokenURL, url.Values{
+		"grant_type":    {"refresh_token"},
+		"client_id":     {c.file.ClientID},
+		"client_secret": {c.file.ClientSecret},
+		"refresh_token": {c.file.RefreshToken},
+	})
+}
+
+// metadataToken asks the metadata server for the attached service account's
+// token
+func (c *Credentials) metadataToken(ctx context.Context) (string, time.Duration, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, metadataURL, nil)
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Metadata-Flavor", "Google")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return "", 0, fmt.Errorf("no Google credentials found: set GOOGLE_APPLICATION_CREDENTIALS or run `gcloud auth application-default login` (metadata server: %w)", err)
+	}
+	defer resp.Body.Close()
+	return decodeToken(resp)
+}
+
+// exchange posts an OAuth2 token request
+func (c *Credentials) exchange(ctx context.Context, tokenURL string, form url.Values) (string, time.Duration, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to fetch Google access token: %w", err)
+	}
+	defer resp.Body.Close()
+	return decodeToken(resp)
+}
+
+// decodeToken reads an OAuth2 token response
+func decodeToken(resp *http.Response) (string, time.Duration, error) {
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to read Google token response: %w", err)
+	}
+
+	var token struct {
+		AccessToken      string `json:"access_token"`
+		ExpiresIn        int    `json:"expires_in"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	_ = json.Unmarshal(body, &token)
+	if resp.StatusCode != http.StatusOK || token.AccessToken == "" {
+		reason := token.ErrorDescription
+		if reason == "" {
+			reason = token.Error
+		}
+		if reason == "" {
+			reason = resp.Status
+		}
+		return "", 0, fmt.Errorf("failed to fetch Google access token: %s", reason)
+	}
+	return token.AccessToken, time.Duration(token.ExpiresIn) * time.Second, nil
+}
+
+// signJWT returns the RS256-signed assertion a service account exchanges
+// for an access token
+func (c *Credentials) signJWT(audience string, now time.Time) (string, error) {
+	header, err := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT", "kid": c.file.PrivateKeyID})
+	if err != nil {
+		return "", err
+	}
+	claims, err := json.Marshal(map[string]any{
+		"iss":   c.file.ClientEmail,
+		"scope": Scope,
+		"aud":   audience,
+		"iat":   now.Unix(),
+		"exp":   now.Add(jwtLifetime).Unix(),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	unsigned := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+	digest := sha256.Sum256([]byte(unsigned))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, c.key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", fmt.Errorf("failed to sign token request: %w", err)
+	}
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// parsePrivateKey decodes the PEM private key of a service account key file
+func parsePrivateKey(data string) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode([]byte(data))
+	if block == nil {
+		return nil, errors.New("no PEM data")
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("not an RSA key")
+	}
+	return key, nil
+}
@@ -0,0 +1,159 @@
+package gcpauth
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeJSON writes v as a credentials file
+func writeJSON(t *testing.T, v any) string {
+	t.Helper()
+	data, err := json.Marshal(v)
+	require.NoError(t, err)
+	file := filepath.Join(t.TempDir(), "credentials.json")
+	require.NoError(t, os.WriteFile(file, data, 0o600))
+	return file
+}
+
+func TestServiceAccountToken(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		require.NoError(t, r.ParseForm())
+		assert.Equal(t, "urn:ietf:params:oauth:grant-type:jwt-bearer", r.PostForm.Get("grant_type"))
+
+		// The assertion is signed with the service account key
+		parts := strings.Split(r.PostForm.Get("assertion"), ".")
+		require.Len(t, parts, 3)
+		signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+		require.NoError(t, err)
+		digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+		assert.NoError(t, rsa.VerifyPKCS1v15(&key.PublicKey, crypto.SHA256, digest[:], signature))
+
+		claims, err := base64.RawURLEncoding.DecodeString(parts[1])
+		require.NoError(t, err)
+		assert.Contains(t, string(claims), `"iss":"ci@my-project.iam.gserviceaccount.com"`)
+		assert.Contains(t, string(claims), `"scope":"`+Scope+`"`)
+
+		w.Write([]byte(`{"access_token": "ya29.sa", "expires_in": 3600}`))
+	}))
+	defer server.Close()
+
+	file := writeJSON(t, map[string]string{
+		"type":         "service_account",
+		"project_id":   "my-project",
+		"client_email": "ci@my-project.iam.gserviceaccount.com",
+		"private_key":  string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})),
+		"token_uri":    server.URL,
+	})
+	creds, err := Find(file, server.Client())
+	require.NoError(t, err)
+	assert.Equal(t, "my-project", creds.ProjectID())
+
+	token, err := creds.Token(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "ya29.sa", token)
+
+	// The token is cached until shortly before it expires
+	_, err = creds.Token(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, int32(1), requests.Load())
+}
+
+func TestAuthorizedUserToken(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, r.ParseForm())
+		assert.Equal(t, "refresh_token", r.PostForm.Get("grant_type"))
+		assert.Equal(t, "1//refresh", r.PostForm.Get("refresh_token"))
+		w.Write([]byte(`{"access_token": "ya29.user", "expires_in": 3599}`))
+	}))
+	defer server.Close()
+	original := defaultTokenURL
+	defaultTokenURL = server.URL
+	defer func() { defaultTokenURL = original }()
+
+	// gcloud's application default credentials are found through CLOUDSDK_CONFIG
+	configDir := t.TempDir()
+	data := `{"type": "authorized_user", "client_id": "id", "client_secret": "secret", "refresh_token": "1//refresh", "quota_project_id": "billing-project"}`
+	require.NoError(t, os.WriteFile(filepath.Join(configDir, "application_default_credentials.json"), []byte(data), 0o600))
+	t.Setenv("GOOGLE_APPLICATION_CREDENTIALS", "")
+	t.Setenv("CLOUDSDK_CONFIG", configDir)
+
+	creds, err := Find("", server.Client())
+	require.NoError(t, err)
+	assert.Equal(t, "billing-project", creds.ProjectID())
+
+	token, err := creds.Token(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "ya29.user", token)
+}
+
+func TestMetadataToken(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Google", r.Header.Get("Metadata-Flavor"))
+		w.Write([]byte(`{"access_token": "ya29.gce", "expires_in": 1800}`))
+	}))
+	defer server.Close()
+	original := metadataURL
+	metadataURL = server.URL
+	defer func() { metadataURL = original }()
+
+	t.Setenv("GOOGLE_APPLICATION_CREDENTIALS", "")
+	t.Setenv("CLOUDSDK_CONFIG", t.TempDir())
+
+	creds, err := Find("", server.Client())
+	require.NoError(t, err)
+	assert.Empty(t, creds.ProjectID())
+
+	token, err := creds.Token(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "ya29.gce", token)
+}
+
+func TestTokenError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error": "invalid_grant", "error_description": "Token has been expired or revoked."}`))
+	}))
+	defer server.Close()
+	original := defaultTokenURL
+	defaultTokenURL = server.URL
+	defer func() { defaultTokenURL = original }()
+
+	file := writeJSON(t, map[string]string{"type": "authorized_user", "refresh_token": "1//old"})
+	creds, err := Find(file, server.Client())
+	require.NoError(t, err)
+
+	_, err = creds.Token(context.Background())
+	assert.ErrorContains(t, err, "Token has been expired or revoked.")
+}
+
+func TestFind_Invalid(t *testing.T) {
+	_, err := Find(writeJSON(t, map[string]string{"type": "external_account"}), http.DefaultClient)
+	assert.ErrorContains(t, err, `unsupported Google credentials type "external_account"`)
+
+	_, err = Find(writeJSON(t, map[string]string{"type": "service_account", "private_key": "garbage"}), http.DefaultClient)
+	assert.ErrorContains(t, err, "invalid private key")
+}
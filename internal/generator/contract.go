@@ -0,0 +1,173 @@
+package generator
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// maxContractRetries bounds how often the model is re-prompted after a
+// response that isn't just a commit message
+const maxContractRetries = 2
+
+// Re-prompts after an unusable response raise the temperature by
+// retryTemperatureStep per attempt, starting from CAI_TEMPERATURE or, when it
+// is unset, from defaultTemperature, a common provider default. Sampling the
+// same way again tends to fail the same way.
+const (
+	defaultTemperature   = 0.7
+	retryTemperatureStep = 0.3
+)
+
+var (
+	// markdownHeading matches Markdown headings, which git would also drop
+	// as comment lines
+	markdownHeading = regexp.MustCompile(`^#{1,6}(\s|$)`)
+	// preamble matches the openings of explanations around the message
+	preamble = regexp.MustCompile(`(?i)^(here('s| is| are)\b|sure\b|certainly\b|of course\b|okay\b|ok[,.!]|i('ve| have| would| will|'d)\b|this commit message\b|the (above|following) commit message\b|explanation:|note:|rationale:)`)
+	// alternativeLabel matches labels of alternative messages
+	alternativeLabel = regexp.MustCompile(`(?i)^(\*\*)?(option|alternative|alternatively|version|or)\b\s*\d*\s*[:.)]?(\*\*)?\s*$|^(\*\*)?(option|alternative|version)\s*\d+\s*[:.)]`)
+	// conventionalSubject matches a conventional commit subject, possibly
+	// numbered as the first of a list of alternatives
+	conventionalSubject = regexp.MustCompile(`^(\d+[.)]\s+)?(feat|fix|docs|style|refactor|perf|test|build|ci|chore|revert)(\([^)]*\))?!?: \S`)
+	// numberedItem matches the number of a list item
+	numberedItem = regexp.MustCompile(`^\d+[.)]\s`)
+	// quotedSubject matches a subject wrapped in quotes or backticks
+	quotedSubject = regexp.MustCompile("^([\"'`]).*([\"'`])$")
+	// letterOrDigit matches text with at least one letter or digit
+	letterOrDigit = regexp.MustCompile(`[\p{L}\p{N}]`)
+)
+
+// ContractError reports a response that still wasn't a bare commit message
+// after the re-prompts
+type ContractError struct {
+	// Response is the last response of the model
+	Response string
+	// Problems lists why it was rejected
+	Problems []string
+	// Attempts is the number of responses that were rejected
+	Attempts int
+	// Unusable is set when the last response couldn't be a commit message
+	// at all, see unusableResponse
+	Unusable bool
+}
+
+func (e *ContractError) Error() string {
+	return fmt.Sprintf("the model did not return a plain commit message after %d attempts: %s", e.Attempts, strings.Join(e.Problems, "; "))
+}
+
+// checkOutput returns the response as a commit message, or the problems that
+// keep it from being one. The expected shape is a single subject line,
+// optionally followed by a blank line and a body; explanations, Markdown
+// headings, code fences and multiple alternative messages are rejected.
+func checkOutput(response string) (string, []string) {
+	lines := strings.Split(strings.ReplaceAll(response, "\r\n", "\n"), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " \t")
+	}
+	message := strings.TrimSpace(strings.Join(lines, "\n"))
+	if message == "" {
+		return "", []string{"the response is empty"}
+	}
+	lines = strings.Split(message, "\n")
+
+	var problems []string
+	add := func(problem string) {
+		for _, p := range problems {
+			if p == problem {
+				return
+			}
+		}
+		problems = append(problems, problem)
+	}
+
+	// Only the text up to the subject line can be an explanation of the
+	// message: a body may well start a paragraph with "Note:" or list the
+	// subjects of squashed commits
+	subjectAt := 0
+	for i, line := range lines {
+		if conventionalSubject.MatchString(strings.TrimSpace(line)) {
+			subjectAt = i
+			break
+		}
+	}
+	for _, line := range lines[:subjectAt+1] {
+		if preamble.MatchString(strings.TrimSpace(line)) {
+			add("it starts with an explanation instead of the subject line")
+			break
+		}
+	}
+	if numberedItem.MatchString(strings.TrimSpace(lines[subjectAt])) {
+		add("it contains more than one subject line")
+	}
+
+	subject := lines[0]
+	if quotedSubject.MatchString(subject) && len(subject) > 1 {
+		add("the subject line is wrapped in quotes")
+	}
+	if strings.Contains(subject, "**") {
+		add("the subject line contains Markdown formatting")
+	}
+	if len(lines) > 1 && lines[1] != "" {
+		add("the subject line is not followed by a blank line")
+	}
+
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case markdownHeading.MatchString(trimmed):
+			add(fmt.Sprintf("line %d is a Markdown heading", i+1))
+		case strings.HasPrefix(trimmed, "```"):
+			add("it contains a code fence")
+		case alternativeLabel.MatchString(trimmed):
+			add("it offers alternative messages")
+		}
+	}
+	return message, problems
+}
+
+// unusableResponse returns why response can't be a commit message at all,
+// whatever CAI_OUTPUT_CONTRACT says, or "" when it can: it is empty, has no
+// letters or digits, or merely repeats the prompt. A response repeats the
+// prompt when it contains the diff delimiters or all of its lines are lines
+// of the prompt template, the instructions.
+func unusableResponse(response string, instructions map[string]bool) string {
+	text := strings.TrimSpace(response)
+	switch {
+	case text == "":
+		return "the response is empty"
+	case !letterOrDigit.MatchString(text):
+		return "the response contains only punctuation"
+	case strings.Contains(text, "UNTRUSTED DIFF"):
+		return "the response repeats the prompt"
+	}
+
+	for _, line := range strings.Split(text, "\n") {
+		if line = strings.TrimSpace(line); line != "" && !instructions[line] {
+			return ""
+		}
+	}
+	return "the response repeats the prompt"
+}
+
+// unusableFeedback asks the model for a commit message after an unusable
+// response
+func unusableFeedback(problem string) string {
+	return fmt.Sprintf("The previous response was rejected because %s. "+
+		"Describe the changes in the diff above as a commit message: one subject line, "+
+		"optionally followed by a blank line and a body.", "it "+strings.TrimPrefix(problem, "the response "))
+}
+
+// contractFeedback asks the model to fix a rejected response
+func contractFeedback(response string, problems []string) string {
+	var b strings.Builder
+	b.WriteString("The previous response was rejected because it is not just a commit message:\n")
+	b.WriteString(response)
+	b.WriteString("\n\nProblems:\n")
+	for _, problem := range problems {
+		b.WriteString("- " + problem + "\n")
+	}
+	b.WriteString("\nOutput only the commit message: one subject line, optionally followed by a blank line and a body. ")
+	b.WriteString("Do not add explanations, headings, code fences or alternatives.")
+	return b.String()
+}
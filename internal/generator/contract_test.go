@@ -0,0 +1,205 @@
+package generator
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"text/template"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nseba/commit-ai/internal/config"
+)
+
+func TestCheckOutput_Accepted(t *testing.T) {
+	accepted := map[string]string{
+		"feat: add login":   "feat: add login",
+		"  fix: typo  \r\n": "fix: typo",
+		"feat(api)!: drop v1\n\nThe v1 endpoints are gone.\n\nBREAKING CHANGE: clients must use v2": "feat(api)!: drop v1\n\nThe v1 endpoints are gone.\n\nBREAKING CHANGE: clients must use v2",
+		"refactor: split parser\n\n- Move lexer to its own file\n- fix: is not a subject here":      "refactor: split parser\n\n- Move lexer to its own file\n- fix: is not a subject here",
+		"Update README": "Update README",
+		"feat: add login\n\nNote: sessions expire after an hour.":             "feat: add login\n\nNote: sessions expire after an hour.",
+		"fix: handle timeouts\n\nOK, the retry no longer loops forever.":      "fix: handle timeouts\n\nOK, the retry no longer loops forever.",
+		"feat: add login\n\nSquashed commits:\nfix: handle errors\nfix: typo": "feat: add login\n\nSquashed commits:\nfix: handle errors\nfix: typo",
+	}
+	for response, expected := range accepted {
+		message, problems := checkOutput(response)
+		assert.Empty(t, problems, response)
+		assert.Equal(t, expected, message)
+	}
+}
+
+func TestCheckOutput_Rejected(t *testing.T) {
+	rejected := map[string]string{
+		"": "the response is empty",
+		"Here is a commit message for your change:\n\nfeat: x":         "starts with an explanation",
+		"Sure! feat: add login":                                        "starts with an explanation",
+		"## Commit Message\n\nfeat: add login":                         "Markdown heading",
+		"```\nfeat: add login\n```":                                    "code fence",
+		"feat: add login\n\nor\n\nfeat: implement login":               "alternative messages",
+		"Option 1: feat: add login\nOption 2: feat: login":             "alternative messages",
+		"1. feat: add login\n2. feat: implement login":                 "more than one subject line",
+		"Certainly.\n\n1. feat: add login\n\n2. feat: implement login": "more than one subject line",
+		"\"feat: add login\"":                                          "wrapped in quotes",
+		"**feat: add login**":                                          "Markdown formatting",
+		"feat: add login\nAdds the form.":                              "not followed by a blank line",
+	}
+	for response, problem := range rejected {
+		_, problems := checkOutput(response)
+		assert.Contains(t, strings.Join(problems, "\n"), problem, response)
+	}
+}
+
+// contractServer answers OpenAI chat completion requests with responses in
+// turn, recording the prompts
+func contractServer(t *testing.T, responses []string, prompts *[]string) *httptest.Server {
+	t.Helper()
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Messages []struct {
+				Content string `json:"content"`
+			} `json:"messages"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		*prompts = append(*prompts, body.Messages[len(body.Messages)-1].Content)
+
+		content := responses[min(len(*prompts), len(responses))-1]
+		data, err := json.Marshal(map[string]any{"choices": []any{map[string]any{"message": map[string]string{"content": content}}}})
+		require.NoError(t, err)
+		w.Write(data)
+	}))
+}
+
+func newContractGenerator(t *testing.T, url string) *Generator {
+	t.Helper()
+	cfg := config.DefaultConfig()
+	cfg.Provider = "openai"
+	cfg.APIURL = url
+	cfg.APIToken = "test-token"
+	gen, err := New(cfg, filepath.Join(t.TempDir(), "config.toml"))
+	require.NoError(t, err)
+	return gen
+}
+
+func TestGenerate_OutputContractRetry(t *testing.T) {
+	var prompts []string
+	server := contractServer(t, []string{"Here are two options:\n\n1. feat: add login\n2. feat: implement login", "feat: add login"}, &prompts)
+	defer server.Close()
+
+	gen := newContractGenerator(t, server.URL)
+	message, err := gen.Generate("+login")
+	require.NoError(t, err)
+	assert.Equal(t, "feat: add login", message)
+
+	require.Len(t, prompts, 2)
+	assert.Contains(t, prompts[1], "The previous response was rejected")
+	assert.Contains(t, prompts[1], "- it starts with an explanation instead of the subject line")
+	assert.Contains(t, prompts[1], "- it contains more than one subject line")
+}
+
+func TestGenerate_OutputContractExhausted(t *testing.T) {
+	var prompts []string
+	server := contractServer(t, []string{"### Commit\nfeat: add login"}, &prompts)
+	defer server.Close()
+
+	gen := newContractGenerator(t, server.URL)
+	_, err := gen.Generate("+login")
+	var contractErr *ContractError
+	require.ErrorAs(t, err, &contractErr)
+	assert.Equal(t, maxContractRetries+1, contractErr.Attempts)
+	assert.Len(t, prompts, maxContractRetries+1)
+	assert.Contains(t, err.Error(), "did not return a plain commit message after 3 attempts")
+
+	// With the contract off, the response is used as is
+	prompts = nil
+	gen.config.OutputContract = config.OutputContractOff
+	message, err := gen.Generate("+login")
+	require.NoError(t, err)
+	assert.Equal(t, "### Commit\nfeat: add login", message)
+	assert.Len(t, prompts, 1)
+}
+
+func TestUnusableResponse(t *testing.T) {
+	instructions := templateLines(template.Must(newTemplate("test", getDefaultTemplate())))
+
+	unusable := map[string]string{
+		"":                             "empty",
+		"  \n\t":                       "empty",
+		"...":                          "only punctuation",
+		"- * -":                        "only punctuation",
+		"Commit Message:":              "repeats the prompt",
+		"Git Diff:\n\nCommit Message:": "repeats the prompt",
+		"<<<BEGIN UNTRUSTED DIFF 0123>>>\n+login": "repeats the prompt",
+	}
+	for response, problem := range unusable {
+		assert.Contains(t, unusableResponse(response, instructions), problem, response)
+	}
+
+	for _, response := range []string{"feat: add login", "Update README", "fix: 404", "docs: Commit Message: fix typo"} {
+		assert.Empty(t, unusableResponse(response, instructions), response)
+	}
+}
+
+func TestGenerate_UnusableResponseRetry(t *testing.T) {
+	var temperatures []any
+	var prompts []string
+	responses := []string{"", "...", "feat: add login"}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Temperature any `json:"temperature"`
+			Messages    []struct {
+				Content string `json:"content"`
+			} `json:"messages"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		temperatures = append(temperatures, body.Temperature)
+		prompts = append(prompts, body.Messages[len(body.Messages)-1].Content)
+
+		content := responses[min(len(prompts), len(responses))-1]
+		data, err := json.Marshal(map[string]any{"choices": []any{map[string]any{"message": map[string]string{"content": content}}}})
+		require.NoError(t, err)
+		w.Write(data)
+	}))
+	defer server.Close()
+
+	// Unusable responses are retried even with the contract off
+	gen := newContractGenerator(t, server.URL)
+	gen.config.OutputContract = config.OutputContractOff
+	temperature := 0.0
+	gen.config.Temperature = &temperature
+
+	message, err := gen.Generate("+login")
+	require.NoError(t, err)
+	assert.Equal(t, "feat: add login", message)
+	assert.Equal(t, []any{0.0, 0.3, 0.6}, temperatures)
+	require.Len(t, prompts, 3)
+	assert.Contains(t, prompts[1], "rejected because it is empty")
+	assert.Contains(t, prompts[2], "rejected because it contains only punctuation")
+
+	// The next request uses the configured temperature again
+	responses = []string{""}
+	prompts, temperatures = nil, nil
+	_, err = gen.Generate("+login")
+	var contractErr *ContractError
+	require.ErrorAs(t, err, &contractErr)
+	assert.True(t, contractErr.Unusable)
+	assert.Equal(t, maxContractRetries+1, contractErr.Attempts)
+	assert.Equal(t, 0.0, temperatures[0])
+}
+
+func TestGenerate_Normalize(t *testing.T) {
+	var prompts []string
+	server := contractServer(t, []string{"feat(auth): Add login flow.\n\nStore the session."}, &prompts)
+	defer server.Close()
+
+	gen := newContractGenerator(t, server.URL)
+	gen.config.Normalize = []string{config.NormalizeLowercaseAfterType, config.NormalizeStripTrailingPeriod}
+	message, err := gen.Generate("+login")
+	require.NoError(t, err)
+	assert.Equal(t, "feat(auth): add login flow\n\nStore the session.", message)
+	assert.Len(t, prompts, 1, "normalizing doesn't re-prompt")
+}
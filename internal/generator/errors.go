@@ -0,0 +1,174 @@
+package generator
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// ErrorKind classifies provider failures
+type ErrorKind string
+
+// Provider error kinds
+const (
+	ErrorAuth           ErrorKind = "auth"
+	ErrorQuota          ErrorKind = "quota"
+	ErrorModelNotFound  ErrorKind = "model-not-found"
+	ErrorContextTooLong ErrorKind = "context-too-long"
+	ErrorNetwork        ErrorKind = "network"
+	ErrorServer         ErrorKind = "server"
+	ErrorRequest        ErrorKind = "request"
+)
+
+// maxErrorMessage bounds how much of an unparsed response body is shown
+const maxErrorMessage = 300
+
+// ProviderError is a provider failure normalized into a kind, with the
+// provider's own message extracted from the response body
+type ProviderError struct {
+	Kind     ErrorKind
+	Provider string
+	Model    string
+	URL      string
+	// Status is the HTTP status code, zero for network failures
+	Status  int
+	Message string
+	Err     error
+}
+
+func (e *ProviderError) Error() string {
+	if e.Status == 0 {
+		return e.Err.Error()
+	}
+	return fmt.Sprintf("%s API error (status %d, %s): %s", providerLabel(e.Provider), e.Status, e.Kind, e.Message)
+}
+
+func (e *ProviderError) Unwrap() error { return e.Err }
+
+// Unavailable reports whether the failure means the endpoint is down rather
+// than rejecting the request
+func (e *ProviderError) Unavailable() bool {
+	return e.Kind == ErrorNetwork || e.Kind == ErrorServer
+}
+
+// Hint suggests how to fix the failure
+func (e *ProviderError) Hint() string {
+	switch e.Kind {
+	case ErrorAuth:
+		if e.Provider == providerVertex {
+			return "the Google credentials were rejected; check CAI_VERTEX_CREDENTIALS or run `gcloud auth application-default login`, and that the account may use Vertex AI in the project"
+		}
+		return "the API token was rejected; check CAI_API_TOKEN (or the token of the active [providers.*] section)"
+	case ErrorQuota:
+		return "the API key hit its rate limit or quota; retry later, check the account's billing, or set CAI_RATE_LIMIT_RPM/CAI_RATE_LIMIT_TPM to stay below it"
+	case ErrorModelNotFound:
+		if e.Provider == providerOllama {
+			return fmt.Sprintf("model %s is not installed; run `ollama pull %s` or set CAI_MODEL to a model listed by `ollama list`", e.Model, e.Model)
+		}
+		return fmt.Sprintf("model %s is not available to this API key; set CAI_MODEL to a model you can access", e.Model)
+	case ErrorContextTooLong:
+		return fmt.Sprintf("the diff is too large for %s; enable CAI_COMPRESS_DIFF, add generated files to .caiignore, or describe fewer files with --only", e.Model)
+	case ErrorNetwork:
+		var netErr net.Error
+		if errors.As(e.Err, &netErr) && netErr.Timeout() {
+			return fmt.Sprintf("%s did not answer in time; raise CAI_TIMEOUT_SECONDS or use a smaller model", e.URL)
+		}
+		if e.Provider == providerOllama {
+			return fmt.Sprintf("cannot reach %s; check CAI_API_URL and that Ollama is running (`ollama serve`)", e.URL)
+		}
+		return fmt.Sprintf("cannot reach %s; check CAI_API_URL and your network connection", e.URL)
+	case ErrorServer:
+		return "the provider had an internal error; retry later or set CAI_FALLBACK_PROFILE to use another provider"
+	default:
+		return ""
+	}
+}
+
+// networkError wraps a failure to reach the provider
+func networkError(provider, model, url string, err error) *ProviderError {
+	return &ProviderError{Kind: ErrorNetwork, Provider: provider, Model: model, URL: url, Err: err}
+}
+
+// classifyResponse turns an unsuccessful API response into a ProviderError
+func classifyResponse(provider, model, url string, status int, body []byte) *ProviderError {
+	message, code := parseErrorBody(body)
+	if message == "" {
+		message = http.StatusText(status)
+	}
+	lower := strings.ToLower(message + " " + code)
+
+	kind := ErrorRequest
+	switch {
+	case status == http.StatusUnauthorized || status == http.StatusForbidden:
+		kind = ErrorAuth
+	case status == http.StatusTooManyRequests || strings.Contains(lower, "insufficient_quota"):
+		kind = ErrorQuota
+	case code == "model_not_found" || (strings.Contains(lower, "model") && strings.Contains(lower, "not found")) ||
+		(status == http.StatusNotFound && strings.Contains(lower, "model")):
+		kind = ErrorModelNotFound
+	case code == "context_length_exceeded" || strings.Contains(lower, "context length") ||
+		strings.Contains(lower, "maximum context") || strings.Contains(lower, "too many tokens"):
+		kind = ErrorContextTooLong
+	case status >= http.StatusInternalServerError:
+		kind = ErrorServer
+	}
+
+	return &ProviderError{
+		Kind:     kind,
+		Provider: provider,
+		Model:    model,
+		URL:      url,
+		Status:   status,
+		Message:  message,
+		Err:      fmt.Errorf("%s API error (status %d)", providerLabel(provider), status),
+	}
+}
+
+// parseErrorBody extracts the message and error code from the error bodies
+// of Ollama ({"error": "..."}) and OpenAI ({"error": {"message", "code"}}),
+// falling back to the trimmed raw body
+func parseErrorBody(body []byte) (message, code string) {
+	var ollama struct {
+		Error string `json:"error"`
+	}
+	if json.Unmarshal(body, &ollama) == nil && ollama.Error != "" {
+		return ollama.Error, ""
+	}
+
+	var openai struct {
+		Error struct {
+			Message string `json:"message"`
+			Type    string `json:"type"`
+			Code    any    `json:"code"`
+		} `json:"error"`
+	}
+	if json.Unmarshal(body, &openai) == nil && openai.Error.Message != "" {
+		if c, ok := openai.Error.Code.(string); ok {
+			code = c
+		}
+		if code == "" {
+			code = openai.Error.Type
+		}
+		return openai.Error.Message, code
+	}
+
+	message = strings.TrimSpace(string(body))
+	if len(message) > maxErrorMessage {
+		message = message[:maxErrorMessage] + "..."
+	}
+	return message, ""
+}
+
+// providerLabel returns the provider name as used in error messages
+func providerLabel(provider string) string {
+	switch provider {
+	case providerOpenAI:
+		return "OpenAI"
+	case providerVertex:
+		return "Vertex AI"
+	}
+	return provider
+}
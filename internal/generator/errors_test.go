@@ -0,0 +1,63 @@
+package generator
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClassifyResponse(t *testing.T) {
+	tests := []struct {
+		name     string
+		provider string
+		status   int
+		body     string
+		kind     ErrorKind
+		message  string
+	}{
+		{"ollama model missing", providerOllama, http.StatusNotFound, `{"error":"model 'llama2' not found, try pulling it first"}`, ErrorModelNotFound, "model 'llama2' not found, try pulling it first"},
+		{"openai auth", providerOpenAI, http.StatusUnauthorized, `{"error":{"message":"Incorrect API key provided","type":"invalid_request_error","code":"invalid_api_key"}}`, ErrorAuth, "Incorrect API key provided"},
+		{"openai quota", providerOpenAI, http.StatusTooManyRequests, `{"error":{"message":"You exceeded your current quota","type":"insufficient_quota","code":"insufficient_quota"}}`, ErrorQuota, "You exceeded your current quota"},
+		{"openai model missing", providerOpenAI, http.StatusNotFound, `{"error":{"message":"The model gpt-5 does not exist","code":"model_not_found"}}`, ErrorModelNotFound, "The model gpt-5 does not exist"},
+		{"openai context", providerOpenAI, http.StatusBadRequest, `{"error":{"message":"This model's maximum context length is 8192 tokens","code":"context_length_exceeded"}}`, ErrorContextTooLong, "This model's maximum context length is 8192 tokens"},
+		{"server", providerOllama, http.StatusBadGateway, `<html>bad gateway</html>`, ErrorServer, "<html>bad gateway</html>"},
+		{"empty body", providerOllama, http.StatusServiceUnavailable, ``, ErrorServer, "Service Unavailable"},
+		{"other request error", providerOpenAI, http.StatusBadRequest, `{"error":{"message":"invalid temperature"}}`, ErrorRequest, "invalid temperature"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := classifyResponse(tt.provider, "llama2", "http://localhost:11434", tt.status, []byte(tt.body))
+			assert.Equal(t, tt.kind, err.Kind)
+			assert.Equal(t, tt.message, err.Message)
+			assert.Contains(t, err.Error(), fmt.Sprintf("status %d", tt.status))
+		})
+	}
+}
+
+func TestProviderError_Hint(t *testing.T) {
+	err := classifyResponse(providerOllama, "llama2", "http://localhost:11434", http.StatusNotFound, []byte(`{"error":"model 'llama2' not found"}`))
+	assert.Contains(t, err.Hint(), "ollama pull llama2")
+	assert.False(t, err.Unavailable())
+
+	netErr := networkError(providerOllama, "llama2", "http://localhost:11434", errors.New("connection refused"))
+	assert.Contains(t, netErr.Hint(), "ollama serve")
+	assert.True(t, netErr.Unavailable())
+	assert.Equal(t, "connection refused", netErr.Error())
+
+	assert.Empty(t, (&ProviderError{Kind: ErrorRequest}).Hint())
+}
+
+func TestProviderError_Wrapped(t *testing.T) {
+	err := fmt.Errorf("failed to generate commit message: %w",
+		classifyResponse(providerOpenAI, "gpt-4", "https://api.openai.com", http.StatusUnauthorized, []byte(`{}`)))
+
+	var providerErr *ProviderError
+	require.True(t, errors.As(err, &providerErr))
+	assert.Equal(t, ErrorAuth, providerErr.Kind)
+	assert.Contains(t, err.Error(), "OpenAI API error (status 401, auth)")
+}
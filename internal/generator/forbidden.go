@@ -0,0 +1,63 @@
+package generator
+
+import (
+	"fmt"
+	"strings"
+)
+
+// maxForbiddenRetries bounds how often the model is re-prompted after a
+// message matching CAI_FORBIDDEN_PATTERNS
+const maxForbiddenRetries = 2
+
+// ForbiddenContentError reports a message that still matched
+// CAI_FORBIDDEN_PATTERNS after the re-prompts
+type ForbiddenContentError struct {
+	// Matches holds the forbidden text of the last message
+	Matches []string
+	// Attempts is the number of messages that were rejected
+	Attempts int
+}
+
+func (e *ForbiddenContentError) Error() string {
+	return fmt.Sprintf("the generated message still contained forbidden content after %d attempts: %s",
+		e.Attempts, quoteAll(e.Matches))
+}
+
+// avoidForbidden re-prompts the model while message matches
+// CAI_FORBIDDEN_PATTERNS, up to maxForbiddenRetries times, and fails when it
+// still does
+func (g *Generator) avoidForbidden(prompt string, instructions map[string]bool, message string) (string, error) {
+	for attempt := 1; ; attempt++ {
+		matches := g.config.ForbiddenMatches(message)
+		if len(matches) == 0 {
+			return message, nil
+		}
+		g.debugf("message %d contains forbidden content %s:\n%s", attempt, quoteAll(matches), message)
+		if attempt > maxForbiddenRetries {
+			return "", &ForbiddenContentError{Matches: matches, Attempts: attempt}
+		}
+
+		var err error
+		message, err = g.completeMessage(prompt+"\n\n"+forbiddenFeedback(message, matches), instructions)
+		if err != nil {
+			return "", err
+		}
+	}
+}
+
+// forbiddenFeedback asks the model to rewrite a message without the
+// forbidden text
+func forbiddenFeedback(message string, matches []string) string {
+	return fmt.Sprintf("The previous commit message was rejected because it mentions %s, which must not appear in commit messages:\n%s\n\n"+
+		"Rewrite it without these terms or anything that identifies them, and output only the commit message.",
+		quoteAll(matches), message)
+}
+
+// quoteAll quotes and joins texts for messages
+func quoteAll(texts []string) string {
+	quoted := make([]string, 0, len(texts))
+	for _, text := range texts {
+		quoted = append(quoted, fmt.Sprintf("%q", text))
+	}
+	return strings.Join(quoted, ", ")
+}
@@ -0,0 +1,39 @@
+package generator
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerate_ForbiddenRetry(t *testing.T) {
+	var prompts []string
+	server := contractServer(t, []string{"feat: port Project Falcon login", "feat: port the new login flow"}, &prompts)
+	defer server.Close()
+
+	gen := newContractGenerator(t, server.URL)
+	gen.config.ForbiddenPatterns = []string{`project\s*falcon`}
+	message, err := gen.Generate("+login")
+	require.NoError(t, err)
+	assert.Equal(t, "feat: port the new login flow", message)
+
+	require.Len(t, prompts, 2)
+	assert.Contains(t, prompts[1], `rejected because it mentions "Project Falcon"`)
+}
+
+func TestGenerate_ForbiddenExhausted(t *testing.T) {
+	var prompts []string
+	server := contractServer(t, []string{"fix: handle ACME Corp invoices"}, &prompts)
+	defer server.Close()
+
+	gen := newContractGenerator(t, server.URL)
+	gen.config.ForbiddenPatterns = []string{"acme corp"}
+	_, err := gen.Generate("+invoice")
+	var forbiddenErr *ForbiddenContentError
+	require.ErrorAs(t, err, &forbiddenErr)
+	assert.Equal(t, []string{"ACME Corp"}, forbiddenErr.Matches)
+	assert.Equal(t, maxForbiddenRetries+1, forbiddenErr.Attempts)
+	assert.Len(t, prompts, maxForbiddenRetries+1)
+	assert.Contains(t, err.Error(), `forbidden content after 3 attempts: "ACME Corp"`)
+}
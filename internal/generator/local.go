@@ -0,0 +1,188 @@
+//go:build local
+
+package generator
+
+/*
+#cgo LDFLAGS: -lllama
+#include <stdlib.h>
+#include <llama.h>
+
+// quietLog drops llama.cpp's progress logging, which would otherwise flood
+// stderr on every generation
+static void quietLog(enum ggml_log_level level, const char *text, void *data) {}
+
+static void silenceLlama(void) { llama_log_set(quietLog, NULL); }
+*/
+import "C"
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+	"unsafe"
+)
+
+// localMaxTokens bounds the length of a locally generated response
+const localMaxTokens = 512
+
+var (
+	// localModels caches loaded models by path, so regenerations and
+	// translations in one process load the file only once
+	localModels   = map[string]*C.struct_llama_model{}
+	localModelsMu sync.Mutex
+	localInit     sync.Once
+)
+
+// loadLocalModel returns the model at path, loading it on first use
+func loadLocalModel(path string) (*C.struct_llama_model, error) {
+	localInit.Do(func() {
+		C.silenceLlama()
+		C.llama_backend_init()
+	})
+
+	localModelsMu.Lock()
+	defer localModelsMu.Unlock()
+	if model, ok := localModels[path]; ok {
+		return model, nil
+	}
+
+	cPath := C.CString(path)
+	defer C.free(unsafe.Pointer(cPath))
+	model := C.llama_model_load_from_file(cPath, C.llama_model_default_params())
+	if model == nil {
+		return nil, fmt.Errorf("failed to load model %s", path)
+	}
+	localModels[path] = model
+	return model, nil
+}
+
+// generateWithLocal runs the prompt through the GGUF model at CAI_MODEL_PATH
+// in-process with llama.cpp, so no inference daemon is needed. The prompt is
+// wrapped in the model's chat template when it has one, with the static part
+// of a prompt with a cache break as the system message.
+func (g *Generator) generateWithLocal(prompt string) (string, error) {
+	path, err := g.config.LocalModelPath()
+	if err != nil {
+		return "", err
+	}
+	model, err := loadLocalModel(path)
+	if err != nil {
+		return "", err
+	}
+	vocab := C.llama_model_get_vocab(model)
+
+	text := applyChatTemplate(model, prompt)
+	tokens, n, err := tokenize(vocab, text)
+	if err != nil {
+		return "", err
+	}
+	defer C.free(unsafe.Pointer(tokens))
+
+	params := C.llama_context_default_params()
+	params.n_ctx = C.uint32_t(int(n) + localMaxTokens)
+	params.n_batch = C.uint32_t(n)
+	ctx := C.llama_init_from_model(model, params)
+	if ctx == nil {
+		return "", fmt.Errorf("failed to create llama.cpp context")
+	}
+	defer C.llama_free(ctx)
+
+	sampler := C.llama_sampler_chain_init(C.llama_sampler_chain_default_params())
+	defer C.llama_sampler_free(sampler)
+	C.llama_sampler_chain_add(sampler, C.llama_sampler_init_greedy())
+
+	// The sampled token lives in C memory so the batch holds no Go pointer
+	next := (*C.llama_token)(C.malloc(C.size_t(unsafe.Sizeof(C.llama_token(0)))))
+	defer C.free(unsafe.Pointer(next))
+
+	deadline := time.Now().Add(time.Duration(g.config.TimeoutSeconds) * time.Second)
+	var response strings.Builder
+	piece := make([]byte, 256)
+	batch := C.llama_batch_get_one(tokens, n)
+	for i := 0; i < localMaxTokens; i++ {
+		if time.Now().After(deadline) {
+			return "", fmt.Errorf("local generation timed out after %d seconds", g.config.TimeoutSeconds)
+		}
+		if C.llama_decode(ctx, batch) != 0 {
+			return "", fmt.Errorf("llama.cpp failed to decode the prompt")
+		}
+
+		token := C.llama_sampler_sample(sampler, ctx, -1)
+		if C.llama_vocab_is_eog(vocab, token) {
+			break
+		}
+		size := C.llama_token_to_piece(vocab, token, (*C.char)(unsafe.Pointer(&piece[0])), C.int32_t(len(piece)), 0, false)
+		if size < 0 {
+			return "", fmt.Errorf("failed to convert token to text")
+		}
+		response.Write(piece[:size])
+
+		*next = token
+		batch = C.llama_batch_get_one(next, 1)
+	}
+
+	return cleanResponse(strings.TrimSpace(response.String())), nil
+}
+
+// applyChatTemplate formats the prompt with the model's chat template, or
+// returns it unchanged for models without one
+func applyChatTemplate(model *C.struct_llama_model, prompt string) string {
+	static, dynamic := splitPrompt(prompt)
+	tmpl := C.llama_model_chat_template(model, nil)
+	if tmpl == nil {
+		return strings.TrimPrefix(static+"\n"+dynamic, "\n")
+	}
+
+	roles := []string{"user"}
+	contents := []string{dynamic}
+	if static != "" {
+		roles = []string{"system", "user"}
+		contents = []string{static, dynamic}
+	}
+
+	var message C.struct_llama_chat_message
+	messages := unsafe.Slice((*C.struct_llama_chat_message)(C.malloc(C.size_t(len(roles))*C.size_t(unsafe.Sizeof(message)))), len(roles))
+	defer C.free(unsafe.Pointer(&messages[0]))
+	for i := range roles {
+		messages[i].role = C.CString(roles[i])
+		messages[i].content = C.CString(contents[i])
+		defer C.free(unsafe.Pointer(messages[i].role))
+		defer C.free(unsafe.Pointer(messages[i].content))
+	}
+
+	size := C.int32_t(2*len(prompt) + 1024)
+	for {
+		buf := (*C.char)(C.malloc(C.size_t(size)))
+		n := C.llama_chat_apply_template(tmpl, &messages[0], C.size_t(len(messages)), true, buf, size)
+		if n < 0 {
+			C.free(unsafe.Pointer(buf))
+			return dynamic
+		}
+		if n <= size {
+			formatted := C.GoStringN(buf, n)
+			C.free(unsafe.Pointer(buf))
+			return formatted
+		}
+		C.free(unsafe.Pointer(buf))
+		size = n
+	}
+}
+
+// tokenize converts text to model tokens in C memory, which the caller frees
+func tokenize(vocab *C.struct_llama_vocab, text string) (*C.llama_token, C.int32_t, error) {
+	cText := C.CString(text)
+	defer C.free(unsafe.Pointer(cText))
+
+	// With no room for tokens, llama_tokenize returns the negated count
+	n := -C.llama_tokenize(vocab, cText, C.int32_t(len(text)), nil, 0, true, true)
+	if n <= 0 {
+		return nil, 0, fmt.Errorf("failed to tokenize the prompt")
+	}
+	tokens := (*C.llama_token)(C.malloc(C.size_t(n) * C.size_t(unsafe.Sizeof(C.llama_token(0)))))
+	if C.llama_tokenize(vocab, cText, C.int32_t(len(text)), tokens, n, true, true) < 0 {
+		C.free(unsafe.Pointer(tokens))
+		return nil, 0, fmt.Errorf("failed to tokenize the prompt")
+	}
+	return tokens, n, nil
+}
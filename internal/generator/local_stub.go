@@ -0,0 +1,11 @@
+//go:build !local
+
+package generator
+
+import "fmt"
+
+// generateWithLocal reports that the local provider isn't compiled in; see
+// local.go for builds with the local tag
+func (g *Generator) generateWithLocal(string) (string, error) {
+	return "", fmt.Errorf("this build of commit-ai has no local model support; rebuild with -tags local against llama.cpp (see README)")
+}
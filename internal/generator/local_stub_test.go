@@ -0,0 +1,24 @@
+//go:build !local
+
+package generator
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nseba/commit-ai/internal/config"
+)
+
+func TestGenerate_LocalWithoutBuildTag(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.Provider = "local"
+	cfg.ModelPath = "models/qwen2.5-coder-1.5b.gguf"
+	gen, err := New(cfg, filepath.Join(t.TempDir(), "config.toml"))
+	require.NoError(t, err)
+
+	_, err = gen.Generate("diff --git a/main.go b/main.go\n+package main\n")
+	assert.ErrorContains(t, err, "rebuild with -tags local")
+}
@@ -0,0 +1,75 @@
+package generator
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/nseba/commit-ai/internal/interrupt"
+)
+
+// ListModels returns the models the active provider offers, as reported by
+// its API: the installed models for Ollama and the /v1/models listing for
+// OpenAI-compatible services
+func (g *Generator) ListModels() ([]string, error) {
+	provider := g.config.ActiveProvider()
+
+	var url string
+	switch provider.Provider {
+	case providerOllama:
+		url = strings.TrimRight(provider.URL, "/") + "/api/tags"
+	case providerOpenAI:
+		url = strings.TrimRight(provider.URL, "/") + "/v1/models"
+		if provider.URL == "http://localhost:11434" {
+			url = "https://api.openai.com/v1/models"
+		}
+	default:
+		return nil, fmt.Errorf("listing models is not supported for the %s provider", provider.Provider)
+	}
+
+	req, err := http.NewRequestWithContext(interrupt.Context(), http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	if err := applyAuth(req, provider); err != nil {
+		return nil, err
+	}
+
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return nil, networkError(provider.Provider, provider.Model, url, fmt.Errorf("failed to list models: %w", redactQuery(err)))
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, classifyResponse(provider.Provider, provider.Model, url, resp.StatusCode, body)
+	}
+
+	var listing struct {
+		// Ollama
+		Models []struct {
+			Name string `json:"name"`
+		} `json:"models"`
+		// OpenAI-compatible
+		Data []struct {
+			ID string `json:"id"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&listing); err != nil {
+		return nil, fmt.Errorf("failed to decode model list: %w", err)
+	}
+
+	var models []string
+	for _, model := range listing.Models {
+		models = append(models, model.Name)
+	}
+	for _, model := range listing.Data {
+		models = append(models, model.ID)
+	}
+	sort.Strings(models)
+	return models, nil
+}
@@ -0,0 +1,52 @@
+package generator
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nseba/commit-ai/internal/config"
+)
+
+func TestListModels_Ollama(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/tags", r.URL.Path)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"models": [{"name": "qwen2.5-coder:7b"}, {"name": "llama3:8b"}]}`))
+	}))
+	defer server.Close()
+
+	cfg := config.DefaultConfig()
+	cfg.APIURL = server.URL
+	gen, err := New(cfg, filepath.Join(t.TempDir(), "config.toml"))
+	require.NoError(t, err)
+
+	models, err := gen.ListModels()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"llama3:8b", "qwen2.5-coder:7b"}, models)
+}
+
+func TestListModels_Preset(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/models", r.URL.Path)
+		assert.Equal(t, "Bearer sk-test", r.Header.Get("Authorization"))
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"object": "list", "data": [{"id": "deepseek-reasoner"}, {"id": "deepseek-chat"}]}`))
+	}))
+	defer server.Close()
+
+	cfg := config.DefaultConfig()
+	cfg.Provider = "deepseek"
+	cfg.APIURL = server.URL
+	cfg.APIToken = "sk-test"
+	gen, err := New(cfg, filepath.Join(t.TempDir(), "config.toml"))
+	require.NoError(t, err)
+
+	models, err := gen.ListModels()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"deepseek-chat", "deepseek-reasoner"}, models)
+}
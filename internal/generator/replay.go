@@ -0,0 +1,46 @@
+package generator
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/nseba/commit-ai/internal/config"
+	"github.com/nseba/commit-ai/internal/promptguard"
+	"github.com/nseba/commit-ai/internal/replay"
+)
+
+// generateWithReplay answers the prompt with the response recorded for it in
+// CAI_REPLAY_FIXTURES, without network access. The random ids of the diff
+// delimiters are normalized, so the same diff finds its recording.
+func (g *Generator) generateWithReplay(prompt string) (string, error) {
+	file, err := g.config.ReplayFixturesPath()
+	if err != nil {
+		return "", err
+	}
+	response, err := replay.Lookup(file, promptguard.Normalize(prompt))
+	if errors.Is(err, replay.ErrNotRecorded) {
+		return "", fmt.Errorf("%w in %s; record it by running with another provider and --record", err, file)
+	}
+	return response, err
+}
+
+// recordResponse saves the response of provider to CAI_REPLAY_FIXTURES when
+// CAI_RECORD is set. A failed write is reported but doesn't fail the
+// generation.
+func (g *Generator) recordResponse(provider config.ProviderSettings, prompt, response string) {
+	if !g.config.Record || provider.Provider == providerReplay {
+		return
+	}
+	file, err := g.config.ReplayFixturesPath()
+	if err == nil {
+		err = replay.Record(file, promptguard.Normalize(prompt), replay.Fixture{
+			Provider: provider.Provider,
+			Model:    provider.Model,
+			Response: response,
+		})
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to record the response: %v\n", err)
+	}
+}
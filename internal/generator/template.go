@@ -0,0 +1,296 @@
+package generator
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"regexp"
+	"slices"
+	"strings"
+	"text/template"
+	"text/template/parse"
+)
+
+// errCallDisabled is returned when a template uses the call builtin
+var errCallDisabled = errors.New("call is not available in prompt templates")
+
+// cacheBreakMarker separates the static start of a prompt, which providers can
+// cache between requests, from the part that changes with every diff.
+// Templates emit it with {{cacheBreak}}.
+const cacheBreakMarker = "\x00commit-ai:cache-break\x00"
+
+// templateFuncs is the function set available to prompt templates in addition
+// to the comparison and formatting builtins. call is replaced so templates can
+// only read the prompt data, never invoke code.
+var templateFuncs = template.FuncMap{
+	"call":       func(...interface{}) (string, error) { return "", errCallDisabled },
+	"cacheBreak": func() string { return cacheBreakMarker },
+	"lower":      strings.ToLower,
+	"upper":      strings.ToUpper,
+	"trim":       strings.TrimSpace,
+	"contains":   strings.Contains,
+	"hasPrefix":  strings.HasPrefix,
+}
+
+// TemplateFunction describes a function prompt templates can call
+type TemplateFunction struct {
+	Name string `json:"name"`
+	// Usage shows the arguments, such as "contains S SUBSTR"
+	Usage       string `json:"usage"`
+	Description string `json:"description"`
+}
+
+// templateFunctions documents templateFuncs, except the disabled call, and
+// the text/template builtins templates can use
+var templateFunctions = []TemplateFunction{
+	{"lower", "lower S", "S in lower case"},
+	{"upper", "upper S", "S in upper case"},
+	{"trim", "trim S", "S without leading and trailing white space"},
+	{"contains", "contains S SUBSTR", "Whether S contains SUBSTR"},
+	{"hasPrefix", "hasPrefix S PREFIX", "Whether S starts with PREFIX"},
+	{"cacheBreak", "cacheBreak", "Marks the end of the static prompt start that providers may cache"},
+	{"and", "and X Y...", "The first empty argument, or the last one"},
+	{"or", "or X Y...", "The first non-empty argument, or the last one"},
+	{"not", "not X", "Whether X is empty"},
+	{"eq", "eq X Y...", "Whether X equals any of the other arguments"},
+	{"ne", "ne X Y", "Whether X differs from Y"},
+	{"lt", "lt X Y", "Whether X is less than Y"},
+	{"le", "le X Y", "Whether X is less than or equal to Y"},
+	{"gt", "gt X Y", "Whether X is greater than Y"},
+	{"ge", "ge X Y", "Whether X is greater than or equal to Y"},
+	{"len", "len X", "Length of a string, slice or map"},
+	{"index", "index MAP KEY", "Element of a map or slice, like index .Env \"CI\""},
+	{"slice", "slice S START END", "Part of a string or slice"},
+	{"print", "print X...", "The arguments formatted like fmt.Sprint"},
+	{"printf", "printf FORMAT X...", "The arguments formatted like fmt.Sprintf"},
+	{"println", "println X...", "The arguments formatted like fmt.Sprintln"},
+	{"html", "html S", "S escaped for HTML"},
+	{"js", "js S", "S escaped for JavaScript"},
+	{"urlquery", "urlquery S", "S escaped for a URL query"},
+}
+
+// TemplateFunctions returns the functions prompt templates can call
+func TemplateFunctions() []TemplateFunction {
+	return slices.Clone(templateFunctions)
+}
+
+// TemplateVariable describes a field of the prompt template data
+type TemplateVariable struct {
+	// Name is the reference used in templates, such as .Diff or .Env.CI
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	// Example is the value for the current changes
+	Example string `json:"example"`
+}
+
+// TemplateVariables returns the fields prompt templates can reference, with
+// the values they have for diff with the current context. Env is listed once
+// per variable of CAI_TEMPLATE_ENV, or as .Env.NAME when there are none.
+func (g *Generator) TemplateVariables(diff string) []TemplateVariable {
+	data := reflect.ValueOf(g.newPromptData(diff))
+	var variables []TemplateVariable
+	for i := 0; i < data.NumField(); i++ {
+		field := data.Type().Field(i)
+		description := field.Tag.Get("desc")
+		if field.Name != "Env" {
+			variables = append(variables, TemplateVariable{Name: "." + field.Name, Description: description, Example: data.Field(i).String()})
+			continue
+		}
+
+		env := data.Field(i).Interface().(map[string]string)
+		if len(g.config.TemplateEnv) == 0 {
+			variables = append(variables, TemplateVariable{Name: ".Env.NAME", Description: description})
+		}
+		for _, name := range g.config.TemplateEnv {
+			variables = append(variables, TemplateVariable{Name: ".Env." + name, Description: description, Example: env[name]})
+		}
+	}
+	return variables
+}
+
+// unknownFieldPattern matches text/template errors about missing data
+var unknownFieldPattern = regexp.MustCompile(`can't evaluate field (\w+)|map has no entry for key "?(\w+)"?`)
+
+// splitPrompt splits a rendered prompt at its first cache break into the
+// static part and the rest. Without a cache break static is empty.
+func splitPrompt(prompt string) (static, dynamic string) {
+	static, dynamic, found := strings.Cut(prompt, cacheBreakMarker)
+	if !found {
+		return "", prompt
+	}
+	return strings.TrimSpace(static), strings.TrimSpace(strings.ReplaceAll(dynamic, cacheBreakMarker, ""))
+}
+
+// newTemplate parses a prompt template with the restricted function set and
+// strict handling of missing keys
+func newTemplate(name, content string) (*template.Template, error) {
+	return template.New(name).Option("missingkey=error").Funcs(templateFuncs).Parse(content)
+}
+
+// validateTemplate executes tmpl against sample data with every field set, so
+// references to unknown fields are reported when the template is loaded
+// rather than when the first message is generated
+func validateTemplate(tmpl *template.Template) error {
+	sample := promptData{}
+	v := reflect.ValueOf(&sample).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		if v.Field(i).Kind() == reflect.String {
+			v.Field(i).SetString("sample")
+		}
+	}
+	sample.Env = make(map[string]string)
+	for _, name := range envReferences(tmpl) {
+		sample.Env[name] = "sample"
+	}
+
+	if err := tmpl.Execute(io.Discard, sample); err != nil {
+		return explainTemplateError(err)
+	}
+	return nil
+}
+
+// explainTemplateError adds the available fields to errors about unknown
+// template data
+func explainTemplateError(err error) error {
+	match := unknownFieldPattern.FindStringSubmatch(err.Error())
+	if match == nil {
+		return err
+	}
+
+	field := match[1]
+	if field == "" {
+		// Env is the only map in the template data
+		return fmt.Errorf("template references environment variable %s, which is not available; reference it as {{.Env.%s}} and list it in CAI_TEMPLATE_ENV: %w",
+			match[2], match[2], err)
+	}
+	return fmt.Errorf("template references unknown field .%s; available fields: %s: %w",
+		field, strings.Join(templateFields(), ", "), err)
+}
+
+// templateFields lists the fields prompt templates can reference
+func templateFields() []string {
+	t := reflect.TypeOf(promptData{})
+	fields := make([]string, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		fields = append(fields, "."+t.Field(i).Name)
+	}
+	return fields
+}
+
+// templateLines returns the non-empty lines of the literal text of tmpl and
+// its associated templates, trimmed
+func templateLines(tmpl *template.Template) map[string]bool {
+	lines := make(map[string]bool)
+	var walk func(node parse.Node)
+	walk = func(node parse.Node) {
+		switch n := node.(type) {
+		case *parse.ListNode:
+			if n == nil {
+				return
+			}
+			for _, child := range n.Nodes {
+				walk(child)
+			}
+		case *parse.TextNode:
+			for _, line := range strings.Split(string(n.Text), "\n") {
+				if line = strings.TrimSpace(line); line != "" {
+					lines[line] = true
+				}
+			}
+		case *parse.IfNode:
+			walk(&n.BranchNode)
+		case *parse.RangeNode:
+			walk(&n.BranchNode)
+		case *parse.WithNode:
+			walk(&n.BranchNode)
+		case *parse.BranchNode:
+			walk(n.List)
+			walk(n.ElseList)
+		}
+	}
+	for _, t := range tmpl.Templates() {
+		if t.Tree != nil {
+			walk(t.Tree.Root)
+		}
+	}
+	return lines
+}
+
+// templateEnv returns the values of the named environment variables for the
+// template data
+func templateEnv(names []string) map[string]string {
+	env := make(map[string]string, len(names))
+	for _, name := range names {
+		env[name] = os.Getenv(name)
+	}
+	return env
+}
+
+// checkTemplateEnv reports environment variables referenced by tmpl as
+// {{.Env.NAME}} that are missing from the allowed names, so a template can't
+// read the environment beyond what CAI_TEMPLATE_ENV exposes
+func checkTemplateEnv(tmpl *template.Template, allowed []string) error {
+	for _, name := range envReferences(tmpl) {
+		if !slices.Contains(allowed, name) {
+			return fmt.Errorf("template references environment variable %s, which is not listed in CAI_TEMPLATE_ENV", name)
+		}
+	}
+	return nil
+}
+
+// envReferences returns the names of the environment variables tmpl and its
+// associated templates reference as .Env.NAME
+func envReferences(tmpl *template.Template) []string {
+	var names []string
+	var walk func(node parse.Node)
+	walk = func(node parse.Node) {
+		switch n := node.(type) {
+		case *parse.ListNode:
+			if n == nil {
+				return
+			}
+			for _, child := range n.Nodes {
+				walk(child)
+			}
+		case *parse.ActionNode:
+			walk(n.Pipe)
+		case *parse.IfNode:
+			walk(&n.BranchNode)
+		case *parse.RangeNode:
+			walk(&n.BranchNode)
+		case *parse.WithNode:
+			walk(&n.BranchNode)
+		case *parse.BranchNode:
+			walk(n.Pipe)
+			walk(n.List)
+			walk(n.ElseList)
+		case *parse.TemplateNode:
+			walk(n.Pipe)
+		case *parse.PipeNode:
+			if n == nil {
+				return
+			}
+			for _, cmd := range n.Cmds {
+				walk(cmd)
+			}
+		case *parse.CommandNode:
+			for _, arg := range n.Args {
+				walk(arg)
+			}
+		case *parse.ChainNode:
+			walk(n.Node)
+		case *parse.FieldNode:
+			if len(n.Ident) >= 2 && n.Ident[0] == "Env" && !slices.Contains(names, n.Ident[1]) {
+				names = append(names, n.Ident[1])
+			}
+		}
+	}
+	for _, t := range tmpl.Templates() {
+		if t.Tree != nil {
+			walk(t.Tree.Root)
+		}
+	}
+	return names
+}
@@ -0,0 +1,104 @@
+package generator
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"time"
+
+	"github.com/nseba/commit-ai/internal/config"
+)
+
+// sshCommand is the ssh client used for CAI_SSH_JUMP_HOST; tests replace it
+var sshCommand = "ssh"
+
+// newTransport returns the transport for provider requests: through
+// CAI_PROXY, tunneled through CAI_SSH_JUMP_HOST, or the default transport,
+// which honors HTTP_PROXY and HTTPS_PROXY
+func newTransport(cfg *config.Config) (http.RoundTripper, error) {
+	switch {
+	case cfg.Proxy != "":
+		proxyURL, err := url.Parse(cfg.Proxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CAI_PROXY: %w", err)
+		}
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.Proxy = http.ProxyURL(proxyURL)
+		return transport, nil
+	case cfg.SSHJumpHost != "":
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.Proxy = nil
+		transport.DialContext = sshDialer(cfg.SSHJumpHost)
+		return transport, nil
+	default:
+		return http.DefaultTransport, nil
+	}
+}
+
+// sshDialer returns a dial function connecting through "ssh -W", like an
+// OpenSSH ProxyCommand, so ~/.ssh/config, keys and the agent apply as usual
+func sshDialer(jumpHost string) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	return func(ctx context.Context, _, addr string) (net.Conn, error) {
+		// Not bound to ctx, which only covers the dial: the tunnel lives as
+		// long as the connection
+		cmd := exec.Command(sshCommand, "-W", addr, "--", jumpHost) // #nosec G204 -- jump host is validated configuration
+		cmd.Stderr = os.Stderr
+
+		stdin, err := cmd.StdinPipe()
+		if err != nil {
+			return nil, fmt.Errorf("failed to start ssh tunnel: %w", err)
+		}
+		stdout, err := cmd.StdoutPipe()
+		if err != nil {
+			return nil, fmt.Errorf("failed to start ssh tunnel: %w", err)
+		}
+		if err := cmd.Start(); err != nil {
+			return nil, fmt.Errorf("failed to start ssh tunnel via %s: %w", jumpHost, err)
+		}
+		if err := ctx.Err(); err != nil {
+			_ = cmd.Process.Kill()
+			_ = cmd.Wait()
+			return nil, err
+		}
+		return &sshConn{cmd: cmd, stdin: stdin, stdout: stdout, addr: addr}, nil
+	}
+}
+
+// sshConn is a connection over the stdin and stdout of an ssh process.
+// Deadlines aren't supported; the HTTP client enforces its timeout by
+// closing the connection.
+type sshConn struct {
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	stdout io.ReadCloser
+	addr   string
+}
+
+func (c *sshConn) Read(p []byte) (int, error)  { return c.stdout.Read(p) }
+func (c *sshConn) Write(p []byte) (int, error) { return c.stdin.Write(p) }
+
+// Close ends the tunnel
+func (c *sshConn) Close() error {
+	_ = c.stdin.Close()
+	_ = c.cmd.Process.Kill()
+	_ = c.cmd.Wait()
+	return nil
+}
+
+func (c *sshConn) LocalAddr() net.Addr  { return tunnelAddr("ssh") }
+func (c *sshConn) RemoteAddr() net.Addr { return tunnelAddr(c.addr) }
+
+func (c *sshConn) SetDeadline(time.Time) error      { return nil }
+func (c *sshConn) SetReadDeadline(time.Time) error  { return nil }
+func (c *sshConn) SetWriteDeadline(time.Time) error { return nil }
+
+// tunnelAddr is the address of an end of an ssh tunnel
+type tunnelAddr string
+
+func (a tunnelAddr) Network() string { return "ssh" }
+func (a tunnelAddr) String() string  { return string(a) }
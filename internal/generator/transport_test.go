@@ -0,0 +1,79 @@
+package generator
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nseba/commit-ai/internal/config"
+)
+
+// fakeSSH connects the address after -W to stdin and stdout like "ssh -W"
+func fakeSSH(args []string) {
+	if len(args) < 2 || args[0] != "-W" {
+		os.Exit(2)
+	}
+	conn, err := net.Dial("tcp", args[1])
+	if err != nil {
+		os.Exit(1)
+	}
+	go func() { _, _ = io.Copy(conn, os.Stdin) }()
+	_, _ = io.Copy(os.Stdout, conn)
+}
+
+func TestGenerate_Proxy(t *testing.T) {
+	var mu sync.Mutex
+	var proxied []string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		proxied = append(proxied, r.URL.String())
+		mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"response": "feat: proxied", "done": true}`))
+	}))
+	defer proxy.Close()
+
+	cfg := config.DefaultConfig()
+	cfg.APIURL = "http://inference.internal:11434"
+	cfg.Proxy = proxy.URL
+	gen, err := New(cfg, filepath.Join(t.TempDir(), "config.toml"))
+	require.NoError(t, err)
+
+	result, err := gen.generateWithOllama(gen.config.ActiveProvider(), "prompt")
+	require.NoError(t, err)
+	assert.Equal(t, "feat: proxied", result)
+	assert.Equal(t, []string{"http://inference.internal:11434/api/generate"}, proxied)
+}
+
+func TestGenerate_SSHJumpHost(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"response": "feat: tunneled", "done": true}`))
+	}))
+	defer server.Close()
+
+	executable, err := os.Executable()
+	require.NoError(t, err)
+	original := sshCommand
+	sshCommand = executable
+	defer func() { sshCommand = original }()
+	t.Setenv("CAI_TEST_FAKE_SSH", "1")
+
+	cfg := config.DefaultConfig()
+	cfg.APIURL = server.URL
+	cfg.SSHJumpHost = "dev@bastion.example.com"
+	gen, err := New(cfg, filepath.Join(t.TempDir(), "config.toml"))
+	require.NoError(t, err)
+
+	result, err := gen.generateWithOllama(gen.config.ActiveProvider(), "prompt")
+	require.NoError(t, err)
+	assert.Equal(t, "feat: tunneled", result)
+}
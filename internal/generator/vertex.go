@@ -0,0 +1,157 @@
+package generator
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/nseba/commit-ai/internal/config"
+	"github.com/nseba/commit-ai/internal/gcpauth"
+	"github.com/nseba/commit-ai/internal/interrupt"
+)
+
+// generateWithVertex generates commit message using the Gemini API of Google
+// Vertex AI. The static part of a prompt with a cache break is sent as the
+// system instruction, which Vertex AI caches implicitly.
+func (g *Generator) generateWithVertex(provider config.ProviderSettings, prompt string) (string, error) {
+	project, err := g.vertexProject()
+	if err != nil {
+		return "", err
+	}
+
+	static, dynamic := splitPrompt(prompt)
+	reqBody := map[string]interface{}{
+		"contents": []map[string]interface{}{{
+			"role":  "user",
+			"parts": []map[string]string{{"text": dynamic}},
+		}},
+	}
+	if static != "" {
+		reqBody["systemInstruction"] = map[string]interface{}{
+			"parts": []map[string]string{{"text": static}},
+		}
+	}
+	if temperature := g.temperature(); temperature != nil {
+		reqBody["generationConfig"] = map[string]interface{}{"temperature": *temperature}
+	}
+
+	jsonData, err := json.Marshal(reqBody)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	url := fmt.Sprintf("%s/v1/projects/%s/locations/%s/publishers/google/models/%s:generateContent",
+		strings.TrimRight(provider.URL, "/"), project, g.config.VertexLocation, provider.Model)
+	ctx := interrupt.Context()
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if err := g.vertexAuth(ctx, req, provider); err != nil {
+		return "", err
+	}
+
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return "", networkError(provider.Provider, provider.Model, url, fmt.Errorf("failed to make request to Vertex AI: %w", err))
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return "", classifyResponse(provider.Provider, provider.Model, url, resp.StatusCode, body)
+	}
+
+	var vertexResp struct {
+		Candidates []struct {
+			Content struct {
+				Parts []struct {
+					Text string `json:"text"`
+				} `json:"parts"`
+			} `json:"content"`
+			FinishReason string `json:"finishReason"`
+		} `json:"candidates"`
+		PromptFeedback struct {
+			BlockReason string `json:"blockReason"`
+		} `json:"promptFeedback"`
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(&vertexResp); err != nil {
+		return "", fmt.Errorf("failed to decode Vertex AI response: %w", err)
+	}
+
+	if len(vertexResp.Candidates) == 0 {
+		if reason := vertexResp.PromptFeedback.BlockReason; reason != "" {
+			return "", fmt.Errorf("prompt blocked by Vertex AI: %s", reason)
+		}
+		return "", fmt.Errorf("no response from Vertex AI")
+	}
+
+	var text strings.Builder
+	for _, part := range vertexResp.Candidates[0].Content.Parts {
+		text.WriteString(part.Text)
+	}
+	return cleanResponse(strings.TrimSpace(text.String())), nil
+}
+
+// vertexProject returns the Google Cloud project to bill: CAI_VERTEX_PROJECT,
+// then GOOGLE_CLOUD_PROJECT, then the project of the credentials
+func (g *Generator) vertexProject() (string, error) {
+	if g.config.VertexProject != "" {
+		return g.config.VertexProject, nil
+	}
+	if project := os.Getenv("GOOGLE_CLOUD_PROJECT"); project != "" {
+		return project, nil
+	}
+	creds, err := g.vertexCredentials()
+	if err != nil {
+		return "", err
+	}
+	if project := creds.ProjectID(); project != "" {
+		return project, nil
+	}
+	return "", fmt.Errorf("no Google Cloud project found; set CAI_VERTEX_PROJECT")
+}
+
+// vertexAuth authorizes req with an access token from the Google credentials.
+// A configured CAI_API_TOKEN is used as the access token instead, e.g. the
+// output of `gcloud auth print-access-token`.
+func (g *Generator) vertexAuth(ctx context.Context, req *http.Request, provider config.ProviderSettings) error {
+	if provider.Token != "" {
+		return applyAuth(req, provider)
+	}
+	creds, err := g.vertexCredentials()
+	if err != nil {
+		return err
+	}
+	token, err := creds.Token(ctx)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	return nil
+}
+
+// vertexCredentials returns the credentials in CAI_VERTEX_CREDENTIALS or the
+// Application Default Credentials, looking them up once
+func (g *Generator) vertexCredentials() (*gcpauth.Credentials, error) {
+	if g.vertexCreds != nil {
+		return g.vertexCreds, nil
+	}
+	file, err := g.config.VertexCredentialsPath()
+	if err != nil {
+		return nil, err
+	}
+	creds, err := gcpauth.Find(file, g.client)
+	if err != nil {
+		return nil, err
+	}
+	g.vertexCreds = creds
+	return creds, nil
+}
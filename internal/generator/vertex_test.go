@@ -0,0 +1,142 @@
+package generator
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nseba/commit-ai/internal/config"
+)
+
+// vertexServer answers generateContent requests, and token requests of a
+// service account on /token
+func vertexServer(t *testing.T, token string) *httptest.Server {
+	t.Helper()
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/token" {
+			w.Write([]byte(`{"access_token": "ya29.sa", "expires_in": 3600}`))
+			return
+		}
+		assert.Equal(t, "/v1/projects/my-project/locations/europe-west4/publishers/google/models/gemini-2.5-flash:generateContent", r.URL.Path)
+		assert.Equal(t, "Bearer "+token, r.Header.Get("Authorization"))
+
+		var body struct {
+			Contents []struct {
+				Role  string `json:"role"`
+				Parts []struct {
+					Text string `json:"text"`
+				} `json:"parts"`
+			} `json:"contents"`
+			SystemInstruction struct {
+				Parts []struct {
+					Text string `json:"text"`
+				} `json:"parts"`
+			} `json:"systemInstruction"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		require.Len(t, body.Contents, 1)
+		assert.Equal(t, "user", body.Contents[0].Role)
+		assert.Equal(t, "diff", body.Contents[0].Parts[0].Text)
+		assert.Equal(t, "instructions", body.SystemInstruction.Parts[0].Text)
+
+		w.Write([]byte(`{"candidates": [{"content": {"role": "model", "parts": [{"text": "feat: "}, {"text": "vertex"}]}}]}`))
+	}))
+}
+
+// writeServiceAccount writes a service account key whose token endpoint is
+// tokenURI
+func writeServiceAccount(t *testing.T, tokenURI string) string {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	data, err := json.Marshal(map[string]string{
+		"type":         "service_account",
+		"project_id":   "my-project",
+		"client_email": "ci@my-project.iam.gserviceaccount.com",
+		"private_key":  string(pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})),
+		"token_uri":    tokenURI,
+	})
+	require.NoError(t, err)
+	file := filepath.Join(t.TempDir(), "service-account.json")
+	require.NoError(t, os.WriteFile(file, data, 0o600))
+	return file
+}
+
+func TestGenerateWithVertex(t *testing.T) {
+	server := vertexServer(t, "ya29.sa")
+	defer server.Close()
+	t.Setenv("GOOGLE_CLOUD_PROJECT", "")
+
+	cfg := config.DefaultConfig()
+	cfg.Provider = "vertex"
+	cfg.APIURL = server.URL
+	cfg.VertexLocation = "europe-west4"
+	cfg.VertexCredentials = writeServiceAccount(t, server.URL+"/token")
+	gen, err := New(cfg, filepath.Join(t.TempDir(), "config.toml"))
+	require.NoError(t, err)
+
+	// The project comes from the service account key
+	result, err := gen.send(gen.config.ActiveProvider(), "instructions\n"+cacheBreakMarker+"\ndiff")
+	require.NoError(t, err)
+	assert.Equal(t, "feat: vertex", result)
+}
+
+func TestGenerateWithVertex_AccessToken(t *testing.T) {
+	server := vertexServer(t, "ya29.gcloud")
+	defer server.Close()
+
+	cfg := config.DefaultConfig()
+	cfg.Provider = "vertex"
+	cfg.APIURL = server.URL
+	cfg.APIToken = "ya29.gcloud"
+	cfg.VertexProject = "my-project"
+	cfg.VertexLocation = "europe-west4"
+	gen, err := New(cfg, filepath.Join(t.TempDir(), "config.toml"))
+	require.NoError(t, err)
+
+	result, err := gen.generateWithVertex(gen.config.ActiveProvider(), "instructions\n"+cacheBreakMarker+"\ndiff")
+	require.NoError(t, err)
+	assert.Equal(t, "feat: vertex", result)
+}
+
+func TestGenerateWithVertex_Errors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte(`{"error": {"code": 403, "message": "Permission 'aiplatform.endpoints.predict' denied", "status": "PERMISSION_DENIED"}}`))
+	}))
+	defer server.Close()
+
+	cfg := config.DefaultConfig()
+	cfg.Provider = "vertex"
+	cfg.APIURL = server.URL
+	cfg.APIToken = "ya29.gcloud"
+	cfg.VertexProject = "my-project"
+	gen, err := New(cfg, filepath.Join(t.TempDir(), "config.toml"))
+	require.NoError(t, err)
+
+	_, err = gen.generateWithVertex(gen.config.ActiveProvider(), "prompt")
+	var providerErr *ProviderError
+	require.ErrorAs(t, err, &providerErr)
+	assert.Equal(t, ErrorAuth, providerErr.Kind)
+	assert.Contains(t, providerErr.Message, "aiplatform.endpoints.predict")
+	assert.Contains(t, providerErr.Hint(), "gcloud auth application-default login")
+
+	// Without a project anywhere, the request isn't sent
+	userCreds := filepath.Join(t.TempDir(), "application_default_credentials.json")
+	require.NoError(t, os.WriteFile(userCreds, []byte(`{"type": "authorized_user", "refresh_token": "1//refresh"}`), 0o600))
+	t.Setenv("GOOGLE_CLOUD_PROJECT", "")
+	t.Setenv("GOOGLE_APPLICATION_CREDENTIALS", userCreds)
+	cfg.VertexProject = ""
+	_, err = gen.generateWithVertex(gen.config.ActiveProvider(), "prompt")
+	assert.ErrorContains(t, err, "set CAI_VERTEX_PROJECT")
+}
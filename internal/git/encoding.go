@@ -0,0 +1,89 @@
+package git
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// Byte order marks recognized when decoding file content
+var (
+	bomUTF8    = []byte{0xEF, 0xBB, 0xBF}
+	bomUTF16LE = []byte{0xFF, 0xFE}
+	bomUTF16BE = []byte{0xFE, 0xFF}
+)
+
+// binaryMarker starts the stand-in for the content of binary files in the
+// diff builders, followed by the content hash so changes are still detected.
+// Content with a NUL byte is binary, so no text decodes to it.
+const binaryMarker = "\x00binary:"
+
+// binarySniffLen is how much of the content is checked for NUL bytes, the
+// same heuristic git uses to tell binary files apart
+const binarySniffLen = 8000
+
+// decodeText converts file content to UTF-8 so the prompt doesn't receive
+// mojibake: UTF-16 with a byte order mark is decoded, a UTF-8 byte order mark
+// is dropped and other invalid UTF-8 is read as Latin-1. isBinary is set for
+// content with NUL bytes, which has no meaningful text form.
+func decodeText(content []byte) (text string, isBinary bool) {
+	switch {
+	case bytes.HasPrefix(content, bomUTF16LE):
+		return decodeUTF16(content[len(bomUTF16LE):], false), false
+	case bytes.HasPrefix(content, bomUTF16BE):
+		return decodeUTF16(content[len(bomUTF16BE):], true), false
+	case bytes.HasPrefix(content, bomUTF8):
+		content = content[len(bomUTF8):]
+	}
+
+	if bytes.IndexByte(content[:min(len(content), binarySniffLen)], 0) >= 0 {
+		return "", true
+	}
+	if utf8.Valid(content) {
+		return string(content), false
+	}
+	return decodeLatin1(content), false
+}
+
+// decodeContent returns content as UTF-8 text, or a binary stand-in
+func decodeContent(content []byte) string {
+	text, isBinary := decodeText(content)
+	if isBinary {
+		return fmt.Sprintf("%s%x", binaryMarker, sha256.Sum256(content))
+	}
+	return text
+}
+
+// isBinaryContent reports whether content is the stand-in of a binary file
+func isBinaryContent(content string) bool {
+	return strings.HasPrefix(content, binaryMarker)
+}
+
+// decodeUTF16 decodes UTF-16 content without its byte order mark; a trailing
+// odd byte is dropped
+func decodeUTF16(content []byte, bigEndian bool) string {
+	units := make([]uint16, len(content)/2)
+	for i := range units {
+		if bigEndian {
+			units[i] = binary.BigEndian.Uint16(content[2*i:])
+		} else {
+			units[i] = binary.LittleEndian.Uint16(content[2*i:])
+		}
+	}
+	return string(utf16.Decode(units))
+}
+
+// decodeLatin1 decodes ISO-8859-1 content, whose bytes are the first 256
+// Unicode code points
+func decodeLatin1(content []byte) string {
+	var b strings.Builder
+	b.Grow(len(content) * 2)
+	for _, c := range content {
+		b.WriteRune(rune(c))
+	}
+	return b.String()
+}
@@ -0,0 +1,31 @@
+package git
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDecodeText(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  []byte
+		expected string
+		binary   bool
+	}{
+		{"utf-8", []byte("héllo"), "héllo", false},
+		{"utf-8 bom", []byte("\xEF\xBB\xBFhéllo"), "héllo", false},
+		{"latin-1", []byte("h\xe9llo"), "héllo", false},
+		{"utf-16le bom", []byte{0xFF, 0xFE, 'h', 0, 0xE9, 0, 'l', 0}, "hél", false},
+		{"utf-16be bom", []byte{0xFE, 0xFF, 0, 'h', 0, 0xE9, 0, 'l'}, "hél", false},
+		{"binary", []byte("PK\x03\x04\x00\x00"), "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			text, binary := decodeText(tt.content)
+			assert.Equal(t, tt.expected, text)
+			assert.Equal(t, tt.binary, binary)
+		})
+	}
+}
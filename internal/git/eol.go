@@ -0,0 +1,48 @@
+package git
+
+import (
+	"strings"
+
+	gitconfig "github.com/go-git/go-git/v5/config"
+)
+
+// normalizesLineEndings reports whether git converts CRLF line endings of
+// file to LF when it is added, following the text and eol attributes and,
+// for files without them, core.autocrlf. Comparing the converted work tree
+// content with HEAD keeps a CRLF checkout from showing every line as changed.
+func (r *Repository) normalizesLineEndings(filename string) bool {
+	attrs := r.attributesFor(filename, "text", "eol")
+	if text, ok := attrs["text"]; ok {
+		switch {
+		case text.IsUnset():
+			return false
+		case text.IsSet(), text.IsValueSet() && text.Value() == "auto":
+			return true
+		}
+	}
+	if _, ok := attrs["eol"]; ok {
+		// eol implies text
+		return true
+	}
+
+	// core.autocrlf is usually set globally, e.g. by Git for Windows
+	cfg, err := r.repo.ConfigScoped(gitconfig.SystemScope)
+	if err != nil {
+		return false
+	}
+	switch strings.ToLower(cfg.Raw.Section("core").Option("autocrlf")) {
+	case "true", "input":
+		return true
+	default:
+		return false
+	}
+}
+
+// normalizeLineEndings converts CRLF line endings to LF; binary stand-ins are
+// returned unchanged
+func normalizeLineEndings(content string) string {
+	if isBinaryContent(content) {
+		return content
+	}
+	return strings.ReplaceAll(content, "\r\n", "\n")
+}
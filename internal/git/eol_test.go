@@ -0,0 +1,47 @@
+package git
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetDiff_CRLFWithAutocrlf(t *testing.T) {
+	tempDir, gitRepo := createTestRepo(t)
+	commitFile(t, gitRepo, tempDir, "main.c", "int a;\nint b;\n")
+
+	cfg, err := gitRepo.Config()
+	require.NoError(t, err)
+	cfg.Raw.Section("core").SetOption("autocrlf", "true")
+	require.NoError(t, gitRepo.SetConfig(cfg))
+
+	// A CRLF checkout with one edited line
+	createTestFile(t, tempDir, "main.c", "int a;\r\nint c;\r\n")
+
+	repo, err := NewRepository(tempDir)
+	require.NoError(t, err)
+
+	diff, err := repo.GetDiff()
+	require.NoError(t, err)
+	assert.Contains(t, diff, "-int b;\n+int c;")
+	assert.NotContains(t, diff, "int a;")
+	assert.NotContains(t, diff, "\r")
+}
+
+func TestNormalizesLineEndings_Attributes(t *testing.T) {
+	// Keep a core.autocrlf from the user's global config out of the test
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+
+	tempDir, gitRepo := createTestRepo(t)
+	commitFile(t, gitRepo, tempDir, ".gitattributes", "*.txt text\n*.bat eol=crlf\n*.bin -text\n")
+
+	repo, err := NewRepository(tempDir)
+	require.NoError(t, err)
+
+	assert.True(t, repo.normalizesLineEndings("notes.txt"))
+	assert.True(t, repo.normalizesLineEndings("scripts/run.bat"))
+	assert.False(t, repo.normalizesLineEndings("data.bin"))
+	assert.False(t, repo.normalizesLineEndings("main.c"), "no attributes and no core.autocrlf")
+}
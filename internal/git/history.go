@@ -0,0 +1,169 @@
+package git
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/storer"
+)
+
+// HistoryCommit is a commit of a range returned by CommitRange
+type HistoryCommit struct {
+	Hash    string
+	Message string
+	// Author is the "Name <email>" author of the commit, and Time when it
+	// was authored
+	Author string
+	Time   time.Time
+}
+
+// newHistoryCommit returns the HistoryCommit of c
+func newHistoryCommit(c *object.Commit) HistoryCommit {
+	return HistoryCommit{
+		Hash:    c.Hash.String(),
+		Message: c.Message,
+		Author:  fmt.Sprintf("%s <%s>", c.Author.Name, c.Author.Email),
+		Time:    c.Author.When,
+	}
+}
+
+// CommitRange returns the commits reachable from to but not from, following
+// first parents, oldest first. from must be an ancestor of to.
+func (r *Repository) CommitRange(from, to string) ([]HistoryCommit, error) {
+	fromCommit, err := r.resolveCommit(from)
+	if err != nil {
+		return nil, err
+	}
+	toCommit, err := r.resolveCommit(to)
+	if err != nil {
+		return nil, err
+	}
+
+	var commits []HistoryCommit
+	for c := toCommit; c.Hash != fromCommit.Hash; {
+		commits = append(commits, newHistoryCommit(c))
+		if c.NumParents() == 0 {
+			return nil, fmt.Errorf("%s is not an ancestor of %s", from, to)
+		}
+		if c, err = c.Parent(0); err != nil {
+			return nil, fmt.Errorf("failed to read parent commit: %w", err)
+		}
+	}
+
+	// Oldest first
+	for i, j := 0, len(commits)-1; i < j; i, j = i+1, j-1 {
+		commits[i], commits[j] = commits[j], commits[i]
+	}
+	return commits, nil
+}
+
+// RecentCommits returns up to n commits reachable from HEAD along first
+// parents, newest first. A repository without commits has none.
+func (r *Repository) RecentCommits(n int) ([]HistoryCommit, error) {
+	head, err := r.repo.Head()
+	if errors.Is(err, plumbing.ErrReferenceNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get HEAD: %w", err)
+	}
+	c, err := r.repo.CommitObject(head.Hash())
+	if err != nil {
+		return nil, fmt.Errorf("failed to get HEAD commit: %w", err)
+	}
+
+	var commits []HistoryCommit
+	for len(commits) < n {
+		commits = append(commits, newHistoryCommit(c))
+		if c.NumParents() == 0 {
+			break
+		}
+		if c, err = c.Parent(0); err != nil {
+			return nil, fmt.Errorf("failed to read parent commit: %w", err)
+		}
+	}
+	return commits, nil
+}
+
+// CommitsSince returns the commits of the local branches authored at or
+// after since, oldest first. Merge commits are left out.
+func (r *Repository) CommitsSince(since time.Time) ([]HistoryCommit, error) {
+	branches, err := r.repo.Branches()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list branches: %w", err)
+	}
+	defer branches.Close()
+
+	seen := make(map[plumbing.Hash]bool)
+	var commits []HistoryCommit
+	err = branches.ForEach(func(ref *plumbing.Reference) error {
+		log, err := r.repo.Log(&git.LogOptions{From: ref.Hash(), Order: git.LogOrderCommitterTime})
+		if err != nil {
+			return fmt.Errorf("failed to read history of %s: %w", ref.Name().Short(), err)
+		}
+		defer log.Close()
+
+		err = log.ForEach(func(c *object.Commit) error {
+			// Commits are visited newest first by commit time, which is
+			// never before the author time
+			if c.Committer.When.Before(since) {
+				return storer.ErrStop
+			}
+			if seen[c.Hash] || c.NumParents() > 1 || c.Author.When.Before(since) {
+				return nil
+			}
+			seen[c.Hash] = true
+			commits = append(commits, newHistoryCommit(c))
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("failed to read history of %s: %w", ref.Name().Short(), err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(commits, func(i, j int) bool { return commits[i].Time.Before(commits[j].Time) })
+	return commits, nil
+}
+
+// CommitDiff returns the commit at rev and its diff against its first
+// parent. A root commit is diffed against an empty tree.
+func (r *Repository) CommitDiff(rev string) (HistoryCommit, string, error) {
+	commit, err := r.resolveCommit(rev)
+	if err != nil {
+		return HistoryCommit{}, "", err
+	}
+	tree, err := commit.Tree()
+	if err != nil {
+		return HistoryCommit{}, "", fmt.Errorf("failed to read tree of %s: %w", rev, err)
+	}
+
+	var parentTree *object.Tree
+	if commit.NumParents() > 0 {
+		parent, err := commit.Parent(0)
+		if err != nil {
+			return HistoryCommit{}, "", fmt.Errorf("failed to read parent commit: %w", err)
+		}
+		if parentTree, err = parent.Tree(); err != nil {
+			return HistoryCommit{}, "", fmt.Errorf("failed to read tree of %s's parent: %w", rev, err)
+		}
+	}
+
+	changes, err := object.DiffTree(parentTree, tree)
+	if err != nil {
+		return HistoryCommit{}, "", fmt.Errorf("failed to compute diff: %w", err)
+	}
+	patch, err := r.filterChanges(changes).Patch()
+	if err != nil {
+		return HistoryCommit{}, "", fmt.Errorf("failed to compute diff: %w", err)
+	}
+	return newHistoryCommit(commit), patch.String(), nil
+}
@@ -0,0 +1,211 @@
+package git
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCommitRange(t *testing.T) {
+	tempDir, gitRepo := createTestRepo(t)
+	commitFile(t, gitRepo, tempDir, "a.txt", "a")
+	base, err := gitRepo.Head()
+	require.NoError(t, err)
+	commitFile(t, gitRepo, tempDir, "b.txt", "b")
+	commitFile(t, gitRepo, tempDir, "c.txt", "c")
+
+	repo, err := NewRepository(tempDir)
+	require.NoError(t, err)
+
+	commits, err := repo.CommitRange(base.Hash().String(), "HEAD")
+	require.NoError(t, err)
+	require.Len(t, commits, 2)
+
+	head, err := gitRepo.Head()
+	require.NoError(t, err)
+	assert.Equal(t, head.Hash().String(), commits[1].Hash)
+	assert.Equal(t, "Initial commit", commits[0].Message)
+
+	_, err = repo.CommitRange("HEAD", base.Hash().String())
+	assert.Error(t, err)
+}
+
+func TestRecentCommits(t *testing.T) {
+	tempDir, gitRepo := createTestRepo(t)
+	repo, err := NewRepository(tempDir)
+	require.NoError(t, err)
+
+	commits, err := repo.RecentCommits(2)
+	require.NoError(t, err)
+	assert.Empty(t, commits)
+
+	commitFile(t, gitRepo, tempDir, "a.txt", "a")
+	commitFile(t, gitRepo, tempDir, "b.txt", "b")
+	commitFile(t, gitRepo, tempDir, "c.txt", "c")
+	head, err := gitRepo.Head()
+	require.NoError(t, err)
+
+	commits, err = repo.RecentCommits(2)
+	require.NoError(t, err)
+	require.Len(t, commits, 2)
+	assert.Equal(t, head.Hash().String(), commits[0].Hash)
+
+	commits, err = repo.RecentCommits(10)
+	require.NoError(t, err)
+	assert.Len(t, commits, 3)
+}
+
+func TestCommitsSince(t *testing.T) {
+	tempDir, gitRepo := createTestRepo(t)
+	worktree, err := gitRepo.Worktree()
+	require.NoError(t, err)
+	now := time.Now()
+	commit := func(file, message string, when time.Time) {
+		createTestFile(t, tempDir, file, message)
+		_, err := worktree.Add(file)
+		require.NoError(t, err)
+		signature := &object.Signature{Name: "Test User", Email: "test@example.com", When: when}
+		_, err = worktree.Commit(message, &git.CommitOptions{Author: signature, Committer: signature})
+		require.NoError(t, err)
+	}
+
+	commit("a.txt", "feat: old work", now.Add(-72*time.Hour))
+	commit("b.txt", "feat: recent work", now.Add(-2*time.Hour))
+	require.NoError(t, worktree.Checkout(&git.CheckoutOptions{Branch: plumbing.NewBranchReferenceName("feature"), Create: true}))
+	commit("c.txt", "fix: work on a branch", now.Add(-time.Hour))
+
+	repo, err := NewRepository(tempDir)
+	require.NoError(t, err)
+	commits, err := repo.CommitsSince(now.Add(-24 * time.Hour))
+	require.NoError(t, err)
+	require.Len(t, commits, 2)
+	assert.Equal(t, "feat: recent work", commits[0].Message)
+	assert.Equal(t, "fix: work on a branch", commits[1].Message)
+	assert.Equal(t, "Test User <test@example.com>", commits[1].Author)
+	assert.WithinDuration(t, now.Add(-time.Hour), commits[1].Time, time.Second)
+}
+
+func TestCommitDiff(t *testing.T) {
+	tempDir, gitRepo := createTestRepo(t)
+	commitFile(t, gitRepo, tempDir, "a.txt", "Hello\n")
+	commitFile(t, gitRepo, tempDir, "a.txt", "Hello\nWorld\n")
+
+	repo, err := NewRepository(tempDir)
+	require.NoError(t, err)
+
+	commit, diff, err := repo.CommitDiff("HEAD")
+	require.NoError(t, err)
+	head, err := gitRepo.Head()
+	require.NoError(t, err)
+	assert.Equal(t, head.Hash().String(), commit.Hash)
+	assert.Equal(t, "Initial commit", commit.Message)
+	assert.Contains(t, diff, "+World")
+	assert.NotContains(t, diff, "+Hello")
+
+	// The root commit adds everything
+	_, diff, err = repo.CommitDiff("HEAD~1")
+	require.NoError(t, err)
+	assert.Contains(t, diff, "+Hello")
+
+	_, _, err = repo.CommitDiff("does-not-exist")
+	assert.Error(t, err)
+}
+
+func TestRewordCommits(t *testing.T) {
+	t.Setenv("GIT_COMMITTER_NAME", "Rewording User")
+	t.Setenv("GIT_COMMITTER_EMAIL", "reword@example.com")
+	tempDir, gitRepo := createTestRepo(t)
+	commitFile(t, gitRepo, tempDir, "a.txt", "a")
+	base, err := gitRepo.Head()
+	require.NoError(t, err)
+	commitFile(t, gitRepo, tempDir, "b.txt", "b")
+	commitFile(t, gitRepo, tempDir, "c.txt", "c")
+
+	repo, err := NewRepository(tempDir)
+	require.NoError(t, err)
+
+	commits, err := repo.CommitRange(base.Hash().String(), "HEAD")
+	require.NoError(t, err)
+	oldHead, err := gitRepo.CommitObject(plumbing.NewHash(commits[1].Hash))
+	require.NoError(t, err)
+
+	newHead, err := repo.RewordCommits(base.Hash().String(), map[string]string{
+		commits[0].Hash: "feat: ajouter b",
+		commits[1].Hash: "feat: ajouter c",
+	})
+	require.NoError(t, err)
+
+	head, err := gitRepo.Head()
+	require.NoError(t, err)
+	assert.Equal(t, newHead, head.Hash().String())
+	assert.Equal(t, plumbing.NewBranchReferenceName("master"), head.Name())
+
+	rewritten, err := repo.CommitRange(base.Hash().String(), "HEAD")
+	require.NoError(t, err)
+	require.Len(t, rewritten, 2)
+	assert.Equal(t, "feat: ajouter b", rewritten[0].Message)
+	assert.Equal(t, "feat: ajouter c", rewritten[1].Message)
+
+	newCommit, err := gitRepo.CommitObject(head.Hash())
+	require.NoError(t, err)
+	assert.Equal(t, oldHead.TreeHash, newCommit.TreeHash)
+	assert.Equal(t, oldHead.Author, newCommit.Author)
+	// The current user becomes the committer, as with git rebase
+	assert.Equal(t, "Rewording User", newCommit.Committer.Name)
+	assert.Equal(t, "reword@example.com", newCommit.Committer.Email)
+	assert.False(t, newCommit.Committer.When.Before(oldHead.Committer.When))
+
+	repo.SetReadOnly(true)
+	_, err = repo.RewordCommits(base.Hash().String(), nil)
+	assert.ErrorIs(t, err, ErrReadOnly)
+}
+
+func TestAddNote(t *testing.T) {
+	tempDir, _ := createTestRepo(t)
+
+	repo, err := NewRepository(tempDir)
+	require.NoError(t, err)
+
+	var gotArgs []string
+	original := runGit
+	runGit = func(dir string, args ...string) (string, error) {
+		gotArgs = args
+		return "", nil
+	}
+	t.Cleanup(func() { runGit = original })
+
+	require.NoError(t, repo.AddNote("translations", "abc123", "feat: ajouter"))
+	assert.Equal(t, []string{"notes", "--ref", "translations", "add", "-f", "-m", "feat: ajouter", "abc123"}, gotArgs)
+
+	repo.SetReadOnly(true)
+	assert.ErrorIs(t, repo.AddNote("", "abc123", "x"), ErrReadOnly)
+}
+
+func TestReadNote(t *testing.T) {
+	tempDir, gitRepo := createTestRepo(t)
+	commitFile(t, gitRepo, tempDir, "a.txt", "a")
+
+	repo, err := NewRepository(tempDir)
+	require.NoError(t, err)
+
+	head, err := repo.HeadHash()
+	require.NoError(t, err)
+
+	var gotArgs []string
+	original := runGit
+	runGit = func(dir string, args ...string) (string, error) {
+		gotArgs = args
+		return "note body", nil
+	}
+	t.Cleanup(func() { runGit = original })
+
+	note, err := repo.ReadNote("refs/notes/commit-ai", "HEAD")
+	require.NoError(t, err)
+	assert.Equal(t, "note body", note)
+	assert.Equal(t, []string{"notes", "--ref", "refs/notes/commit-ai", "show", head}, gotArgs)
+}
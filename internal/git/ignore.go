@@ -0,0 +1,161 @@
+package git
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	gitignore "github.com/sabhiram/go-gitignore"
+
+	"github.com/nseba/commit-ai/internal/diffparse"
+)
+
+// ignoreMatcher matches paths against the ignore files that apply to a
+// directory. It remembers its answers: the same paths are matched while a
+// diff is generated and again when it is filtered.
+type ignoreMatcher struct {
+	// patterns holds the lines of every ignore file, nil when there are none
+	patterns *gitignore.GitIgnore
+	matched  map[string]bool
+}
+
+// ignores reports whether the patterns ignore the work tree relative file
+func (m *ignoreMatcher) ignores(file string) bool {
+	if m.patterns == nil {
+		return false
+	}
+	if ignored, ok := m.matched[file]; ok {
+		return ignored
+	}
+	ignored := m.patterns.MatchesPath(file)
+	m.matched[file] = ignored
+	return ignored
+}
+
+// SetGlobalIgnoreFile sets an ignore file applied in every repository, such
+// as ~/.config/commit-ai/ignore, beneath the .caiignore files: a repository
+// can re-include what it ignores with a "!" pattern. A missing file is
+// skipped. It is set before SetIgnorePatterns.
+func (r *Repository) SetGlobalIgnoreFile(file string) {
+	r.globalIgnoreFile = file
+	r.ignoreMatchers = nil
+}
+
+// SetIgnorePatterns applies the .caiignore files that apply to basePath, and
+// the global ignore file, while diffs are generated: GetDiff, StagedDiff and
+// WorkingTreeDiff leave out ignored files without reading them, and
+// CommitDiff, RangeDiff and ScopeDiff drop them. Ignored files are still
+// staged and committed.
+func (r *Repository) SetIgnorePatterns(basePath string) error {
+	matcher, err := r.ignoreMatcherFor(basePath)
+	if err != nil {
+		return err
+	}
+	r.ignore = matcher
+	return nil
+}
+
+// IsIgnored reports whether the work tree relative file matches the patterns
+// set by SetIgnorePatterns
+func (r *Repository) IsIgnored(file string) bool {
+	return r.ignore != nil && r.ignore.ignores(file)
+}
+
+// ignoreMatcherFor returns the matcher of the .caiignore files that apply to
+// basePath, compiling them on first use
+func (r *Repository) ignoreMatcherFor(basePath string) (*ignoreMatcher, error) {
+	if matcher, ok := r.ignoreMatchers[basePath]; ok {
+		return matcher, nil
+	}
+	patterns, err := r.loadIgnorePatterns(basePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load ignore patterns: %w", err)
+	}
+	if r.ignoreMatchers == nil {
+		r.ignoreMatchers = make(map[string]*ignoreMatcher)
+	}
+	matcher := &ignoreMatcher{patterns: patterns, matched: make(map[string]bool)}
+	r.ignoreMatchers[basePath] = matcher
+	return matcher, nil
+}
+
+// ApplyIgnorePatterns filters the diff content based on .caiignore files and
+// the global ignore file
+func (r *Repository) ApplyIgnorePatterns(diff, basePath string) (string, error) {
+	matcher, err := r.ignoreMatcherFor(basePath)
+	if err != nil {
+		return "", err
+	}
+
+	if matcher.patterns == nil {
+		return diff, nil
+	}
+
+	// Text before the first file section has no path to match, and is dropped
+	preamble, files := diffparse.Split(diff)
+	var filteredSections []string
+
+	for _, file := range files {
+		filename := file.Path()
+		if filename != "" && !matcher.ignores(filename) {
+			filteredSections = append(filteredSections, file.Raw)
+		}
+	}
+
+	// A diff already filtered, e.g. after SetIgnorePatterns, is returned as is
+	if preamble == "" && len(filteredSections) == len(files) {
+		return diff, nil
+	}
+	return strings.Join(filteredSections, "\n"), nil
+}
+
+// loadIgnorePatterns compiles the global ignore file and the .caiignore
+// files from the root down to basePath into one set of patterns, later lines
+// taking precedence as in a single .gitignore. It returns nil when there are
+// no patterns.
+func (r *Repository) loadIgnorePatterns(basePath string) (*gitignore.GitIgnore, error) {
+	var files []string
+
+	// Walk up the directory tree looking for .caiignore files
+	currentPath := basePath
+	for {
+		files = append(files, filepath.Join(currentPath, ".caiignore"))
+
+		parent := filepath.Dir(currentPath)
+		if parent == currentPath {
+			break
+		}
+		currentPath = parent
+	}
+	if r.globalIgnoreFile != "" {
+		files = append(files, r.globalIgnoreFile)
+	}
+
+	var lines []string
+	found := false
+	for i := len(files) - 1; i >= 0; i-- {
+		if _, err := os.Stat(files[i]); err != nil {
+			continue
+		}
+		fileLines, err := ignoreCache.Get(files[i], readIgnoreFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to compile ignore file %s: %w", files[i], err)
+		}
+		lines = append(lines, fileLines...)
+		found = true
+	}
+	if !found {
+		return nil, nil
+	}
+	return gitignore.CompileIgnoreLines(lines...), nil
+}
+
+// readIgnoreFile reads the pattern lines of an ignore file
+func readIgnoreFile(file string) ([]string, error) {
+	data, err := os.ReadFile(file) // #nosec G304 -- .caiignore files and the global ignore file
+	if err != nil {
+		return nil, err
+	}
+	return strings.Split(string(data), "\n"), nil
+}
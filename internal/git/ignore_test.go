@@ -0,0 +1,130 @@
+package git
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSetIgnorePatterns(t *testing.T) {
+	tempDir, gitRepo := createTestRepo(t)
+	commitFile(t, gitRepo, tempDir, "main.go", "package main\n")
+	commitFile(t, gitRepo, tempDir, "debug.log", "old\n")
+	createTestFile(t, tempDir, ".caiignore", "*.log\n.caiignore\n")
+
+	createTestFile(t, tempDir, "main.go", "package main\n\nfunc main() {}\n")
+	createTestFile(t, tempDir, "debug.log", "new\n")
+
+	repo, err := NewRepository(tempDir)
+	require.NoError(t, err)
+	require.NoError(t, repo.SetIgnorePatterns(tempDir))
+
+	diff, err := repo.WorkingTreeDiff()
+	require.NoError(t, err)
+	assert.Contains(t, diff, "main.go")
+	assert.NotContains(t, diff, "debug.log")
+
+	// Nothing is left for ApplyIgnorePatterns to drop
+	filtered, err := repo.ApplyIgnorePatterns(diff, tempDir)
+	require.NoError(t, err)
+	assert.Equal(t, diff, filtered)
+
+	// Staged changes that are all ignored are still the staged changes,
+	// rather than a reason to describe the work tree
+	worktree, err := gitRepo.Worktree()
+	require.NoError(t, err)
+	_, err = worktree.Add("debug.log")
+	require.NoError(t, err)
+
+	diff, err = repo.GetDiff()
+	require.NoError(t, err)
+	assert.Empty(t, diff)
+}
+
+func TestSetIgnorePatterns_CommitDiff(t *testing.T) {
+	tempDir, gitRepo := createTestRepo(t)
+	createTestFile(t, tempDir, "build.log", "output\n")
+	worktree, err := gitRepo.Worktree()
+	require.NoError(t, err)
+	_, err = worktree.Add("build.log")
+	require.NoError(t, err)
+	commitFile(t, gitRepo, tempDir, "main.go", "package main\n")
+	createTestFile(t, tempDir, ".caiignore", "*.log\n")
+
+	repo, err := NewRepository(tempDir)
+	require.NoError(t, err)
+
+	_, diff, err := repo.CommitDiff("HEAD")
+	require.NoError(t, err)
+	assert.Contains(t, diff, "build.log")
+
+	require.NoError(t, repo.SetIgnorePatterns(tempDir))
+	_, diff, err = repo.CommitDiff("HEAD")
+	require.NoError(t, err)
+	assert.Contains(t, diff, "main.go")
+	assert.NotContains(t, diff, "build.log")
+}
+
+func TestIgnoreMatcher_RemembersMatches(t *testing.T) {
+	tempDir, _ := createTestRepo(t)
+	createTestFile(t, tempDir, ".caiignore", "vendor/\n")
+
+	repo, err := NewRepository(tempDir)
+	require.NoError(t, err)
+
+	matcher, err := repo.ignoreMatcherFor(tempDir)
+	require.NoError(t, err)
+	assert.True(t, matcher.ignores("vendor/lib/a.go"))
+	assert.False(t, matcher.ignores("main.go"))
+	assert.Equal(t, map[string]bool{"vendor/lib/a.go": true, "main.go": false}, matcher.matched)
+
+	again, err := repo.ignoreMatcherFor(tempDir)
+	require.NoError(t, err)
+	assert.Same(t, matcher, again, "patterns are compiled once per base path")
+}
+
+func TestSetGlobalIgnoreFile(t *testing.T) {
+	tempDir, _ := createTestRepo(t)
+	globalFile := filepath.Join(t.TempDir(), "ignore")
+	require.NoError(t, os.WriteFile(globalFile, []byte("*.log\n.idea/\n"), 0o644))
+	createTestFile(t, tempDir, ".caiignore", "!keep.log\n")
+
+	repo, err := NewRepository(tempDir)
+	require.NoError(t, err)
+	repo.SetGlobalIgnoreFile(globalFile)
+
+	diff := strings.Join([]string{
+		"diff --git a/debug.log b/debug.log\n+ignored",
+		"diff --git a/.idea/workspace.xml b/.idea/workspace.xml\n+ignored",
+		"diff --git a/keep.log b/keep.log\n+kept",
+		"diff --git a/main.go b/main.go\n+kept",
+	}, "\n")
+
+	filtered, err := repo.ApplyIgnorePatterns(diff, tempDir)
+	require.NoError(t, err)
+	assert.Equal(t, "diff --git a/keep.log b/keep.log\n+kept\ndiff --git a/main.go b/main.go\n+kept", filtered)
+
+	// A missing global file is skipped
+	repo.SetGlobalIgnoreFile(filepath.Join(t.TempDir(), "missing"))
+	filtered, err = repo.ApplyIgnorePatterns(diff, tempDir)
+	require.NoError(t, err)
+	assert.Equal(t, diff, filtered)
+}
+
+func TestLoadIgnorePatterns_InnerFilesTakePrecedence(t *testing.T) {
+	tempDir, _ := createTestRepo(t)
+	createTestFile(t, tempDir, ".caiignore", "*.gen.go\n")
+	createTestFile(t, tempDir, "api/.caiignore", "!api.gen.go\n")
+
+	repo, err := NewRepository(tempDir)
+	require.NoError(t, err)
+
+	matcher, err := repo.ignoreMatcherFor(filepath.Join(tempDir, "api"))
+	require.NoError(t, err)
+	assert.True(t, matcher.ignores("models.gen.go"))
+	assert.False(t, matcher.ignores("api/api.gen.go"))
+}
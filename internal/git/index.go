@@ -0,0 +1,176 @@
+package git
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/format/index"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// SetIndexOnly makes diffs compare the index with HEAD without reading the
+// work tree, for bare repositories on a server and slow file systems such as
+// NFS or WSL mounts: GetDiff and StagedDiff describe the staged changes only,
+// and WorkingTreeDiff and StageAll fail with ErrIndexOnly. Bare repositories,
+// which have no work tree, always are index-only.
+func (r *Repository) SetIndexOnly(indexOnly bool) {
+	r.indexOnly = indexOnly
+}
+
+// IndexOnly reports whether diffs are built without reading the work tree
+func (r *Repository) IndexOnly() bool {
+	return r.indexOnly || r.workTree == nil
+}
+
+// indexChange is a file whose index entry differs from HEAD
+type indexChange struct {
+	name string
+	// head is the HEAD entry, nil for added files
+	head *object.TreeEntry
+	// entry is the index entry, nil for deleted files
+	entry *index.Entry
+}
+
+// indexChanges returns the files whose index entries differ from HEAD, by
+// name. Only object hashes and modes are compared, so neither the work tree
+// nor any file content is read. Unmerged entries, files marked with
+// "git add -N" and submodules are left out.
+func (r *Repository) indexChanges() ([]indexChange, error) {
+	// A bare repository has no index, so nothing is staged
+	if r.workTree == nil {
+		if _, err := os.Stat(filepath.Join(r.path, "index")); os.IsNotExist(err) {
+			return nil, nil
+		}
+	}
+
+	idx, err := r.repo.Storer.Index()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read index: %w", indexError(err))
+	}
+
+	headEntries := make(map[string]object.TreeEntry)
+	if head, err := r.repo.Head(); err == nil {
+		headCommit, err := r.repo.CommitObject(head.Hash())
+		if err != nil {
+			return nil, fmt.Errorf("failed to get HEAD commit: %w", err)
+		}
+		headTree, err := headCommit.Tree()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get HEAD tree: %w", err)
+		}
+		walker := object.NewTreeWalker(headTree, true, nil)
+		defer walker.Close()
+		for {
+			name, entry, err := walker.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return nil, fmt.Errorf("failed to read HEAD tree: %w", err)
+			}
+			if entry.Mode != filemode.Dir {
+				headEntries[name] = entry
+			}
+		}
+	}
+
+	var changes []indexChange
+	inIndex := make(map[string]bool, len(idx.Entries))
+	for _, entry := range idx.Entries {
+		inIndex[entry.Name] = true
+		// Unmerged entries carry the stage of their side of the conflict
+		if entry.Stage >= index.AncestorMode || entry.IntentToAdd || entry.Mode == filemode.Submodule {
+			continue
+		}
+		head, ok := headEntries[entry.Name]
+		if ok && head.Hash == entry.Hash && head.Mode == entry.Mode {
+			continue
+		}
+		change := indexChange{name: entry.Name, entry: entry}
+		if ok {
+			change.head = &head
+		}
+		changes = append(changes, change)
+	}
+	for name, head := range headEntries {
+		if !inIndex[name] && head.Mode != filemode.Submodule {
+			changes = append(changes, indexChange{name: name, head: &head})
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool { return changes[i].name < changes[j].name })
+	return changes, nil
+}
+
+// getIndexDiff returns the diff of the index against HEAD, read from the
+// object database alone, and whether changed files were left out as ignored
+func (r *Repository) getIndexDiff() (string, bool, error) {
+	changes, err := r.indexChanges()
+	if err != nil {
+		return "", false, err
+	}
+
+	var diffLines []string
+	ignored := false
+	for _, change := range changes {
+		if !r.includeFile(change.name, &ignored) {
+			continue
+		}
+		fileDiff, err := r.indexFileDiff(change)
+		if err != nil {
+			return "", false, fmt.Errorf("failed to get diff for file %s: %w", change.name, err)
+		}
+		diffLines = append(diffLines, fileDiff)
+	}
+
+	return strings.Join(diffLines, "\n"), ignored, nil
+}
+
+// indexFileDiff generates the diff of a file changed in the index
+func (r *Repository) indexFileDiff(change indexChange) (string, error) {
+	var oldContent, newContent string
+	var err error
+	if change.head != nil {
+		if oldContent, err = r.blobContent(change.head.Hash); err != nil {
+			return "", err
+		}
+	}
+	if change.entry != nil {
+		if newContent, err = r.blobContent(change.entry.Hash); err != nil {
+			return "", err
+		}
+	}
+
+	switch {
+	case change.head == nil:
+		return r.newFileDiff(change.name, change.entry.Mode, newContent), nil
+	case change.entry == nil:
+		return r.deletedFileDiff(change.name, change.head.Mode, oldContent), nil
+	}
+	return r.generateModeDiff(change.name, change.head.Mode, change.entry.Mode, oldContent, newContent), nil
+}
+
+// blobContent returns the content of a blob as UTF-8 text
+func (r *Repository) blobContent(hash plumbing.Hash) (string, error) {
+	blob, err := r.repo.BlobObject(hash)
+	if err != nil {
+		return "", fmt.Errorf("failed to get blob %s: %w", hash, err)
+	}
+	reader, err := blob.Reader()
+	if err != nil {
+		return "", fmt.Errorf("failed to read blob %s: %w", hash, err)
+	}
+	defer reader.Close()
+
+	content, err := io.ReadAll(reader)
+	if err != nil {
+		return "", fmt.Errorf("failed to read blob %s: %w", hash, err)
+	}
+	return decodeContent(content), nil
+}
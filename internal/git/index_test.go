@@ -0,0 +1,86 @@
+package git
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIndexOnly(t *testing.T) {
+	tempDir, gitRepo := createTestRepo(t)
+	commitFile(t, gitRepo, tempDir, "README.md", "readme\n")
+	commitFile(t, gitRepo, tempDir, "old.txt", "old\n")
+
+	repo, err := NewRepository(tempDir)
+	require.NoError(t, err)
+	repo.SetIndexOnly(true)
+
+	// Nothing staged: the work tree isn't described instead
+	createTestFile(t, tempDir, "README.md", "readme\nunstaged\n")
+	diff, err := repo.GetDiff()
+	require.NoError(t, err)
+	assert.Empty(t, diff)
+
+	createTestFile(t, tempDir, "README.md", "readme\nstaged\n")
+	createTestFile(t, tempDir, "new.go", "package main\n")
+	_, err = repo.workTree.Add("README.md")
+	require.NoError(t, err)
+	_, err = repo.workTree.Add("new.go")
+	require.NoError(t, err)
+	_, err = repo.workTree.Remove("old.txt")
+	require.NoError(t, err)
+
+	// Later work tree changes are not read
+	createTestFile(t, tempDir, "README.md", "readme\nstaged\nunstaged\n")
+	require.NoError(t, os.Remove(filepath.Join(tempDir, "new.go")))
+
+	diff, err = repo.GetDiff()
+	require.NoError(t, err)
+	assert.Contains(t, diff, "+staged")
+	assert.NotContains(t, diff, "unstaged")
+	assert.Contains(t, diff, "diff --git a/new.go b/new.go\nnew file mode 100644")
+	assert.Contains(t, diff, "+package main")
+	assert.Contains(t, diff, "diff --git a/old.txt b/old.txt\ndeleted file mode 100644")
+
+	_, err = repo.WorkingTreeDiff()
+	assert.ErrorIs(t, err, ErrIndexOnly)
+	assert.ErrorIs(t, repo.StageAll(), ErrIndexOnly)
+
+	require.NoError(t, repo.Commit("feat: add new.go"))
+	diff, err = repo.StagedDiff()
+	require.NoError(t, err)
+	assert.Empty(t, diff)
+}
+
+func TestIndexOnly_BareRepository(t *testing.T) {
+	tempDir, gitRepo := createTestRepo(t)
+	commitFile(t, gitRepo, tempDir, "main.go", "package main\n")
+	head, err := gitRepo.Head()
+	require.NoError(t, err)
+	commitFile(t, gitRepo, tempDir, "main.go", "package main\n\nfunc main() {}\n")
+
+	bare := filepath.Join(t.TempDir(), "repo.git")
+	_, err = git.PlainClone(bare, true, &git.CloneOptions{URL: tempDir})
+	require.NoError(t, err)
+
+	repo, err := NewRepository(bare)
+	require.NoError(t, err)
+	assert.True(t, repo.IndexOnly())
+	dir, err := repo.GitDir()
+	require.NoError(t, err)
+	assert.Equal(t, bare, dir)
+
+	diff, err := repo.GetDiff()
+	require.NoError(t, err)
+	assert.Empty(t, diff)
+
+	diff, err = repo.RangeDiff(head.Hash().String(), "HEAD")
+	require.NoError(t, err)
+	assert.Contains(t, diff, "+func main() {}")
+
+	assert.ErrorIs(t, repo.Commit("feat: add main"), git.ErrIsBareRepository)
+}
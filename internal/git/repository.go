@@ -1,38 +1,108 @@
 package git
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
+	"io"
+	"net/url"
 	"os"
+	"os/exec"
+	"path"
 	"path/filepath"
+	"sort"
 	"strings"
 	"time"
 
 	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/format/gitattributes"
 	"github.com/go-git/go-git/v5/plumbing/object"
-	gitignore "github.com/sabhiram/go-gitignore"
+	"github.com/go-git/go-git/v5/plumbing/storer"
+
+	"github.com/nseba/commit-ai/internal/diffparse"
+	"github.com/nseba/commit-ai/internal/filecache"
+	"github.com/nseba/commit-ai/internal/lfs"
+)
+
+// runGit executes the git CLI in dir and returns its combined output. It is
+// used for operations go-git doesn't implement and is a variable so tests can
+// stub it.
+var runGit = func(dir string, args ...string) (string, error) {
+	cmd := exec.Command("git", args...) // #nosec G204 -- args are built by this package
+	cmd.Dir = dir
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("git %s: %w: %s", strings.Join(args, " "), err, strings.TrimSpace(string(out)))
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// ignoreCache holds the lines of ignore files and repoCache opened
+// repositories, keyed by path and reloaded when the file (or .git entry) changes
+var (
+	ignoreCache = filecache.New[[]string]()
+	repoCache   = filecache.New[*git.Repository]()
 )
 
+// ErrReadOnly is returned by operations that would modify the repository
+// while read-only mode is enabled.
+var ErrReadOnly = errors.New("repository is in read-only mode")
+
+// ErrIndexOnly is returned by operations that need the work tree while
+// index-only mode is enabled or the repository is bare
+var ErrIndexOnly = errors.New("the work tree isn't read in index-only mode")
+
 // Repository represents a git repository with additional functionality
 type Repository struct {
-	repo     *git.Repository
-	workTree *git.Worktree
-	path     string
+	repo *git.Repository
+	// workTree is nil in a bare repository
+	workTree   *git.Worktree
+	path       string
+	readOnly   bool
+	allowEmpty bool
+	// indexOnly builds diffs from the index and HEAD alone
+	indexOnly bool
+	// authorName and authorEmail override the author of new commits
+	authorName  string
+	authorEmail string
+	// scope limits GetDiff to files below this work tree relative directory
+	scope string
+	// pathspecs, when set, limit diffs, staging and commits to matching files
+	pathspecs []string
+	// excludes drop matching files from diffs
+	excludes []string
+	// ignore, when set, drops the files matched by ignore files from diffs;
+	// ignoreMatchers caches the compiled ignore files by base path
+	ignore         *ignoreMatcher
+	ignoreMatchers map[string]*ignoreMatcher
+	// globalIgnoreFile applies beneath the .caiignore files of every directory
+	globalIgnoreFile string
+	// attributes caches the .gitattributes patterns of work tree
+	// directories, keyed by slash-separated relative path ("" is the root)
+	attributes map[string][]gitattributes.MatchAttribute
+	// sparse holds the sparse-checkout patterns, loaded on first use
+	sparse *sparsePatterns
 }
 
-// NewRepository creates a new Repository instance
+// NewRepository creates a new Repository instance for the repository whose
+// work tree contains path, which may be any directory inside it
 func NewRepository(path string) (*Repository, error) {
 	absPath, err := filepath.Abs(path)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get absolute path: %w", err)
 	}
+	absPath = findWorkTreeRoot(absPath)
 
-	repo, err := git.PlainOpen(absPath)
+	repo, err := openRepository(absPath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open git repository at %s: %w", absPath, err)
 	}
 
 	workTree, err := repo.Worktree()
-	if err != nil {
+	if err != nil && !errors.Is(err, git.ErrIsBareRepository) {
 		return nil, fmt.Errorf("failed to get worktree: %w", err)
 	}
 
@@ -43,24 +113,478 @@ func NewRepository(path string) (*Repository, error) {
 	}, nil
 }
 
-// GetDiff returns the diff of staged changes, or unstaged changes if nothing is staged
+// findWorkTreeRoot returns the closest directory at or above path that
+// contains a .git entry, or path itself when there is none
+func findWorkTreeRoot(path string) string {
+	for dir := path; ; {
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+			return dir
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return path
+		}
+		dir = parent
+	}
+}
+
+// openRepository opens the repository at path, reusing a previously opened
+// instance while its .git entry is unchanged
+func openRepository(path string) (*git.Repository, error) {
+	if _, err := os.Stat(filepath.Join(path, ".git")); err != nil {
+		return git.PlainOpen(path)
+	}
+	return repoCache.Get(filepath.Join(path, ".git"), func(string) (*git.Repository, error) {
+		return git.PlainOpen(path)
+	})
+}
+
+// Path returns the absolute path of the repository work tree, or of the
+// repository itself when it is bare
+func (r *Repository) Path() string {
+	return r.path
+}
+
+// GitDir returns the absolute path of the repository's git directory: the
+// .git directory of the work tree, or the directory a .git file points to in
+// linked work trees and submodules
+func (r *Repository) GitDir() (string, error) {
+	if r.workTree == nil {
+		return r.path, nil
+	}
+	dotGit := filepath.Join(r.path, ".git")
+	info, err := os.Stat(dotGit)
+	if err != nil {
+		return "", fmt.Errorf("failed to find git directory: %w", err)
+	}
+	if info.IsDir() {
+		return dotGit, nil
+	}
+
+	data, err := os.ReadFile(dotGit) // #nosec G304 -- the .git file of the opened repository
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", dotGit, err)
+	}
+	dir, ok := strings.CutPrefix(strings.TrimSpace(string(data)), "gitdir:")
+	if !ok {
+		return "", fmt.Errorf("invalid .git file %s", dotGit)
+	}
+	dir = strings.TrimSpace(dir)
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(r.path, dir)
+	}
+	return filepath.Clean(dir), nil
+}
+
+// HooksDir returns the directory git runs hooks from: core.hooksPath when it
+// is set, otherwise the hooks directory of the main repository, which
+// linked worktrees share
+func (r *Repository) HooksDir() (string, error) {
+	dir, err := runGit(r.path, "rev-parse", "--git-path", "hooks")
+	if err != nil {
+		return "", fmt.Errorf("failed to find hooks directory: %w", err)
+	}
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(r.path, dir)
+	}
+	return filepath.Clean(dir), nil
+}
+
+// Editor returns core.editor from the repository or global git config, or an
+// empty string when it is not set
+func (r *Repository) Editor() string {
+	cfg, err := r.repo.ConfigScoped(config.GlobalScope)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(cfg.Raw.Section("core").Option("editor"))
+}
+
+// SetReadOnly enables or disables read-only mode. In read-only mode every
+// operation that writes to the index or history fails with ErrReadOnly.
+func (r *Repository) SetReadOnly(readOnly bool) {
+	r.readOnly = readOnly
+}
+
+// SetAllowEmpty controls whether Commit may create a commit without staged changes
+func (r *Repository) SetAllowEmpty(allowEmpty bool) {
+	r.allowEmpty = allowEmpty
+}
+
+// SetAuthor sets the author of the commits created by Commit, instead of
+// the one from the environment
+func (r *Repository) SetAuthor(name, email string) {
+	r.authorName, r.authorEmail = name, email
+}
+
+// SetScope limits GetDiff to changes below dir, which must be inside the work tree
+func (r *Repository) SetScope(dir string) error {
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return fmt.Errorf("failed to get absolute path: %w", err)
+	}
+
+	rel, err := filepath.Rel(r.path, absDir)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return fmt.Errorf("%s is outside the repository work tree %s", absDir, r.path)
+	}
+
+	if rel == "." {
+		r.scope = ""
+	} else {
+		r.scope = filepath.ToSlash(rel)
+	}
+	return nil
+}
+
+// SetPathspecs limits GetDiff, StageAll and Commit to files matching any of
+// specs. Specs are paths or shell globs relative to the current directory; a
+// path also matches everything below it.
+func (r *Repository) SetPathspecs(specs []string) error {
+	resolved, err := r.resolvePathspecs(specs)
+	if err != nil {
+		return err
+	}
+	r.pathspecs = resolved
+	return nil
+}
+
+// SetExcludes drops files matching any of specs from GetDiff, like a one-off
+// .caiignore. Specs use the same syntax as SetPathspecs.
+func (r *Repository) SetExcludes(specs []string) error {
+	resolved, err := r.resolvePathspecs(specs)
+	if err != nil {
+		return err
+	}
+	r.excludes = resolved
+	return nil
+}
+
+// resolvePathspecs makes specs relative to the work tree root
+func (r *Repository) resolvePathspecs(specs []string) ([]string, error) {
+	var resolved []string
+	for _, spec := range specs {
+		absSpec, err := filepath.Abs(spec)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get absolute path: %w", err)
+		}
+
+		rel, err := filepath.Rel(r.path, absSpec)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return nil, fmt.Errorf("pathspec %s is outside the repository work tree %s", spec, r.path)
+		}
+		resolved = append(resolved, filepath.ToSlash(rel))
+	}
+	return resolved, nil
+}
+
+// inScope reports whether the work tree relative file is below the scope,
+// matches the pathspecs and isn't excluded
+func (r *Repository) inScope(file string) bool {
+	return (r.scope == "" || file == r.scope || strings.HasPrefix(file, r.scope+"/")) &&
+		r.inPathspecs(file) && !matchPathspecs(r.excludes, file)
+}
+
+// inPathspecs reports whether the work tree relative file matches the pathspecs
+func (r *Repository) inPathspecs(file string) bool {
+	return len(r.pathspecs) == 0 || matchPathspecs(r.pathspecs, file)
+}
+
+// matchPathspecs reports whether the work tree relative file matches any of specs
+func matchPathspecs(specs []string, file string) bool {
+	file = filepath.ToSlash(file)
+	for _, spec := range specs {
+		if spec == "." {
+			return true
+		}
+		// Match the file itself or any of its parent directories
+		for candidate := file; candidate != "." && candidate != "/"; candidate = path.Dir(candidate) {
+			if candidate == spec {
+				return true
+			}
+			if matched, err := path.Match(spec, candidate); err == nil && matched {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// GetDiff returns the diff of staged changes, or unstaged changes if nothing
+// is staged and the work tree is read
 func (r *Repository) GetDiff() (string, error) {
 	// First, try to get staged changes
-	stagedDiff, err := r.getStagedDiff()
+	stagedDiff, ignored, err := r.getStagedDiff()
 	if err != nil {
 		return "", fmt.Errorf("failed to get staged diff: %w", err)
 	}
 
-	if stagedDiff != "" {
+	// Staged changes are what gets committed even when all of them are
+	// ignored, so the work tree isn't described instead
+	if stagedDiff != "" || ignored || r.IndexOnly() {
 		return stagedDiff, nil
 	}
 
 	// If no staged changes, get unstaged changes
-	return r.getUnstagedDiff()
+	unstagedDiff, _, err := r.getUnstagedDiff()
+	if err != nil {
+		return "", err
+	}
+	return unstagedDiff, nil
+}
+
+// StagedDiff returns the diff of staged changes
+func (r *Repository) StagedDiff() (string, error) {
+	diff, _, err := r.getStagedDiff()
+	return diff, err
+}
+
+// WorkingTreeDiff returns the diff of changes in the working tree
+func (r *Repository) WorkingTreeDiff() (string, error) {
+	diff, _, err := r.getUnstagedDiff()
+	return diff, err
+}
+
+// RangeDiff returns the diff between the from and to revisions, such as a
+// branch, tag or commit hash
+func (r *Repository) RangeDiff(from, to string) (string, error) {
+	fromCommit, err := r.resolveCommit(from)
+	if err != nil {
+		return "", err
+	}
+	toCommit, err := r.resolveCommit(to)
+	if err != nil {
+		return "", err
+	}
+
+	fromTree, err := fromCommit.Tree()
+	if err != nil {
+		return "", fmt.Errorf("failed to read tree of %s: %w", from, err)
+	}
+	toTree, err := toCommit.Tree()
+	if err != nil {
+		return "", fmt.Errorf("failed to read tree of %s: %w", to, err)
+	}
+
+	changes, err := object.DiffTree(fromTree, toTree)
+	if err != nil {
+		return "", fmt.Errorf("failed to compute diff: %w", err)
+	}
+	patch, err := r.filterChanges(changes).Patch()
+	if err != nil {
+		return "", fmt.Errorf("failed to compute diff: %w", err)
+	}
+	return patch.String(), nil
 }
 
-// getStagedDiff returns the diff of staged changes
-func (r *Repository) getStagedDiff() (string, error) {
+// resolveCommit resolves a revision to its commit
+func (r *Repository) resolveCommit(revision string) (*object.Commit, error) {
+	hash, err := r.repo.ResolveRevision(plumbing.Revision(revision))
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve revision %s: %w", revision, err)
+	}
+	commit, err := r.repo.CommitObject(*hash)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get commit %s: %w", revision, err)
+	}
+	return commit, nil
+}
+
+// ScopeDiff applies the scope, pathspecs and excludes of the repository to a
+// diff obtained elsewhere, e.g. from a patch file
+func (r *Repository) ScopeDiff(diff string) string {
+	return r.scopeDiff(diff)
+}
+
+// scopeDiff drops the file sections of diff outside the scope set by SetScope
+// or the pathspecs set by SetPathspecs, and those excluded by SetExcludes or
+// ignored after SetIgnorePatterns
+func (r *Repository) scopeDiff(diff string) string {
+	if (r.scope == "" && len(r.pathspecs) == 0 && len(r.excludes) == 0 && r.ignore == nil) || diff == "" {
+		return diff
+	}
+
+	preamble, files := diffparse.Split(diff)
+	var kept []string
+	for _, file := range files {
+		if r.inScope(file.Path()) && !r.IsIgnored(file.Path()) {
+			kept = append(kept, file.Raw)
+		}
+	}
+	if !r.inScope("") {
+		preamble = ""
+	}
+	return preamble + strings.Join(kept, "\n")
+}
+
+// filterChanges drops the changes outside the scope, pathspecs and excludes,
+// and those ignored after SetIgnorePatterns, before their blobs are read for
+// the patch
+func (r *Repository) filterChanges(changes object.Changes) object.Changes {
+	kept := make(object.Changes, 0, len(changes))
+	for _, change := range changes {
+		file := change.To.Name
+		if file == "" {
+			file = change.From.Name
+		}
+		if r.inScope(file) && !r.IsIgnored(file) {
+			kept = append(kept, change)
+		}
+	}
+	return kept
+}
+
+// AddNote attaches message as a git note to the commit, replacing an
+// existing note. An empty ref uses git's default notes ref.
+func (r *Repository) AddNote(ref, hash, message string) error {
+	if r.readOnly {
+		return ErrReadOnly
+	}
+
+	args := []string{"notes"}
+	if ref != "" {
+		args = append(args, "--ref", ref)
+	}
+	args = append(args, "add", "-f", "-m", message, hash)
+	if _, err := runGit(r.path, args...); err != nil {
+		return fmt.Errorf("failed to add note to %s: %w", hash, err)
+	}
+	return nil
+}
+
+// ReadNote returns the note attached to the commit rev under ref
+func (r *Repository) ReadNote(ref, rev string) (string, error) {
+	commit, err := r.resolveCommit(rev)
+	if err != nil {
+		return "", err
+	}
+
+	args := []string{"notes"}
+	if ref != "" {
+		args = append(args, "--ref", ref)
+	}
+	note, err := runGit(r.path, append(args, "show", commit.Hash.String())...)
+	if err != nil {
+		return "", fmt.Errorf("no note found for %s: %w", rev, err)
+	}
+	return note, nil
+}
+
+// HeadHash returns the hash of the commit HEAD points to
+func (r *Repository) HeadHash() (string, error) {
+	head, err := r.repo.Head()
+	if err != nil {
+		return "", fmt.Errorf("failed to get HEAD: %w", err)
+	}
+	return head.Hash().String(), nil
+}
+
+// RewordCommits rewrites the messages of the commits after from up to HEAD,
+// like an interactive rebase that only rewords. messages maps commit hashes to
+// their new message; other commits keep theirs. Trees and authors are
+// preserved, so the working tree and index are unaffected, while the current
+// user becomes the committer, as with git rebase. Merge commits are not
+// supported. It returns the new HEAD hash.
+func (r *Repository) RewordCommits(from string, messages map[string]string) (string, error) {
+	if r.readOnly {
+		return "", ErrReadOnly
+	}
+
+	head, err := r.repo.Head()
+	if err != nil {
+		return "", fmt.Errorf("failed to get HEAD: %w", err)
+	}
+	if !head.Name().IsBranch() {
+		return "", fmt.Errorf("cannot reword commits on a detached HEAD")
+	}
+
+	commits, err := r.CommitRange(from, "HEAD")
+	if err != nil {
+		return "", err
+	}
+	if len(commits) == 0 {
+		return head.Hash().String(), nil
+	}
+
+	committer := r.committer()
+	var parent plumbing.Hash
+	for i, hc := range commits {
+		original, err := r.repo.CommitObject(plumbing.NewHash(hc.Hash))
+		if err != nil {
+			return "", fmt.Errorf("failed to read commit %s: %w", hc.Hash, err)
+		}
+		if original.NumParents() > 1 {
+			return "", fmt.Errorf("cannot reword merge commit %s", hc.Hash)
+		}
+
+		rewritten := &object.Commit{
+			Author:       original.Author,
+			Committer:    committer,
+			Message:      original.Message,
+			TreeHash:     original.TreeHash,
+			ParentHashes: original.ParentHashes,
+		}
+		if msg, ok := messages[hc.Hash]; ok {
+			rewritten.Message = msg
+		}
+		if i > 0 {
+			rewritten.ParentHashes = []plumbing.Hash{parent}
+		}
+
+		obj := r.repo.Storer.NewEncodedObject()
+		if err := rewritten.Encode(obj); err != nil {
+			return "", fmt.Errorf("failed to encode commit: %w", err)
+		}
+		if parent, err = r.repo.Storer.SetEncodedObject(obj); err != nil {
+			return "", fmt.Errorf("failed to write commit: %w", err)
+		}
+	}
+
+	// Only move the branch if nobody else moved it in the meantime
+	newRef := plumbing.NewHashReference(head.Name(), parent)
+	if err := r.repo.Storer.CheckAndSetReference(newRef, head); err != nil {
+		return "", fmt.Errorf("failed to update %s: %w", head.Name().Short(), err)
+	}
+	return parent.String(), nil
+}
+
+// committer returns the identity of the current user at the current time:
+// GIT_COMMITTER_NAME and GIT_COMMITTER_EMAIL, or the committer or user
+// identity of the git config, falling back to the author of new commits
+func (r *Repository) committer() object.Signature {
+	sig := object.Signature{
+		Name:  os.Getenv("GIT_COMMITTER_NAME"),
+		Email: os.Getenv("GIT_COMMITTER_EMAIL"),
+		When:  time.Now(),
+	}
+	if cfg, err := r.repo.ConfigScoped(config.GlobalScope); err == nil {
+		for _, name := range []string{cfg.Committer.Name, cfg.User.Name} {
+			if sig.Name == "" {
+				sig.Name = name
+			}
+		}
+		for _, email := range []string{cfg.Committer.Email, cfg.User.Email} {
+			if sig.Email == "" {
+				sig.Email = email
+			}
+		}
+	}
+	if sig.Name == "" {
+		sig.Name = getGitConfigValue("user.name")
+	}
+	if sig.Email == "" {
+		sig.Email = getGitConfigValue("user.email")
+	}
+	return sig
+}
+
+// getStagedDiff returns the diff of staged changes, and whether staged files
+// were left out as ignored
+func (r *Repository) getStagedDiff() (string, bool, error) {
+	if r.IndexOnly() {
+		return r.getIndexDiff()
+	}
+
 	head, err := r.repo.Head()
 	if err != nil {
 		// If there's no HEAD (empty repo), compare against empty tree
@@ -69,30 +593,31 @@ func (r *Repository) getStagedDiff() (string, error) {
 
 	headCommit, err := r.repo.CommitObject(head.Hash())
 	if err != nil {
-		return "", fmt.Errorf("failed to get HEAD commit: %w", err)
+		return "", false, fmt.Errorf("failed to get HEAD commit: %w", err)
 	}
 
 	headTree, err := headCommit.Tree()
 	if err != nil {
-		return "", fmt.Errorf("failed to get HEAD tree: %w", err)
+		return "", false, fmt.Errorf("failed to get HEAD tree: %w", err)
 	}
 
 	// Get the index (staging area)
-	status, err := r.workTree.Status()
+	status, err := r.status()
 	if err != nil {
-		return "", fmt.Errorf("failed to get status: %w", err)
+		return "", false, fmt.Errorf("failed to get status: %w", err)
 	}
 
 	var diffLines []string
+	ignored := false
 	for file, fileStatus := range status {
 		// Only process staged files
-		if fileStatus.Staging == git.Unmodified {
+		if fileStatus.Staging == git.Unmodified || !r.includeFile(file, &ignored) {
 			continue
 		}
 
 		fileDiff, err := r.getFileDiff(file, headTree)
 		if err != nil {
-			return "", fmt.Errorf("failed to get diff for file %s: %w", file, err)
+			return "", false, fmt.Errorf("failed to get diff for file %s: %w", file, err)
 		}
 
 		if fileDiff != "" {
@@ -100,14 +625,19 @@ func (r *Repository) getStagedDiff() (string, error) {
 		}
 	}
 
-	return strings.Join(diffLines, "\n"), nil
+	return strings.Join(diffLines, "\n"), ignored, nil
 }
 
-// getUnstagedDiff returns the diff of unstaged changes
-func (r *Repository) getUnstagedDiff() (string, error) {
-	status, err := r.workTree.Status()
+// getUnstagedDiff returns the diff of unstaged changes, and whether changed
+// files were left out as ignored
+func (r *Repository) getUnstagedDiff() (string, bool, error) {
+	if r.IndexOnly() {
+		return "", false, ErrIndexOnly
+	}
+
+	status, err := r.status()
 	if err != nil {
-		return "", fmt.Errorf("failed to get status: %w", err)
+		return "", false, fmt.Errorf("failed to get status: %w", err)
 	}
 
 	head, err := r.repo.Head()
@@ -118,24 +648,25 @@ func (r *Repository) getUnstagedDiff() (string, error) {
 
 	headCommit, err := r.repo.CommitObject(head.Hash())
 	if err != nil {
-		return "", fmt.Errorf("failed to get HEAD commit: %w", err)
+		return "", false, fmt.Errorf("failed to get HEAD commit: %w", err)
 	}
 
 	headTree, err := headCommit.Tree()
 	if err != nil {
-		return "", fmt.Errorf("failed to get HEAD tree: %w", err)
+		return "", false, fmt.Errorf("failed to get HEAD tree: %w", err)
 	}
 
 	var diffLines []string
+	ignored := false
 	for file, fileStatus := range status {
 		// Only process modified files in working directory
-		if fileStatus.Worktree == git.Unmodified {
+		if fileStatus.Worktree == git.Unmodified || !r.includeFile(file, &ignored) {
 			continue
 		}
 
 		fileDiff, err := r.getFileDiff(file, headTree)
 		if err != nil {
-			return "", fmt.Errorf("failed to get diff for file %s: %w", file, err)
+			return "", false, fmt.Errorf("failed to get diff for file %s: %w", file, err)
 		}
 
 		if fileDiff != "" {
@@ -143,38 +674,52 @@ func (r *Repository) getUnstagedDiff() (string, error) {
 		}
 	}
 
-	return strings.Join(diffLines, "\n"), nil
+	return strings.Join(diffLines, "\n"), ignored, nil
 }
 
 // getInitialCommitDiff handles the case when there's no HEAD (empty repository)
-func (r *Repository) getInitialCommitDiff() (string, error) {
-	status, err := r.workTree.Status()
+func (r *Repository) getInitialCommitDiff() (string, bool, error) {
+	status, err := r.status()
 	if err != nil {
-		return "", fmt.Errorf("failed to get status: %w", err)
+		return "", false, fmt.Errorf("failed to get status: %w", err)
 	}
 
 	var diffLines []string
+	ignored := false
 	for file, fileStatus := range status {
 		if fileStatus.Staging == git.Untracked && fileStatus.Worktree == git.Untracked {
 			continue
 		}
+		if !r.includeFile(file, &ignored) {
+			continue
+		}
 
 		if err := r.validatePath(file); err != nil {
 			continue // Skip invalid paths
 		}
-		filePath := filepath.Join(r.path, file)
-		content, err := os.ReadFile(filePath) // #nosec G304 -- path validated by validatePath()
+		content, mode, err := r.readWorkTreeEntry(file, filemode.Regular)
 		if err != nil {
 			continue // Skip files that can't be read
 		}
 
-		diff := fmt.Sprintf("diff --git a/%s b/%s\nnew file mode 100644\nindex 0000000..%s\n--- /dev/null\n+++ b/%s\n%s",
-			file, file, "xxxxxxx", file, addPlusPrefix(string(content)))
-
-		diffLines = append(diffLines, diff)
+		diffLines = append(diffLines, r.newFileDiff(file, mode, content))
 	}
 
-	return strings.Join(diffLines, "\n"), nil
+	return strings.Join(diffLines, "\n"), ignored, nil
+}
+
+// includeFile reports whether generated diffs include the changed, work tree
+// relative file. It is checked before the file is read, so files outside the
+// scope and ignored files cost nothing; the latter set ignored.
+func (r *Repository) includeFile(file string, ignored *bool) bool {
+	if !r.inScope(file) {
+		return false
+	}
+	if r.IsIgnored(file) {
+		*ignored = true
+		return false
+	}
+	return true
 }
 
 // getFileDiff gets the diff for a specific file
@@ -182,10 +727,15 @@ func (r *Repository) getFileDiff(filename string, headTree *object.Tree) (string
 	if err := r.validatePath(filename); err != nil {
 		return "", err
 	}
-	filePath := filepath.Join(r.path, filename)
+	// The HEAD mode is kept when core.fileMode says the executable bit of
+	// the work tree can't be trusted
+	headMode := filemode.Regular
+	if entry, err := headTree.FindEntry(filename); err == nil {
+		headMode = entry.Mode
+	}
 
 	// Read current file content
-	currentContent, err := os.ReadFile(filePath) // #nosec G304 -- path validated by validatePath()
+	currentContent, currentMode, err := r.readWorkTreeEntry(filename, headMode)
 	if os.IsNotExist(err) {
 		// File was deleted
 		return r.getDeletedFileDiff(filename, headTree)
@@ -198,11 +748,122 @@ func (r *Repository) getFileDiff(filename string, headTree *object.Tree) (string
 	headContent, err := r.getFileContentFromTree(filename, headTree)
 	if err != nil {
 		// New file
-		return r.getNewFileDiff(filename, string(currentContent)), nil
+		return r.newFileDiff(filename, currentMode, currentContent), nil
 	}
 
 	// Generate diff
-	return r.generateDiff(filename, headContent, string(currentContent)), nil
+	return r.generateModeDiff(filename, headMode, currentMode, headContent, currentContent), nil
+}
+
+// readWorkTreeEntry returns the content and git file mode of a work tree
+// file. Symlinks are not followed: like git, their content is the link
+// target. When core.fileMode is false the executable bit isn't trusted and
+// a regular or executable file keeps knownMode.
+func (r *Repository) readWorkTreeEntry(filename string, knownMode filemode.FileMode) (string, filemode.FileMode, error) {
+	filePath := filepath.Join(r.path, filename)
+	info, err := os.Lstat(filePath)
+	if err != nil {
+		return "", filemode.Empty, err
+	}
+
+	if info.Mode()&os.ModeSymlink != 0 {
+		target, err := os.Readlink(filePath)
+		return target, filemode.Symlink, err
+	}
+
+	mode := filemode.Regular
+	switch {
+	case !r.trustExecutableBit() && (knownMode == filemode.Regular || knownMode == filemode.Executable):
+		mode = knownMode
+	case info.Mode().Perm()&0o111 != 0:
+		mode = filemode.Executable
+	}
+
+	content, err := r.readWorkTreeFile(filename)
+	if err == nil && r.normalizesLineEndings(filename) {
+		content = normalizeLineEndings(content)
+	}
+	return content, mode, err
+}
+
+// trustExecutableBit reports whether core.fileMode allows reading the
+// executable bit from the work tree; git defaults it to true
+func (r *Repository) trustExecutableBit() bool {
+	cfg, err := r.repo.Config()
+	if err != nil {
+		return true
+	}
+	value := cfg.Raw.Section("core").Option("filemode")
+	return !strings.EqualFold(value, "false")
+}
+
+// readWorkTreeFile returns the content of a work tree file as git stores it.
+// Files tracked by Git LFS are usually checked out as the large object, so
+// their pointer is computed by hashing the file instead of reading it into
+// the diff.
+func (r *Repository) readWorkTreeFile(filename string) (string, error) {
+	filePath := filepath.Join(r.path, filename)
+	if !r.isLFSTracked(filename) {
+		content, err := os.ReadFile(filePath) // #nosec G304 -- callers validate filename with validatePath()
+		return decodeContent(content), err
+	}
+
+	f, err := os.Open(filePath) // #nosec G304 -- callers validate filename with validatePath()
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = f.Close() }()
+
+	head := make([]byte, 1024)
+	n, err := io.ReadFull(f, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return "", err
+	}
+	if pointer, ok := lfs.Parse(string(head[:n])); ok && n < len(head) {
+		// Not smudged, e.g. when git-lfs isn't installed
+		return pointer.String(), nil
+	}
+
+	pointer, err := lfs.FromReader(io.MultiReader(bytes.NewReader(head[:n]), f))
+	if err != nil {
+		return "", fmt.Errorf("failed to hash %s: %w", filename, err)
+	}
+	return pointer.String(), nil
+}
+
+// isLFSTracked reports whether .gitattributes assigns the lfs filter to file
+func (r *Repository) isLFSTracked(filename string) bool {
+	filter, ok := r.attributesFor(filename, "filter")["filter"]
+	return ok && filter.IsValueSet() && filter.Value() == "lfs"
+}
+
+// attributesFor returns the .gitattributes attributes of file among names.
+// Only the .gitattributes files of the directories containing file are read,
+// rather than those of the whole work tree.
+func (r *Repository) attributesFor(filename string, names ...string) map[string]gitattributes.Attribute {
+	if r.attributes == nil {
+		r.attributes = make(map[string][]gitattributes.MatchAttribute)
+	}
+
+	parts := strings.Split(filename, "/")
+	var stack []gitattributes.MatchAttribute
+	for i := 0; i < len(parts); i++ {
+		dir := strings.Join(parts[:i], "/")
+		patterns, ok := r.attributes[dir]
+		if !ok {
+			// Unreadable attribute files are ignored like missing ones; the
+			// capacity is capped so the callee's append can't overwrite parts
+			patterns, _ = gitattributes.ReadAttributesFile(r.workTree.Filesystem, parts[:i:i], ".gitattributes", i == 0)
+			r.attributes[dir] = patterns
+		}
+		stack = append(stack, patterns...)
+	}
+	if len(stack) == 0 {
+		return nil
+	}
+
+	attrs, _ := gitattributes.NewMatcher(stack).Match(parts, names)
+	return attrs
 }
 
 // getFileContentFromTree retrieves file content from a tree
@@ -217,13 +878,22 @@ func (r *Repository) getFileContentFromTree(filename string, tree *object.Tree)
 		return "", fmt.Errorf("failed to get file contents: %w", err)
 	}
 
-	return content, nil
+	return decodeContent([]byte(content)), nil
 }
 
-// getNewFileDiff generates diff for a new file
+// getNewFileDiff generates diff for a new regular file
 func (r *Repository) getNewFileDiff(filename, content string) string {
-	return fmt.Sprintf("diff --git a/%s b/%s\nnew file mode 100644\nindex 0000000..%s\n--- /dev/null\n+++ b/%s\n%s",
-		filename, filename, "xxxxxxx", filename, addPlusPrefix(content))
+	return r.newFileDiff(filename, filemode.Regular, content)
+}
+
+// newFileDiff generates diff for a new file with the given mode
+func (r *Repository) newFileDiff(filename string, mode filemode.FileMode, content string) string {
+	if isBinaryContent(content) {
+		return fmt.Sprintf("diff --git %s %s\nnew file mode %s\nindex 0000000..%s\nBinary files /dev/null and %s differ",
+			oldName(filename), newName(filename), gitMode(mode), "xxxxxxx", newName(filename))
+	}
+	return fmt.Sprintf("diff --git %s %s\nnew file mode %s\nindex 0000000..%s\n--- /dev/null\n+++ %s\n%s",
+		oldName(filename), newName(filename), gitMode(mode), "xxxxxxx", newName(filename), addPlusPrefix(content))
 }
 
 // getDeletedFileDiff generates diff for a deleted file
@@ -232,25 +902,61 @@ func (r *Repository) getDeletedFileDiff(filename string, headTree *object.Tree)
 	if err != nil {
 		return "", err
 	}
+	mode := filemode.Regular
+	if entry, err := headTree.FindEntry(filename); err == nil {
+		mode = entry.Mode
+	}
+	return r.deletedFileDiff(filename, mode, headContent), nil
+}
 
-	return fmt.Sprintf("diff --git a/%s b/%s\ndeleted file mode 100644\nindex %s..0000000\n--- a/%s\n+++ /dev/null\n%s",
-		filename, filename, "xxxxxxx", filename, addMinusPrefix(headContent)), nil
+// deletedFileDiff generates diff for a deleted file with the given mode
+func (r *Repository) deletedFileDiff(filename string, mode filemode.FileMode, content string) string {
+	if isBinaryContent(content) {
+		return fmt.Sprintf("diff --git %s %s\ndeleted file mode %s\nindex %s..0000000\nBinary files %s and /dev/null differ",
+			oldName(filename), newName(filename), gitMode(mode), "xxxxxxx", oldName(filename))
+	}
+	return fmt.Sprintf("diff --git %s %s\ndeleted file mode %s\nindex %s..0000000\n--- %s\n+++ /dev/null\n%s",
+		oldName(filename), newName(filename), gitMode(mode), "xxxxxxx", oldName(filename), addMinusPrefix(content))
 }
 
-// generateDiff generates a unified diff between two content strings
+// generateDiff generates a unified diff between two content strings of a
+// regular file
 func (r *Repository) generateDiff(filename, oldContent, newContent string) string {
-	if oldContent == newContent {
+	return r.generateModeDiff(filename, filemode.Regular, filemode.Regular, oldContent, newContent)
+}
+
+// generateModeDiff generates a unified diff between two versions of a file.
+// A mode change, such as an executable bit flip or a file replaced by a
+// symlink, gets old mode/new mode headers like git's, and is shown even when
+// the content is unchanged.
+func (r *Repository) generateModeDiff(filename string, oldMode, newMode filemode.FileMode, oldContent, newContent string) string {
+	if oldContent == newContent && oldMode == newMode {
 		return ""
 	}
 
+	header := []string{fmt.Sprintf("diff --git %s %s", oldName(filename), newName(filename))}
+	if oldMode != newMode {
+		header = append(header,
+			fmt.Sprintf("old mode %s", gitMode(oldMode)),
+			fmt.Sprintf("new mode %s", gitMode(newMode)))
+		if oldContent == newContent {
+			return strings.Join(header, "\n")
+		}
+		header = append(header, fmt.Sprintf("index %s..%s", "xxxxxxx", "xxxxxxx"))
+	} else {
+		header = append(header, fmt.Sprintf("index %s..%s %s", "xxxxxxx", "xxxxxxx", gitMode(newMode)))
+	}
+
+	if isBinaryContent(oldContent) || isBinaryContent(newContent) {
+		return strings.Join(append(header, fmt.Sprintf("Binary files %s and %s differ", oldName(filename), newName(filename))), "\n")
+	}
+
 	oldLines := strings.Split(oldContent, "\n")
 	newLines := strings.Split(newContent, "\n")
 
-	var diffLines []string
-	diffLines = append(diffLines, fmt.Sprintf("diff --git a/%s b/%s", filename, filename))
-	diffLines = append(diffLines, fmt.Sprintf("index %s..%s 100644", "xxxxxxx", "xxxxxxx"))
-	diffLines = append(diffLines, fmt.Sprintf("--- a/%s", filename))
-	diffLines = append(diffLines, fmt.Sprintf("+++ b/%s", filename))
+	diffLines := header
+	diffLines = append(diffLines, "--- "+oldName(filename))
+	diffLines = append(diffLines, "+++ "+newName(filename))
 
 	// Simple diff implementation - for production, consider using a proper diff library
 	maxLines := len(oldLines)
@@ -280,128 +986,423 @@ func (r *Repository) generateDiff(filename, oldContent, newContent string) strin
 	return strings.Join(diffLines, "\n")
 }
 
-// ApplyIgnorePatterns filters the diff content based on .caiignore files
-func (r *Repository) ApplyIgnorePatterns(diff, basePath string) (string, error) {
-	ignorePatterns, err := r.loadIgnorePatterns(basePath)
+// Helper functions
+
+// oldName and newName return the a/ and b/ names of filename in diff headers,
+// quoted like git's when the name has special characters
+func oldName(filename string) string {
+	return diffparse.Quote("a/" + filename)
+}
+
+func newName(filename string) string {
+	return diffparse.Quote("b/" + filename)
+}
+
+// gitMode formats a file mode the way diff headers show it, e.g. 100755
+func gitMode(mode filemode.FileMode) string {
+	return fmt.Sprintf("%o", uint32(mode))
+}
+
+// addPlusPrefix adds '+' prefix to each line of content
+func addPlusPrefix(content string) string {
+	lines := strings.Split(content, "\n")
+	for i, line := range lines {
+		if i == len(lines)-1 && line == "" {
+			continue // Don't add prefix to empty last line
+		}
+		lines[i] = "+" + line
+	}
+	return strings.Join(lines, "\n")
+}
+
+// addMinusPrefix adds '-' prefix to each line of content
+func addMinusPrefix(content string) string {
+	lines := strings.Split(content, "\n")
+	for i, line := range lines {
+		if i == len(lines)-1 && line == "" {
+			continue // Don't add prefix to empty last line
+		}
+		lines[i] = "-" + line
+	}
+	return strings.Join(lines, "\n")
+}
+
+// CurrentBranch returns the short name of the checked-out branch, or an empty
+// string when HEAD is detached or the repository has no commits yet.
+func (r *Repository) CurrentBranch() (string, error) {
+	head, err := r.repo.Head()
 	if err != nil {
-		return "", fmt.Errorf("failed to load ignore patterns: %w", err)
+		if errors.Is(err, plumbing.ErrReferenceNotFound) {
+			return "", nil
+		}
+		return "", fmt.Errorf("failed to get HEAD: %w", err)
 	}
 
-	if len(ignorePatterns) == 0 {
-		return diff, nil
+	if !head.Name().IsBranch() {
+		return "", nil
 	}
 
-	// Split diff into file sections
-	sections := r.splitDiffIntoSections(diff)
-	var filteredSections []string
+	return head.Name().Short(), nil
+}
 
-	for _, section := range sections {
-		filename := r.extractFilenameFromDiff(section)
-		if filename != "" {
-			ignored := false
-			for _, pattern := range ignorePatterns {
-				if pattern.MatchesPath(filename) {
-					ignored = true
-					break
-				}
-			}
-			if !ignored {
-				filteredSections = append(filteredSections, section)
-			}
-		}
+// CommitTemplate returns the content of the commit.template file git starts
+// new commit messages from, or an empty string when none is set. The setting
+// is read with the git CLI, as it usually lives in the global git config.
+func (r *Repository) CommitTemplate() (string, error) {
+	// git config fails with exit status 1 when the key is unset
+	file, err := runGit(r.path, "config", "--path", "--get", "commit.template")
+	if err != nil || file == "" {
+		return "", nil
+	}
+	if !filepath.IsAbs(file) {
+		file = filepath.Join(r.path, file)
+	}
+
+	data, err := os.ReadFile(file) // #nosec G304 -- the file named by commit.template
+	if err != nil {
+		return "", fmt.Errorf("failed to read commit template: %w", err)
 	}
+	return string(data), nil
+}
 
-	return strings.Join(filteredSections, "\n"), nil
+// TrackedFiles returns the slash-separated paths of the files in the index
+func (r *Repository) TrackedFiles() ([]string, error) {
+	idx, err := r.repo.Storer.Index()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read index: %w", indexError(err))
+	}
+	files := make([]string, 0, len(idx.Entries))
+	for _, entry := range idx.Entries {
+		files = append(files, entry.Name)
+	}
+	return files, nil
 }
 
-// loadIgnorePatterns loads ignore patterns from .caiignore files
-func (r *Repository) loadIgnorePatterns(basePath string) ([]*gitignore.GitIgnore, error) {
-	var patterns []*gitignore.GitIgnore
+// MayCommit reports whether file, a path in the work tree, is tracked or
+// isn't ignored by git, so that it is or may end up in a commit. Files
+// outside the work tree never are; when git can't tell, it assumes so.
+func (r *Repository) MayCommit(file string) bool {
+	absPath, err := filepath.Abs(file)
+	if err != nil {
+		return true
+	}
+	rel, err := filepath.Rel(r.path, absPath)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return false
+	}
+	rel = filepath.ToSlash(rel)
 
-	// Walk up the directory tree looking for .caiignore files
-	currentPath := basePath
-	for {
-		ignoreFile := filepath.Join(currentPath, ".caiignore")
-		if _, err := os.Stat(ignoreFile); err == nil {
-			pattern, err := gitignore.CompileIgnoreFile(ignoreFile)
-			if err != nil {
-				return nil, fmt.Errorf("failed to compile ignore file %s: %w", ignoreFile, err)
-			}
-			patterns = append(patterns, pattern)
+	if idx, err := r.repo.Storer.Index(); err == nil {
+		if _, err := idx.Entry(rel); err == nil {
+			return true
 		}
+	}
+	// check-ignore fails with exit status 1 for files that aren't ignored
+	_, err = runGit(r.path, "check-ignore", "-q", "--", rel)
+	return err != nil
+}
+
+// Remote describes where a remote repository is hosted
+type Remote struct {
+	// Host is the remote host name, e.g. github.com
+	Host string
+	// Path is the repository path on the host without the .git suffix, e.g. owner/repo
+	Path string
+}
 
-		parent := filepath.Dir(currentPath)
-		if parent == currentPath {
-			break
+// ParseRemoteURL parses an HTTPS, SSH or scp-style (git@host:path) remote URL
+func ParseRemoteURL(rawURL string) (*Remote, error) {
+	rawURL = strings.TrimSpace(rawURL)
+
+	var host, repoPath string
+	switch {
+	case strings.Contains(rawURL, "://"):
+		parsed, err := url.Parse(rawURL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid remote URL %s: %w", rawURL, err)
 		}
-		currentPath = parent
+		host = parsed.Hostname()
+		repoPath = parsed.Path
+	case strings.Contains(rawURL, ":"):
+		// scp-like syntax: [user@]host:path
+		hostPart, pathPart, _ := strings.Cut(rawURL, ":")
+		if at := strings.LastIndex(hostPart, "@"); at >= 0 {
+			hostPart = hostPart[at+1:]
+		}
+		host = hostPart
+		repoPath = pathPart
+	default:
+		return nil, fmt.Errorf("unsupported remote URL: %s", rawURL)
+	}
+
+	repoPath = strings.TrimSuffix(strings.Trim(repoPath, "/"), ".git")
+	if host == "" || repoPath == "" {
+		return nil, fmt.Errorf("unsupported remote URL: %s", rawURL)
+	}
+
+	return &Remote{Host: host, Path: repoPath}, nil
+}
+
+// Remote returns the parsed URL of the named remote
+func (r *Repository) Remote(name string) (*Remote, error) {
+	remote, err := r.repo.Remote(name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get remote %s: %w", name, err)
 	}
 
-	return patterns, nil
+	urls := remote.Config().URLs
+	if len(urls) == 0 {
+		return nil, fmt.Errorf("remote %s has no URL", name)
+	}
+
+	return ParseRemoteURL(urls[0])
 }
 
-// splitDiffIntoSections splits a unified diff into individual file sections
-func (r *Repository) splitDiffIntoSections(diff string) []string {
-	lines := strings.Split(diff, "\n")
-	var sections []string
-	var currentSection []string
+// BranchChanges describes the work on the current branch relative to a base branch
+type BranchChanges struct {
+	// Diff is the unified diff between the merge base and HEAD
+	Diff string
+	// Subjects holds the subject lines of the branch commits, newest first
+	Subjects []string
+}
 
-	for _, line := range lines {
-		if strings.HasPrefix(line, "diff --git") && len(currentSection) > 0 {
-			sections = append(sections, strings.Join(currentSection, "\n"))
-			currentSection = []string{line}
-		} else {
-			currentSection = append(currentSection, line)
+// DefaultBranch returns the name of the repository's default branch, taken from
+// origin/HEAD when available and otherwise the first of main or master that exists.
+func (r *Repository) DefaultBranch() (string, error) {
+	if ref, err := r.repo.Reference(plumbing.NewRemoteHEADReferenceName("origin"), false); err == nil {
+		if target := ref.Target(); target.IsRemote() {
+			return strings.TrimPrefix(target.Short(), "origin/"), nil
 		}
 	}
 
-	if len(currentSection) > 0 {
-		sections = append(sections, strings.Join(currentSection, "\n"))
+	for _, name := range []string{"main", "master"} {
+		if _, err := r.resolveBranch(name); err == nil {
+			return name, nil
+		}
 	}
 
-	return sections
+	return "", fmt.Errorf("cannot determine default branch, please specify the base branch")
 }
 
-// extractFilenameFromDiff extracts the filename from a diff section
-func (r *Repository) extractFilenameFromDiff(diffSection string) string {
-	lines := strings.Split(diffSection, "\n")
-	for _, line := range lines {
-		if strings.HasPrefix(line, "diff --git") {
-			parts := strings.Fields(line)
-			if len(parts) >= 4 {
-				// Extract filename from "a/filename" format
-				filename := strings.TrimPrefix(parts[2], "a/")
-				return filename
-			}
+// BranchChanges returns the diff and commit subjects of HEAD since it diverged from base
+func (r *Repository) BranchChanges(base string) (*BranchChanges, error) {
+	baseHash, err := r.resolveBranch(base)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve base branch %s: %w", base, err)
+	}
+
+	head, err := r.repo.Head()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get HEAD: %w", err)
+	}
+
+	headCommit, err := r.repo.CommitObject(head.Hash())
+	if err != nil {
+		return nil, fmt.Errorf("failed to get HEAD commit: %w", err)
+	}
+
+	baseCommit, err := r.repo.CommitObject(baseHash)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get base commit: %w", err)
+	}
+
+	mergeBases, err := headCommit.MergeBase(baseCommit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find merge base: %w", err)
+	}
+	if len(mergeBases) == 0 {
+		return nil, fmt.Errorf("branch has no common history with %s", base)
+	}
+	mergeBase := mergeBases[0]
+
+	patch, err := mergeBase.Patch(headCommit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compute diff: %w", err)
+	}
+
+	changes := &BranchChanges{Diff: patch.String()}
+
+	commits, err := r.repo.Log(&git.LogOptions{From: headCommit.Hash})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read history: %w", err)
+	}
+	defer commits.Close()
+
+	err = commits.ForEach(func(c *object.Commit) error {
+		if c.Hash == mergeBase.Hash {
+			return storer.ErrStop
 		}
+		subject, _, _ := strings.Cut(strings.TrimSpace(c.Message), "\n")
+		changes.Subjects = append(changes.Subjects, subject)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read history: %w", err)
 	}
-	return ""
+
+	return changes, nil
 }
 
-// Helper functions
+// maxTopologyCount caps the ahead and behind counts of Topology, so branches
+// whose common history is far back don't walk the whole history
+const maxTopologyCount = 1000
+
+// Topology describes how the current branch relates to its upstream
+type Topology struct {
+	Branch string
+	// Upstream is the short name of the upstream branch, e.g. origin/main
+	Upstream string
+	// Ahead and Behind count the commits only on the branch and only on its
+	// upstream, up to maxTopologyCount
+	Ahead  int
+	Behind int
+	// MergeBase is the hash of the last commit the two have in common
+	MergeBase string
+}
 
-// addPlusPrefix adds '+' prefix to each line of content
-func addPlusPrefix(content string) string {
-	lines := strings.Split(content, "\n")
-	for i, line := range lines {
-		if i == len(lines)-1 && line == "" {
-			continue // Don't add prefix to empty last line
+// Topology returns the position of the current branch relative to its
+// configured upstream, or nil when HEAD is detached, the branch has no
+// upstream, or the upstream hasn't been fetched.
+func (r *Repository) Topology() (*Topology, error) {
+	branch, err := r.CurrentBranch()
+	if err != nil || branch == "" {
+		return nil, err
+	}
+
+	cfg, err := r.repo.Config()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read git config: %w", err)
+	}
+	tracking, ok := cfg.Branches[branch]
+	if !ok || tracking.Merge == "" {
+		return nil, nil
+	}
+	upstreamRef, upstream := tracking.Merge, tracking.Merge.Short()
+	if tracking.Remote != "" && tracking.Remote != "." {
+		upstreamRef = plumbing.NewRemoteReferenceName(tracking.Remote, tracking.Merge.Short())
+		upstream = tracking.Remote + "/" + tracking.Merge.Short()
+	}
+	ref, err := r.repo.Reference(upstreamRef, true)
+	if err != nil {
+		if errors.Is(err, plumbing.ErrReferenceNotFound) {
+			return nil, nil
 		}
-		lines[i] = "+" + line
+		return nil, fmt.Errorf("failed to resolve upstream %s: %w", upstream, err)
 	}
-	return strings.Join(lines, "\n")
+
+	head, err := r.repo.Head()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get HEAD: %w", err)
+	}
+	headCommit, err := r.repo.CommitObject(head.Hash())
+	if err != nil {
+		return nil, fmt.Errorf("failed to get HEAD commit: %w", err)
+	}
+	upstreamCommit, err := r.repo.CommitObject(ref.Hash())
+	if err != nil {
+		return nil, fmt.Errorf("failed to get upstream commit: %w", err)
+	}
+
+	mergeBases, err := headCommit.MergeBase(upstreamCommit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find merge base: %w", err)
+	}
+	if len(mergeBases) == 0 {
+		return nil, fmt.Errorf("branch has no common history with %s", upstream)
+	}
+	ignore := make([]plumbing.Hash, 0, len(mergeBases))
+	for _, c := range mergeBases {
+		ignore = append(ignore, c.Hash)
+	}
+
+	topology := &Topology{Branch: branch, Upstream: upstream, MergeBase: mergeBases[0].Hash.String()}
+	if topology.Ahead, err = countCommits(headCommit, ignore); err != nil {
+		return nil, err
+	}
+	if topology.Behind, err = countCommits(upstreamCommit, ignore); err != nil {
+		return nil, err
+	}
+	return topology, nil
 }
 
-// addMinusPrefix adds '-' prefix to each line of content
-func addMinusPrefix(content string) string {
-	lines := strings.Split(content, "\n")
-	for i, line := range lines {
-		if i == len(lines)-1 && line == "" {
-			continue // Don't add prefix to empty last line
+// countCommits counts the commits reachable from c without passing through
+// the ignored ones, up to maxTopologyCount
+func countCommits(c *object.Commit, ignore []plumbing.Hash) (int, error) {
+	iter := object.NewCommitIterBSF(c, nil, ignore)
+	defer iter.Close()
+
+	count := 0
+	err := iter.ForEach(func(*object.Commit) error {
+		count++
+		if count == maxTopologyCount {
+			return storer.ErrStop
 		}
-		lines[i] = "-" + line
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to read history: %w", err)
 	}
-	return strings.Join(lines, "\n")
+	return count, nil
+}
+
+// resolveBranch resolves a local branch, falling back to the origin remote branch
+func (r *Repository) resolveBranch(name string) (plumbing.Hash, error) {
+	for _, refName := range []plumbing.ReferenceName{
+		plumbing.NewBranchReferenceName(name),
+		plumbing.NewRemoteReferenceName("origin", name),
+	} {
+		if ref, err := r.repo.Reference(refName, true); err == nil {
+			return ref.Hash(), nil
+		}
+	}
+
+	hash, err := r.repo.ResolveRevision(plumbing.Revision(name))
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+	return *hash, nil
+}
+
+// StashDiff returns the diff of the latest stash entry against the commit it
+// was created on, or an empty string when there is no stash.
+func (r *Repository) StashDiff() (string, error) {
+	ref, err := r.repo.Reference(plumbing.ReferenceName("refs/stash"), true)
+	if err != nil {
+		if errors.Is(err, plumbing.ErrReferenceNotFound) {
+			return "", nil
+		}
+		return "", fmt.Errorf("failed to read stash: %w", err)
+	}
+
+	stash, err := r.repo.CommitObject(ref.Hash())
+	if err != nil {
+		return "", fmt.Errorf("failed to get stash commit: %w", err)
+	}
+
+	base, err := stash.Parent(0)
+	if err != nil {
+		return "", fmt.Errorf("failed to get stash base commit: %w", err)
+	}
+
+	patch, err := base.Patch(stash)
+	if err != nil {
+		return "", fmt.Errorf("failed to compute stash diff: %w", err)
+	}
+
+	return patch.String(), nil
+}
+
+// PopStash applies the latest stash entry to the work tree and drops it
+func (r *Repository) PopStash() error {
+	if r.readOnly {
+		return ErrReadOnly
+	}
+
+	if _, err := runGit(r.path, "stash", "pop"); err != nil {
+		return fmt.Errorf("failed to pop stash: %w", err)
+	}
+
+	return nil
 }
 
 // GetLastCommitMessage returns the message of the last commit
@@ -421,31 +1422,67 @@ func (r *Repository) GetLastCommitMessage() (string, error) {
 
 // Commit creates a new commit with the given message
 func (r *Repository) Commit(message string) error {
-	// First check if there are staged changes
-	status, err := r.workTree.Status()
-	if err != nil {
-		return fmt.Errorf("failed to get status: %w", err)
+	if r.readOnly {
+		return ErrReadOnly
+	}
+	if r.workTree == nil {
+		return git.ErrIsBareRepository
 	}
 
+	// First check if there are staged changes
 	hasStagedChanges := false
-	for _, fileStatus := range status {
-		if fileStatus.Staging != git.Unmodified {
+	var outside []string
+	if r.IndexOnly() {
+		changes, err := r.indexChanges()
+		if err != nil {
+			return err
+		}
+		for _, change := range changes {
+			hasStagedChanges = true
+			if !r.inPathspecs(change.name) {
+				outside = append(outside, change.name)
+			}
+		}
+	} else {
+		status, err := r.status()
+		if err != nil {
+			return fmt.Errorf("failed to get status: %w", err)
+		}
+		for file, fileStatus := range status {
+			if fileStatus.Staging == git.Unmodified {
+				continue
+			}
 			hasStagedChanges = true
-			break
+			if fileStatus.Staging != git.Untracked && !r.inPathspecs(file) {
+				outside = append(outside, file)
+			}
 		}
 	}
 
-	if !hasStagedChanges {
+	// The index is committed as a whole, so a path-limited commit must not
+	// carry staged changes from elsewhere
+	if len(outside) > 0 {
+		sort.Strings(outside)
+		return fmt.Errorf("staged changes outside the selected paths: %s", strings.Join(outside, ", "))
+	}
+
+	if !hasStagedChanges && !r.allowEmpty {
 		return fmt.Errorf("no staged changes to commit")
 	}
 
+	author := &object.Signature{
+		Name:  getGitConfigValue("user.name"),
+		Email: getGitConfigValue("user.email"),
+		When:  time.Now(),
+	}
+	if r.authorName != "" {
+		author.Name, author.Email = r.authorName, r.authorEmail
+	}
+
 	// Create the commit
-	_, err = r.workTree.Commit(message, &git.CommitOptions{
-		Author: &object.Signature{
-			Name:  getGitConfigValue("user.name"),
-			Email: getGitConfigValue("user.email"),
-			When:  time.Now(),
-		},
+	_, err := r.workTree.Commit(message, &git.CommitOptions{
+		Author:            author,
+		AllowEmptyCommits: r.allowEmpty,
 	})
 	if err != nil {
 		return fmt.Errorf("failed to create commit: %w", err)
@@ -454,14 +1491,25 @@ func (r *Repository) Commit(message string) error {
 	return nil
 }
 
-// StageAll stages all changes in the working directory
+// StageAll stages all changes in the working directory, or only those
+// matching the pathspecs set by SetPathspecs
 func (r *Repository) StageAll() error {
-	status, err := r.workTree.Status()
+	if r.readOnly {
+		return ErrReadOnly
+	}
+	if r.IndexOnly() {
+		return ErrIndexOnly
+	}
+
+	status, err := r.status()
 	if err != nil {
 		return fmt.Errorf("failed to get status: %w", err)
 	}
 
 	for file := range status {
+		if !r.inPathspecs(file) {
+			continue
+		}
 		_, err = r.workTree.Add(file)
 		if err != nil {
 			return fmt.Errorf("failed to stage file %s: %w", file, err)
@@ -3,12 +3,17 @@ package git
 import (
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
 	"github.com/go-git/go-git/v5/plumbing/object"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+
+	"github.com/nseba/commit-ai/internal/diffparse"
 )
 
 func createTestRepo(t *testing.T) (string, *git.Repository) {
@@ -63,6 +68,50 @@ func TestNewRepository_NonGitDirectory(t *testing.T) {
 	assert.Contains(t, err.Error(), "failed to open git repository")
 }
 
+func TestNewRepository_Subdirectory(t *testing.T) {
+	tempDir, _ := createTestRepo(t)
+	subDir := filepath.Join(tempDir, "pkg", "sub")
+	require.NoError(t, os.MkdirAll(subDir, 0o755))
+
+	repo, err := NewRepository(subDir)
+	require.NoError(t, err)
+	assert.Equal(t, tempDir, repo.Path())
+}
+
+func TestGetDiff_Scope(t *testing.T) {
+	tempDir, repo := createTestRepo(t)
+	commitFile(t, repo, tempDir, "README.md", "readme\n")
+
+	createTestFile(t, tempDir, "README.md", "readme\nchanged\n")
+	createTestFile(t, tempDir, "pkg/sub/file.go", "package sub\n")
+
+	worktree, err := repo.Worktree()
+	require.NoError(t, err)
+	_, err = worktree.Add("README.md")
+	require.NoError(t, err)
+	_, err = worktree.Add("pkg/sub/file.go")
+	require.NoError(t, err)
+
+	gitRepo, err := NewRepository(tempDir)
+	require.NoError(t, err)
+	require.NoError(t, gitRepo.SetScope(filepath.Join(tempDir, "pkg")))
+
+	diff, err := gitRepo.GetDiff()
+	require.NoError(t, err)
+	assert.Contains(t, diff, "pkg/sub/file.go")
+	assert.NotContains(t, diff, "README.md")
+
+	assert.Error(t, gitRepo.SetScope(t.TempDir()))
+
+	// "." is the current directory, not the top of the work tree
+	t.Chdir(filepath.Join(tempDir, "pkg", "sub"))
+	require.NoError(t, gitRepo.SetScope("."))
+	diff, err = gitRepo.GetDiff()
+	require.NoError(t, err)
+	assert.Contains(t, diff, "pkg/sub/file.go")
+	assert.NotContains(t, diff, "README.md")
+}
+
 func TestGetDiff_EmptyRepository(t *testing.T) {
 	tempDir, gitRepo := createTestRepo(t)
 
@@ -106,6 +155,95 @@ func TestGetDiff_WithCommits(t *testing.T) {
 	assert.Contains(t, diff, "+Hello, Universe!")
 }
 
+func TestGetDiff_LFSPointer(t *testing.T) {
+	tempDir, gitRepo := createTestRepo(t)
+
+	commitFile(t, gitRepo, tempDir, ".gitattributes", "*.bin filter=lfs diff=lfs merge=lfs -text\n")
+	pointer := "version https://git-lfs.github.com/spec/v1\n" +
+		"oid sha256:4d7a214614ab2935c943f9e0ff69d22eadbb8f32b1258daaa5e2ca24d17e2393\nsize 1024\n"
+	commitFile(t, gitRepo, tempDir, "assets/model.bin", pointer)
+
+	// The checked out (smudged) object is hashed instead of diffed
+	createTestFile(t, tempDir, "assets/model.bin", "hello")
+
+	repo, err := NewRepository(tempDir)
+	require.NoError(t, err)
+
+	diff, err := repo.GetDiff()
+	require.NoError(t, err)
+
+	assert.Contains(t, diff, "-oid sha256:4d7a214614ab2935c943f9e0ff69d22eadbb8f32b1258daaa5e2ca24d17e2393")
+	assert.Contains(t, diff, "+oid sha256:2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824")
+	assert.Contains(t, diff, "+size 5")
+	assert.NotContains(t, diff, "hello")
+}
+
+func TestGetDiff_Encodings(t *testing.T) {
+	tempDir, gitRepo := createTestRepo(t)
+
+	commitFile(t, gitRepo, tempDir, "legacy.txt", "caf\xe9\n")
+	commitFile(t, gitRepo, tempDir, "image.png", "\x89PNG\x00\x01")
+	commitFile(t, gitRepo, tempDir, "notes.txt", "\xff\xfeh\x00")
+
+	createTestFile(t, tempDir, "legacy.txt", "caf\xe9 cr\xe8me\n")
+	createTestFile(t, tempDir, "image.png", "\x89PNG\x00\x02\x03")
+	createTestFile(t, tempDir, "notes.txt", "\xff\xfeh\x00i\x00")
+
+	repo, err := NewRepository(tempDir)
+	require.NoError(t, err)
+
+	diff, err := repo.GetDiff()
+	require.NoError(t, err)
+
+	assert.Contains(t, diff, "-café")
+	assert.Contains(t, diff, "+café crème")
+	assert.Contains(t, diff, "Binary files a/image.png and b/image.png differ")
+	assert.NotContains(t, diff, "PNG")
+	assert.Contains(t, diff, "-h\n+hi")
+}
+
+func TestGetDiff_ModeChange(t *testing.T) {
+	tempDir, gitRepo := createTestRepo(t)
+
+	commitFile(t, gitRepo, tempDir, "build.sh", "#!/bin/sh\nmake\n")
+	require.NoError(t, os.Chmod(filepath.Join(tempDir, "build.sh"), 0o755))
+
+	repo, err := NewRepository(tempDir)
+	require.NoError(t, err)
+
+	diff, err := repo.GetDiff()
+	require.NoError(t, err)
+	assert.Equal(t, "diff --git a/build.sh b/build.sh\nold mode 100644\nnew mode 100755", diff)
+}
+
+func TestGetDiff_Symlink(t *testing.T) {
+	tempDir, gitRepo := createTestRepo(t)
+
+	createTestFile(t, tempDir, "config/prod.toml", "prod")
+	createTestFile(t, tempDir, "config/dev.toml", "dev")
+	require.NoError(t, os.Symlink("config/prod.toml", filepath.Join(tempDir, "current.toml")))
+	worktree, err := gitRepo.Worktree()
+	require.NoError(t, err)
+	require.NoError(t, worktree.AddGlob("."))
+	_, err = worktree.Commit("Initial commit", &git.CommitOptions{
+		Author: &object.Signature{Name: "Test User", Email: "test@example.com"},
+	})
+	require.NoError(t, err)
+
+	// Retarget the link; its content is the target, not the file it points to
+	require.NoError(t, os.Remove(filepath.Join(tempDir, "current.toml")))
+	require.NoError(t, os.Symlink("config/dev.toml", filepath.Join(tempDir, "current.toml")))
+
+	repo, err := NewRepository(tempDir)
+	require.NoError(t, err)
+
+	diff, err := repo.GetDiff()
+	require.NoError(t, err)
+	assert.Contains(t, diff, "index xxxxxxx..xxxxxxx 120000")
+	assert.Contains(t, diff, "-config/prod.toml\n+config/dev.toml")
+	assert.NotContains(t, diff, "-prod")
+}
+
 func TestGetDiff_StagedChanges(t *testing.T) {
 	tempDir, gitRepo := createTestRepo(t)
 
@@ -186,60 +324,39 @@ func TestApplyIgnorePatterns_WithIgnoreFile(t *testing.T) {
 	assert.Equal(t, normalDiff, filteredDiff)
 }
 
-func TestSplitDiffIntoSections(t *testing.T) {
-	repo := &Repository{}
+func TestApplyIgnorePatterns_QuotedAndSpacedNames(t *testing.T) {
+	tempDir, _ := createTestRepo(t)
+	createTestFile(t, tempDir, ".caiignore", "*.log\nmy docs/\n")
 
-	diff := `diff --git a/file1.txt b/file1.txt
-index 123..456 100644
---- a/file1.txt
-+++ b/file1.txt
-+line1
-diff --git a/file2.txt b/file2.txt
-index 789..abc 100644
---- a/file2.txt
-+++ b/file2.txt
-+line2`
+	repo, err := NewRepository(tempDir)
+	require.NoError(t, err)
 
-	sections := repo.splitDiffIntoSections(diff)
+	kept := "diff --git a/notes b/x.txt b/notes b/x.txt\n+kept"
+	diff := strings.Join([]string{
+		"diff --git a/my docs/a.txt b/my docs/a.txt\n+ignored",
+		"diff --git \"a/debug\\tday.log\" \"b/debug\\tday.log\"\n+ignored",
+		"diff --git a/old.txt b/run 1.log\nsimilarity index 90%\nrename from old.txt\nrename to run 1.log",
+		kept,
+	}, "\n")
+
+	filteredDiff, err := repo.ApplyIgnorePatterns(diff, tempDir)
+	require.NoError(t, err)
 
-	assert.Len(t, sections, 2)
-	assert.Contains(t, sections[0], "file1.txt")
-	assert.Contains(t, sections[0], "+line1")
-	assert.Contains(t, sections[1], "file2.txt")
-	assert.Contains(t, sections[1], "+line2")
+	assert.Equal(t, kept, filteredDiff)
 }
 
-func TestExtractFilenameFromDiff(t *testing.T) {
+func TestGenerateDiff_QuotesSpecialNames(t *testing.T) {
 	repo := &Repository{}
 
-	testCases := []struct {
-		name     string
-		diff     string
-		expected string
-	}{
-		{
-			name:     "normal file",
-			diff:     "diff --git a/test.txt b/test.txt\nindex 123..456",
-			expected: "test.txt",
-		},
-		{
-			name:     "file in subdirectory",
-			diff:     "diff --git a/src/main.go b/src/main.go\nindex 123..456",
-			expected: "src/main.go",
-		},
-		{
-			name:     "no diff header",
-			diff:     "some random content",
-			expected: "",
-		},
-	}
+	result := repo.generateDiff("tab\there.txt", "old", "new")
+	assert.Contains(t, result, `diff --git "a/tab\there.txt" "b/tab\there.txt"`)
 
-	for _, tc := range testCases {
-		t.Run(tc.name, func(t *testing.T) {
-			result := repo.extractFilenameFromDiff(tc.diff)
-			assert.Equal(t, tc.expected, result)
-		})
-	}
+	_, files := diffparse.Split(result)
+	require.Len(t, files, 1)
+	assert.Equal(t, "tab\there.txt", files[0].Path())
+
+	result = repo.getNewFileDiff("with space.txt", "x")
+	assert.Contains(t, result, "diff --git a/with space.txt b/with space.txt")
 }
 
 func TestAddPlusPrefix(t *testing.T) {
@@ -294,3 +411,432 @@ func TestGetNewFileDiff(t *testing.T) {
 	assert.Contains(t, result, "+++ b/new.txt")
 	assert.Contains(t, result, "+new file content")
 }
+
+func TestReadOnly_BlocksWrites(t *testing.T) {
+	tempDir, gitRepo := createTestRepo(t)
+	commitFile(t, gitRepo, tempDir, "test.txt", "Hello")
+	createTestFile(t, tempDir, "test.txt", "Hello, World!")
+
+	repo, err := NewRepository(tempDir)
+	require.NoError(t, err)
+	repo.SetReadOnly(true)
+
+	err = repo.StageAll()
+	assert.ErrorIs(t, err, ErrReadOnly)
+
+	err = repo.Commit("feat: should not commit")
+	assert.ErrorIs(t, err, ErrReadOnly)
+
+	// Reading the diff still works
+	diff, err := repo.GetDiff()
+	require.NoError(t, err)
+	assert.Contains(t, diff, "Hello, World!")
+}
+
+func TestCurrentBranch(t *testing.T) {
+	tempDir, gitRepo := createTestRepo(t)
+
+	repo, err := NewRepository(tempDir)
+	require.NoError(t, err)
+
+	// No commits yet
+	branch, err := repo.CurrentBranch()
+	require.NoError(t, err)
+	assert.Equal(t, "", branch)
+
+	commitFile(t, gitRepo, tempDir, "test.txt", "Hello")
+
+	branch, err = repo.CurrentBranch()
+	require.NoError(t, err)
+	assert.Equal(t, "master", branch)
+}
+
+func TestCommitTemplate(t *testing.T) {
+	t.Setenv("GIT_CONFIG_GLOBAL", filepath.Join(t.TempDir(), "gitconfig"))
+	t.Setenv("GIT_CONFIG_NOSYSTEM", "1")
+	tempDir, _ := createTestRepo(t)
+
+	repo, err := NewRepository(tempDir)
+	require.NoError(t, err)
+
+	template, err := repo.CommitTemplate()
+	require.NoError(t, err)
+	assert.Empty(t, template)
+
+	// A relative path is relative to the work tree
+	createTestFile(t, tempDir, ".gitmessage", "Why:\n\n# Explain the change\n")
+	_, err = runGit(tempDir, "config", "commit.template", ".gitmessage")
+	require.NoError(t, err)
+	template, err = repo.CommitTemplate()
+	require.NoError(t, err)
+	assert.Equal(t, "Why:\n\n# Explain the change\n", template)
+
+	_, err = runGit(tempDir, "config", "commit.template", "missing")
+	require.NoError(t, err)
+	_, err = repo.CommitTemplate()
+	assert.ErrorContains(t, err, "failed to read commit template")
+}
+
+func TestTrackedFiles(t *testing.T) {
+	tempDir, gitRepo := createTestRepo(t)
+	commitFile(t, gitRepo, tempDir, "main.go", "package main")
+	commitFile(t, gitRepo, tempDir, "README.md", "# Test")
+	createTestFile(t, tempDir, "untracked.txt", "not added")
+
+	repo, err := NewRepository(tempDir)
+	require.NoError(t, err)
+	files, err := repo.TrackedFiles()
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"main.go", "README.md"}, files)
+}
+
+func TestMayCommit(t *testing.T) {
+	tempDir, gitRepo := createTestRepo(t)
+	commitFile(t, gitRepo, tempDir, ".gitignore", "*.toml\n")
+	createTestFile(t, tempDir, "tracked.toml", "CAI_API_TOKEN = \"x\"\n")
+	worktree, err := gitRepo.Worktree()
+	require.NoError(t, err)
+	_, err = worktree.Add("tracked.toml")
+	require.NoError(t, err)
+	createTestFile(t, tempDir, "ignored.toml", "")
+	createTestFile(t, tempDir, ".commitai", "")
+
+	repo, err := NewRepository(tempDir)
+	require.NoError(t, err)
+
+	assert.True(t, repo.MayCommit(filepath.Join(tempDir, "tracked.toml")), "tracked despite the pattern")
+	assert.True(t, repo.MayCommit(filepath.Join(tempDir, ".commitai")), "untracked but not ignored")
+	assert.False(t, repo.MayCommit(filepath.Join(tempDir, "ignored.toml")))
+	assert.False(t, repo.MayCommit(filepath.Join(t.TempDir(), "config.toml")), "outside the work tree")
+}
+
+func TestParseRemoteURL(t *testing.T) {
+	tests := []struct {
+		url  string
+		host string
+		path string
+	}{
+		{"git@github.com:nseba/commit-ai.git", "github.com", "nseba/commit-ai"},
+		{"https://github.com/nseba/commit-ai.git", "github.com", "nseba/commit-ai"},
+		{"https://github.com/nseba/commit-ai", "github.com", "nseba/commit-ai"},
+		{"ssh://git@gitlab.example.com:2222/group/sub/project.git", "gitlab.example.com", "group/sub/project"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			remote, err := ParseRemoteURL(tt.url)
+			require.NoError(t, err)
+			assert.Equal(t, tt.host, remote.Host)
+			assert.Equal(t, tt.path, remote.Path)
+		})
+	}
+
+	_, err := ParseRemoteURL("/local/path/repo")
+	assert.Error(t, err)
+}
+
+func TestBranchChanges(t *testing.T) {
+	tempDir, gitRepo := createTestRepo(t)
+	commitFile(t, gitRepo, tempDir, "base.txt", "base\n")
+
+	worktree, err := gitRepo.Worktree()
+	require.NoError(t, err)
+	err = worktree.Checkout(&git.CheckoutOptions{Branch: plumbing.NewBranchReferenceName("feature"), Create: true})
+	require.NoError(t, err)
+
+	createTestFile(t, tempDir, "feature.txt", "new feature\n")
+	_, err = worktree.Add("feature.txt")
+	require.NoError(t, err)
+	_, err = worktree.Commit("feat: add feature file", &git.CommitOptions{
+		Author: &object.Signature{Name: "Test User", Email: "test@example.com"},
+	})
+	require.NoError(t, err)
+
+	repo, err := NewRepository(tempDir)
+	require.NoError(t, err)
+
+	base, err := repo.DefaultBranch()
+	require.NoError(t, err)
+	assert.Equal(t, "master", base)
+
+	changes, err := repo.BranchChanges(base)
+	require.NoError(t, err)
+	assert.Contains(t, changes.Diff, "feature.txt")
+	assert.Contains(t, changes.Diff, "+new feature")
+	assert.NotContains(t, changes.Diff, "base.txt")
+	assert.Equal(t, []string{"feat: add feature file"}, changes.Subjects)
+}
+
+func TestTopology(t *testing.T) {
+	tempDir, gitRepo := createTestRepo(t)
+	commitFile(t, gitRepo, tempDir, "base.txt", "base\n")
+	head, err := gitRepo.Head()
+	require.NoError(t, err)
+	base := head.Hash()
+
+	repo, err := NewRepository(tempDir)
+	require.NoError(t, err)
+	topology, err := repo.Topology()
+	require.NoError(t, err)
+	assert.Nil(t, topology, "a branch without upstream has no topology")
+
+	// origin/master gains one commit, the local master two others
+	worktree, err := gitRepo.Worktree()
+	require.NoError(t, err)
+	err = worktree.Checkout(&git.CheckoutOptions{Branch: plumbing.NewBranchReferenceName("upstream"), Create: true})
+	require.NoError(t, err)
+	commitFile(t, gitRepo, tempDir, "upstream.txt", "upstream\n")
+	head, err = gitRepo.Head()
+	require.NoError(t, err)
+	err = gitRepo.Storer.SetReference(plumbing.NewHashReference(plumbing.NewRemoteReferenceName("origin", "master"), head.Hash()))
+	require.NoError(t, err)
+
+	err = worktree.Checkout(&git.CheckoutOptions{Branch: plumbing.NewBranchReferenceName("master")})
+	require.NoError(t, err)
+	commitFile(t, gitRepo, tempDir, "one.txt", "one\n")
+	commitFile(t, gitRepo, tempDir, "two.txt", "two\n")
+
+	cfg, err := gitRepo.Config()
+	require.NoError(t, err)
+	cfg.Branches["master"] = &config.Branch{Name: "master", Remote: "origin", Merge: plumbing.NewBranchReferenceName("master")}
+	require.NoError(t, gitRepo.SetConfig(cfg))
+
+	repo, err = NewRepository(tempDir)
+	require.NoError(t, err)
+	topology, err = repo.Topology()
+	require.NoError(t, err)
+	require.NotNil(t, topology)
+	assert.Equal(t, &Topology{Branch: "master", Upstream: "origin/master", Ahead: 2, Behind: 1, MergeBase: base.String()}, topology)
+}
+
+func TestStashDiff(t *testing.T) {
+	tempDir, gitRepo := createTestRepo(t)
+	commitFile(t, gitRepo, tempDir, "test.txt", "Hello\n")
+
+	repo, err := NewRepository(tempDir)
+	require.NoError(t, err)
+
+	// No stash yet
+	diff, err := repo.StashDiff()
+	require.NoError(t, err)
+	assert.Empty(t, diff)
+
+	// Build a stash commit by hand: its tree holds the stashed work tree and
+	// its first parent is the commit the stash was created on
+	head, err := gitRepo.Head()
+	require.NoError(t, err)
+
+	createTestFile(t, tempDir, "test.txt", "Hello, Stash!\n")
+	worktree, err := gitRepo.Worktree()
+	require.NoError(t, err)
+	_, err = worktree.Add("test.txt")
+	require.NoError(t, err)
+	stashHash, err := worktree.Commit("WIP on master", &git.CommitOptions{
+		Author:  &object.Signature{Name: "Test User", Email: "test@example.com"},
+		Parents: []plumbing.Hash{head.Hash()},
+	})
+	require.NoError(t, err)
+	require.NoError(t, gitRepo.Storer.SetReference(plumbing.NewHashReference("refs/stash", stashHash)))
+
+	diff, err = repo.StashDiff()
+	require.NoError(t, err)
+	assert.Contains(t, diff, "-Hello")
+	assert.Contains(t, diff, "+Hello, Stash!")
+}
+
+func TestPopStash(t *testing.T) {
+	tempDir, _ := createTestRepo(t)
+
+	repo, err := NewRepository(tempDir)
+	require.NoError(t, err)
+
+	var gotArgs []string
+	original := runGit
+	runGit = func(dir string, args ...string) (string, error) {
+		assert.Equal(t, repo.Path(), dir)
+		gotArgs = args
+		return "", nil
+	}
+	t.Cleanup(func() { runGit = original })
+
+	require.NoError(t, repo.PopStash())
+	assert.Equal(t, []string{"stash", "pop"}, gotArgs)
+
+	repo.SetReadOnly(true)
+	assert.ErrorIs(t, repo.PopStash(), ErrReadOnly)
+}
+
+func TestCommit_AllowEmpty(t *testing.T) {
+	tempDir, gitRepo := createTestRepo(t)
+	commitFile(t, gitRepo, tempDir, "test.txt", "Hello")
+
+	repo, err := NewRepository(tempDir)
+	require.NoError(t, err)
+
+	err = repo.Commit("chore: empty")
+	assert.Error(t, err)
+
+	repo.SetAllowEmpty(true)
+	require.NoError(t, repo.Commit("chore: empty"))
+
+	message, err := repo.GetLastCommitMessage()
+	require.NoError(t, err)
+	assert.Equal(t, "chore: empty", message)
+}
+
+func TestCommit_Author(t *testing.T) {
+	tempDir, gitRepo := createTestRepo(t)
+	commitFile(t, gitRepo, tempDir, "test.txt", "Hello")
+
+	repo, err := NewRepository(tempDir)
+	require.NoError(t, err)
+	repo.SetAllowEmpty(true)
+	repo.SetAuthor("deps-bot", "deps-bot@example.com")
+	require.NoError(t, repo.Commit("chore: empty"))
+
+	head, err := gitRepo.Head()
+	require.NoError(t, err)
+	commit, err := gitRepo.CommitObject(head.Hash())
+	require.NoError(t, err)
+	assert.Equal(t, "deps-bot", commit.Author.Name)
+	assert.Equal(t, "deps-bot@example.com", commit.Author.Email)
+}
+
+func TestPathspecs(t *testing.T) {
+	tempDir, gitRepo := createTestRepo(t)
+	commitFile(t, gitRepo, tempDir, "README.md", "readme\n")
+
+	createTestFile(t, tempDir, "README.md", "readme\nchanged\n")
+	createTestFile(t, tempDir, "internal/config/config.go", "package config\n")
+	createTestFile(t, tempDir, "docs/guide.md", "guide\n")
+
+	repo, err := NewRepository(tempDir)
+	require.NoError(t, err)
+	require.NoError(t, repo.SetPathspecs([]string{
+		filepath.Join(tempDir, "internal", "config"),
+		filepath.Join(tempDir, "docs", "*.md"),
+	}))
+
+	require.NoError(t, repo.StageAll())
+
+	diff, err := repo.GetDiff()
+	require.NoError(t, err)
+	assert.Contains(t, diff, "internal/config/config.go")
+	assert.Contains(t, diff, "docs/guide.md")
+	assert.NotContains(t, diff, "README.md")
+
+	status, err := repo.workTree.Status()
+	require.NoError(t, err)
+	assert.Equal(t, git.Unmodified, status.File("README.md").Staging)
+
+	// Staged changes outside the pathspecs block a path-limited commit
+	_, err = repo.workTree.Add("README.md")
+	require.NoError(t, err)
+	err = repo.Commit("feat: add config")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "README.md")
+
+	assert.Error(t, repo.SetPathspecs([]string{t.TempDir()}))
+}
+
+func TestExcludes(t *testing.T) {
+	tempDir, gitRepo := createTestRepo(t)
+	commitFile(t, gitRepo, tempDir, "main.go", "package main\n")
+
+	createTestFile(t, tempDir, "main.go", "package main\n\nfunc main() {}\n")
+	createTestFile(t, tempDir, "vendor/lib/lib.go", "package lib\n")
+
+	repo, err := NewRepository(tempDir)
+	require.NoError(t, err)
+	require.NoError(t, repo.SetExcludes([]string{filepath.Join(tempDir, "vendor")}))
+
+	diff, err := repo.GetDiff()
+	require.NoError(t, err)
+	assert.Contains(t, diff, "main.go")
+	assert.NotContains(t, diff, "vendor/lib/lib.go")
+}
+
+func TestRangeDiff(t *testing.T) {
+	tempDir, gitRepo := createTestRepo(t)
+	commitFile(t, gitRepo, tempDir, "test.txt", "Hello\n")
+	head, err := gitRepo.Head()
+	require.NoError(t, err)
+	commitFile(t, gitRepo, tempDir, "test.txt", "Hello\nWorld\n")
+
+	repo, err := NewRepository(tempDir)
+	require.NoError(t, err)
+
+	diff, err := repo.RangeDiff(head.Hash().String(), "HEAD")
+	require.NoError(t, err)
+	assert.Contains(t, diff, "+World")
+
+	_, err = repo.RangeDiff("does-not-exist", "HEAD")
+	assert.Error(t, err)
+}
+
+func TestGitDir(t *testing.T) {
+	tempDir, _ := createTestRepo(t)
+
+	repo, err := NewRepository(tempDir)
+	require.NoError(t, err)
+	dir, err := repo.GitDir()
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(tempDir, ".git"), dir)
+
+	// A linked work tree's .git file points to its git directory
+	linked := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(linked, ".git"), []byte("gitdir: "+filepath.Join(tempDir, ".git")+"\n"), 0o600))
+	repo.path = linked
+	dir, err = repo.GitDir()
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(tempDir, ".git"), dir)
+}
+
+func TestHooksDir(t *testing.T) {
+	t.Setenv("GIT_CONFIG_GLOBAL", filepath.Join(t.TempDir(), "gitconfig"))
+	t.Setenv("GIT_CONFIG_NOSYSTEM", "1")
+	tempDir, gitRepo := createTestRepo(t)
+	commitFile(t, gitRepo, tempDir, "README.md", "readme\n")
+
+	repo, err := NewRepository(tempDir)
+	require.NoError(t, err)
+	dir, err := repo.HooksDir()
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(tempDir, ".git", "hooks"), dir)
+
+	// Linked worktrees share the hooks of the main repository
+	linked := filepath.Join(t.TempDir(), "linked")
+	_, err = runGit(tempDir, "worktree", "add", "-b", "linked", linked)
+	require.NoError(t, err)
+	linkedRepo, err := NewRepository(linked)
+	require.NoError(t, err)
+	dir, err = linkedRepo.HooksDir()
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(tempDir, ".git", "hooks"), dir)
+
+	// core.hooksPath is relative to the top of the work tree running the hook
+	_, err = runGit(tempDir, "config", "core.hooksPath", ".githooks")
+	require.NoError(t, err)
+	dir, err = repo.HooksDir()
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(tempDir, ".githooks"), dir)
+	dir, err = linkedRepo.HooksDir()
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(linked, ".githooks"), dir)
+}
+
+func TestEditor(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("XDG_CONFIG_HOME", "")
+	tempDir, gitRepo := createTestRepo(t)
+
+	repo, err := NewRepository(tempDir)
+	require.NoError(t, err)
+	assert.Equal(t, "", repo.Editor())
+
+	cfg, err := gitRepo.Config()
+	require.NoError(t, err)
+	cfg.Raw.Section("core").SetOption("editor", "code --wait")
+	require.NoError(t, gitRepo.SetConfig(cfg))
+	assert.Equal(t, "code --wait", repo.Editor())
+}
@@ -0,0 +1,114 @@
+package git
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-git/go-git/v5"
+	formatconfig "github.com/go-git/go-git/v5/plumbing/format/config"
+	"github.com/go-git/go-git/v5/plumbing/format/gitignore"
+	"github.com/go-git/go-git/v5/plumbing/format/index"
+)
+
+// sparsePatterns holds the sparse-checkout patterns of the work tree
+type sparsePatterns struct {
+	// matcher is nil when the work tree isn't a sparse checkout
+	matcher gitignore.Matcher
+}
+
+// includes reports whether the work tree relative file is inside the sparse
+// checkout
+func (s *sparsePatterns) includes(file string) bool {
+	// A matching pattern "excludes" the file from the sparse-checkout
+	// file's point of view, which means git checks it out
+	return s.matcher == nil || s.matcher.Match(strings.Split(file, "/"), false)
+}
+
+// sparseCheckout returns the sparse-checkout patterns of the work tree,
+// loading them on first use
+func (r *Repository) sparseCheckout() *sparsePatterns {
+	if r.sparse != nil {
+		return r.sparse
+	}
+	r.sparse = &sparsePatterns{}
+
+	gitDir, err := r.GitDir()
+	if err != nil {
+		return r.sparse
+	}
+	data, err := os.ReadFile(filepath.Join(gitDir, "info", "sparse-checkout")) // #nosec G304 -- file of the opened repository
+	if err != nil {
+		return r.sparse
+	}
+	if !r.sparseCheckoutEnabled(gitDir) {
+		return r.sparse
+	}
+
+	var patterns []gitignore.Pattern
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimRight(line, "\r")
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		patterns = append(patterns, gitignore.ParsePattern(line, nil))
+	}
+	r.sparse.matcher = gitignore.NewMatcher(patterns)
+	return r.sparse
+}
+
+// sparseCheckoutEnabled reports whether core.sparseCheckout is set, in the
+// repository config or in the config.worktree file of the git directory,
+// where git sparse-checkout puts it and which go-git doesn't read
+func (r *Repository) sparseCheckoutEnabled(gitDir string) bool {
+	value := ""
+	if cfg, err := r.repo.Config(); err == nil {
+		value = cfg.Raw.Section("core").Option("sparseCheckout")
+	}
+	if f, err := os.Open(filepath.Join(gitDir, "config.worktree")); err == nil { // #nosec G304 -- file of the opened repository
+		defer f.Close()
+		worktreeConfig := formatconfig.New()
+		if err := formatconfig.NewDecoder(f).Decode(worktreeConfig); err == nil {
+			if core := worktreeConfig.Section("core"); core.HasOption("sparseCheckout") {
+				value = core.Option("sparseCheckout")
+			}
+		}
+	}
+
+	switch strings.ToLower(value) {
+	case "true", "yes", "on", "1":
+		return true
+	}
+	return false
+}
+
+// status returns the status of the work tree as git reports it. go-git
+// already leaves out index entries with the skip-worktree bit, which sparse
+// checkouts set outside their patterns and whose work tree files git neither
+// reads nor writes; untracked files outside the sparse-checkout patterns,
+// which git add refuses, are dropped too.
+func (r *Repository) status() (git.Status, error) {
+	status, err := r.workTree.Status()
+	if err != nil {
+		return nil, indexError(err)
+	}
+
+	sparse := r.sparseCheckout()
+	for file, fileStatus := range status {
+		if fileStatus.Worktree == git.Untracked && !sparse.includes(file) {
+			delete(status, file)
+		}
+	}
+	return status, nil
+}
+
+// indexError explains the index extensions go-git can't read: a sparse index
+// and a split index
+func indexError(err error) error {
+	if errors.Is(err, index.ErrUnknownExtension) {
+		return fmt.Errorf(`%w: sparse and split indexes are not supported; convert the index with "git sparse-checkout reapply --no-sparse-index" or "git update-index --no-split-index"`, err)
+	}
+	return err
+}
@@ -0,0 +1,52 @@
+package git
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSparseCheckout(t *testing.T) {
+	t.Setenv("GIT_CONFIG_GLOBAL", filepath.Join(t.TempDir(), "gitconfig"))
+	t.Setenv("GIT_CONFIG_NOSYSTEM", "1")
+	tempDir, gitRepo := createTestRepo(t)
+	commitFile(t, gitRepo, tempDir, "app/main.go", "package main\n")
+	commitFile(t, gitRepo, tempDir, "docs/guide.md", "guide\n")
+	commitFile(t, gitRepo, tempDir, "README.md", "readme\n")
+
+	// docs/ is left out of the work tree with the skip-worktree bit set
+	_, err := runGit(tempDir, "sparse-checkout", "set", "app")
+	require.NoError(t, err)
+	_, err = os.Stat(filepath.Join(tempDir, "docs", "guide.md"))
+	require.True(t, os.IsNotExist(err))
+
+	createTestFile(t, tempDir, "app/main.go", "package main\n\nfunc main() {}\n")
+	createTestFile(t, tempDir, "app/new.go", "package main\n")
+	createTestFile(t, tempDir, "docs/draft.md", "outside the sparse checkout\n")
+
+	repo, err := NewRepository(tempDir)
+	require.NoError(t, err)
+
+	diff, err := repo.WorkingTreeDiff()
+	require.NoError(t, err)
+	assert.Contains(t, diff, "+func main() {}")
+	assert.Contains(t, diff, "app/new.go")
+	assert.NotContains(t, diff, "docs/")
+
+	require.NoError(t, repo.StageAll())
+	status, err := repo.workTree.Status()
+	require.NoError(t, err)
+	assert.Equal(t, git.Added, status.File("app/new.go").Staging)
+	assert.Equal(t, git.Untracked, status.File("docs/draft.md").Staging, "untracked files outside the sparse checkout aren't staged")
+	assert.NotContains(t, status, "docs/guide.md", "skip-worktree files aren't staged as deleted")
+
+	// go-git can't read a sparse index
+	_, err = runGit(tempDir, "sparse-checkout", "set", "--sparse-index", "app")
+	require.NoError(t, err)
+	_, err = repo.GetDiff()
+	assert.ErrorContains(t, err, "--no-sparse-index")
+}
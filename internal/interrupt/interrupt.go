@@ -0,0 +1,146 @@
+// Package interrupt handles SIGINT and SIGTERM. A signal cancels Context,
+// waits for critical sections such as writing a commit to finish, runs the
+// registered cleanup functions and exits, so an interrupted run leaves no
+// temporary files, lock files or terminal settings behind.
+package interrupt
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"slices"
+	"sync"
+	"syscall"
+)
+
+var (
+	ctx, cancel = context.WithCancel(context.Background())
+
+	mu sync.Mutex
+	// done is signalled when a critical section finishes
+	done = sync.NewCond(&mu)
+	// active counts the critical sections in progress
+	active int
+	// hooks are the cleanup functions in registration order
+	hooks  []hook
+	nextID int
+	// ignoring counts the IgnoreInterrupts calls in progress
+	ignoring int
+
+	// exit ends the process; tests replace it
+	exit = os.Exit
+)
+
+// hook is a registered cleanup function
+type hook struct {
+	id int
+	fn func()
+}
+
+// Context returns a context that is cancelled on SIGINT or SIGTERM, for
+// requests that should stop when the user interrupts the run
+func Context() context.Context {
+	return ctx
+}
+
+// Notify starts handling SIGINT and SIGTERM and returns a function that
+// stops it
+func Notify() func() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	stopped := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case sig := <-signals:
+				handle(sig)
+			case <-stopped:
+				return
+			}
+		}
+	}()
+	return func() {
+		signal.Stop(signals)
+		close(stopped)
+	}
+}
+
+// OnInterrupt registers fn to run when the process is interrupted and
+// returns a function that unregisters it. Functions run in the reverse order
+// of their registration.
+func OnInterrupt(fn func()) func() {
+	mu.Lock()
+	defer mu.Unlock()
+	id := nextID
+	nextID++
+	hooks = append(hooks, hook{id: id, fn: fn})
+	return func() {
+		mu.Lock()
+		defer mu.Unlock()
+		hooks = slices.DeleteFunc(hooks, func(h hook) bool { return h.id == id })
+	}
+}
+
+// Critical runs fn, delaying the exit on a signal until it returns, for work
+// that must not be left half done such as writing a commit or a file. Once
+// the process is interrupted, fn no longer runs and the context's error is
+// returned.
+func Critical(fn func() error) error {
+	mu.Lock()
+	if err := ctx.Err(); err != nil {
+		mu.Unlock()
+		return err
+	}
+	active++
+	mu.Unlock()
+
+	defer func() {
+		mu.Lock()
+		active--
+		done.Broadcast()
+		mu.Unlock()
+	}()
+	return fn()
+}
+
+// IgnoreInterrupts runs fn with SIGINT ignored, for child processes such as
+// an editor that share the terminal and handle Ctrl-C themselves. SIGTERM
+// still ends the process.
+func IgnoreInterrupts(fn func() error) error {
+	mu.Lock()
+	ignoring++
+	mu.Unlock()
+	defer func() {
+		mu.Lock()
+		ignoring--
+		mu.Unlock()
+	}()
+	return fn()
+}
+
+// handle cancels Context, waits for critical sections, runs the cleanup
+// functions and exits with the conventional 128+signal status
+func handle(sig os.Signal) {
+	mu.Lock()
+	if sig == os.Interrupt && ignoring > 0 {
+		mu.Unlock()
+		return
+	}
+	cancel()
+	for active > 0 {
+		done.Wait()
+	}
+	pending := hooks
+	hooks = nil
+	mu.Unlock()
+
+	for i := len(pending) - 1; i >= 0; i-- {
+		pending[i].fn()
+	}
+
+	code := 130
+	if sig == syscall.SIGTERM {
+		code = 143
+	}
+	exit(code)
+}
@@ -0,0 +1,109 @@
+package interrupt
+
+import (
+	"context"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// reset restores the package state and records the exit codes of handle
+func reset(t *testing.T) *[]int {
+	t.Helper()
+	var codes []int
+	ctx, cancel = context.WithCancel(context.Background())
+	hooks, nextID, active, ignoring = nil, 0, 0, 0
+	exit = func(code int) { codes = append(codes, code) }
+	t.Cleanup(func() { exit = os.Exit })
+	return &codes
+}
+
+func TestHandle_RunsHooksInReverse(t *testing.T) {
+	codes := reset(t)
+
+	var ran []string
+	OnInterrupt(func() { ran = append(ran, "first") })
+	remove := OnInterrupt(func() { ran = append(ran, "removed") })
+	OnInterrupt(func() { ran = append(ran, "last") })
+	remove()
+
+	handle(os.Interrupt)
+
+	assert.Equal(t, []string{"last", "first"}, ran)
+	assert.Equal(t, []int{130}, *codes)
+	assert.ErrorIs(t, Context().Err(), context.Canceled)
+}
+
+func TestHandle_SIGTERM(t *testing.T) {
+	codes := reset(t)
+
+	handle(syscall.SIGTERM)
+
+	assert.Equal(t, []int{143}, *codes)
+}
+
+func TestHandle_WaitsForCriticalSection(t *testing.T) {
+	codes := reset(t)
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	finished := make(chan error)
+	go func() {
+		finished <- Critical(func() error {
+			close(started)
+			<-release
+			return nil
+		})
+	}()
+	<-started
+
+	handled := make(chan struct{})
+	go func() {
+		handle(os.Interrupt)
+		close(handled)
+	}()
+
+	select {
+	case <-handled:
+		t.Fatal("handle returned while a critical section was running")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(release)
+	require.NoError(t, <-finished)
+	<-handled
+	assert.Equal(t, []int{130}, *codes)
+}
+
+func TestCritical_AfterInterrupt(t *testing.T) {
+	reset(t)
+	handle(os.Interrupt)
+
+	ran := false
+	err := Critical(func() error {
+		ran = true
+		return nil
+	})
+
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.False(t, ran)
+}
+
+func TestIgnoreInterrupts(t *testing.T) {
+	codes := reset(t)
+
+	err := IgnoreInterrupts(func() error {
+		handle(os.Interrupt)
+		assert.NoError(t, Context().Err())
+		assert.Empty(t, *codes)
+
+		handle(syscall.SIGTERM)
+		assert.Equal(t, []int{143}, *codes)
+		return nil
+	})
+	require.NoError(t, err)
+}
@@ -0,0 +1,86 @@
+package keyring
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// Service is the keyring service name under which commit-ai stores secrets
+const Service = "commit-ai"
+
+var (
+	// ErrNotFound is returned when no secret is stored for an account
+	ErrNotFound = errors.New("secret not found in keyring")
+	// ErrUnsupported is returned on platforms without a supported keyring tool
+	ErrUnsupported = errors.New("keyring is not supported on this platform")
+)
+
+// runCommand executes a keyring tool and returns its trimmed stdout. It is a
+// variable so tests can replace it.
+var runCommand = func(stdin string, name string, args ...string) (string, error) {
+	cmd := exec.Command(name, args...) // #nosec G204 -- name and args are fixed keyring tools
+	if stdin != "" {
+		cmd.Stdin = strings.NewReader(stdin)
+	}
+	var stdout bytes.Buffer
+	cmd.Stdout = &stdout
+	if err := cmd.Run(); err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
+
+// Get returns the secret stored for account, using the macOS keychain or the
+// freedesktop secret service (secret-tool) on Linux.
+func Get(account string) (string, error) {
+	var (
+		secret string
+		err    error
+	)
+
+	switch runtime.GOOS {
+	case "darwin":
+		secret, err = runCommand("", "security", "find-generic-password", "-s", Service, "-a", account, "-w")
+	case "linux", "freebsd", "openbsd":
+		secret, err = runCommand("", "secret-tool", "lookup", "service", Service, "account", account)
+	default:
+		return "", ErrUnsupported
+	}
+
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return "", ErrNotFound
+		}
+		return "", fmt.Errorf("failed to read keyring: %w", err)
+	}
+	if secret == "" {
+		return "", ErrNotFound
+	}
+
+	return secret, nil
+}
+
+// Set stores secret for account in the platform keyring
+func Set(account, secret string) error {
+	var err error
+
+	switch runtime.GOOS {
+	case "darwin":
+		_, err = runCommand("", "security", "add-generic-password", "-U", "-s", Service, "-a", account, "-w", secret)
+	case "linux", "freebsd", "openbsd":
+		label := fmt.Sprintf("%s %s", Service, account)
+		_, err = runCommand(secret, "secret-tool", "store", "--label", label, "service", Service, "account", account)
+	default:
+		return ErrUnsupported
+	}
+
+	if err != nil {
+		return fmt.Errorf("failed to write keyring: %w", err)
+	}
+	return nil
+}
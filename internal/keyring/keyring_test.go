@@ -0,0 +1,62 @@
+package keyring
+
+import (
+	"os/exec"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func stubRunCommand(t *testing.T, fn func(stdin, name string, args ...string) (string, error)) {
+	original := runCommand
+	runCommand = fn
+	t.Cleanup(func() { runCommand = original })
+}
+
+func TestGet(t *testing.T) {
+	if runtime.GOOS != "linux" && runtime.GOOS != "darwin" {
+		t.Skip("keyring tools not supported on this platform")
+	}
+
+	var gotArgs []string
+	stubRunCommand(t, func(stdin, name string, args ...string) (string, error) {
+		gotArgs = args
+		return "secret-token", nil
+	})
+
+	secret, err := Get("jira")
+	require.NoError(t, err)
+	assert.Equal(t, "secret-token", secret)
+	assert.Contains(t, gotArgs, Service)
+	assert.Contains(t, gotArgs, "jira")
+}
+
+func TestGet_NotFound(t *testing.T) {
+	if runtime.GOOS != "linux" && runtime.GOOS != "darwin" {
+		t.Skip("keyring tools not supported on this platform")
+	}
+
+	stubRunCommand(t, func(stdin, name string, args ...string) (string, error) {
+		return "", &exec.ExitError{}
+	})
+
+	_, err := Get("jira")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestSet(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("secret-tool is only used on linux")
+	}
+
+	var gotStdin string
+	stubRunCommand(t, func(stdin, name string, args ...string) (string, error) {
+		gotStdin = stdin
+		return "", nil
+	})
+
+	require.NoError(t, Set("jira", "secret-token"))
+	assert.Equal(t, "secret-token", gotStdin)
+}
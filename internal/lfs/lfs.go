@@ -0,0 +1,95 @@
+// Package lfs recognizes Git LFS pointer files, so large objects can be
+// described by their size and object ID instead of their content.
+package lfs
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Version is the pointer format written by current git-lfs releases
+const Version = "https://git-lfs.github.com/spec/v1"
+
+// legacyVersion is the pointer format of pre-1.0 releases
+const legacyVersion = "https://hawser.github.com/spec/v1"
+
+// maxPointerSize is the size limit of pointer files set by the specification
+const maxPointerSize = 1024
+
+// oidPattern matches a SHA-256 object ID
+var oidPattern = regexp.MustCompile(`^sha256:([0-9a-f]{64})$`)
+
+// Pointer identifies a large object stored outside the repository
+type Pointer struct {
+	// OID is the hex SHA-256 of the object
+	OID string
+	// Size is the object size in bytes, -1 when unknown
+	Size int64
+}
+
+// String returns the pointer file content
+func (p Pointer) String() string {
+	return fmt.Sprintf("version %s\noid sha256:%s\nsize %d\n", Version, p.OID, p.Size)
+}
+
+// Parse parses the content of a pointer file
+func Parse(content string) (Pointer, bool) {
+	if len(content) > maxPointerSize {
+		return Pointer{}, false
+	}
+	lines := strings.Split(strings.TrimRight(content, "\n"), "\n")
+	if lines[0] != "version "+Version && lines[0] != "version "+legacyVersion {
+		return Pointer{}, false
+	}
+	p, ok := ParseLines(lines)
+	return p, ok && p.Size >= 0
+}
+
+// ParseLines parses pointer lines that may be incomplete, as seen on one side
+// of a diff that omits unchanged lines. Every line must be a pointer key and
+// the oid must be present; Size is -1 when the size line is missing.
+func ParseLines(lines []string) (Pointer, bool) {
+	p := Pointer{Size: -1}
+	for _, line := range lines {
+		key, value, _ := strings.Cut(strings.TrimSpace(line), " ")
+		switch {
+		case key == "":
+		case key == "version":
+			if value != Version && value != legacyVersion {
+				return Pointer{}, false
+			}
+		case key == "oid":
+			m := oidPattern.FindStringSubmatch(value)
+			if m == nil {
+				return Pointer{}, false
+			}
+			p.OID = m[1]
+		case key == "size":
+			size, err := strconv.ParseInt(value, 10, 64)
+			if err != nil || size < 0 {
+				return Pointer{}, false
+			}
+			p.Size = size
+		case strings.HasPrefix(key, "ext-"):
+		default:
+			return Pointer{}, false
+		}
+	}
+	return p, p.OID != ""
+}
+
+// FromReader returns the pointer of the object read from r, hashing it as a
+// stream so large files aren't held in memory
+func FromReader(r io.Reader) (Pointer, error) {
+	h := sha256.New()
+	size, err := io.Copy(h, r)
+	if err != nil {
+		return Pointer{}, err
+	}
+	return Pointer{OID: hex.EncodeToString(h.Sum(nil)), Size: size}, nil
+}
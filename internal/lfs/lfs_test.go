@@ -0,0 +1,47 @@
+package lfs
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const oid = "4d7a214614ab2935c943f9e0ff69d22eadbb8f32b1258daaa5e2ca24d17e2393"
+
+func TestParse(t *testing.T) {
+	content := "version https://git-lfs.github.com/spec/v1\noid sha256:" + oid + "\nsize 12345\n"
+	p, ok := Parse(content)
+	require.True(t, ok)
+	assert.Equal(t, Pointer{OID: oid, Size: 12345}, p)
+	assert.Equal(t, content, p.String())
+
+	_, ok = Parse("oid sha256:" + oid + "\nsize 1\n")
+	assert.False(t, ok, "the version line is required")
+
+	_, ok = Parse("version https://git-lfs.github.com/spec/v1\noid sha256:" + oid + "\n")
+	assert.False(t, ok, "the size line is required")
+
+	_, ok = Parse("version https://git-lfs.github.com/spec/v1\nhello\n")
+	assert.False(t, ok)
+}
+
+func TestParseLines(t *testing.T) {
+	p, ok := ParseLines([]string{"oid sha256:" + oid})
+	require.True(t, ok)
+	assert.Equal(t, Pointer{OID: oid, Size: -1}, p)
+
+	_, ok = ParseLines([]string{"size 10"})
+	assert.False(t, ok)
+
+	_, ok = ParseLines([]string{"oid sha256:" + oid, "binary junk"})
+	assert.False(t, ok)
+}
+
+func TestFromReader(t *testing.T) {
+	p, err := FromReader(strings.NewReader("hello"))
+	require.NoError(t, err)
+	assert.Equal(t, "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824", p.OID)
+	assert.Equal(t, int64(5), p.Size)
+}
@@ -0,0 +1,271 @@
+// Package lineedit reads a line on the terminal with readline-style editing:
+// the line can start with a value to edit in place, the cursor moves with the
+// arrow keys, Home/End and the usual Emacs keys, and Up/Down recall the lines
+// entered before.
+package lineedit
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"unicode"
+
+	"github.com/nseba/commit-ai/internal/interrupt"
+)
+
+var (
+	// ErrCancelled is returned when line editing is cancelled with Ctrl-C
+	ErrCancelled = errors.New("input cancelled")
+	// ErrUnavailable is returned when the terminal cannot be put in raw mode
+	ErrUnavailable = errors.New("line editing is not available")
+)
+
+// Control keys understood by the editor
+const (
+	keyCtrlA     = 1
+	keyCtrlB     = 2
+	keyCtrlC     = 3
+	keyCtrlD     = 4
+	keyCtrlE     = 5
+	keyCtrlF     = 6
+	keyBackspace = 8
+	keyCtrlK     = 11
+	keyLineFeed  = 10
+	keyEnter     = 13
+	keyCtrlN     = 14
+	keyCtrlP     = 16
+	keyCtrlU     = 21
+	keyCtrlW     = 23
+	keyEscape    = 27
+	keyDelete    = 127
+)
+
+// Editor reads lines from a terminal, remembering the lines entered as
+// history
+type Editor struct {
+	in  *bufio.Reader
+	out io.Writer
+	// history holds the lines entered, oldest first
+	history []string
+}
+
+// New returns an editor reading keys from in and drawing the line on out.
+// in is shared with the caller, so input it buffered stays readable.
+func New(in *bufio.Reader, out io.Writer) *Editor {
+	return &Editor{in: in, out: out}
+}
+
+// ReadLine puts the terminal on standard input in raw mode and reads a line
+// starting as initial. Ctrl-C returns ErrCancelled; when the terminal cannot
+// be put in raw mode, ErrUnavailable is returned.
+func (e *Editor) ReadLine(prompt, initial string) (string, error) {
+	restore, err := rawMode()
+	if err != nil {
+		return "", ErrUnavailable
+	}
+	defer func() {
+		if err := restore(); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to restore the terminal: %v\n", err)
+		}
+	}()
+	return e.edit(prompt, initial)
+}
+
+// edit reads keys until Enter or Ctrl-C, editing the line
+func (e *Editor) edit(prompt, initial string) (string, error) {
+	line := &lineState{buf: []rune(initial), pos: len([]rune(initial))}
+	// history[len(e.history)] holds the line being edited while browsing
+	history := append(append([]string(nil), e.history...), initial)
+	current := len(e.history)
+
+	for {
+		line.render(e.out, prompt)
+
+		r, _, err := e.in.ReadRune()
+		if err != nil {
+			fmt.Fprint(e.out, "\r\n")
+			return "", fmt.Errorf("failed to read input: %w", err)
+		}
+
+		switch r {
+		case keyEnter, keyLineFeed:
+			fmt.Fprint(e.out, "\r\n")
+			result := string(line.buf)
+			if strings.TrimSpace(result) != "" {
+				e.history = append(e.history, result)
+			}
+			return result, nil
+		case keyCtrlC:
+			fmt.Fprint(e.out, "^C\r\n")
+			return "", ErrCancelled
+		case keyCtrlD:
+			line.deleteForward()
+		case keyCtrlA:
+			line.pos = 0
+		case keyCtrlE:
+			line.pos = len(line.buf)
+		case keyCtrlB:
+			line.moveLeft()
+		case keyCtrlF:
+			line.moveRight()
+		case keyCtrlK:
+			line.buf = line.buf[:line.pos]
+		case keyCtrlU:
+			line.buf = line.buf[line.pos:]
+			line.pos = 0
+		case keyCtrlW:
+			line.deleteWord()
+		case keyBackspace, keyDelete:
+			line.deleteBackward()
+		case keyCtrlP:
+			current = line.recall(history, current, current-1)
+		case keyCtrlN:
+			current = line.recall(history, current, current+1)
+		case keyEscape:
+			switch e.readEscape() {
+			case "[A", "OA":
+				current = line.recall(history, current, current-1)
+			case "[B", "OB":
+				current = line.recall(history, current, current+1)
+			case "[C", "OC":
+				line.moveRight()
+			case "[D", "OD":
+				line.moveLeft()
+			case "[H", "OH", "[1~", "[7~":
+				line.pos = 0
+			case "[F", "OF", "[4~", "[8~":
+				line.pos = len(line.buf)
+			case "[3~":
+				line.deleteForward()
+			}
+		default:
+			if unicode.IsPrint(r) {
+				line.insert(r)
+			}
+		}
+	}
+}
+
+// readEscape reads the rest of an escape sequence after the escape key,
+// such as "[A" for the up arrow
+func (e *Editor) readEscape() string {
+	first, err := e.in.ReadByte()
+	if err != nil || (first != '[' && first != 'O') {
+		return ""
+	}
+	seq := []byte{first}
+	for {
+		b, err := e.in.ReadByte()
+		if err != nil {
+			return ""
+		}
+		seq = append(seq, b)
+		// Parameters are digits and semicolons; anything else ends the sequence
+		if (b < '0' || b > '9') && b != ';' {
+			return string(seq)
+		}
+	}
+}
+
+// lineState is the text being edited and the cursor position within it
+type lineState struct {
+	buf []rune
+	pos int
+}
+
+// render redraws the prompt and the line, and places the cursor
+func (l *lineState) render(out io.Writer, prompt string) {
+	fmt.Fprintf(out, "\r%s%s\x1b[K", prompt, string(l.buf))
+	if back := len(l.buf) - l.pos; back > 0 {
+		fmt.Fprintf(out, "\x1b[%dD", back)
+	}
+}
+
+// insert adds r at the cursor
+func (l *lineState) insert(r rune) {
+	l.buf = append(l.buf[:l.pos], append([]rune{r}, l.buf[l.pos:]...)...)
+	l.pos++
+}
+
+func (l *lineState) moveLeft() {
+	if l.pos > 0 {
+		l.pos--
+	}
+}
+
+func (l *lineState) moveRight() {
+	if l.pos < len(l.buf) {
+		l.pos++
+	}
+}
+
+// deleteBackward removes the character before the cursor
+func (l *lineState) deleteBackward() {
+	if l.pos > 0 {
+		l.buf = append(l.buf[:l.pos-1], l.buf[l.pos:]...)
+		l.pos--
+	}
+}
+
+// deleteForward removes the character under the cursor
+func (l *lineState) deleteForward() {
+	if l.pos < len(l.buf) {
+		l.buf = append(l.buf[:l.pos], l.buf[l.pos+1:]...)
+	}
+}
+
+// deleteWord removes the word before the cursor, with the spaces after it
+func (l *lineState) deleteWord() {
+	start := l.pos
+	for start > 0 && unicode.IsSpace(l.buf[start-1]) {
+		start--
+	}
+	for start > 0 && !unicode.IsSpace(l.buf[start-1]) {
+		start--
+	}
+	l.buf = append(l.buf[:start], l.buf[l.pos:]...)
+	l.pos = start
+}
+
+// recall replaces the line with history entry next, keeping the edits of
+// entry current, and returns the entry now shown
+func (l *lineState) recall(history []string, current, next int) int {
+	if next < 0 || next >= len(history) {
+		return current
+	}
+	history[current] = string(l.buf)
+	l.buf = []rune(history[next])
+	l.pos = len(l.buf)
+	return next
+}
+
+// rawMode puts the terminal on standard input in raw mode, returning a
+// function that restores its previous settings
+func rawMode() (func() error, error) {
+	saved, err := stty("-g")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := stty("raw", "-echo"); err != nil {
+		return nil, err
+	}
+	// Raw mode turns Ctrl-C into a key, but SIGTERM still ends the process
+	removeHook := interrupt.OnInterrupt(func() { _, _ = stty(saved) })
+	return func() error {
+		removeHook()
+		_, err := stty(saved)
+		return err
+	}, nil
+}
+
+// stty runs stty on the terminal on standard input and returns its output
+func stty(args ...string) (string, error) {
+	cmd := exec.Command("stty", args...) // #nosec G204 -- the arguments are stty modes
+	cmd.Stdin = os.Stdin
+	out, err := cmd.Output()
+	return strings.TrimSpace(string(out)), err
+}
@@ -0,0 +1,134 @@
+package lineedit
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	left  = "\x1b[D"
+	right = "\x1b[C"
+	up    = "\x1b[A"
+	down  = "\x1b[B"
+	home  = "\x1b[H"
+	end   = "\x1b[F"
+	del   = "\x1b[3~"
+)
+
+// newEditor returns an editor reading keys from input
+func newEditor(input string) (*Editor, *bytes.Buffer) {
+	var out bytes.Buffer
+	return New(bufio.NewReader(strings.NewReader(input)), &out), &out
+}
+
+func TestEditor_Editing(t *testing.T) {
+	tests := []struct {
+		name    string
+		initial string
+		keys    string
+		want    string
+	}{
+		{"enter keeps the initial line", "feat: add login", "\r", "feat: add login"},
+		{"typing appends", "feat: add", " login\r", "feat: add login"},
+		{"line feed ends the line", "", "fix: typo\n", "fix: typo"},
+		{"backspace", "feat: add logn", "\x7fin\r", "feat: add login"},
+		{"ctrl-h", "feat: add login!", "\x08\r", "feat: add login"},
+		{"insert before the cursor", "feat: add lgin", left + left + left + "o\r", "feat: add login"},
+		{"ctrl-b and ctrl-f", "fix: tpo", "\x02\x02y\x06\x06\x06!\r", "fix: typo!"},
+		{"home and end", "add login", home + "feat: " + end + " form\r", "feat: add login form"},
+		{"ctrl-a and ctrl-e", "add login", "\x01feat: \x05 form\r", "feat: add login form"},
+		{"alternative home and end sequences", "b", "\x1bOHa\x1b[4~c\r", "abc"},
+		{"ctrl-d deletes under the cursor", "feat: add xlogin", "\x01" + strings.Repeat(right, 10) + "\x04\r", "feat: add login"},
+		{"delete key", "feat: add xlogin", home + strings.Repeat(right, 10) + del + "\r", "feat: add login"},
+		{"ctrl-k kills to the end", "feat: add login form", left + left + left + left + left + "\x0b\r", "feat: add login"},
+		{"ctrl-u kills to the start", "wip feat: add login", home + strings.Repeat(right, 4) + "\x15\r", "feat: add login"},
+		{"ctrl-w deletes the word before the cursor", "feat: add the login", left + left + left + left + left + "\x17\r", "feat: add login"},
+		{"ctrl-w takes trailing spaces", "feat: add login  ", "\x17\x17\r", "feat: "},
+		{"cursor stops at the ends", "ab", left + left + left + "x" + right + right + right + "y\r", "xaby"},
+		{"backspace at the start does nothing", "ab", home + "\x7f\r", "ab"},
+		{"unknown escape sequences are ignored", "ab", "\x1b[1;5Cc\x1bxd\r", "abcd"},
+		{"non-printable keys are ignored", "ab", "\x00\x07\x1fc\r", "abc"},
+		{"unicode", "feat: cafe", left + "\x04é\r", "feat: café"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			editor, _ := newEditor(tt.keys)
+			line, err := editor.edit("> ", tt.initial)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, line)
+		})
+	}
+}
+
+func TestEditor_History(t *testing.T) {
+	editor, _ := newEditor("first\r" + "\x15second\r" +
+		// Up recalls the latest line, up again the one before
+		up + up + "!\r" +
+		// The edits of the line being typed survive browsing
+		" draft" + up + down + "\r" +
+		// Up and down stop at the ends of the history
+		up + up + up + up + up + "\x10\x0e" + "\r")
+
+	line, err := editor.edit("> ", "")
+	require.NoError(t, err)
+	assert.Equal(t, "first", line)
+
+	line, err = editor.edit("> ", "initial")
+	require.NoError(t, err)
+	assert.Equal(t, "second", line)
+
+	line, err = editor.edit("> ", "")
+	require.NoError(t, err)
+	assert.Equal(t, "first!", line)
+
+	line, err = editor.edit("> ", "new")
+	require.NoError(t, err)
+	assert.Equal(t, "new draft", line)
+
+	line, err = editor.edit("> ", "")
+	require.NoError(t, err)
+	assert.Equal(t, "second", line)
+
+	assert.Equal(t, []string{"first", "second", "first!", "new draft", "second"}, editor.history)
+}
+
+func TestEditor_BlankLinesStayOutOfHistory(t *testing.T) {
+	editor, _ := newEditor("  \r")
+	line, err := editor.edit("> ", "")
+	require.NoError(t, err)
+	assert.Equal(t, "  ", line)
+	assert.Empty(t, editor.history)
+}
+
+func TestEditor_Cancel(t *testing.T) {
+	editor, out := newEditor("typed\x03more\r")
+	_, err := editor.edit("> ", "feat: add login")
+	assert.ErrorIs(t, err, ErrCancelled)
+	assert.True(t, strings.HasSuffix(out.String(), "^C\r\n"))
+	assert.Empty(t, editor.history)
+}
+
+func TestEditor_EndOfInput(t *testing.T) {
+	editor, _ := newEditor("unfinished")
+	_, err := editor.edit("> ", "")
+	assert.ErrorIs(t, err, io.EOF)
+}
+
+func TestEditor_Render(t *testing.T) {
+	editor, out := newEditor(left + left + "\r")
+	_, err := editor.edit("Subject: ", "fix: typo")
+	require.NoError(t, err)
+
+	// The line is redrawn after each key, with the cursor moved back from
+	// the end of the line
+	assert.Equal(t, "\rSubject: fix: typo\x1b[K"+
+		"\rSubject: fix: typo\x1b[K\x1b[1D"+
+		"\rSubject: fix: typo\x1b[K\x1b[2D"+
+		"\r\n", out.String())
+}
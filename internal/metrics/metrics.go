@@ -0,0 +1,198 @@
+// Package metrics counts the requests a long-running commit-ai process sends
+// to model providers and exposes them in the Prometheus text format, so
+// platform teams can monitor shared services. Like the audit log, metrics
+// describe requests, never their content.
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Request outcomes
+const (
+	StatusOK    = "ok"
+	StatusError = "error"
+)
+
+// DurationBuckets are the upper bounds, in seconds, of the request latency
+// histogram: local models answer within a second, large prompts to remote
+// models can take a minute
+var DurationBuckets = []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 120}
+
+// Request is one request sent to a provider
+type Request struct {
+	Provider string
+	Model    string
+	Duration time.Duration
+	// ErrorKind classifies a failed request, e.g. "auth" or "network"; empty
+	// when the request succeeded
+	ErrorKind string
+	Failed    bool
+	// PromptTokens and CompletionTokens are estimated sizes of the prompt
+	// and the response
+	PromptTokens     int
+	CompletionTokens int
+}
+
+// series identifies a metric by its label values
+type series struct {
+	provider, model, extra string
+}
+
+// histogram counts observations per bucket of DurationBuckets
+type histogram struct {
+	buckets []uint64
+	sum     float64
+	count   uint64
+}
+
+// Metrics aggregates provider requests. It is safe for concurrent use.
+type Metrics struct {
+	mu               sync.Mutex
+	requests         map[series]uint64
+	errors           map[series]uint64
+	promptTokens     map[series]uint64
+	completionTokens map[series]uint64
+	durations        map[series]*histogram
+}
+
+// New creates empty metrics
+func New() *Metrics {
+	return &Metrics{
+		requests:         make(map[series]uint64),
+		errors:           make(map[series]uint64),
+		promptTokens:     make(map[series]uint64),
+		completionTokens: make(map[series]uint64),
+		durations:        make(map[series]*histogram),
+	}
+}
+
+// Observe records a request
+func (m *Metrics) Observe(r Request) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	status := StatusOK
+	if r.Failed {
+		status = StatusError
+		kind := r.ErrorKind
+		if kind == "" {
+			kind = "other"
+		}
+		m.errors[series{r.Provider, r.Model, kind}]++
+	}
+	m.requests[series{r.Provider, r.Model, status}]++
+
+	key := series{provider: r.Provider, model: r.Model}
+	m.promptTokens[key] += uint64(max(r.PromptTokens, 0))
+	m.completionTokens[key] += uint64(max(r.CompletionTokens, 0))
+
+	h, ok := m.durations[key]
+	if !ok {
+		h = &histogram{buckets: make([]uint64, len(DurationBuckets))}
+		m.durations[key] = h
+	}
+	seconds := r.Duration.Seconds()
+	for i, bound := range DurationBuckets {
+		if seconds <= bound {
+			h.buckets[i]++
+		}
+	}
+	h.sum += seconds
+	h.count++
+}
+
+// WriteTo writes the metrics in the Prometheus text exposition format
+func (m *Metrics) WriteTo(w io.Writer) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var b strings.Builder
+	writeCounter(&b, "commit_ai_requests_total", "Requests sent to model providers.", "status", m.requests)
+	writeCounter(&b, "commit_ai_provider_errors_total", "Failed provider requests by error kind.", "kind", m.errors)
+	writeCounter(&b, "commit_ai_prompt_tokens_total", "Estimated tokens sent in prompts.", "", m.promptTokens)
+	writeCounter(&b, "commit_ai_completion_tokens_total", "Estimated tokens received in responses.", "", m.completionTokens)
+	m.writeDurations(&b)
+
+	n, err := io.WriteString(w, b.String())
+	return int64(n), err
+}
+
+// ServeHTTP serves the metrics to a Prometheus scraper
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	_, _ = m.WriteTo(w)
+}
+
+// writeCounter writes a counter with the provider and model labels and, when
+// extra is set, a third label holding series.extra
+func writeCounter(b *strings.Builder, name, help, extra string, values map[series]uint64) {
+	fmt.Fprintf(b, "# HELP %s %s\n# TYPE %s counter\n", name, help, name)
+	for _, key := range sortedKeys(values) {
+		labels := [][2]string{{"provider", key.provider}, {"model", key.model}}
+		if extra != "" {
+			labels = append(labels, [2]string{extra, key.extra})
+		}
+		fmt.Fprintf(b, "%s%s %d\n", name, formatLabels(labels), values[key])
+	}
+}
+
+// writeDurations writes the request latency histogram
+func (m *Metrics) writeDurations(b *strings.Builder) {
+	const name = "commit_ai_request_duration_seconds"
+	fmt.Fprintf(b, "# HELP %s Latency of provider requests.\n# TYPE %s histogram\n", name, name)
+	for _, key := range sortedKeys(m.durations) {
+		h := m.durations[key]
+		labels := [][2]string{{"provider", key.provider}, {"model", key.model}}
+		for i, bound := range DurationBuckets {
+			le := strconv.FormatFloat(bound, 'g', -1, 64)
+			fmt.Fprintf(b, "%s_bucket%s %d\n", name, formatLabels(append(labels, [2]string{"le", le})), h.buckets[i])
+		}
+		fmt.Fprintf(b, "%s_bucket%s %d\n", name, formatLabels(append(labels, [2]string{"le", "+Inf"})), h.count)
+		fmt.Fprintf(b, "%s_sum%s %s\n", name, formatLabels(labels), strconv.FormatFloat(h.sum, 'g', -1, 64))
+		fmt.Fprintf(b, "%s_count%s %d\n", name, formatLabels(labels), h.count)
+	}
+}
+
+// sortedKeys returns the series of values in a stable order
+func sortedKeys[V any](values map[series]V) []series {
+	keys := make([]series, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := keys[i], keys[j]
+		if a.provider != b.provider {
+			return a.provider < b.provider
+		}
+		if a.model != b.model {
+			return a.model < b.model
+		}
+		return a.extra < b.extra
+	})
+	return keys
+}
+
+// labelEscaper escapes label values as the text format requires
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// formatLabels formats name-value pairs as {name="value",...}
+func formatLabels(labels [][2]string) string {
+	parts := make([]string, len(labels))
+	for i, label := range labels {
+		parts[i] = fmt.Sprintf(`%s="%s"`, label[0], labelEscaper.Replace(label[1]))
+	}
+	return "{" + strings.Join(parts, ",") + "}"
+}
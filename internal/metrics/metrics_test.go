@@ -0,0 +1,59 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetrics_WriteTo(t *testing.T) {
+	m := New()
+	m.Observe(Request{Provider: "openai", Model: "gpt-4", Duration: 300 * time.Millisecond, PromptTokens: 120, CompletionTokens: 10})
+	m.Observe(Request{Provider: "openai", Model: "gpt-4", Duration: 3 * time.Second, Failed: true, ErrorKind: "quota", PromptTokens: 80})
+	m.Observe(Request{Provider: "ollama", Model: `my"model`, Duration: time.Second, Failed: true})
+
+	var out strings.Builder
+	_, err := m.WriteTo(&out)
+	require.NoError(t, err)
+	text := out.String()
+
+	assert.Contains(t, text, "# TYPE commit_ai_requests_total counter\n")
+	assert.Contains(t, text, `commit_ai_requests_total{provider="openai",model="gpt-4",status="ok"} 1`+"\n")
+	assert.Contains(t, text, `commit_ai_requests_total{provider="openai",model="gpt-4",status="error"} 1`+"\n")
+	assert.Contains(t, text, `commit_ai_provider_errors_total{provider="openai",model="gpt-4",kind="quota"} 1`+"\n")
+	assert.Contains(t, text, `commit_ai_provider_errors_total{provider="ollama",model="my\"model",kind="other"} 1`+"\n")
+	assert.Contains(t, text, `commit_ai_prompt_tokens_total{provider="openai",model="gpt-4"} 200`+"\n")
+	assert.Contains(t, text, `commit_ai_completion_tokens_total{provider="openai",model="gpt-4"} 10`+"\n")
+
+	// Buckets are cumulative
+	assert.Contains(t, text, "# TYPE commit_ai_request_duration_seconds histogram\n")
+	assert.Contains(t, text, `commit_ai_request_duration_seconds_bucket{provider="openai",model="gpt-4",le="0.25"} 0`+"\n")
+	assert.Contains(t, text, `commit_ai_request_duration_seconds_bucket{provider="openai",model="gpt-4",le="0.5"} 1`+"\n")
+	assert.Contains(t, text, `commit_ai_request_duration_seconds_bucket{provider="openai",model="gpt-4",le="5"} 2`+"\n")
+	assert.Contains(t, text, `commit_ai_request_duration_seconds_bucket{provider="openai",model="gpt-4",le="+Inf"} 2`+"\n")
+	assert.Contains(t, text, `commit_ai_request_duration_seconds_sum{provider="openai",model="gpt-4"} 3.3`+"\n")
+	assert.Contains(t, text, `commit_ai_request_duration_seconds_count{provider="openai",model="gpt-4"} 2`+"\n")
+
+	// Series are sorted, so scrapes are stable
+	assert.Less(t, strings.Index(text, `requests_total{provider="ollama"`), strings.Index(text, `requests_total{provider="openai"`))
+}
+
+func TestMetrics_ServeHTTP(t *testing.T) {
+	m := New()
+	m.Observe(Request{Provider: "ollama", Model: "llama2", Duration: time.Second})
+
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "text/plain; version=0.0.4; charset=utf-8", rec.Header().Get("Content-Type"))
+	assert.Contains(t, rec.Body.String(), `commit_ai_requests_total{provider="ollama",model="llama2",status="ok"} 1`)
+
+	rec = httptest.NewRecorder()
+	m.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/metrics", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+}
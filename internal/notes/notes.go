@@ -0,0 +1,59 @@
+// Package notes records how commit messages were generated as git notes, so
+// AI-assisted history can be audited later.
+package notes
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Ref is the notes ref generation metadata is stored under
+const Ref = "refs/notes/commit-ai"
+
+// schemaVersion is bumped on incompatible metadata changes
+const schemaVersion = 1
+
+// Metadata describes the generation of a commit message
+type Metadata struct {
+	Version     int       `json:"version"`
+	Tool        string    `json:"tool"`
+	Provider    string    `json:"provider,omitempty"`
+	Model       string    `json:"model,omitempty"`
+	PromptHash  string    `json:"prompt_hash,omitempty"`
+	Candidates  []string  `json:"candidates"`
+	Edited      bool      `json:"edited"`
+	GeneratedAt time.Time `json:"generated_at"`
+}
+
+// New returns metadata for a message generated by the given tool version
+func New(toolVersion string) *Metadata {
+	return &Metadata{
+		Version:     schemaVersion,
+		Tool:        "commit-ai " + toolVersion,
+		Candidates:  []string{},
+		GeneratedAt: time.Now().UTC().Truncate(time.Second),
+	}
+}
+
+// Encode renders the metadata as the note body
+func (m *Metadata) Encode() (string, error) {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to encode note: %w", err)
+	}
+	return string(data) + "\n", nil
+}
+
+// Decode parses a note body written by Encode
+func Decode(note string) (*Metadata, error) {
+	var m Metadata
+	if err := json.Unmarshal([]byte(strings.TrimSpace(note)), &m); err != nil {
+		return nil, fmt.Errorf("not a commit-ai note: %w", err)
+	}
+	if m.Version > schemaVersion {
+		return nil, fmt.Errorf("unsupported note version %d", m.Version)
+	}
+	return &m, nil
+}
@@ -0,0 +1,34 @@
+package notes
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncodeDecode(t *testing.T) {
+	m := New("v1.2.3")
+	m.Provider = "ollama"
+	m.Model = "llama2"
+	m.PromptHash = "sha256:abc"
+	m.Candidates = []string{"feat: first", "feat: second"}
+	m.Edited = true
+
+	note, err := m.Encode()
+	require.NoError(t, err)
+	assert.Contains(t, note, `"model": "llama2"`)
+
+	decoded, err := Decode(note)
+	require.NoError(t, err)
+	assert.Equal(t, m, decoded)
+	assert.Equal(t, "commit-ai v1.2.3", decoded.Tool)
+}
+
+func TestDecode_Invalid(t *testing.T) {
+	_, err := Decode("translated message")
+	assert.Error(t, err)
+
+	_, err = Decode(`{"version": 99}`)
+	assert.Error(t, err)
+}
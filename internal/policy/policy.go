@@ -0,0 +1,183 @@
+package policy
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/BurntSushi/toml"
+
+	"github.com/nseba/commit-ai/internal/commitmsg"
+)
+
+// FileName is the name of the team policy file committed to a repository
+const FileName = ".commitai-policy"
+
+// Policy defines the commit message rules a team enforces
+type Policy struct {
+	Types            []string `toml:"types"`
+	Scopes           []string `toml:"scopes"`
+	MaxSubjectLength int      `toml:"max_subject_length"`
+	RequiredTrailers []string `toml:"required_trailers"`
+	ForbiddenWords   []string `toml:"forbidden_words"`
+}
+
+// ConventionalTypes are the commit types of the Conventional Commits format
+var ConventionalTypes = []string{"feat", "fix", "docs", "style", "refactor", "perf", "test", "build", "ci", "chore", "revert"}
+
+// Violation describes a single policy rule a message does not satisfy
+type Violation struct {
+	Rule    string
+	Message string
+}
+
+func (v Violation) String() string {
+	return fmt.Sprintf("%s: %s", v.Rule, v.Message)
+}
+
+// Load reads a policy file
+func Load(policyFile string) (*Policy, error) {
+	p := &Policy{}
+	if _, err := toml.DecodeFile(policyFile, p); err != nil {
+		return nil, fmt.Errorf("failed to decode policy file %s: %w", policyFile, err)
+	}
+	return p, nil
+}
+
+// Find looks for a policy file starting at startPath and walking up to the
+// enclosing git repository root. It returns an empty string when no policy
+// file exists.
+func Find(startPath string) (string, error) {
+	currentPath, err := filepath.Abs(startPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve path: %w", err)
+	}
+
+	for {
+		policyFile := filepath.Join(currentPath, FileName)
+		if info, err := os.Stat(policyFile); err == nil && !info.IsDir() {
+			return policyFile, nil
+		}
+
+		// Don't look beyond the repository root
+		if _, err := os.Stat(filepath.Join(currentPath, ".git")); err == nil {
+			return "", nil
+		}
+
+		parent := filepath.Dir(currentPath)
+		if parent == currentPath {
+			return "", nil
+		}
+		currentPath = parent
+	}
+}
+
+// Discover finds and loads the policy that applies to startPath. It returns
+// nil without error when the repository has no policy file.
+func Discover(startPath string) (*Policy, error) {
+	policyFile, err := Find(startPath)
+	if err != nil || policyFile == "" {
+		return nil, err
+	}
+	return Load(policyFile)
+}
+
+// RequireConventional returns a copy of p that also requires a conventional
+// commit type, for callers that can't accept free-form messages. A nil p
+// stands for a repository without a policy.
+func RequireConventional(p *Policy) *Policy {
+	required := &Policy{}
+	if p != nil {
+		*required = *p
+	}
+	if len(required.Types) == 0 {
+		required.Types = ConventionalTypes
+	}
+	return required
+}
+
+// Check validates a commit message against the policy
+func (p *Policy) Check(message string) []Violation {
+	var violations []Violation
+	msg := commitmsg.Parse(message)
+
+	if msg.Header == "" {
+		return []Violation{{Rule: "subject", Message: "commit message is empty"}}
+	}
+
+	if len(p.Types) > 0 {
+		if msg.Type == "" {
+			violations = append(violations, Violation{
+				Rule:    "type",
+				Message: fmt.Sprintf("subject must start with one of: %s", strings.Join(p.Types, ", ")),
+			})
+		} else if !slices.ContainsFunc(p.Types, func(t string) bool { return strings.EqualFold(t, msg.Type) }) {
+			violations = append(violations, Violation{
+				Rule:    "type",
+				Message: fmt.Sprintf("type %q is not allowed (allowed: %s)", msg.Type, strings.Join(p.Types, ", ")),
+			})
+		}
+	}
+
+	if len(p.Scopes) > 0 && msg.Scope != "" && !slices.ContainsFunc(p.Scopes, func(s string) bool { return strings.EqualFold(s, msg.Scope) }) {
+		violations = append(violations, Violation{
+			Rule:    "scope",
+			Message: fmt.Sprintf("scope %q is not allowed (allowed: %s)", msg.Scope, strings.Join(p.Scopes, ", ")),
+		})
+	}
+
+	if p.MaxSubjectLength > 0 {
+		if length := utf8.RuneCountInString(msg.Header); length > p.MaxSubjectLength {
+			violations = append(violations, Violation{
+				Rule:    "subject-length",
+				Message: fmt.Sprintf("subject is %d characters, maximum is %d", length, p.MaxSubjectLength),
+			})
+		}
+	}
+
+	for _, trailer := range p.RequiredTrailers {
+		if _, ok := msg.Trailer(trailer); !ok {
+			violations = append(violations, Violation{
+				Rule:    "trailer",
+				Message: fmt.Sprintf("missing required trailer %q", trailer),
+			})
+		}
+	}
+
+	// Only the message counts, not git's comments or a verbose diff
+	text := msg.String()
+	for _, word := range p.ForbiddenWords {
+		if word != "" && containsWord(text, word) {
+			violations = append(violations, Violation{
+				Rule:    "forbidden-word",
+				Message: fmt.Sprintf("message contains forbidden word %q", word),
+			})
+		}
+	}
+
+	return violations
+}
+
+// containsWord reports whether text contains word, ignoring case, as a whole
+// word: "wip" matches "WIP: cleanup" but not "wipe" or "swipe". word may be
+// a phrase such as "do not merge".
+func containsWord(text, word string) bool {
+	pattern := `(?i)(^|[^\p{L}\p{N}_])` + regexp.QuoteMeta(word) + `($|[^\p{L}\p{N}_])`
+	return regexp.MustCompile(pattern).MatchString(text)
+}
+
+// Feedback formats violations as instructions for re-prompting the model
+func Feedback(message string, violations []Violation) string {
+	var b strings.Builder
+	b.WriteString("The previous commit message was rejected by the repository commit policy:\n")
+	b.WriteString(message)
+	b.WriteString("\n\nFix the following problems and output only the corrected commit message:\n")
+	for _, v := range violations {
+		b.WriteString("- " + v.String() + "\n")
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
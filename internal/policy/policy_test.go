@@ -0,0 +1,164 @@
+package policy
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPolicy_Check(t *testing.T) {
+	p := &Policy{
+		Types:            []string{"feat", "fix", "chore"},
+		Scopes:           []string{"cli", "config"},
+		MaxSubjectLength: 50,
+		RequiredTrailers: []string{"Refs"},
+		ForbiddenWords:   []string{"wip"},
+	}
+
+	tests := []struct {
+		name    string
+		message string
+		rules   []string
+	}{
+		{
+			name:    "valid message",
+			message: "feat(cli): add lint command\n\nRefs: #42",
+			rules:   nil,
+		},
+		{
+			name:    "non conventional subject",
+			message: "Add lint command\n\nRefs: #42",
+			rules:   []string{"type"},
+		},
+		{
+			name:    "disallowed type and scope",
+			message: "docs(readme): update usage\n\nRefs: #42",
+			rules:   []string{"type", "scope"},
+		},
+		{
+			name:    "subject too long and missing trailer",
+			message: "fix(config): handle an extremely long subject line that keeps going",
+			rules:   []string{"subject-length", "trailer"},
+		},
+		{
+			name:    "forbidden word",
+			message: "chore: WIP cleanup\n\nRefs: #42",
+			rules:   []string{"forbidden-word"},
+		},
+		{
+			name:    "empty message",
+			message: "",
+			rules:   []string{"subject"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var rules []string
+			for _, v := range p.Check(tt.message) {
+				rules = append(rules, v.Rule)
+			}
+			assert.Equal(t, tt.rules, rules)
+		})
+	}
+}
+
+func TestPolicy_ForbiddenWords(t *testing.T) {
+	p := &Policy{ForbiddenWords: []string{"wip", "do not merge", "c++"}}
+
+	forbidden := []string{
+		"chore: WIP cleanup",
+		"wip: cleanup",
+		"fix: cleanup (wip)",
+		"feat: add login\n\nDo not merge yet",
+		"refactor: port the C++ parser",
+	}
+	for _, message := range forbidden {
+		violations := p.Check(message)
+		require.Len(t, violations, 1, message)
+		assert.Equal(t, "forbidden-word", violations[0].Rule)
+	}
+
+	for _, message := range []string{
+		"fix: wipe the cache on logout",
+		"feat: add swipe gestures",
+		"chore: drop the wip_ prefix",
+		"docs: explain why not merged",
+		"refactor: port the C++11 parser",
+	} {
+		assert.Empty(t, p.Check(message), message)
+	}
+}
+
+func TestPolicy_ForbiddenWordsIgnoreVerboseBuffer(t *testing.T) {
+	p := &Policy{ForbiddenWords: []string{"wip"}}
+
+	// A commit --verbose buffer, with the word only in comments and the diff
+	buffer := "feat: add login\n\n" +
+		"# On branch wip-login\n" +
+		"# ------------------------ >8 ------------------------\n" +
+		"# Do not modify or remove the line above.\n" +
+		"diff --git a/login.go b/login.go\n" +
+		"+// TODO: wip\n"
+	assert.Empty(t, p.Check(buffer))
+
+	violations := p.Check("feat: add login\n\nStill wip\n" + buffer[len("feat: add login\n\n"):])
+	require.Len(t, violations, 1)
+	assert.Equal(t, "forbidden-word", violations[0].Rule)
+}
+
+func TestRequireConventional(t *testing.T) {
+	p := RequireConventional(nil)
+	assert.Empty(t, p.Check("fix: handle empty diffs"))
+	require.Len(t, p.Check("Update dependencies"), 1)
+	assert.Equal(t, "type", p.Check("Update dependencies")[0].Rule)
+
+	// The repository's own types and other rules are kept
+	team := &Policy{Types: []string{"feat"}, MaxSubjectLength: 10}
+	p = RequireConventional(team)
+	assert.Equal(t, []string{"feat"}, p.Types)
+	assert.Equal(t, 10, p.MaxSubjectLength)
+
+	team = &Policy{MaxSubjectLength: 10}
+	p = RequireConventional(team)
+	assert.Equal(t, ConventionalTypes, p.Types)
+	assert.Empty(t, team.Types, "the team policy is left unchanged")
+}
+
+func TestDiscover(t *testing.T) {
+	tempDir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(tempDir, ".git"), 0o755))
+	subDir := filepath.Join(tempDir, "internal", "cli")
+	require.NoError(t, os.MkdirAll(subDir, 0o755))
+
+	content := `types = ["feat", "fix"]
+max_subject_length = 72
+required_trailers = ["Refs"]`
+	require.NoError(t, os.WriteFile(filepath.Join(tempDir, FileName), []byte(content), 0o644))
+
+	p, err := Discover(subDir)
+	require.NoError(t, err)
+	require.NotNil(t, p)
+	assert.Equal(t, []string{"feat", "fix"}, p.Types)
+	assert.Equal(t, 72, p.MaxSubjectLength)
+	assert.Equal(t, []string{"Refs"}, p.RequiredTrailers)
+}
+
+func TestDiscover_NoPolicy(t *testing.T) {
+	tempDir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(tempDir, ".git"), 0o755))
+
+	p, err := Discover(tempDir)
+	require.NoError(t, err)
+	assert.Nil(t, p)
+}
+
+func TestFeedback(t *testing.T) {
+	feedback := Feedback("update stuff", []Violation{{Rule: "type", Message: "subject must start with one of: feat, fix"}})
+
+	assert.Contains(t, feedback, "update stuff")
+	assert.Contains(t, feedback, "- type: subject must start with one of: feat, fix")
+}
@@ -0,0 +1,258 @@
+package pr
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// azureAPIVersion is the Azure DevOps REST API version used for requests
+const azureAPIVersion = "7.0"
+
+var (
+	// azureWorkItemPattern matches work item mentions such as AB#123 in commit subjects
+	azureWorkItemPattern = regexp.MustCompile(`\bAB#([0-9]+)\b`)
+	// azureBranchWorkItemPattern matches work item ids in branch names such as
+	// feature/123-add-x or bugfix/wi-123
+	azureBranchWorkItemPattern = regexp.MustCompile(`(?i)(?:^|/)(?:wi-?)?([0-9]+)(?:[-_/]|$)`)
+)
+
+// AzureDevOpsPublisher creates or updates pull requests in Azure Repos and links
+// them to work items
+type AzureDevOpsPublisher struct {
+	baseURL string
+	project string
+	repo    string
+	token   string
+	client  *http.Client
+}
+
+// azurePullRequest is the subset of the Azure DevOps pull request resource used here
+type azurePullRequest struct {
+	PullRequestID int `json:"pullRequestId"`
+	Repository    struct {
+		ID      string `json:"id"`
+		WebURL  string `json:"webUrl"`
+		Project struct {
+			ID string `json:"id"`
+		} `json:"project"`
+	} `json:"repository"`
+}
+
+// AzureDevOpsTarget derives the organization base URL, project and repository
+// from a remote host and path. Supported forms are dev.azure.com/org/project/_git/repo,
+// ssh.dev.azure.com:v3/org/project/repo and org.visualstudio.com/project/_git/repo.
+func AzureDevOpsTarget(host, path string) (baseURL, project, repo string, err error) {
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+
+	switch {
+	case host == "dev.azure.com" && len(parts) == 4 && parts[2] == "_git":
+		return "https://dev.azure.com/" + parts[0], parts[1], parts[3], nil
+	case host == "ssh.dev.azure.com" && len(parts) == 4 && parts[0] == "v3":
+		return "https://dev.azure.com/" + parts[1], parts[2], parts[3], nil
+	case strings.HasSuffix(host, ".visualstudio.com"):
+		// The collection segment (DefaultCollection) is optional
+		if len(parts) >= 3 && parts[len(parts)-2] == "_git" {
+			return "https://" + host, parts[len(parts)-3], parts[len(parts)-1], nil
+		}
+	}
+
+	return "", "", "", fmt.Errorf("unsupported Azure DevOps remote: %s/%s", host, path)
+}
+
+// ExtractWorkItems returns the work item ids referenced by a branch name or by
+// AB#<id> mentions in commit subjects, without duplicates
+func ExtractWorkItems(branch string, subjects []string) []string {
+	seen := map[string]bool{}
+	var ids []string
+	add := func(id string) {
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+
+	if m := azureBranchWorkItemPattern.FindStringSubmatch(branch); m != nil {
+		add(m[1])
+	}
+	for _, subject := range subjects {
+		for _, m := range azureWorkItemPattern.FindAllStringSubmatch(subject, -1) {
+			add(m[1])
+		}
+	}
+
+	return ids
+}
+
+// NewAzureDevOpsPublisher creates a publisher for a repository. baseURL is the
+// organization URL, e.g. https://dev.azure.com/contoso, and token is a personal
+// access token.
+func NewAzureDevOpsPublisher(baseURL, project, repo, token string, timeout time.Duration) *AzureDevOpsPublisher {
+	return &AzureDevOpsPublisher{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		project: project,
+		repo:    repo,
+		token:   token,
+		client:  &http.Client{Timeout: timeout},
+	}
+}
+
+// Name returns the publisher name
+func (p *AzureDevOpsPublisher) Name() string {
+	return PublisherAzure
+}
+
+// Publish updates the active pull request for the source branch, or creates
+// one when none exists, links the requested work items and returns its URL
+func (p *AzureDevOpsPublisher) Publish(req *Request) (string, error) {
+	if req.Head == "" {
+		return "", fmt.Errorf("source branch is required")
+	}
+	sourceRef := "refs/heads/" + req.Head
+
+	existing, err := p.findPullRequest(sourceRef)
+	if err != nil {
+		return "", err
+	}
+
+	var result azurePullRequest
+	if existing != nil {
+		update := map[string]interface{}{
+			"title":       req.Title,
+			"description": req.Body,
+		}
+		endpoint := fmt.Sprintf("/pullrequests/%d", existing.PullRequestID)
+		if err := p.do("PATCH", p.repoURL(endpoint, nil), update, &result); err != nil {
+			return "", err
+		}
+		if err := p.linkWorkItems(&result, req.WorkItems); err != nil {
+			return "", err
+		}
+	} else {
+		if req.Base == "" {
+			return "", fmt.Errorf("target branch is required to create a pull request")
+		}
+		workItemRefs := make([]map[string]string, 0, len(req.WorkItems))
+		for _, id := range req.WorkItems {
+			workItemRefs = append(workItemRefs, map[string]string{"id": id})
+		}
+		create := map[string]interface{}{
+			"sourceRefName": sourceRef,
+			"targetRefName": "refs/heads/" + req.Base,
+			"title":         req.Title,
+			"description":   req.Body,
+			"isDraft":       req.Draft,
+			"workItemRefs":  workItemRefs,
+		}
+		if err := p.do("POST", p.repoURL("/pullrequests", nil), create, &result); err != nil {
+			return "", err
+		}
+	}
+
+	return fmt.Sprintf("%s/pullrequest/%d", result.Repository.WebURL, result.PullRequestID), nil
+}
+
+// findPullRequest returns the active pull request for a source ref, if any
+func (p *AzureDevOpsPublisher) findPullRequest(sourceRef string) (*azurePullRequest, error) {
+	var list struct {
+		Value []azurePullRequest `json:"value"`
+	}
+	query := url.Values{
+		"searchCriteria.sourceRefName": {sourceRef},
+		"searchCriteria.status":        {"active"},
+	}
+	if err := p.do("GET", p.repoURL("/pullrequests", query), nil, &list); err != nil {
+		return nil, err
+	}
+	if len(list.Value) == 0 {
+		return nil, nil
+	}
+	return &list.Value[0], nil
+}
+
+// linkWorkItems adds an artifact link to the pull request on each work item
+func (p *AzureDevOpsPublisher) linkWorkItems(pr *azurePullRequest, workItems []string) error {
+	artifact := fmt.Sprintf("vstfs:///Git/PullRequestId/%s%%2F%s%%2F%d",
+		pr.Repository.Project.ID, pr.Repository.ID, pr.PullRequestID)
+
+	for _, id := range workItems {
+		patch := []map[string]interface{}{{
+			"op":   "add",
+			"path": "/relations/-",
+			"value": map[string]interface{}{
+				"rel":        "ArtifactLink",
+				"url":        artifact,
+				"attributes": map[string]string{"name": "Pull Request"},
+			},
+		}}
+		endpoint := fmt.Sprintf("%s/_apis/wit/workitems/%s?api-version=%s", p.baseURL, url.PathEscape(id), azureAPIVersion)
+		var ignored json.RawMessage
+		if err := p.do("PATCH", endpoint, patch, &ignored); err != nil {
+			// The link already exists when the pull request was linked before
+			if strings.Contains(err.Error(), "already exists") {
+				continue
+			}
+			return fmt.Errorf("failed to link work item %s: %w", id, err)
+		}
+	}
+	return nil
+}
+
+// repoURL builds a URL below the repository API endpoint
+func (p *AzureDevOpsPublisher) repoURL(endpoint string, query url.Values) string {
+	if query == nil {
+		query = url.Values{}
+	}
+	query.Set("api-version", azureAPIVersion)
+	return fmt.Sprintf("%s/%s/_apis/git/repositories/%s%s?%s",
+		p.baseURL, url.PathEscape(p.project), url.PathEscape(p.repo), endpoint, query.Encode())
+}
+
+// do sends an authenticated request and decodes the JSON response
+func (p *AzureDevOpsPublisher) do(method, reqURL string, body, out interface{}) error {
+	var reqBody io.Reader = http.NoBody
+	if body != nil {
+		jsonData, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("failed to marshal request: %w", err)
+		}
+		reqBody = bytes.NewBuffer(jsonData)
+	}
+
+	req, err := http.NewRequestWithContext(context.Background(), method, reqURL, reqBody)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	contentType := "application/json"
+	if strings.Contains(reqURL, "/_apis/wit/") {
+		contentType = "application/json-patch+json"
+	}
+	req.Header.Set("Content-Type", contentType)
+	// Personal access tokens use Basic auth with an empty user name
+	req.SetBasicAuth("", p.token)
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to make request to Azure DevOps: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		respBody, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("azure DevOps API error (status %d): %s", resp.StatusCode, string(respBody))
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode Azure DevOps response: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,112 @@
+package pr
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAzureDevOpsTarget(t *testing.T) {
+	tests := []struct {
+		host    string
+		path    string
+		baseURL string
+		project string
+		repo    string
+	}{
+		{"dev.azure.com", "contoso/Web/_git/portal", "https://dev.azure.com/contoso", "Web", "portal"},
+		{"ssh.dev.azure.com", "v3/contoso/Web/portal", "https://dev.azure.com/contoso", "Web", "portal"},
+		{"contoso.visualstudio.com", "DefaultCollection/Web/_git/portal", "https://contoso.visualstudio.com", "Web", "portal"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.host, func(t *testing.T) {
+			baseURL, project, repo, err := AzureDevOpsTarget(tt.host, tt.path)
+			require.NoError(t, err)
+			assert.Equal(t, tt.baseURL, baseURL)
+			assert.Equal(t, tt.project, project)
+			assert.Equal(t, tt.repo, repo)
+		})
+	}
+
+	_, _, _, err := AzureDevOpsTarget("github.com", "owner/repo")
+	assert.Error(t, err)
+}
+
+func TestExtractWorkItems(t *testing.T) {
+	ids := ExtractWorkItems("feature/123-add-login", []string{"feat: add login AB#456", "fix: typo AB#123"})
+	assert.Equal(t, []string{"123", "456"}, ids)
+
+	assert.Empty(t, ExtractWorkItems("main", []string{"chore: tidy"}))
+}
+
+func TestAzureDevOpsPublisher_Create(t *testing.T) {
+	var created map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, pass, ok := r.BasicAuth()
+		assert.True(t, ok)
+		assert.Equal(t, "", user)
+		assert.Equal(t, "ado-pat", pass)
+		assert.Equal(t, "/contoso/Web/_apis/git/repositories/portal/pullrequests", r.URL.Path)
+		assert.Equal(t, "7.0", r.URL.Query().Get("api-version"))
+
+		w.Header().Set("Content-Type", "application/json")
+		switch r.Method {
+		case "GET":
+			assert.Equal(t, "refs/heads/feature", r.URL.Query().Get("searchCriteria.sourceRefName"))
+			w.Write([]byte(`{"value": []}`))
+		case "POST":
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&created))
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"pullRequestId": 9, "repository": {"id": "r1", "webUrl": "https://dev.azure.com/contoso/Web/_git/portal", "project": {"id": "p1"}}}`))
+		default:
+			t.Fatalf("unexpected method %s", r.Method)
+		}
+	}))
+	defer server.Close()
+
+	publisher := NewAzureDevOpsPublisher(server.URL+"/contoso", "Web", "portal", "ado-pat", 5*time.Second)
+	url, err := publisher.Publish(&Request{Title: "Add X", Body: "Body", Base: "main", Head: "feature", WorkItems: []string{"123"}})
+	require.NoError(t, err)
+
+	assert.Equal(t, "https://dev.azure.com/contoso/Web/_git/portal/pullrequest/9", url)
+	assert.Equal(t, "refs/heads/main", created["targetRefName"])
+	assert.Equal(t, []interface{}{map[string]interface{}{"id": "123"}}, created["workItemRefs"])
+}
+
+func TestAzureDevOpsPublisher_UpdateAndLink(t *testing.T) {
+	var linked bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.Method == "GET":
+			w.Write([]byte(`{"value": [{"pullRequestId": 4}]}`))
+		case r.Method == "PATCH" && strings.Contains(r.URL.Path, "/pullrequests/4"):
+			w.Write([]byte(`{"pullRequestId": 4, "repository": {"id": "r1", "webUrl": "https://ado/portal", "project": {"id": "p1"}}}`))
+		case r.Method == "PATCH" && strings.Contains(r.URL.Path, "/_apis/wit/workitems/123"):
+			assert.Equal(t, "application/json-patch+json", r.Header.Get("Content-Type"))
+			var patch []map[string]interface{}
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&patch))
+			value := patch[0]["value"].(map[string]interface{})
+			assert.Equal(t, "vstfs:///Git/PullRequestId/p1%2Fr1%2F4", value["url"])
+			linked = true
+			w.Write([]byte(`{"id": 123}`))
+		default:
+			t.Fatalf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+	}))
+	defer server.Close()
+
+	publisher := NewAzureDevOpsPublisher(server.URL+"/contoso", "Web", "portal", "ado-pat", 5*time.Second)
+	url, err := publisher.Publish(&Request{Title: "Add X", Body: "Body", Head: "feature", WorkItems: []string{"123"}})
+	require.NoError(t, err)
+
+	assert.Equal(t, "https://ado/portal/pullrequest/4", url)
+	assert.True(t, linked)
+}
@@ -0,0 +1,105 @@
+package pr
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/nseba/commit-ai/internal/interrupt"
+)
+
+// runCommand executes a CLI tool in dir and returns its trimmed stdout. It is a
+// variable so tests can replace it.
+var runCommand = func(dir, name string, args ...string) (string, error) {
+	if _, err := exec.LookPath(name); err != nil {
+		return "", fmt.Errorf("%s CLI not found in PATH: %w", name, err)
+	}
+
+	cmd := exec.Command(name, args...) // #nosec G204 -- name is gh or glab, args are built by this package
+	cmd.Dir = dir
+	cmd.Stdin = os.Stdin
+	cmd.Stderr = os.Stderr
+	var stdout bytes.Buffer
+	cmd.Stdout = &stdout
+
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("%s failed: %w", name, err)
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
+
+// CLIPublisher delegates pull request creation to the gh or glab CLI, reusing
+// their existing authentication
+type CLIPublisher struct {
+	tool string
+	dir  string
+}
+
+// NewCLIPublisher creates a publisher for the given tool (gh or glab) that
+// runs in the repository directory dir
+func NewCLIPublisher(tool, dir string) (*CLIPublisher, error) {
+	if tool != PublisherGH && tool != PublisherGlab {
+		return nil, fmt.Errorf("unsupported PR CLI: %s", tool)
+	}
+	return &CLIPublisher{tool: tool, dir: dir}, nil
+}
+
+// Name returns the tool name
+func (p *CLIPublisher) Name() string {
+	return p.tool
+}
+
+// Publish creates the pull request (or merge request) through the CLI
+func (p *CLIPublisher) Publish(req *Request) (string, error) {
+	var args []string
+
+	switch p.tool {
+	case PublisherGH:
+		// Pass the body through a file to avoid argument length and quoting issues
+		bodyFile, err := os.CreateTemp("", "commit-ai-pr-*.md")
+		if err != nil {
+			return "", fmt.Errorf("failed to create temporary file: %w", err)
+		}
+		defer os.Remove(bodyFile.Name())
+		defer interrupt.OnInterrupt(func() { _ = os.Remove(bodyFile.Name()) })()
+
+		if _, err := bodyFile.WriteString(req.Body); err != nil {
+			bodyFile.Close()
+			return "", fmt.Errorf("failed to write temporary file: %w", err)
+		}
+		if err := bodyFile.Close(); err != nil {
+			return "", fmt.Errorf("failed to close temporary file: %w", err)
+		}
+
+		args = []string{"pr", "create", "--title", req.Title, "--body-file", bodyFile.Name()}
+		if req.Base != "" {
+			args = append(args, "--base", req.Base)
+		}
+		if req.Head != "" {
+			args = append(args, "--head", req.Head)
+		}
+	case PublisherGlab:
+		args = []string{"mr", "create", "--yes", "--title", req.Title, "--description", req.Body}
+		if req.Base != "" {
+			args = append(args, "--target-branch", req.Base)
+		}
+		if req.Head != "" {
+			args = append(args, "--source-branch", req.Head)
+		}
+	}
+
+	if req.Draft {
+		args = append(args, "--draft")
+	}
+
+	out, err := runCommand(p.dir, p.tool, args...)
+	if err != nil {
+		return "", err
+	}
+
+	// Both tools print the URL of the created request as the last line
+	lines := strings.Split(out, "\n")
+	return strings.TrimSpace(lines[len(lines)-1]), nil
+}
@@ -0,0 +1,140 @@
+package pr
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// GitLabPublisher creates or updates merge requests through the GitLab REST API
+type GitLabPublisher struct {
+	baseURL string
+	project string
+	token   string
+	client  *http.Client
+}
+
+// gitlabMergeRequest is the subset of the GitLab merge request resource used here
+type gitlabMergeRequest struct {
+	IID    int    `json:"iid"`
+	WebURL string `json:"web_url"`
+}
+
+// NewGitLabPublisher creates a publisher for the project identified by its full
+// path (group/subgroup/project) on the instance at baseURL
+func NewGitLabPublisher(baseURL, project, token string, timeout time.Duration) *GitLabPublisher {
+	return &GitLabPublisher{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		project: project,
+		token:   token,
+		client:  &http.Client{Timeout: timeout},
+	}
+}
+
+// Name returns the publisher name
+func (p *GitLabPublisher) Name() string {
+	return PublisherGitLab
+}
+
+// Publish updates the open merge request for the source branch, or creates one
+// when none exists, and returns its URL
+func (p *GitLabPublisher) Publish(req *Request) (string, error) {
+	if req.Head == "" {
+		return "", fmt.Errorf("source branch is required")
+	}
+
+	title := req.Title
+	if req.Draft && !strings.HasPrefix(title, "Draft:") {
+		title = "Draft: " + title
+	}
+
+	existing, err := p.findMergeRequest(req.Head)
+	if err != nil {
+		return "", err
+	}
+
+	var mr gitlabMergeRequest
+	if existing != nil {
+		update := map[string]interface{}{
+			"title":       title,
+			"description": req.Body,
+		}
+		if req.Base != "" {
+			update["target_branch"] = req.Base
+		}
+		err = p.do("PUT", fmt.Sprintf("/merge_requests/%d", existing.IID), update, &mr)
+	} else {
+		if req.Base == "" {
+			return "", fmt.Errorf("target branch is required to create a merge request")
+		}
+		err = p.do("POST", "/merge_requests", map[string]interface{}{
+			"source_branch": req.Head,
+			"target_branch": req.Base,
+			"title":         title,
+			"description":   req.Body,
+		}, &mr)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	return mr.WebURL, nil
+}
+
+// findMergeRequest returns the open merge request for a source branch, if any
+func (p *GitLabPublisher) findMergeRequest(sourceBranch string) (*gitlabMergeRequest, error) {
+	var mrs []gitlabMergeRequest
+	query := "/merge_requests?state=opened&source_branch=" + url.QueryEscape(sourceBranch)
+	if err := p.do("GET", query, nil, &mrs); err != nil {
+		return nil, err
+	}
+	if len(mrs) == 0 {
+		return nil, nil
+	}
+	return &mrs[0], nil
+}
+
+// do sends a request relative to the project API endpoint and decodes the response
+func (p *GitLabPublisher) do(method, endpoint string, body, out interface{}) error {
+	reqURL := fmt.Sprintf("%s/api/v4/projects/%s%s", p.baseURL, url.PathEscape(p.project), endpoint)
+
+	var reqBody io.Reader = http.NoBody
+	if body != nil {
+		jsonData, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("failed to marshal request: %w", err)
+		}
+		reqBody = bytes.NewBuffer(jsonData)
+	}
+
+	req, err := http.NewRequestWithContext(context.Background(), method, reqURL, reqBody)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("PRIVATE-TOKEN", p.token)
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to make request to GitLab: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		respBody, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("GitLab API error (status %d): %s", resp.StatusCode, string(respBody))
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode GitLab response: %w", err)
+	}
+
+	return nil
+}
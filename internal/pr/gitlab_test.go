@@ -0,0 +1,83 @@
+package pr
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGitLabPublisher_Create(t *testing.T) {
+	var created map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "gl-token", r.Header.Get("PRIVATE-TOKEN"))
+		assert.Equal(t, "/api/v4/projects/group%2Fproject/merge_requests", r.URL.EscapedPath())
+
+		w.Header().Set("Content-Type", "application/json")
+		switch r.Method {
+		case "GET":
+			assert.Equal(t, "feature", r.URL.Query().Get("source_branch"))
+			w.Write([]byte(`[]`))
+		case "POST":
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&created))
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"iid": 5, "web_url": "https://gitlab.example.com/group/project/-/merge_requests/5"}`))
+		default:
+			t.Fatalf("unexpected method %s", r.Method)
+		}
+	}))
+	defer server.Close()
+
+	publisher := NewGitLabPublisher(server.URL, "group/project", "gl-token", 5*time.Second)
+	url, err := publisher.Publish(&Request{Title: "Add X", Body: "Body", Base: "main", Head: "feature", Draft: true})
+	require.NoError(t, err)
+
+	assert.Equal(t, "https://gitlab.example.com/group/project/-/merge_requests/5", url)
+	assert.Equal(t, "Draft: Add X", created["title"])
+	assert.Equal(t, "Body", created["description"])
+	assert.Equal(t, "main", created["target_branch"])
+	assert.Equal(t, "feature", created["source_branch"])
+}
+
+func TestGitLabPublisher_UpdateExisting(t *testing.T) {
+	var updated map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.Method {
+		case "GET":
+			w.Write([]byte(`[{"iid": 7, "web_url": "https://gitlab.example.com/mr/7"}]`))
+		case "PUT":
+			assert.Equal(t, "/api/v4/projects/group%2Fproject/merge_requests/7", r.URL.EscapedPath())
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&updated))
+			w.Write([]byte(`{"iid": 7, "web_url": "https://gitlab.example.com/mr/7"}`))
+		default:
+			t.Fatalf("unexpected method %s", r.Method)
+		}
+	}))
+	defer server.Close()
+
+	publisher := NewGitLabPublisher(server.URL, "group/project", "gl-token", 5*time.Second)
+	url, err := publisher.Publish(&Request{Title: "Add X", Body: "New body", Head: "feature"})
+	require.NoError(t, err)
+
+	assert.Equal(t, "https://gitlab.example.com/mr/7", url)
+	assert.Equal(t, "Add X", updated["title"])
+	assert.Equal(t, "New body", updated["description"])
+}
+
+func TestGitLabPublisher_APIError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(`{"message": "401 Unauthorized"}`))
+	}))
+	defer server.Close()
+
+	publisher := NewGitLabPublisher(server.URL, "group/project", "bad", 5*time.Second)
+	_, err := publisher.Publish(&Request{Title: "Add X", Base: "main", Head: "feature"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "status 401")
+}
@@ -0,0 +1,48 @@
+package pr
+
+import (
+	"strings"
+)
+
+// Supported publisher names
+const (
+	PublisherGH     = "gh"
+	PublisherGlab   = "glab"
+	PublisherGitLab = "gitlab"
+	PublisherAzure  = "azure"
+)
+
+// Request holds the content and target branches of a pull request
+type Request struct {
+	Title string
+	Body  string
+	Base  string
+	Head  string
+	Draft bool
+	// WorkItems lists tracker work items to link, used by Azure DevOps
+	WorkItems []string
+}
+
+// Publisher creates pull requests on a code hosting service
+type Publisher interface {
+	// Name returns the publisher name used in flags and messages
+	Name() string
+	// Publish creates the pull request and returns its URL
+	Publish(req *Request) (string, error)
+}
+
+// DetectPublisher returns the publisher best suited for a remote host, or an
+// empty string when the host is not recognized
+func DetectPublisher(host string) string {
+	host = strings.ToLower(host)
+	switch {
+	case strings.Contains(host, "github"):
+		return PublisherGH
+	case strings.Contains(host, "gitlab"):
+		return PublisherGlab
+	case strings.HasSuffix(host, "dev.azure.com"), strings.HasSuffix(host, ".visualstudio.com"):
+		return PublisherAzure
+	default:
+		return ""
+	}
+}
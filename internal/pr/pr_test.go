@@ -0,0 +1,76 @@
+package pr
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func stubRunCommand(t *testing.T, fn func(dir, name string, args ...string) (string, error)) {
+	original := runCommand
+	runCommand = fn
+	t.Cleanup(func() { runCommand = original })
+}
+
+func TestDetectPublisher(t *testing.T) {
+	assert.Equal(t, PublisherGH, DetectPublisher("github.com"))
+	assert.Equal(t, PublisherGlab, DetectPublisher("gitlab.example.com"))
+	assert.Equal(t, PublisherAzure, DetectPublisher("dev.azure.com"))
+	assert.Equal(t, PublisherAzure, DetectPublisher("ssh.dev.azure.com"))
+	assert.Equal(t, "", DetectPublisher("bitbucket.org"))
+}
+
+func TestCLIPublisher_GH(t *testing.T) {
+	var gotArgs []string
+	var gotBody string
+	stubRunCommand(t, func(dir, name string, args ...string) (string, error) {
+		assert.Equal(t, "gh", name)
+		assert.Equal(t, "/repo", dir)
+		gotArgs = args
+		for i, arg := range args {
+			if arg == "--body-file" {
+				content, err := os.ReadFile(args[i+1])
+				require.NoError(t, err)
+				gotBody = string(content)
+			}
+		}
+		return "Creating pull request\nhttps://github.com/o/r/pull/1", nil
+	})
+
+	publisher, err := NewCLIPublisher(PublisherGH, "/repo")
+	require.NoError(t, err)
+
+	url, err := publisher.Publish(&Request{Title: "Add X", Body: "Body", Base: "main", Head: "feature", Draft: true})
+	require.NoError(t, err)
+
+	assert.Equal(t, "https://github.com/o/r/pull/1", url)
+	assert.Equal(t, "Body", gotBody)
+	assert.Contains(t, gotArgs, "--draft")
+	assert.Contains(t, gotArgs, "main")
+	assert.Contains(t, gotArgs, "feature")
+}
+
+func TestCLIPublisher_Glab(t *testing.T) {
+	var gotArgs []string
+	stubRunCommand(t, func(dir, name string, args ...string) (string, error) {
+		assert.Equal(t, "glab", name)
+		gotArgs = args
+		return "https://gitlab.com/o/r/-/merge_requests/3", nil
+	})
+
+	publisher, err := NewCLIPublisher(PublisherGlab, "/repo")
+	require.NoError(t, err)
+
+	url, err := publisher.Publish(&Request{Title: "Add X", Body: "Body", Base: "main"})
+	require.NoError(t, err)
+
+	assert.Equal(t, "https://gitlab.com/o/r/-/merge_requests/3", url)
+	assert.Equal(t, []string{"mr", "create", "--yes", "--title", "Add X", "--description", "Body", "--target-branch", "main"}, gotArgs)
+}
+
+func TestNewCLIPublisher_Unsupported(t *testing.T) {
+	_, err := NewCLIPublisher("hub", "/repo")
+	assert.Error(t, err)
+}
@@ -0,0 +1,143 @@
+// Package promptguard defends prompts against diffs carrying adversarial
+// text: it encloses the diff in delimiters with a random id that the diff
+// can't forge, and finds or strips lines that read like instructions to the
+// model ("ignore previous instructions", chat control tokens, ...).
+package promptguard
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Delimiter prefixes; templates tell the model that the text between them is
+// data
+const (
+	beginPrefix = "<<<BEGIN UNTRUSTED DIFF "
+	endPrefix   = "<<<END UNTRUSTED DIFF "
+)
+
+// StrippedLine replaces the content of a stripped line
+const StrippedLine = "[line removed by commit-ai: possible prompt injection]"
+
+// Finding is a diff line that looks like an instruction to the model
+type Finding struct {
+	// Line is the 1-based line number in the diff
+	Line int
+	// Text is the line, trimmed
+	Text string
+	// Reason names the kind of injection the line resembles
+	Reason string
+}
+
+// String formats the finding for warnings
+func (f Finding) String() string {
+	text := f.Text
+	if len(text) > 80 {
+		text = text[:77] + "..."
+	}
+	return fmt.Sprintf("line %d (%s): %s", f.Line, f.Reason, text)
+}
+
+// pattern is one kind of instruction-like text
+type pattern struct {
+	reason string
+	re     *regexp.Regexp
+}
+
+// patterns match the phrasing of known injection payloads. They are kept
+// specific, since every diff line is checked and ordinary code and prose
+// must not match.
+var patterns = []pattern{
+	{"instruction override", regexp.MustCompile(`(?i)\b(ignore|disregard|forget|override)\s+(all\s+|any\s+)?(of\s+)?(the\s+|your\s+)?(previous|prior|above|preceding|earlier|system|original)\s+(instructions?|prompts?|rules|directions|context)`)},
+	{"role reassignment", regexp.MustCompile(`(?i)\byou\s+are\s+now\s+(a|an|in|the|my)\b|\bfrom\s+now\s+on,?\s+you\b`)},
+	{"injected instructions", regexp.MustCompile(`(?i)\b(new|updated|real|actual|hidden)\s+instructions?\s*:`)},
+	{"prompt extraction", regexp.MustCompile(`(?i)\b(reveal|print|show|repeat|leak)\s+(me\s+)?(your\s+(system\s+)?(prompt|instructions)|the\s+system\s+prompt)\b`)},
+	{"chat control token", regexp.MustCompile(`<\|(im_start|im_end|system|user|assistant|start_header_id|end_header_id|eot_id)\|>|\[/?INST\]|<</?SYS>>`)},
+	{"model address", regexp.MustCompile(`(?i)\b(AI|assistant|language model|LLM|chatbot)\s*[,:]\s*(please\s+)?(ignore|disregard|write|output|respond|reply|say)\b`)},
+	{"output dictation", regexp.MustCompile(`(?i)\bcommit\s+message\s+(must|should|shall)\s+(be|say|read)\s*[:"'\x60]`)},
+	{"delimiter forgery", regexp.MustCompile(`(?i)UNTRUSTED\s+DIFF`)},
+}
+
+// Delimit encloses diff in begin and end lines with a random id. The id is
+// chosen so it doesn't occur in the diff, so a diff can't close the block
+// early and continue with text outside of it.
+func Delimit(diff string) string {
+	id := newID()
+	for strings.Contains(diff, id) {
+		id = newID()
+	}
+	return beginPrefix + id + ">>>\n" + strings.TrimRight(diff, "\n") + "\n" + endPrefix + id + ">>>"
+}
+
+// delimiterLine matches the begin and end lines written by Delimit
+var delimiterLine = regexp.MustCompile("(" + regexp.QuoteMeta(beginPrefix) + "|" + regexp.QuoteMeta(endPrefix) + ")[0-9a-f]{16}>>>")
+
+// Normalize replaces the random ids of the delimiters in prompt with a fixed
+// one, so the same diff always gives the same prompt, e.g. to look up a
+// recorded response
+func Normalize(prompt string) string {
+	return delimiterLine.ReplaceAllString(prompt, "${1}0000000000000000>>>")
+}
+
+// newID returns a random hex id
+func newID() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// Scan returns the diff lines that look like instructions to the model.
+// The file headers of a git diff, up to the first hunk of each file, and
+// hunk headers are skipped.
+func Scan(diff string) []Finding {
+	var findings []Finding
+	inHeader := false
+	for i, line := range strings.Split(diff, "\n") {
+		switch {
+		case strings.HasPrefix(line, "diff --git "):
+			inHeader = true
+			continue
+		case strings.HasPrefix(line, "@@ "):
+			inHeader = false
+			continue
+		case inHeader:
+			continue
+		}
+		if reason := match(line); reason != "" {
+			findings = append(findings, Finding{Line: i + 1, Text: strings.TrimSpace(line), Reason: reason})
+		}
+	}
+	return findings
+}
+
+// Strip replaces the content of the lines Scan finds with StrippedLine,
+// keeping their diff marker so the diff stays well-formed
+func Strip(diff string) (string, []Finding) {
+	findings := Scan(diff)
+	if len(findings) == 0 {
+		return diff, nil
+	}
+	lines := strings.Split(diff, "\n")
+	for _, f := range findings {
+		line := lines[f.Line-1]
+		marker := ""
+		if line != "" && strings.ContainsRune("+- ", rune(line[0])) {
+			marker = line[:1]
+		}
+		lines[f.Line-1] = marker + StrippedLine
+	}
+	return strings.Join(lines, "\n"), findings
+}
+
+// match returns the reason of the first pattern that matches line
+func match(line string) string {
+	for _, p := range patterns {
+		if p.re.MatchString(line) {
+			return p.reason
+		}
+	}
+	return ""
+}
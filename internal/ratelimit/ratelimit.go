@@ -0,0 +1,190 @@
+// Package ratelimit caps the requests and tokens sent to a provider per
+// minute. The usage of the last minute is kept in a ledger file guarded by a
+// lock file, so every commit-ai process sharing an API key on the machine
+// draws from the same budget.
+package ratelimit
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/nseba/commit-ai/internal/filelock"
+)
+
+const (
+	// window is the period the limits apply to
+	window = time.Minute
+)
+
+// Limits are the per-minute caps; zero disables a cap
+type Limits struct {
+	RequestsPerMinute int
+	TokensPerMinute   int
+}
+
+// Enabled reports whether any cap is set
+func (l Limits) Enabled() bool {
+	return l.RequestsPerMinute > 0 || l.TokensPerMinute > 0
+}
+
+// entry is one request recorded in the ledger
+type entry struct {
+	Time   time.Time `json:"time"`
+	Tokens int       `json:"tokens"`
+}
+
+// Limiter admits requests within the limits of a shared ledger file
+type Limiter struct {
+	ledger  string
+	limits  Limits
+	maxWait time.Duration
+
+	// OnWait, when set, is called before sleeping for a free slot
+	OnWait func(time.Duration)
+
+	now   func() time.Time
+	sleep func(time.Duration)
+}
+
+// New creates a limiter recording usage in the ledger file. Wait gives up
+// once it would have to wait longer than maxWait in total.
+func New(ledger string, limits Limits, maxWait time.Duration) *Limiter {
+	return &Limiter{
+		ledger:  ledger,
+		limits:  limits,
+		maxWait: maxWait,
+		now:     time.Now,
+		sleep:   time.Sleep,
+	}
+}
+
+// Wait blocks until a request of the given estimated token count fits within
+// the limits and records it in the ledger. A single request larger than the
+// token cap is admitted once the window is empty.
+func (l *Limiter) Wait(tokens int) error {
+	var waited time.Duration
+	for {
+		delay, err := l.reserve(tokens)
+		if err != nil {
+			return err
+		}
+		if delay == 0 {
+			return nil
+		}
+
+		if waited+delay > l.maxWait {
+			return fmt.Errorf("rate limit reached: no capacity within %s", l.maxWait)
+		}
+		if l.OnWait != nil {
+			l.OnWait(delay)
+		}
+		l.sleep(delay)
+		waited += delay
+	}
+}
+
+// reserve records the request when it fits and otherwise returns how long to
+// wait before trying again
+func (l *Limiter) reserve(tokens int) (time.Duration, error) {
+	if err := os.MkdirAll(filepath.Dir(l.ledger), 0o750); err != nil {
+		return 0, fmt.Errorf("failed to create rate limit directory: %w", err)
+	}
+	unlock, err := filelock.Lock(l.ledger)
+	if err != nil {
+		return 0, fmt.Errorf("failed to lock rate limit ledger: %w", err)
+	}
+	defer unlock()
+
+	now := l.now()
+	entries, err := l.load(now)
+	if err != nil {
+		return 0, err
+	}
+
+	if delay := l.delay(entries, tokens, now); delay > 0 {
+		return delay, nil
+	}
+
+	entries = append(entries, entry{Time: now, Tokens: tokens})
+	return 0, l.save(entries)
+}
+
+// delay returns how long until the request fits, or zero when it fits now
+func (l *Limiter) delay(entries []entry, tokens int, now time.Time) time.Duration {
+	if len(entries) == 0 {
+		return 0
+	}
+
+	used := 0
+	for _, e := range entries {
+		used += e.Tokens
+	}
+
+	// Drop the oldest entries until the request fits; the delay is the time
+	// until the last dropped entry leaves the window
+	var until time.Time
+	requests := len(entries)
+	for _, e := range entries {
+		requestsOK := l.limits.RequestsPerMinute <= 0 || requests+1 <= l.limits.RequestsPerMinute
+		tokensOK := l.limits.TokensPerMinute <= 0 || used+tokens <= l.limits.TokensPerMinute || requests == 0
+		if requestsOK && tokensOK {
+			break
+		}
+		until = e.Time.Add(window)
+		requests--
+		used -= e.Tokens
+	}
+
+	if until.IsZero() || !until.After(now) {
+		return 0
+	}
+	return until.Sub(now)
+}
+
+// load reads the ledger entries still inside the window, oldest first
+func (l *Limiter) load(now time.Time) ([]entry, error) {
+	data, err := os.ReadFile(l.ledger)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read rate limit ledger: %w", err)
+	}
+
+	var entries []entry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		// A corrupt ledger only loses the last minute of usage
+		return nil, nil
+	}
+
+	cutoff := now.Add(-window)
+	recent := entries[:0]
+	for _, e := range entries {
+		if e.Time.After(cutoff) {
+			recent = append(recent, e)
+		}
+	}
+	return recent, nil
+}
+
+// save writes the ledger; callers hold the ledger lock
+func (l *Limiter) save(entries []entry) error {
+	data, err := json.Marshal(entries)
+	if err != nil {
+		return fmt.Errorf("failed to encode rate limit ledger: %w", err)
+	}
+	if err := os.WriteFile(l.ledger, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write rate limit ledger: %w", err)
+	}
+	return nil
+}
+
+// EstimateTokens roughly estimates the tokens of text at four characters per
+// token, the usual rule of thumb for English text and code
+func EstimateTokens(text string) int {
+	return len(text)/4 + 1
+}
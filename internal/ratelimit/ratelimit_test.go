@@ -0,0 +1,100 @@
+package ratelimit
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestLimiter returns a limiter on a fake clock that advances when the
+// limiter sleeps, along with the recorded sleeps
+func newTestLimiter(t *testing.T, ledger string, limits Limits) (*Limiter, *[]time.Duration) {
+	t.Helper()
+
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	var sleeps []time.Duration
+	l := New(ledger, limits, 5*time.Minute)
+	l.now = func() time.Time { return now }
+	l.sleep = func(d time.Duration) {
+		sleeps = append(sleeps, d)
+		now = now.Add(d)
+	}
+	return l, &sleeps
+}
+
+func TestWait_RequestsPerMinute(t *testing.T) {
+	ledger := filepath.Join(t.TempDir(), "ledger.json")
+	l, sleeps := newTestLimiter(t, ledger, Limits{RequestsPerMinute: 2})
+
+	require.NoError(t, l.Wait(10))
+	require.NoError(t, l.Wait(10))
+	assert.Empty(t, *sleeps)
+
+	// The third request waits for the first one to leave the window
+	require.NoError(t, l.Wait(10))
+	assert.Equal(t, []time.Duration{time.Minute}, *sleeps)
+}
+
+func TestWait_TokensPerMinute(t *testing.T) {
+	ledger := filepath.Join(t.TempDir(), "ledger.json")
+	l, sleeps := newTestLimiter(t, ledger, Limits{TokensPerMinute: 100})
+
+	require.NoError(t, l.Wait(60))
+	require.NoError(t, l.Wait(30))
+	assert.Empty(t, *sleeps)
+
+	require.NoError(t, l.Wait(20))
+	assert.Equal(t, []time.Duration{time.Minute}, *sleeps)
+
+	// A request above the cap is admitted once the window is empty
+	require.NoError(t, l.Wait(500))
+	assert.Len(t, *sleeps, 2)
+}
+
+func TestWait_SharedLedger(t *testing.T) {
+	ledger := filepath.Join(t.TempDir(), "ledger.json")
+	first, _ := newTestLimiter(t, ledger, Limits{RequestsPerMinute: 1})
+	second, sleeps := newTestLimiter(t, ledger, Limits{RequestsPerMinute: 1})
+
+	require.NoError(t, first.Wait(1))
+	require.NoError(t, second.Wait(1))
+	assert.Equal(t, []time.Duration{time.Minute}, *sleeps)
+}
+
+func TestWait_MaxWait(t *testing.T) {
+	ledger := filepath.Join(t.TempDir(), "ledger.json")
+	l, _ := newTestLimiter(t, ledger, Limits{RequestsPerMinute: 1})
+	l.maxWait = 10 * time.Second
+
+	var notified []time.Duration
+	l.OnWait = func(d time.Duration) { notified = append(notified, d) }
+
+	require.NoError(t, l.Wait(1))
+	err := l.Wait(1)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "rate limit reached")
+	assert.Empty(t, notified)
+}
+
+func TestWait_CorruptLedger(t *testing.T) {
+	ledger := filepath.Join(t.TempDir(), "ledger.json")
+	require.NoError(t, os.WriteFile(ledger, []byte("{not json"), 0o600))
+
+	l, sleeps := newTestLimiter(t, ledger, Limits{RequestsPerMinute: 1})
+	require.NoError(t, l.Wait(1))
+	assert.Empty(t, *sleeps)
+}
+
+func TestLimits_Enabled(t *testing.T) {
+	assert.False(t, Limits{}.Enabled())
+	assert.True(t, Limits{TokensPerMinute: 1000}.Enabled())
+}
+
+func TestEstimateTokens(t *testing.T) {
+	assert.Equal(t, 1, EstimateTokens(""))
+	assert.Equal(t, 26, EstimateTokens(string(make([]byte, 100))))
+}
@@ -0,0 +1,118 @@
+// Package replay keeps provider responses in a fixture file, so the replay
+// provider can answer prompts without network access or tokens, with the same
+// response every time. Fixtures are keyed by a hash of the prompt; prompts
+// themselves, and the diffs in them, aren't stored.
+package replay
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/nseba/commit-ai/internal/filelock"
+)
+
+// fileVersion is the version of the fixture file format
+const fileVersion = 1
+
+// ErrNotRecorded is returned by Lookup for a prompt without a fixture
+var ErrNotRecorded = errors.New("no response recorded for this prompt")
+
+// Fixture is a recorded response
+type Fixture struct {
+	// Key identifies the prompt, see Key
+	Key string `json:"key"`
+	// Provider and Model answered the prompt when it was recorded
+	Provider string `json:"provider"`
+	Model    string `json:"model"`
+	Response string `json:"response"`
+}
+
+// fixtureFile is the content of a fixture file
+type fixtureFile struct {
+	Version  int       `json:"version"`
+	Fixtures []Fixture `json:"fixtures"`
+}
+
+// Key returns the fixture key of a prompt
+func Key(prompt string) string {
+	return fmt.Sprintf("sha256:%x", sha256.Sum256([]byte(prompt)))
+}
+
+// Lookup returns the response recorded in file for prompt, or ErrNotRecorded
+func Lookup(file, prompt string) (string, error) {
+	f, err := load(file)
+	if err != nil {
+		return "", err
+	}
+	key := Key(prompt)
+	for _, fixture := range f.Fixtures {
+		if fixture.Key == key {
+			return fixture.Response, nil
+		}
+	}
+	return "", ErrNotRecorded
+}
+
+// Record saves fixture as the response to prompt in file, replacing an
+// earlier recording of the same prompt. The file and its directory are
+// created when missing.
+func Record(file, prompt string, fixture Fixture) error {
+	if err := os.MkdirAll(filepath.Dir(file), 0o750); err != nil {
+		return fmt.Errorf("failed to create fixture directory: %w", err)
+	}
+	unlock, err := filelock.Lock(file)
+	if err != nil {
+		return fmt.Errorf("failed to lock fixture file: %w", err)
+	}
+	defer unlock()
+
+	f, err := load(file)
+	if err != nil {
+		return err
+	}
+	fixture.Key = Key(prompt)
+	replaced := false
+	for i := range f.Fixtures {
+		if f.Fixtures[i].Key == fixture.Key {
+			f.Fixtures[i] = fixture
+			replaced = true
+		}
+	}
+	if !replaced {
+		f.Fixtures = append(f.Fixtures, fixture)
+	}
+
+	// Indented, so recordings checked into a repository diff readably
+	data, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode fixtures: %w", err)
+	}
+	// #nosec G306 -- fixtures are meant to be shared, e.g. in a repository
+	if err := os.WriteFile(file, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("failed to write fixture file: %w", err)
+	}
+	return nil
+}
+
+// load reads a fixture file; a missing file has no fixtures
+func load(file string) (fixtureFile, error) {
+	f := fixtureFile{Version: fileVersion}
+	data, err := os.ReadFile(file) // #nosec G304 -- path from the user's CAI_REPLAY_FIXTURES
+	if errors.Is(err, os.ErrNotExist) {
+		return f, nil
+	}
+	if err != nil {
+		return f, fmt.Errorf("failed to read fixture file: %w", err)
+	}
+	if err := json.Unmarshal(data, &f); err != nil {
+		return f, fmt.Errorf("failed to decode fixture file %s: %w", file, err)
+	}
+	if f.Version != fileVersion {
+		return f, fmt.Errorf("fixture file %s has unsupported version %d", file, f.Version)
+	}
+	return f, nil
+}
@@ -0,0 +1,51 @@
+package replay
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecordAndLookup(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "testdata", "fixtures.json")
+
+	_, err := Lookup(file, "prompt")
+	assert.ErrorIs(t, err, ErrNotRecorded)
+
+	require.NoError(t, Record(file, "prompt", Fixture{Provider: "ollama", Model: "llama3", Response: "feat: first"}))
+	require.NoError(t, Record(file, "other", Fixture{Provider: "ollama", Model: "llama3", Response: "fix: other"}))
+
+	response, err := Lookup(file, "prompt")
+	require.NoError(t, err)
+	assert.Equal(t, "feat: first", response)
+
+	// Recording a prompt again replaces its response
+	require.NoError(t, Record(file, "prompt", Fixture{Provider: "openai", Model: "gpt-4o", Response: "feat: second"}))
+	response, err = Lookup(file, "prompt")
+	require.NoError(t, err)
+	assert.Equal(t, "feat: second", response)
+
+	f, err := load(file)
+	require.NoError(t, err)
+	assert.Len(t, f.Fixtures, 2)
+	assert.Equal(t, Key("prompt"), f.Fixtures[0].Key)
+
+	data, err := os.ReadFile(file)
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), `"prompt"`, "prompts aren't stored")
+}
+
+func TestLookup_InvalidFile(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "fixtures.json")
+
+	require.NoError(t, os.WriteFile(file, []byte("{"), 0o600))
+	_, err := Lookup(file, "prompt")
+	assert.ErrorContains(t, err, "failed to decode fixture file")
+
+	require.NoError(t, os.WriteFile(file, []byte(`{"version": 2, "fixtures": []}`), 0o600))
+	_, err = Lookup(file, "prompt")
+	assert.ErrorContains(t, err, "unsupported version 2")
+}
@@ -0,0 +1,252 @@
+// Package serve exposes commit message generation over HTTP for editors and
+// tools on a shared development machine. Requests are authenticated with a
+// bearer token or by the permissions of a unix socket, may only name
+// repositories below allow-listed directories, and are bounded in size and
+// concurrency, so the service cannot be used to read arbitrary repositories.
+package serve
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// MaxCandidates bounds the messages a single request may ask for
+const MaxCandidates = 5
+
+// Request asks for commit messages describing the changes of a repository
+type Request struct {
+	// Repo is the absolute path of the repository, or of a directory in
+	// it to limit the diff to
+	Repo string `json:"repo"`
+	// Hint is extra guidance for the model
+	Hint string `json:"hint,omitempty"`
+	// Candidates is the number of messages to generate, 1 when unset
+	Candidates int `json:"candidates,omitempty"`
+	// Continue is the continuation token of an earlier response
+	Continue string `json:"continue,omitempty"`
+}
+
+// GenerateFunc generates the response to a request whose Repo was resolved
+// and allowed
+type GenerateFunc func(req Request) (any, error)
+
+// Options configures the handler
+type Options struct {
+	// Token is the bearer token clients must send; empty accepts every
+	// client, which is only safe on a private unix socket
+	Token string
+	// Repos are the directories whose repositories may be served
+	Repos []string
+	// MaxRequestBytes bounds the size of a request body
+	MaxRequestBytes int64
+	// MaxConcurrent bounds the requests generated at the same time
+	MaxConcurrent int
+	// Metrics serves /metrics when set
+	Metrics http.Handler
+}
+
+// handler serves the API. It holds no per-request state, so requests are
+// handled concurrently.
+type handler struct {
+	opts     Options
+	repos    []string
+	slots    chan struct{}
+	generate GenerateFunc
+	mux      *http.ServeMux
+}
+
+// NewHandler returns the HTTP API: POST /v1/generate, GET /metrics when
+// metrics are collected, and an unauthenticated GET /healthz
+func NewHandler(opts Options, generate GenerateFunc) (http.Handler, error) {
+	if len(opts.Repos) == 0 {
+		return nil, errors.New("no repository directories are allowed")
+	}
+	if opts.MaxRequestBytes <= 0 || opts.MaxConcurrent <= 0 {
+		return nil, errors.New("request size and concurrency limits must be positive")
+	}
+
+	h := &handler{
+		opts:     opts,
+		slots:    make(chan struct{}, opts.MaxConcurrent),
+		generate: generate,
+		mux:      http.NewServeMux(),
+	}
+	for _, repo := range opts.Repos {
+		resolved, err := resolve(repo)
+		if err != nil {
+			return nil, fmt.Errorf("invalid allowed directory %s: %w", repo, err)
+		}
+		h.repos = append(h.repos, resolved)
+	}
+
+	h.mux.HandleFunc("POST /v1/generate", h.authorized(h.handleGenerate))
+	if opts.Metrics != nil {
+		h.mux.Handle("GET /metrics", h.authorized(opts.Metrics.ServeHTTP))
+	}
+	h.mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("ok\n"))
+	})
+	return h, nil
+}
+
+func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mux.ServeHTTP(w, r)
+}
+
+// authorized rejects requests without the bearer token, when one is set
+func (h *handler) authorized(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if h.opts.Token != "" {
+			token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(h.opts.Token)) != 1 {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="commit-ai"`)
+				writeError(w, http.StatusUnauthorized, "missing or invalid bearer token")
+				return
+			}
+		}
+		next(w, r)
+	}
+}
+
+// handleGenerate decodes, checks and generates a request
+func (h *handler) handleGenerate(w http.ResponseWriter, r *http.Request) {
+	select {
+	case h.slots <- struct{}{}:
+		defer func() { <-h.slots }()
+	default:
+		w.Header().Set("Retry-After", "1")
+		writeError(w, http.StatusServiceUnavailable, "too many concurrent requests")
+		return
+	}
+
+	var req Request
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, h.opts.MaxRequestBytes))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&req); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("request exceeds %d bytes", h.opts.MaxRequestBytes))
+			return
+		}
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid request: %v", err))
+		return
+	}
+
+	if req.Candidates == 0 {
+		req.Candidates = 1
+	}
+	if req.Candidates < 1 || req.Candidates > MaxCandidates {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("candidates must be between 1 and %d", MaxCandidates))
+		return
+	}
+
+	repo, status, err := h.allow(req.Repo)
+	if err != nil {
+		writeError(w, status, err.Error())
+		return
+	}
+	req.Repo = repo
+
+	response, err := h.generate(req)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+// allow resolves repo and checks that it is below an allowed directory,
+// returning the HTTP status to fail the request with otherwise. Symbolic
+// links are resolved first, so a link cannot lead out of the allowed
+// directories.
+func (h *handler) allow(repo string) (string, int, error) {
+	if !filepath.IsAbs(repo) {
+		return "", http.StatusBadRequest, errors.New("repo must be an absolute path")
+	}
+	resolved, err := resolve(repo)
+	if err != nil {
+		return "", http.StatusForbidden, fmt.Errorf("repository %s is not allowed", repo)
+	}
+	for _, allowed := range h.repos {
+		if within(resolved, allowed) {
+			return resolved, 0, nil
+		}
+	}
+	return "", http.StatusForbidden, fmt.Errorf("repository %s is not allowed", repo)
+}
+
+// resolve returns the absolute path of an existing directory with symbolic
+// links resolved
+func resolve(dir string) (string, error) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	resolved, err := filepath.EvalSymlinks(abs)
+	if err != nil {
+		return "", err
+	}
+	info, err := os.Stat(resolved)
+	if err != nil {
+		return "", err
+	}
+	if !info.IsDir() {
+		return "", fmt.Errorf("%s is not a directory", dir)
+	}
+	return resolved, nil
+}
+
+// within reports whether path is dir or below it
+func within(path, dir string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) && !filepath.IsAbs(rel)
+}
+
+// writeJSON writes value as the JSON response
+func writeJSON(w http.ResponseWriter, status int, value any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(value)
+}
+
+// writeError writes a JSON error response
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
+
+// Listen listens on the unix socket at socket or, when it is empty, on the
+// TCP address. The socket is only accessible to its owner; a stale socket
+// left behind by an earlier run is replaced, any other file is not.
+func Listen(address, socket string) (net.Listener, error) {
+	if socket == "" {
+		return net.Listen("tcp", address)
+	}
+
+	if info, err := os.Lstat(socket); err == nil {
+		if info.Mode()&os.ModeSocket == 0 {
+			return nil, fmt.Errorf("%s exists and is not a socket", socket)
+		}
+		if err := os.Remove(socket); err != nil {
+			return nil, fmt.Errorf("failed to remove stale socket: %w", err)
+		}
+	}
+	if err := os.MkdirAll(filepath.Dir(socket), 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create socket directory: %w", err)
+	}
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(socket, 0o600); err != nil {
+		listener.Close()
+		return nil, fmt.Errorf("failed to restrict socket permissions: %w", err)
+	}
+	return listener, nil
+}
@@ -0,0 +1,184 @@
+package serve
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestHandler serves repositories below a temporary directory with
+// generate answering the resolved request
+func newTestHandler(t *testing.T, opts Options, generate GenerateFunc) (http.Handler, string) {
+	t.Helper()
+	allowed, err := filepath.EvalSymlinks(t.TempDir())
+	require.NoError(t, err)
+	require.NoError(t, os.Mkdir(filepath.Join(allowed, "repo"), 0o750))
+
+	opts.Repos = []string{allowed}
+	if opts.MaxRequestBytes == 0 {
+		opts.MaxRequestBytes = 1024
+	}
+	if opts.MaxConcurrent == 0 {
+		opts.MaxConcurrent = 2
+	}
+	if generate == nil {
+		generate = func(req Request) (any, error) {
+			return map[string]any{"repo": req.Repo, "candidates": req.Candidates}, nil
+		}
+	}
+	h, err := NewHandler(opts, generate)
+	require.NoError(t, err)
+	return h, allowed
+}
+
+func post(h http.Handler, token, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/v1/generate", strings.NewReader(body))
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestHandler_Auth(t *testing.T) {
+	h, allowed := newTestHandler(t, Options{Token: "s3cret"}, nil)
+	body := fmt.Sprintf(`{"repo": %q}`, filepath.Join(allowed, "repo"))
+
+	rec := post(h, "", body)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	assert.Equal(t, `Bearer realm="commit-ai"`, rec.Header().Get("WWW-Authenticate"))
+
+	rec = post(h, "wrong", body)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	rec = post(h, "s3cret", body)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var response map[string]any
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+	assert.Equal(t, filepath.Join(allowed, "repo"), response["repo"])
+	assert.EqualValues(t, 1, response["candidates"])
+
+	// Health checks need no token
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
+
+func TestHandler_AllowedRepos(t *testing.T) {
+	h, allowed := newTestHandler(t, Options{}, nil)
+	outside := t.TempDir()
+	require.NoError(t, os.Symlink(outside, filepath.Join(allowed, "link")))
+
+	tests := []struct {
+		repo   string
+		status int
+	}{
+		{filepath.Join(allowed, "repo"), http.StatusOK},
+		{allowed, http.StatusOK},
+		{"repo", http.StatusBadRequest},
+		{outside, http.StatusForbidden},
+		{filepath.Join(allowed, "repo", "..", ".."), http.StatusForbidden},
+		{filepath.Join(allowed, "link"), http.StatusForbidden},
+		{filepath.Join(allowed, "missing"), http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.repo, func(t *testing.T) {
+			rec := post(h, "", fmt.Sprintf(`{"repo": %q}`, tt.repo))
+			assert.Equal(t, tt.status, rec.Code, rec.Body.String())
+		})
+	}
+}
+
+func TestHandler_Limits(t *testing.T) {
+	h, allowed := newTestHandler(t, Options{MaxRequestBytes: 100}, nil)
+	repo := filepath.Join(allowed, "repo")
+
+	rec := post(h, "", fmt.Sprintf(`{"repo": %q, "hint": %q}`, repo, strings.Repeat("x", 200)))
+	assert.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+
+	rec = post(h, "", `{"repo": "/", "unknown": true}`)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec = post(h, "", fmt.Sprintf(`{"repo": %q, "candidates": %d}`, repo, MaxCandidates+1))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, rec.Body.String(), "candidates must be between 1 and 5")
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/generate", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+}
+
+func TestHandler_Concurrency(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	h, allowed := newTestHandler(t, Options{MaxConcurrent: 1}, func(req Request) (any, error) {
+		close(started)
+		<-release
+		return "done", nil
+	})
+	body := fmt.Sprintf(`{"repo": %q}`, allowed)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		assert.Equal(t, http.StatusOK, post(h, "", body).Code)
+	}()
+	<-started
+
+	rec := post(h, "", body)
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	assert.Equal(t, "1", rec.Header().Get("Retry-After"))
+
+	close(release)
+	wg.Wait()
+}
+
+func TestHandler_Metrics(t *testing.T) {
+	metrics := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("commit_ai_requests_total 0\n"))
+	})
+	h, _ := newTestHandler(t, Options{Token: "s3cret", Metrics: metrics}, nil)
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	req.Header.Set("Authorization", "Bearer s3cret")
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), "commit_ai_requests_total")
+}
+
+func TestListen_Socket(t *testing.T) {
+	// Unix socket paths are limited to about 100 bytes, shorter than some
+	// temporary directories
+	dir, err := os.MkdirTemp("", "cai")
+	require.NoError(t, err)
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	socket := filepath.Join(dir, "serve.sock")
+
+	listener, err := Listen("", socket)
+	require.NoError(t, err)
+	info, err := os.Stat(socket)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+	listener.Close()
+
+	// Files other than a stale socket are never replaced
+	require.NoError(t, os.WriteFile(socket, nil, 0o600))
+	_, err = Listen("", socket)
+	assert.ErrorContains(t, err, "is not a socket")
+}
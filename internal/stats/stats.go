@@ -0,0 +1,137 @@
+// Package stats records locally how generated commit messages are received,
+// accepted as generated, edited or rejected, so providers, models and
+// templates can be compared with real data.
+package stats
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// Outcomes of an interactive session
+const (
+	OutcomeAccepted = "accepted"
+	OutcomeEdited   = "edited"
+	OutcomeRejected = "rejected"
+)
+
+// Event is the outcome of one interactive session
+type Event struct {
+	Time          time.Time `json:"time"`
+	Outcome       string    `json:"outcome"`
+	Provider      string    `json:"provider"`
+	Model         string    `json:"model"`
+	Template      string    `json:"template"`
+	Regenerations int       `json:"regenerations,omitempty"`
+}
+
+// Summary aggregates the events of one provider, model and template
+type Summary struct {
+	Provider string `json:"provider"`
+	Model    string `json:"model"`
+	Template string `json:"template"`
+	Accepted int    `json:"accepted"`
+	Edited   int    `json:"edited"`
+	Rejected int    `json:"rejected"`
+}
+
+// Record appends event to the stats file, creating it if needed. Each event
+// is a single JSON line written with O_APPEND, so concurrent writers don't
+// interleave.
+func Record(file string, event Event) error {
+	if err := os.MkdirAll(filepath.Dir(file), 0o750); err != nil {
+		return fmt.Errorf("failed to create stats directory: %w", err)
+	}
+
+	data, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to encode stats event: %w", err)
+	}
+
+	f, err := os.OpenFile(file, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600) // #nosec G304 -- stats file under the config directory
+	if err != nil {
+		return fmt.Errorf("failed to open stats file: %w", err)
+	}
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("failed to write stats file: %w", err)
+	}
+	return f.Close()
+}
+
+// Load reads the events recorded in file. A missing file has no events, and
+// malformed lines, such as one cut short by a crash, are skipped.
+func Load(file string) ([]Event, error) {
+	f, err := os.Open(file) // #nosec G304 -- stats file under the config directory
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open stats file: %w", err)
+	}
+	defer f.Close()
+
+	var events []Event
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var event Event
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			continue
+		}
+		events = append(events, event)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read stats file: %w", err)
+	}
+	return events, nil
+}
+
+// Summarize groups events by provider, model and template, most used first
+func Summarize(events []Event) []Summary {
+	type key struct{ provider, model, template string }
+	index := make(map[key]int)
+	var summaries []Summary
+
+	for _, event := range events {
+		k := key{event.Provider, event.Model, event.Template}
+		i, ok := index[k]
+		if !ok {
+			i = len(summaries)
+			index[k] = i
+			summaries = append(summaries, Summary{Provider: k.provider, Model: k.model, Template: k.template})
+		}
+
+		switch event.Outcome {
+		case OutcomeAccepted:
+			summaries[i].Accepted++
+		case OutcomeEdited:
+			summaries[i].Edited++
+		case OutcomeRejected:
+			summaries[i].Rejected++
+		}
+	}
+
+	sort.SliceStable(summaries, func(i, j int) bool {
+		return summaries[i].Total() > summaries[j].Total()
+	})
+	return summaries
+}
+
+// Total returns the number of sessions in the summary
+func (s Summary) Total() int {
+	return s.Accepted + s.Edited + s.Rejected
+}
+
+// AcceptanceRate returns the share of sessions accepted without edits
+func (s Summary) AcceptanceRate() float64 {
+	if s.Total() == 0 {
+		return 0
+	}
+	return float64(s.Accepted) / float64(s.Total())
+}
@@ -0,0 +1,63 @@
+package stats
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecordLoad(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "commit-ai", "stats.jsonl")
+
+	events, err := Load(file)
+	require.NoError(t, err)
+	assert.Empty(t, events)
+
+	first := Event{Time: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC), Outcome: OutcomeAccepted, Provider: "ollama", Model: "llama2", Template: "default.txt"}
+	second := Event{Time: first.Time.Add(time.Minute), Outcome: OutcomeEdited, Provider: "openai", Model: "gpt-4", Template: "default.txt", Regenerations: 2}
+	require.NoError(t, Record(file, first))
+	require.NoError(t, Record(file, second))
+
+	events, err = Load(file)
+	require.NoError(t, err)
+	assert.Equal(t, []Event{first, second}, events)
+}
+
+func TestLoad_SkipsMalformedLines(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "stats.jsonl")
+	content := `{"outcome":"accepted","provider":"ollama"}
+{"outcome":"rej
+{"outcome":"rejected","provider":"ollama"}
+`
+	require.NoError(t, os.WriteFile(file, []byte(content), 0o600))
+
+	events, err := Load(file)
+	require.NoError(t, err)
+	require.Len(t, events, 2)
+	assert.Equal(t, OutcomeRejected, events[1].Outcome)
+}
+
+func TestSummarize(t *testing.T) {
+	events := []Event{
+		{Outcome: OutcomeAccepted, Provider: "openai", Model: "gpt-4", Template: "default.txt"},
+		{Outcome: OutcomeAccepted, Provider: "ollama", Model: "llama2", Template: "default.txt"},
+		{Outcome: OutcomeEdited, Provider: "ollama", Model: "llama2", Template: "default.txt"},
+		{Outcome: OutcomeRejected, Provider: "ollama", Model: "llama2", Template: "default.txt"},
+		{Outcome: OutcomeAccepted, Provider: "ollama", Model: "llama2", Template: "custom.txt"},
+	}
+
+	summaries := Summarize(events)
+	require.Len(t, summaries, 3)
+
+	assert.Equal(t, Summary{Provider: "ollama", Model: "llama2", Template: "default.txt", Accepted: 1, Edited: 1, Rejected: 1}, summaries[0])
+	assert.Equal(t, 3, summaries[0].Total())
+	assert.InDelta(t, 1.0/3, summaries[0].AcceptanceRate(), 0.001)
+
+	assert.Equal(t, "gpt-4", summaries[1].Model)
+	assert.Equal(t, "custom.txt", summaries[2].Template)
+	assert.Equal(t, 0.0, Summary{}.AcceptanceRate())
+}
@@ -0,0 +1,90 @@
+package ticket
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// DefaultGitHubAPIURL is the API endpoint for github.com
+const DefaultGitHubAPIURL = "https://api.github.com"
+
+// GitHubClient fetches issues of a single repository from the GitHub REST API
+type GitHubClient struct {
+	apiURL string
+	repo   string
+	token  string
+	client *http.Client
+}
+
+// NewGitHubClient creates a GitHub client for the repository identified by
+// "owner/repo" on the given API endpoint
+func NewGitHubClient(apiURL, repo, token string, timeout time.Duration) *GitHubClient {
+	if apiURL == "" {
+		apiURL = DefaultGitHubAPIURL
+	}
+	return &GitHubClient{
+		apiURL: strings.TrimRight(apiURL, "/"),
+		repo:   repo,
+		token:  token,
+		client: &http.Client{Timeout: timeout},
+	}
+}
+
+// Name returns the provider name
+func (c *GitHubClient) Name() string {
+	return ProviderGitHub
+}
+
+// ExtractKey returns the issue number referenced by a branch name as "#<n>"
+func (c *GitHubClient) ExtractKey(branch string) string {
+	return extractIssueNumber(branch)
+}
+
+// FetchIssue fetches the issue with the given "#<n>" key
+func (c *GitHubClient) FetchIssue(key string) (*Issue, error) {
+	endpoint := fmt.Sprintf("%s/repos/%s/issues/%s", c.apiURL, c.repo, strings.TrimPrefix(key, "#"))
+
+	req, err := http.NewRequestWithContext(context.Background(), "GET", endpoint, http.NoBody)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Accept", "application/vnd.github+json")
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to make request to GitHub: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("GitHub API error (status %d): %s", resp.StatusCode, string(body))
+	}
+
+	var githubResp struct {
+		Number  int    `json:"number"`
+		Title   string `json:"title"`
+		Body    string `json:"body"`
+		HTMLURL string `json:"html_url"`
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(&githubResp); err != nil {
+		return nil, fmt.Errorf("failed to decode GitHub response: %w", err)
+	}
+
+	return &Issue{
+		Key:   fmt.Sprintf("#%d", githubResp.Number),
+		Title: githubResp.Title,
+		Body:  githubResp.Body,
+		URL:   githubResp.HTMLURL,
+	}, nil
+}
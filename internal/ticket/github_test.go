@@ -0,0 +1,65 @@
+package ticket
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGitHubClient_ExtractKey(t *testing.T) {
+	client := NewGitHubClient("", "nseba/commit-ai", "", 5*time.Second)
+	tests := []struct {
+		branch string
+		want   string
+	}{
+		{"123-fix-login", "#123"},
+		{"fix/#45", "#45"},
+		{"feature/issue-7-add-thing", "#7"},
+		{"gh-99", "#99"},
+		{"main", ""},
+		{"release/v1.2", ""},
+		{"feature/oauth2-support", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.branch, func(t *testing.T) {
+			assert.Equal(t, tt.want, client.ExtractKey(tt.branch))
+		})
+	}
+}
+
+func TestGitHubClient_FetchIssue(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/repos/nseba/commit-ai/issues/42", r.URL.Path)
+		assert.Equal(t, "Bearer gh-token", r.Header.Get("Authorization"))
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"number": 42, "title": "Support includes", "body": "Share settings.", "html_url": "https://github.com/nseba/commit-ai/issues/42"}`))
+	}))
+	defer server.Close()
+
+	client := NewGitHubClient(server.URL, "nseba/commit-ai", "gh-token", 5*time.Second)
+	issue, err := client.FetchIssue("#42")
+	require.NoError(t, err)
+
+	assert.Equal(t, "#42", issue.Key)
+	assert.Equal(t, "Support includes", issue.Title)
+	assert.Equal(t, "#42: Support includes\n\nShare settings.", issue.Context())
+}
+
+func TestGitHubClient_FetchIssue_NotFound(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"message": "Not Found"}`))
+	}))
+	defer server.Close()
+
+	client := NewGitHubClient(server.URL, "nseba/commit-ai", "", 5*time.Second)
+	_, err := client.FetchIssue("#1")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "status 404")
+}
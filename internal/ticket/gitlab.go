@@ -0,0 +1,86 @@
+package ticket
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// GitLabClient fetches issues of a single project from the GitLab REST API
+type GitLabClient struct {
+	baseURL string
+	project string
+	token   string
+	client  *http.Client
+}
+
+// NewGitLabClient creates a GitLab client for the project identified by its
+// full path (group/subgroup/project) on the instance at baseURL
+func NewGitLabClient(baseURL, project, token string, timeout time.Duration) *GitLabClient {
+	return &GitLabClient{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		project: project,
+		token:   token,
+		client:  &http.Client{Timeout: timeout},
+	}
+}
+
+// Name returns the provider name
+func (c *GitLabClient) Name() string {
+	return ProviderGitLab
+}
+
+// ExtractKey returns the issue number referenced by a branch name as "#<n>"
+func (c *GitLabClient) ExtractKey(branch string) string {
+	return extractIssueNumber(branch)
+}
+
+// FetchIssue fetches the issue with the given "#<n>" key
+func (c *GitLabClient) FetchIssue(key string) (*Issue, error) {
+	endpoint := fmt.Sprintf("%s/api/v4/projects/%s/issues/%s",
+		c.baseURL, url.PathEscape(c.project), strings.TrimPrefix(key, "#"))
+
+	req, err := http.NewRequestWithContext(context.Background(), "GET", endpoint, http.NoBody)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Accept", "application/json")
+	if c.token != "" {
+		req.Header.Set("PRIVATE-TOKEN", c.token)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to make request to GitLab: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("GitLab API error (status %d): %s", resp.StatusCode, string(body))
+	}
+
+	var gitlabResp struct {
+		IID         int    `json:"iid"`
+		Title       string `json:"title"`
+		Description string `json:"description"`
+		WebURL      string `json:"web_url"`
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(&gitlabResp); err != nil {
+		return nil, fmt.Errorf("failed to decode GitLab response: %w", err)
+	}
+
+	return &Issue{
+		Key:   fmt.Sprintf("#%d", gitlabResp.IID),
+		Title: gitlabResp.Title,
+		Body:  gitlabResp.Description,
+		URL:   gitlabResp.WebURL,
+	}, nil
+}
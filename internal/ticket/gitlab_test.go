@@ -0,0 +1,31 @@
+package ticket
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGitLabClient_FetchIssue(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/v4/projects/group%2Fsub%2Fproject/issues/12", r.URL.EscapedPath())
+		assert.Equal(t, "gl-token", r.Header.Get("PRIVATE-TOKEN"))
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"iid": 12, "title": "Fix pipeline", "description": "It is red.", "web_url": "https://gitlab.example.com/group/sub/project/-/issues/12"}`))
+	}))
+	defer server.Close()
+
+	client := NewGitLabClient(server.URL, "group/sub/project", "gl-token", 5*time.Second)
+	assert.Equal(t, "#12", client.ExtractKey("12-fix-pipeline"))
+
+	issue, err := client.FetchIssue("#12")
+	require.NoError(t, err)
+	assert.Equal(t, "#12", issue.Key)
+	assert.Equal(t, "Fix pipeline", issue.Title)
+	assert.Equal(t, "It is red.", issue.Body)
+}
@@ -0,0 +1,95 @@
+package ticket
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// JiraClient fetches issues from the Jira REST API
+type JiraClient struct {
+	baseURL string
+	email   string
+	token   string
+	client  *http.Client
+}
+
+// NewJiraClient creates a Jira client. When email is set the token is sent
+// using Basic auth (Jira Cloud API tokens); otherwise it is sent as a Bearer
+// personal access token (Jira Server/Data Center).
+func NewJiraClient(baseURL, email, token string, timeout time.Duration) *JiraClient {
+	return &JiraClient{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		email:   email,
+		token:   token,
+		client:  &http.Client{Timeout: timeout},
+	}
+}
+
+// Name returns the provider name
+func (c *JiraClient) Name() string {
+	return ProviderJira
+}
+
+// ExtractKey returns the first Jira issue key found in a branch name
+func (c *JiraClient) ExtractKey(branch string) string {
+	return extractIssueKey(branch)
+}
+
+// FetchIssue fetches the summary and description of an issue
+func (c *JiraClient) FetchIssue(key string) (*Issue, error) {
+	endpoint := fmt.Sprintf("%s/rest/api/2/issue/%s?fields=summary,description", c.baseURL, url.PathEscape(key))
+
+	req, err := http.NewRequestWithContext(context.Background(), "GET", endpoint, http.NoBody)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Accept", "application/json")
+	if c.token != "" {
+		if c.email != "" {
+			req.SetBasicAuth(c.email, c.token)
+		} else {
+			req.Header.Set("Authorization", "Bearer "+c.token)
+		}
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to make request to Jira: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("jira API error (status %d): %s", resp.StatusCode, string(body))
+	}
+
+	var jiraResp struct {
+		Key    string `json:"key"`
+		Fields struct {
+			Summary     string `json:"summary"`
+			Description string `json:"description"`
+		} `json:"fields"`
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(&jiraResp); err != nil {
+		return nil, fmt.Errorf("failed to decode Jira response: %w", err)
+	}
+
+	if jiraResp.Key == "" {
+		jiraResp.Key = key
+	}
+
+	return &Issue{
+		Key:   jiraResp.Key,
+		Title: jiraResp.Fields.Summary,
+		Body:  jiraResp.Fields.Description,
+		URL:   fmt.Sprintf("%s/browse/%s", c.baseURL, jiraResp.Key),
+	}, nil
+}
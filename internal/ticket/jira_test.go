@@ -0,0 +1,83 @@
+package ticket
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJiraClient_ExtractKey(t *testing.T) {
+	client := NewJiraClient("https://jira.example.com", "", "", 5*time.Second)
+	tests := []struct {
+		branch string
+		want   string
+	}{
+		{"feature/PROJ-123-add-login", "PROJ-123"},
+		{"bugfix/proj-42", "PROJ-42"},
+		{"ABC2-7", "ABC2-7"},
+		{"main", ""},
+		{"feature/add-login", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.branch, func(t *testing.T) {
+			assert.Equal(t, tt.want, client.ExtractKey(tt.branch))
+		})
+	}
+}
+
+func TestJiraClient_FetchIssue(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/rest/api/2/issue/PROJ-123", r.URL.Path)
+		user, pass, ok := r.BasicAuth()
+		assert.True(t, ok)
+		assert.Equal(t, "dev@example.com", user)
+		assert.Equal(t, "jira-token", pass)
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"key": "PROJ-123", "fields": {"summary": "Add login page", "description": "Users need to log in."}}`))
+	}))
+	defer server.Close()
+
+	client := NewJiraClient(server.URL+"/", "dev@example.com", "jira-token", 5*time.Second)
+	issue, err := client.FetchIssue("PROJ-123")
+	require.NoError(t, err)
+
+	assert.Equal(t, "PROJ-123", issue.Key)
+	assert.Equal(t, "Add login page", issue.Title)
+	assert.Equal(t, server.URL+"/browse/PROJ-123", issue.URL)
+	assert.Equal(t, "PROJ-123: Add login page\n\nUsers need to log in.", issue.Context())
+}
+
+func TestJiraClient_FetchIssue_BearerToken(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer pat-token", r.Header.Get("Authorization"))
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"errorMessages": ["Issue does not exist"]}`))
+	}))
+	defer server.Close()
+
+	client := NewJiraClient(server.URL, "", "pat-token", 5*time.Second)
+	_, err := client.FetchIssue("PROJ-999")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "status 404")
+}
+
+func TestProviders_ImplementInterface(t *testing.T) {
+	providers := []Provider{
+		NewJiraClient("https://jira.example.com", "", "", time.Second),
+		NewGitHubClient("", "owner/repo", "", time.Second),
+		NewGitLabClient("https://gitlab.com", "group/project", "", time.Second),
+		NewLinearClient("", "", time.Second),
+	}
+
+	names := make([]string, 0, len(providers))
+	for _, p := range providers {
+		names = append(names, p.Name())
+	}
+	assert.Equal(t, []string{ProviderJira, ProviderGitHub, ProviderGitLab, ProviderLinear}, names)
+}
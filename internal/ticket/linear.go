@@ -0,0 +1,119 @@
+package ticket
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// DefaultLinearAPIURL is the Linear GraphQL endpoint
+const DefaultLinearAPIURL = "https://api.linear.app/graphql"
+
+// linearIssueQuery fetches an issue by its identifier (e.g. ENG-123)
+const linearIssueQuery = `query Issue($id: String!) { issue(id: $id) { identifier title description url } }`
+
+// LinearClient fetches issues from the Linear GraphQL API
+type LinearClient struct {
+	apiURL string
+	token  string
+	client *http.Client
+}
+
+// NewLinearClient creates a Linear client authenticated with a personal API key
+func NewLinearClient(apiURL, token string, timeout time.Duration) *LinearClient {
+	if apiURL == "" {
+		apiURL = DefaultLinearAPIURL
+	}
+	return &LinearClient{
+		apiURL: apiURL,
+		token:  token,
+		client: &http.Client{Timeout: timeout},
+	}
+}
+
+// Name returns the provider name
+func (c *LinearClient) Name() string {
+	return ProviderLinear
+}
+
+// ExtractKey returns the first Linear issue identifier found in a branch name
+func (c *LinearClient) ExtractKey(branch string) string {
+	return extractIssueKey(branch)
+}
+
+// FetchIssue fetches the issue with the given identifier
+func (c *LinearClient) FetchIssue(key string) (*Issue, error) {
+	reqBody := map[string]interface{}{
+		"query":     linearIssueQuery,
+		"variables": map[string]string{"id": key},
+	}
+
+	jsonData, err := json.Marshal(reqBody)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(context.Background(), "POST", c.apiURL, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	if c.token != "" {
+		// Personal API keys are sent without a scheme
+		req.Header.Set("Authorization", c.token)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to make request to Linear: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("linear API error (status %d): %s", resp.StatusCode, string(body))
+	}
+
+	var linearResp struct {
+		Data struct {
+			Issue *struct {
+				Identifier  string `json:"identifier"`
+				Title       string `json:"title"`
+				Description string `json:"description"`
+				URL         string `json:"url"`
+			} `json:"issue"`
+		} `json:"data"`
+		Errors []struct {
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(&linearResp); err != nil {
+		return nil, fmt.Errorf("failed to decode Linear response: %w", err)
+	}
+
+	if len(linearResp.Errors) > 0 {
+		var messages []string
+		for _, e := range linearResp.Errors {
+			messages = append(messages, e.Message)
+		}
+		return nil, fmt.Errorf("linear API error: %s", strings.Join(messages, "; "))
+	}
+	if linearResp.Data.Issue == nil {
+		return nil, fmt.Errorf("linear issue %s not found", key)
+	}
+
+	issue := linearResp.Data.Issue
+	return &Issue{
+		Key:   issue.Identifier,
+		Title: issue.Title,
+		Body:  issue.Description,
+		URL:   issue.URL,
+	}, nil
+}
@@ -0,0 +1,49 @@
+package ticket
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLinearClient_FetchIssue(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "lin_api_key", r.Header.Get("Authorization"))
+
+		var req struct {
+			Variables map[string]string `json:"variables"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		assert.Equal(t, "ENG-42", req.Variables["id"])
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"data": {"issue": {"identifier": "ENG-42", "title": "Speed up CI", "description": "Cache modules.", "url": "https://linear.app/acme/issue/ENG-42"}}}`))
+	}))
+	defer server.Close()
+
+	client := NewLinearClient(server.URL, "lin_api_key", 5*time.Second)
+	assert.Equal(t, "ENG-42", client.ExtractKey("jane/eng-42-speed-up-ci"))
+
+	issue, err := client.FetchIssue("ENG-42")
+	require.NoError(t, err)
+	assert.Equal(t, "ENG-42", issue.Key)
+	assert.Equal(t, "Speed up CI", issue.Title)
+}
+
+func TestLinearClient_FetchIssue_GraphQLError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"data": {"issue": null}, "errors": [{"message": "Entity not found"}]}`))
+	}))
+	defer server.Close()
+
+	client := NewLinearClient(server.URL, "lin_api_key", 5*time.Second)
+	_, err := client.FetchIssue("ENG-1")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "Entity not found")
+}
@@ -0,0 +1,85 @@
+package ticket
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxBodyLength caps the issue body injected into the prompt
+const maxBodyLength = 2000
+
+// Supported ticket provider names
+const (
+	ProviderJira   = "jira"
+	ProviderGitHub = "github"
+	ProviderGitLab = "gitlab"
+	ProviderLinear = "linear"
+)
+
+// issueKeyPattern matches project-prefixed issue keys such as PROJ-123, used
+// by Jira and Linear
+var issueKeyPattern = regexp.MustCompile(`\b([A-Z][A-Z0-9]+-[0-9]+)\b`)
+
+// issueNumberPattern matches issue numbers in branch names such as
+// 123-fix-login, fix/#123, feature/issue-123 or gh-123, used by GitHub and GitLab
+var issueNumberPattern = regexp.MustCompile(`(?i)(?:^|/)(?:#|issue-?|gh-)?([0-9]+)(?:[-_/]|$)`)
+
+// Provider is an issue tracker adapter. Keys returned by ExtractKey are in the
+// tracker's display form (PROJ-123, #42) and are used verbatim in trailers.
+type Provider interface {
+	// Name returns the provider name used in configuration
+	Name() string
+	// ExtractKey returns the ticket key referenced by a branch name, or an
+	// empty string if there is none
+	ExtractKey(branch string) string
+	// FetchIssue fetches the ticket identified by key
+	FetchIssue(key string) (*Issue, error)
+}
+
+// Issue is a ticket fetched from an issue tracker
+type Issue struct {
+	Key   string
+	Title string
+	Body  string
+	URL   string
+}
+
+// Context formats the issue for inclusion in a prompt
+func (i *Issue) Context() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s: %s", i.Key, i.Title)
+
+	body := strings.TrimSpace(i.Body)
+	if len(body) > maxBodyLength {
+		// Cut before the rune that would be split
+		cut := maxBodyLength
+		for cut > 0 && !utf8.RuneStart(body[cut]) {
+			cut--
+		}
+		body = body[:cut] + "..."
+	}
+	if body != "" {
+		b.WriteString("\n\n")
+		b.WriteString(body)
+	}
+
+	return b.String()
+}
+
+// extractIssueKey returns the first project-prefixed key in a branch name.
+// Branch names often lowercase the key (feature/proj-123-add-x).
+func extractIssueKey(branch string) string {
+	return issueKeyPattern.FindString(strings.ToUpper(branch))
+}
+
+// extractIssueNumber returns the issue number referenced by a branch name as
+// "#<n>", or an empty string if there is none
+func extractIssueNumber(branch string) string {
+	m := issueNumberPattern.FindStringSubmatch(branch)
+	if m == nil {
+		return ""
+	}
+	return "#" + m[1]
+}
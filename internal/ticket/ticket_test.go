@@ -0,0 +1,22 @@
+package ticket
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIssue_Context_TruncatesOnRuneBoundary(t *testing.T) {
+	// "é" is two bytes, so the limit falls inside a rune
+	issue := &Issue{Key: "PROJ-1", Title: "Résumé", Body: "a" + strings.Repeat("é", maxBodyLength)}
+
+	context := issue.Context()
+	assert.True(t, utf8.ValidString(context))
+	assert.True(t, strings.HasSuffix(context, "é..."))
+	assert.Equal(t, "PROJ-1: Résumé\n\na"+strings.Repeat("é", (maxBodyLength-1)/2)+"...", context)
+
+	issue.Body = "short body"
+	assert.Equal(t, "PROJ-1: Résumé\n\nshort body", issue.Context())
+}